@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	gcpvm "extras/scaler/internal/gcp"
+	"extras/scaler/internal/vmstate"
+)
+
+// zoneLister is implemented by providers with candidate zones.
+type zoneLister interface {
+	Zones() []string
+}
+
+// capacityReporter is implemented by providers that track GPU quota and
+// stockouts (the GCP manager).
+type capacityReporter interface {
+	Capacity() gcpvm.Capacity
+}
+
+// imageReporter is implemented by providers that boot VMs from an image
+// family's newest image (the GCP manager).
+type imageReporter interface {
+	Image() gcpvm.ImageVersion
+}
+
+// deletionReporter is implemented by providers that delete VMs in the
+// background (the GCP manager).
+type deletionReporter interface {
+	Deleting() []string
+}
+
+// imageRefresher is implemented by providers that can re-read their image
+// family on demand (the GCP manager).
+type imageRefresher interface {
+	RefreshImage(ctx context.Context) error
+}
+
+// adminAPI serves the operator API under /api/v1/ on --http-addr. Every
+// request must carry "Authorization: Bearer <--admin-token>". It replaces
+// signals and log greps for inspecting and steering a running scaler.
+type adminAPI struct {
+	token        string
+	logger       *slog.Logger
+	scaleSet     string
+	provider     string
+	project      string // GCP project; empty for other providers
+	scaler       *gcpRunnerScaler
+	listener     maxRunnersSetter
+	requestDrain func(reason string)
+	history      *eventHistory
+	logLevel     *slog.LevelVar
+}
+
+// adminStatus is the body of GET /api/v1/status.
+type adminStatus struct {
+	ScaleSet string `json:"scale_set"`
+	Provider string `json:"provider"`
+	Project  string `json:"project,omitempty"`
+	// Build is the version of the scaler serving the status.
+	Build    buildInfo `json:"build"`
+	Draining bool      `json:"draining"`
+	Paused   bool      `json:"paused"`
+	// Pause is the reason and end of the pause while Paused.
+	Pause      *pauseState `json:"pause,omitempty"`
+	MaxRunners int         `json:"max_runners"`
+	MinRunners int         `json:"min_runners"`
+	// MinRunnersOverride is the override MinRunners comes from, if any.
+	MinRunnersOverride *minRunnersOverride `json:"min_runners_override,omitempty"`
+	// Desired is the runner count the last scaling decision aimed for.
+	Desired int          `json:"desired"`
+	Zones   []string     `json:"zones,omitempty"`
+	VMs     []vmstate.VM `json:"vms"`
+	// Jobs maps each runner the scaler saw start a job to that job.
+	Jobs map[string]runningJob `json:"jobs,omitempty"`
+	// Deleting lists the VMs whose deletion is still in flight; they no
+	// longer count toward the pool.
+	Deleting []string `json:"deleting,omitempty"`
+	// Capacity is set for providers with GPU quota (GCP).
+	Capacity *gcpvm.Capacity `json:"capacity,omitempty"`
+	// Image is set when new VMs boot from an image family (GCP).
+	Image *gcpvm.ImageVersion `json:"image,omitempty"`
+	// Budget is set with --daily-budget-usd.
+	Budget *budgetStatus `json:"budget,omitempty"`
+	// IdleCost is set with --idle-cost-alert-usd.
+	IdleCost *idleCostStatus `json:"idle_cost,omitempty"`
+	// CreateBackoffUntil is set while --breaker-threshold failed creates
+	// hold new ones: when the next probe may go.
+	CreateBackoffUntil *time.Time `json:"create_backoff_until,omitempty"`
+	// FairShare is the pool's last share of the GPU quota, with
+	// --fair-share-dir.
+	FairShare *fairShareStatus `json:"fair_share,omitempty"`
+	// Drain is the drain's progress while draining.
+	Drain *drainStatus `json:"drain,omitempty"`
+}
+
+func (a *adminAPI) register(mux *http.ServeMux) {
+	api := a.handler()
+	mux.Handle("/api/v1/", a.authenticate(api))
+	mux.Handle("/debug/", a.authenticate(api))
+}
+
+// handler returns the API's routes, without authentication.
+func (a *adminAPI) handler() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /api/v1/status", a.status)
+	api.HandleFunc("POST /api/v1/drain", a.drain)
+	api.HandleFunc("POST /api/v1/pause", a.pause)
+	api.HandleFunc("POST /api/v1/resume", a.resume)
+	api.HandleFunc("PUT /api/v1/max-runners", a.setMaxRunners)
+	api.HandleFunc("PUT /api/v1/min-runners", a.overrideMinRunners)
+	api.HandleFunc("DELETE /api/v1/min-runners", a.clearMinRunnersOverride)
+	api.HandleFunc("DELETE /api/v1/vms/{runner}", a.deleteVM)
+	api.HandleFunc("POST /api/v1/vms/{runner}/recycle", a.recycleVM)
+	api.HandleFunc("POST /api/v1/image/refresh", a.refreshImage)
+	api.HandleFunc("GET /api/v1/events", a.events)
+	api.HandleFunc("GET /api/v1/log-level", a.getLogLevel)
+	api.HandleFunc("PUT /api/v1/log-level", a.setLogLevel)
+	a.registerDebug(api)
+	return api
+}
+
+func (a *adminAPI) authenticate(next http.Handler) http.Handler {
+	want := []byte("Bearer " + a.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeAdminError(w, http.StatusUnauthorized, errors.New("missing or invalid admin token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *adminAPI) snapshot() adminStatus {
+	maxRunners, _ := a.scaler.limits()
+	jobs, _ := a.scaler.activity.snapshot()
+	st := adminStatus{
+		ScaleSet:   a.scaleSet,
+		Provider:   a.provider,
+		Project:    a.project,
+		Build:      currentBuild(),
+		Draining:   a.scaler.isDraining(),
+		MaxRunners: maxRunners,
+		MinRunners: a.scaler.minRunnersAt(time.Now()),
+		Desired:    int(a.scaler.desired.Load()),
+		VMs:        a.scaler.vmManager.VMs(),
+		Jobs:       jobs,
+		Budget:     a.scaler.budget.status(time.Now()),
+		IdleCost:   a.scaler.idleCost.status(time.Now()),
+		FairShare:  a.scaler.fairShare.status(),
+		Drain:      a.scaler.drainStatus(),
+	}
+	if p := a.scaler.pauseStatus(); p != nil {
+		st.Paused, st.Pause = true, p
+	}
+	st.MinRunnersOverride = a.scaler.minRunnersOverrideAt(time.Now())
+	if until := a.scaler.breaker.Until(); !until.IsZero() {
+		st.CreateBackoffUntil = &until
+	}
+	if zl, ok := a.scaler.vmManager.(zoneLister); ok {
+		st.Zones = zl.Zones()
+	}
+	if dr, ok := a.scaler.vmManager.(deletionReporter); ok {
+		st.Deleting = dr.Deleting()
+	}
+	if cr, ok := a.scaler.vmManager.(capacityReporter); ok {
+		c := cr.Capacity()
+		st.Capacity = &c
+	}
+	if ir, ok := a.scaler.vmManager.(imageReporter); ok {
+		if image := ir.Image(); image.Name != "" {
+			st.Image = &image
+		}
+	}
+	return st
+}
+
+func (a *adminAPI) status(w http.ResponseWriter, r *http.Request) {
+	writeAdminJSON(w, a.snapshot())
+}
+
+func (a *adminAPI) drain(w http.ResponseWriter, r *http.Request) {
+	a.logger.Info("admin API: drain requested", "event", eventAdminAction, "action", "drain", "remote", r.RemoteAddr)
+	a.requestDrain("admin_api")
+	writeAdminJSON(w, a.snapshot())
+}
+
+// pause stops scale-ups. The body is optional: {"reason": "...",
+// "duration": "2h"} records why and resumes by itself after duration.
+func (a *adminAPI) pause(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Reason   string `json:"reason"`
+		Duration string `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeAdminError(w, http.StatusBadRequest, errors.New(`body must be {"reason": "...", "duration": "2h"}, both optional`))
+		return
+	}
+	var d time.Duration
+	if body.Duration != "" {
+		var err error
+		if d, err = time.ParseDuration(body.Duration); err != nil || d <= 0 {
+			writeAdminError(w, http.StatusBadRequest, fmt.Errorf("invalid duration %q: must be positive, e.g. 2h", body.Duration))
+			return
+		}
+	}
+	p := a.scaler.pauseScaleUps(body.Reason, d)
+	attrs := []any{"event", eventAdminAction, "action", "pause", "remote", r.RemoteAddr}
+	if p.Reason != "" {
+		attrs = append(attrs, "reason", p.Reason)
+	}
+	if p.Until != nil {
+		attrs = append(attrs, "until", p.Until.Format(time.RFC3339))
+	}
+	a.logger.Info("admin API: scale-ups paused", attrs...)
+	writeAdminJSON(w, a.snapshot())
+}
+
+func (a *adminAPI) resume(w http.ResponseWriter, r *http.Request) {
+	a.logger.Info("admin API: scale-ups resumed", "event", eventAdminAction, "action", "resume", "remote", r.RemoteAddr)
+	a.scaler.setPaused(false)
+	writeAdminJSON(w, a.snapshot())
+}
+
+// setMaxRunners changes --max-runners until the next restart. A SIGHUP only
+// applies settings whose configured value changed, so it does not undo this
+// unless max-runners itself was edited.
+func (a *adminAPI) setMaxRunners(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		MaxRunners *int `json:"max_runners"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.MaxRunners == nil || *body.MaxRunners < 0 {
+		writeAdminError(w, http.StatusBadRequest, errors.New(`body must be {"max_runners": N} with N >= 0`))
+		return
+	}
+	maxRunners := *body.MaxRunners
+	_, minRunners := a.scaler.limits()
+	a.scaler.setLimits(maxRunners, minRunners)
+	// Draining pins the listener at zero; leave it there.
+	if !a.scaler.isDraining() {
+		a.listener.SetMaxRunners(maxRunners)
+	}
+	a.logger.Info("admin API: max runners changed", "event", eventAdminAction, "action", "set_max_runners", "max_runners", maxRunners, "remote", r.RemoteAddr)
+	writeAdminJSON(w, a.snapshot())
+}
+
+// overrideMinRunners keeps min_runners warm for duration, e.g. ahead of a
+// release, then reverts to --min-runners and --min-runners-schedule. The
+// reconciler creates the extra runners on its next pass.
+func (a *adminAPI) overrideMinRunners(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		MinRunners *int   `json:"min_runners"`
+		Duration   string `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.MinRunners == nil || *body.MinRunners < 0 {
+		writeAdminError(w, http.StatusBadRequest, errors.New(`body must be {"min_runners": N, "duration": "2h"} with N >= 0`))
+		return
+	}
+	d, err := time.ParseDuration(body.Duration)
+	if err != nil || d <= 0 {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("invalid duration %q: must be positive, e.g. 2h", body.Duration))
+		return
+	}
+	if maxRunners, _ := a.scaler.limits(); *body.MinRunners > maxRunners {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("min_runners %d is above max runners %d", *body.MinRunners, maxRunners))
+		return
+	}
+	o := a.scaler.overrideMinRunners(*body.MinRunners, d)
+	a.logger.Info("admin API: min runners overridden", "event", eventAdminAction, "action", "override_min_runners",
+		"min_runners", o.MinRunners, "until", o.Until.Format(time.RFC3339), "remote", r.RemoteAddr)
+	writeAdminJSON(w, a.snapshot())
+}
+
+// clearMinRunnersOverride ends a min runners override before it expires.
+func (a *adminAPI) clearMinRunnersOverride(w http.ResponseWriter, r *http.Request) {
+	if !a.scaler.clearMinRunnersOverride() {
+		writeAdminError(w, http.StatusNotFound, errors.New("min runners are not overridden"))
+		return
+	}
+	a.logger.Info("admin API: min runners override cleared", "event", eventAdminAction, "action", "clear_min_runners_override", "remote", r.RemoteAddr)
+	writeAdminJSON(w, a.snapshot())
+}
+
+// deleteVM force-deletes a runner's VM, e.g. one stuck booting, and removes
+// the runner from GitHub.
+func (a *adminAPI) deleteVM(w http.ResponseWriter, r *http.Request) {
+	runner := r.PathValue("runner")
+	if _, ok := a.trackedVM(runner); !ok {
+		writeAdminError(w, http.StatusNotFound, fmt.Errorf("no VM tracked for runner %q", runner))
+		return
+	}
+	a.logger.Info("admin API: force-deleting VM", "event", eventAdminAction, "action", "delete_vm", "runner", runner, "remote", r.RemoteAddr)
+	if err := a.forceDelete(r.Context(), runner, "deleted"); err != nil {
+		writeAdminError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeAdminJSON(w, a.snapshot())
+}
+
+// recycleVM deletes a runner's VM and its GitHub registration, e.g. one
+// with a corrupted GPU state, leaving the rest of the pool alone. A runner
+// still waiting for its job is replaced by a new runner and VM right away,
+// as after a --boot-timeout, so the queued job does not wait for the next
+// scale-up; a busy runner's job fails with its VM. A runner GitHub would
+// not remove, e.g. because it was just assigned a job, is left alone.
+func (a *adminAPI) recycleVM(w http.ResponseWriter, r *http.Request) {
+	runner := r.PathValue("runner")
+	vm, ok := a.trackedVM(runner)
+	if !ok {
+		writeAdminError(w, http.StatusNotFound, fmt.Errorf("no VM tracked for runner %q", runner))
+		return
+	}
+	a.logger.Info("admin API: recycling VM", "event", eventAdminAction, "action", "recycle_vm", "runner", runner, "busy", vm.Busy, "remote", r.RemoteAddr)
+	if vm.Busy {
+		if err := a.forceDelete(r.Context(), runner, "recycled"); err != nil {
+			writeAdminError(w, http.StatusBadGateway, err)
+			return
+		}
+	} else {
+		// Finish even if the caller gives up waiting for the new VM.
+		if err := a.scaler.replaceRunnerVM(context.WithoutCancel(r.Context()), runner, "recycled"); err != nil {
+			writeAdminError(w, http.StatusConflict, fmt.Errorf("runner %q could not be removed from GitHub, e.g. because it just started a job; its VM was kept: %w", runner, err))
+			return
+		}
+	}
+	writeAdminJSON(w, a.snapshot())
+}
+
+// trackedVM returns the created VM of runner.
+func (a *adminAPI) trackedVM(runner string) (vmstate.VM, bool) {
+	vms := a.scaler.vmManager.VMs()
+	i := slices.IndexFunc(vms, func(vm vmstate.VM) bool {
+		return vm.RunnerName == runner && !vm.Pending
+	})
+	if i < 0 {
+		return vmstate.VM{}, false
+	}
+	return vms[i], true
+}
+
+// forceDelete deletes runner's VM and removes the runner from GitHub,
+// finishing it for reason.
+func (a *adminAPI) forceDelete(ctx context.Context, runner, reason string) error {
+	if err := a.scaler.vmManager.DeleteByRunnerName(ctx, runner); err != nil {
+		return err
+	}
+	a.scaler.runners.finished(runner, reason)
+	a.scaler.activity.jobFinished(runner)
+	a.scaler.latency.forget(runner)
+	a.scaler.removeRunnerFromGitHub(ctx, runner)
+	return nil
+}
+
+// refreshImage re-reads the image family right away instead of at the next
+// periodic check, e.g. after `scaler bake --roll` published an image.
+func (a *adminAPI) refreshImage(w http.ResponseWriter, r *http.Request) {
+	refresher, ok := a.scaler.vmManager.(imageRefresher)
+	if reporter, reports := a.scaler.vmManager.(imageReporter); !ok || !reports || reporter.Image().Name == "" {
+		writeAdminError(w, http.StatusNotFound, errors.New("the scaler does not boot VMs from an image family (--image-family)"))
+		return
+	}
+	a.logger.Info("admin API: image family refresh requested", "event", eventAdminAction, "action", "refresh_image", "remote", r.RemoteAddr)
+	if err := refresher.RefreshImage(r.Context()); err != nil {
+		writeAdminError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeAdminJSON(w, a.snapshot())
+}
+
+// logLevelBody is the body of GET and PUT /api/v1/log-level.
+type logLevelBody struct {
+	Level string `json:"level"`
+}
+
+func (a *adminAPI) getLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeAdminJSON(w, logLevelBody{Level: a.logLevel.Level().String()})
+}
+
+// setLogLevel changes the log level until the next restart, or a SIGHUP
+// that changes --log-level.
+func (a *adminAPI) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	var body logLevelBody
+	var lvl slog.Level
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || lvl.UnmarshalText([]byte(body.Level)) != nil {
+		writeAdminError(w, http.StatusBadRequest, errors.New(`body must be {"level": "debug|info|warn|error"}`))
+		return
+	}
+	a.logLevel.Set(lvl)
+	// Logged at warn so the change shows up at any level.
+	a.logger.Warn("admin API: log level changed", "event", eventAdminAction, "action", "set_log_level", "level", lvl, "remote", r.RemoteAddr)
+	writeAdminJSON(w, logLevelBody{Level: lvl.String()})
+}
+
+func writeAdminJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
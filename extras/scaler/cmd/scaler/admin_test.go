@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"extras/scaler/internal/vmstate"
+)
+
+type fakeAdminProvider struct {
+	vmProvider
+	vms []vmstate.VM
+}
+
+func (p *fakeAdminProvider) VMs() []vmstate.VM { return p.vms }
+
+func (p *fakeAdminProvider) Zones() []string { return []string{"us-east1-c", "us-east1-d"} }
+
+func newTestAdmin() (*adminAPI, *http.ServeMux, *fakeListener) {
+	provider := &fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-1", Name: "win-1", Location: "us-east1-c", Busy: true},
+		{RunnerName: "win-2", Pending: true},
+	}}
+	lst := &fakeListener{maxRunners: 4}
+	a := &adminAPI{
+		token:    "s3cret",
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		scaleSet: "windows-gpu",
+		provider: "gcp",
+		scaler:   &gcpRunnerScaler{vmManager: provider, maxRunners: 4, minRunners: 1},
+		listener: lst,
+		logLevel: new(slog.LevelVar),
+	}
+	a.requestDrain = func(string) {
+		a.scaler.setDraining(true)
+		lst.SetMaxRunners(0)
+	}
+	mux := http.NewServeMux()
+	a.register(mux)
+	return a, mux, lst
+}
+
+func adminRequest(t *testing.T, mux *http.ServeMux, method, path, body string) (int, adminStatus) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	var st adminStatus
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&st); err != nil {
+			t.Fatalf("decoding %s %s: %v", method, path, err)
+		}
+	}
+	return rec.Code, st
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	_, mux, _ := newTestAdmin()
+	for _, auth := range []string{"", "Bearer wrong", "s3cret"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("Authorization %q: status %d, want 401", auth, rec.Code)
+		}
+	}
+}
+
+func TestAdminStatus(t *testing.T) {
+	_, mux, _ := newTestAdmin()
+	code, st := adminRequest(t, mux, http.MethodGet, "/api/v1/status", "")
+	if code != http.StatusOK {
+		t.Fatalf("status code = %d", code)
+	}
+	if st.ScaleSet != "windows-gpu" || st.MaxRunners != 4 || st.MinRunners != 1 || st.Draining || st.Paused {
+		t.Fatalf("status = %+v", st)
+	}
+	if len(st.Zones) != 2 || len(st.VMs) != 2 || !st.VMs[0].Busy || !st.VMs[1].Pending {
+		t.Fatalf("status zones/VMs = %+v / %+v", st.Zones, st.VMs)
+	}
+}
+
+func TestAdminActions(t *testing.T) {
+	a, mux, lst := newTestAdmin()
+
+	if _, st := adminRequest(t, mux, http.MethodPost, "/api/v1/pause", ""); !st.Paused {
+		t.Fatal("pause did not pause scale-ups")
+	}
+	if _, st := adminRequest(t, mux, http.MethodPost, "/api/v1/resume", ""); st.Paused {
+		t.Fatal("resume did not resume scale-ups")
+	}
+
+	if code, _ := adminRequest(t, mux, http.MethodPut, "/api/v1/max-runners", `{"max_runners": -1}`); code != http.StatusBadRequest {
+		t.Fatalf("negative max_runners: status %d, want 400", code)
+	}
+	if _, st := adminRequest(t, mux, http.MethodPut, "/api/v1/max-runners", `{"max_runners": 8}`); st.MaxRunners != 8 || lst.maxRunners != 8 {
+		t.Fatalf("max runners = %d, listener = %d, want 8", st.MaxRunners, lst.maxRunners)
+	}
+
+	if _, st := adminRequest(t, mux, http.MethodPost, "/api/v1/drain", ""); !st.Draining || lst.maxRunners != 0 {
+		t.Fatalf("drain: draining = %v, listener = %d", st.Draining, lst.maxRunners)
+	}
+	adminRequest(t, mux, http.MethodPut, "/api/v1/max-runners", `{"max_runners": 6}`)
+	if max, _ := a.scaler.limits(); max != 6 || lst.maxRunners != 0 {
+		t.Fatalf("while draining: max = %d, listener = %d; want 6 and listener kept at 0", max, lst.maxRunners)
+	}
+}
+
+func TestAdminDeleteUnknownVM(t *testing.T) {
+	_, mux, _ := newTestAdmin()
+	for _, runner := range []string{"nope", "win-2"} { // win-2 is still pending
+		if code, _ := adminRequest(t, mux, http.MethodDelete, "/api/v1/vms/"+runner, ""); code != http.StatusNotFound {
+			t.Fatalf("DELETE %s: status %d, want 404", runner, code)
+		}
+	}
+}
+
+// recycleProvider moves VMs like replaceProvider and records deletions.
+type recycleProvider struct {
+	replaceProvider
+	deleted []string
+}
+
+func (p *recycleProvider) DeleteByRunnerName(_ context.Context, runnerName string) error {
+	p.deleted = append(p.deleted, runnerName)
+	return nil
+}
+
+func TestAdminRecycleVM(t *testing.T) {
+	a, mux, _ := newTestAdmin()
+	provider := &recycleProvider{replaceProvider: replaceProvider{replaced: map[string]string{}, fakeAdminProvider: fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-idle", Name: "win-idle"},
+		{RunnerName: "win-busy", Name: "win-busy", Busy: true},
+		{RunnerName: "win-pending", Pending: true},
+	}}}}
+	client, actions := newFakeActionsClient(t)
+	a.scaler.vmManager = provider
+	a.scaler.logger = a.logger
+	a.scaler.scalesetClient = client
+	a.scaler.scaleSetID = 1
+	a.scaler.vmPrefix = "win"
+
+	if code, _ := adminRequest(t, mux, http.MethodPost, "/api/v1/vms/win-pending/recycle", ""); code != http.StatusNotFound {
+		t.Fatalf("recycling a pending VM: status %d, want 404", code)
+	}
+
+	// GitHub would not remove the runner, which just got a job.
+	actions.removeFailures = 1
+	if code, _ := adminRequest(t, mux, http.MethodPost, "/api/v1/vms/win-idle/recycle", ""); code != http.StatusConflict {
+		t.Fatalf("recycling win-idle as it got a job: status %d, want 409", code)
+	}
+	if len(provider.replaced) != 0 || len(provider.deleted) != 0 {
+		t.Fatalf("replaced = %v, deleted = %v; want the VM kept", provider.replaced, provider.deleted)
+	}
+
+	// A runner waiting for its job gets a replacement.
+	if code, _ := adminRequest(t, mux, http.MethodPost, "/api/v1/vms/win-idle/recycle", ""); code != http.StatusOK {
+		t.Fatalf("recycling win-idle: status %d", code)
+	}
+	if newName, ok := provider.replaced["win-idle"]; !ok || len(actions.registered) != 1 || actions.registered[0] != newName {
+		t.Fatalf("replaced = %v, registered = %v; want win-idle replaced by a new runner", provider.replaced, actions.registered)
+	}
+
+	// A busy one is only deleted.
+	if code, _ := adminRequest(t, mux, http.MethodPost, "/api/v1/vms/win-busy/recycle", ""); code != http.StatusOK {
+		t.Fatalf("recycling win-busy: status %d", code)
+	}
+	if len(provider.deleted) != 1 || provider.deleted[0] != "win-busy" || len(actions.registered) != 1 {
+		t.Fatalf("deleted = %v, registered = %v; want win-busy deleted without a replacement", provider.deleted, actions.registered)
+	}
+	if len(actions.removed) != 2 {
+		t.Fatalf("removed = %v, want both runners' registrations", actions.removed)
+	}
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	a, mux, _ := newTestAdmin()
+	do := func(method, body string) (int, string) {
+		req := httptest.NewRequest(method, "/api/v1/log-level", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var got logLevelBody
+		json.NewDecoder(rec.Body).Decode(&got)
+		return rec.Code, got.Level
+	}
+
+	if code, level := do(http.MethodGet, ""); code != http.StatusOK || level != "INFO" {
+		t.Fatalf("GET: %d %q, want 200 INFO", code, level)
+	}
+	if code, level := do(http.MethodPut, `{"level":"debug"}`); code != http.StatusOK || level != "DEBUG" {
+		t.Fatalf("PUT debug: %d %q, want 200 DEBUG", code, level)
+	}
+	if a.logLevel.Level() != slog.LevelDebug {
+		t.Fatalf("log level = %v, want DEBUG", a.logLevel.Level())
+	}
+	if code, _ := do(http.MethodPut, `{"level":"verbose"}`); code != http.StatusBadRequest {
+		t.Fatalf("PUT verbose: status %d, want 400", code)
+	}
+	if a.logLevel.Level() != slog.LevelDebug {
+		t.Fatal("a rejected PUT should keep the level")
+	}
+}
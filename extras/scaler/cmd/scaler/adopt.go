@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"extras/scaler/internal/github"
+)
+
+// vmAdopter is implemented by providers that can take over the VMs of a
+// previous run (see --adopt-vms).
+type vmAdopter interface {
+	AdoptVMs(ctx context.Context, runners map[string]bool) int
+}
+
+// createRecoverer is implemented by providers that record each create
+// before making it, so a restart can finish the creates a crash cut short
+// (see --state-file).
+type createRecoverer interface {
+	AbandonedCreates() []string
+}
+
+// busySyncer is implemented by providers that can set the busy flags of
+// the VMs of a previous run from GitHub.
+type busySyncer interface {
+	SyncBusy(runners map[string]bool) int
+}
+
+// runnerLister lists the self-hosted runners registered with GitHub.
+type runnerLister interface {
+	Runners(ctx context.Context) ([]github.Runner, error)
+}
+
+// adoptVMs has adopter track the live VMs of the pool left by a previous
+// run whose runners GitHub still lists, before the listener takes its first
+// message. A failed runner list adopts nothing: the VMs then finish their
+// jobs untracked, as without --adopt-vms.
+func adoptVMs(ctx context.Context, adopter vmAdopter, lister runnerLister, vmPrefix string, logger *slog.Logger) {
+	registered, err := poolRunners(ctx, lister, vmPrefix)
+	if err != nil {
+		logger.Warn("failed to list GitHub runners, not adopting VMs", "error", err)
+		return
+	}
+	if n := adopter.AdoptVMs(ctx, registered); n > 0 {
+		logger.Info("adopted VMs from a previous run", "count", n, "registered_runners", len(registered))
+	}
+}
+
+// syncBusy has syncer mark the VMs it tracks from a previous run busy or
+// idle as GitHub lists their runners, before the listener takes its first
+// message. Drain mode and the idle timeouts then see the jobs that started
+// or finished while the scaler was down. A failed runner list keeps the
+// flags as they were saved or adopted.
+func syncBusy(ctx context.Context, syncer busySyncer, lister runnerLister, vmPrefix string, logger *slog.Logger) {
+	registered, err := poolRunners(ctx, lister, vmPrefix)
+	if err != nil {
+		logger.Warn("failed to list GitHub runners, keeping the busy flags of restored VMs", "error", err)
+		return
+	}
+	if n := syncer.SyncBusy(registered); n > 0 {
+		logger.Info("synced busy flags of restored VMs from GitHub", "changed", n)
+	}
+}
+
+// poolRunners maps the name of each runner of the pool registered with
+// GitHub to whether it is running a job.
+func poolRunners(ctx context.Context, lister runnerLister, vmPrefix string) (map[string]bool, error) {
+	runners, err := lister.Runners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	registered := make(map[string]bool)
+	for _, r := range runners {
+		if strings.HasPrefix(r.Name, vmPrefix+"-") {
+			registered[r.Name] = r.Busy
+		}
+	}
+	return registered, nil
+}
+
+// removeAbandonedRunners removes the GitHub registrations of the runners
+// whose VMs a previous run started to create but that never came to exist,
+// so they do not linger offline.
+func (s *gcpRunnerScaler) removeAbandonedRunners(ctx context.Context) {
+	recoverer, ok := s.vmManager.(createRecoverer)
+	if !ok {
+		return
+	}
+	for _, name := range recoverer.AbandonedCreates() {
+		s.logger.Info("removing the runner of a create cut short by a restart", "runner", name)
+		s.removeRunnerFromGitHub(ctx, name)
+	}
+}
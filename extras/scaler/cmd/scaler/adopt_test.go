@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"extras/scaler/internal/github"
+)
+
+type fakeAdopter struct {
+	runners map[string]bool
+}
+
+func (a *fakeAdopter) AdoptVMs(_ context.Context, runners map[string]bool) int {
+	a.runners = runners
+	return len(runners)
+}
+
+type fakeRunnerLister struct {
+	runners []github.Runner
+	err     error
+}
+
+func (l *fakeRunnerLister) Runners(context.Context) ([]github.Runner, error) { return l.runners, l.err }
+
+func TestAdoptVMsMatchesThePoolsRunners(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	adopter := &fakeAdopter{}
+	lister := &fakeRunnerLister{runners: []github.Runner{
+		{Name: "win-test-1a2b3c4d", Status: "online", Busy: true},
+		{Name: "win-test-5e6f7a8b", Status: "offline"},
+		{Name: "linux-test-9c0d1e2f", Status: "online", Busy: true},
+		{Name: "win-test", Status: "online"},
+	}}
+	adoptVMs(context.Background(), adopter, lister, "win-test", logger)
+	want := map[string]bool{"win-test-1a2b3c4d": true, "win-test-5e6f7a8b": false}
+	if !reflect.DeepEqual(adopter.runners, want) {
+		t.Fatalf("runners = %v, want %v", adopter.runners, want)
+	}
+
+	adopter = &fakeAdopter{}
+	adoptVMs(context.Background(), adopter, &fakeRunnerLister{err: errors.New("403 Forbidden")}, "win-test", logger)
+	if adopter.runners != nil {
+		t.Fatal("a failed runner list should adopt nothing")
+	}
+}
+
+type fakeBusySyncer struct {
+	runners map[string]bool
+}
+
+func (s *fakeBusySyncer) SyncBusy(runners map[string]bool) int {
+	s.runners = runners
+	return len(runners)
+}
+
+func TestSyncBusyPassesThePoolsRunners(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	syncer := &fakeBusySyncer{}
+	lister := &fakeRunnerLister{runners: []github.Runner{
+		{Name: "win-test-1a2b3c4d5e6f", Status: "online", Busy: true},
+		{Name: "win-test-7a8b9c0d1e2f", Status: "online"},
+		{Name: "linux-test-9c0d1e2f3a4b", Status: "online", Busy: true},
+	}}
+	syncBusy(context.Background(), syncer, lister, "win-test", logger)
+	want := map[string]bool{"win-test-1a2b3c4d5e6f": true, "win-test-7a8b9c0d1e2f": false}
+	if !reflect.DeepEqual(syncer.runners, want) {
+		t.Fatalf("runners = %v, want %v", syncer.runners, want)
+	}
+
+	syncer = &fakeBusySyncer{}
+	syncBusy(context.Background(), syncer, &fakeRunnerLister{err: errors.New("403 Forbidden")}, "win-test", logger)
+	if syncer.runners != nil {
+		t.Fatal("a failed runner list should leave the busy flags alone")
+	}
+}
+
+func TestLoadConfigValidatesAdoptVMs(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--adopt-vms"); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--adopt-vms", "--provider=libvirt", "--platform=linux"); err == nil {
+		t.Fatal("loadConfig should reject --adopt-vms without --provider=gcp")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/github"
+	"extras/scaler/internal/supervise"
+)
+
+// annotateTimeout bounds the GitHub calls annotating one job.
+const annotateTimeout = 30 * time.Second
+
+// machineTyper is implemented by providers that know their VMs' machine
+// types.
+type machineTyper interface {
+	MachineType(ctx context.Context, runnerName string) (string, error)
+}
+
+// checkRunCreator is the part of the GitHub client jobAnnotator uses.
+type checkRunCreator interface {
+	WorkflowRunHeadSHA(ctx context.Context, owner, repo string, runID int64) (string, error)
+	CreateCheckRun(ctx context.Context, owner, repo string, run github.CheckRun) error
+}
+
+// jobAnnotator attaches a neutral check run to each started job's commit
+// recording the VM it runs on, so a flaky test can be traced to its VM and
+// zone from the workflow run page.
+type jobAnnotator struct {
+	client checkRunCreator
+	logger *slog.Logger
+}
+
+// jobVM is what a job annotation reports about the job's VM.
+type jobVM struct {
+	name        string
+	zone        string
+	machineType string // empty when the provider does not report it
+	boot        time.Duration
+}
+
+// annotateJob looks up the VM running job and records it on the job's
+// workflow run. Failures are logged; annotations are best effort.
+func (s *gcpRunnerScaler) annotateJob(ctx context.Context, job *scaleset.JobStarted, boot time.Duration) {
+	defer supervise.Recover("annotate_job")
+	ctx, cancel := context.WithTimeout(ctx, annotateTimeout)
+	defer cancel()
+
+	vm := jobVM{name: job.RunnerName, boot: boot}
+	for _, v := range s.vmManager.VMs() {
+		if v.RunnerName == job.RunnerName {
+			if v.Name != "" {
+				vm.name = v.Name
+			}
+			vm.zone = v.Location
+			break
+		}
+	}
+	if mt, ok := s.vmManager.(machineTyper); ok {
+		t, err := mt.MachineType(ctx, job.RunnerName)
+		if err != nil {
+			s.logger.Warn("failed to read VM machine type", "runner", job.RunnerName, "error", err)
+		}
+		vm.machineType = t
+	}
+	if err := s.annotator.annotate(ctx, job, vm); err != nil {
+		s.annotator.logger.Warn("failed to annotate job", "runner", job.RunnerName, "job", job.JobDisplayName, "error", err)
+	}
+}
+
+func (a *jobAnnotator) annotate(ctx context.Context, job *scaleset.JobStarted, vm jobVM) error {
+	sha, err := a.client.WorkflowRunHeadSHA(ctx, job.OwnerName, job.RepositoryName, job.WorkflowRunID)
+	if err != nil {
+		return fmt.Errorf("reading workflow run %d: %w", job.WorkflowRunID, err)
+	}
+	title := vm.name
+	if vm.zone != "" {
+		title += " in " + vm.zone
+	}
+	return a.client.CreateCheckRun(ctx, job.OwnerName, job.RepositoryName, github.CheckRun{
+		Name:       "runner VM: " + job.JobDisplayName,
+		HeadSHA:    sha,
+		Conclusion: "neutral",
+		Title:      title,
+		Summary:    jobVMSummary(job, vm),
+	})
+}
+
+// jobVMSummary renders vm as the check run's Markdown summary.
+func jobVMSummary(job *scaleset.JobStarted, vm jobVM) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Job **%s** (run %d) runs on:\n\n", job.JobDisplayName, job.WorkflowRunID)
+	b.WriteString("| | |\n|---|---|\n")
+	row := func(k, v string) {
+		if v != "" {
+			fmt.Fprintf(&b, "| %s | `%s` |\n", k, v)
+		}
+	}
+	row("VM", vm.name)
+	row("Runner", job.RunnerName)
+	row("Zone", vm.zone)
+	row("Machine type", vm.machineType)
+	if vm.boot > 0 {
+		row("Boot time", vm.boot.Round(time.Second).String())
+	}
+	return b.String()
+}
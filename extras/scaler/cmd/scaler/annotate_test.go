@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/github"
+	"extras/scaler/internal/vmstate"
+)
+
+type fakeCheckRuns struct {
+	shaErr error
+	owner  string
+	repo   string
+	runs   []github.CheckRun
+}
+
+func (f *fakeCheckRuns) WorkflowRunHeadSHA(_ context.Context, owner, repo string, runID int64) (string, error) {
+	if runID != 4242 {
+		return "", errors.New("unexpected run ID")
+	}
+	return "abc123", f.shaErr
+}
+
+func (f *fakeCheckRuns) CreateCheckRun(_ context.Context, owner, repo string, run github.CheckRun) error {
+	f.owner, f.repo = owner, repo
+	f.runs = append(f.runs, run)
+	return nil
+}
+
+type machineTypeProvider struct {
+	fakeAdminProvider
+}
+
+func (p *machineTypeProvider) MachineType(_ context.Context, runnerName string) (string, error) {
+	return "g2-standard-8", nil
+}
+
+func testStartedJob() *scaleset.JobStarted {
+	job := &scaleset.JobStarted{RunnerName: "win-1"}
+	job.OwnerName, job.RepositoryName = "shader-slang", "slang"
+	job.WorkflowRunID = 4242
+	job.JobDisplayName = "test (windows, gpu)"
+	return job
+}
+
+func TestAnnotateJobRecordsVM(t *testing.T) {
+	client := &fakeCheckRuns{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := &gcpRunnerScaler{
+		logger: logger,
+		vmManager: &machineTypeProvider{fakeAdminProvider{vms: []vmstate.VM{
+			{RunnerName: "win-1", Name: "win-test-1", Location: "us-east1-c", Busy: true},
+		}}},
+		annotator: &jobAnnotator{client: client, logger: logger},
+	}
+
+	s.annotateJob(context.Background(), testStartedJob(), 3*time.Minute+12400*time.Millisecond)
+	if len(client.runs) != 1 {
+		t.Fatalf("check runs = %d, want 1", len(client.runs))
+	}
+	run := client.runs[0]
+	if client.owner != "shader-slang" || client.repo != "slang" || run.HeadSHA != "abc123" {
+		t.Fatalf("check run on %s/%s@%s, want shader-slang/slang@abc123", client.owner, client.repo, run.HeadSHA)
+	}
+	if run.Name != "runner VM: test (windows, gpu)" || run.Title != "win-test-1 in us-east1-c" || run.Conclusion != "neutral" {
+		t.Fatalf("check run = %+v", run)
+	}
+	for _, want := range []string{"| VM | `win-test-1` |", "| Zone | `us-east1-c` |", "| Machine type | `g2-standard-8` |", "| Boot time | `3m12s` |"} {
+		if !strings.Contains(run.Summary, want) {
+			t.Errorf("summary missing %q:\n%s", want, run.Summary)
+		}
+	}
+}
+
+func TestAnnotateJobOmitsUnknownDetails(t *testing.T) {
+	client := &fakeCheckRuns{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := &gcpRunnerScaler{
+		logger:    logger,
+		vmManager: &fakeAdminProvider{},
+		annotator: &jobAnnotator{client: client, logger: logger},
+	}
+
+	s.annotateJob(context.Background(), testStartedJob(), 0)
+	run := client.runs[0]
+	if run.Title != "win-1" {
+		t.Fatalf("title = %q, want the runner name for an untracked VM", run.Title)
+	}
+	for _, absent := range []string{"Zone", "Machine type", "Boot time"} {
+		if strings.Contains(run.Summary, absent) {
+			t.Errorf("summary should omit %s:\n%s", absent, run.Summary)
+		}
+	}
+
+	client.shaErr = errors.New("404 Not Found")
+	s.annotateJob(context.Background(), testStartedJob(), 0)
+	if len(client.runs) != 1 {
+		t.Fatal("no check run should be created when the workflow run cannot be read")
+	}
+}
+
+func TestLoadConfigAnnotateJobsRequiresApp(t *testing.T) {
+	t.Setenv("SCALER_APP_CLIENT_ID", "")
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--token=ghp_x", "--annotate-jobs"); err == nil {
+		t.Fatal("loadConfig should reject --annotate-jobs with PAT auth")
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--app-client-id=Iv1.app", "--annotate-jobs"); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import "time"
+
+// Decisions recorded in the audit log.
+const (
+	decisionScaleUp       = "scale_up"
+	decisionHold          = "hold"
+	decisionDrainComplete = "drain_complete"
+)
+
+// scaleAudit is one --audit-log line: a HandleDesiredRunnerCount call, what
+// it decided and why, and the outcome of each VM it tried to create.
+type scaleAudit struct {
+	Time        time.Time   `json:"time"`
+	PendingJobs int         `json:"pending_jobs"`
+	Current     int         `json:"current"`
+	Target      int         `json:"target"`
+	MaxRunners  int         `json:"max_runners"`
+	MinRunners  int         `json:"min_runners"`
+	Decision    string      `json:"decision"`
+	Reason      string      `json:"reason,omitempty"`
+	OverQuota   int         `json:"over_quota,omitempty"` // pending jobs held back by --runner-quotas
+	Throttled   int         `json:"throttled,omitempty"`  // VM creates deferred by the create rate limit
+	Headroom    int         `json:"headroom,omitempty"`   // VMs added to the target while the queue grows fast
+	VMs         []vmOutcome `json:"vms,omitempty"`
+	Result      int         `json:"result"` // VM count reported back to the listener
+}
+
+// vmOutcome is one runner of a scale-up. Stage names the step that failed.
+type vmOutcome struct {
+	Runner string `json:"runner"`
+	VM     string `json:"vm,omitempty"`
+	Stage  string `json:"stage,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (s *gcpRunnerScaler) recordDecision(rec scaleAudit) {
+	if err := s.auditLog.Write(rec); err != nil {
+		s.logger.Warn("failed to write audit log", "error", err)
+	}
+}
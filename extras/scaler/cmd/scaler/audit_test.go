@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"extras/scaler/internal/audit"
+)
+
+func TestHandleDesiredRunnerCountAudits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := audit.Open(path, 1<<20, 1)
+	if err != nil {
+		t.Fatalf("audit.Open: %v", err)
+	}
+	s := &gcpRunnerScaler{
+		logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:  &fakePingProvider{}, // 2 active VMs
+		maxRunners: 2,
+		auditLog:   log,
+	}
+	ctx := context.Background()
+
+	s.HandleDesiredRunnerCount(ctx, 2) // at target
+	s.HandleDesiredRunnerCount(ctx, 5) // capped by max runners
+	s.maxRunners = 4
+	s.HandleDesiredRunnerCount(ctx, 1) // above target
+	s.setPaused(true)
+	s.HandleDesiredRunnerCount(ctx, 3)
+	s.setDraining(true)
+	s.HandleDesiredRunnerCount(ctx, 3)
+	log.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer f.Close()
+	var got []scaleAudit
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var rec scaleAudit
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("bad audit line %q: %v", sc.Text(), err)
+		}
+		got = append(got, rec)
+	}
+
+	want := []struct {
+		pending, target int
+		decision        string
+		reason          string
+	}{
+		{2, 2, decisionHold, "at target"},
+		{5, 2, decisionHold, "at max runners"},
+		{1, 1, decisionHold, "above target; VMs are deleted as their jobs complete"},
+		{3, 0, decisionHold, "paused"},
+		{3, 0, decisionHold, "draining"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("audit records = %d, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.PendingJobs != w.pending || g.Target != w.target || g.Decision != w.decision || g.Reason != w.reason ||
+			g.Current != 2 || g.Result != 2 || g.Time.IsZero() {
+			t.Errorf("record %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestDrainCompleteAudited(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := audit.Open(path, 1<<20, 1)
+	if err != nil {
+		t.Fatalf("audit.Open: %v", err)
+	}
+	defer log.Close()
+	s := &gcpRunnerScaler{
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager: idleProvider{},
+		auditLog:  log,
+	}
+	s.setDraining(true)
+	if _, err := s.HandleDesiredRunnerCount(context.Background(), 0); !errors.Is(err, errDrainComplete) {
+		t.Fatalf("err = %v, want errDrainComplete", err)
+	}
+	data, _ := os.ReadFile(path)
+	var rec scaleAudit
+	if err := json.Unmarshal(data, &rec); err != nil || rec.Decision != decisionDrainComplete {
+		t.Fatalf("audit log = %q (%v), want a drain_complete record", data, err)
+	}
+}
+
+// idleProvider is a vmProvider with no VMs.
+type idleProvider struct{ vmProvider }
+
+func (idleProvider) ActiveCount() int { return 0 }
+
+// slowProvider is a provider whose creates wait for release.
+type slowProvider struct {
+	fakeAdminProvider
+	release chan struct{}
+	mu      sync.Mutex
+	created []string
+}
+
+func (p *slowProvider) ActiveCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.created)
+}
+
+func (p *slowProvider) CreateVM(_ context.Context, runnerName, _ string) (string, error) {
+	<-p.release
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.created = append(p.created, runnerName)
+	return runnerName, nil
+}
+
+func TestScaleUpCreatesInBackground(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := audit.Open(path, 1<<20, 1)
+	if err != nil {
+		t.Fatalf("audit.Open: %v", err)
+	}
+	provider := &slowProvider{release: make(chan struct{})}
+	client, _ := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      provider,
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "win",
+		maxRunners:     4,
+		auditLog:       log,
+	}
+	ctx := context.Background()
+
+	// Both calls return while the creates wait; the second one counts them.
+	for range 2 {
+		if got, err := s.HandleDesiredRunnerCount(ctx, 2); err != nil || got != 2 {
+			t.Fatalf("HandleDesiredRunnerCount = %d, %v, want 2 runners being created", got, err)
+		}
+	}
+	close(provider.release)
+	s.creates.Wait()
+	log.Close()
+
+	if len(provider.created) != 2 || s.activeCount() != 2 {
+		t.Fatalf("created = %v, want 2 VMs", provider.created)
+	}
+	data, _ := os.ReadFile(path)
+	var got []scaleAudit
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		var rec scaleAudit
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("bad audit line %q: %v", sc.Text(), err)
+		}
+		got = append(got, rec)
+	}
+	// The hold is recorded right away, the scale-up once its VMs exist.
+	if len(got) != 2 || got[0].Decision != decisionHold || got[1].Decision != decisionScaleUp ||
+		len(got[1].VMs) != 2 || got[1].Result != 2 {
+		t.Fatalf("audit records = %+v, want a hold, then a scale-up with 2 VMs", got)
+	}
+}
+
+func TestScaleUpCooldownHolds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := audit.Open(path, 1<<20, 1)
+	if err != nil {
+		t.Fatalf("audit.Open: %v", err)
+	}
+	s := &gcpRunnerScaler{
+		logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:       idleProvider{}, // creating a VM would panic
+		maxRunners:      4,
+		auditLog:        log,
+		scaleUpCooldown: time.Minute,
+	}
+	s.released(time.Now().Add(-30 * time.Second))
+	if got, err := s.HandleDesiredRunnerCount(context.Background(), 2); err != nil || got != 0 {
+		t.Fatalf("HandleDesiredRunnerCount = %d, %v, want no VMs during the cooldown", got, err)
+	}
+	log.Close()
+	data, _ := os.ReadFile(path)
+	var rec scaleAudit
+	if err := json.Unmarshal(data, &rec); err != nil || rec.Decision != decisionHold || rec.Reason != "cooling down after a VM release" {
+		t.Fatalf("audit log = %q (%v), want a cooldown hold", data, err)
+	}
+	if s.coolingDown(time.Now().Add(31 * time.Second)) {
+		t.Fatal("the cooldown should end a minute after the release")
+	}
+}
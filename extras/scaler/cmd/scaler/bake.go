@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+
+	gcpvm "extras/scaler/internal/gcp"
+	"extras/scaler/internal/startup"
+)
+
+// bakeOptions are the flags of `scaler bake`.
+type bakeOptions struct {
+	config gcpvm.BakeConfig
+	script string
+	labels string
+	// roll has the scaler at addr switch to the new image right away.
+	roll  bool
+	addr  string
+	token string
+}
+
+func (o *bakeOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.config.Project, "gcp-project", "slang-runners", "GCP project the builder VM and the image are created in")
+	fs.StringVar(&o.config.Zone, "gcp-zone", "", "Zone of the builder VM (required), e.g. us-east1-c")
+	fs.StringVar(&o.config.Platform, "platform", "windows", "Image platform: windows or linux")
+	fs.StringVar(&o.config.BaseImage, "base-image", "", "Image or image family the builder boots from (required), e.g. projects/windows-cloud/global/images/family/windows-2022")
+	fs.StringVar(&o.config.MachineType, "machine-type", "", "Builder machine type (default n1-standard-8)")
+	fs.StringVar(&o.config.GPUType, "gcp-gpu-type", "nvidia-tesla-t4", "GPU attached to the builder, or none")
+	fs.Int64Var(&o.config.DiskSizeGB, "disk-size-gb", 0, "Boot disk size of the builder and the image (default 100)")
+	fs.StringVar(&o.config.Network, "gcp-network", "", "VPC network of the builder (default: default)")
+	fs.StringVar(&o.config.Subnetwork, "gcp-subnetwork", "", "Subnetwork of the builder")
+	fs.StringVar(&o.config.ServiceAccount, "gcp-service-account", "", "Service account email of the builder (default: the project's default)")
+	fs.StringVar(&o.config.ImpersonateServiceAccount, "impersonate-service-account", "", "Service account email whose credentials the bake's API calls use, impersonated with the ambient ones")
+	fs.StringVar(&o.script, "script", "", "Provisioning script (required): a local path or gs://bucket/object; PowerShell on Windows, bash on Linux")
+	fs.StringVar(&o.config.ImageFamily, "image-family", "", "Family the new image joins (required)")
+	fs.StringVar(&o.config.ImageName, "image-name", "", "Name of the new image (default: the family and a UTC timestamp)")
+	fs.StringVar(&o.labels, "labels", "", "Comma-separated key=value labels for the builder VM and the image")
+	fs.DurationVar(&o.config.Timeout, "timeout", 0, "Time the provisioning script may take (default 2h)")
+	fs.BoolVar(&o.config.KeepBuilder, "keep-builder", false, "Keep the builder VM after a failed bake, for debugging")
+	fs.BoolVar(&o.roll, "roll", false, "Have the scaler at --addr boot new VMs from the image right away")
+	fs.StringVar(&o.addr, "addr", "127.0.0.1:8080", "The scaler's --http-addr, for --roll")
+	fs.StringVar(&o.token, "token", "", "Admin API token, for --roll (env: SCALER_ADMIN_TOKEN)")
+}
+
+func parseBakeFlags(args []string) (bakeOptions, error) {
+	var o bakeOptions
+	fs := flag.NewFlagSet("scaler bake", flag.ContinueOnError)
+	o.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return bakeOptions{}, err
+	}
+
+	switch {
+	case o.config.Zone == "":
+		return bakeOptions{}, errors.New("--gcp-zone is required")
+	case o.config.BaseImage == "":
+		return bakeOptions{}, errors.New("--base-image is required")
+	case o.script == "":
+		return bakeOptions{}, errors.New("--script is required")
+	case o.config.ImageFamily == "":
+		return bakeOptions{}, errors.New("--image-family is required")
+	case o.config.Platform != "windows" && o.config.Platform != "linux":
+		return bakeOptions{}, fmt.Errorf("--platform must be windows or linux, got %q", o.config.Platform)
+	}
+	var err error
+	if o.config.Labels, err = parseKeyValues(o.labels); err != nil {
+		return bakeOptions{}, fmt.Errorf("--labels: %w", err)
+	}
+	if err := gcpvm.ValidateLabels(o.config.Labels); err != nil {
+		return bakeOptions{}, fmt.Errorf("--labels: %w", err)
+	}
+	return o, nil
+}
+
+// runBake implements `scaler bake [flags]`. It provisions a builder VM with
+// --script and publishes its disk as a new image in --image-family (see
+// gcpvm.Bake). With --roll, a scaler booting VMs from that family switches
+// to the image right away instead of at its next periodic check.
+func runBake(ctx context.Context, args []string, out io.Writer) error {
+	o, err := parseBakeFlags(args)
+	if err != nil {
+		return err
+	}
+	script, err := startup.Read(ctx, o.script)
+	if err != nil {
+		return err
+	}
+	o.config.Script = string(script)
+
+	fmt.Fprintf(out, "Baking %s from %s in %s; this usually takes 20-60 minutes\n", o.config.ImageFamily, o.config.BaseImage, o.config.Zone)
+	image, err := gcpvm.Bake(ctx, o.config)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Created image %s in family %s\n", image, o.config.ImageFamily)
+	if !o.roll {
+		return nil
+	}
+	return rollImage(ctx, o, image, out)
+}
+
+// rollImage has the scaler at o.addr re-read its image family and checks
+// that it picked up image.
+func rollImage(ctx context.Context, o bakeOptions, image string, out io.Writer) error {
+	body, err := (&adminClient{addr: o.addr, token: o.token}).do(ctx, http.MethodPost, "/api/v1/image/refresh", nil)
+	if err != nil {
+		return fmt.Errorf("rolling the pool onto %s: %w", image, err)
+	}
+	var st adminStatus
+	if err := json.Unmarshal(body, &st); err != nil {
+		return err
+	}
+	if st.Image == nil || st.Image.Name != image {
+		current := "none"
+		if st.Image != nil {
+			current = st.Image.Name + " of family " + st.Image.Family
+		}
+		return fmt.Errorf("scale set %s boots VMs from image %s, not %s; check its --image-family", st.ScaleSet, current, image)
+	}
+	fmt.Fprintf(out, "Scale set %s now creates VMs from %s; %d VMs still run an earlier image until their jobs finish\n",
+		st.ScaleSet, image, st.Image.OutdatedVMs)
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gcpvm "extras/scaler/internal/gcp"
+)
+
+func TestParseBakeFlags(t *testing.T) {
+	required := []string{"--gcp-zone=us-east1-c", "--base-image=projects/windows-cloud/global/images/family/windows-2022",
+		"--script=provision.ps1", "--image-family=windows-gpu-runner"}
+	for i := range required {
+		args := append(append([]string{}, required[:i]...), required[i+1:]...)
+		if _, err := parseBakeFlags(args); err == nil {
+			t.Errorf("parseBakeFlags without %s should fail", required[i])
+		}
+	}
+	if _, err := parseBakeFlags(append(required, "--platform=darwin")); err == nil {
+		t.Error("parseBakeFlags should reject --platform=darwin")
+	}
+	if _, err := parseBakeFlags(append(required, "--labels=Team=gfx")); err == nil {
+		t.Error("parseBakeFlags should reject labels GCP does not accept")
+	}
+
+	o, err := parseBakeFlags(append(required, "--labels=team=gfx", "--gcp-gpu-type=none"))
+	if err != nil {
+		t.Fatalf("parseBakeFlags: %v", err)
+	}
+	if o.config.Zone != "us-east1-c" || o.config.GPUType != "none" || o.config.Labels["team"] != "gfx" || o.script != "provision.ps1" {
+		t.Fatalf("options = %+v", o)
+	}
+}
+
+// imageProvider is a fakeAdminProvider booting VMs from an image family.
+type imageProvider struct {
+	fakeAdminProvider
+	image     gcpvm.ImageVersion
+	refreshed gcpvm.ImageVersion
+}
+
+func (p *imageProvider) Image() gcpvm.ImageVersion { return p.image }
+
+func (p *imageProvider) RefreshImage(context.Context) error {
+	p.image = p.refreshed
+	return nil
+}
+
+func TestRollImage(t *testing.T) {
+	a, mux, _ := newTestAdmin()
+	provider := &imageProvider{
+		image:     gcpvm.ImageVersion{Family: "windows-gpu-runner", Name: "windows-gpu-runner-v6"},
+		refreshed: gcpvm.ImageVersion{Family: "windows-gpu-runner", Name: "windows-gpu-runner-v7", OutdatedVMs: 2},
+	}
+	a.scaler.vmManager = provider
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	o := bakeOptions{addr: srv.URL, token: "s3cret"}
+
+	var out bytes.Buffer
+	if err := rollImage(context.Background(), o, "windows-gpu-runner-v7", &out); err != nil {
+		t.Fatalf("rollImage: %v", err)
+	}
+	if want := "now creates VMs from windows-gpu-runner-v7; 2 VMs still run an earlier image"; !strings.Contains(out.String(), want) {
+		t.Fatalf("output %q missing %q", out.String(), want)
+	}
+
+	// A scaler on another family does not pick the image up.
+	err := rollImage(context.Background(), o, "linux-gpu-runner-v3", &out)
+	if err == nil || !strings.Contains(err.Error(), "check its --image-family") {
+		t.Fatalf("rollImage error = %v", err)
+	}
+}
+
+func TestRefreshImageWithoutImageFamily(t *testing.T) {
+	_, mux, _ := newTestAdmin()
+	if code, _ := adminRequest(t, mux, "POST", "/api/v1/image/refresh", ""); code != 404 {
+		t.Fatalf("status %d, want 404 without an image family", code)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/costreport"
+	gcpvm "extras/scaler/internal/gcp"
+	"extras/scaler/internal/supervise"
+)
+
+// labelTimeout bounds the API calls labelling one job's VM.
+const labelTimeout = 30 * time.Second
+
+// jobLabeler is implemented by providers that can stamp a VM with the
+// billing labels of its job (see *gcp.Manager.SetJobLabels).
+type jobLabeler interface {
+	SetJobLabels(ctx context.Context, runnerName string, job gcpvm.JobLabels) error
+}
+
+// labelJob stamps the VM running job with the job's billing labels.
+// Failures are logged; the VM keeps its pool label either way.
+func (s *gcpRunnerScaler) labelJob(ctx context.Context, job *scaleset.JobStarted) {
+	defer supervise.Recover("label_job")
+	ctx, cancel := context.WithTimeout(ctx, labelTimeout)
+	defer cancel()
+	err := s.labeler.SetJobLabels(ctx, job.RunnerName, gcpvm.JobLabels{
+		Repository: job.OwnerName + "/" + job.RepositoryName,
+		Workflow:   workflowFile(job.JobWorkflowRef),
+		RunID:      job.WorkflowRunID,
+	})
+	if err != nil {
+		s.logger.Warn("failed to set billing labels", "runner", job.RunnerName, "job", job.JobDisplayName, "error", err)
+	}
+}
+
+// costsOptions are the flags of `scaler costs`.
+type costsOptions struct {
+	export string
+	period time.Duration
+	end    string
+	format string
+	output string
+}
+
+func (o *costsOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.export, "from-billing-export", "", "Billing export rows of the period as JSON, from bq query --format=json or bq extract")
+	fs.DurationVar(&o.period, "period", 24*time.Hour, "Length of the period the report covers")
+	fs.StringVar(&o.end, "end", "", "End of the period, RFC 3339 (default now)")
+	fs.StringVar(&o.format, "format", "markdown", "Report format: markdown or csv")
+	fs.StringVar(&o.output, "output", "", "File to write the report to (default stdout)")
+}
+
+// runCosts implements `scaler costs --from-billing-export=FILE [flags]
+// AUDIT_LOG...`. It is `scaler cost-report` with the estimates replaced by
+// the billed costs of the Cloud Billing export, joined to the audit logs'
+// jobs by VM name, or by the run ID label of --billing-labels.
+func runCosts(ctx context.Context, args []string, out io.Writer) error {
+	var o costsOptions
+	fs := flag.NewFlagSet("scaler costs", flag.ContinueOnError)
+	o.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if o.export == "" || fs.NArg() == 0 {
+		return errors.New("usage: scaler costs --from-billing-export=FILE [flags] AUDIT_LOG...")
+	}
+	if o.format != "markdown" && o.format != "csv" {
+		return fmt.Errorf("--format must be markdown or csv, not %q", o.format)
+	}
+	if o.period <= 0 {
+		return errors.New("--period must be positive")
+	}
+	end := time.Now()
+	if o.end != "" {
+		t, err := time.Parse(time.RFC3339, o.end)
+		if err != nil {
+			return fmt.Errorf("--end: %w", err)
+		}
+		end = t
+	}
+	start := end.Add(-o.period)
+
+	f, err := os.Open(o.export)
+	if err != nil {
+		return err
+	}
+	rows, err := costreport.ReadBillingExport(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	// Every job is read, so billing rows of jobs outside the period are
+	// not taken for unattributed ones.
+	jobs, err := readAuditJobs(fs.Args(), time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	billed, unattributed := costreport.Reconcile(jobs, rows, start, end)
+	report := costreport.NewBilled(billed, unattributed, start, end)
+
+	text := report.Markdown()
+	if o.format == "csv" {
+		text = report.CSV()
+	}
+	if o.output != "" {
+		return os.WriteFile(o.output, []byte(text), 0o644)
+	}
+	_, err = io.WriteString(out, text)
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/actions/scaleset"
+
+	gcpvm "extras/scaler/internal/gcp"
+)
+
+type fakeLabeler struct {
+	runner string
+	job    gcpvm.JobLabels
+}
+
+func (f *fakeLabeler) SetJobLabels(_ context.Context, runnerName string, job gcpvm.JobLabels) error {
+	f.runner, f.job = runnerName, job
+	return nil
+}
+
+func TestLabelJobPassesTheJob(t *testing.T) {
+	labeler := &fakeLabeler{}
+	s := &gcpRunnerScaler{logger: slog.New(slog.NewTextHandler(io.Discard, nil)), labeler: labeler}
+	job := &scaleset.JobStarted{RunnerName: "win-1"}
+	job.OwnerName, job.RepositoryName = "shader-slang", "slang"
+	job.WorkflowRunID = 4242
+	job.JobWorkflowRef = "shader-slang/slang/.github/workflows/ci.yml@refs/heads/master"
+
+	s.labelJob(context.Background(), job)
+	want := gcpvm.JobLabels{Repository: "shader-slang/slang", Workflow: "ci.yml", RunID: 4242}
+	if labeler.runner != "win-1" || labeler.job != want {
+		t.Fatalf("labelled %s with %+v, want win-1 with %+v", labeler.runner, labeler.job, want)
+	}
+}
+
+func TestCostsReconcilesTheBillingExport(t *testing.T) {
+	dir := t.TempDir()
+	auditPath := filepath.Join(dir, "windows-gpu.jsonl")
+	exportPath := filepath.Join(dir, "billing.json")
+	os.WriteFile(auditPath, []byte(`{"time":"2026-03-03T02:30:00Z","decision":"job_cost","pool":"win-gpu","repository":"shader-slang/slang","workflow":"ci.yml","workflow_run":1,"vm":"win-gpu-1","uptime_seconds":3600,"cost_usd":9}`+"\n"), 0o644)
+	os.WriteFile(exportPath, []byte(`[
+{"cost":"1.5","resource":{"name":"win-gpu-1"},"usage_start_time":"2026-03-03 01:00:00 UTC"},
+{"cost":"1","resource":{"name":"win-gpu-1"},"usage_start_time":"2026-03-03 02:00:00 UTC"},
+{"cost":"0.5","labels":[{"key":"scaler-pool","value":"win-gpu"}],"resource":{"name":"win-gpu-1"},"usage_start_time":"2026-03-03 03:00:00 UTC"}
+]`), 0o644)
+
+	var out bytes.Buffer
+	err := runCosts(context.Background(), []string{"--from-billing-export=" + exportPath, "--end=2026-03-04T00:00:00Z", "--format=csv", auditPath}, &out)
+	if err != nil {
+		t.Fatalf("runCosts: %v", err)
+	}
+	for _, want := range []string{",workflow,shader-slang/slang ci.yml,1,1.00,2.50", ",unattributed,win-gpu,0,0.00,0.50"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("report lacks %q:\n%s", want, out.String())
+		}
+	}
+
+	for _, args := range [][]string{
+		{auditPath},
+		{"--from-billing-export=" + exportPath},
+		{"--from-billing-export=" + filepath.Join(dir, "missing.json"), auditPath},
+		{"--from-billing-export=" + exportPath, "--format=pdf", auditPath},
+	} {
+		if err := runCosts(context.Background(), args, &out); err == nil {
+			t.Errorf("runCosts(%v) should fail", args)
+		}
+	}
+}
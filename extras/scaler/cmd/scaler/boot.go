@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/vmstate"
+)
+
+// runnerStatusChecker looks up a runner's GitHub status: "online",
+// "offline", or "" when it is not registered. *github.Client implements it.
+type runnerStatusChecker interface {
+	RunnerStatus(ctx context.Context, name string) (string, error)
+}
+
+// vmReplacer is implemented by providers that can recreate a VM somewhere
+// else, e.g. in another zone.
+type vmReplacer interface {
+	ReplaceVM(ctx context.Context, runnerName, newRunnerName, jitConfig string) (string, error)
+}
+
+// minBootTimeout leaves a VM time to boot, and the boot watchdog's check
+// interval, a quarter of the timeout, above zero.
+const minBootTimeout = time.Minute
+
+// bootWatchdog finds the VMs whose runner has not come online in GitHub
+// within timeout of the VM being created, or handed a new JIT config for
+// reuse. --boot-timeout replaces them, --zombie-timeout deletes them.
+type bootWatchdog struct {
+	timeout time.Duration
+	status  runnerStatusChecker
+	logger  *slog.Logger
+	// online maps each runner seen online to the boot it was seen for, so
+	// a runner is looked up once per boot.
+	online map[string]time.Time
+}
+
+// stuckBoot is a VM whose runner missed the boot watchdog's timeout.
+type stuckBoot struct {
+	vm vmstate.VM
+	// status is the runner's GitHub status, "not registered" when GitHub
+	// does not list it.
+	status string
+	age    time.Duration
+}
+
+// vmBootStart is when a VM started waiting for its current runner.
+func vmBootStart(vm vmstate.VM) time.Time {
+	if vm.ReusedAt.After(vm.CreatedAt) {
+		return vm.ReusedAt
+	}
+	return vm.CreatedAt
+}
+
+// watchEvery calls check every quarter of timeout, at most a minute apart,
+// until ctx is done. loadConfig keeps the timeouts it is given a minute or
+// longer.
+func watchEvery(ctx context.Context, timeout time.Duration, check func(now time.Time)) {
+	ticker := time.NewTicker(min(timeout/4, time.Minute))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		check(time.Now())
+	}
+}
+
+// stuck returns the VMs of vms that have run no job and whose runner is not
+// online timeout after their boot.
+func (w *bootWatchdog) stuck(ctx context.Context, vms []vmstate.VM, now time.Time) []stuckBoot {
+	var stuck []stuckBoot
+	current := make(map[string]bool)
+	for _, vm := range vms {
+		current[vm.RunnerName] = true
+		since := vmBootStart(vm)
+		if vm.Pending || vm.Busy || since.IsZero() || now.Sub(since) < w.timeout || w.online[vm.RunnerName].Equal(since) {
+			continue
+		}
+		status, err := w.status.RunnerStatus(ctx, vm.RunnerName)
+		if err != nil {
+			w.logger.Warn("failed to look up runner status", "runner", vm.RunnerName, "error", err)
+			continue
+		}
+		if status == "online" {
+			w.online[vm.RunnerName] = since
+			continue
+		}
+		if status == "" {
+			status = "not registered"
+		}
+		stuck = append(stuck, stuckBoot{vm: vm, status: status, age: now.Sub(since)})
+	}
+	for name := range w.online {
+		if !current[name] {
+			delete(w.online, name)
+		}
+	}
+	return stuck
+}
+
+func (s *gcpRunnerScaler) watchBoots(ctx context.Context, w *bootWatchdog) {
+	watchEvery(ctx, w.timeout, func(now time.Time) { s.checkBoots(ctx, w, now) })
+}
+
+func (s *gcpRunnerScaler) checkBoots(ctx context.Context, w *bootWatchdog, now time.Time) {
+	for _, b := range w.stuck(ctx, s.vmManager.VMs(), now) {
+		s.replaceStuckVM(ctx, b.vm, b.status, b.age)
+	}
+}
+
+// replaceStuckVM deletes a VM whose runner never came online, removes the
+// runner's registration and creates a VM for a new runner in its place.
+func (s *gcpRunnerScaler) replaceStuckVM(ctx context.Context, vm vmstate.VM, status string, age time.Duration) {
+	old := vm.RunnerName
+	s.logger.Warn("runner did not come online, replacing its VM",
+		"event", eventBootTimeout,
+		"runner", old,
+		"vm", vm.Name,
+		"location", vm.Location,
+		"status", status,
+		"age", age.Round(time.Second),
+		"phase", vm.Phase,
+		"boot_error", vm.BootError,
+	)
+	s.replaceRunnerVM(ctx, old, "boot_timeout")
+}
+
+// replaceRunnerVM removes the registration of a runner whose VM is of no
+// use, finishing it for reason, and creates a VM for a new runner in its
+// place. While paused it only deletes the VM; the next scale-up after the
+// resume creates what the queue still needs. When GitHub would not remove
+// the runner, e.g. because it was just assigned a job, it leaves the VM
+// alone and returns the error.
+func (s *gcpRunnerScaler) replaceRunnerVM(ctx context.Context, old, reason string) error {
+	if err := s.removeRunnerFromGitHub(ctx, old); err != nil {
+		return err
+	}
+	s.latency.forget(old)
+	s.runners.finished(old, reason)
+
+	if s.isPaused() {
+		s.logger.Info("paused, deleting the VM without a replacement", "runner", old, "reason", reason)
+		if err := s.vmManager.DeleteByRunnerName(ctx, old); err != nil {
+			s.logger.Error("failed to delete VM", "event", eventVMDeleteFailed, "runner", old, "error", err)
+		}
+		return nil
+	}
+
+	name := s.newRunnerName()
+	jit, err := s.scalesetClient.get().GenerateJitRunnerConfig(
+		ctx,
+		&scaleset.RunnerScaleSetJitRunnerSetting{Name: name},
+		s.scaleSetID,
+	)
+	if err != nil {
+		s.logger.Error("failed to generate JIT config", "event", eventJITFailed, "runner", name, "error", err)
+		// The old runner is gone, so its VM is of no use either way.
+		if err := s.vmManager.DeleteByRunnerName(ctx, old); err != nil {
+			s.logger.Error("failed to delete VM", "event", eventVMDeleteFailed, "runner", old, "error", err)
+		}
+		return nil
+	}
+	s.runnerRegistered(name, jit)
+	s.latency.registered(name, time.Now())
+
+	var vmName string
+	if replacer, ok := s.vmManager.(vmReplacer); ok {
+		vmName, err = replacer.ReplaceVM(ctx, old, name, jit.EncodedJITConfig)
+	} else {
+		if err := s.vmManager.DeleteByRunnerName(ctx, old); err != nil {
+			s.logger.Error("failed to delete VM", "event", eventVMDeleteFailed, "runner", old, "error", err)
+		}
+		vmName, err = s.vmManager.CreateVM(ctx, name, jit.EncodedJITConfig)
+	}
+	if err != nil {
+		s.logger.Error("failed to create VM", "event", eventVMCreateFailed, "runner", name, "error", err)
+		s.createFailed(ctx, err)
+		s.latency.forget(name)
+		s.removeRunnerFromGitHub(ctx, name)
+		return nil
+	}
+	s.runners.booting(ctx, name)
+	s.createSucceeded()
+	s.logger.Info("created runner VM", "event", eventVMCreated, "vm", vmName, "runner", name, "replaces", old, "service_account", s.vmIdentity(ctx, nil))
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/actions/scaleset"
+	"github.com/golang-jwt/jwt/v4"
+
+	"extras/scaler/internal/vmstate"
+)
+
+// fakeActions serves the registration and Actions service endpoints the
+// scale set client uses to register and remove runners, recording the
+// runners it was asked to register and remove.
+type fakeActions struct {
+	url string
+
+	mu         sync.Mutex
+	registered []string
+	removed    []string
+	// jitFailures fails that many JIT config requests.
+	jitFailures int
+	// removeFailures fails that many runner removals, as GitHub does for a
+	// runner that was just assigned a job.
+	removeFailures int
+}
+
+func (f *fakeActions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	const agents = "/actions/_apis/distributedtask/pools/0/agents"
+	switch {
+	case r.URL.Path == "/api/v3/repos/shader-slang/slang/actions/runners/registration-token":
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"token": "reg", "expires_at": time.Now().Add(time.Hour)})
+	case r.URL.Path == "/api/v3/actions/runner-registration":
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}).SignedString([]byte("test"))
+		json.NewEncoder(w).Encode(map[string]string{"url": f.url + "/actions", "token": token})
+	case strings.HasSuffix(r.URL.Path, "/generatejitconfig") && f.jitFailures > 0:
+		f.jitFailures--
+		http.Error(w, `{"message":"runner registration failed"}`, http.StatusBadRequest)
+	case strings.HasSuffix(r.URL.Path, "/generatejitconfig"):
+		var setting scaleset.RunnerScaleSetJitRunnerSetting
+		json.NewDecoder(r.Body).Decode(&setting)
+		f.registered = append(f.registered, setting.Name)
+		json.NewEncoder(w).Encode(scaleset.RunnerScaleSetJitRunnerConfig{
+			Runner:           &scaleset.RunnerReference{ID: len(f.registered), Name: setting.Name},
+			EncodedJITConfig: "jit-" + setting.Name,
+		})
+	case r.URL.Path == agents && r.Method == http.MethodGet:
+		name := r.URL.Query().Get("agentName")
+		json.NewEncoder(w).Encode(scaleset.RunnerReferenceList{
+			Count:            1,
+			RunnerReferences: []scaleset.RunnerReference{{ID: 7, Name: name}},
+		})
+	case strings.HasPrefix(r.URL.Path, agents+"/") && r.Method == http.MethodDelete && f.removeFailures > 0:
+		f.removeFailures--
+		http.Error(w, `{"message":"runner is running a job"}`, http.StatusBadRequest)
+	case strings.HasPrefix(r.URL.Path, agents+"/") && r.Method == http.MethodDelete:
+		f.removed = append(f.removed, strings.TrimPrefix(r.URL.Path, agents+"/"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newFakeActionsClient returns a scale set client talking to a fakeActions.
+func newFakeActionsClient(t *testing.T) (*scalesetClientRef, *fakeActions) {
+	t.Helper()
+	f := &fakeActions{}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	f.url = srv.URL
+	cfg := config{registrationURL: srv.URL + "/shader-slang/slang", token: "ghp_test"}
+	client, err := cfg.scalesetClient()
+	if err != nil {
+		t.Fatalf("scalesetClient: %v", err)
+	}
+	return &scalesetClientRef{client: client}, f
+}
+
+type fakeRunnerStatus struct {
+	statuses map[string]string
+	lookups  []string
+}
+
+func (f *fakeRunnerStatus) RunnerStatus(_ context.Context, name string) (string, error) {
+	f.lookups = append(f.lookups, name)
+	return f.statuses[name], nil
+}
+
+// replaceProvider is a provider that can move a VM to another zone.
+type replaceProvider struct {
+	fakeAdminProvider
+	replaced map[string]string // old runner -> new runner
+	jit      string
+}
+
+func (p *replaceProvider) ReplaceVM(_ context.Context, runnerName, newRunnerName, jitConfig string) (string, error) {
+	p.replaced[runnerName] = newRunnerName
+	p.jit = jitConfig
+	return newRunnerName, nil
+}
+
+func TestCheckBootsReplacesRunnersThatNeverCameOnline(t *testing.T) {
+	now := time.Now()
+	provider := &replaceProvider{replaced: map[string]string{}, fakeAdminProvider: fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-stuck", Name: "win-stuck", Location: "us-east1-c", CreatedAt: now.Add(-20 * time.Minute)},
+		{RunnerName: "win-idle", CreatedAt: now.Add(-20 * time.Minute)},
+		{RunnerName: "win-busy", Busy: true, CreatedAt: now.Add(-20 * time.Minute)},
+		{RunnerName: "win-new", CreatedAt: now.Add(-5 * time.Minute)},
+		{RunnerName: "win-reused", CreatedAt: now.Add(-time.Hour), ReusedAt: now.Add(-5 * time.Minute)},
+		{RunnerName: "win-pending", Pending: true},
+	}}}
+	client, actions := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      provider,
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "win",
+	}
+	status := &fakeRunnerStatus{statuses: map[string]string{"win-stuck": "offline", "win-idle": "online"}}
+	w := &bootWatchdog{timeout: 15 * time.Minute, status: status, logger: s.logger, online: map[string]time.Time{}}
+
+	s.checkBoots(context.Background(), w, now)
+
+	if len(status.lookups) != 2 {
+		t.Fatalf("status lookups = %v, want win-stuck and win-idle only", status.lookups)
+	}
+	newName, ok := provider.replaced["win-stuck"]
+	if !ok || len(provider.replaced) != 1 {
+		t.Fatalf("replaced = %v, want only win-stuck", provider.replaced)
+	}
+	if !strings.HasPrefix(newName, "win-") || newName == "win-stuck" {
+		t.Fatalf("replacement runner = %q, want a new name in the pool", newName)
+	}
+	if provider.jit != "jit-"+newName {
+		t.Fatalf("replacement JIT config = %q, want the new runner's", provider.jit)
+	}
+	if len(actions.registered) != 1 || actions.registered[0] != newName {
+		t.Fatalf("registered = %v, want %s", actions.registered, newName)
+	}
+	if len(actions.removed) != 1 {
+		t.Fatalf("removed = %v, want the stuck runner's registration", actions.removed)
+	}
+
+	// An online runner is looked up once per boot.
+	status.lookups = nil
+	s.checkBoots(context.Background(), w, now.Add(time.Minute))
+	for _, name := range status.lookups {
+		if name == "win-idle" {
+			t.Fatal("win-idle was looked up again after being seen online")
+		}
+	}
+}
+
+func TestBootTimeoutIsValidated(t *testing.T) {
+	base := []string{"--url=https://github.com/o/r"}
+	if _, err := testLoadConfig(t, append(base, "--boot-timeout=15m")...); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	for _, v := range []string{"-1m", "3ns", "30s"} {
+		if _, err := testLoadConfig(t, append(base, "--boot-timeout="+v)...); err == nil {
+			t.Errorf("loadConfig(--boot-timeout=%s) should fail", v)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import "time"
+
+// admitCreates drops the plans the create circuit breaker holds back (see
+// --breaker-threshold): all of them while it is open, and all but the probe
+// while it is half-open. Their labels go back to the queue.
+func (s *gcpRunnerScaler) admitCreates(now time.Time, plans []vmPlan) []vmPlan {
+	allowed := s.breaker.Allow(now, len(plans))
+	if allowed == len(plans) {
+		return plans
+	}
+	if allowed > 0 {
+		s.logger.Info("probing whether VM creates work again", "until", s.breaker.Until())
+	}
+	s.deferPlans(plans[allowed:])
+	return plans[:allowed]
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"extras/scaler/internal/breaker"
+)
+
+// failingProvider fails every create until fixed.
+type failingProvider struct {
+	fakeAdminProvider
+	fixed   bool
+	creates int
+}
+
+func (p *failingProvider) ActiveCount() int { return len(p.vms) }
+
+func (p *failingProvider) CreateVM(_ context.Context, runnerName, _ string) (string, error) {
+	p.creates++
+	if !p.fixed {
+		return "", errors.New("ZONE_RESOURCE_POOL_EXHAUSTED")
+	}
+	return runnerName, nil
+}
+
+func TestScaleUpBacksOffAfterFailedCreates(t *testing.T) {
+	provider := &failingProvider{}
+	client, actions := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      provider,
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "win",
+		maxRunners:     10,
+		breaker:        breaker.New(3, time.Hour, time.Hour),
+	}
+	ctx := context.Background()
+	s.HandleDesiredRunnerCount(ctx, 3)
+	s.creates.Wait()
+	if provider.creates != 3 || s.breaker.State(time.Now()) != breaker.Open {
+		t.Fatalf("creates = %d, want 3 failures opening the breaker", provider.creates)
+	}
+
+	// While open, no JIT config is minted and no VM created.
+	registered := len(actions.registered)
+	s.HandleDesiredRunnerCount(ctx, 3)
+	s.creates.Wait()
+	if provider.creates != 3 || len(actions.registered) != registered {
+		t.Fatalf("creates = %d, registered = %d; want nothing while backing off", provider.creates, len(actions.registered))
+	}
+}
+
+func TestScaleUpProbesWhenHalfOpen(t *testing.T) {
+	provider := &failingProvider{}
+	client, _ := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      provider,
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "win",
+		maxRunners:     10,
+		// Half-open right after opening.
+		breaker: breaker.New(1, time.Nanosecond, time.Nanosecond),
+	}
+	ctx := context.Background()
+	s.createFailed(ctx, errors.New("boom"))
+	time.Sleep(time.Millisecond)
+
+	provider.fixed = true
+	s.HandleDesiredRunnerCount(ctx, 3)
+	s.creates.Wait()
+	if provider.creates != 1 || s.breaker.State(time.Now()) != breaker.Closed {
+		t.Fatalf("creates = %d, want a single probe that closes the breaker", provider.creates)
+	}
+	s.HandleDesiredRunnerCount(ctx, 3)
+	s.creates.Wait()
+	if provider.creates != 4 {
+		t.Fatalf("creates = %d, want the rest created once closed", provider.creates)
+	}
+}
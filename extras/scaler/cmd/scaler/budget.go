@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"extras/scaler/internal/notify"
+	"extras/scaler/internal/vmstate"
+)
+
+// budgetStatus is the day's VM spend against --daily-budget-usd, in USD.
+type budgetStatus struct {
+	LimitUSD float64 `json:"limit_usd"`
+	// SpentUSD is the estimated spend since midnight UTC, and ProjectedUSD
+	// that plus what the current VMs cost if they run until the next
+	// midnight.
+	SpentUSD     float64 `json:"spent_usd"`
+	ProjectedUSD float64 `json:"projected_usd"`
+	HourlyUSD    float64 `json:"hourly_usd"`
+}
+
+func (b budgetStatus) over() bool { return b.ProjectedUSD > b.LimitUSD }
+
+// dailyBudget tracks the estimated spend on VMs per UTC day (see
+// --daily-budget-usd). The spend accrues at the VMs' hourly cost between
+// updates, so it is only as fine-grained as the listener's polls.
+type dailyBudget struct {
+	limit float64
+
+	mu      sync.Mutex
+	day     time.Time // midnight UTC starting the day spent is for
+	spent   float64
+	hourly  float64 // the VMs' cost per hour at last
+	last    time.Time
+	alerted bool // whether the day's over-budget alert was sent
+}
+
+func newDailyBudget(limit float64) *dailyBudget {
+	return &dailyBudget{limit: limit}
+}
+
+// update accrues the spend since the last update and records hourly, the
+// current VMs' cost per hour. alert is set the first time in a day the
+// projected spend exceeds the budget.
+func (b *dailyBudget) update(now time.Time, hourly float64) (st budgetStatus, alert bool) {
+	if b == nil {
+		return budgetStatus{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.accrue(now)
+	b.hourly = hourly
+	st = b.statusLocked(now)
+	if st.over() && !b.alerted {
+		b.alerted = true
+		alert = true
+	}
+	return st, alert
+}
+
+// status returns the spend so far, without recording a new hourly cost.
+func (b *dailyBudget) status(now time.Time) *budgetStatus {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.accrue(now)
+	st := b.statusLocked(now)
+	return &st
+}
+
+func (b *dailyBudget) accrue(now time.Time) {
+	now = now.UTC()
+	day := now.Truncate(24 * time.Hour)
+	if !day.Equal(b.day) {
+		b.day, b.spent, b.alerted = day, 0, false
+	}
+	if !b.last.IsZero() && now.After(b.last) {
+		// Only the part since midnight counts toward today.
+		from := b.last
+		if from.Before(day) {
+			from = day
+		}
+		b.spent += b.hourly * now.Sub(from).Hours()
+	}
+	b.last = now
+}
+
+func (b *dailyBudget) statusLocked(now time.Time) budgetStatus {
+	left := b.day.Add(24 * time.Hour).Sub(now.UTC()).Hours()
+	return budgetStatus{
+		LimitUSD:     b.limit,
+		SpentUSD:     b.spent,
+		ProjectedUSD: b.spent + b.hourly*left,
+		HourlyUSD:    b.hourly,
+	}
+}
+
+// checkBudget updates the day's spend with the current VMs' cost and
+// alerts the first time in a day the projected spend exceeds the budget.
+func (s *gcpRunnerScaler) checkBudget(ctx context.Context, now time.Time) budgetStatus {
+	if s.budget == nil {
+		return budgetStatus{}
+	}
+	st, alert := s.budget.update(now, vmHourlyCost(s.vmManager.VMs()))
+	if alert {
+		s.logger.Warn("projected daily spend exceeds the budget, holding scale-ups until midnight UTC", "event", eventBudgetExceeded,
+			"projected_usd", st.ProjectedUSD, "spent_usd", st.SpentUSD, "budget_usd", st.LimitUSD)
+		s.notifier.Notify(ctx, notify.BudgetExceeded, fmt.Sprintf("projected VM spend today is $%.2f, over the $%.2f daily budget ($%.2f spent so far); not creating VMs until midnight UTC",
+			st.ProjectedUSD, st.LimitUSD, st.SpentUSD))
+	}
+	return st
+}
+
+// vmHourlyCost returns the summed estimated hourly cost of vms.
+func vmHourlyCost(vms []vmstate.VM) float64 {
+	total := 0.0
+	for _, vm := range vms {
+		total += vm.HourlyCost
+	}
+	return total
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+
+	"extras/scaler/internal/vmstate"
+)
+
+func TestDailyBudgetAccruesAndResetsAtMidnight(t *testing.T) {
+	b := newDailyBudget(100)
+	start := time.Date(2026, 3, 2, 20, 0, 0, 0, time.UTC)
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+
+	st, alert := b.update(start, 2)
+	if st.SpentUSD != 0 || !near(st.ProjectedUSD, 8) || alert {
+		t.Fatalf("first update = %+v, %v; want $8 projected for the 4 hours left", st, alert)
+	}
+	// Two hours at $2/h, then $40/h for the last two.
+	st, alert = b.update(start.Add(2*time.Hour), 40)
+	if !near(st.SpentUSD, 4) || !near(st.ProjectedUSD, 84) || st.over() || alert {
+		t.Fatalf("update at 22:00 = %+v, %v; want $4 spent, $84 projected", st, alert)
+	}
+	st, alert = b.update(start.Add(2*time.Hour+30*time.Minute), 80)
+	if !near(st.SpentUSD, 24) || !near(st.ProjectedUSD, 144) || !st.over() || !alert {
+		t.Fatalf("update at 22:30 = %+v, %v; want an alert at $144 projected", st, alert)
+	}
+	if _, alert := b.update(start.Add(3*time.Hour), 80); alert {
+		t.Fatal("the alert should be sent once a day")
+	}
+
+	// The hour after midnight counts toward the new day only.
+	st, alert = b.update(start.Add(5*time.Hour), 1)
+	if !near(st.SpentUSD, 80) || !near(st.ProjectedUSD, 103) || !alert {
+		t.Fatalf("update at 01:00 = %+v, %v; want $80 spent and a new alert", st, alert)
+	}
+
+	var none *dailyBudget
+	if st, _ := none.update(start, 1000); st.over() || none.status(start) != nil {
+		t.Fatal("no budget should never be over")
+	}
+}
+
+func TestHandleDesiredRunnerCountHoldsOverBudget(t *testing.T) {
+	s := &gcpRunnerScaler{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager: &budgetProvider{fakeAdminProvider{vms: []vmstate.VM{
+			{RunnerName: "win-1", Name: "win-1", Busy: true, HourlyCost: 0.9},
+			{RunnerName: "win-2", Name: "win-2", Busy: true, HourlyCost: 0.9},
+		}}},
+		maxRunners: 4,
+		budget:     newDailyBudget(0.5),
+	}
+	if got, err := s.HandleDesiredRunnerCount(context.Background(), 4); err != nil || got != 2 {
+		t.Fatalf("HandleDesiredRunnerCount = %d, %v, want the 2 existing VMs kept", got, err)
+	}
+	if st := s.budget.status(time.Now()); st == nil || !st.over() || st.HourlyUSD != 1.8 {
+		t.Fatalf("budget = %+v, want over at $1.80/h", st)
+	}
+}
+
+// budgetProvider reports its VMs as the active ones; creating a VM on it
+// panics.
+type budgetProvider struct {
+	fakeAdminProvider
+}
+
+func (p *budgetProvider) ActiveCount() int { return len(p.vms) }
+
+func TestDailyBudgetFlagIsValidated(t *testing.T) {
+	base := []string{"--url=https://github.com/o/r"}
+	if _, err := testLoadConfig(t, append(base, "--daily-budget-usd=250")...); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	for _, args := range [][]string{
+		{"--daily-budget-usd=-1"},
+		{"--daily-budget-usd=250", "--provider=libvirt", "--platform=linux"},
+	} {
+		if _, err := testLoadConfig(t, append(base, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+}
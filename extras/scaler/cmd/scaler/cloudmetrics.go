@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"extras/scaler/internal/cloudmetrics"
+	gcpvm "extras/scaler/internal/gcp"
+)
+
+// minMetricsInterval keeps writes under Cloud Monitoring's limit of one
+// point per time series every 5 seconds, with headroom.
+const minMetricsInterval = 10 * time.Second
+
+// metricsSample reads the scaler's current counts for export.
+func (s *gcpRunnerScaler) metricsSample() cloudmetrics.Sample {
+	sample := cloudmetrics.Sample{
+		Desired:        int(s.desired.Load()),
+		Creates:        s.createsTotal.Load(),
+		CreateFailures: s.createFailuresTotal.Load(),
+		JobCostUSD:     s.jobCosts.snapshot(),
+	}
+	sample.BootSeconds, sample.QueueSeconds = s.latency.snapshots()
+	if s.createLimit != nil {
+		sample.RateLimited = true
+		sample.CreateTokens, sample.CreatesThrottled = s.createLimit.available(time.Now())
+	}
+	if s.bootPhases {
+		sample.BootPhases = make(map[string]int, len(gcpvm.BootPhases))
+		for _, phase := range gcpvm.BootPhases {
+			sample.BootPhases[phase] = 0
+		}
+	}
+	for _, vm := range s.vmManager.VMs() {
+		sample.Active++
+		if vm.Busy {
+			sample.Busy++
+		} else if _, ok := sample.BootPhases[vm.Phase]; ok {
+			sample.BootPhases[vm.Phase]++
+		}
+	}
+	if cr, ok := s.vmManager.(capacityReporter); ok {
+		c := cr.Capacity()
+		for _, q := range c.Quotas {
+			sample.Quotas = append(sample.Quotas, cloudmetrics.Quota{
+				Region: q.Region, GPUType: q.GPUType, Limit: q.Limit, Usage: q.Usage, Available: q.Available,
+			})
+		}
+		sample.Stockouts = c.StockoutsByZone
+		for _, r := range c.Reservations {
+			sample.Reservations = append(sample.Reservations, cloudmetrics.Reservation{
+				Name: r.Name, Zone: r.Zone, Count: r.Count, InUse: r.InUse,
+			})
+		}
+		sample.ReservedVMs, sample.OnDemandVMs = c.ReservedVMs, c.OnDemandVMs
+	}
+	return sample
+}
+
+// runMetricsExport writes a sample every interval until ctx is done. Write
+// failures are logged; the next tick tries again.
+func runMetricsExport(ctx context.Context, e *cloudmetrics.Exporter, s *gcpRunnerScaler, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := e.Export(ctx, s.metricsSample(), time.Now()); err != nil {
+			logger.Warn("failed to export Cloud Monitoring metrics", "error", err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/cloudmetrics"
+	"extras/scaler/internal/histogram"
+	"extras/scaler/internal/vmstate"
+)
+
+func TestMetricsSampleCountsVMsAndCreates(t *testing.T) {
+	provider := &fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-1", Busy: true},
+		{RunnerName: "win-2"},
+		{RunnerName: "win-3", Pending: true},
+	}}
+	s, _ := newNotifyingScaler(t, provider)
+	s.desired.Store(4)
+	s.createSucceeded()
+	s.createSucceeded()
+	s.createFailed(context.Background(), errors.New("stockout"))
+	s.latency = newRunnerLatency()
+	job := &scaleset.JobStarted{}
+	job.RunnerName = "win-1"
+	job.QueueTime = time.Now().Add(-time.Minute)
+	s.latency.jobStarted(job, time.Now())
+
+	got := s.metricsSample()
+	if got.QueueSeconds.Count != 1 || got.BootSeconds.Count != 0 {
+		t.Fatalf("latency counts = boot %d, queue %d; want 0, 1", got.BootSeconds.Count, got.QueueSeconds.Count)
+	}
+	got.BootSeconds, got.QueueSeconds = histogram.Snapshot{}, histogram.Snapshot{}
+	want := cloudmetrics.Sample{Active: 3, Desired: 4, Busy: 1, Creates: 2, CreateFailures: 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("sample = %+v, want %+v", got, want)
+	}
+}
+
+func TestMetricsSampleIncludesCapacity(t *testing.T) {
+	s, _ := newNotifyingScaler(t, &capacityProvider{capacity: testCapacity(time.Now())})
+	got := s.metricsSample()
+	want := []cloudmetrics.Quota{{Region: "us-east1", GPUType: "nvidia-tesla-t4", Limit: 16, Usage: 16}}
+	if !reflect.DeepEqual(got.Quotas, want) || got.Stockouts["us-east1-d"] != 4 {
+		t.Fatalf("quotas = %+v, stockouts = %v", got.Quotas, got.Stockouts)
+	}
+}
+
+func TestMetricsSampleCountsBootPhases(t *testing.T) {
+	provider := &fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-1", Phase: "driver-ok"},
+		{RunnerName: "win-2", Phase: "failed", BootError: "no GPU"},
+		{RunnerName: "win-3", Phase: "runner-online", Busy: true},
+		{RunnerName: "win-4", Pending: true},
+	}}
+	s, _ := newNotifyingScaler(t, provider)
+	if got := s.metricsSample(); got.BootPhases != nil {
+		t.Fatalf("boot phases = %v without --boot-phases", got.BootPhases)
+	}
+	s.bootPhases = true
+	got := s.metricsSample().BootPhases
+	want := map[string]int{"started": 0, "driver-ok": 1, "runner-configured": 0, "runner-online": 0, "failed": 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("boot phases = %v, want %v", got, want)
+	}
+}
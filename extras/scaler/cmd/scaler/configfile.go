@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// applyConfigFile sets every flag named in the YAML file at path that was
+// not given on the command line. Keys are flag names without the leading
+// dashes, e.g.
+//
+//	max-runners: 16
+//	gcp-zones: [us-east1-c, us-east1-d]
+//
+// Sequences are joined with commas and mappings are flattened to sorted
+// k=v pairs, matching the comma-separated forms the flags accept.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values, err := parseConfigFile(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "config" {
+			return fmt.Errorf("%s: the config file cannot set config", path)
+		}
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown setting %q", path, name)
+		}
+		if explicit[name] {
+			continue
+		}
+		if err := fs.Set(name, values[name]); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, name, err)
+		}
+	}
+	return nil
+}
+
+// parseConfigFile decodes a config file into flag-name -> flag-value pairs.
+func parseConfigFile(data []byte) (map[string]string, error) {
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(raw))
+	for name, v := range raw {
+		s, err := configValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		values[name] = s
+	}
+	return values, nil
+}
+
+func configValue(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if !isScalar(item) {
+				return "", fmt.Errorf("list items must be scalars")
+			}
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, ","), nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(v))
+		for _, k := range keys {
+			if !isScalar(v[k]) {
+				return "", fmt.Errorf("map values must be scalars")
+			}
+			parts = append(parts, k+"="+fmt.Sprint(v[k]))
+		}
+		return strings.Join(parts, ","), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func isScalar(v any) bool {
+	switch v.(type) {
+	case []any, map[string]any, nil:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scaler.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testLoadConfig(t *testing.T, args ...string) (config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("scaler", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return loadConfig(fs, args)
+}
+
+func TestLoadConfigReadsConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `
+url: https://github.com/shader-slang/slang
+max-runners: 16
+gcp-zones: [us-east1-c, us-west1-a]
+gcp-cleanup-interval: 5m
+`)
+	cfg, err := testLoadConfig(t, "--config="+path)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if cfg.maxRunners != 16 {
+		t.Fatalf("maxRunners = %d, want 16", cfg.maxRunners)
+	}
+	if cfg.gcpZones != "us-east1-c,us-west1-a" {
+		t.Fatalf("gcpZones = %q, want joined list", cfg.gcpZones)
+	}
+	if cfg.gcpCleanupInterval != 5*time.Minute {
+		t.Fatalf("gcpCleanupInterval = %v, want 5m", cfg.gcpCleanupInterval)
+	}
+	if cfg.minRunners != 0 {
+		t.Fatalf("minRunners = %d, want flag default 0", cfg.minRunners)
+	}
+}
+
+func TestLoadConfigCommandLineWins(t *testing.T) {
+	path := writeConfigFile(t, "url: https://github.com/shader-slang/slang\nmax-runners: 16\n")
+	cfg, err := testLoadConfig(t, "--config="+path, "--max-runners=3")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if cfg.maxRunners != 3 {
+		t.Fatalf("maxRunners = %d, want command-line value 3", cfg.maxRunners)
+	}
+}
+
+func TestLoadConfigRejectsBadConfigFile(t *testing.T) {
+	for name, contents := range map[string]string{
+		"unknown setting": "max-runnerz: 3\n",
+		"bad value":       "max-runners: lots\n",
+		"nested config":   "config: other.yaml\n",
+		"nested list":     "labels: [[a, b]]\n",
+	} {
+		path := writeConfigFile(t, contents)
+		if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--config="+path); err == nil {
+			t.Fatalf("%s: loadConfig should fail", name)
+		}
+	}
+}
+
+func TestConfigValueFlattensMappings(t *testing.T) {
+	got, err := configValue(map[string]any{"team": "gfx", "cost-center": "ci"})
+	if err != nil {
+		t.Fatalf("configValue: %v", err)
+	}
+	if got != "cost-center=ci,team=gfx" {
+		t.Fatalf("configValue = %q, want sorted k=v pairs", got)
+	}
+}
+
+func TestLoadConfigVMMetadataAndLabelsFromMappings(t *testing.T) {
+	path := writeConfigFile(t, `
+url: https://github.com/shader-slang/slang
+vm-labels:
+  team: gfx
+  cost-center: ci
+vm-metadata:
+  owner: gfx-ci
+`)
+	cfg, err := testLoadConfig(t, "--config="+path)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if cfg.gcpLabels["team"] != "gfx" || cfg.gcpLabels["cost-center"] != "ci" {
+		t.Fatalf("gcpLabels = %v", cfg.gcpLabels)
+	}
+	if cfg.metadata["owner"] != "gfx-ci" {
+		t.Fatalf("metadata = %v", cfg.metadata)
+	}
+
+	bad := writeConfigFile(t, "url: https://github.com/o/r\nvm-labels: {Team: gfx}\n")
+	if _, err := testLoadConfig(t, "--config="+bad); err == nil {
+		t.Fatal("loadConfig should reject invalid GCP label keys")
+	}
+}
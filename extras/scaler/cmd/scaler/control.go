@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// controlSockets is where the CLI looks for a running scaler's
+// --control-socket when neither --socket nor --addr is given: the systemd
+// units' RuntimeDirectory.
+var controlSockets = "/run/scaler-*/scaler.sock"
+
+// serveControlSocket serves the admin API on a unix socket at path, until
+// ctx is done. The socket is only accessible to the scaler's user and group,
+// which is what authenticates its requests, so they need no admin token.
+func serveControlSocket(ctx context.Context, path string, admin *adminAPI, logger *slog.Logger) error {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another scaler", path)
+	}
+	// Left behind by a scaler that crashed.
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0o660); err != nil {
+		ln.Close()
+		return err
+	}
+	handler := admin.handler()
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Who asked, for the admin_action events.
+			r.RemoteAddr = "unix:" + path
+			handler.ServeHTTP(w, r)
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("control socket failed", "path", path, "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.WithoutCancel(ctx))
+	}()
+	logger.Info("serving the admin API on the control socket", "path", path)
+	return nil
+}
+
+// adminFlags are the flags with which the CLI subcommands reach a running
+// scaler: its control socket, or its admin API on --http-addr.
+type adminFlags struct {
+	fs     *flag.FlagSet
+	socket *string
+	pool   *string
+	addr   *string
+	token  *string
+}
+
+func newAdminFlags(fs *flag.FlagSet) *adminFlags {
+	return &adminFlags{
+		fs:     fs,
+		socket: fs.String("socket", "", "The scaler's --control-socket (env: SCALER_SOCKET; default: the only one under /run/scaler-*)"),
+		pool:   fs.String("pool", "", "The pool whose control socket to use, e.g. windows for /run/scaler-windows/scaler.sock"),
+		addr:   fs.String("addr", "127.0.0.1:8080", "The scaler's --http-addr, when it has no control socket"),
+		token:  fs.String("token", "", "Admin API token, with --addr (env: SCALER_ADMIN_TOKEN)"),
+	}
+}
+
+// client returns the admin API client the flags select: --socket or
+// $SCALER_SOCKET, else --pool's control socket, else --addr when given,
+// else the only control socket under controlSockets, else the default
+// --addr.
+func (f *adminFlags) client() (*adminClient, error) {
+	socket := *f.socket
+	if socket == "" && *f.pool != "" {
+		if *f.pool != filepath.Base(*f.pool) {
+			return nil, fmt.Errorf("invalid --pool %q", *f.pool)
+		}
+		socket = strings.Replace(controlSockets, "*", *f.pool, 1)
+	}
+	if socket == "" {
+		socket = os.Getenv("SCALER_SOCKET")
+	}
+	addrSet := false
+	f.fs.Visit(func(fl *flag.Flag) { addrSet = addrSet || fl.Name == "addr" })
+	if socket == "" && !addrSet {
+		found, _ := filepath.Glob(controlSockets)
+		switch len(found) {
+		case 0:
+		case 1:
+			socket = found[0]
+		default:
+			return nil, fmt.Errorf("several scalers run here, pick one with --socket: %s", strings.Join(found, ", "))
+		}
+	}
+	return &adminClient{socket: socket, addr: *f.addr, token: *f.token}, nil
+}
+
+// clients is client for commands that can watch several scalers: without
+// --socket, $SCALER_SOCKET or --addr it returns one client for each control
+// socket under controlSockets.
+func (f *adminFlags) clients() ([]*adminClient, error) {
+	addrSet := false
+	f.fs.Visit(func(fl *flag.Flag) { addrSet = addrSet || fl.Name == "addr" })
+	if *f.socket == "" && *f.pool == "" && os.Getenv("SCALER_SOCKET") == "" && !addrSet {
+		if found, _ := filepath.Glob(controlSockets); len(found) > 1 {
+			clients := make([]*adminClient, len(found))
+			for i, socket := range found {
+				clients[i] = &adminClient{socket: socket}
+			}
+			return clients, nil
+		}
+	}
+	client, err := f.client()
+	if err != nil {
+		return nil, err
+	}
+	return []*adminClient{client}, nil
+}
+
+// adminClient calls a running scaler's admin API over its control socket,
+// or on addr with token.
+type adminClient struct {
+	socket string
+	addr   string
+	token  string
+}
+
+// do makes a request to path, with in as its JSON body if non-nil, and
+// returns the response body.
+func (c *adminClient) do(ctx context.Context, method, path string, in any) ([]byte, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	client := http.DefaultClient
+	base := c.addr
+	if c.socket != "" {
+		client = &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", c.socket)
+			},
+		}}
+		base = "http://scaler"
+	} else if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(base, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.socket == "" {
+		token := c.token
+		if token == "" {
+			token = os.Getenv("SCALER_ADMIN_TOKEN")
+		}
+		if token == "" {
+			return nil, errors.New("--token or SCALER_ADMIN_TOKEN is required without a control socket")
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			return nil, fmt.Errorf("%s: %s", resp.Status, e.Error)
+		}
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// controlOptions are the flags of the control subcommand command.
+type controlOptions struct {
+	command    string
+	target     *adminFlags
+	json       bool
+	reason     string
+	pauseFor   time.Duration
+	minRunners int
+	until      string
+	resetMin   bool
+}
+
+func (o *controlOptions) register(fs *flag.FlagSet) {
+	o.target = newAdminFlags(fs)
+	fs.BoolVar(&o.json, "json", false, "Print the raw JSON")
+	switch o.command {
+	case "pause":
+		fs.StringVar(&o.reason, "reason", "", "Why scale-ups are paused, shown in the status")
+		fs.DurationVar(&o.pauseFor, "for", 0, "Resume by itself after this long, e.g. 2h (default: until `scaler resume`)")
+	case "scale":
+		fs.IntVar(&o.minRunners, "min", -1, "Keep this many runners warm until --until, instead of MAX_RUNNERS")
+		fs.StringVar(&o.until, "until", "", "When a --min override ends: a duration such as 2h, or an RFC 3339 time")
+		fs.BoolVar(&o.resetMin, "reset-min", false, "End a --min override now")
+	}
+}
+
+// runControl implements the subcommands that steer a running scaler:
+// `scaler drain`, `scaler pause [--reason=TEXT] [--for=DURATION]`, `scaler
+// resume`, `scaler scale MAX_RUNNERS`, `scaler scale --min=N --until=WHEN`,
+// `scaler scale --reset-min`, `scaler delete-vm RUNNER` and `scaler recycle
+// RUNNER`. Each prints the scaler's status after the change.
+func runControl(ctx context.Context, command string, args []string, out io.Writer) error {
+	o := controlOptions{command: command}
+	fs := flag.NewFlagSet("scaler "+command, flag.ContinueOnError)
+	o.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var method, path, done string
+	var body any
+	switch command {
+	case "drain", "pause", "resume":
+		if fs.NArg() != 0 {
+			return fmt.Errorf("usage: scaler %s [flags]", command)
+		}
+		method, path = http.MethodPost, "/api/v1/"+command
+		done = map[string]string{"drain": "Drain started", "pause": "Scale-ups paused", "resume": "Scale-ups resumed"}[command]
+		if command == "pause" {
+			if o.pauseFor < 0 {
+				return errors.New("--for must not be negative")
+			}
+			if o.reason != "" || o.pauseFor > 0 {
+				p := map[string]string{"reason": o.reason}
+				if o.pauseFor > 0 {
+					p["duration"] = o.pauseFor.String()
+				}
+				body = p
+			}
+		}
+	case "scale":
+		usage := errors.New("usage: scaler scale [flags] MAX_RUNNERS | --min=N --until=WHEN | --reset-min")
+		switch {
+		case o.resetMin:
+			if fs.NArg() != 0 || o.minRunners >= 0 {
+				return usage
+			}
+			method, path = http.MethodDelete, "/api/v1/min-runners"
+			done = "Min runners back to the configured minimum"
+		case o.minRunners >= 0:
+			if fs.NArg() != 0 || o.until == "" {
+				return usage
+			}
+			d, err := parseUntil(o.until, time.Now())
+			if err != nil {
+				return err
+			}
+			method, path = http.MethodPut, "/api/v1/min-runners"
+			body = map[string]any{"min_runners": o.minRunners, "duration": d.String()}
+			done = fmt.Sprintf("Min runners set to %d for %s", o.minRunners, d)
+		default:
+			n, err := strconv.Atoi(fs.Arg(0))
+			if fs.NArg() != 1 || err != nil || n < 0 || o.until != "" {
+				return usage
+			}
+			method, path, body = http.MethodPut, "/api/v1/max-runners", map[string]int{"max_runners": n}
+			done = fmt.Sprintf("Max runners set to %d until the next restart", n)
+		}
+	case "delete-vm":
+		if fs.NArg() != 1 {
+			return errors.New("usage: scaler delete-vm [flags] RUNNER")
+		}
+		method, path = http.MethodDelete, "/api/v1/vms/"+url.PathEscape(fs.Arg(0))
+		done = "Deleting the VM of " + fs.Arg(0)
+	case "recycle":
+		if fs.NArg() != 1 {
+			return errors.New("usage: scaler recycle [flags] RUNNER")
+		}
+		method, path = http.MethodPost, "/api/v1/vms/"+url.PathEscape(fs.Arg(0))+"/recycle"
+		done = "Recycled the VM of " + fs.Arg(0)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+
+	client, err := o.target.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	if o.json {
+		_, err := out.Write(resp)
+		return err
+	}
+	var st adminStatus
+	if err := json.Unmarshal(resp, &st); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s.\n\n", done)
+	printStatus(out, st, time.Now())
+	return nil
+}
+
+// parseUntil parses --until: a duration from now such as 2h, or an RFC 3339
+// time after now. It returns the duration from now.
+func parseUntil(v string, now time.Time) (time.Duration, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		if d <= 0 {
+			return 0, fmt.Errorf("--until %s must be positive", v)
+		}
+		return d, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return 0, fmt.Errorf("--until %q is neither a duration such as 2h nor an RFC 3339 time", v)
+	}
+	if !t.After(now) {
+		return 0, fmt.Errorf("--until %s is in the past", v)
+	}
+	return t.Sub(now).Round(time.Second), nil
+}
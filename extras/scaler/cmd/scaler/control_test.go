@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlSocket(t *testing.T) {
+	a, _, lst := newTestAdmin()
+	a.token = ""
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "scaler.sock")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if err := serveControlSocket(ctx, path, a, logger); err != nil {
+		t.Fatalf("serveControlSocket: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o660 {
+		t.Fatalf("socket mode = %v, %v; want 0660", info.Mode(), err)
+	}
+	if err := serveControlSocket(ctx, path, a, logger); err == nil {
+		t.Fatal("a second scaler on the same socket should fail")
+	}
+
+	var out bytes.Buffer
+	if err := runControl(ctx, "scale", []string{"--socket", path, "2"}, &out); err != nil {
+		t.Fatalf("scaler scale: %v", err)
+	}
+	if lst.maxRunners != 2 || !strings.Contains(out.String(), "Max runners set to 2") || !strings.Contains(out.String(), "max 2") {
+		t.Fatalf("max runners = %d, output:\n%s", lst.maxRunners, out.String())
+	}
+	out.Reset()
+	a.scaler.logger = logger
+	if err := runControl(ctx, "scale", []string{"--socket", path, "--min", "2", "--until", "2h"}, &out); err != nil {
+		t.Fatalf("scaler scale --min: %v", err)
+	}
+	if got := a.scaler.minRunnersAt(time.Now()); got != 2 || !strings.Contains(out.String(), "Min runners pinned at 2") {
+		t.Fatalf("min runners = %d, output:\n%s", got, out.String())
+	}
+	out.Reset()
+	if err := runControl(ctx, "pause", []string{"--socket", path, "--reason", "GCP maintenance", "--for", "2h"}, &out); err != nil {
+		t.Fatalf("scaler pause: %v", err)
+	}
+	if p := a.scaler.pauseStatus(); p == nil || p.Reason != "GCP maintenance" || p.Until == nil || !strings.Contains(out.String(), "(GCP maintenance); resumes in") {
+		t.Fatalf("pause = %+v, output:\n%s", p, out.String())
+	}
+	out.Reset()
+	if err := runControl(ctx, "drain", []string{"--socket", path}, &out); err != nil {
+		t.Fatalf("scaler drain: %v", err)
+	}
+	if !a.scaler.isDraining() || !strings.Contains(out.String(), "draining") {
+		t.Fatalf("draining = %v, output:\n%s", a.scaler.isDraining(), out.String())
+	}
+	err := runControl(ctx, "delete-vm", []string{"--socket", path, "win-9"}, &out)
+	if err == nil || !strings.Contains(err.Error(), `no VM tracked for runner "win-9"`) {
+		t.Fatalf("scaler delete-vm of an unknown runner: %v", err)
+	}
+
+	// A crashed scaler's socket is replaced.
+	cancel()
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "stale.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := ln.Addr().String()
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	if err := serveControlSocket(ctx2, stale, a, logger); err != nil {
+		t.Fatalf("serveControlSocket over a stale socket: %v", err)
+	}
+}
+
+func TestRunControlUsage(t *testing.T) {
+	for _, args := range [][]string{{"scale"}, {"scale", "many"}, {"delete-vm"}, {"drain", "now"}, {"scale", "--min", "2"}, {"scale", "--min", "2", "--until", "1h", "8"}, {"scale", "--reset-min", "--min", "2"}} {
+		if err := runControl(context.Background(), args[0], args[1:], io.Discard); err == nil || !strings.Contains(err.Error(), "usage") {
+			t.Errorf("runControl(%v) = %v, want a usage error", args, err)
+		}
+	}
+}
+
+func TestAdminFlagsFindControlSocket(t *testing.T) {
+	orig := controlSockets
+	t.Cleanup(func() { controlSockets = orig })
+	dir := t.TempDir()
+	controlSockets = filepath.Join(dir, "scaler-*", "scaler.sock")
+	t.Setenv("SCALER_SOCKET", "")
+
+	client := func(args ...string) (*adminClient, error) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		f := newAdminFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return f.client()
+	}
+	if c, err := client(); err != nil || c.socket != "" || c.addr != "127.0.0.1:8080" {
+		t.Fatalf("without sockets: %+v, %v; want --addr", c, err)
+	}
+	for _, pool := range []string{"scaler-windows", "scaler-linux"} {
+		os.MkdirAll(filepath.Join(dir, pool), 0o755)
+		os.WriteFile(filepath.Join(dir, pool, "scaler.sock"), nil, 0o600)
+		c, err := client()
+		if pool == "scaler-windows" && (err != nil || c.socket != filepath.Join(dir, pool, "scaler.sock")) {
+			t.Fatalf("with one socket: %+v, %v; want it used", c, err)
+		}
+		if pool == "scaler-linux" && err == nil {
+			t.Fatal("with two sockets the client should ask for --socket")
+		}
+	}
+	if c, err := client("--addr", "10.0.0.5:8080"); err != nil || c.socket != "" {
+		t.Fatalf("with --addr: %+v, %v; want HTTP", c, err)
+	}
+	if c, err := client("--pool", "windows"); err != nil || c.socket != filepath.Join(dir, "scaler-windows", "scaler.sock") {
+		t.Fatalf("with --pool: %+v, %v; want the pool's socket", c, err)
+	}
+	if _, err := client("--pool", "../etc"); err == nil {
+		t.Fatal("--pool with a path should fail")
+	}
+	if c, err := client("--socket", "/tmp/x.sock"); err != nil || c.socket != "/tmp/x.sock" {
+		t.Fatalf("with --socket: %+v, %v", c, err)
+	}
+}
+
+func TestAdminClientNeedsTokenOverHTTP(t *testing.T) {
+	_, mux, _ := newTestAdmin()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	t.Setenv("SCALER_ADMIN_TOKEN", "")
+	if _, err := (&adminClient{addr: srv.URL}).do(context.Background(), "GET", "/api/v1/status", nil); err == nil {
+		t.Fatal("a request over HTTP without a token should fail")
+	}
+	if _, err := (&adminClient{addr: srv.URL, token: "s3cret"}).do(context.Background(), "GET", "/api/v1/status", nil); err != nil {
+		t.Fatalf("do: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"extras/scaler/internal/costreport"
+	"extras/scaler/internal/github"
+)
+
+// costReportSlackRows bounds each breakdown of the report posted to Slack.
+const costReportSlackRows = 10
+
+// issueCreator is the part of the GitHub client `scaler cost-report` uses.
+type issueCreator interface {
+	CreateIssue(ctx context.Context, owner, repo, title, body string) error
+}
+
+// costReportOptions are the flags of `scaler cost-report`.
+type costReportOptions struct {
+	period       time.Duration
+	end          string
+	format       string
+	output       string
+	slackWebhook string
+	issueRepo    string
+	token        string
+}
+
+func (o *costReportOptions) register(fs *flag.FlagSet) {
+	fs.DurationVar(&o.period, "period", 24*time.Hour, "Length of the period the report covers")
+	fs.StringVar(&o.end, "end", "", "End of the period, RFC 3339 (default now)")
+	fs.StringVar(&o.format, "format", "markdown", "Report format: markdown or csv")
+	fs.StringVar(&o.output, "output", "", "File to write the report to (default stdout)")
+	fs.StringVar(&o.slackWebhook, "slack-webhook", "", "Slack incoming webhook to post a summary to (env: SCALER_NOTIFY_WEBHOOK)")
+	fs.StringVar(&o.issueRepo, "github-issue", "", "URL of a repository to open an issue with the report in, e.g. https://github.com/org/infra")
+	fs.StringVar(&o.token, "token", "", "GitHub PAT for --github-issue (env: SCALER_TOKEN)")
+}
+
+// runCostReport implements `scaler cost-report [flags] AUDIT_LOG...`. It
+// totals the job costs the audit logs record (see --job-costs) over a
+// period, per pool, repository and workflow, and writes them as Markdown or
+// CSV, optionally also posting them to Slack or a GitHub issue. Run it from
+// a timer, e.g. daily with --period=24h or weekly with --period=168h.
+func runCostReport(ctx context.Context, args []string, out io.Writer) error {
+	var o costReportOptions
+	fs := flag.NewFlagSet("scaler cost-report", flag.ContinueOnError)
+	o.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: scaler cost-report [flags] AUDIT_LOG...")
+	}
+	if o.format != "markdown" && o.format != "csv" {
+		return fmt.Errorf("--format must be markdown or csv, not %q", o.format)
+	}
+	if o.period <= 0 {
+		return errors.New("--period must be positive")
+	}
+	end := time.Now()
+	if o.end != "" {
+		t, err := time.Parse(time.RFC3339, o.end)
+		if err != nil {
+			return fmt.Errorf("--end: %w", err)
+		}
+		end = t
+	}
+	if o.slackWebhook == "" {
+		o.slackWebhook = os.Getenv("SCALER_NOTIFY_WEBHOOK")
+	}
+	if o.token == "" {
+		o.token = os.Getenv("SCALER_TOKEN")
+	}
+
+	report, err := buildCostReport(fs.Args(), end.Add(-o.period), end)
+	if err != nil {
+		return err
+	}
+	text := report.Markdown()
+	if o.format == "csv" {
+		text = report.CSV()
+	}
+	if o.output != "" {
+		if err := os.WriteFile(o.output, []byte(text), 0o644); err != nil {
+			return err
+		}
+	} else if _, err := io.WriteString(out, text); err != nil {
+		return err
+	}
+
+	if o.slackWebhook != "" {
+		if err := postSlack(ctx, o.slackWebhook, "```\n"+report.Text(costReportSlackRows)+"```"); err != nil {
+			return fmt.Errorf("posting to Slack: %w", err)
+		}
+	}
+	if o.issueRepo != "" {
+		owner, repo, err := repoFromURL(o.issueRepo)
+		if err != nil {
+			return fmt.Errorf("--github-issue: %w", err)
+		}
+		client, err := github.NewWithToken(o.issueRepo, o.token)
+		if err != nil {
+			return fmt.Errorf("--github-issue: %w", err)
+		}
+		if err := openCostIssue(ctx, client, owner, repo, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildCostReport totals the jobs of the audit log files paths, e.g. a
+// pool's log and its rotated files, that completed in [start, end).
+func buildCostReport(paths []string, start, end time.Time) (*costreport.Report, error) {
+	jobs, err := readAuditJobs(paths, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return costreport.New(jobs, start, end), nil
+}
+
+// readAuditJobs returns the jobs of the audit log files paths that
+// completed in [start, end).
+func readAuditJobs(paths []string, start, end time.Time) ([]costreport.Job, error) {
+	var jobs []costreport.Job
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		fileJobs, err := costreport.Read(f, start, end)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		jobs = append(jobs, fileJobs...)
+	}
+	return jobs, nil
+}
+
+// openCostIssue opens an issue on owner/repo with report as its body.
+func openCostIssue(ctx context.Context, client issueCreator, owner, repo string, report *costreport.Report) error {
+	if err := client.CreateIssue(ctx, owner, repo, report.Title()+" UTC", report.Markdown()); err != nil {
+		return fmt.Errorf("opening issue on %s/%s: %w", owner, repo, err)
+	}
+	return nil
+}
+
+// repoFromURL returns the owner and name of the repository at u.
+func repoFromURL(u string) (owner, repo string, err error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", "", err
+	}
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("%q does not name a repository", u)
+	}
+	return parts[0], parts[1], nil
+}
+
+// postSlack posts text to a Slack incoming webhook.
+func postSlack(ctx context.Context, webhook, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"extras/scaler/internal/costreport"
+)
+
+func TestCostReportReadsAuditLogs(t *testing.T) {
+	dir := t.TempDir()
+	current := filepath.Join(dir, "windows-gpu.jsonl")
+	rotated := current + ".1"
+	os.WriteFile(rotated, []byte(`{"time":"2026-03-03T02:00:00Z","decision":"job_cost","pool":"win-gpu","repository":"shader-slang/slang","workflow":"ci.yml","uptime_seconds":3600,"cost_usd":1.5}`+"\n"), 0o644)
+	os.WriteFile(current, []byte(`{"time":"2026-03-03T09:00:00Z","decision":"job_cost","pool":"win-gpu","repository":"shader-slang/slang","workflow":"ci.yml","uptime_seconds":1800,"cost_usd":0.75}`+"\n"), 0o644)
+
+	var posted string
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		posted = body["text"]
+	}))
+	defer slack.Close()
+
+	var out bytes.Buffer
+	err := runCostReport(context.Background(), []string{"--end=2026-03-04T00:00:00Z", "--format=csv", "--slack-webhook=" + slack.URL, current, rotated}, &out)
+	if err != nil {
+		t.Fatalf("runCostReport: %v", err)
+	}
+	if !strings.Contains(out.String(), "total,total,2,1.50,2.25") {
+		t.Fatalf("report =\n%s", out.String())
+	}
+	if !strings.HasPrefix(posted, "```\nRunner cost 2026-03-03 00:00:00 to 2026-03-04 00:00:00 UTC: $2.25") {
+		t.Fatalf("Slack message =\n%s", posted)
+	}
+
+	for _, args := range [][]string{
+		{},
+		{"--format=pdf", current},
+		{"--period=0s", current},
+		{"--end=yesterday", current},
+		{filepath.Join(dir, "missing.jsonl")},
+	} {
+		if err := runCostReport(context.Background(), args, &out); err == nil {
+			t.Errorf("runCostReport(%v) should fail", args)
+		}
+	}
+}
+
+type fakeIssues struct {
+	owner, repo, title, body string
+}
+
+func (f *fakeIssues) CreateIssue(_ context.Context, owner, repo, title, body string) error {
+	f.owner, f.repo, f.title, f.body = owner, repo, title, body
+	return nil
+}
+
+func TestOpenCostIssue(t *testing.T) {
+	owner, repo, err := repoFromURL("https://github.com/shader-slang/infra")
+	if err != nil {
+		t.Fatalf("repoFromURL: %v", err)
+	}
+	if _, _, err := repoFromURL("https://github.com/shader-slang"); err == nil {
+		t.Fatal("repoFromURL should reject an organization URL")
+	}
+	start := time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)
+	report := costreport.New(nil, start, start.Add(7*24*time.Hour))
+	f := &fakeIssues{}
+	if err := openCostIssue(context.Background(), f, owner, repo, report); err != nil {
+		t.Fatalf("openCostIssue: %v", err)
+	}
+	if f.owner != "shader-slang" || f.repo != "infra" || f.title != "Runner cost 2026-03-03 00:00:00 to 2026-03-10 00:00:00 UTC" || !strings.Contains(f.body, "## By pool") {
+		t.Fatalf("issue = %+v", f)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/secrets"
+)
+
+// scalesetClientRef holds the scale set client. The client bakes its
+// credentials in at construction, so rotating them means swapping in a new
+// client; everything after startup reads it through get().
+type scalesetClientRef struct {
+	mu     sync.Mutex
+	client *scaleset.Client
+}
+
+func (r *scalesetClientRef) get() *scaleset.Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.client
+}
+
+func (r *scalesetClientRef) set(c *scaleset.Client) {
+	r.mu.Lock()
+	r.client = c
+	r.mu.Unlock()
+}
+
+// credentialRefresher fetches the GitHub PAT and/or App private key from
+// Secret Manager, Vault or local files at startup and polls for rotations.
+//
+// The message session keeps the client it was created with for its own
+// token refreshes, so if the old credential is revoked outright the session
+// eventually fails and is replaced with one made with the new client.
+type credentialRefresher struct {
+	logger     *slog.Logger
+	interval   time.Duration
+	token      secrets.Source // nil unless the PAT comes from a secret store or file
+	privateKey secrets.Source // nil unless the App key comes from a secret store or file
+}
+
+// newCredentialRefresher returns nil when no secret- or file-backed
+// credentials are configured.
+func newCredentialRefresher(ctx context.Context, cfg config, logger *slog.Logger) (*credentialRefresher, error) {
+	if cfg.tokenSecret == "" && cfg.appPrivateKeySecret == "" && cfg.vaultPath == "" &&
+		cfg.tokenFile == "" && cfg.appPrivateKeyFile == "" && cfg.envFile == "" {
+		return nil, nil
+	}
+	r := &credentialRefresher{logger: logger, interval: cfg.secretRefreshInterval}
+	if cfg.vaultPath != "" {
+		v, err := secrets.NewVault(secrets.VaultConfig{
+			Addr:  cfg.vaultAddr,
+			Path:  cfg.vaultPath,
+			Role:  cfg.vaultRole,
+			Token: cfg.vaultToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("--vault-path: %w", err)
+		}
+		// App auth reads the private key; otherwise the path holds a PAT or
+		// is the GitHub secrets engine minting installation tokens.
+		if cfg.appClientID != "" {
+			r.privateKey = v.Field("app_private_key")
+		} else {
+			r.token = v.Field("token")
+		}
+		return r, nil
+	}
+	// The EnvironmentFile holds whichever credential the scaler uses, like
+	// a Vault path; the dedicated flags take precedence over it.
+	if cfg.envFile != "" {
+		if cfg.appClientID != "" {
+			r.privateKey = secrets.NewEnvFile(cfg.envFile, "SCALER_APP_PRIVATE_KEY")
+		} else {
+			r.token = secrets.NewEnvFile(cfg.envFile, "SCALER_TOKEN")
+		}
+	}
+	if cfg.tokenFile != "" {
+		r.token = secrets.NewFile(cfg.tokenFile)
+	}
+	if cfg.appPrivateKeyFile != "" {
+		r.privateKey = secrets.NewFile(cfg.appPrivateKeyFile)
+	}
+	if cfg.tokenSecret != "" {
+		s, err := secrets.NewSecretManager(ctx, cfg.tokenSecret)
+		if err != nil {
+			return nil, fmt.Errorf("--token-secret: %w", err)
+		}
+		r.token = s
+	}
+	if cfg.appPrivateKeySecret != "" {
+		s, err := secrets.NewSecretManager(ctx, cfg.appPrivateKeySecret)
+		if err != nil {
+			return nil, fmt.Errorf("--app-private-key-secret: %w", err)
+		}
+		r.privateKey = s
+	}
+	return r, nil
+}
+
+// load fetches every configured secret into cfg, overriding flag and
+// environment values.
+func (r *credentialRefresher) load(ctx context.Context, cfg *config) error {
+	if r.token != nil {
+		v, err := r.token.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+		cfg.token = v
+	}
+	if r.privateKey != nil {
+		v, err := r.privateKey.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+		cfg.appPrivateKey = v
+	}
+	return nil
+}
+
+// run re-fetches the secrets every interval and, when a value changed,
+// swaps a client built from the new credentials into ref. Fetch failures
+// keep the current client; it stays valid until the old secret is revoked.
+func (r *credentialRefresher) run(ctx context.Context, cfg config, ref *scalesetClientRef) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		next := cfg
+		if err := r.load(ctx, &next); err != nil {
+			r.logger.Warn("failed to refresh credentials, keeping current ones", "error", err)
+			continue
+		}
+		if next.token == cfg.token && next.appPrivateKey == cfg.appPrivateKey {
+			continue
+		}
+		client, err := next.scalesetClient()
+		if err != nil {
+			r.logger.Error("failed to build client with rotated credentials", "error", err)
+			continue
+		}
+		client.SetSystemInfo(ref.get().SystemInfo())
+		ref.set(client)
+		cfg = next
+		r.logger.Info("credentials rotated")
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/actions/scaleset"
+)
+
+type fakeSecret struct {
+	mu    sync.Mutex
+	value string
+	err   error
+}
+
+func (f *fakeSecret) Fetch(context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.value, f.err
+}
+
+func (f *fakeSecret) String() string { return "fake" }
+
+func (f *fakeSecret) set(v string) {
+	f.mu.Lock()
+	f.value = v
+	f.mu.Unlock()
+}
+
+func TestCredentialRefresherLoadOverridesFlags(t *testing.T) {
+	r := &credentialRefresher{token: &fakeSecret{value: "from-secret"}}
+	cfg := config{token: "from-flag", appPrivateKey: "pem"}
+	if err := r.load(context.Background(), &cfg); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.token != "from-secret" {
+		t.Fatalf("token = %q, want secret value", cfg.token)
+	}
+	if cfg.appPrivateKey != "pem" {
+		t.Fatalf("appPrivateKey = %q; unconfigured secrets must not touch it", cfg.appPrivateKey)
+	}
+
+	r.privateKey = &fakeSecret{err: errors.New("denied")}
+	if err := r.load(context.Background(), &cfg); err == nil {
+		t.Fatal("load should surface fetch errors")
+	}
+}
+
+func TestCredentialRefresherSwapsClientOnRotation(t *testing.T) {
+	cfg := config{registrationURL: "https://github.com/shader-slang/slang", token: "old"}
+	initial, err := cfg.scalesetClient()
+	if err != nil {
+		t.Fatalf("scalesetClient: %v", err)
+	}
+	initial.SetSystemInfo(scaleset.SystemInfo{System: "gcp-runner-scaler", ScaleSetID: 42})
+	ref := &scalesetClientRef{client: initial}
+
+	secret := &fakeSecret{value: "old"}
+	r := &credentialRefresher{
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		interval: time.Millisecond,
+		token:    secret,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.run(ctx, cfg, ref)
+
+	time.Sleep(20 * time.Millisecond)
+	if ref.get() != initial {
+		t.Fatal("client swapped although the secret did not change")
+	}
+
+	secret.set("new")
+	deadline := time.Now().Add(time.Second)
+	for ref.get() == initial {
+		if time.Now().After(deadline) {
+			t.Fatal("client not swapped after the secret rotated")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := ref.get().SystemInfo().ScaleSetID; got != 42 {
+		t.Fatalf("rotated client ScaleSetID = %d, want 42 carried over", got)
+	}
+}
+
+func TestNewCredentialRefresherVaultPicksField(t *testing.T) {
+	base := config{vaultAddr: "https://vault.example.com", vaultPath: "secret/data/ci/scaler", vaultToken: "t", secretRefreshInterval: time.Minute}
+
+	r, err := newCredentialRefresher(context.Background(), base, nil)
+	if err != nil {
+		t.Fatalf("newCredentialRefresher: %v", err)
+	}
+	if r.token == nil || r.privateKey != nil {
+		t.Fatalf("PAT auth should read only the token field, got token=%v privateKey=%v", r.token, r.privateKey)
+	}
+
+	app := base
+	app.appClientID = "Iv1.abc"
+	r, err = newCredentialRefresher(context.Background(), app, nil)
+	if err != nil {
+		t.Fatalf("newCredentialRefresher: %v", err)
+	}
+	if r.token != nil || r.privateKey == nil {
+		t.Fatalf("App auth should read only the private key field, got token=%v privateKey=%v", r.token, r.privateKey)
+	}
+
+	noAuth := base
+	noAuth.vaultToken = ""
+	if _, err := newCredentialRefresher(context.Background(), noAuth, nil); err == nil {
+		t.Fatal("vault without a role or token should fail")
+	}
+}
+
+func TestCredentialRefresherRereadsEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scaler.env")
+	write := func(token string) {
+		t.Helper()
+		// Rename it into place like an editor or config management would.
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte("SCALER_TOKEN="+token+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("old")
+
+	cfg := config{registrationURL: "https://github.com/shader-slang/slang", envFile: path, secretRefreshInterval: time.Millisecond}
+	r, err := newCredentialRefresher(context.Background(), cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("newCredentialRefresher: %v", err)
+	}
+	if err := r.load(context.Background(), &cfg); err != nil || cfg.token != "old" {
+		t.Fatalf("load: token = %q, %v", cfg.token, err)
+	}
+	initial, err := cfg.scalesetClient()
+	if err != nil {
+		t.Fatalf("scalesetClient: %v", err)
+	}
+	ref := &scalesetClientRef{client: initial}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.run(ctx, cfg, ref)
+
+	write("new")
+	deadline := time.Now().Add(time.Second)
+	for ref.get() == initial {
+		if time.Now().After(deadline) {
+			t.Fatal("client not swapped after the env file changed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNewCredentialRefresherFilesOverrideEnvFile(t *testing.T) {
+	cfg := config{envFile: "/opt/scaler/scaler.env", appClientID: "Iv1.abc", secretRefreshInterval: time.Minute}
+	r, err := newCredentialRefresher(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatalf("newCredentialRefresher: %v", err)
+	}
+	if r.token != nil || r.privateKey == nil || r.privateKey.String() != "/opt/scaler/scaler.env#SCALER_APP_PRIVATE_KEY" {
+		t.Fatalf("App auth should read only the env file's private key, got token=%v privateKey=%v", r.token, r.privateKey)
+	}
+
+	cfg.appPrivateKeyFile = "/run/secrets/app-key.pem"
+	if r, err = newCredentialRefresher(context.Background(), cfg, nil); err != nil {
+		t.Fatalf("newCredentialRefresher: %v", err)
+	}
+	if r.privateKey.String() != "/run/secrets/app-key.pem" {
+		t.Fatalf("privateKey = %v, want --app-private-key-file", r.privateKey)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	gcpvm "extras/scaler/internal/gcp"
+	"extras/scaler/internal/histogram"
+	"extras/scaler/internal/vmstate"
+)
+
+// maxScaleDecisions bounds the scale-up history the dashboard shows.
+const maxScaleDecisions = 50
+
+// runningJob is the job a runner is currently executing.
+type runningJob struct {
+	Name          string    `json:"name"`
+	WorkflowRunID int64     `json:"workflow_run_id"`
+	StartedAt     time.Time `json:"started_at"`
+}
+
+// scaleDecision records one scale-up: the pending jobs GitHub reported, the
+// VM count before and the target, and how many runners were created.
+type scaleDecision struct {
+	Time    time.Time
+	Pending int
+	Current int
+	Target  int
+	Created int
+	Failed  int
+}
+
+// scalerActivity remembers each runner's current job and the most recent
+// scale-ups for the status dashboard. A nil *scalerActivity records nothing.
+type scalerActivity struct {
+	mu        sync.Mutex
+	jobs      map[string]runningJob
+	decisions []scaleDecision // oldest first
+}
+
+func newScalerActivity() *scalerActivity {
+	return &scalerActivity{jobs: make(map[string]runningJob)}
+}
+
+func (a *scalerActivity) jobStarted(job *scaleset.JobStarted, now time.Time) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.jobs[job.RunnerName] = runningJob{Name: job.JobDisplayName, WorkflowRunID: job.WorkflowRunID, StartedAt: now}
+	a.mu.Unlock()
+}
+
+func (a *scalerActivity) jobFinished(runnerName string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	delete(a.jobs, runnerName)
+	a.mu.Unlock()
+}
+
+func (a *scalerActivity) scaled(d scaleDecision) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.decisions = append(a.decisions, d)
+	if n := len(a.decisions) - maxScaleDecisions; n > 0 {
+		a.decisions = append(a.decisions[:0], a.decisions[n:]...)
+	}
+}
+
+func (a *scalerActivity) size() (jobs, decisions int) {
+	if a == nil {
+		return 0, 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.jobs), len(a.decisions)
+}
+
+// snapshot returns a copy of the current jobs and the decisions, newest first.
+func (a *scalerActivity) snapshot() (map[string]runningJob, []scaleDecision) {
+	if a == nil {
+		return nil, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	jobs := make(map[string]runningJob, len(a.jobs))
+	for k, v := range a.jobs {
+		jobs[k] = v
+	}
+	decisions := make([]scaleDecision, len(a.decisions))
+	for i, d := range a.decisions {
+		decisions[len(decisions)-1-i] = d
+	}
+	return jobs, decisions
+}
+
+// dashboard serves a read-only HTML status page at / on --http-addr. It
+// shows nothing the admin API does not, minus the actions, so it needs no
+// token.
+type dashboard struct {
+	scaleSet string
+	provider string
+	scaler   *gcpRunnerScaler
+	now      func() time.Time
+}
+
+type dashboardVM struct {
+	vmstate.VM
+	Age string
+	Job *runningJob
+}
+
+// latencySummary is one row of the dashboard's latency table.
+type latencySummary struct {
+	Name  string
+	Count int64
+	Mean  time.Duration
+	P50   string
+	P90   string
+}
+
+func summarizeLatency(name string, h histogram.Snapshot) latencySummary {
+	quantile := func(q float64) string {
+		v := h.Quantile(q)
+		if math.IsInf(v, 1) {
+			return fmt.Sprintf("> %s", time.Duration(h.Bounds[len(h.Bounds)-1])*time.Second)
+		}
+		return fmt.Sprintf("≤ %s", time.Duration(v)*time.Second)
+	}
+	return latencySummary{
+		Name:  name,
+		Count: h.Count,
+		Mean:  time.Duration(h.Mean * float64(time.Second)).Truncate(time.Second),
+		P50:   quantile(0.5),
+		P90:   quantile(0.9),
+	}
+}
+
+type dashboardPage struct {
+	ScaleSet   string
+	Provider   string
+	Draining   bool
+	Pause      *pauseState // nil unless scale-ups are paused
+	MaxRunners int
+	MinRunners int
+	// MinOverride is the `scaler scale --min` override in force, if any.
+	MinOverride *minRunnersOverride
+	Zones       []string
+	Image       *gcpvm.ImageVersion
+	Now         time.Time
+	VMs         []dashboardVM
+	Busy        int
+	Decisions   []scaleDecision
+	Latency     []latencySummary
+}
+
+func (d *dashboard) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /{$}", d.serve)
+}
+
+func (d *dashboard) page() dashboardPage {
+	now := d.now()
+	maxRunners, _ := d.scaler.limits()
+	jobs, decisions := d.scaler.activity.snapshot()
+	p := dashboardPage{
+		ScaleSet:    d.scaleSet,
+		Provider:    d.provider,
+		Draining:    d.scaler.isDraining(),
+		Pause:       d.scaler.pauseStatus(),
+		MaxRunners:  maxRunners,
+		MinRunners:  d.scaler.minRunnersAt(now),
+		MinOverride: d.scaler.minRunnersOverrideAt(now),
+		Now:         now,
+		Decisions:   decisions,
+	}
+	if zl, ok := d.scaler.vmManager.(zoneLister); ok {
+		p.Zones = zl.Zones()
+	}
+	if ir, ok := d.scaler.vmManager.(imageReporter); ok {
+		if image := ir.Image(); image.Name != "" {
+			p.Image = &image
+		}
+	}
+	if boot, queue := d.scaler.latency.snapshots(); boot.Count+queue.Count > 0 {
+		p.Latency = []latencySummary{
+			summarizeLatency("Runner boot (registered to job assigned)", boot),
+			summarizeLatency("Job queue (queued to started)", queue),
+		}
+	}
+	for _, vm := range d.scaler.vmManager.VMs() {
+		row := dashboardVM{VM: vm}
+		if !vm.CreatedAt.IsZero() {
+			row.Age = now.Sub(vm.CreatedAt).Truncate(time.Second).String()
+		}
+		if job, ok := jobs[vm.RunnerName]; ok {
+			row.Job = &job
+		}
+		if vm.Busy {
+			p.Busy++
+		}
+		p.VMs = append(p.VMs, row)
+	}
+	return p
+}
+
+func (d *dashboard) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, d.page()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="30">
+<title>{{.ScaleSet}} runners</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+th { background: #eee; }
+.busy { color: #b35900; }
+.state { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{.ScaleSet}}</h1>
+<p>
+Provider <b>{{.Provider}}</b>;
+{{len .VMs}} VMs, {{.Busy}} busy; runners {{.MinRunners}}&ndash;{{.MaxRunners}}
+{{- with .MinOverride}} (minimum pinned until {{.Until.Format "15:04 MST"}}){{end}}
+{{- if .Zones}}; zones {{range $i, $z := .Zones}}{{if $i}}, {{end}}{{$z}}{{end}}{{end}}.
+{{with .Image}}Image <b>{{.Name}}</b> from family {{.Family}}{{if .OutdatedVMs}}, {{.OutdatedVMs}} VMs on earlier images{{end}}.{{end}}
+{{if .Draining}}<span class="state">Draining.</span>{{end}}
+{{with .Pause}}<span class="state">Scale-ups paused{{with .Reason}}: {{.}}{{end}}{{with .Until}}, until {{.Format "15:04 MST"}}{{end}}.</span>{{end}}
+</p>
+
+<h2>VMs</h2>
+{{if .VMs}}
+<table>
+<tr><th>Runner</th><th>VM</th><th>Zone</th><th>State</th><th>Age</th><th>Job</th></tr>
+{{range .VMs}}
+<tr>
+<td>{{.RunnerName}}</td>
+<td>{{.Name}}</td>
+<td>{{.Location}}</td>
+<td>{{if .Pending}}creating{{else if .Busy}}<span class="busy">busy</span>{{else if eq .Phase "failed"}}boot failed: {{.BootError}}{{else if and .Phase (ne .Phase "runner-online")}}booting ({{.Phase}}){{else}}idle{{end}}</td>
+<td>{{.Age}}</td>
+<td>{{with .Job}}{{.Name}} (run {{.WorkflowRunID}}){{end}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>No VMs.</p>
+{{end}}
+
+<h2>Recent scale-ups</h2>
+{{if .Decisions}}
+<table>
+<tr><th>Time</th><th>Pending jobs</th><th>VMs</th><th>Target</th><th>Created</th><th>Failed</th></tr>
+{{range .Decisions}}
+<tr>
+<td>{{.Time.UTC.Format "2006-01-02 15:04:05Z"}}</td>
+<td>{{.Pending}}</td>
+<td>{{.Current}}</td>
+<td>{{.Target}}</td>
+<td>{{.Created}}</td>
+<td>{{.Failed}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>No scale-ups yet.</p>
+{{end}}
+{{if .Latency}}
+<h2>Latency since start</h2>
+<table>
+<tr><th></th><th>Jobs</th><th>Mean</th><th>p50</th><th>p90</th></tr>
+{{range .Latency}}
+<tr><td>{{.Name}}</td><td>{{.Count}}</td><td>{{.Mean}}</td><td>{{.P50}}</td><td>{{.P90}}</td></tr>
+{{end}}
+</table>
+{{end}}
+<p><small>Updated {{.Now.UTC.Format "2006-01-02 15:04:05Z"}}</small></p>
+</body>
+</html>
+`))
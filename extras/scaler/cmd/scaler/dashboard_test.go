@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/vmstate"
+)
+
+func TestScalerActivityKeepsRecentDecisions(t *testing.T) {
+	a := newScalerActivity()
+	for i := range maxScaleDecisions + 5 {
+		a.scaled(scaleDecision{Target: i})
+	}
+	_, decisions := a.snapshot()
+	if len(decisions) != maxScaleDecisions {
+		t.Fatalf("decisions = %d, want %d", len(decisions), maxScaleDecisions)
+	}
+	if decisions[0].Target != maxScaleDecisions+4 || decisions[len(decisions)-1].Target != 5 {
+		t.Fatalf("decisions run %d..%d, want newest first", decisions[0].Target, decisions[len(decisions)-1].Target)
+	}
+
+	var nilActivity *scalerActivity
+	nilActivity.scaled(scaleDecision{})
+	nilActivity.jobStarted(&scaleset.JobStarted{}, time.Now())
+}
+
+func TestDashboardShowsVMsAndJobs(t *testing.T) {
+	now := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	provider := &fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-1", Name: "win-1", Location: "us-east1-c", Busy: true, CreatedAt: now.Add(-90 * time.Second)},
+		{RunnerName: "win-2", Name: "win-2", Location: "us-east1-d", CreatedAt: now.Add(-30 * time.Second)},
+	}}
+	s := &gcpRunnerScaler{vmManager: provider, maxRunners: 4, activity: newScalerActivity()}
+	s.setPaused(true)
+	job := &scaleset.JobStarted{}
+	job.RunnerName = "win-1"
+	job.JobDisplayName = "build (windows, release)"
+	job.WorkflowRunID = 4242
+	s.activity.jobStarted(job, now)
+	s.activity.scaled(scaleDecision{Time: now, Pending: 3, Current: 0, Target: 2, Created: 2})
+	s.latency = newRunnerLatency()
+	s.latency.registered("win-1", now.Add(-3*time.Minute))
+	s.latency.jobStarted(job, now)
+
+	mux := http.NewServeMux()
+	(&dashboard{scaleSet: "windows-gpu", provider: "gcp", scaler: s, now: func() time.Time { return now }}).register(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"windows-gpu", "Scale-ups paused", "us-east1-c", "1m30s", "busy",
+		"build (windows, release) (run 4242)", "2026-03-02 15:00:00Z",
+		"Runner boot", "≤ 3m0s",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard is missing %q", want)
+		}
+	}
+
+	s.activity.jobFinished("win-1")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Contains(rec.Body.String(), "run 4242") {
+		t.Error("dashboard still shows a finished job")
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/other", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("/other status = %d, want 404", rec.Code)
+	}
+}
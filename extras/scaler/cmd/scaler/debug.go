@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+
+	"extras/scaler/internal/vmstate"
+)
+
+// debugState is the body of GET /debug/state: memory stats and the size of
+// every long-lived map in the scaler, to spot state that is never pruned.
+type debugState struct {
+	Goroutines     int            `json:"goroutines"`
+	HeapAllocBytes uint64         `json:"heap_alloc_bytes"`
+	HeapObjects    uint64         `json:"heap_objects"`
+	SysBytes       uint64         `json:"sys_bytes"`
+	NumGC          uint32         `json:"num_gc"`
+	Entries        map[string]int `json:"entries"`
+	VMs            []vmstate.VM   `json:"vms"`
+}
+
+// registerDebug serves net/http/pprof and /debug/state on api. Goroutine
+// dumps are /debug/pprof/goroutine?debug=2.
+func (a *adminAPI) registerDebug(api *http.ServeMux) {
+	api.HandleFunc("GET /debug/pprof/", pprof.Index)
+	api.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	api.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	api.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	api.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	api.HandleFunc("GET /debug/state", a.debugState)
+}
+
+func (a *adminAPI) debugState(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	s := a.scaler
+	runningJobs, decisions := s.activity.size()
+	writeAdminJSON(w, debugState{
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: mem.HeapAlloc,
+		HeapObjects:    mem.HeapObjects,
+		SysBytes:       mem.Sys,
+		NumGC:          mem.NumGC,
+		Entries: map[string]int{
+			"runner_spans":    s.runners.size(),
+			"running_jobs":    runningJobs,
+			"scale_decisions": decisions,
+			"boot_timers":     s.latency.size(),
+			"assigned_jobs":   s.assignedJobs.size(),
+		},
+		VMs: s.vmManager.VMs(),
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugEndpointsRequireToken(t *testing.T) {
+	_, mux, _ := newTestAdmin()
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=2", "/debug/state"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("%s without token: status %d, want 401", path, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=2", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine") {
+		t.Fatalf("goroutine dump: status %d, body %.100q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDebugState(t *testing.T) {
+	a, mux, _ := newTestAdmin()
+	a.scaler.latency = newRunnerLatency()
+	a.scaler.latency.registered("win-2", time.Now())
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/state", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	var st debugState
+	if err := json.NewDecoder(rec.Body).Decode(&st); err != nil {
+		t.Fatalf("decoding /debug/state: %v", err)
+	}
+	if st.Goroutines == 0 || st.HeapAllocBytes == 0 {
+		t.Fatalf("runtime stats missing: %+v", st)
+	}
+	if st.Entries["boot_timers"] != 1 || st.Entries["runner_spans"] != 0 || len(st.VMs) != 2 {
+		t.Fatalf("entries = %v, VMs = %d", st.Entries, len(st.VMs))
+	}
+}
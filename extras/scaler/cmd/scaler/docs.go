@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Registered here as the commands read subcommands, which the map's
+// initializer cannot refer to.
+func init() {
+	subcommands["completion"] = subcommand{run: runCompletion, args: "bash|zsh|fish", summary: "Print a shell completion script"}
+	subcommands["docs"] = subcommand{run: runDocs, args: "man", summary: "Print the man page"}
+}
+
+// commandFlags returns the flags sub defines.
+func commandFlags(sub subcommand) *flag.FlagSet {
+	fs := flag.NewFlagSet("scaler", flag.ContinueOnError)
+	if sub.flags != nil {
+		sub.flags(fs)
+	}
+	if sub.config {
+		registerServiceFlags(fs)
+	}
+	return fs
+}
+
+// serviceFlags returns the flags of the scaler itself, `scaler run`.
+func serviceFlags() *flag.FlagSet {
+	fs := flag.NewFlagSet("scaler", flag.ContinueOnError)
+	registerServiceFlags(fs)
+	return fs
+}
+
+// registerServiceFlags defines the scaler's flags on fs, discarding their
+// values.
+func registerServiceFlags(fs *flag.FlagSet) {
+	registerFlags(fs, new(config))
+}
+
+// docCommand is a command as the completions and man page describe it.
+type docCommand struct {
+	name, args, summary string
+	flags               []*flag.Flag
+}
+
+// docCommands returns run and the subcommands, by name, with their flags.
+func docCommands() []docCommand {
+	commands := []docCommand{{name: "run", summary: "Serve the pool; the default without a command", flags: allFlags(serviceFlags())}}
+	for _, name := range slices.Sorted(maps.Keys(subcommands)) {
+		sub := subcommands[name]
+		commands = append(commands, docCommand{name: name, args: sub.args, summary: sub.summary, flags: allFlags(commandFlags(sub))})
+	}
+	return commands
+}
+
+func allFlags(fs *flag.FlagSet) []*flag.Flag {
+	var flags []*flag.Flag
+	if fs != nil {
+		fs.VisitAll(func(f *flag.Flag) { flags = append(flags, f) })
+	}
+	return flags
+}
+
+// flagDescription is f's usage cut to its first sentence, for completions.
+func flagDescription(f *flag.Flag) string {
+	_, usage := flag.UnquoteUsage(f)
+	for i := 0; ; {
+		j := strings.Index(usage[i:], ". ")
+		if j < 0 {
+			break
+		}
+		if end := usage[:i+j]; !strings.HasSuffix(end, "e.g") && !strings.HasSuffix(end, "i.e") {
+			usage = end
+			break
+		}
+		i += j + 2
+	}
+	if r := []rune(usage); len(r) > 80 {
+		usage = string(r[:79]) + "…"
+	}
+	return usage
+}
+
+// isBoolFlag reports whether f takes no value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// runCompletion implements `scaler completion bash|zsh|fish`.
+func runCompletion(_ context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("scaler completion", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: scaler completion bash|zsh|fish")
+	}
+	switch fs.Arg(0) {
+	case "bash":
+		writeBashCompletion(out, docCommands())
+	case "zsh":
+		writeZshCompletion(out, docCommands())
+	case "fish":
+		writeFishCompletion(out, docCommands())
+	default:
+		return fmt.Errorf("unknown shell %q: want bash, zsh or fish", fs.Arg(0))
+	}
+	return nil
+}
+
+func flagNames(flags []*flag.Flag) string {
+	names := make([]string, len(flags))
+	for i, f := range flags {
+		names[i] = "--" + f.Name
+	}
+	return strings.Join(names, " ")
+}
+
+func writeBashCompletion(out io.Writer, commands []docCommand) {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+	fmt.Fprint(out, "# bash completion for scaler, generated by `scaler completion bash`.\n")
+	fmt.Fprint(out, "_scaler() {\n")
+	fmt.Fprint(out, "\tlocal cur=${COMP_WORDS[COMP_CWORD]} words\n")
+	fmt.Fprint(out, "\tif [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then\n")
+	fmt.Fprintf(out, "\t\twords=%q\n", strings.Join(names, " "))
+	fmt.Fprint(out, "\telse\n\t\tcase ${COMP_WORDS[1]} in\n")
+	for _, c := range commands[1:] {
+		fmt.Fprintf(out, "\t\t%s) words=%q ;;\n", c.name, flagNames(c.flags))
+	}
+	fmt.Fprintf(out, "\t\t*) words=%q ;;\n", flagNames(commands[0].flags))
+	fmt.Fprint(out, "\t\tesac\n\tfi\n")
+	fmt.Fprint(out, "\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	fmt.Fprint(out, "}\n")
+	fmt.Fprint(out, "complete -o default -F _scaler scaler\n")
+}
+
+// zshQuote quotes s for a single-quoted _arguments spec or _describe item.
+func zshQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+	return strings.ReplaceAll(s, "'", `'\''`)
+}
+
+func zshFlagSpecs(flags []*flag.Flag) string {
+	var b strings.Builder
+	for _, f := range flags {
+		if isBoolFlag(f) {
+			fmt.Fprintf(&b, " \\\n\t\t\t'--%s[%s]'", f.Name, zshQuote(flagDescription(f)))
+			continue
+		}
+		name, _ := flag.UnquoteUsage(f)
+		fmt.Fprintf(&b, " \\\n\t\t\t'--%s=[%s]:%s:_files'", f.Name, zshQuote(flagDescription(f)), name)
+	}
+	return b.String()
+}
+
+func writeZshCompletion(out io.Writer, commands []docCommand) {
+	fmt.Fprint(out, "#compdef scaler\n")
+	fmt.Fprint(out, "# zsh completion for scaler, generated by `scaler completion zsh`.\n")
+	fmt.Fprint(out, "_scaler() {\n")
+	fmt.Fprint(out, "\tlocal -a commands\n\tcommands=(\n")
+	for _, c := range commands {
+		fmt.Fprintf(out, "\t\t'%s:%s'\n", c.name, zshQuote(c.summary))
+	}
+	fmt.Fprint(out, "\t)\n")
+	fmt.Fprint(out, "\tif (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then\n")
+	fmt.Fprint(out, "\t\t_describe -t commands 'scaler command' commands\n\t\treturn\n\tfi\n")
+	fmt.Fprint(out, "\tif [[ $words[2] != -* ]]; then\n\t\tshift words\n\t\t(( CURRENT-- ))\n\tfi\n")
+	fmt.Fprint(out, "\tcase $words[1] in\n")
+	for _, c := range commands[1:] {
+		fmt.Fprintf(out, "\t%s)\n\t\t_arguments%s \\\n\t\t\t'*:argument:_files'\n\t\t;;\n", c.name, zshFlagSpecs(c.flags))
+	}
+	fmt.Fprintf(out, "\t*)\n\t\t_arguments%s\n\t\t;;\n", zshFlagSpecs(commands[0].flags))
+	fmt.Fprint(out, "\tesac\n}\n")
+	fmt.Fprint(out, "_scaler \"$@\"\n")
+}
+
+// fishQuote single-quotes s for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func writeFishCompletion(out io.Writer, commands []docCommand) {
+	fmt.Fprint(out, "# fish completion for scaler, generated by `scaler completion fish`.\n")
+	for _, c := range commands {
+		fmt.Fprintf(out, "complete -c scaler -n __fish_use_subcommand -a %s -d %s\n", c.name, fishQuote(c.summary))
+	}
+	for _, c := range commands {
+		cond := fishQuote("__fish_seen_subcommand_from " + c.name)
+		if c.name == "run" {
+			// Without a command the flags are run's.
+			cond = fishQuote("__fish_use_subcommand; or __fish_seen_subcommand_from run")
+		}
+		for _, f := range c.flags {
+			value := " -r"
+			if isBoolFlag(f) {
+				value = ""
+			}
+			fmt.Fprintf(out, "complete -c scaler -n %s -l %s%s -d %s\n", cond, f.Name, value, fishQuote(flagDescription(f)))
+		}
+	}
+}
+
+// runDocs implements `scaler docs man`, which prints the man page.
+func runDocs(_ context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("scaler docs", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || fs.Arg(0) != "man" {
+		return errors.New("usage: scaler docs man")
+	}
+	writeManPage(out, docCommands(), currentBuild().Version)
+	return nil
+}
+
+// roff escapes s for a man page line.
+func roff(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+// manFlag writes f as a man page .TP entry.
+func manFlag(out io.Writer, f *flag.Flag) {
+	name, usage := flag.UnquoteUsage(f)
+	fmt.Fprintf(out, ".TP\n\\fB\\-\\-%s\\fR", roff(f.Name))
+	if name != "" {
+		fmt.Fprintf(out, "=\\fI%s\\fR", roff(name))
+	}
+	fmt.Fprintf(out, "\n%s", roff(usage))
+	switch f.DefValue {
+	case "", "0", "0s", "false", "[]":
+	default:
+		fmt.Fprintf(out, " (default: %s)", roff(f.DefValue))
+	}
+	fmt.Fprintln(out)
+}
+
+func writeManPage(out io.Writer, commands []docCommand, version string) {
+	run := commands[0]
+	service := make(map[string]bool, len(run.flags))
+	for _, f := range run.flags {
+		service[f.Name] = true
+	}
+
+	fmt.Fprintf(out, ".TH SCALER 1 \"\" \"scaler %s\" \"User Commands\"\n", roff(version))
+	fmt.Fprint(out, ".SH NAME\nscaler \\- autoscale GitHub Actions runners on cloud VMs\n")
+	fmt.Fprint(out, ".SH SYNOPSIS\n.B scaler\n[\\fBrun\\fR] [\\fIflags\\fR]\n.br\n.B scaler\n\\fIcommand\\fR [\\fIflags\\fR] [\\fIargs\\fR]\n")
+	fmt.Fprint(out, ".SH DESCRIPTION\n")
+	fmt.Fprint(out, "The scaler registers a runner scale set with GitHub and creates a VM for\n")
+	fmt.Fprint(out, "each runner its queued jobs need, deleting the VM when its job completes.\n")
+	fmt.Fprint(out, "Without a command, or with \\fBrun\\fR, it serves the pool with the flags below;\n")
+	fmt.Fprint(out, "the commands check its configuration, steer a running scaler and report costs.\n")
+	fmt.Fprint(out, "Any flag can also be set from the YAML file of \\fB\\-\\-config\\fR.\n")
+	fmt.Fprint(out, ".SH OPTIONS\n")
+	for _, f := range run.flags {
+		manFlag(out, f)
+	}
+	fmt.Fprint(out, ".SH COMMANDS\n")
+	for _, c := range commands[1:] {
+		// Commands that load the scaler's configuration define all of its
+		// flags; list only their own.
+		own := c.flags
+		shared := 0
+		for _, f := range c.flags {
+			if service[f.Name] {
+				shared++
+			}
+		}
+		if shared == len(service) {
+			own = slices.DeleteFunc(slices.Clone(c.flags), func(f *flag.Flag) bool { return service[f.Name] })
+		}
+		synopsis := "scaler " + c.name
+		if len(c.flags) > 0 {
+			synopsis += " [flags]"
+		}
+		if c.args != "" {
+			synopsis += " " + c.args
+		}
+		fmt.Fprintf(out, ".SS \"%s\"\n%s.\n", roff(synopsis), roff(c.summary))
+		if shared == len(service) {
+			fmt.Fprint(out, "Takes the flags and \\fB\\-\\-config\\fR file of the scaler (see OPTIONS)")
+			if len(own) > 0 {
+				fmt.Fprint(out, ", and:\n")
+			} else {
+				fmt.Fprint(out, ".\n")
+			}
+		}
+		for _, f := range own {
+			manFlag(out, f)
+		}
+	}
+	fmt.Fprint(out, ".SH SIGNALS\n")
+	fmt.Fprint(out, ".TP\n.B SIGUSR1\nEnter drain mode.\n")
+	fmt.Fprint(out, ".TP\n.B SIGHUP\nRe\\-read the flags and \\fB\\-\\-config\\fR.\n")
+	fmt.Fprint(out, ".TP\n.B SIGUSR2\nCycle the log level.\n")
+	fmt.Fprint(out, ".SH SEE ALSO\nThe README next to the scaler's source, extras/scaler/README.md.\n")
+}
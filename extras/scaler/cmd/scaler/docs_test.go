@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommandFlags(t *testing.T) {
+	scale := commandFlags(subcommands["scale"])
+	for _, name := range []string{"min", "until", "reset-min", "pool", "json"} {
+		if scale.Lookup(name) == nil {
+			t.Errorf("scale has no --%s", name)
+		}
+	}
+	if validate := commandFlags(subcommands["validate"]); validate.Lookup("url") == nil {
+		t.Error("validate should take the scaler's flags")
+	}
+	if reconcile := commandFlags(subcommands["reconcile"]); reconcile.Lookup("once") == nil || reconcile.Lookup("url") == nil {
+		t.Error("reconcile should take --once and the scaler's flags")
+	}
+	if fs := commandFlags(subcommands["completion"]); len(allFlags(fs)) != 0 {
+		t.Errorf("completion flags = %v, want none", allFlags(fs))
+	}
+}
+
+func TestFlagDescription(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("zones", "", "Zones to use, e.g. us-east1-c. Later ones are fallbacks")
+	if got, want := flagDescription(fs.Lookup("zones")), "Zones to use, e.g. us-east1-c"; got != want {
+		t.Fatalf("flagDescription = %q, want %q", got, want)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var out bytes.Buffer
+		if err := runCompletion(context.Background(), []string{shell}, &out); err != nil {
+			t.Fatalf("completion %s: %v", shell, err)
+		}
+		for _, want := range []string{"recycle", "reset-min", "gcp-zones", "Delete a runner"} {
+			// Bash completes names only.
+			if shell == "bash" && want == "Delete a runner" {
+				continue
+			}
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s completion missing %q", shell, want)
+			}
+		}
+		if shell == "bash" {
+			if _, err := exec.LookPath("bash"); err == nil {
+				script := filepath.Join(t.TempDir(), "scaler.bash")
+				os.WriteFile(script, out.Bytes(), 0o644)
+				if msg, err := exec.Command("bash", "-n", script).CombinedOutput(); err != nil {
+					t.Errorf("bash -n: %v: %s", err, msg)
+				}
+			}
+		}
+	}
+	if err := runCompletion(context.Background(), []string{"tcsh"}, &bytes.Buffer{}); err == nil {
+		t.Fatal("completion for an unknown shell should fail")
+	}
+}
+
+func TestManPage(t *testing.T) {
+	var out bytes.Buffer
+	if err := runDocs(context.Background(), []string{"man"}, &out); err != nil {
+		t.Fatalf("docs man: %v", err)
+	}
+	man := out.String()
+	for _, want := range []string{
+		".TH SCALER 1",
+		`.SS "scaler scale [flags] [MAX_RUNNERS]"`,
+		`\fB\-\-reset\-min\fR`,
+		`\fB\-\-gcp\-zones\fR=\fIstring\fR`,
+	} {
+		if !strings.Contains(man, want) {
+			t.Errorf("man page missing %q", want)
+		}
+	}
+	// Commands taking the scaler's flags refer to OPTIONS instead of
+	// repeating them.
+	if n := strings.Count(man, `\fB\-\-session\-max\-age\fR`); n != 1 {
+		t.Errorf("--session-max-age is described %d times, want once", n)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errDrainTimedOut is returned when VMs were still running --drain-timeout
+// after drain mode began. Like errDrainComplete it causes a clean exit.
+var errDrainTimedOut = errors.New("drain timed out")
+
+// Actions for the VMs still running when --drain-timeout ends a drain.
+const (
+	drainTimeoutLeave  = "leave"
+	drainTimeoutDelete = "delete"
+)
+
+// drainStatus is a drain's progress, in GET /api/v1/status.
+type drainStatus struct {
+	StartedAt time.Time `json:"started_at"`
+	// Deadline and TimeoutAction are set with --drain-timeout.
+	Deadline      *time.Time `json:"deadline,omitempty"`
+	TimeoutAction string     `json:"timeout_action,omitempty"`
+	// RemainingVMs is the VMs the drain waits for, BusyVMs those of them
+	// running a job.
+	RemainingVMs int `json:"remaining_vms"`
+	BusyVMs      int `json:"busy_vms"`
+}
+
+// drainStatus returns the progress of the drain, or nil when the scaler is
+// not draining.
+func (s *gcpRunnerScaler) drainStatus() *drainStatus {
+	s.mu.Lock()
+	draining, started := s.draining, s.drainStarted
+	s.mu.Unlock()
+	if !draining {
+		return nil
+	}
+	st := &drainStatus{StartedAt: started}
+	if s.drainTimeout > 0 {
+		deadline := started.Add(s.drainTimeout)
+		st.Deadline, st.TimeoutAction = &deadline, s.drainTimeoutAction
+	}
+	for _, vm := range s.vmManager.VMs() {
+		st.RemainingVMs++
+		if vm.Busy {
+			st.BusyVMs++
+		}
+	}
+	return st
+}
+
+// enforceDrainTimeout ends a drain that still waits for VMs drainTimeout
+// after it began: with the delete action it deletes them, and either way it
+// stops the scaler with errDrainTimedOut. A drain that completes first ends
+// the scaler through errDrainComplete instead.
+func (s *gcpRunnerScaler) enforceDrainTimeout(ctx context.Context, stop context.CancelCauseFunc) {
+	timer := time.NewTimer(s.drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+	}
+	remaining := s.vmManager.ActiveCount()
+	if remaining == 0 {
+		return
+	}
+	s.logger.Warn("drain timed out with VMs still running",
+		"event", eventDrainTimeout,
+		"timeout", s.drainTimeout,
+		"remaining", remaining,
+		"action", s.drainTimeoutAction,
+	)
+	if s.drainTimeoutAction == drainTimeoutDelete {
+		s.deleteAllVMs(ctx)
+	}
+	stop(errDrainTimedOut)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"extras/scaler/internal/vmstate"
+)
+
+// drainProvider is a fakeAdminProvider whose VMs can be deleted.
+type drainProvider struct {
+	fakeAdminProvider
+	deletedAll bool
+}
+
+func (p *drainProvider) ActiveCount() int { return len(p.vms) }
+
+func (p *drainProvider) ActiveRunnerNames() []string {
+	var names []string
+	for _, vm := range p.vms {
+		names = append(names, vm.RunnerName)
+	}
+	return names
+}
+
+func (p *drainProvider) DeleteAll(context.Context) {
+	p.deletedAll = true
+	p.vms = nil
+}
+
+func newDrainScaler(t *testing.T, action string, vms ...vmstate.VM) (*gcpRunnerScaler, *drainProvider, *fakeActions) {
+	t.Helper()
+	provider := &drainProvider{fakeAdminProvider: fakeAdminProvider{vms: vms}}
+	client, actions := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:             slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:          provider,
+		scalesetClient:     client,
+		scaleSetID:         1,
+		vmPrefix:           "win",
+		drainTimeout:       time.Millisecond,
+		drainTimeoutAction: action,
+	}
+	s.setDraining(true)
+	return s, provider, actions
+}
+
+func TestEnforceDrainTimeout(t *testing.T) {
+	busy := vmstate.VM{RunnerName: "win-1", Name: "win-1", Busy: true}
+
+	s, provider, actions := newDrainScaler(t, drainTimeoutDelete, busy)
+	var cause error
+	s.enforceDrainTimeout(context.Background(), func(err error) { cause = err })
+	if !errors.Is(cause, errDrainTimedOut) {
+		t.Fatalf("stop cause = %v, want errDrainTimedOut", cause)
+	}
+	if !provider.deletedAll || len(actions.removed) != 1 {
+		t.Fatalf("deleted all = %v, removed = %v, want the VM and its runner gone", provider.deletedAll, actions.removed)
+	}
+
+	s, provider, actions = newDrainScaler(t, drainTimeoutLeave, busy)
+	cause = nil
+	s.enforceDrainTimeout(context.Background(), func(err error) { cause = err })
+	if !errors.Is(cause, errDrainTimedOut) {
+		t.Fatalf("stop cause = %v, want errDrainTimedOut", cause)
+	}
+	if provider.deletedAll || len(actions.removed) != 0 {
+		t.Fatal("the leave action deleted the VMs")
+	}
+
+	// A drain without VMs left completes on its own.
+	s, _, _ = newDrainScaler(t, drainTimeoutDelete)
+	cause = nil
+	s.enforceDrainTimeout(context.Background(), func(err error) { cause = err })
+	if cause != nil {
+		t.Fatalf("stop cause = %v after the VMs finished, want none", cause)
+	}
+}
+
+func TestDrainStatus(t *testing.T) {
+	s, _, _ := newDrainScaler(t, drainTimeoutDelete,
+		vmstate.VM{RunnerName: "win-1", Busy: true},
+		vmstate.VM{RunnerName: "win-2"},
+	)
+	s.drainTimeout = time.Hour
+	st := s.drainStatus()
+	if st == nil || st.RemainingVMs != 2 || st.BusyVMs != 1 || st.TimeoutAction != drainTimeoutDelete {
+		t.Fatalf("drainStatus = %+v, want 2 VMs left, 1 busy, delete at the deadline", st)
+	}
+	if st.Deadline == nil || !st.Deadline.Equal(st.StartedAt.Add(time.Hour)) {
+		t.Fatalf("deadline = %v, want an hour after %v", st.Deadline, st.StartedAt)
+	}
+
+	var out bytes.Buffer
+	printStatus(&out, adminStatus{Draining: true, Drain: st}, st.StartedAt.Add(10*time.Minute))
+	if want := "Draining for 10m0s: 2 VMs left, 1 busy; timeout in 50m0s, then delete them"; !strings.Contains(out.String(), want) {
+		t.Fatalf("output missing %q:\n%s", want, out.String())
+	}
+
+	s.setDraining(false)
+	if st := s.drainStatus(); st != nil {
+		t.Fatalf("drainStatus = %+v when not draining, want nil", st)
+	}
+}
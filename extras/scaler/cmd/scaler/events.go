@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// historyEvent is one entry of the event history.
+type historyEvent struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Event   string            `json:"event,omitempty"`
+	Message string            `json:"message"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// eventHistory keeps the last N lifecycle events and errors in memory, so
+// recent history can be read from a running scaler after journald rotated
+// it away. A nil *eventHistory records nothing.
+type eventHistory struct {
+	mu     sync.Mutex
+	events []historyEvent // ring buffer
+	next   int
+	full   bool
+}
+
+func newEventHistory(size int) *eventHistory {
+	if size <= 0 {
+		return nil
+	}
+	return &eventHistory{events: make([]historyEvent, size)}
+}
+
+func (h *eventHistory) add(e historyEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events[h.next] = e
+	h.next = (h.next + 1) % len(h.events)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns up to limit events (all if limit <= 0), oldest first, keeping
+// only those whose event name is event when it is non-empty.
+func (h *eventHistory) list(limit int, event string) []historyEvent {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	ordered := append([]historyEvent(nil), h.events[:h.next]...)
+	if h.full {
+		ordered = append(append([]historyEvent(nil), h.events[h.next:]...), ordered...)
+	}
+	h.mu.Unlock()
+
+	var out []historyEvent
+	for _, e := range ordered {
+		if event == "" || e.Event == event {
+			out = append(out, e)
+		}
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
+
+// historyHandler passes every record to the wrapped handler and copies
+// those carrying an "event" key, and all errors, into the history. This
+// captures the providers' events too, since they log through slog's
+// default logger.
+type historyHandler struct {
+	slog.Handler
+	history *eventHistory // nil without --event-history
+	// security receives every event too, with --security-events (see
+	// withSecurityEvents).
+	security func(historyEvent)
+	prefix   string // group prefix for attribute keys, e.g. "scaler."
+	attrs    []prefixedAttr
+}
+
+// prefixedAttr is an attribute from WithAttrs and the group prefix in
+// effect when it was added.
+type prefixedAttr struct {
+	slog.Attr
+	prefix string
+}
+
+func newHistoryHandler(h slog.Handler, history *eventHistory) slog.Handler {
+	if history == nil {
+		return h
+	}
+	return &historyHandler{Handler: h, history: history}
+}
+
+func (h *historyHandler) Handle(ctx context.Context, r slog.Record) error {
+	e := historyEvent{Time: r.Time, Level: r.Level.String(), Message: r.Message}
+	add := func(prefix string, a slog.Attr) {
+		// "event" is recognized inside groups too.
+		if a.Key == "event" {
+			e.Event = a.Value.Resolve().String()
+			return
+		}
+		if e.Attrs == nil {
+			e.Attrs = make(map[string]string)
+		}
+		e.Attrs[prefix+a.Key] = a.Value.Resolve().String()
+	}
+	for _, a := range h.attrs {
+		add(a.prefix, a.Attr)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		add(h.prefix, a)
+		return true
+	})
+	if h.history != nil && (e.Event != "" || r.Level >= slog.LevelError) {
+		h.history.add(e)
+	}
+	if h.security != nil && e.Event != "" {
+		h.security(e)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *historyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.Handler = h.Handler.WithAttrs(attrs)
+	next.attrs = append([]prefixedAttr(nil), h.attrs...)
+	for _, a := range attrs {
+		next.attrs = append(next.attrs, prefixedAttr{Attr: a, prefix: h.prefix})
+	}
+	return &next
+}
+
+func (h *historyHandler) WithGroup(name string) slog.Handler {
+	next := *h
+	next.Handler = h.Handler.WithGroup(name)
+	next.prefix = h.prefix + name + "."
+	return &next
+}
+
+// events serves GET /api/v1/events?limit=N&event=NAME, oldest first.
+func (a *adminAPI) events(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeAdminError(w, http.StatusBadRequest, errors.New("limit must be a non-negative integer"))
+			return
+		}
+		limit = n
+	}
+	events := a.history.list(limit, r.URL.Query().Get("event"))
+	if events == nil {
+		events = []historyEvent{}
+	}
+	writeAdminJSON(w, events)
+}
+
+// eventsOptions are the flags of `scaler events`.
+type eventsOptions struct {
+	target *adminFlags
+	limit  int
+	event  string
+	json   bool
+}
+
+func (o *eventsOptions) register(fs *flag.FlagSet) {
+	o.target = newAdminFlags(fs)
+	fs.IntVar(&o.limit, "limit", 50, "Number of most recent events to show (0 for all)")
+	fs.StringVar(&o.event, "event", "", "Only show events with this name, e.g. vm_create_failed")
+	fs.BoolVar(&o.json, "json", false, "Print the raw JSON")
+}
+
+// runEvents implements `scaler events [flags]`, printing a running
+// scaler's recent events from its admin API.
+func runEvents(ctx context.Context, args []string, out io.Writer) error {
+	var o eventsOptions
+	fs := flag.NewFlagSet("scaler events", flag.ContinueOnError)
+	o.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	query := url.Values{"limit": {strconv.Itoa(o.limit)}}
+	if o.event != "" {
+		query.Set("event", o.event)
+	}
+	client, err := o.target.client()
+	if err != nil {
+		return err
+	}
+	body, err := client.do(ctx, http.MethodGet, "/api/v1/events?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if o.json {
+		_, err := out.Write(body)
+		return err
+	}
+
+	var events []historyEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return err
+	}
+	for _, e := range events {
+		fmt.Fprintf(out, "%s %-5s %-18s %s", e.Time.Local().Format(time.DateTime), e.Level, e.Event, e.Message)
+		keys := make([]string, 0, len(e.Attrs))
+		for k := range e.Attrs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(out, " %s=%s", k, e.Attrs[k])
+		}
+		fmt.Fprintln(out)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEventHistoryRing(t *testing.T) {
+	h := newEventHistory(3)
+	for _, name := range []string{"a", "b", "c", "d", "b"} {
+		h.add(historyEvent{Event: name})
+	}
+	names := func(events []historyEvent) string {
+		var s []string
+		for _, e := range events {
+			s = append(s, e.Event)
+		}
+		return strings.Join(s, ",")
+	}
+	if got := names(h.list(0, "")); got != "c,d,b" {
+		t.Fatalf("list = %s, want c,d,b (oldest first, oldest dropped)", got)
+	}
+	if got := names(h.list(2, "")); got != "d,b" {
+		t.Fatalf("list(2) = %s, want d,b", got)
+	}
+	if got := names(h.list(0, "b")); got != "b" {
+		t.Fatalf("list(event=b) = %s, want b", got)
+	}
+	if newEventHistory(0) != nil || newEventHistory(0).list(0, "") != nil {
+		t.Fatal("size 0 should disable the history")
+	}
+}
+
+func TestHistoryHandlerCapturesEventsAndErrors(t *testing.T) {
+	h := newEventHistory(10)
+	var out bytes.Buffer
+	logger := slog.New(newHistoryHandler(slog.NewTextHandler(&out, nil), h))
+	scaler := logger.WithGroup("scaler").With("pool", "win")
+
+	scaler.Info("created runner VM", "event", eventVMCreated, "vm", "win-1")
+	scaler.Info("scaling up, no event key")
+	logger.Error("failed to delete scale set", "error", errors.New("boom"))
+
+	events := h.list(0, "")
+	if len(events) != 2 {
+		t.Fatalf("history = %+v, want the event and the error", events)
+	}
+	e := events[0]
+	if e.Event != "vm_created" || e.Message != "created runner VM" || e.Attrs["scaler.vm"] != "win-1" || e.Attrs["scaler.pool"] != "win" {
+		t.Fatalf("event = %+v", e)
+	}
+	if events[1].Level != "ERROR" || events[1].Attrs["error"] != "boom" {
+		t.Fatalf("error event = %+v", events[1])
+	}
+	if !strings.Contains(out.String(), "scaling up, no event key") {
+		t.Fatal("records must still reach the wrapped handler")
+	}
+}
+
+func TestEventsCommand(t *testing.T) {
+	a, mux, _ := newTestAdmin()
+	a.history = newEventHistory(10)
+	logger := slog.New(newHistoryHandler(slog.NewTextHandler(io.Discard, nil), a.history))
+	logger.Info("job started", "event", eventJobStarted, "runner", "win-1", "job", "build")
+	logger.Info("created runner VM", "event", eventVMCreated, "runner", "win-2")
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var out bytes.Buffer
+	err := runEvents(context.Background(), []string{"--addr", srv.URL, "--token", "s3cret", "--event", "job_started"}, &out)
+	if err != nil {
+		t.Fatalf("runEvents: %v", err)
+	}
+	got := out.String()
+	if !strings.Contains(got, "job_started") || !strings.Contains(got, "job=build runner=win-1") || strings.Contains(got, "win-2") {
+		t.Fatalf("output = %q", got)
+	}
+
+	err = runEvents(context.Background(), []string{"--addr", srv.URL, "--token", "wrong"}, &out)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("runEvents with a bad token: %v, want 401", err)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fairShareTTL is how long a pool's published demand counts after its last
+// poll. A listener polls at least every minute, so older files belong to
+// pools that stopped.
+const fairShareTTL = 5 * time.Minute
+
+// fairShareDemand is what a pool publishes to the --fair-share-dir: how many
+// VMs of gpuType it wants, and its weight.
+type fairShareDemand struct {
+	Pool    string    `json:"pool"`
+	GPUType string    `json:"gpu_type"`
+	Weight  int       `json:"weight"`
+	Demand  int       `json:"demand"`
+	Updated time.Time `json:"updated"`
+}
+
+// fairShareStatus is the pool's last allocation, for the admin API.
+type fairShareStatus struct {
+	Weight   int `json:"weight"`
+	Demand   int `json:"demand"`
+	Share    int `json:"share"`
+	Capacity int `json:"capacity"` // the GPU quota the pools share
+	Pools    int `json:"pools"`
+}
+
+// fairShare splits a regional GPU quota between the pools on a host that
+// create VMs with the same GPU (see --fair-share-dir). Each pool publishes
+// its demand to a file in a shared directory at every poll and reads the
+// others'; every pool computes the same weighted max-min allocation of the
+// quota from them and caps its own target at its share. A pool that wants
+// less than its share leaves the rest to the others, so no quota sits idle
+// while a pool waits.
+type fairShare struct {
+	dir     string
+	pool    string
+	gpuType string
+	weight  int
+
+	mu   sync.Mutex
+	last *fairShareStatus
+}
+
+func newFairShare(dir, pool, gpuType string, weight int) *fairShare {
+	return &fairShare{dir: dir, pool: pool, gpuType: gpuType, weight: weight}
+}
+
+// share publishes demand and returns the pool's share of capacity GPUs, or
+// ok == false when it cannot tell, e.g. before the quota was first read.
+func (f *fairShare) share(now time.Time, demand, capacity int) (share int, ok bool, err error) {
+	if f == nil || capacity <= 0 {
+		return 0, false, nil
+	}
+	own := fairShareDemand{Pool: f.pool, GPUType: f.gpuType, Weight: f.weight, Demand: demand, Updated: now}
+	if err := f.publish(own); err != nil {
+		return 0, false, err
+	}
+	pools, err := f.read(now)
+	if err != nil {
+		return 0, false, err
+	}
+	pools[f.pool] = own
+	alloc := allocateFairShare(capacity, pools)
+	f.mu.Lock()
+	f.last = &fairShareStatus{Weight: f.weight, Demand: demand, Share: alloc[f.pool], Capacity: capacity, Pools: len(pools)}
+	f.mu.Unlock()
+	return alloc[f.pool], true, nil
+}
+
+// status returns the last allocation, or nil before the first.
+func (f *fairShare) status() *fairShareStatus {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.last
+}
+
+func (f *fairShare) path() string {
+	return filepath.Join(f.dir, f.pool+".json")
+}
+
+// publish writes d atomically, so a peer never reads half a file.
+func (f *fairShare) publish(d fairShareDemand) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, "."+f.pool+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path())
+}
+
+// read returns the fresh demands of the pools sharing f's GPU type.
+func (f *fairShare) read(now time.Time) (map[string]fairShareDemand, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	pools := make(map[string]fairShareDemand)
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(f.dir, e.Name()))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // withdrawn since the listing
+		} else if err != nil {
+			return nil, err
+		}
+		var d fairShareDemand
+		if json.Unmarshal(data, &d) != nil || d.GPUType != f.gpuType || d.Weight <= 0 || now.Sub(d.Updated) > fairShareTTL {
+			continue
+		}
+		pools[d.Pool] = d
+	}
+	return pools, nil
+}
+
+// withdraw removes the pool's demand when the scaler exits, leaving its
+// share to the others.
+func (f *fairShare) withdraw() {
+	if f == nil {
+		return
+	}
+	os.Remove(f.path())
+}
+
+// allocateFairShare splits capacity between pools by weighted max-min
+// fairness: one GPU at a time, to the pool with the least per unit of weight
+// that still wants more, ties broken by name so every pool computes the
+// same allocation.
+func allocateFairShare(capacity int, pools map[string]fairShareDemand) map[string]int {
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	alloc := make(map[string]int, len(pools))
+	for range capacity {
+		next := ""
+		for _, name := range names {
+			p := pools[name]
+			if alloc[name] >= p.Demand {
+				continue
+			}
+			// alloc/weight < best's alloc/weight, without division.
+			if next == "" || alloc[name]*pools[next].Weight < alloc[next]*p.Weight {
+				next = name
+			}
+		}
+		if next == "" {
+			break
+		}
+		alloc[next]++
+	}
+	return alloc
+}
+
+// fairShareOf publishes demand and returns the pool's fair share of its GPU
+// type's quota, summed over the regions whose quota the provider read.
+func (s *gcpRunnerScaler) fairShareOf(now time.Time, demand int) (int, bool) {
+	cr, ok := s.vmManager.(capacityReporter)
+	if s.fairShare == nil || !ok {
+		return 0, false
+	}
+	capacity := 0.0
+	for _, q := range cr.Capacity().Quotas {
+		if q.GPUType == s.fairShare.gpuType {
+			capacity += q.Limit
+		}
+	}
+	share, ok, err := s.fairShare.share(now, demand, int(capacity))
+	if err != nil {
+		s.logger.Warn("failed to share the GPU quota with the other pools", "dir", s.fairShare.dir, "error", err)
+	}
+	return share, ok
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	gcpvm "extras/scaler/internal/gcp"
+	"extras/scaler/internal/vmstate"
+)
+
+func TestAllocateFairShare(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		capacity int
+		pools    map[string]fairShareDemand
+		want     map[string]int
+	}{
+		{
+			name:     "by weight",
+			capacity: 12,
+			pools: map[string]fairShareDemand{
+				"linux":   {Weight: 1, Demand: 20},
+				"windows": {Weight: 2, Demand: 20},
+			},
+			want: map[string]int{"linux": 4, "windows": 8},
+		},
+		{
+			name:     "unused share goes to the others",
+			capacity: 12,
+			pools: map[string]fairShareDemand{
+				"linux":   {Weight: 1, Demand: 20},
+				"windows": {Weight: 2, Demand: 3},
+			},
+			want: map[string]int{"linux": 9, "windows": 3},
+		},
+		{
+			name:     "ties by name",
+			capacity: 3,
+			pools: map[string]fairShareDemand{
+				"b": {Weight: 1, Demand: 5},
+				"a": {Weight: 1, Demand: 5},
+			},
+			want: map[string]int{"a": 2, "b": 1},
+		},
+	} {
+		if got := allocateFairShare(tc.capacity, tc.pools); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: allocateFairShare = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func writeFairShareDemand(t *testing.T, dir string, d fairShareDemand) {
+	t.Helper()
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, d.Pool+".json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFairShareReadsFreshPeers(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+	writeFairShareDemand(t, dir, fairShareDemand{Pool: "linux", GPUType: "nvidia-tesla-t4", Weight: 1, Demand: 10, Updated: now.Add(-time.Minute)})
+	writeFairShareDemand(t, dir, fairShareDemand{Pool: "stopped", GPUType: "nvidia-tesla-t4", Weight: 1, Demand: 10, Updated: now.Add(-time.Hour)})
+	writeFairShareDemand(t, dir, fairShareDemand{Pool: "l4", GPUType: "nvidia-l4", Weight: 1, Demand: 10, Updated: now})
+
+	f := newFairShare(dir, "windows", "nvidia-tesla-t4", 1)
+	if share, ok, err := f.share(now, 10, 8); err != nil || !ok || share != 4 {
+		t.Fatalf("share = %d, %v, %v; want half of 8 next to linux only", share, ok, err)
+	}
+	if st := f.status(); st == nil || st.Pools != 2 || st.Capacity != 8 {
+		t.Fatalf("status = %+v, want 2 pools sharing 8", st)
+	}
+	// The peer sees the same split.
+	peer := newFairShare(dir, "linux", "nvidia-tesla-t4", 1)
+	if share, _, _ := peer.share(now, 10, 8); share != 4 {
+		t.Fatalf("peer share = %d, want 4", share)
+	}
+
+	f.withdraw()
+	if share, _, _ := peer.share(now, 10, 8); share != 8 {
+		t.Fatalf("share after withdraw = %d, want all 8", share)
+	}
+	if _, ok, _ := f.share(now, 10, 0); ok {
+		t.Fatal("share without a known quota should not cap")
+	}
+}
+
+// fairShareProvider reports its VMs as the active ones and a T4 quota;
+// creating a VM on it panics.
+type fairShareProvider struct {
+	budgetProvider
+	capacity gcpvm.Capacity
+}
+
+func (p *fairShareProvider) Capacity() gcpvm.Capacity { return p.capacity }
+
+func TestHandleDesiredRunnerCountCapsAtFairShare(t *testing.T) {
+	dir := t.TempDir()
+	writeFairShareDemand(t, dir, fairShareDemand{Pool: "linux", GPUType: "nvidia-tesla-t4", Weight: 1, Demand: 10, Updated: time.Now()})
+	s := &gcpRunnerScaler{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager: &fairShareProvider{
+			budgetProvider: budgetProvider{fakeAdminProvider{vms: []vmstate.VM{
+				{RunnerName: "win-1", Name: "win-1", Busy: true},
+				{RunnerName: "win-2", Name: "win-2", Busy: true},
+			}}},
+			capacity: gcpvm.Capacity{Quotas: []gcpvm.RegionQuota{
+				{Region: "us-east1", GPUType: "nvidia-tesla-t4", Limit: 3},
+				{Region: "us-central1", GPUType: "nvidia-tesla-t4", Limit: 1},
+				{Region: "us-central1", GPUType: "nvidia-l4", Limit: 8},
+			}},
+		},
+		maxRunners: 6,
+		fairShare:  newFairShare(dir, "windows", "nvidia-tesla-t4", 1),
+	}
+	if got, err := s.HandleDesiredRunnerCount(context.Background(), 6); err != nil || got != 2 {
+		t.Fatalf("HandleDesiredRunnerCount = %d, %v, want the 2 existing VMs as the share of 4", got, err)
+	}
+	if st := s.fairShare.status(); st == nil || st.Share != 2 || st.Demand != 6 {
+		t.Fatalf("fair share = %+v, want 2 of a demand of 6", st)
+	}
+}
+
+func TestFairShareFlagsAreValidated(t *testing.T) {
+	base := []string{"--url=https://github.com/o/r"}
+	if _, err := testLoadConfig(t, append(base, "--fair-share-dir=/run/scaler", "--fair-share-weight=2")...); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	for _, args := range [][]string{
+		{"--fair-share-weight=0"},
+		{"--fair-share-dir=/run/scaler", "--gcp-gpu-type=none"},
+		{"--fair-share-dir=/run/scaler", "--provider=libvirt", "--platform=linux"},
+	} {
+		if _, err := testLoadConfig(t, append(base, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+}
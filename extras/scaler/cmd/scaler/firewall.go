@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	gcpvm "extras/scaler/internal/gcp"
+)
+
+// firewallManager is implemented by providers that keep VPC firewall rules
+// for their VMs (GCP).
+type firewallManager interface {
+	EnsureFirewall(ctx context.Context, githubRanges []string) error
+}
+
+// egressLister is the part of *github.Client ensureFirewall uses.
+type egressLister interface {
+	EgressRanges(ctx context.Context) ([]string, error)
+}
+
+// ensureFirewall has fm create or update the firewall rules that limit the
+// VMs' egress to GitHub, whose current addresses client lists, and Google
+// APIs.
+func ensureFirewall(ctx context.Context, fm firewallManager, client egressLister, logger *slog.Logger) error {
+	ranges, err := client.EgressRanges(ctx)
+	if err != nil {
+		return err
+	}
+	if err := fm.EnsureFirewall(ctx, ranges); err != nil {
+		return err
+	}
+	logger.Info("firewall rules in place", "github_ranges", len(ranges))
+	return nil
+}
+
+// deleteFirewall deletes the firewall rules of --manage-firewall; tests
+// replace it.
+var deleteFirewall = gcpvm.DeleteFirewall
+
+// teardownFirewall deletes the firewall rules the pool of cfg created with
+// --manage-firewall.
+func teardownFirewall(ctx context.Context, cfg config, out io.Writer) error {
+	vmPrefix := cfg.gcpVMPrefix
+	if vmPrefix == "" {
+		vmPrefix = defaultVMPrefix(cfg.gcpPlatform)
+	}
+	deleted, err := deleteFirewall(ctx, gcpManagerConfig(cfg, vmPrefix, nil))
+	for _, name := range deleted {
+		fmt.Fprintf(out, "deleted firewall rule %s\n", name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(deleted) == 0 {
+		fmt.Fprintln(out, "no firewall rules to delete")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	gcpvm "extras/scaler/internal/gcp"
+)
+
+type fakeEgress []string
+
+func (f fakeEgress) EgressRanges(context.Context) ([]string, error) { return f, nil }
+
+type fakeFirewallManager struct{ ranges []string }
+
+func (f *fakeFirewallManager) EnsureFirewall(_ context.Context, ranges []string) error {
+	f.ranges = ranges
+	return nil
+}
+
+func TestEnsureFirewallPassesGitHubRanges(t *testing.T) {
+	fm := &fakeFirewallManager{}
+	ranges := fakeEgress{"140.82.112.0/20", "192.30.252.0/22"}
+	if err := ensureFirewall(context.Background(), fm, ranges, slog.New(slog.NewTextHandler(io.Discard, nil))); err != nil {
+		t.Fatalf("ensureFirewall: %v", err)
+	}
+	if !slices.Equal(fm.ranges, ranges) {
+		t.Fatalf("EnsureFirewall got %v, want %v", fm.ranges, ranges)
+	}
+}
+
+func TestTeardownFirewall(t *testing.T) {
+	orig := deleteFirewall
+	t.Cleanup(func() { deleteFirewall = orig })
+	var got gcpvm.ManagerConfig
+	deleteFirewall = func(_ context.Context, cfg gcpvm.ManagerConfig) ([]string, error) {
+		got = cfg
+		return []string{"scaler-linux-test-deny-egress", "scaler-linux-test-github-0"}, nil
+	}
+
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--manage-firewall", "--platform=linux")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	var out bytes.Buffer
+	if err := teardownFirewall(context.Background(), cfg, &out); err != nil {
+		t.Fatalf("teardownFirewall: %v", err)
+	}
+	if got.VMPrefix != defaultVMPrefix("linux") || !got.ManageFirewall {
+		t.Fatalf("DeleteFirewall config = VMPrefix %q, ManageFirewall %v", got.VMPrefix, got.ManageFirewall)
+	}
+	if !strings.Contains(out.String(), "deleted firewall rule scaler-linux-test-github-0") {
+		t.Fatalf("output = %q", out.String())
+	}
+
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--manage-firewall", "--provider=libvirt", "--platform=linux"); err == nil {
+		t.Fatal("--manage-firewall should require --provider=gcp")
+	}
+}
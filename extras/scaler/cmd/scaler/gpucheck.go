@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// gpuCheckInterval is how often the GPU check watcher reads the results of
+// booting VMs.
+const gpuCheckInterval = 30 * time.Second
+
+// gpuCheckReader is implemented by providers whose VMs report the startup
+// script's GPU smoke test: "" or "running" until it has finished, "ok",
+// "skipped" on a VM without a GPU, or "failed: " and the reason.
+type gpuCheckReader interface {
+	GPUCheck(ctx context.Context, runnerName string) (string, error)
+}
+
+// gpuCheckWatcher replaces VMs whose GPU smoke test failed, before their
+// runner can take a job.
+type gpuCheckWatcher struct {
+	reader gpuCheckReader
+	logger *slog.Logger
+	// passed maps each runner whose check passed, or was skipped, to the
+	// boot it passed for, so a runner is looked up until then and once per
+	// boot.
+	passed map[string]time.Time
+}
+
+func (s *gcpRunnerScaler) watchGPUChecks(ctx context.Context, w *gpuCheckWatcher) {
+	ticker := time.NewTicker(gpuCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.checkGPUs(ctx, w)
+	}
+}
+
+func (s *gcpRunnerScaler) checkGPUs(ctx context.Context, w *gpuCheckWatcher) {
+	current := make(map[string]bool)
+	for _, vm := range s.vmManager.VMs() {
+		current[vm.RunnerName] = true
+		since := vmBootStart(vm)
+		if vm.Pending || vm.Busy || w.passed[vm.RunnerName].Equal(since) {
+			continue
+		}
+		result, err := w.reader.GPUCheck(ctx, vm.RunnerName)
+		if err != nil {
+			w.logger.Warn("failed to read GPU check result", "runner", vm.RunnerName, "error", err)
+			continue
+		}
+		switch {
+		case result == "ok" || result == "skipped":
+			w.passed[vm.RunnerName] = since
+		case strings.HasPrefix(result, "failed"):
+			s.logger.Warn("GPU check failed, replacing the VM",
+				"event", eventGPUCheckFailed,
+				"runner", vm.RunnerName,
+				"vm", vm.Name,
+				"location", vm.Location,
+				"result", result,
+			)
+			s.replaceRunnerVM(ctx, vm.RunnerName, "gpu_check_failed")
+		}
+	}
+	for name := range w.passed {
+		if !current[name] {
+			delete(w.passed, name)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"extras/scaler/internal/vmstate"
+)
+
+type fakeGPUChecks struct {
+	results map[string]string
+	lookups []string
+}
+
+func (f *fakeGPUChecks) GPUCheck(_ context.Context, runnerName string) (string, error) {
+	f.lookups = append(f.lookups, runnerName)
+	return f.results[runnerName], nil
+}
+
+func TestCheckGPUsReplacesVMsWhoseCheckFailed(t *testing.T) {
+	now := time.Now()
+	provider := &replaceProvider{replaced: map[string]string{}, fakeAdminProvider: fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-broken", Name: "win-broken", Location: "us-east1-c", CreatedAt: now},
+		{RunnerName: "win-ok", CreatedAt: now},
+		{RunnerName: "win-cpu", CreatedAt: now},
+		{RunnerName: "win-booting", CreatedAt: now},
+		{RunnerName: "win-busy", Busy: true, CreatedAt: now},
+		{RunnerName: "win-pending", Pending: true},
+	}}}
+	client, actions := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      provider,
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "win",
+	}
+	checks := &fakeGPUChecks{results: map[string]string{
+		"win-broken":  "failed: nvidia-smi: No devices were found",
+		"win-ok":      "ok",
+		"win-cpu":     "skipped",
+		"win-booting": "running",
+	}}
+	w := &gpuCheckWatcher{reader: checks, logger: s.logger, passed: map[string]time.Time{}}
+
+	s.checkGPUs(context.Background(), w)
+
+	if len(checks.lookups) != 4 {
+		t.Fatalf("lookups = %v, want the four idle VMs", checks.lookups)
+	}
+	if _, ok := provider.replaced["win-broken"]; !ok || len(provider.replaced) != 1 {
+		t.Fatalf("replaced = %v, want only win-broken", provider.replaced)
+	}
+	if len(actions.removed) != 1 {
+		t.Fatalf("removed = %v, want the broken VM's runner", actions.removed)
+	}
+
+	// A passed or skipped check is not read again; one still running is.
+	checks.lookups = nil
+	s.checkGPUs(context.Background(), w)
+	for _, name := range checks.lookups {
+		if name == "win-ok" || name == "win-cpu" {
+			t.Fatalf("%s was looked up again after its check finished", name)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// headroomPolicy is --headroom-policy: how the scale-up follows the trend
+// of the queue between listener messages.
+type headroomPolicy struct {
+	// growRate is the growth, in jobs per minute, at or above which the
+	// queue counts as growing fast.
+	growRate float64
+	// headroom is the VMs created beyond the target while it does, so the
+	// jobs still to come find a runner booting.
+	headroom int
+	// shrinkStep caps the VMs one scale-up creates while the queue
+	// shrinks; -1 leaves it uncapped.
+	shrinkStep int
+}
+
+// parseHeadroomPolicy parses --headroom-policy, e.g.
+// "grow-rate=5,headroom=3,shrink-step=1". It returns nil for "".
+func parseHeadroomPolicy(v string) (*headroomPolicy, error) {
+	values, err := parseKeyValues(v)
+	if err != nil || values == nil {
+		return nil, err
+	}
+	p := &headroomPolicy{shrinkStep: -1}
+	for key, value := range values {
+		switch key {
+		case "grow-rate":
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil || rate <= 0 {
+				return nil, fmt.Errorf("grow-rate %q is not a positive number of jobs per minute", value)
+			}
+			p.growRate = rate
+		case "headroom", "shrink-step":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%s %q is not a non-negative number", key, value)
+			}
+			if key == "headroom" {
+				p.headroom = n
+			} else {
+				p.shrinkStep = n
+			}
+		default:
+			return nil, fmt.Errorf("unknown key %q (want grow-rate, headroom or shrink-step)", key)
+		}
+	}
+	if p.headroom > 0 && p.growRate == 0 {
+		return nil, fmt.Errorf("headroom requires grow-rate")
+	}
+	return p, nil
+}
+
+// queueTrend tracks how fast the listener's pending job count changes. It is
+// safe for concurrent use; a nil *queueTrend adds no headroom and caps
+// nothing.
+type queueTrend struct {
+	policy headroomPolicy
+
+	mu     sync.Mutex
+	last   int
+	lastAt time.Time
+}
+
+func newQueueTrend(policy *headroomPolicy) *queueTrend {
+	if policy == nil {
+		return nil
+	}
+	return &queueTrend{policy: *policy}
+}
+
+// queueRate is the trend observe saw: the change in pending jobs per minute
+// since the previous message, and what the policy makes of it.
+type queueRate struct {
+	perMinute float64
+	headroom  int // VMs to add to the target
+	step      int // cap on the VMs to create; -1 for none
+}
+
+// observe records count at now and returns the trend since the previous
+// message. The first message has no trend.
+func (q *queueTrend) observe(now time.Time, count int) queueRate {
+	r := queueRate{step: -1}
+	if q == nil {
+		return r
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.lastAt.IsZero() && now.After(q.lastAt) {
+		r.perMinute = float64(count-q.last) / now.Sub(q.lastAt).Minutes()
+	}
+	q.last, q.lastAt = count, now
+	switch {
+	case q.policy.growRate > 0 && r.perMinute >= q.policy.growRate:
+		r.headroom = q.policy.headroom
+	case r.perMinute < 0:
+		r.step = q.policy.shrinkStep
+	}
+	return r
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestParseHeadroomPolicy(t *testing.T) {
+	p, err := parseHeadroomPolicy("grow-rate=2.5, headroom=3, shrink-step=0")
+	if err != nil || *p != (headroomPolicy{growRate: 2.5, headroom: 3, shrinkStep: 0}) {
+		t.Fatalf("parseHeadroomPolicy = %+v, %v", p, err)
+	}
+	if p, err := parseHeadroomPolicy("shrink-step=2"); err != nil || p.headroom != 0 || p.shrinkStep != 2 {
+		t.Fatalf("parseHeadroomPolicy(shrink-step only) = %+v, %v", p, err)
+	}
+	if p, err := parseHeadroomPolicy(""); p != nil || err != nil {
+		t.Fatalf("parseHeadroomPolicy(\"\") = %+v, %v; want nil", p, err)
+	}
+	for _, spec := range []string{
+		"headroom=3",
+		"grow-rate=0,headroom=1",
+		"grow-rate=5,headroom=-1",
+		"grow-rate=5,shrink-step=x",
+		"grow-rate=5,burst=2",
+	} {
+		if _, err := parseHeadroomPolicy(spec); err == nil {
+			t.Errorf("parseHeadroomPolicy(%q) should fail", spec)
+		}
+	}
+}
+
+func TestQueueTrendFollowsTheQueue(t *testing.T) {
+	q := newQueueTrend(&headroomPolicy{growRate: 5, headroom: 2, shrinkStep: 1})
+	now := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+	if r := q.observe(now, 10); r.headroom != 0 || r.step != -1 {
+		t.Fatalf("first message = %+v, want no trend", r)
+	}
+	// 3 more jobs in 30s is 6 a minute.
+	if r := q.observe(now.Add(30*time.Second), 13); r.perMinute != 6 || r.headroom != 2 || r.step != -1 {
+		t.Fatalf("growing fast = %+v, want headroom", r)
+	}
+	if r := q.observe(now.Add(90*time.Second), 15); r.headroom != 0 || r.step != -1 {
+		t.Fatalf("growing slowly = %+v, want neither headroom nor a cap", r)
+	}
+	if r := q.observe(now.Add(2*time.Minute), 12); r.perMinute >= 0 || r.step != 1 {
+		t.Fatalf("shrinking = %+v, want a cap of 1", r)
+	}
+
+	var none *queueTrend
+	if r := none.observe(now, 100); r.headroom != 0 || r.step != -1 {
+		t.Fatalf("no policy = %+v, want no trend", r)
+	}
+}
+
+func TestHandleDesiredRunnerCountFollowsQueueTrend(t *testing.T) {
+	provider := &slotProvider{slots: 3}
+	client, _ := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      provider,
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "a100",
+		maxRunners:     20,
+		trend:          newQueueTrend(&headroomPolicy{growRate: 5, headroom: 3, shrinkStep: 3}),
+	}
+
+	// From 0 to 6 jobs in a minute: 3 runners of headroom.
+	s.trend.lastAt = time.Now().Add(-time.Minute)
+	if _, err := s.HandleDesiredRunnerCount(context.Background(), 6); err != nil {
+		t.Fatalf("HandleDesiredRunnerCount: %v", err)
+	}
+	s.creates.Wait()
+	if got := provider.ActiveCount(); got != 9 {
+		t.Fatalf("runners after fast growth = %d, want 6 jobs + 3 headroom", got)
+	}
+
+	// 15 jobs, but down from 20: one VM's worth of runners at a time.
+	s.trend.last, s.trend.lastAt = 20, time.Now().Add(-time.Minute)
+	if _, err := s.HandleDesiredRunnerCount(context.Background(), 15); err != nil {
+		t.Fatalf("HandleDesiredRunnerCount: %v", err)
+	}
+	s.creates.Wait()
+	if got := provider.ActiveCount(); got != 12 {
+		t.Fatalf("runners while shrinking = %d, want 3 more", got)
+	}
+}
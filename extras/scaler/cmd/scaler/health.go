@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/actions/scaleset"
+	"github.com/actions/scaleset/listener"
+)
+
+// gcpCheckTTL bounds how often /readyz calls the provider's API.
+const gcpCheckTTL = 30 * time.Second
+
+// pinger is implemented by providers that can check their API is reachable.
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// healthMonitor tracks what /healthz and /readyz report. The listener long
+// polls GitHub (~50s per poll), so a scaler whose last successful poll is
+// older than maxPollAge has wedged even if the process is still up. The
+// limit must cover a scale-up, which blocks polling until its VMs exist.
+type healthMonitor struct {
+	maxPollAge time.Duration
+	provider   vmProvider
+	session    func() scaleset.RunnerScaleSetSession
+	draining   func() bool // nil until the scaler exists
+	now        func() time.Time
+
+	mu          sync.Mutex
+	lastPoll    time.Time
+	lastPollErr error
+	pingedAt    time.Time
+	pingErr     error
+}
+
+func newHealthMonitor(maxPollAge time.Duration, provider vmProvider, session func() scaleset.RunnerScaleSetSession) *healthMonitor {
+	return &healthMonitor{
+		maxPollAge: maxPollAge,
+		provider:   provider,
+		session:    session,
+		now:        time.Now,
+		// Startup counts as a poll so the first long poll has time to finish.
+		lastPoll: time.Now(),
+	}
+}
+
+func (h *healthMonitor) recordPoll(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastPollErr = err
+	if err == nil {
+		h.lastPoll = h.now()
+	}
+}
+
+// lastPollStatus returns the time of the last successful poll and the
+// latest poll's error.
+func (h *healthMonitor) lastPollStatus() (time.Time, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastPoll, h.lastPollErr
+}
+
+// healthStatus is the JSON body of /healthz and /readyz.
+type healthStatus struct {
+	Status          string    `json:"status"`
+	SessionID       string    `json:"session_id,omitempty"`
+	LastPoll        time.Time `json:"last_poll"`
+	LastPollAgeSecs float64   `json:"last_poll_age_seconds"`
+	LastPollError   string    `json:"last_poll_error,omitempty"`
+	Draining        bool      `json:"draining"`
+	ActiveVMs       int       `json:"active_vms"`
+	Provider        string    `json:"provider_api,omitempty"`
+}
+
+// live reports whether the listener is still polling.
+func (h *healthMonitor) live() (healthStatus, bool) {
+	h.mu.Lock()
+	st := healthStatus{
+		LastPoll:        h.lastPoll,
+		LastPollAgeSecs: h.now().Sub(h.lastPoll).Seconds(),
+	}
+	if h.lastPollErr != nil {
+		st.LastPollError = h.lastPollErr.Error()
+	}
+	h.mu.Unlock()
+
+	if h.session != nil {
+		st.SessionID = h.session().SessionID.String()
+	}
+	if h.draining != nil {
+		st.Draining = h.draining()
+	}
+	st.ActiveVMs = h.provider.ActiveCount()
+	ok := st.LastPollAgeSecs <= h.maxPollAge.Seconds()
+	st.Status = "ok"
+	if !ok {
+		st.Status = "listener stalled"
+	}
+	return st, ok
+}
+
+// ready additionally checks the provider API, caching the result for
+// gcpCheckTTL.
+func (h *healthMonitor) ready(ctx context.Context) (healthStatus, bool) {
+	st, ok := h.live()
+	p, canPing := h.provider.(pinger)
+	if !canPing {
+		return st, ok
+	}
+
+	h.mu.Lock()
+	if h.pingedAt.IsZero() || h.now().Sub(h.pingedAt) >= gcpCheckTTL {
+		h.mu.Unlock()
+		err := p.Ping(ctx)
+		h.mu.Lock()
+		h.pingedAt, h.pingErr = h.now(), err
+	}
+	pingErr := h.pingErr
+	h.mu.Unlock()
+
+	st.Provider = "ok"
+	if pingErr != nil {
+		st.Provider = pingErr.Error()
+		if ok {
+			st.Status = "provider API unreachable"
+		}
+		ok = false
+	}
+	return st, ok
+}
+
+func (h *healthMonitor) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		st, ok := h.live()
+		writeHealth(w, st, ok)
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		st, ok := h.ready(r.Context())
+		writeHealth(w, st, ok)
+	})
+}
+
+func writeHealth(w http.ResponseWriter, st healthStatus, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(st)
+}
+
+// pollRecordingClient wraps the listener's session client to record each
+// poll's outcome for the health endpoints.
+type pollRecordingClient struct {
+	listener.Client
+	health *healthMonitor
+}
+
+func (c *pollRecordingClient) GetMessage(ctx context.Context, lastMessageID, maxCapacity int) (*scaleset.RunnerScaleSetMessage, error) {
+	msg, err := c.Client.GetMessage(ctx, lastMessageID, maxCapacity)
+	c.health.recordPoll(err)
+	return msg, err
+}
+
+// runWatchdog pings the systemd watchdog (WatchdogSec= with
+// NotifyAccess=main) at half the configured interval while live reports
+// true (the listener polls, or a standby reaches its lease), so systemd
+// restarts a wedged scaler. It returns immediately when the unit has no
+// watchdog.
+func runWatchdog(ctx context.Context, live func() bool, logger *slog.Logger) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if err != nil || usec <= 0 || socket == "" {
+		return
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:] // abstract namespace
+	}
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		logger.Warn("systemd watchdog disabled", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	ticker := time.NewTicker(time.Duration(usec) * time.Microsecond / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !live() {
+			logger.Warn("scaler stalled, withholding systemd watchdog ping")
+			continue
+		}
+		if _, err := conn.Write([]byte("WATCHDOG=1")); err != nil {
+			logger.Warn("systemd watchdog ping failed", "error", err)
+		}
+	}
+}
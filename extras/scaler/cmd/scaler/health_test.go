@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/actions/scaleset"
+)
+
+type fakePingProvider struct {
+	vmProvider
+	pingErr error
+	pings   int
+}
+
+func (p *fakePingProvider) ActiveCount() int { return 2 }
+
+func (p *fakePingProvider) Ping(context.Context) error {
+	p.pings++
+	return p.pingErr
+}
+
+func getHealth(t *testing.T, mux *http.ServeMux, path string) (int, healthStatus) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var st healthStatus
+	if err := json.NewDecoder(rec.Body).Decode(&st); err != nil {
+		t.Fatalf("decoding %s: %v", path, err)
+	}
+	return rec.Code, st
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	provider := &fakePingProvider{}
+	h := newHealthMonitor(10*time.Minute, provider, func() scaleset.RunnerScaleSetSession { return scaleset.RunnerScaleSetSession{} })
+	now := time.Now()
+	h.now = func() time.Time { return now }
+	h.draining = func() bool { return true }
+	h.recordPoll(nil)
+	mux := http.NewServeMux()
+	h.register(mux)
+
+	code, st := getHealth(t, mux, "/healthz")
+	if code != http.StatusOK || st.Status != "ok" || !st.Draining || st.ActiveVMs != 2 {
+		t.Fatalf("/healthz = %d %+v, want 200 ok", code, st)
+	}
+	if code, st := getHealth(t, mux, "/readyz"); code != http.StatusOK || st.Provider != "ok" {
+		t.Fatalf("/readyz = %d %+v, want 200 with provider ok", code, st)
+	}
+
+	// A failing ping is cached for gcpCheckTTL, then retried.
+	provider.pingErr = errors.New("compute API unreachable")
+	if code, _ := getHealth(t, mux, "/readyz"); code != http.StatusOK || provider.pings != 1 {
+		t.Fatalf("/readyz = %d after %d pings, want cached success", code, provider.pings)
+	}
+	now = now.Add(gcpCheckTTL)
+	if code, st := getHealth(t, mux, "/readyz"); code != http.StatusServiceUnavailable || st.Provider != "compute API unreachable" {
+		t.Fatalf("/readyz = %d %+v, want 503 with the ping error", code, st)
+	}
+
+	// Failed polls do not refresh liveness; an old enough success fails it.
+	now = now.Add(11 * time.Minute)
+	h.recordPoll(errors.New("failed to get next message"))
+	code, st = getHealth(t, mux, "/healthz")
+	if code != http.StatusServiceUnavailable || st.Status != "listener stalled" || st.LastPollError == "" {
+		t.Fatalf("/healthz = %d %+v, want 503 listener stalled", code, st)
+	}
+}
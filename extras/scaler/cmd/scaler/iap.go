@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"extras/scaler/internal/vmstate"
+)
+
+// runGcloud runs gcloud with the terminal attached; tests replace it.
+var runGcloud = func(ctx context.Context, args []string) error {
+	// gcloud gets the terminal's Ctrl-C too and ends the session; the
+	// scaler waits for it rather than dying first.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	cmd := exec.CommandContext(ctx, "gcloud", args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// iapOptions are the flags of `scaler ssh` and `scaler rdp`, the kind.
+type iapOptions struct {
+	kind      string
+	target    *adminFlags
+	print     bool
+	localPort int
+}
+
+func (o *iapOptions) register(fs *flag.FlagSet) {
+	o.target = newAdminFlags(fs)
+	fs.BoolVar(&o.print, "print", false, "Print the gcloud command instead of running it")
+	if o.kind == "rdp" {
+		fs.IntVar(&o.localPort, "local-port", 33389, "Local port the RDP tunnel listens on")
+	}
+}
+
+// runIAP implements `scaler ssh [flags] RUNNER` and `scaler rdp [flags]
+// RUNNER`. It looks the runner's VM up in a running scaler's admin API and
+// opens an SSH session to it, or an RDP tunnel on a local port, through
+// Identity-Aware Proxy, so the VM needs no external IP or open firewall
+// beyond IAP's range. With --print it only prints the gcloud command.
+func runIAP(ctx context.Context, kind string, args []string, out io.Writer) error {
+	o := iapOptions{kind: kind}
+	fs := flag.NewFlagSet("scaler "+kind, flag.ContinueOnError)
+	o.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: scaler %s [flags] RUNNER", kind)
+	}
+	client, err := o.target.client()
+	if err != nil {
+		return err
+	}
+	body, err := client.do(ctx, http.MethodGet, "/api/v1/status", nil)
+	if err != nil {
+		return err
+	}
+	var st adminStatus
+	if err := json.Unmarshal(body, &st); err != nil {
+		return err
+	}
+	gcloudArgs, err := iapCommand(kind, st, fs.Arg(0), o.localPort)
+	if err != nil {
+		return err
+	}
+
+	if o.print {
+		_, err := fmt.Fprintln(out, "gcloud "+strings.Join(gcloudArgs, " "))
+		return err
+	}
+	if kind == "rdp" {
+		fmt.Fprintf(out, "Connect your RDP client to localhost:%d; Ctrl-C closes the tunnel.\n", o.localPort)
+	}
+	return runGcloud(ctx, gcloudArgs)
+}
+
+// iapCommand returns the gcloud arguments that open an IAP session of kind
+// ("ssh" or "rdp") to the VM of runner, which may also be the VM's name.
+func iapCommand(kind string, st adminStatus, runner string, localPort int) ([]string, error) {
+	if st.Provider != "gcp" {
+		return nil, fmt.Errorf("scaler %s needs a GCP pool, not %s", kind, st.Provider)
+	}
+	vm, ok := findVM(st.VMs, runner)
+	if !ok {
+		return nil, fmt.Errorf("runner %q is not tracked by scale set %s", runner, st.ScaleSet)
+	}
+	if vm.Pending {
+		return nil, fmt.Errorf("VM of runner %q is still being created", runner)
+	}
+	var args []string
+	switch kind {
+	case "ssh":
+		args = []string{"compute", "ssh", vm.Name, "--tunnel-through-iap"}
+	case "rdp":
+		args = []string{"compute", "start-iap-tunnel", vm.Name, "3389", "--local-host-port=localhost:" + strconv.Itoa(localPort)}
+	default:
+		return nil, errors.New("kind must be ssh or rdp")
+	}
+	args = append(args, "--zone="+vm.Location)
+	if st.Project != "" {
+		args = append(args, "--project="+st.Project)
+	}
+	return args, nil
+}
+
+// findVM returns the VM of runner, or the VM named runner.
+func findVM(vms []vmstate.VM, runner string) (vmstate.VM, bool) {
+	for _, vm := range vms {
+		if vm.RunnerName == runner {
+			return vm, true
+		}
+	}
+	for _, vm := range vms {
+		if vm.Name == runner {
+			return vm, true
+		}
+	}
+	return vmstate.VM{}, false
+}
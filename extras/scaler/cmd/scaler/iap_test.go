@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"extras/scaler/internal/vmstate"
+)
+
+func TestIAPCommandRunsGcloud(t *testing.T) {
+	a, mux, _ := newTestAdmin()
+	a.project = "slang-ci"
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	var got []string
+	defer func(orig func(context.Context, []string) error) { runGcloud = orig }(runGcloud)
+	runGcloud = func(_ context.Context, args []string) error {
+		got = args
+		return nil
+	}
+
+	var out bytes.Buffer
+	if err := runIAP(context.Background(), "ssh", []string{"--addr", srv.URL, "--token", "s3cret", "win-1"}, &out); err != nil {
+		t.Fatalf("runIAP: %v", err)
+	}
+	want := []string{"compute", "ssh", "win-1", "--tunnel-through-iap", "--zone=us-east1-c", "--project=slang-ci"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("gcloud %v, want %v", got, want)
+	}
+
+	out.Reset()
+	if err := runIAP(context.Background(), "rdp", []string{"--addr", srv.URL, "--token", "s3cret", "--print", "--local-port=4000", "win-1"}, &out); err != nil {
+		t.Fatalf("runIAP: %v", err)
+	}
+	if want := "gcloud compute start-iap-tunnel win-1 3389 --local-host-port=localhost:4000 --zone=us-east1-c --project=slang-ci\n"; out.String() != want {
+		t.Fatalf("printed %q, want %q", out.String(), want)
+	}
+}
+
+func TestIAPCommandRejectsUnknownRunners(t *testing.T) {
+	st := adminStatus{ScaleSet: "windows-gpu", Provider: "gcp", VMs: []vmstate.VM{
+		{RunnerName: "win-1-a", Name: "win-1", Location: "us-east1-c"},
+		{RunnerName: "win-2", Pending: true},
+	}}
+	if args, err := iapCommand("ssh", st, "win-1", 0); err != nil || args[2] != "win-1" {
+		t.Fatalf("iapCommand by VM name = %v, %v", args, err)
+	}
+	for runner, want := range map[string]string{
+		"win-3": "not tracked",
+		"win-2": "still being created",
+	} {
+		if _, err := iapCommand("ssh", st, runner, 0); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("iapCommand(%s) error = %v, want %q", runner, err, want)
+		}
+	}
+	st.Provider = "orka"
+	if _, err := iapCommand("ssh", st, "win-1-a", 0); err == nil {
+		t.Fatal("iapCommand should need a GCP pool")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"extras/scaler/internal/notify"
+	"extras/scaler/internal/vmstate"
+)
+
+// idleCostStatus is the day's idle VM time and its cost against
+// --idle-cost-alert-usd.
+type idleCostStatus struct {
+	ThresholdUSD float64 `json:"threshold_usd"`
+	// IdleUSD is the estimated cost since midnight UTC of VMs running no
+	// job, and IdleVMHours and VMHours the VMs' idle and total hours.
+	IdleUSD     float64 `json:"idle_usd"`
+	IdleVMHours float64 `json:"idle_vm_hours"`
+	VMHours     float64 `json:"vm_hours"`
+}
+
+// idleFraction returns the share of the day's VM hours that were idle.
+func (c idleCostStatus) idleFraction() float64 {
+	if c.VMHours == 0 {
+		return 0
+	}
+	return c.IdleVMHours / c.VMHours
+}
+
+// idleCost tracks the VM time spent idle per UTC day, warm or waiting
+// between jobs, and its estimated cost (see --idle-cost-alert-usd). Like
+// dailyBudget, it accrues at the VM counts of the last update.
+type idleCost struct {
+	threshold float64
+
+	mu         sync.Mutex
+	day        time.Time // midnight UTC starting the day the totals are for
+	st         idleCostStatus
+	vms, idle  int     // the VMs and idle VMs at last
+	idleHourly float64 // the idle VMs' cost per hour at last
+	last       time.Time
+	alerted    bool // whether the day's alert was sent
+}
+
+func newIdleCost(threshold float64) *idleCost {
+	return &idleCost{threshold: threshold}
+}
+
+// update accrues the idle time since the last update and records vms as
+// the current VMs. alert is set the first time in a day the idle cost
+// exceeds the threshold.
+func (c *idleCost) update(now time.Time, vms []vmstate.VM) (st idleCostStatus, alert bool) {
+	if c == nil {
+		return idleCostStatus{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accrue(now)
+	c.vms, c.idle, c.idleHourly = idleVMs(vms)
+	if c.st.IdleUSD > c.threshold && !c.alerted {
+		c.alerted = true
+		alert = true
+	}
+	return c.st, alert
+}
+
+// status returns the day's totals so far, without recording new VMs.
+func (c *idleCost) status(now time.Time) *idleCostStatus {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accrue(now)
+	st := c.st
+	return &st
+}
+
+func (c *idleCost) accrue(now time.Time) {
+	now = now.UTC()
+	day := now.Truncate(24 * time.Hour)
+	if !day.Equal(c.day) {
+		c.day, c.st, c.alerted = day, idleCostStatus{ThresholdUSD: c.threshold}, false
+	}
+	if !c.last.IsZero() && now.After(c.last) {
+		from := c.last
+		if from.Before(day) {
+			from = day
+		}
+		hours := now.Sub(from).Hours()
+		c.st.VMHours += float64(c.vms) * hours
+		c.st.IdleVMHours += float64(c.idle) * hours
+		c.st.IdleUSD += c.idleHourly * hours
+	}
+	c.last = now
+}
+
+// idleVMs counts the VMs of vms and those running no job, with the idle
+// ones' cost per hour. A VM running several runners is idle while none
+// runs a job. Creates in flight are not counted; a booting VM is.
+func idleVMs(vms []vmstate.VM) (total, idle int, idleHourly float64) {
+	type vmUse struct {
+		busy   bool
+		hourly float64
+	}
+	byName := make(map[string]*vmUse)
+	for _, vm := range vms {
+		if vm.Pending || vm.Name == "" {
+			continue
+		}
+		u, ok := byName[vm.Name]
+		if !ok {
+			u = &vmUse{}
+			byName[vm.Name] = u
+		}
+		u.busy = u.busy || vm.Busy
+		u.hourly += vm.HourlyCost
+	}
+	for _, u := range byName {
+		if !u.busy {
+			idle++
+			idleHourly += u.hourly
+		}
+	}
+	return len(byName), idle, idleHourly
+}
+
+// checkIdleCost updates the day's idle time with the current VMs and
+// alerts the first time in a day the idle cost exceeds the threshold.
+func (s *gcpRunnerScaler) checkIdleCost(ctx context.Context, now time.Time) {
+	if s.idleCost == nil {
+		return
+	}
+	st, alert := s.idleCost.update(now, s.vmManager.VMs())
+	if !alert {
+		return
+	}
+	s.logger.Warn("idle VM cost today exceeds the threshold", "event", eventIdleCostExceeded,
+		"idle_usd", st.IdleUSD, "threshold_usd", st.ThresholdUSD, "idle_fraction", st.idleFraction(), "min_runners", s.minRunnersAt(now))
+	s.notifier.Notify(ctx, notify.IdleCost, fmt.Sprintf("idle VMs have cost $%.2f today, over the $%.2f threshold; %.0f%% of %.1f VM hours were idle with --min-runners=%d",
+		st.IdleUSD, st.ThresholdUSD, 100*st.idleFraction(), st.VMHours, s.minRunnersAt(now)))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"extras/scaler/internal/vmstate"
+)
+
+func TestIdleCostAccruesIdleVMTime(t *testing.T) {
+	c := newIdleCost(5)
+	start := time.Date(2026, 3, 2, 20, 0, 0, 0, time.UTC)
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+	vms := []vmstate.VM{
+		{RunnerName: "win-1", Name: "win-1", Busy: true, HourlyCost: 2},
+		{RunnerName: "win-2", Name: "win-2", HourlyCost: 1.5},
+		// A VM with two runners, one of them busy.
+		{RunnerName: "win-3-a", Name: "win-3", HourlyCost: 1},
+		{RunnerName: "win-3-b", Name: "win-3", Busy: true},
+		{RunnerName: "win-4", Pending: true},
+	}
+
+	if st, alert := c.update(start, vms); st.VMHours != 0 || alert {
+		t.Fatalf("first update = %+v, %v; want nothing accrued", st, alert)
+	}
+	st, alert := c.update(start.Add(2*time.Hour), vms)
+	if !near(st.VMHours, 6) || !near(st.IdleVMHours, 2) || !near(st.IdleUSD, 3) || alert {
+		t.Fatalf("update at 22:00 = %+v, %v; want 2 of 6 VM hours idle for $3", st, alert)
+	}
+	if !near(st.idleFraction(), 1.0/3) {
+		t.Fatalf("idle fraction = %v, want 1/3", st.idleFraction())
+	}
+	vms[0].Busy = false
+	st, alert = c.update(start.Add(3*time.Hour), vms)
+	if !near(st.IdleUSD, 4.5) || alert {
+		t.Fatalf("update at 23:00 = %+v, %v; want $4.50 idle", st, alert)
+	}
+	st, alert = c.update(start.Add(3*time.Hour+45*time.Minute), vms)
+	if !near(st.IdleUSD, 7.125) || !alert {
+		t.Fatalf("update at 23:45 = %+v, %v; want an alert over $5", st, alert)
+	}
+	if _, alert := c.update(start.Add(3*time.Hour+50*time.Minute), vms); alert {
+		t.Fatal("the alert should be sent once a day")
+	}
+
+	// Only the time after midnight counts toward the new day.
+	st = *c.status(start.Add(5 * time.Hour))
+	if !near(st.IdleVMHours, 2) || !near(st.IdleUSD, 3.5) || st.ThresholdUSD != 5 {
+		t.Fatalf("status at 01:00 = %+v, want the new day's hour only", st)
+	}
+
+	var none *idleCost
+	if _, alert := none.update(start, vms); alert || none.status(start) != nil {
+		t.Fatal("no threshold should never alert")
+	}
+}
+
+func TestCheckIdleCostLogsTheAlert(t *testing.T) {
+	var logs bytes.Buffer
+	now := time.Now()
+	s := &gcpRunnerScaler{
+		logger:    slog.New(slog.NewTextHandler(&logs, nil)),
+		vmManager: &fakeAdminProvider{vms: []vmstate.VM{{RunnerName: "win-1", Name: "win-1", HourlyCost: 100}}},
+		idleCost:  newIdleCost(1),
+	}
+	s.checkIdleCost(context.Background(), now)
+	s.checkIdleCost(context.Background(), now.Add(time.Minute))
+	if !strings.Contains(logs.String(), "event="+eventIdleCostExceeded) {
+		t.Fatalf("logs = %s, want %s", logs.String(), eventIdleCostExceeded)
+	}
+	(&gcpRunnerScaler{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}).checkIdleCost(context.Background(), now)
+}
+
+func TestIdleCostFlagIsValidated(t *testing.T) {
+	base := []string{"--url=https://github.com/o/r"}
+	if _, err := testLoadConfig(t, append(base, "--idle-cost-alert-usd=50")...); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	for _, args := range [][]string{
+		{"--idle-cost-alert-usd=-1"},
+		{"--idle-cost-alert-usd=50", "--provider=libvirt", "--platform=linux"},
+	} {
+		if _, err := testLoadConfig(t, append(base, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+}
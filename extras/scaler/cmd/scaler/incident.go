@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"extras/scaler/internal/incident"
+)
+
+const (
+	// incidentCheckInterval is how often watchIncidents re-evaluates.
+	incidentCheckInterval = time.Minute
+	// incidentNoVMs is the key of the incident opened when no VM can be
+	// created.
+	incidentNoVMs = "no_vms"
+)
+
+// createStall tracks since when runner creation has been failing: the
+// first JIT config or VM create failure with no success since. Scale-ups
+// that are not needed (at or above target, paused, draining) clear it, so
+// a failure followed by the demand going away does not page. A nil
+// *createStall tracks nothing.
+type createStall struct {
+	mu           sync.Mutex
+	failingSince time.Time
+	lastErr      error
+}
+
+func (c *createStall) failed(err error, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failingSince.IsZero() {
+		c.failingSince = now
+	}
+	c.lastErr = err
+}
+
+func (c *createStall) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.failingSince, c.lastErr = time.Time{}, nil
+	c.mu.Unlock()
+}
+
+func (c *createStall) since() (time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.failingSince, c.lastErr
+}
+
+// incidentWatcher opens an incident once the scaler has been unable to
+// create any VM for after: either every create has failed (quota, GCP API)
+// or the listener has not completed a GitHub poll (auth, network), which
+// stops scale-ups without any create failing. The incident is resolved
+// when both recover.
+type incidentWatcher struct {
+	pager  incident.Pager
+	after  time.Duration
+	stall  *createStall
+	health *healthMonitor
+	logger *slog.Logger
+
+	open bool
+}
+
+func (w *incidentWatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(incidentCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		w.check(ctx, time.Now())
+	}
+}
+
+func (w *incidentWatcher) check(ctx context.Context, now time.Time) {
+	var problem string
+	if since, err := w.stall.since(); !since.IsZero() && now.Sub(since) >= w.after {
+		problem = fmt.Sprintf("no runner VM could be created for %s; last error: %v", now.Sub(since).Round(time.Second), err)
+	} else if lastPoll, pollErr := w.health.lastPollStatus(); now.Sub(lastPoll) >= w.after {
+		problem = fmt.Sprintf("no successful GitHub poll for %s, so no runners are being created", now.Sub(lastPoll).Round(time.Second))
+		if pollErr != nil {
+			problem += fmt.Sprintf("; last error: %v", pollErr)
+		}
+	}
+
+	switch {
+	case problem != "" && !w.open:
+		if err := w.pager.Trigger(ctx, incidentNoVMs, problem); err != nil {
+			w.logger.Error("failed to open incident", "error", err)
+			return
+		}
+		w.open = true
+		w.logger.Warn("opened incident", "reason", problem)
+	case problem == "" && w.open:
+		if err := w.pager.Resolve(ctx, incidentNoVMs); err != nil {
+			w.logger.Error("failed to resolve incident", "error", err)
+			return
+		}
+		w.open = false
+		w.logger.Info("resolved incident")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakePager struct {
+	events []string
+}
+
+func (p *fakePager) Trigger(_ context.Context, key, summary string) error {
+	p.events = append(p.events, "trigger "+key+": "+summary)
+	return nil
+}
+
+func (p *fakePager) Resolve(_ context.Context, key string) error {
+	p.events = append(p.events, "resolve "+key)
+	return nil
+}
+
+func newTestIncidentWatcher(now time.Time) (*incidentWatcher, *fakePager) {
+	pager := &fakePager{}
+	health := newHealthMonitor(time.Hour, nil, nil)
+	health.lastPoll = now
+	return &incidentWatcher{
+		pager:  pager,
+		after:  30 * time.Minute,
+		stall:  &createStall{},
+		health: health,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}, pager
+}
+
+func TestIncidentOnCreateStall(t *testing.T) {
+	start := time.Now()
+	w, pager := newTestIncidentWatcher(start)
+	ctx := context.Background()
+
+	w.stall.failed(errors.New("first"), start)
+	w.stall.failed(errors.New("QUOTA_EXCEEDED"), start.Add(20*time.Minute))
+	w.health.lastPoll = start.Add(29 * time.Minute)
+	w.check(ctx, start.Add(29*time.Minute))
+	if len(pager.events) != 0 {
+		t.Fatalf("events = %q, want none before --incident-after", pager.events)
+	}
+
+	w.check(ctx, start.Add(30*time.Minute))
+	w.check(ctx, start.Add(31*time.Minute))
+	if len(pager.events) != 1 || !strings.Contains(pager.events[0], "trigger no_vms: no runner VM could be created for 30m0s; last error: QUOTA_EXCEEDED") {
+		t.Fatalf("events = %q, want one trigger naming the last error", pager.events)
+	}
+
+	w.stall.clear()
+	w.check(ctx, start.Add(32*time.Minute))
+	if len(pager.events) != 2 || pager.events[1] != "resolve no_vms" {
+		t.Fatalf("events = %q, want a resolve once a create succeeds", pager.events)
+	}
+}
+
+func TestIncidentOnStalledPolling(t *testing.T) {
+	start := time.Now()
+	w, pager := newTestIncidentWatcher(start)
+	ctx := context.Background()
+
+	w.health.recordPoll(errors.New("401 Bad credentials"))
+	w.check(ctx, start.Add(45*time.Minute))
+	if len(pager.events) != 1 || !strings.Contains(pager.events[0], "no successful GitHub poll for 45m0s, so no runners are being created; last error: 401 Bad credentials") {
+		t.Fatalf("events = %q, want a trigger for the stalled listener", pager.events)
+	}
+
+	w.health.now = func() time.Time { return start.Add(46 * time.Minute) }
+	w.health.recordPoll(nil)
+	w.check(ctx, start.Add(46*time.Minute))
+	if len(pager.events) != 2 || pager.events[1] != "resolve no_vms" {
+		t.Fatalf("events = %q, want a resolve after a successful poll", pager.events)
+	}
+}
+
+func TestScaleUpTracksCreateStall(t *testing.T) {
+	s := &gcpRunnerScaler{
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager: idleProvider{},
+		stall:     &createStall{},
+	}
+	s.stall.failed(errors.New("stockout"), time.Now())
+	if _, err := s.HandleDesiredRunnerCount(context.Background(), 0); err != nil {
+		t.Fatalf("HandleDesiredRunnerCount: %v", err)
+	}
+	if since, _ := s.stall.since(); !since.IsZero() {
+		t.Fatal("a scaler at target should clear the stall")
+	}
+}
+
+func TestLoadConfigIncident(t *testing.T) {
+	t.Setenv("SCALER_INCIDENT_KEY", "")
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--incident-provider=pagerduty"); err == nil {
+		t.Fatal("loadConfig should require an incident key")
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--incident-provider=victorops", "--incident-key=k"); err == nil {
+		t.Fatal("loadConfig should reject an unknown incident provider")
+	}
+	t.Setenv("SCALER_INCIDENT_KEY", "k")
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--incident-provider=opsgenie")
+	if err != nil || cfg.incidentKey != "k" {
+		t.Fatalf("loadConfig = %q, %v; want the key from the environment", cfg.incidentKey, err)
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// initSettings are the answers `scaler init` builds its files from.
+type initSettings struct {
+	url, name, platform, labels       string
+	project, zones, template, gpuType string
+	maxRunners, minRunners            int
+	tokenSecret                       string
+	metrics, manageFirewall           bool
+	hostServiceAccount                string
+}
+
+// initPrompts are the settings `scaler init` asks for, in order, when they
+// were not given as flags.
+var initPrompts = []struct{ flag, question string }{
+	{"url", "GitHub URL of the repository or organization"},
+	{"platform", "Runner platform (linux or windows)"},
+	{"name", "Scale set name"},
+	{"labels", "Runner labels"},
+	{"gcp-project", "GCP project"},
+	{"gcp-zones", "GCP zones, in preference order"},
+	{"gcp-instance-template", "Instance template"},
+	{"max-runners", "Maximum runners"},
+	{"min-runners", "Runners to keep warm"},
+}
+
+var unitNameRE = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// runInit implements `scaler init [flags]`. It writes a starter config file,
+// a systemd unit running the scaler with it and a script granting the
+// scaler host's service account the IAM roles the config needs, asking on a
+// terminal for the settings not given as flags.
+func runInit(_ context.Context, args []string, out io.Writer) error {
+	return initPool(args, os.Stdin, isTerminal(os.Stdin), out)
+}
+
+// initOptions are the flags of `scaler init`.
+type initOptions struct {
+	initSettings
+	dir             string
+	force, noPrompt bool
+}
+
+func (o *initOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.url, "url", "", "GitHub URL of the repository or organization the runners serve")
+	fs.StringVar(&o.platform, "platform", "linux", "Runner platform: linux or windows")
+	fs.StringVar(&o.name, "name", "", "Scale set name, also naming the files (default PLATFORM-gpu-runners)")
+	fs.StringVar(&o.labels, "labels", "", "Comma-separated runner labels (default Linux,self-hosted,GCP or Windows,self-hosted,GCP)")
+	fs.StringVar(&o.project, "gcp-project", "", "GCP project the runner VMs are created in")
+	fs.StringVar(&o.zones, "gcp-zones", "us-east1-c,us-east1-d,us-central1-a,us-west1-a", "Comma-separated zones in preference order")
+	fs.StringVar(&o.template, "gcp-instance-template", "", "Instance template of the runner VMs (default PLATFORM-gpu-runner)")
+	fs.StringVar(&o.gpuType, "gcp-gpu-type", "nvidia-tesla-t4", "GPU accelerator type of the instance template")
+	fs.IntVar(&o.maxRunners, "max-runners", 5, "Maximum concurrent runners")
+	fs.IntVar(&o.minRunners, "min-runners", 0, "Minimum runners to keep warm")
+	fs.StringVar(&o.tokenSecret, "token-secret", "", "Secret Manager secret holding the GitHub PAT, e.g. projects/p/secrets/s (default: credentials from /opt/scaler/scaler.env)")
+	fs.BoolVar(&o.metrics, "metrics", false, "Write Cloud Monitoring metrics to --gcp-project")
+	fs.BoolVar(&o.manageFirewall, "manage-firewall", false, "Have the scaler create firewall rules limiting the VMs' egress")
+	fs.StringVar(&o.hostServiceAccount, "host-service-account", "", "Email of the scaler host's service account for the IAM script (default: $SCALER_SA when the script runs)")
+	fs.StringVar(&o.dir, "dir", ".", "Directory to write the files to")
+	fs.BoolVar(&o.force, "force", false, "Overwrite existing files")
+	fs.BoolVar(&o.noPrompt, "no-prompt", false, "Do not ask for the settings not given as flags")
+}
+
+func initPool(args []string, in io.Reader, interactive bool, out io.Writer) error {
+	var o initOptions
+	fs := flag.NewFlagSet("scaler init", flag.ContinueOnError)
+	o.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	s := &o.initSettings
+	if interactive && !o.noPrompt {
+		if err := promptInit(fs, s, in, out); err != nil {
+			return err
+		}
+	}
+	s.fillDefaults()
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	files := []struct {
+		name string
+		data []byte
+		mode os.FileMode
+	}{
+		{s.name + ".yaml", s.configFile(), 0o644},
+		{"scaler-" + s.name + ".service", s.unitFile(), 0o644},
+		{s.name + "-iam.sh", s.iamScript(), 0o755},
+	}
+	if !o.force {
+		for _, f := range files {
+			if _, err := os.Stat(filepath.Join(o.dir, f.name)); err == nil {
+				return fmt.Errorf("%s already exists (--force overwrites it)", filepath.Join(o.dir, f.name))
+			}
+		}
+	}
+	for _, f := range files {
+		path := filepath.Join(o.dir, f.name)
+		if err := os.WriteFile(path, f.data, f.mode); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "wrote %s\n", path)
+	}
+
+	fmt.Fprintf(out, "\nThe scaler host's service account needs these roles in %s:\n", s.project)
+	for _, r := range s.iamRoles() {
+		fmt.Fprintf(out, "  %-36s %s\n", r.role, r.reason)
+	}
+	fmt.Fprintf(out, "\nNext steps:\n")
+	if s.hostServiceAccount == "" {
+		fmt.Fprintf(out, "  SCALER_SA=SERVICE_ACCOUNT_EMAIL sh %s\n", filepath.Join(o.dir, files[2].name))
+	} else {
+		fmt.Fprintf(out, "  sh %s\n", filepath.Join(o.dir, files[2].name))
+	}
+	fmt.Fprintf(out, "  sudo cp %s /opt/scaler/\n", filepath.Join(o.dir, files[0].name))
+	fmt.Fprintf(out, "  sudo cp %s /etc/systemd/system/\n", filepath.Join(o.dir, files[1].name))
+	if s.tokenSecret == "" {
+		fmt.Fprintf(out, "  add the GitHub credentials to /opt/scaler/scaler.env (see deploy/scaler.env.example)\n")
+	}
+	fmt.Fprintf(out, "  /opt/scaler/scaler validate --config=/opt/scaler/%s.yaml\n", s.name)
+	fmt.Fprintf(out, "  sudo systemctl enable --now scaler-%s\n", s.name)
+	return nil
+}
+
+// promptInit asks for each of initPrompts not set on the command line,
+// offering its current value. An empty answer keeps it; the end of the
+// input keeps the rest.
+func promptInit(fs *flag.FlagSet, s *initSettings, in io.Reader, out io.Writer) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	r := bufio.NewReader(in)
+	for _, p := range initPrompts {
+		if explicit[p.flag] {
+			continue
+		}
+		for {
+			def := fs.Lookup(p.flag).Value.String()
+			if def == "" {
+				// Named after the platform answered above.
+				def = s.platformDefault(p.flag)
+			}
+			if def == "" {
+				fmt.Fprintf(out, "%s: ", p.question)
+			} else {
+				fmt.Fprintf(out, "%s [%s]: ", p.question, def)
+			}
+			line, err := r.ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			if answer := strings.TrimSpace(line); answer != "" {
+				if setErr := fs.Set(p.flag, answer); setErr != nil {
+					if err != nil {
+						return fmt.Errorf("--%s: invalid value %q", p.flag, answer)
+					}
+					fmt.Fprintf(out, "invalid value %q\n", answer)
+					continue
+				}
+			}
+			if err != nil {
+				fmt.Fprintln(out)
+				return nil
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// platformDefault is the default of a setting named after the platform,
+// or "" for the others.
+func (s *initSettings) platformDefault(flag string) string {
+	switch flag {
+	case "name":
+		return s.platform + "-gpu-runners"
+	case "labels":
+		return upperFirst(s.platform) + ",self-hosted,GCP"
+	case "gcp-instance-template":
+		return s.platform + "-gpu-runner"
+	}
+	return ""
+}
+
+func (s *initSettings) fillDefaults() {
+	for flag, v := range map[string]*string{"name": &s.name, "labels": &s.labels, "gcp-instance-template": &s.template} {
+		if *v == "" {
+			*v = s.platformDefault(flag)
+		}
+	}
+}
+
+func (s *initSettings) validate() error {
+	switch {
+	case s.url == "":
+		return errors.New("--url is required")
+	case !strings.HasPrefix(s.url, "https://"):
+		return fmt.Errorf("--url %q must be an https:// URL", s.url)
+	case s.platform != "linux" && s.platform != "windows":
+		return fmt.Errorf("--platform %q must be linux or windows", s.platform)
+	case !unitNameRE.MatchString(s.name):
+		return fmt.Errorf("--name %q must be lowercase letters, digits and dashes, as it names the systemd unit", s.name)
+	case s.project == "":
+		return errors.New("--gcp-project is required")
+	case s.maxRunners < 1:
+		return errors.New("--max-runners must be at least 1")
+	case s.minRunners < 0 || s.minRunners > s.maxRunners:
+		return fmt.Errorf("--min-runners must be between 0 and --max-runners (%d)", s.maxRunners)
+	}
+	return nil
+}
+
+// configFile is the scaler's --config file for s.
+func (s *initSettings) configFile() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Generated by `scaler init`. Keys are scaler flags without the dashes;\n")
+	fmt.Fprintf(&b, "# see `scaler -h` or `scaler docs man` for the others.\n")
+	fmt.Fprintf(&b, "url: %s\n", yamlScalar(s.url))
+	fmt.Fprintf(&b, "name: %s\n", yamlScalar(s.name))
+	fmt.Fprintf(&b, "labels: %s\n", yamlList(s.labels))
+	fmt.Fprintf(&b, "max-runners: %d\n", s.maxRunners)
+	fmt.Fprintf(&b, "min-runners: %d\n", s.minRunners)
+	fmt.Fprintf(&b, "platform: %s\n", s.platform)
+	fmt.Fprintf(&b, "gcp-project: %s\n", yamlScalar(s.project))
+	fmt.Fprintf(&b, "gcp-zones: %s\n", yamlList(s.zones))
+	fmt.Fprintf(&b, "gcp-instance-template: %s\n", yamlScalar(s.template))
+	fmt.Fprintf(&b, "gcp-gpu-type: %s\n", yamlScalar(s.gpuType))
+	fmt.Fprintf(&b, "session-max-age: 2h\n")
+	fmt.Fprintf(&b, "state-file: /var/lib/scaler-%s/vms.json\n", s.name)
+	if s.tokenSecret != "" {
+		fmt.Fprintf(&b, "token-secret: %s\n", yamlScalar(s.tokenSecret))
+	}
+	if s.metrics {
+		fmt.Fprintf(&b, "metrics-project: %s\n", yamlScalar(s.project))
+	}
+	if s.manageFirewall {
+		fmt.Fprintf(&b, "manage-firewall: true\n")
+	}
+	return b.Bytes()
+}
+
+// unitFile is a systemd unit running the scaler with the config file
+// installed in /opt/scaler, like the units in deploy/.
+func (s *initSettings) unitFile() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `[Unit]
+Description=GCP Runner Scaler (%s)
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+User=scaler
+Group=scaler
+RuntimeDirectory=%%N
+StateDirectory=%%N
+
+ExecReload=/bin/kill -USR1 $MAINPID
+ExecStart=/opt/scaler/scaler \
+    --config=/opt/scaler/%s.yaml \
+    --control-socket=/run/%%N/scaler.sock
+
+EnvironmentFile=/opt/scaler/scaler.env
+
+Restart=always
+RestartSec=10
+
+# Logging
+StandardOutput=journal
+StandardError=journal
+SyslogIdentifier=scaler-%s
+
+# Security hardening
+NoNewPrivileges=true
+ProtectSystem=strict
+ProtectHome=true
+ReadOnlyPaths=/opt/scaler
+
+[Install]
+WantedBy=multi-user.target
+`, s.name, s.name, s.name)
+	return b.Bytes()
+}
+
+type iamRole struct {
+	role, reason string
+	// resource, when set, is the secret the role is granted on rather
+	// than the project.
+	resource string
+}
+
+// iamRoles are the roles the scaler host's service account needs for the
+// config s.
+func (s *initSettings) iamRoles() []iamRole {
+	roles := []iamRole{
+		{role: "roles/compute.instanceAdmin.v1", reason: "create, stop and delete the runner VMs, and read GPU quota"},
+		{role: "roles/iam.serviceAccountUser", reason: "attach the instance template's service account to the VMs"},
+	}
+	if s.tokenSecret != "" {
+		secret, _, _ := strings.Cut(s.tokenSecret, "/versions/")
+		roles = append(roles, iamRole{role: "roles/secretmanager.secretAccessor", reason: "read the GitHub PAT from " + secret, resource: secret})
+	}
+	if s.metrics {
+		roles = append(roles, iamRole{role: "roles/monitoring.metricWriter", reason: "write the runner count metrics"})
+	}
+	if s.manageFirewall {
+		roles = append(roles, iamRole{role: "roles/compute.securityAdmin", reason: "create and delete the VMs' firewall rules"})
+	}
+	return roles
+}
+
+// iamScript grants iamRoles with gcloud.
+func (s *initSettings) iamScript() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "#!/bin/sh\n")
+	fmt.Fprintf(&b, "# Generated by `scaler init`: grants the scaler host's service account the\n")
+	fmt.Fprintf(&b, "# IAM roles the %s pool needs in %s.\n", s.name, s.project)
+	fmt.Fprintf(&b, "set -eu\n\n")
+	if s.hostServiceAccount != "" {
+		fmt.Fprintf(&b, "SCALER_SA=\"${SCALER_SA:-%s}\"\n", s.hostServiceAccount)
+	} else {
+		fmt.Fprintf(&b, ": \"${SCALER_SA:?set SCALER_SA to the scaler host's service account email}\"\n")
+	}
+	fmt.Fprintf(&b, "PROJECT=%s\n", s.project)
+	for _, r := range s.iamRoles() {
+		fmt.Fprintf(&b, "\n# %s\n", upperFirst(r.reason))
+		if r.resource != "" {
+			fmt.Fprintf(&b, "gcloud secrets add-iam-policy-binding %s \\\n", r.resource)
+		} else {
+			fmt.Fprintf(&b, "gcloud projects add-iam-policy-binding \"$PROJECT\" \\\n")
+		}
+		fmt.Fprintf(&b, "  --member=\"serviceAccount:$SCALER_SA\" --role=%s --condition=None >/dev/null\n", r.role)
+	}
+	return b.Bytes()
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// yamlScalar quotes s where YAML would otherwise read it as something else.
+func yamlScalar(s string) string {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Sprintf("%q", s)
+	}
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+// yamlList writes a comma-separated flag value as a flow sequence.
+func yamlList(s string) string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, yamlScalar(item))
+		}
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe, a file or
+// /dev/null, which is a character device too.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(fi, null)
+}
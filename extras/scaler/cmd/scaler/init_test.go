@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitWritesFiles(t *testing.T) {
+	dir := t.TempDir()
+	args := []string{"--dir=" + dir, "--url=https://github.com/o/r", "--gcp-project=ci-runners", "--platform=windows",
+		"--max-runners=8", "--token-secret=projects/ci-runners/secrets/gh/versions/2", "--metrics"}
+	var out bytes.Buffer
+	if err := initPool(args, strings.NewReader(""), false, &out); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	// The config is one the scaler accepts.
+	fs := serviceFlags()
+	if err := applyConfigFile(fs, filepath.Join(dir, "windows-gpu-runners.yaml")); err != nil {
+		t.Fatalf("generated config: %v", err)
+	}
+	for name, want := range map[string]string{
+		"url":          "https://github.com/o/r",
+		"name":         "windows-gpu-runners",
+		"labels":       "Windows,self-hosted,GCP",
+		"max-runners":  "8",
+		"gcp-zones":    "us-east1-c,us-east1-d,us-central1-a,us-west1-a",
+		"token-secret": "projects/ci-runners/secrets/gh/versions/2",
+	} {
+		if got := fs.Lookup(name).Value.String(); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	unit, err := os.ReadFile(filepath.Join(dir, "scaler-windows-gpu-runners.service"))
+	if err != nil || !strings.Contains(string(unit), "--config=/opt/scaler/windows-gpu-runners.yaml") {
+		t.Fatalf("unit = %s, %v; want it to run the config", unit, err)
+	}
+	script, err := os.ReadFile(filepath.Join(dir, "windows-gpu-runners-iam.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"--role=roles/compute.instanceAdmin.v1", "--role=roles/monitoring.metricWriter",
+		"gcloud secrets add-iam-policy-binding projects/ci-runners/secrets/gh \\"} {
+		if !strings.Contains(string(script), want) {
+			t.Errorf("IAM script missing %q:\n%s", want, script)
+		}
+	}
+	if strings.Contains(string(script), "compute.securityAdmin") {
+		t.Error("IAM script grants firewall roles without --manage-firewall")
+	}
+
+	if err := initPool(args, strings.NewReader(""), false, &out); err == nil {
+		t.Fatal("init overwrote its files without --force")
+	}
+	if err := initPool(append(args, "--force"), strings.NewReader(""), false, &out); err != nil {
+		t.Fatalf("init --force: %v", err)
+	}
+}
+
+func TestInitPrompts(t *testing.T) {
+	dir := t.TempDir()
+	// url, platform, name, labels, project, zones, template, an invalid and
+	// a valid max, min.
+	in := "https://github.com/o/r\nwindows\n\n\nci-runners\n\nwin-template\nmany\n8\n2\n"
+	var out bytes.Buffer
+	if err := initPool([]string{"--dir=" + dir, "--min-runners=1"}, strings.NewReader(in), true, &out); err != nil {
+		t.Fatalf("init: %v\n%s", err, out.String())
+	}
+	for _, want := range []string{"Scale set name [windows-gpu-runners]: ", `invalid value "many"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "Runners to keep warm") {
+		t.Error("asked for --min-runners given as a flag")
+	}
+
+	fs := serviceFlags()
+	if err := applyConfigFile(fs, filepath.Join(dir, "windows-gpu-runners.yaml")); err != nil {
+		t.Fatalf("generated config: %v", err)
+	}
+	for name, want := range map[string]string{"gcp-project": "ci-runners", "gcp-instance-template": "win-template", "max-runners": "8", "min-runners": "1"} {
+		if got := fs.Lookup(name).Value.String(); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestInitRejectsBadSettings(t *testing.T) {
+	for name, args := range map[string][]string{
+		"no url":         {"--gcp-project=p"},
+		"no project":     {"--url=https://github.com/o/r"},
+		"darwin":         {"--url=https://github.com/o/r", "--gcp-project=p", "--platform=darwin"},
+		"unit name":      {"--url=https://github.com/o/r", "--gcp-project=p", "--name=GPU Pool"},
+		"min above max":  {"--url=https://github.com/o/r", "--gcp-project=p", "--max-runners=2", "--min-runners=3"},
+		"extra argument": {"--url=https://github.com/o/r", "--gcp-project=p", "pool"},
+	} {
+		dir := t.TempDir()
+		if err := initPool(append(args, "--dir="+dir), strings.NewReader(""), false, new(bytes.Buffer)); err == nil {
+			t.Errorf("%s: init succeeded", name)
+		}
+		if files, _ := os.ReadDir(dir); len(files) > 0 {
+			t.Errorf("%s: init wrote %d files", name, len(files))
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/github"
+	"extras/scaler/internal/supervise"
+)
+
+// decisionJobCost marks the audit log line of a completed job's cost.
+const decisionJobCost = "job_cost"
+
+// jobCost is the estimated cost of the VM that ran one job: its hourly
+// price times its uptime, from its creation, or its reuse for the job's
+// runner, to the job's completion. Boot and idle time count towards the
+// job, as they are billed the same. A VM running several runners, with
+// --runner-slots, splits its price evenly between their jobs.
+type jobCost struct {
+	vm       string
+	location string
+	uptime   time.Duration
+	hourly   float64
+	usd      float64
+}
+
+// jobCostAudit is the --audit-log line of a completed job with --job-costs.
+type jobCostAudit struct {
+	Time          time.Time `json:"time"`
+	Decision      string    `json:"decision"`
+	Pool          string    `json:"pool"`
+	Repository    string    `json:"repository"`
+	Workflow      string    `json:"workflow,omitempty"`
+	Job           string    `json:"job"`
+	WorkflowRun   int64     `json:"workflow_run"`
+	Result        string    `json:"result"`
+	Runner        string    `json:"runner"`
+	VM            string    `json:"vm"`
+	Location      string    `json:"location,omitempty"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	HourlyUSD     float64   `json:"hourly_usd"`
+	CostUSD       float64   `json:"cost_usd"`
+}
+
+// jobCosts adds up the estimated cost of the jobs completed since the
+// scaler started, per repository, for the Cloud Monitoring metrics. A nil
+// *jobCosts records nothing.
+type jobCosts struct {
+	mu     sync.Mutex
+	byRepo map[string]float64
+}
+
+func newJobCosts() *jobCosts {
+	return &jobCosts{byRepo: make(map[string]float64)}
+}
+
+func (c *jobCosts) add(repo string, usd float64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.byRepo[repo] += usd
+	c.mu.Unlock()
+}
+
+// snapshot returns a copy of the costs per repository, or nil.
+func (c *jobCosts) snapshot() map[string]float64 {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.byRepo)
+}
+
+// jobCost estimates the cost of runnerName's share of its VM up to now. ok
+// is false when the VM is not tracked or its price is unknown.
+func (s *gcpRunnerScaler) jobCost(runnerName string, now time.Time) (cost jobCost, ok bool) {
+	vms := s.vmManager.VMs()
+	for _, vm := range vms {
+		if vm.RunnerName != runnerName {
+			continue
+		}
+		start := vm.CreatedAt
+		if !vm.ReusedAt.IsZero() {
+			start = vm.ReusedAt
+		}
+		// Providers report a VM's price for one of its runners only.
+		hourly, slots := vm.HourlyCost, 1
+		if vm.Name != "" {
+			hourly, slots = 0, 0
+			for _, other := range vms {
+				if other.Name == vm.Name {
+					hourly += other.HourlyCost
+					slots++
+				}
+			}
+		}
+		hourly /= float64(slots)
+		if hourly == 0 || start.IsZero() {
+			return jobCost{}, false
+		}
+		uptime := now.Sub(start)
+		return jobCost{
+			vm:       vm.Name,
+			location: vm.Location,
+			uptime:   uptime,
+			hourly:   hourly,
+			usd:      hourly * uptime.Hours(),
+		}, true
+	}
+	return jobCost{}, false
+}
+
+// recordJobCost estimates the cost of job, which just completed, and
+// records it in the audit log, the per-repository totals and, with
+// --annotate-jobs, a check run on the job's commit. It must run before the
+// job's VM is released.
+func (s *gcpRunnerScaler) recordJobCost(ctx context.Context, job *scaleset.JobCompleted, now time.Time) {
+	if s.jobCosts == nil {
+		return
+	}
+	cost, ok := s.jobCost(job.RunnerName, now)
+	if !ok {
+		return
+	}
+	repo := job.OwnerName + "/" + job.RepositoryName
+	s.jobCosts.add(repo, cost.usd)
+	err := s.auditLog.Write(jobCostAudit{
+		Time:          now,
+		Decision:      decisionJobCost,
+		Pool:          s.vmPrefix,
+		Repository:    repo,
+		Workflow:      workflowFile(job.JobWorkflowRef),
+		Job:           job.JobDisplayName,
+		WorkflowRun:   job.WorkflowRunID,
+		Result:        job.Result,
+		Runner:        job.RunnerName,
+		VM:            cost.vm,
+		Location:      cost.location,
+		UptimeSeconds: int64(cost.uptime.Seconds()),
+		HourlyUSD:     cost.hourly,
+		CostUSD:       cost.usd,
+	})
+	if err != nil {
+		s.logger.Warn("failed to write audit log", "error", err)
+	}
+	if s.annotator != nil {
+		go s.annotateJobCost(context.WithoutCancel(ctx), job, cost)
+	}
+}
+
+// workflowFile returns the file name of the workflow ref
+// "<owner>/<repo>/.github/workflows/<file>@<ref>", or "" for none.
+func workflowFile(ref string) string {
+	file, _, _ := strings.Cut(ref, "@")
+	if file == "" {
+		return ""
+	}
+	return path.Base(file)
+}
+
+// annotateJobCost records cost on job's workflow run. Failures are logged;
+// annotations are best effort.
+func (s *gcpRunnerScaler) annotateJobCost(ctx context.Context, job *scaleset.JobCompleted, cost jobCost) {
+	defer supervise.Recover("annotate_job_cost")
+	ctx, cancel := context.WithTimeout(ctx, annotateTimeout)
+	defer cancel()
+	if err := s.annotator.annotateCost(ctx, job, cost); err != nil {
+		s.annotator.logger.Warn("failed to annotate job cost", "runner", job.RunnerName, "job", job.JobDisplayName, "error", err)
+	}
+}
+
+func (a *jobAnnotator) annotateCost(ctx context.Context, job *scaleset.JobCompleted, cost jobCost) error {
+	sha, err := a.client.WorkflowRunHeadSHA(ctx, job.OwnerName, job.RepositoryName, job.WorkflowRunID)
+	if err != nil {
+		return fmt.Errorf("reading workflow run %d: %w", job.WorkflowRunID, err)
+	}
+	return a.client.CreateCheckRun(ctx, job.OwnerName, job.RepositoryName, github.CheckRun{
+		Name:       "runner cost: " + job.JobDisplayName,
+		HeadSHA:    sha,
+		Conclusion: "neutral",
+		Title:      fmt.Sprintf("$%.2f on %s", cost.usd, cost.vm),
+		Summary:    jobCostSummary(job, cost),
+	})
+}
+
+// jobCostSummary renders cost as the check run's Markdown summary.
+func jobCostSummary(job *scaleset.JobCompleted, cost jobCost) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Job **%s** (run %d) cost an estimated **$%.2f**:\n\n", job.JobDisplayName, job.WorkflowRunID, cost.usd)
+	b.WriteString("| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| VM | `%s` |\n", cost.vm)
+	if cost.location != "" {
+		fmt.Fprintf(&b, "| Zone | `%s` |\n", cost.location)
+	}
+	fmt.Fprintf(&b, "| VM uptime | `%s` |\n", cost.uptime.Round(time.Second))
+	fmt.Fprintf(&b, "| Hourly rate | `$%.4f` |\n", cost.hourly)
+	b.WriteString("\nThe estimate uses list prices and includes the VM's boot and idle time.\n")
+	return b.String()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/audit"
+	"extras/scaler/internal/vmstate"
+)
+
+func testCompletedJob(runnerName string) *scaleset.JobCompleted {
+	job := &scaleset.JobCompleted{RunnerName: runnerName, Result: "succeeded"}
+	job.OwnerName, job.RepositoryName = "shader-slang", "slang"
+	job.WorkflowRunID = 4242
+	job.JobWorkflowRef = "shader-slang/slang/.github/workflows/ci.yml@refs/heads/master"
+	job.JobDisplayName = "test (windows, gpu)"
+	return job
+}
+
+func TestRecordJobCostAuditsAndTotalsPerRepository(t *testing.T) {
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := audit.Open(path, 1<<20, 1)
+	if err != nil {
+		t.Fatalf("audit.Open: %v", err)
+	}
+	defer auditLog.Close()
+	s := &gcpRunnerScaler{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager: &fakeAdminProvider{vms: []vmstate.VM{
+			{RunnerName: "win-1", Name: "win-1", Location: "us-east1-c", CreatedAt: now.Add(-2 * time.Hour), HourlyCost: 1.5},
+			{RunnerName: "win-2", Name: "win-2", CreatedAt: now.Add(-5 * time.Hour), ReusedAt: now.Add(-30 * time.Minute), HourlyCost: 1},
+			{RunnerName: "win-free", Name: "win-free", CreatedAt: now.Add(-time.Hour)},
+		}},
+		vmPrefix: "win",
+		auditLog: auditLog,
+		jobCosts: newJobCosts(),
+	}
+
+	s.recordJobCost(context.Background(), testCompletedJob("win-1"), now)
+	// A reused VM's cost counts from its reuse for the runner.
+	s.recordJobCost(context.Background(), testCompletedJob("win-2"), now)
+	// VMs without a price and unknown runners cost nothing.
+	s.recordJobCost(context.Background(), testCompletedJob("win-free"), now)
+	s.recordJobCost(context.Background(), testCompletedJob("win-gone"), now)
+
+	if got := s.jobCosts.snapshot()["shader-slang/slang"]; math.Abs(got-3.5) > 1e-9 {
+		t.Fatalf("repository cost = %v, want 3.5", got)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("audit log has %d lines, want 2:\n%s", len(lines), data)
+	}
+	var rec jobCostAudit
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Decision != "job_cost" || rec.Pool != "win" || rec.Workflow != "ci.yml" || rec.Repository != "shader-slang/slang" || rec.VM != "win-1" || rec.UptimeSeconds != 7200 || rec.CostUSD != 3 {
+		t.Fatalf("audit record = %+v", rec)
+	}
+}
+
+func TestJobCostSplitsSlotsOfAVM(t *testing.T) {
+	now := time.Now()
+	s := &gcpRunnerScaler{vmManager: &fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-1", Name: "win-vm", CreatedAt: now.Add(-time.Hour), HourlyCost: 2},
+		{RunnerName: "win-2", Name: "win-vm", CreatedAt: now.Add(-time.Hour), ReusedAt: now.Add(-30 * time.Minute)},
+	}}}
+	for runner, want := range map[string]float64{"win-1": 1, "win-2": 0.5} {
+		cost, ok := s.jobCost(runner, now)
+		if !ok || cost.hourly != 1 || math.Abs(cost.usd-want) > 1e-9 {
+			t.Errorf("jobCost(%s) = %+v, %v; want $1/h and $%v", runner, cost, ok, want)
+		}
+	}
+}
+
+func TestAnnotateJobCostRecordsCost(t *testing.T) {
+	now := time.Now()
+	client := &fakeCheckRuns{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := &gcpRunnerScaler{
+		logger: logger,
+		vmManager: &fakeAdminProvider{vms: []vmstate.VM{
+			{RunnerName: "win-1", Name: "win-test-1", Location: "us-east1-c", CreatedAt: now.Add(-90 * time.Minute), HourlyCost: 2},
+		}},
+		annotator: &jobAnnotator{client: client, logger: logger},
+	}
+
+	cost, ok := s.jobCost("win-1", now)
+	if !ok {
+		t.Fatal("jobCost found no cost for a priced VM")
+	}
+	s.annotateJobCost(context.Background(), testCompletedJob("win-1"), cost)
+	if len(client.runs) != 1 {
+		t.Fatalf("check runs = %d, want 1", len(client.runs))
+	}
+	run := client.runs[0]
+	if run.Name != "runner cost: test (windows, gpu)" || run.Title != "$3.00 on win-test-1" {
+		t.Fatalf("check run = %+v", run)
+	}
+	for _, want := range []string{"| Zone | `us-east1-c` |", "| VM uptime | `1h30m0s` |", "| Hourly rate | `$2.0000` |"} {
+		if !strings.Contains(run.Summary, want) {
+			t.Errorf("summary missing %q:\n%s", want, run.Summary)
+		}
+	}
+}
+
+func TestLoadConfigJobCostsRequiresGCP(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--job-costs"); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--job-costs", "--provider=libvirt", "--platform=linux"); err == nil {
+		t.Fatal("loadConfig should reject --job-costs without --provider=gcp")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/histogram"
+)
+
+// latencyBuckets are the histogram bounds in seconds, fine-grained around
+// typical VM boots (2-5 min) and coarse out to queue waits of an hour.
+var latencyBuckets = []float64{30, 60, 90, 120, 150, 180, 240, 300, 450, 600, 900, 1800, 3600}
+
+// runnerLatency measures, per job, the runner's boot time (JIT config
+// generated until GitHub assigned it the job, which it only does once the
+// runner is online) and the job's queue time (queued until started), and
+// aggregates both into histograms. Boot time includes any idle time of warm
+// runners kept by --min-runners. A nil *runnerLatency records nothing.
+type runnerLatency struct {
+	boot  *histogram.Histogram
+	queue *histogram.Histogram
+
+	mu    sync.Mutex
+	jitAt map[string]time.Time
+}
+
+func newRunnerLatency() *runnerLatency {
+	return &runnerLatency{
+		boot:  histogram.New(latencyBuckets...),
+		queue: histogram.New(latencyBuckets...),
+		jitAt: make(map[string]time.Time),
+	}
+}
+
+// registered records when runnerName's JIT config was generated.
+func (l *runnerLatency) registered(runnerName string, at time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.jitAt[runnerName] = at
+	l.mu.Unlock()
+}
+
+// forget drops runnerName, e.g. after its VM failed to create.
+func (l *runnerLatency) forget(runnerName string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	delete(l.jitAt, runnerName)
+	l.mu.Unlock()
+}
+
+// jobStarted observes job's boot and queue times and returns them; either is
+// zero when unknown (the runner predates this scaler, or GitHub sent no
+// timestamp).
+func (l *runnerLatency) jobStarted(job *scaleset.JobStarted, now time.Time) (boot, queue time.Duration) {
+	if l == nil {
+		return 0, 0
+	}
+	l.mu.Lock()
+	jitAt, ok := l.jitAt[job.RunnerName]
+	delete(l.jitAt, job.RunnerName)
+	l.mu.Unlock()
+
+	if ok {
+		assigned := job.RunnerAssignTime
+		if assigned.IsZero() {
+			assigned = now
+		}
+		boot = assigned.Sub(jitAt)
+		l.boot.Observe(boot.Seconds())
+	}
+	if !job.QueueTime.IsZero() {
+		queue = now.Sub(job.QueueTime)
+		l.queue.Observe(queue.Seconds())
+	}
+	return boot, queue
+}
+
+func (l *runnerLatency) size() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.jitAt)
+}
+
+// snapshots returns the boot and queue histograms.
+func (l *runnerLatency) snapshots() (boot, queue histogram.Snapshot) {
+	if l == nil {
+		return histogram.Snapshot{}, histogram.Snapshot{}
+	}
+	return l.boot.Snapshot(), l.queue.Snapshot()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/actions/scaleset"
+)
+
+func TestRunnerLatency(t *testing.T) {
+	l := newRunnerLatency()
+	jit := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	now := jit.Add(5 * time.Minute)
+	l.registered("win-1", jit)
+	l.registered("win-2", jit)
+	l.forget("win-2")
+
+	job := &scaleset.JobStarted{}
+	job.RunnerName = "win-1"
+	job.QueueTime = jit.Add(-30 * time.Second)
+	job.RunnerAssignTime = jit.Add(4 * time.Minute)
+	boot, queue := l.jobStarted(job, now)
+	if boot != 4*time.Minute || queue != 5*time.Minute+30*time.Second {
+		t.Fatalf("boot, queue = %s, %s; want 4m0s, 5m30s", boot, queue)
+	}
+
+	// Unknown runner and no GitHub timestamps: nothing to measure.
+	job = &scaleset.JobStarted{}
+	job.RunnerName = "win-2"
+	if boot, queue := l.jobStarted(job, now); boot != 0 || queue != 0 {
+		t.Fatalf("boot, queue = %s, %s; want zero for an unknown runner", boot, queue)
+	}
+
+	bootHist, queueHist := l.snapshots()
+	if bootHist.Count != 1 || bootHist.Mean != 240 || queueHist.Count != 1 || queueHist.Mean != 330 {
+		t.Fatalf("histograms = boot %+v, queue %+v", bootHist, queueHist)
+	}
+
+	var nilLatency *runnerLatency
+	nilLatency.registered("win-3", jit)
+	if boot, _ := nilLatency.jobStarted(job, now); boot != 0 {
+		t.Fatal("nil runnerLatency measured a boot")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// errLeadershipLost ends a leader that could not renew its --ha-lease; the
+// replica that takes the lease over runs the pool from then on.
+var errLeadershipLost = errors.New("lost the HA lease")
+
+const (
+	// minHALeaseTTL leaves time for a few renewals within one --ha-lease-ttl.
+	minHALeaseTTL = 3 * time.Second
+	// haSessionWait is how long a new leader retries creating its message
+	// session, while GitHub still holds the session of a leader that died.
+	haSessionWait  = 5 * time.Minute
+	haSessionRetry = 15 * time.Second
+)
+
+// leaseHolder is a lease on the pool shared by its replicas (see
+// internal/lease).
+type leaseHolder interface {
+	Acquire(ctx context.Context) (held bool, holder string, err error)
+	Release(ctx context.Context) error
+}
+
+// leaderElection runs one replica's side of --ha-lease: a standby waits
+// for the lease, and the leader renews it every ttl/3 until it exits.
+type leaderElection struct {
+	lease  leaseHolder
+	ttl    time.Duration
+	logger *slog.Logger
+	now    func() time.Time
+	// reached is when the lease was last read or written, in Unix
+	// nanoseconds, so the systemd watchdog can tell a standby that cannot
+	// reach Cloud Storage from a waiting one.
+	reached atomic.Int64
+}
+
+func (e *leaderElection) interval() time.Duration { return e.ttl / 3 }
+
+// wait blocks until the replica holds the lease, or ctx is done.
+func (e *leaderElection) wait(ctx context.Context) error {
+	lastHolder := ""
+	for {
+		held, holder, err := e.lease.Acquire(ctx)
+		switch {
+		case err != nil:
+			e.logger.Warn("failed to check the HA lease", "error", err)
+		case held:
+			e.reached.Store(e.now().UnixNano())
+			e.logger.Info("took the HA lease, becoming the leader")
+			return nil
+		default:
+			e.reached.Store(e.now().UnixNano())
+			if holder != lastHolder {
+				e.logger.Info("standing by while another replica holds the HA lease", "leader", holder)
+				lastHolder = holder
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(e.interval()):
+		}
+	}
+}
+
+// hold renews the lease until ctx is done. When the lease is taken over,
+// or cannot be renewed before it would expire, hold calls lost and returns,
+// so the replica stops scaling before another one starts.
+func (e *leaderElection) hold(ctx context.Context, lost func()) {
+	ticker := time.NewTicker(e.interval())
+	defer ticker.Stop()
+	renewed := e.now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		held, holder, err := e.lease.Acquire(ctx)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return
+		case err != nil:
+			e.logger.Warn("failed to renew the HA lease", "error", err)
+			// Give up a renewal interval before the lease expires.
+			if e.now().Sub(renewed) < e.ttl-e.interval() {
+				continue
+			}
+			e.logger.Error("could not renew the HA lease in time, stepping down")
+		case !held:
+			e.logger.Error("another replica took the HA lease, stepping down", "leader", holder)
+		default:
+			renewed = e.now()
+			e.reached.Store(renewed.UnixNano())
+			continue
+		}
+		lost()
+		return
+	}
+}
+
+// live reports whether Cloud Storage answered within the lease's TTL.
+func (e *leaderElection) live() bool {
+	return e.now().Sub(time.Unix(0, e.reached.Load())) < e.ttl
+}
+
+// release gives up the lease on exit, so the standby takes over at once.
+func (e *leaderElection) release(ctx context.Context) {
+	if err := e.lease.Release(ctx); err != nil {
+		e.logger.Warn("failed to release the HA lease", "error", err)
+	}
+}
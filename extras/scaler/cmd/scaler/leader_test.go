@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"extras/scaler/internal/vmstate"
+)
+
+// fakeLease answers each Acquire with the next of its results, repeating
+// the last one.
+type fakeLease struct {
+	mu       sync.Mutex
+	results  []leaseResult
+	acquires int
+	released bool
+}
+
+type leaseResult struct {
+	held   bool
+	holder string
+	err    error
+}
+
+func (l *fakeLease) Acquire(context.Context) (bool, string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r := l.results[min(l.acquires, len(l.results)-1)]
+	l.acquires++
+	return r.held, r.holder, r.err
+}
+
+func (l *fakeLease) Release(context.Context) error {
+	l.mu.Lock()
+	l.released = true
+	l.mu.Unlock()
+	return nil
+}
+
+func newTestElection(l *fakeLease) *leaderElection {
+	return &leaderElection{lease: l, ttl: 30 * time.Millisecond, logger: slog.New(slog.NewTextHandler(io.Discard, nil)), now: time.Now}
+}
+
+func TestLeaderElectionWaitsForTheLease(t *testing.T) {
+	l := &fakeLease{results: []leaseResult{
+		{holder: "scaler-a"},
+		{err: errors.New("503 Service Unavailable")},
+		{holder: "scaler-a"},
+		{held: true, holder: "scaler-b"},
+	}}
+	e := newTestElection(l)
+	if err := e.wait(context.Background()); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if l.acquires != 4 || !e.live() {
+		t.Fatalf("acquires = %d, live = %v; want 4 and live", l.acquires, e.live())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	standby := newTestElection(&fakeLease{results: []leaseResult{{holder: "scaler-b"}}})
+	if err := standby.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wait after cancel = %v", err)
+	}
+}
+
+func TestLeaderElectionStepsDown(t *testing.T) {
+	for name, results := range map[string][]leaseResult{
+		"taken over":   {{held: true}, {holder: "scaler-b"}},
+		"unreachable":  {{held: true}, {err: errors.New("dial tcp: i/o timeout")}},
+		"never renews": {{err: errors.New("500 Internal Server Error")}},
+	} {
+		e := newTestElection(&fakeLease{results: results})
+		lost := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			e.hold(context.Background(), func() { close(lost) })
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: hold did not step down", name)
+		}
+		select {
+		case <-lost:
+		default:
+			t.Errorf("%s: hold returned without calling lost", name)
+		}
+	}
+}
+
+func TestLeaderElectionHoldsUntilCanceled(t *testing.T) {
+	l := &fakeLease{results: []leaseResult{
+		{held: true},
+		// A blip shorter than the TTL is ridden out.
+		{err: errors.New("503 Service Unavailable")},
+		{held: true},
+	}}
+	e := newTestElection(l)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	e.hold(ctx, func() { t.Error("hold stepped down while renewing") })
+	if l.acquires < 3 {
+		t.Fatalf("acquires = %d, want renewals every ttl/3", l.acquires)
+	}
+	e.release(context.Background())
+	if !l.released {
+		t.Fatal("release did not release the lease")
+	}
+}
+
+func TestShutdownHandsOverVMs(t *testing.T) {
+	// budgetProvider panics on DeleteAll, so a handover must not call it.
+	p := &budgetProvider{fakeAdminProvider{vms: []vmstate.VM{{Name: "win-1"}}}}
+	s := &gcpRunnerScaler{logger: slog.New(slog.NewTextHandler(io.Discard, nil)), vmManager: p, handover: true}
+	s.shutdown(context.Background())
+}
+
+func TestHALeaseFlagIsValidated(t *testing.T) {
+	base := []string{"--url=https://github.com/o/r"}
+	if _, err := testLoadConfig(t, append(base, "--ha-lease=gs://slang-ci/leases/windows-gpu")...); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	for _, args := range [][]string{
+		{"--ha-lease=slang-ci/leases/windows-gpu"},
+		{"--ha-lease=gs://slang-ci/lease", "--ha-lease-ttl=1s"},
+		{"--ha-lease=gs://slang-ci/lease", "--provider=libvirt", "--platform=linux"},
+	} {
+		if _, err := testLoadConfig(t, append(base, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Lifecycle events are logged with an "event" key so log pipelines can filter
+// on a stable name instead of matching message text. Dashboards and alerts
+// depend on these names; add new ones rather than renaming. The providers
+// log eventVMDeleted, the GCP cleanup loop "vm_evicted", its preemption
+// watch "vm_preempted" and its stopped pool "vm_stopped", "vm_started",
+// "vm_suspended" and "vm_resumed", its zone circuit breakers
+// "zone_backoff", --adopt-vms eventVMAdopted, and a recovered panic
+// "panic", the same way.
+const (
+	eventScaleSetReady    = "scale_set_ready"
+	eventScaleUp          = "scale_up"
+	eventJITFailed        = "jit_config_failed"
+	eventVMCreated        = "vm_created"
+	eventVMCreateFailed   = "vm_create_failed"
+	eventVMDeleteFailed   = "vm_delete_failed"
+	eventJobStarted       = "job_started"
+	eventJobCompleted     = "job_completed"
+	eventRunnerRemoved    = "runner_removed"
+	eventVMReused         = "vm_reused"
+	eventBootTimeout      = "boot_timeout"
+	eventVMZombie         = "vm_zombie"
+	eventJobStuck         = "job_stuck"
+	eventGPUCheckFailed   = "gpu_check_failed"
+	eventDrainStarted     = "drain_started"
+	eventDrainComplete    = "drain_complete"
+	eventDrainTimeout     = "drain_timeout"
+	eventConfigChanged    = "config_changed"
+	eventBudgetExceeded   = "budget_exceeded"
+	eventIdleCostExceeded = "idle_cost_exceeded"
+	eventCreateBackoff    = "create_backoff"
+	eventReconcile        = "reconcile"
+	eventSessionLost      = "session_lost"
+	eventSessionResumed   = "session_resumed"
+	eventShutdown         = "shutdown"
+	eventRunnerRegistered = "runner_registered"
+	eventAdminAction      = "admin_action"
+	eventPauseEnded       = "pause_ended"
+	eventVMDeleted        = "vm_deleted"
+	eventVMAdopted        = "vm_adopted"
+
+	eventMinRunnersOverrideEnded = "min_runners_override_ended"
+)
+
+// logLevels are the levels SIGUSR2 cycles through, in order.
+var logLevels = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
+// newLogger returns a logger writing format ("text" or "json") to w at
+// level ("debug", "info", "warn" or "error"), and the variable holding that
+// level so it can be changed at runtime.
+func newLogger(w io.Writer, format, level string) (*slog.Logger, *slog.LevelVar, error) {
+	lvl := new(slog.LevelVar)
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, nil, fmt.Errorf("invalid --log-level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), lvl, nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), lvl, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid --log-format %q (want text or json)", format)
+	}
+}
+
+// newSinkLogger is newLogger for a sink that keeps each entry's level apart
+// from its text, like the Windows Event Log: it formats every record as
+// newLogger would and hands the line, without its newline, to sink.
+func newSinkLogger(sink func(level slog.Level, line string) error, format, level string) (*slog.Logger, *slog.LevelVar, error) {
+	w := &sinkWriter{sink: sink}
+	logger, lvl, err := newLogger(w, format, level)
+	if err != nil {
+		return nil, nil, err
+	}
+	return slog.New(&sinkHandler{Handler: logger.Handler(), w: w}), lvl, nil
+}
+
+// sinkWriter passes each line a handler writes to sink, with the level of
+// the record being written.
+type sinkWriter struct {
+	mu    sync.Mutex
+	level slog.Level
+	sink  func(level slog.Level, line string) error
+}
+
+func (w *sinkWriter) Write(p []byte) (int, error) {
+	if err := w.sink(w.level, strings.TrimSuffix(string(p), "\n")); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// sinkHandler sets the level of its sinkWriter for each record it handles.
+type sinkHandler struct {
+	slog.Handler
+	w *sinkWriter
+}
+
+func (h *sinkHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.w.mu.Lock()
+	defer h.w.mu.Unlock()
+	h.w.level = r.Level
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *sinkHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sinkHandler{Handler: h.Handler.WithAttrs(attrs), w: h.w}
+}
+
+func (h *sinkHandler) WithGroup(name string) slog.Handler {
+	return &sinkHandler{Handler: h.Handler.WithGroup(name), w: h.w}
+}
+
+// cycleLogLevel moves lvl to the next of logLevels, wrapping from error back
+// to debug, and returns the new level. A level between two of them moves to
+// the higher one.
+func cycleLogLevel(lvl *slog.LevelVar) slog.Level {
+	next := logLevels[0]
+	for _, l := range logLevels {
+		if l > lvl.Level() {
+			next = l
+			break
+		}
+	}
+	lvl.Set(next)
+	return next
+}
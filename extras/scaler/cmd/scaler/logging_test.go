@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _, err := newLogger(&buf, "json", "warn")
+	if err != nil {
+		t.Fatalf("newLogger: %v", err)
+	}
+	logger.Info("filtered out")
+	logger.Warn("job started", "event", eventJobStarted, "runner", "win-1")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log output %q is not one JSON object: %v", buf.String(), err)
+	}
+	if entry["event"] != "job_started" || entry["runner"] != "win-1" || entry["level"] != "WARN" {
+		t.Fatalf("entry = %v", entry)
+	}
+}
+
+func TestNewLoggerRejectsInvalidSettings(t *testing.T) {
+	for _, tc := range []struct{ format, level string }{
+		{"xml", "info"},
+		{"json", "verbose"},
+	} {
+		if _, _, err := newLogger(io.Discard, tc.format, tc.level); err == nil {
+			t.Errorf("newLogger(%q, %q) should fail", tc.format, tc.level)
+		}
+	}
+	if _, _, err := newLogger(io.Discard, "text", "DEBUG"); err != nil {
+		t.Errorf("level names should be case-insensitive: %v", err)
+	}
+}
+
+func TestLogLevelChangesAtRuntime(t *testing.T) {
+	var buf bytes.Buffer
+	logger, lvl, err := newLogger(&buf, "text", "info")
+	if err != nil {
+		t.Fatalf("newLogger: %v", err)
+	}
+	logger.Debug("hidden")
+	lvl.Set(slog.LevelDebug)
+	logger.Debug("zone selection")
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "zone selection") {
+		t.Fatalf("output = %q, want only the debug line logged after the change", out)
+	}
+
+	var got []slog.Level
+	for range 4 {
+		got = append(got, cycleLogLevel(lvl))
+	}
+	want := []slog.Level{slog.LevelInfo, slog.LevelWarn, slog.LevelError, slog.LevelDebug}
+	if !slices.Equal(got, want) {
+		t.Fatalf("cycled levels = %v, want %v", got, want)
+	}
+}
+
+func TestNewSinkLoggerKeepsLevels(t *testing.T) {
+	type entry struct {
+		level slog.Level
+		line  string
+	}
+	var entries []entry
+	logger, _, err := newSinkLogger(func(level slog.Level, line string) error {
+		entries = append(entries, entry{level, line})
+		return nil
+	}, "text", "info")
+	if err != nil {
+		t.Fatalf("newSinkLogger: %v", err)
+	}
+	logger.Debug("filtered out")
+	logger.With("pool", "win").WithGroup("gcp").Warn("zone cooling down", "zone", "us-east1-c")
+	logger.Error("scaler exited with error")
+
+	if len(entries) != 2 || entries[0].level != slog.LevelWarn || entries[1].level != slog.LevelError {
+		t.Fatalf("entries = %v, want a warning and an error", entries)
+	}
+	if line := entries[0].line; !strings.Contains(line, "pool=win gcp.zone=us-east1-c") || strings.HasSuffix(line, "\n") {
+		t.Fatalf("line = %q", line)
+	}
+}
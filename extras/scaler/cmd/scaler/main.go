@@ -3,7 +3,8 @@
 // A standalone service that auto-scales Windows GPU VMs on GCP based on
 // GitHub Actions job queue depth. Uses the GitHub Actions Scale Set Client
 // (github.com/actions/scaleset) to poll for queued jobs and GCP Compute API
-// to create/delete VMs.
+// to create/delete VMs. macOS pools (--platform=darwin) are served from a
+// MacStadium Orka cluster instead of GCP.
 //
 // Based on the dockerscaleset example from github.com/actions/scaleset,
 // replacing Docker containers with GCP Compute Engine VMs.
@@ -22,23 +23,46 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
+	"net/http"
 	"os"
 	"os/signal"
+	"path"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/actions/scaleset"
 	"github.com/actions/scaleset/listener"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"extras/scaler/internal/audit"
+	"extras/scaler/internal/breaker"
+	"extras/scaler/internal/cloudmetrics"
 	gcpvm "extras/scaler/internal/gcp"
+	"extras/scaler/internal/github"
+	"extras/scaler/internal/incident"
+	"extras/scaler/internal/lease"
+	"extras/scaler/internal/libvirt"
+	"extras/scaler/internal/notify"
+	"extras/scaler/internal/orka"
+	"extras/scaler/internal/secevents"
+	"extras/scaler/internal/startup"
+	"extras/scaler/internal/supervise"
+	"extras/scaler/internal/vmstate"
 )
 
 // errDrainComplete is returned when drain mode finishes and all VMs have
@@ -46,13 +70,22 @@ import (
 var errDrainComplete = errors.New("drain complete")
 
 type config struct {
+	configFile string // --config; re-read on SIGHUP
+	// showVersion is --version: print the build and exit.
+	showVersion bool
+
 	// GitHub configuration
 	registrationURL string // e.g. https://github.com/shader-slang/slang
 	scaleSetName    string
 	labels          string
+	userLabels      string
 	runnerGroup     string
 	maxRunners      int
 	minRunners      int
+	// minRunnersScheduleSpec is --min-runners-schedule, parsed into
+	// minRunnersSchedule.
+	minRunnersScheduleSpec string
+	minRunnersSchedule     minRunnersSchedule
 
 	// Authentication (GitHub App or PAT)
 	appClientID       string
@@ -60,30 +93,257 @@ type config struct {
 	appPrivateKey     string
 	token             string
 
+	// Secret Manager sources for token/appPrivateKey, polled for rotations.
+	tokenSecret           string
+	appPrivateKeySecret   string
+	secretRefreshInterval time.Duration
+
+	// Local file sources for token/appPrivateKey, polled like the secrets.
+	tokenFile         string
+	appPrivateKeyFile string
+	envFile           string
+
+	// Vault source for token/appPrivateKey (alternative to Secret Manager).
+	vaultAddr  string
+	vaultPath  string
+	vaultRole  string
+	vaultToken string // VAULT_TOKEN; never a flag
+
 	// GCP configuration
 	gcpProject          string
 	gcpZones            string
+	gcpRegions          string
 	gcpInstanceTemplate string
+	impersonateSA       string
+	jitConfigKMSKey     string
 	gcpGPUType          string
 	gcpPlatform         string
 	gcpVMPrefix         string
 	gcpCleanupInterval  time.Duration
+	gcpRetryAttempts    int
+	gcpRetryBackoff     time.Duration
+	gcpAPIRateLimit     float64
+	gcpQuotaCacheTTL    time.Duration
 	sessionMaxAge       time.Duration
+	drainTimeout        time.Duration
+	drainTimeoutAction  string
+	reconcileInterval   time.Duration
 	orphanGracePeriod   time.Duration
+	maxJobsPerVM        int
+	stoppedPoolSize     int
+	suspendedPoolSize   int
+	maxVMAge            time.Duration
+	reuseGrace          time.Duration
+	scaleUpCooldown     time.Duration
+	dailyBudgetUSD      float64
+	idleCostAlertUSD    float64
+	jobCosts            bool
+	billingLabels       bool
+	maxCreatesPerMinute int
+	maxCreatesPerHour   int
+	breakerThreshold    int
+	breakerBackoff      time.Duration
+	stockoutCooldown    time.Duration
+	preemptionWindow    time.Duration
+	stateFile           string
+	adoptVMs            bool
+	haLease             string
+	haLeaseTTL          time.Duration
+	deleteScaleSet      bool
+	fairShareDir        string
+	fairShareWeight     int
+	priorityLabelSpec   string
+	priorityLabels      []string
+	recyclePriority     bool
+	runnerQuotasSpec    string
+	runnerQuotas        map[string]int
+	headroomPolicySpec  string
+	headroomPolicy      *headroomPolicy
+	templateRoutes      string
+	routes              []gcpvm.TemplateRoute
+	sizeLabelSpec       string
+	sizeLabels          []string
+	vmMetadata          string
+	vmLabels            string
+	metadata            map[string]string
+	gcpLabels           map[string]string
+	bootDiskSizeGB      int64
+	bootDiskType        string
+	imageFamily         string
+	gpuDriverVersion    string
+	gpuDriverURL        string
+	gpuDriverSHA256     string
+	cacheDiskSpec       string
+	cacheDisks          map[string]int
+	cacheDiskSizeGB     int64
+	cacheDiskType       string
+	reservationSpec     string
+	reservations        map[string]string
+	localSSDs           int
+	migProfile          string
+	runnerSlots         int
+	network             string
+	subnetwork          string
+	networkTags         string
+	noExternalIP        bool
+	orgPolicyCheck      bool
+	allowedMachineTypes string
+	manageFirewall      bool
+	serviceAccount      string
+	serviceAccountScope string
+	startupScript       string
+	otlpEndpoint        string
+	httpAddr            string
+	adminToken          string
+	controlSocket       string
+	notifyWebhook       string
+	notifyInterval      time.Duration
+	notifyStuckBoot     time.Duration
+	bootTimeout         time.Duration
+	runnerSweepInterval time.Duration
+	zombieTimeout       time.Duration
+	maxJobDuration      time.Duration
+	recycleStuckJobs    bool
+	gpuCheck            bool
+	bootPhases          bool
+	shutdownScript      bool
+	replacePreempted    bool
+	incidentProvider    string
+	incidentKey         string
+	incidentAfter       time.Duration
+	annotateJobs        bool
+	metricsProject      string
+	auditLog            string
+	securityEvents      string
+	auditLogMaxSizeMB   int
+	auditLogMaxFiles    int
+	logFormat           string
+	logLevel            string
+	eventHistory        int
+	metricsInterval     time.Duration
+	healthMaxPollAge    time.Duration
+
+	// VM provider: "gcp" for Windows/Linux pools, "orka" for macOS pools.
+	provider      string
+	orkaURL       string
+	orkaToken     string
+	orkaNamespace string
+	orkaImage     string
+	orkaCPU       int
+
+	// libvirt configuration (provider=libvirt)
+	libvirtURI       string
+	libvirtBaseImage string
+	libvirtImageDir  string
+	libvirtNetwork   string
+	libvirtGPUs      string
+	libvirtMemoryMiB int
+	libvirtCPUs      int
 }
 
-func (c *config) buildLabels() []scaleset.Label {
-	parts := strings.Split(c.labels, ",")
-	labels := make([]scaleset.Label, 0, len(parts))
-	for _, l := range parts {
-		l = strings.TrimSpace(l)
-		if l != "" {
-			labels = append(labels, scaleset.Label{Name: l, Type: "System"})
+// splitList splits a comma-separated flag value, trimming whitespace and
+// dropping empty entries.
+func splitList(v string) []string {
+	parts := strings.Split(v, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// parseKeyValues parses a comma-separated list of key=value pairs, as used
+// for --vm-metadata and --vm-labels.
+func parseKeyValues(v string) (map[string]string, error) {
+	entries := splitList(v)
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid entry %q (want key=value)", entry)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("duplicate key %q", key)
+		}
+		out[key] = strings.TrimSpace(value)
+	}
+	return out, nil
+}
+
+// parseCacheDisks parses --cache-disks, e.g. "us-east1-c=4,us-east1-d=2".
+func parseCacheDisks(v string) (map[string]int, error) {
+	pools, err := parseKeyValues(v)
+	if err != nil || pools == nil {
+		return nil, err
+	}
+	out := make(map[string]int, len(pools))
+	for zone, count := range pools {
+		n, err := strconv.Atoi(count)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("zone %s: disk count %q is not a positive number", zone, count)
 		}
+		out[zone] = n
+	}
+	return out, nil
+}
+
+// parseReservations parses --reservations, e.g.
+// "us-east1-c=t4-commit,us-east1-d=t4-commit-2".
+func parseReservations(v string) (map[string]string, error) {
+	reservations, err := parseKeyValues(v)
+	if err != nil {
+		return nil, err
+	}
+	for zone, name := range reservations {
+		if name == "" {
+			return nil, fmt.Errorf("zone %s: missing reservation name", zone)
+		}
+	}
+	return reservations, nil
+}
+
+// isFlagSet reports whether name was given on the command line or in the
+// config file.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// buildLabels returns the scale set's labels: --labels, routed labels, size
+// labels and priority labels as System labels, then --user-labels as User
+// labels. A name given in both, in any case, keeps its System type.
+func (c *config) buildLabels() []scaleset.Label {
+	names := splitList(c.labels)
+	names = append(names, routeLabels(names, c.routes)...)
+	names = append(names, missingLabels(names, c.sizeLabels)...)
+	names = append(names, missingLabels(names, c.priorityLabels)...)
+	labels := make([]scaleset.Label, 0, len(names))
+	for _, l := range names {
+		labels = append(labels, scaleset.Label{Name: l, Type: "System"})
+	}
+	for _, l := range missingLabels(names, splitList(c.userLabels)) {
+		labels = append(labels, scaleset.Label{Name: l, Type: "User"})
 	}
 	return labels
 }
 
+// gpuDriver returns the driver the --gpu-driver-* flags name.
+func (c *config) gpuDriver() gcpvm.GPUDriver {
+	return gcpvm.GPUDriver{Version: c.gpuDriverVersion, URL: c.gpuDriverURL, SHA256: strings.ToLower(c.gpuDriverSHA256)}
+}
+
 func (c *config) scalesetClient() (*scaleset.Client, error) {
 	if c.appClientID != "" {
 		return scaleset.NewClientWithGitHubApp(scaleset.ClientWithGitHubAppConfig{
@@ -93,85 +353,637 @@ func (c *config) scalesetClient() (*scaleset.Client, error) {
 				InstallationID: c.appInstallationID,
 				PrivateKey:     c.appPrivateKey,
 			},
-			SystemInfo: scaleset.SystemInfo{
-				System:    "gcp-runner-scaler",
-				Subsystem: "scaler",
-			},
+			SystemInfo: systemInfo(0),
 		})
 	}
 	if c.token != "" {
 		return scaleset.NewClientWithPersonalAccessToken(scaleset.NewClientWithPersonalAccessTokenConfig{
 			GitHubConfigURL:     c.registrationURL,
 			PersonalAccessToken: c.token,
-			SystemInfo: scaleset.SystemInfo{
-				System:    "gcp-runner-scaler",
-				Subsystem: "scaler",
-			},
+			SystemInfo:          systemInfo(0),
 		})
 	}
 	return nil, fmt.Errorf("either --app-client-id or --token is required")
 }
 
+// githubClient returns a GitHub REST client with the same credentials as
+// the scale set client.
+func (c *config) githubClient() (*github.Client, error) {
+	if c.appClientID != "" {
+		return github.New(c.registrationURL, github.AppAuth{
+			ClientID:       c.appClientID,
+			InstallationID: c.appInstallationID,
+			PrivateKey:     c.appPrivateKey,
+		})
+	}
+	return github.NewWithToken(c.registrationURL, c.token)
+}
+
+// subcommand is a `scaler NAME` command besides run, which serves the pool.
+type subcommand struct {
+	run func(ctx context.Context, args []string, out io.Writer) error
+	// interruptible commands get a context that SIGINT and SIGTERM cancel.
+	interruptible bool
+	// flags defines the command's flags on fs, as run does, for the
+	// completions and man page; nil for a command without flags. config
+	// commands load the scaler's configuration, so also take its flags.
+	flags  func(fs *flag.FlagSet)
+	config bool
+	// args are the command's arguments after its flags, and summary what
+	// it does, for the completions and man page.
+	args    string
+	summary string
+}
+
+var subcommands = map[string]subcommand{
+	"validate":    {run: runValidate, config: true, summary: "Check the configuration against GitHub and GCP without creating anything"},
+	"status":      {run: runStatus, flags: new(statusOptions).register, summary: "Print a running scaler's state, VMs and GPU capacity"},
+	"events":      {run: runEvents, flags: new(eventsOptions).register, summary: "Print a running scaler's recent events"},
+	"drain":       {run: controlSubcommand("drain"), flags: controlFlags("drain"), summary: "Put a running scaler in drain mode"},
+	"pause":       {run: controlSubcommand("pause"), flags: controlFlags("pause"), summary: "Stop a running scaler's scale-ups"},
+	"resume":      {run: controlSubcommand("resume"), flags: controlFlags("resume"), summary: "Resume scale-ups after a pause"},
+	"scale":       {run: controlSubcommand("scale"), flags: controlFlags("scale"), args: "[MAX_RUNNERS]", summary: "Set max runners, or pin min runners for a while"},
+	"delete-vm":   {run: controlSubcommand("delete-vm"), flags: controlFlags("delete-vm"), args: "RUNNER", summary: "Force-delete a runner's VM"},
+	"recycle":     {run: controlSubcommand("recycle"), flags: controlFlags("recycle"), args: "RUNNER", summary: "Delete a runner's VM and replace it"},
+	"ssh":         {run: iapSubcommand("ssh"), flags: iapFlags("ssh"), args: "RUNNER", summary: "Open an SSH session to a runner's VM through IAP"},
+	"rdp":         {run: iapSubcommand("rdp"), flags: iapFlags("rdp"), args: "RUNNER", summary: "Open an RDP tunnel to a runner's VM through IAP"},
+	"teardown":    {run: runTeardown, config: true, summary: "Delete the pool's scale set"},
+	"cost-report": {run: runCostReport, flags: new(costReportOptions).register, args: "AUDIT_LOG...", summary: "Total the job costs the audit logs record"},
+	"costs":       {run: runCosts, flags: new(costsOptions).register, args: "AUDIT_LOG...", summary: "Report the billed job costs from a Cloud Billing export"},
+	// A Kubernetes Job's reconcile stops on SIGTERM rather than waiting out
+	// --runner-confirm-delay.
+	"reconcile": {run: runReconcile, flags: new(reconcileOptions).register, config: true, interruptible: true, summary: "Make one cleanup pass and exit"},
+	"service":   {run: runService, args: "install NAME [flags] | remove NAME", summary: "Install or remove the scaler as a Windows service"},
+	// Interrupting top restores the cursor.
+	"top": {run: runTop, flags: new(topOptions).register, interruptible: true, summary: "Show a live view of the running scalers' pools"},
+	// An interrupted bake still deletes its builder VM.
+	"bake": {run: runBake, flags: new(bakeOptions).register, interruptible: true, summary: "Build a runner image with the startup script baked in"},
+	"init": {run: runInit, flags: new(initOptions).register, summary: "Generate a starter config, systemd unit and IAM role script"},
+}
+
+func controlSubcommand(command string) func(context.Context, []string, io.Writer) error {
+	return func(ctx context.Context, args []string, out io.Writer) error {
+		return runControl(ctx, command, args, out)
+	}
+}
+
+func controlFlags(command string) func(*flag.FlagSet) {
+	return (&controlOptions{command: command}).register
+}
+
+func iapSubcommand(kind string) func(context.Context, []string, io.Writer) error {
+	return func(ctx context.Context, args []string, out io.Writer) error {
+		return runIAP(ctx, kind, args, out)
+	}
+}
+
+func iapFlags(kind string) func(*flag.FlagSet) {
+	return (&iapOptions{kind: kind}).register
+}
+
 func main() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command := os.Args[1]
+		if command == "run" {
+			// Flags only from here on, also for SIGHUP reloads.
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		} else if sub, ok := subcommands[command]; ok {
+			ctx := context.Background()
+			if sub.interruptible {
+				var cancel context.CancelFunc
+				ctx, cancel = signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+				defer cancel()
+			}
+			if err := sub.run(ctx, os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		} else {
+			names := slices.Sorted(maps.Keys(subcommands))
+			fmt.Fprintf(os.Stderr, "error: unknown command %q; commands: run, %s\n", command, strings.Join(names, ", "))
+			os.Exit(2)
+		}
+	}
+
+	// Under the Windows service control manager, which stops the scaler
+	// instead of signals.
+	if runAsService() {
+		return
+	}
+
 	cfg := parseFlags()
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
+	// loadConfig has validated the format and level.
+	logger, logLevel, _ := newLogger(os.Stdout, cfg.logFormat, cfg.logLevel)
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	code := serve(ctx, cfg, logger, logLevel)
+	cancel()
+	os.Exit(code)
+}
+
+// serve runs the scaler with cfg until ctx is canceled or it exits on its
+// own, logging to logger at logLevel, and returns the process's exit code.
+func serve(ctx context.Context, cfg config, logger *slog.Logger, logLevel *slog.LevelVar) int {
+	history := newEventHistory(cfg.eventHistory)
+	logger = slog.New(newHistoryHandler(logger.Handler(), history))
 	slog.SetDefault(logger)
+	logger.Info("starting scaler", currentBuild().logAttrs()...)
 
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer cancel()
+	shutdownTracing, err := setupTracing(ctx, cfg)
+	if err != nil {
+		slog.Error("failed to set up tracing", "error", err)
+		return 1
+	}
+
+	logger, stopSecurityEvents, err := setupSecurityEvents(ctx, cfg, logger)
+	if err != nil {
+		slog.Error("failed to set up --security-events", "error", err)
+		return 1
+	}
+	slog.SetDefault(logger)
+
+	err = run(ctx, cfg, logger, logLevel, history)
+	stopSecurityEvents()
+
+	// Flush buffered spans; ctx is already canceled on SIGINT/SIGTERM.
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Warn("failed to flush traces", "error", err)
+	}
+	cancelFlush()
 
-	if err := run(ctx, cfg, logger); err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, errDrainComplete) {
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, errDrainComplete) && !errors.Is(err, errDrainTimedOut) {
 		slog.Error("scaler exited with error", "error", err)
-		os.Exit(1)
+		return 1
 	}
+	return 0
 }
 
 func parseFlags() config {
-	var cfg config
+	cfg, err := loadConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+	if cfg.showVersion {
+		fmt.Println(currentBuild())
+		os.Exit(0)
+	}
+	return cfg
+}
+
+// registerFlags defines every scaler flag on fs, bound to cfg.
+func registerFlags(fs *flag.FlagSet, cfg *config) {
+	fs.BoolVar(&cfg.showVersion, "version", false, "Print the version, commit and build date, and exit")
+	fs.StringVar(&cfg.configFile, "config", "", "YAML file of flag-name: value settings; flags given on the command line take precedence, and SIGHUP re-reads it")
+	fs.StringVar(&cfg.registrationURL, "url", "", "REQUIRED: GitHub URL (e.g. https://github.com/shader-slang/slang)")
+	fs.StringVar(&cfg.scaleSetName, "name", "windows-gpu-runners", "Scale set name (must be unique)")
+	fs.StringVar(&cfg.labels, "labels", "Windows,self-hosted,GCP-T4", "Comma-separated runner labels")
+	fs.StringVar(&cfg.userLabels, "user-labels", "", "Comma-separated runner labels registered with the User type, e.g. benchmark,nightly")
+	fs.StringVar(&cfg.runnerGroup, "runner-group", scaleset.DefaultRunnerGroup, "Runner group name")
+	fs.IntVar(&cfg.maxRunners, "max-runners", 5, "Maximum concurrent runners")
+	fs.IntVar(&cfg.minRunners, "min-runners", 0, "Minimum runners to keep warm")
+	fs.StringVar(&cfg.minRunnersScheduleSpec, "min-runners-schedule", "", "Comma-separated COUNT DAYS HH:MM-HH:MM [ZONE] windows overriding --min-runners, e.g. \"3 Mon-Fri 08:00-20:00 America/Los_Angeles\"")
+
+	fs.StringVar(&cfg.appClientID, "app-client-id", "", "GitHub App client ID")
+	fs.Int64Var(&cfg.appInstallationID, "app-installation-id", 0, "GitHub App installation ID")
+	fs.StringVar(&cfg.appPrivateKey, "app-private-key", "", "GitHub App private key (PEM contents)")
+	fs.StringVar(&cfg.token, "token", "", "GitHub PAT (alternative to App auth)")
+	fs.StringVar(&cfg.tokenSecret, "token-secret", "", "Secret Manager secret holding the GitHub PAT, e.g. projects/p/secrets/s[/versions/v] (overrides --token)")
+	fs.StringVar(&cfg.appPrivateKeySecret, "app-private-key-secret", "", "Secret Manager secret holding the GitHub App private key (overrides --app-private-key)")
+	fs.StringVar(&cfg.tokenFile, "token-file", "", "File holding the GitHub PAT, re-read for rotations (overrides --token)")
+	fs.StringVar(&cfg.appPrivateKeyFile, "app-private-key-file", "", "File holding the GitHub App private key, re-read for rotations (overrides --app-private-key)")
+	fs.StringVar(&cfg.envFile, "env-file", "", "The unit's EnvironmentFile, re-read for a rotated SCALER_TOKEN or SCALER_APP_PRIVATE_KEY")
+	fs.DurationVar(&cfg.secretRefreshInterval, "secret-refresh-interval", 10*time.Minute, "How often to re-fetch secret- and file-backed credentials")
+	fs.StringVar(&cfg.vaultAddr, "vault-addr", os.Getenv("VAULT_ADDR"), "Vault address (default $VAULT_ADDR)")
+	fs.StringVar(&cfg.vaultPath, "vault-path", "", "Vault path holding GitHub credentials: a KV v2 data path (keys token / app_private_key) or a GitHub secrets engine token path")
+	fs.StringVar(&cfg.vaultRole, "vault-role", "", "Vault GCP auth role to log in with using the VM identity (default: use $VAULT_TOKEN)")
+
+	fs.StringVar(&cfg.gcpProject, "gcp-project", "slang-runners", "GCP project ID")
+	fs.StringVar(&cfg.gcpZones, "gcp-zones", "us-east1-c,us-east1-d,us-central1-a,us-west1-a", "Comma-separated zones in preference order (selects by GPU quota availability)")
+	fs.StringVar(&cfg.gcpRegions, "gcp-regions", "", "Comma-separated regions in preference order; their zones offering --gcp-gpu-type and every --template-routes GPU type are discovered at startup instead of using --gcp-zones")
+	fs.StringVar(&cfg.gcpInstanceTemplate, "gcp-instance-template", "windows-gpu-runner", "GCP instance template name")
+	fs.StringVar(&cfg.gcpGPUType, "gcp-gpu-type", "nvidia-tesla-t4", "GPU accelerator type")
+	fs.StringVar(&cfg.impersonateSA, "impersonate-service-account", "", "Service account email whose credentials the GCP compute calls use, impersonated with the ambient ones, which then only need roles/iam.serviceAccountTokenCreator on it (provider=gcp)")
+	fs.StringVar(&cfg.jitConfigKMSKey, "jit-config-kms-key", "", "Cloud KMS key, projects/P/locations/L/keyRings/R/cryptoKeys/K, to encrypt the JIT config in instance metadata with; the VMs' service account decrypts it at boot (provider=gcp)")
+	fs.StringVar(&cfg.gcpPlatform, "platform", "windows", "Runner platform: windows, linux or darwin")
+	fs.StringVar(&cfg.gcpVMPrefix, "vm-prefix", "", "VM name prefix (default: win-test for windows, linux-test for linux, mac-test for darwin)")
+	fs.StringVar(&cfg.startupScript, "startup-script", "", "Path or gs://bucket/object of a startup script (Go template) replacing the embedded one")
+	fs.DurationVar(&cfg.gcpCleanupInterval, "gcp-cleanup-interval", 2*time.Minute, "Interval for scanning and deleting terminated VMs")
+	fs.IntVar(&cfg.gcpRetryAttempts, "gcp-retry-attempts", 4, "Tries of a Compute API insert, delete, list or region read that fails on a rate limit, server error or dropped connection (1 disables retries)")
+	fs.DurationVar(&cfg.gcpRetryBackoff, "gcp-retry-backoff", time.Second, "First wait before retrying a Compute API call; it doubles after every try, up to 30s")
+	fs.DurationVar(&cfg.gcpQuotaCacheTTL, "gcp-quota-cache-ttl", 30*time.Second, "How long a region's GPU quota read serves later creates, less the VMs they created (0 reads it for every create)")
+	fs.Float64Var(&cfg.gcpAPIRateLimit, "gcp-api-rate-limit", 10, "Compute API insert, delete, list and quota calls per second, with bursts of a second's worth (0 does not limit)")
+	fs.DurationVar(&cfg.sessionMaxAge, "session-max-age", 0, "Maximum age before draining and recreating the GitHub scale-set session (0 disables)")
+	fs.DurationVar(&cfg.drainTimeout, "drain-timeout", 0, "Time a drain waits for running VMs before --drain-timeout-action ends it and the scaler exits (0 waits for good)")
+	fs.StringVar(&cfg.drainTimeoutAction, "drain-timeout-action", drainTimeoutLeave, "What --drain-timeout does with the VMs still running: leave (they finish on their own) or delete")
+	fs.DurationVar(&cfg.reconcileInterval, "reconcile-interval", time.Minute, "How often to scale up when fewer runners are active than the queued jobs need, e.g. after failed creates; also retries failed JIT config requests with backoff (0 disables)")
+	fs.DurationVar(&cfg.orphanGracePeriod, "orphan-grace-period", 0, "Time a tracked VM may stay idle (never marked busy) before the cleanup loop evicts it as an orphan (0 uses the package default; negative disables)")
+	fs.IntVar(&cfg.maxJobsPerVM, "max-jobs-per-vm", 1, "Jobs a GCP VM may run before it is deleted; above 1, a VM whose job succeeded is registered again and reused (1 disables reuse)")
+	fs.DurationVar(&cfg.maxVMAge, "max-vm-age", 0, "Age after which a reused VM is deleted once its current job completes (0 disables)")
+	fs.DurationVar(&cfg.reuseGrace, "reuse-grace", 0, "With --max-jobs-per-vm, time a VM freed while the pool is above its desired size is kept for another job before it is deleted (0 deletes it right away)")
+	fs.StringVar(&cfg.priorityLabelSpec, "priority-labels", "", "Comma-separated runs-on labels marking job priority classes, highest first; at --max-runners, VMs are created for higher-priority jobs first (provider=gcp)")
+	fs.BoolVar(&cfg.recyclePriority, "recycle-for-priority", false, "At --max-runners, delete an idle VM created for a lower-priority job, or a warm one, to make room for a higher-priority job")
+	fs.StringVar(&cfg.headroomPolicySpec, "headroom-policy", "", "Comma-separated grow-rate=JOBS_PER_MIN,headroom=N,shrink-step=N: create N extra VMs while the queue grows at least that fast, and at most shrink-step VMs per scale-up while it shrinks")
+	fs.StringVar(&cfg.runnerQuotasSpec, "runner-quotas", "", "Comma-separated KEY=N caps on the runners one repository (OWNER/REPO) or workflow (OWNER/REPO/.github/workflows/FILE) may use at once")
+	fs.DurationVar(&cfg.scaleUpCooldown, "scale-up-cooldown", 0, "Time after a VM is deleted or stopped before the scaler creates another, so a desired count that goes up and down does not churn VMs (0 disables)")
+	fs.IntVar(&cfg.maxCreatesPerMinute, "max-creates-per-minute", 0, "Cap on VM creates per minute, as a token bucket holding as many; creates beyond it wait for a later poll (0 disables)")
+	fs.IntVar(&cfg.maxCreatesPerHour, "max-creates-per-hour", 0, "Cap on VM creates per hour, as a token bucket holding as many (0 disables)")
+	fs.IntVar(&cfg.breakerThreshold, "breaker-threshold", 0, "VM creates in a row that may fail, overall or in one GCP zone, before the scaler stops creating VMs there for --breaker-backoff and then probes with a single create (0 disables)")
+	fs.DurationVar(&cfg.breakerBackoff, "breaker-backoff", time.Minute, "First backoff after --breaker-threshold failed creates; it doubles after every failed probe, up to 30m")
+	fs.DurationVar(&cfg.stockoutCooldown, "gcp-stockout-cooldown", 10*time.Minute, "Time a GCP zone is skipped for new VMs after a create there ran out of resources, unless every zone is (0 only skips it for that create)")
+	fs.DurationVar(&cfg.preemptionWindow, "gcp-preemption-window", 6*time.Hour, "Time a preempted Spot VM counts against its GCP zone; new VMs go to the zones whose Spot VMs were preempted least over it (0 ignores preemptions)")
+	fs.StringVar(&cfg.stateFile, "state-file", "", "Path of a file keeping the tracked VMs across restarts, so a restarted scaler picks up the VMs still running (provider=gcp; empty disables)")
+	fs.BoolVar(&cfg.adoptVMs, "adopt-vms", false, "At startup, track the running VMs of the pool whose runners GitHub still lists, e.g. after a crash (provider=gcp; needs read access to self-hosted runners)")
+	fs.StringVar(&cfg.haLease, "ha-lease", "", "Cloud Storage object (gs://BUCKET/OBJECT) electing one of several replicas of the pool as leader; the others stand by and take over its VMs when it stops (provider=gcp; implies --adopt-vms)")
+	fs.DurationVar(&cfg.haLeaseTTL, "ha-lease-ttl", 30*time.Second, "Time after the leader's last renewal of --ha-lease before a standby takes over")
+	fs.BoolVar(&cfg.deleteScaleSet, "delete-scale-set-on-exit", false, "Delete the scale set when the scaler exits, except after a drain or with --ha-lease; by default it is kept for the next start, and `scaler teardown` deletes it")
+	fs.StringVar(&cfg.fairShareDir, "fair-share-dir", "", "Directory shared by the pools on this host whose VMs use the same GPU; they split its regional quota by --fair-share-weight instead of the first to poll taking it all (provider=gcp)")
+	fs.IntVar(&cfg.fairShareWeight, "fair-share-weight", 1, "This pool's weight in the --fair-share-dir split")
+	fs.BoolVar(&cfg.jobCosts, "job-costs", false, "Estimate the VM cost of each completed job for the audit log and Cloud Monitoring metrics, and with --annotate-jobs its check runs (provider=gcp)")
+	fs.BoolVar(&cfg.billingLabels, "billing-labels", false, "Label each VM with its pool, and with the repository, workflow and run ID of its job once it starts, for the Cloud Billing export (provider=gcp)")
+	fs.Float64Var(&cfg.dailyBudgetUSD, "daily-budget-usd", 0, "Cap in USD on the estimated VM spend per UTC day; once the day's projected spend exceeds it, the scaler stops creating VMs and alerts (0 disables)")
+	fs.Float64Var(&cfg.idleCostAlertUSD, "idle-cost-alert-usd", 0, "Alert once a UTC day when the estimated cost of VMs running no job, warm or between jobs, exceeds this many USD (0 disables)")
+	fs.IntVar(&cfg.stoppedPoolSize, "stopped-pool-size", 0, "Number of finished GCP VMs kept stopped, instead of deleted, for new runners to start (0 disables)")
+	fs.IntVar(&cfg.suspendedPoolSize, "suspended-pool-size", 0, "Like --stopped-pool-size, but suspends the VMs so they resume with their memory intact; GPU-less pools only (0 disables)")
+	fs.StringVar(&cfg.httpAddr, "http-addr", "", "Address for the HTTP /healthz and /readyz endpoints, e.g. 127.0.0.1:8080 (empty disables)")
+	fs.StringVar(&cfg.controlSocket, "control-socket", "", "Unix socket serving the admin API, without a token, to `scaler status`, `scaler drain` and the other CLI commands, e.g. /run/scaler-windows/scaler.sock (empty disables)")
+	fs.StringVar(&cfg.adminToken, "admin-token", "", "Bearer token enabling the admin API under /api/v1/ on --http-addr (env: SCALER_ADMIN_TOKEN)")
+	fs.DurationVar(&cfg.healthMaxPollAge, "health-max-poll-age", 10*time.Minute, "Time since the last successful GitHub poll after which the scaler reports itself unhealthy")
+	fs.StringVar(&cfg.notifyWebhook, "notify-webhook", "", "Slack incoming webhook or other URL receiving JSON alerts about scaling anomalies (env: SCALER_NOTIFY_WEBHOOK)")
+	fs.DurationVar(&cfg.notifyInterval, "notify-interval", 15*time.Minute, "Minimum time between two alerts of the same kind")
+	fs.DurationVar(&cfg.notifyStuckBoot, "notify-stuck-boot", 20*time.Minute, "Time after creation after which a VM that has not started a job is reported as stuck booting")
+	fs.BoolVar(&cfg.gpuCheck, "gpu-check", false, "Run a GPU smoke test on each new GPU VM before its runner starts and replace VMs whose test fails (provider=gcp)")
+	fs.BoolVar(&cfg.bootPhases, "boot-phases", false, "Track the boot phase each new VM's startup script reports as a guest attribute, for status, metrics and boot diagnostics (provider=gcp)")
+	fs.BoolVar(&cfg.shutdownScript, "shutdown-script", false, "Give VMs a shutdown script that stops a running runner gracefully and reports the shutdown, so its registration is removed at once (provider=gcp)")
+	fs.DurationVar(&cfg.bootTimeout, "boot-timeout", 0, "Time after creation within which a VM's runner must come online in GitHub; a VM exceeding it is replaced, in another zone when possible (0 disables)")
+	fs.DurationVar(&cfg.zombieTimeout, "zombie-timeout", 0, "Age after which a VM that has run no job and whose runner is still not online in GitHub is deleted, with its serial output logged, and not replaced (0 disables)")
+	fs.DurationVar(&cfg.maxJobDuration, "max-job-duration", 0, "Time after which a job still running is reported as stuck, logged and alerted on (0 disables)")
+	fs.BoolVar(&cfg.recycleStuckJobs, "recycle-stuck-jobs", false, "Delete the VM of a job running longer than --max-job-duration, failing the job, instead of only reporting it")
+	fs.DurationVar(&cfg.runnerSweepInterval, "runner-sweep-interval", 0, "How often to remove the pool's runners that GitHub lists as offline and no tracked VM runs, e.g. after a crash (0 disables)")
+	fs.BoolVar(&cfg.replacePreempted, "replace-preempted", false, "Create a replacement VM right away when a spot VM is preempted, if the pool is below its desired size")
+	fs.StringVar(&cfg.incidentProvider, "incident-provider", "", "Open a pagerduty or opsgenie incident when no VM can be created for --incident-after (empty disables)")
+	fs.StringVar(&cfg.incidentKey, "incident-key", "", "PagerDuty Events v2 routing key or Opsgenie API key (env: SCALER_INCIDENT_KEY)")
+	fs.DurationVar(&cfg.incidentAfter, "incident-after", 30*time.Minute, "Time without any successful VM create or GitHub poll after which an incident is opened")
+	fs.BoolVar(&cfg.annotateJobs, "annotate-jobs", false, "Add a check run to each started job's commit naming its VM, zone, machine type and boot time (requires GitHub App auth with checks:write)")
+	fs.IntVar(&cfg.eventHistory, "event-history", 500, "Number of recent lifecycle events and errors kept for the admin API and `scaler events` (0 disables)")
+	fs.StringVar(&cfg.auditLog, "audit-log", "", "Path of a JSONL file recording every scaling decision (empty disables)")
+	fs.StringVar(&cfg.securityEvents, "security-events", "", "Where to ship security events for a SIEM: pubsub:projects/P/topics/T or logging:projects/P/logs/NAME (empty disables)")
+	fs.IntVar(&cfg.auditLogMaxSizeMB, "audit-log-max-size-mb", 100, "Size in MB at which the audit log is rotated")
+	fs.IntVar(&cfg.auditLogMaxFiles, "audit-log-max-files", 5, "Number of rotated audit log files kept")
+	fs.StringVar(&cfg.logFormat, "log-format", "text", "Log format: text or json")
+	fs.StringVar(&cfg.logLevel, "log-level", "info", "Minimum log level: debug, info, warn or error")
+	fs.StringVar(&cfg.metricsProject, "metrics-project", "", "GCP project receiving Cloud Monitoring custom metrics for runner counts (empty disables)")
+	fs.DurationVar(&cfg.metricsInterval, "metrics-interval", time.Minute, "Interval between Cloud Monitoring metric writes")
+	fs.StringVar(&cfg.otlpEndpoint, "otlp-endpoint", "", "OTLP/gRPC collector URL for traces, e.g. http://localhost:4317 (also enabled by OTEL_EXPORTER_OTLP_ENDPOINT)")
+
+	fs.StringVar(&cfg.templateRoutes, "template-routes", "", "Comma-separated label=template[/gpu-type] routes picking an instance template by the job's runs-on labels (provider=gcp)")
+	fs.StringVar(&cfg.sizeLabelSpec, "size-labels", "", "Comma-separated gpu-TYPE, cpu-N and mem-N labels jobs can put in runs-on to pick their VM's GPU, vCPUs and memory (GB), e.g. gpu-a100,cpu-32,mem-128 (provider=gcp)")
+	fs.StringVar(&cfg.vmMetadata, "vm-metadata", "", "Comma-separated key=value metadata items added to every VM (provider=gcp)")
+	fs.Int64Var(&cfg.bootDiskSizeGB, "boot-disk-size-gb", 0, "Boot disk size in GB, overriding the instance template (0 keeps the template's; provider=gcp)")
+	fs.StringVar(&cfg.bootDiskType, "boot-disk-type", "", "Boot disk type, e.g. pd-ssd or pd-balanced, overriding the instance template (provider=gcp)")
+	fs.StringVar(&cfg.imageFamily, "image-family", "", "Boot new VMs from this image family's newest image instead of the template's image, picking up new images as they are published (provider=gcp)")
+	fs.StringVar(&cfg.gpuDriverVersion, "gpu-driver-version", "", "NVIDIA driver version, e.g. 553.24, that new GPU VMs install from --gpu-driver-url when their image has another (provider=gcp, platform=windows)")
+	fs.StringVar(&cfg.gpuDriverURL, "gpu-driver-url", "", "https:// or gs:// URL of the --gpu-driver-version installer")
+	fs.StringVar(&cfg.gpuDriverSHA256, "gpu-driver-sha256", "", "SHA-256 checksum of the --gpu-driver-url installer (empty skips the check)")
+	fs.StringVar(&cfg.cacheDiskSpec, "cache-disks", "", "Comma-separated zone=count build cache disk pools; each VM gets a free regional disk of its zone, mounted by the startup script (provider=gcp)")
+	fs.Int64Var(&cfg.cacheDiskSizeGB, "cache-disk-size-gb", 200, "Size in GB of new build cache disks")
+	fs.StringVar(&cfg.cacheDiskType, "cache-disk-type", "pd-balanced", "Disk type of new build cache disks, e.g. pd-ssd")
+	fs.StringVar(&cfg.reservationSpec, "reservations", "", "Comma-separated zone=reservation specific reservations new VMs are created in while they have room; zones with room are preferred (provider=gcp)")
+	fs.StringVar(&cfg.migProfile, "mig-profile", "", "MIG profile, e.g. 1g.5gb, partitioning the A100 GPUs of new VMs into slices with one runner each (provider=gcp, platform=linux)")
+	fs.IntVar(&cfg.runnerSlots, "runner-slots", 1, "Runners per VM for CPU-heavy pools, each with its own JIT config; the VM is deleted once all of them finish (provider=gcp)")
+	fs.IntVar(&cfg.localSSDs, "local-ssds", 0, "Number of local NVMe SSDs attached to each VM as scratch space, replacing the instance template's (provider=gcp)")
+	fs.StringVar(&cfg.network, "network", "", "VPC network name or self-link for the VM's primary interface, overriding the instance template (provider=gcp)")
+	fs.StringVar(&cfg.subnetwork, "subnetwork", "", "Subnetwork name (looked up in each zone's region) or self-link, overriding the instance template (provider=gcp)")
+	fs.StringVar(&cfg.networkTags, "network-tags", "", "Comma-separated network tags for every VM, replacing the template's tags (provider=gcp)")
+	fs.BoolVar(&cfg.noExternalIP, "no-external-ip", false, "Create VMs without external IPs; startup fails unless their subnetworks have Cloud NAT (provider=gcp)")
+	fs.BoolVar(&cfg.orgPolicyCheck, "org-policy-check", false, "Check each VM against the project's resource location and external IP org policies before creating it (provider=gcp)")
+	fs.StringVar(&cfg.allowedMachineTypes, "allowed-machine-types", "", "Comma-separated machine type patterns (e.g. g2-standard-*) VMs may use; others fail before creation (provider=gcp)")
+	fs.BoolVar(&cfg.manageFirewall, "manage-firewall", false, "Create firewall rules limiting the VMs' egress to GitHub and Google APIs at startup; `scaler teardown` deletes them (provider=gcp)")
+	fs.StringVar(&cfg.serviceAccount, "service-account", "", "Service account email attached to every VM, overriding the instance template (provider=gcp)")
+	fs.StringVar(&cfg.serviceAccountScope, "service-account-scopes", "", "Comma-separated OAuth scopes for the VM service account, short names or URLs (default cloud-platform; provider=gcp)")
+	fs.StringVar(&cfg.vmLabels, "vm-labels", "", "Comma-separated key=value GCP resource labels for every VM, replacing the template's labels (provider=gcp)")
+
+	fs.StringVar(&cfg.provider, "provider", "", "VM provider: gcp, orka or libvirt (default: orka for darwin, gcp otherwise)")
+	fs.StringVar(&cfg.orkaURL, "orka-url", "", "Orka API base URL (provider=orka)")
+	fs.StringVar(&cfg.orkaToken, "orka-token", "", "Orka service-account token (provider=orka)")
+	fs.StringVar(&cfg.orkaNamespace, "orka-namespace", "orka-default", "Orka namespace for runner VMs (provider=orka)")
+	fs.StringVar(&cfg.orkaImage, "orka-image", "", "Orka macOS image name (provider=orka)")
+	fs.IntVar(&cfg.orkaCPU, "orka-cpu", 6, "vCPUs per Orka VM (provider=orka)")
+
+	fs.StringVar(&cfg.libvirtURI, "libvirt-uri", "qemu:///system", "libvirt connection URI (provider=libvirt)")
+	fs.StringVar(&cfg.libvirtBaseImage, "libvirt-base-image", "", "Base qcow2 image cloned for each runner (provider=libvirt)")
+	fs.StringVar(&cfg.libvirtImageDir, "libvirt-image-dir", "/var/lib/libvirt/images", "Directory for per-runner overlay disks (provider=libvirt)")
+	fs.StringVar(&cfg.libvirtNetwork, "libvirt-network", "default", "libvirt network for runner NICs (provider=libvirt)")
+	fs.StringVar(&cfg.libvirtGPUs, "libvirt-gpus", "", "Comma-separated host PCI addresses passed through one per VM, e.g. 0000:65:00.0 (provider=libvirt; empty for CPU-only)")
+	fs.IntVar(&cfg.libvirtMemoryMiB, "libvirt-memory-mb", 16384, "Guest memory in MiB (provider=libvirt)")
+	fs.IntVar(&cfg.libvirtCPUs, "libvirt-cpus", 8, "Guest vCPUs (provider=libvirt)")
+}
 
-	flag.StringVar(&cfg.registrationURL, "url", "", "REQUIRED: GitHub URL (e.g. https://github.com/shader-slang/slang)")
-	flag.StringVar(&cfg.scaleSetName, "name", "windows-gpu-runners", "Scale set name (must be unique)")
-	flag.StringVar(&cfg.labels, "labels", "Windows,self-hosted,GCP-T4", "Comma-separated runner labels")
-	flag.StringVar(&cfg.runnerGroup, "runner-group", scaleset.DefaultRunnerGroup, "Runner group name")
-	flag.IntVar(&cfg.maxRunners, "max-runners", 5, "Maximum concurrent runners")
-	flag.IntVar(&cfg.minRunners, "min-runners", 0, "Minimum runners to keep warm")
-
-	flag.StringVar(&cfg.appClientID, "app-client-id", "", "GitHub App client ID")
-	flag.Int64Var(&cfg.appInstallationID, "app-installation-id", 0, "GitHub App installation ID")
-	flag.StringVar(&cfg.appPrivateKey, "app-private-key", "", "GitHub App private key (PEM contents)")
-	flag.StringVar(&cfg.token, "token", "", "GitHub PAT (alternative to App auth)")
-
-	flag.StringVar(&cfg.gcpProject, "gcp-project", "slang-runners", "GCP project ID")
-	flag.StringVar(&cfg.gcpZones, "gcp-zones", "us-east1-c,us-east1-d,us-central1-a,us-west1-a", "Comma-separated zones in preference order (selects by GPU quota availability)")
-	flag.StringVar(&cfg.gcpInstanceTemplate, "gcp-instance-template", "windows-gpu-runner", "GCP instance template name")
-	flag.StringVar(&cfg.gcpGPUType, "gcp-gpu-type", "nvidia-tesla-t4", "GPU accelerator type")
-	flag.StringVar(&cfg.gcpPlatform, "platform", "windows", "Runner platform: windows or linux")
-	flag.StringVar(&cfg.gcpVMPrefix, "vm-prefix", "", "VM name prefix (default: win-test for windows, linux-test for linux)")
-	flag.DurationVar(&cfg.gcpCleanupInterval, "gcp-cleanup-interval", 2*time.Minute, "Interval for scanning and deleting terminated VMs")
-	flag.DurationVar(&cfg.sessionMaxAge, "session-max-age", 0, "Maximum age before draining and recreating the GitHub scale-set session (0 disables)")
-	flag.DurationVar(&cfg.orphanGracePeriod, "orphan-grace-period", 0, "Time a tracked VM may stay idle (never marked busy) before the cleanup loop evicts it as an orphan (0 uses the package default; negative disables)")
-
-	flag.Parse()
+// loadConfig parses args on fs, fills in flags not given on the command line
+// from the --config file, applies SCALER_* environment overrides and
+// validates the result. SIGHUP reloads call it again with a fresh FlagSet.
+func loadConfig(fs *flag.FlagSet, args []string) (config, error) {
+	var cfg config
+	registerFlags(fs, &cfg)
+	if err := fs.Parse(args); err != nil {
+		return config{}, err
+	}
+	if cfg.showVersion {
+		return cfg, nil
+	}
+	if cfg.configFile != "" {
+		if err := applyConfigFile(fs, cfg.configFile); err != nil {
+			return config{}, fmt.Errorf("reading --config: %w", err)
+		}
+	}
 
+	if cfg.reconcileInterval < 0 {
+		return config{}, errors.New("--reconcile-interval must not be negative")
+	}
 	if err := validateSessionMaxAge(cfg.sessionMaxAge); err != nil {
-		fmt.Fprintf(os.Stderr, "error: invalid --session-max-age: %v\n", err)
-		flag.Usage()
-		os.Exit(1)
+		return config{}, fmt.Errorf("invalid --session-max-age: %w", err)
+	}
+	if cfg.drainTimeout < 0 {
+		return config{}, errors.New("--drain-timeout must not be negative")
+	}
+	if cfg.drainTimeoutAction != drainTimeoutLeave && cfg.drainTimeoutAction != drainTimeoutDelete {
+		return config{}, fmt.Errorf("--drain-timeout-action must be leave or delete, got %q", cfg.drainTimeoutAction)
 	}
 
 	if cfg.registrationURL == "" {
-		fmt.Fprintln(os.Stderr, "error: --url is required")
-		flag.Usage()
-		os.Exit(1)
+		return config{}, errors.New("--url is required")
 	}
 
-	if cfg.gcpPlatform != "windows" && cfg.gcpPlatform != "linux" {
-		fmt.Fprintf(os.Stderr, "error: --platform must be 'windows' or 'linux', got %q\n", cfg.gcpPlatform)
-		flag.Usage()
-		os.Exit(1)
+	if cfg.secretRefreshInterval <= 0 {
+		return config{}, errors.New("--secret-refresh-interval must be positive")
+	}
+	if cfg.vaultPath != "" && (cfg.tokenSecret != "" || cfg.appPrivateKeySecret != "") {
+		return config{}, errors.New("--vault-path cannot be combined with --token-secret or --app-private-key-secret")
+	}
+	if cfg.vaultPath != "" && (cfg.tokenFile != "" || cfg.appPrivateKeyFile != "" || cfg.envFile != "") {
+		return config{}, errors.New("--vault-path cannot be combined with --token-file, --app-private-key-file or --env-file")
+	}
+	if cfg.tokenFile != "" && cfg.tokenSecret != "" {
+		return config{}, errors.New("--token-file and --token-secret are mutually exclusive")
+	}
+	if cfg.appPrivateKeyFile != "" && cfg.appPrivateKeySecret != "" {
+		return config{}, errors.New("--app-private-key-file and --app-private-key-secret are mutually exclusive")
+	}
+	cfg.vaultToken = os.Getenv("VAULT_TOKEN")
+	if cfg.gcpRegions != "" && isFlagSet(fs, "gcp-zones") {
+		return config{}, errors.New("--gcp-regions and --gcp-zones are mutually exclusive")
+	}
+
+	provider, err := resolveProvider(cfg.gcpPlatform, cfg.provider)
+	if err != nil {
+		return config{}, err
+	}
+	cfg.provider = provider
+
+	cfg.minRunnersSchedule, err = parseMinRunnersSchedule(cfg.minRunnersScheduleSpec)
+	if err != nil {
+		return config{}, fmt.Errorf("invalid --min-runners-schedule: %w", err)
+	}
+	cfg.runnerQuotas, err = parseRunnerQuotas(cfg.runnerQuotasSpec)
+	if err != nil {
+		return config{}, fmt.Errorf("invalid --runner-quotas: %w", err)
+	}
+	cfg.headroomPolicy, err = parseHeadroomPolicy(cfg.headroomPolicySpec)
+	if err != nil {
+		return config{}, fmt.Errorf("invalid --headroom-policy: %w", err)
+	}
+	cfg.priorityLabels, err = parsePriorityLabels(cfg.priorityLabelSpec)
+	if err != nil {
+		return config{}, fmt.Errorf("invalid --priority-labels: %w", err)
+	}
+	if len(cfg.priorityLabels) > 0 && cfg.provider != "gcp" {
+		return config{}, errors.New("--priority-labels requires --provider=gcp")
+	}
+	if cfg.recyclePriority && len(cfg.priorityLabels) == 0 {
+		return config{}, errors.New("--recycle-for-priority requires --priority-labels")
+	}
+	cfg.routes, err = parseTemplateRoutes(cfg.templateRoutes)
+	if err != nil {
+		return config{}, fmt.Errorf("invalid --template-routes: %w", err)
+	}
+	if len(cfg.routes) > 0 && cfg.provider != "gcp" {
+		return config{}, errors.New("--template-routes requires --provider=gcp")
+	}
+	cfg.sizeLabels, err = parseSizeLabels(cfg.sizeLabelSpec)
+	if err != nil {
+		return config{}, fmt.Errorf("invalid --size-labels: %w", err)
+	}
+	if len(cfg.sizeLabels) > 0 && cfg.provider != "gcp" {
+		return config{}, errors.New("--size-labels requires --provider=gcp")
+	}
+	cfg.metadata, err = parseKeyValues(cfg.vmMetadata)
+	if err != nil {
+		return config{}, fmt.Errorf("invalid --vm-metadata: %w", err)
+	}
+	cfg.gcpLabels, err = parseKeyValues(cfg.vmLabels)
+	if err != nil {
+		return config{}, fmt.Errorf("invalid --vm-labels: %w", err)
+	}
+	if err := gcpvm.ValidateLabels(cfg.gcpLabels); err != nil {
+		return config{}, fmt.Errorf("invalid --vm-labels: %w", err)
+	}
+	if (cfg.metadata != nil || cfg.gcpLabels != nil) && cfg.provider != "gcp" {
+		return config{}, errors.New("--vm-metadata and --vm-labels require --provider=gcp")
+	}
+	if cfg.bootDiskSizeGB < 0 {
+		return config{}, errors.New("--boot-disk-size-gb must not be negative")
+	}
+	if (cfg.bootDiskSizeGB > 0 || cfg.bootDiskType != "") && cfg.provider != "gcp" {
+		return config{}, errors.New("--boot-disk-size-gb and --boot-disk-type require --provider=gcp")
+	}
+	if cfg.imageFamily != "" {
+		if cfg.provider != "gcp" {
+			return config{}, errors.New("--image-family requires --provider=gcp")
+		}
+		if _, _, err := gcpvm.ParseImageFamily(cfg.imageFamily, cfg.gcpProject); err != nil {
+			return config{}, fmt.Errorf("--image-family: %w", err)
+		}
+	}
+	if err := validateGPUDriver(cfg); err != nil {
+		return config{}, err
+	}
+	cfg.cacheDisks, err = parseCacheDisks(cfg.cacheDiskSpec)
+	if err != nil {
+		return config{}, fmt.Errorf("invalid --cache-disks: %w", err)
+	}
+	if cfg.cacheDisks != nil && cfg.provider != "gcp" {
+		return config{}, errors.New("--cache-disks requires --provider=gcp")
+	}
+	if cfg.cacheDiskSizeGB <= 0 {
+		return config{}, errors.New("--cache-disk-size-gb must be positive")
+	}
+	cfg.reservations, err = parseReservations(cfg.reservationSpec)
+	if err != nil {
+		return config{}, fmt.Errorf("invalid --reservations: %w", err)
+	}
+	if cfg.reservations != nil && cfg.provider != "gcp" {
+		return config{}, errors.New("--reservations requires --provider=gcp")
+	}
+	if cfg.localSSDs < 0 {
+		return config{}, errors.New("--local-ssds must not be negative")
+	}
+	if cfg.localSSDs > 0 && cfg.provider != "gcp" {
+		return config{}, errors.New("--local-ssds requires --provider=gcp")
+	}
+	if cfg.localSSDs > 0 && cfg.suspendedPoolSize > 0 {
+		// A resumed VM would find its mounted scratch volume wiped.
+		return config{}, errors.New("--local-ssds cannot be combined with --suspended-pool-size")
+	}
+	if cfg.gcpRegions != "" && cfg.provider != "gcp" {
+		return config{}, errors.New("--gcp-regions requires --provider=gcp")
+	}
+	if (cfg.network != "" || cfg.subnetwork != "" || cfg.networkTags != "") && cfg.provider != "gcp" {
+		return config{}, errors.New("--network, --subnetwork and --network-tags require --provider=gcp")
+	}
+	if cfg.noExternalIP && cfg.provider != "gcp" {
+		return config{}, errors.New("--no-external-ip requires --provider=gcp")
+	}
+	if (cfg.orgPolicyCheck || cfg.allowedMachineTypes != "") && cfg.provider != "gcp" {
+		return config{}, errors.New("--org-policy-check and --allowed-machine-types require --provider=gcp")
+	}
+	for _, pattern := range splitList(cfg.allowedMachineTypes) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return config{}, fmt.Errorf("--allowed-machine-types: invalid pattern %q", pattern)
+		}
+	}
+	if cfg.manageFirewall && cfg.provider != "gcp" {
+		return config{}, errors.New("--manage-firewall requires --provider=gcp")
+	}
+	if (cfg.serviceAccount != "" || cfg.serviceAccountScope != "") && cfg.provider != "gcp" {
+		return config{}, errors.New("--service-account and --service-account-scopes require --provider=gcp")
+	}
+	if cfg.maxJobsPerVM < 1 || cfg.maxVMAge < 0 {
+		return config{}, errors.New("--max-jobs-per-vm must be at least 1 and --max-vm-age non-negative")
+	}
+	if cfg.maxJobsPerVM > 1 && cfg.provider != "gcp" {
+		return config{}, errors.New("--max-jobs-per-vm requires --provider=gcp")
+	}
+	if cfg.reuseGrace < 0 || cfg.scaleUpCooldown < 0 {
+		return config{}, errors.New("--reuse-grace and --scale-up-cooldown must not be negative")
+	}
+	if cfg.reuseGrace > 0 && cfg.maxJobsPerVM < 2 {
+		return config{}, errors.New("--reuse-grace requires --max-jobs-per-vm above 1")
+	}
+	if cfg.maxCreatesPerMinute < 0 || cfg.maxCreatesPerHour < 0 {
+		return config{}, errors.New("--max-creates-per-minute and --max-creates-per-hour must not be negative")
+	}
+	if cfg.gcpRetryAttempts < 1 || cfg.gcpRetryBackoff <= 0 {
+		return config{}, errors.New("--gcp-retry-attempts must be at least 1 and --gcp-retry-backoff must be positive")
+	}
+	if cfg.gcpQuotaCacheTTL < 0 {
+		return config{}, errors.New("--gcp-quota-cache-ttl must not be negative")
+	}
+	if cfg.gcpAPIRateLimit < 0 {
+		return config{}, errors.New("--gcp-api-rate-limit must not be negative")
+	}
+	if cfg.stockoutCooldown < 0 {
+		return config{}, errors.New("--gcp-stockout-cooldown must not be negative")
+	}
+	if cfg.preemptionWindow < 0 {
+		return config{}, errors.New("--gcp-preemption-window must not be negative")
+	}
+	if cfg.breakerThreshold < 0 || cfg.breakerBackoff <= 0 {
+		return config{}, errors.New("--breaker-threshold must not be negative and --breaker-backoff must be positive")
+	}
+	if cfg.stateFile != "" && cfg.provider != "gcp" {
+		return config{}, errors.New("--state-file requires --provider=gcp")
+	}
+	if cfg.adoptVMs && cfg.provider != "gcp" {
+		return config{}, errors.New("--adopt-vms requires --provider=gcp")
+	}
+	if cfg.haLease != "" {
+		if cfg.provider != "gcp" {
+			return config{}, errors.New("--ha-lease requires --provider=gcp")
+		}
+		if _, _, err := lease.ParseURI(cfg.haLease); err != nil {
+			return config{}, fmt.Errorf("--ha-lease: %w", err)
+		}
+		if cfg.haLeaseTTL < minHALeaseTTL {
+			return config{}, fmt.Errorf("--ha-lease-ttl must be at least %s", minHALeaseTTL)
+		}
+	}
+	if cfg.fairShareWeight < 1 {
+		return config{}, errors.New("--fair-share-weight must be at least 1")
+	}
+	if cfg.fairShareDir != "" && (cfg.provider != "gcp" || cfg.gcpGPUType == "none") {
+		// Only GPU quota is shared between pools.
+		return config{}, errors.New("--fair-share-dir requires --provider=gcp and a --gcp-gpu-type")
+	}
+	if cfg.dailyBudgetUSD < 0 {
+		return config{}, errors.New("--daily-budget-usd must not be negative")
+	}
+	if cfg.dailyBudgetUSD > 0 && cfg.provider != "gcp" {
+		// Only the GCP provider estimates what its VMs cost.
+		return config{}, errors.New("--daily-budget-usd requires --provider=gcp")
+	}
+	if cfg.idleCostAlertUSD < 0 {
+		return config{}, errors.New("--idle-cost-alert-usd must not be negative")
+	}
+	if cfg.idleCostAlertUSD > 0 && cfg.provider != "gcp" {
+		return config{}, errors.New("--idle-cost-alert-usd requires --provider=gcp")
+	}
+	if cfg.jobCosts && cfg.provider != "gcp" {
+		return config{}, errors.New("--job-costs requires --provider=gcp")
+	}
+	if cfg.billingLabels && cfg.provider != "gcp" {
+		return config{}, errors.New("--billing-labels requires --provider=gcp")
+	}
+	if cfg.impersonateSA != "" && cfg.provider != "gcp" {
+		return config{}, errors.New("--impersonate-service-account requires --provider=gcp")
+	}
+	if cfg.impersonateSA != "" && !strings.Contains(cfg.impersonateSA, "@") {
+		return config{}, fmt.Errorf("--impersonate-service-account must be a service account email, not %q", cfg.impersonateSA)
+	}
+	if cfg.jitConfigKMSKey != "" && cfg.provider != "gcp" {
+		return config{}, errors.New("--jit-config-kms-key requires --provider=gcp")
+	}
+	if cfg.jitConfigKMSKey != "" && (!strings.HasPrefix(cfg.jitConfigKMSKey, "projects/") || !strings.Contains(cfg.jitConfigKMSKey, "/cryptoKeys/")) {
+		return config{}, fmt.Errorf("--jit-config-kms-key must be a key name like projects/P/locations/L/keyRings/R/cryptoKeys/K, not %q", cfg.jitConfigKMSKey)
+	}
+	if cfg.stoppedPoolSize < 0 || cfg.suspendedPoolSize < 0 {
+		return config{}, errors.New("--stopped-pool-size and --suspended-pool-size must not be negative")
+	}
+	if (cfg.stoppedPoolSize > 0 || cfg.suspendedPoolSize > 0) && cfg.provider != "gcp" {
+		return config{}, errors.New("--stopped-pool-size and --suspended-pool-size require --provider=gcp")
+	}
+	if cfg.stoppedPoolSize > 0 && cfg.suspendedPoolSize > 0 {
+		return config{}, errors.New("--stopped-pool-size and --suspended-pool-size are mutually exclusive")
+	}
+	if cfg.suspendedPoolSize > 0 && !suspendable(cfg) {
+		// GCE refuses to suspend a VM with an accelerator attached.
+		return config{}, errors.New("--suspended-pool-size requires --gcp-gpu-type=none and GPU-less --template-routes")
+	}
+	if cfg.migProfile != "" {
+		if cfg.provider != "gcp" || cfg.gcpPlatform != "linux" {
+			// MIG is not supported on Windows.
+			return config{}, errors.New("--mig-profile requires --provider=gcp and --platform=linux")
+		}
+		if !migCapable(cfg) {
+			return config{}, fmt.Errorf("--mig-profile %q does not fit the GPU of the pool or any --template-routes entry (A100 only)", cfg.migProfile)
+		}
+		// A MIG VM's runners share it until the last one finishes.
+		if cfg.maxJobsPerVM > 1 || cfg.stoppedPoolSize > 0 {
+			return config{}, errors.New("--mig-profile cannot be combined with --max-jobs-per-vm or --stopped-pool-size")
+		}
+	}
+	if cfg.runnerSlots < 1 {
+		return config{}, errors.New("--runner-slots must be at least 1")
+	}
+	if cfg.runnerSlots > 1 {
+		if cfg.provider != "gcp" {
+			return config{}, errors.New("--runner-slots requires --provider=gcp")
+		}
+		if cfg.maxJobsPerVM > 1 || cfg.stoppedPoolSize > 0 || cfg.suspendedPoolSize > 0 {
+			return config{}, errors.New("--runner-slots cannot be combined with --max-jobs-per-vm, --stopped-pool-size or --suspended-pool-size")
+		}
 	}
 
 	// Allow environment variables to override auth flags.
@@ -185,40 +997,109 @@ func parseFlags() config {
 	if v := os.Getenv("SCALER_APP_INSTALLATION_ID"); v != "" && cfg.appInstallationID == 0 {
 		id, err := strconv.ParseInt(v, 10, 64)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: invalid SCALER_APP_INSTALLATION_ID %q: %v\n", v, err)
-			os.Exit(1)
+			return config{}, fmt.Errorf("invalid SCALER_APP_INSTALLATION_ID %q: %w", v, err)
 		}
 		cfg.appInstallationID = id
 	}
 	if v := os.Getenv("SCALER_APP_PRIVATE_KEY"); v != "" && cfg.appPrivateKey == "" {
 		cfg.appPrivateKey = v
 	}
+	if v := os.Getenv("SCALER_ORKA_TOKEN"); v != "" && cfg.orkaToken == "" {
+		cfg.orkaToken = v
+	}
+	if v := os.Getenv("SCALER_ADMIN_TOKEN"); v != "" && cfg.adminToken == "" {
+		cfg.adminToken = v
+	}
+	if v := os.Getenv("SCALER_NOTIFY_WEBHOOK"); v != "" && cfg.notifyWebhook == "" {
+		cfg.notifyWebhook = v
+	}
+	if v := os.Getenv("SCALER_INCIDENT_KEY"); v != "" && cfg.incidentKey == "" {
+		cfg.incidentKey = v
+	}
+	if cfg.incidentProvider != "" {
+		if _, err := incident.New(cfg.incidentProvider, cfg.incidentKey, cfg.scaleSetName, ""); err != nil {
+			return config{}, fmt.Errorf("--incident-provider: %w", err)
+		}
+		if cfg.incidentAfter <= 0 {
+			return config{}, errors.New("--incident-after must be positive")
+		}
+	}
+	if cfg.bootTimeout < 0 || cfg.bootTimeout > 0 && cfg.bootTimeout < minBootTimeout {
+		return config{}, fmt.Errorf("--boot-timeout must be 0 or at least %s", minBootTimeout)
+	}
+	if cfg.zombieTimeout < 0 || cfg.zombieTimeout > 0 && cfg.zombieTimeout < minBootTimeout {
+		return config{}, fmt.Errorf("--zombie-timeout must be 0 or at least %s", minBootTimeout)
+	}
+	if cfg.zombieTimeout > 0 && cfg.bootTimeout > 0 && cfg.zombieTimeout <= cfg.bootTimeout {
+		return config{}, errors.New("--zombie-timeout must be longer than --boot-timeout, which replaces the VM first")
+	}
+	if cfg.maxJobDuration < 0 || cfg.maxJobDuration > 0 && cfg.maxJobDuration < minMaxJobDuration {
+		return config{}, fmt.Errorf("--max-job-duration must be 0 or at least %s", minMaxJobDuration)
+	}
+	if cfg.recycleStuckJobs && cfg.maxJobDuration == 0 {
+		return config{}, errors.New("--recycle-stuck-jobs requires --max-job-duration")
+	}
+	if cfg.runnerSweepInterval < 0 {
+		return config{}, errors.New("--runner-sweep-interval must not be negative")
+	}
+	if (cfg.gpuCheck || cfg.bootPhases) && cfg.provider != "gcp" {
+		return config{}, errors.New("--gpu-check and --boot-phases require --provider=gcp")
+	}
+	if cfg.shutdownScript && cfg.provider != "gcp" {
+		return config{}, errors.New("--shutdown-script requires --provider=gcp")
+	}
+	if cfg.replacePreempted && cfg.provider != "gcp" {
+		return config{}, errors.New("--replace-preempted requires --provider=gcp")
+	}
+	if cfg.notifyInterval <= 0 || cfg.notifyStuckBoot <= 0 {
+		return config{}, errors.New("--notify-interval and --notify-stuck-boot must be positive")
+	}
+	if _, _, err := newLogger(io.Discard, cfg.logFormat, cfg.logLevel); err != nil {
+		return config{}, err
+	}
+	if cfg.annotateJobs && cfg.appClientID == "" {
+		return config{}, errors.New("--annotate-jobs requires GitHub App auth (--app-client-id); check runs cannot be created with a PAT")
+	}
+	if cfg.eventHistory < 0 {
+		return config{}, errors.New("--event-history must not be negative")
+	}
+	if cfg.securityEvents != "" {
+		if _, _, err := secevents.ParseDestination(cfg.securityEvents); err != nil {
+			return config{}, fmt.Errorf("--security-events: %w", err)
+		}
+	}
+	if cfg.auditLogMaxSizeMB <= 0 || cfg.auditLogMaxFiles < 0 {
+		return config{}, errors.New("--audit-log-max-size-mb must be positive and --audit-log-max-files non-negative")
+	}
+	if cfg.metricsInterval < minMetricsInterval {
+		return config{}, fmt.Errorf("--metrics-interval must be at least %s", minMetricsInterval)
+	}
+	if cfg.adminToken != "" && cfg.httpAddr == "" {
+		return config{}, errors.New("--admin-token requires --http-addr")
+	}
 	if v := os.Getenv("SCALER_GCP_CLEANUP_INTERVAL"); v != "" {
 		d, err := parseCleanupInterval(v)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: invalid SCALER_GCP_CLEANUP_INTERVAL: %v\n", err)
-			os.Exit(1)
+			return config{}, fmt.Errorf("invalid SCALER_GCP_CLEANUP_INTERVAL: %w", err)
 		}
 		cfg.gcpCleanupInterval = d
 	}
 	if v := os.Getenv("SCALER_SESSION_MAX_AGE"); v != "" {
 		d, err := parseSessionMaxAge(v)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: invalid SCALER_SESSION_MAX_AGE: %v\n", err)
-			os.Exit(1)
+			return config{}, fmt.Errorf("invalid SCALER_SESSION_MAX_AGE: %w", err)
 		}
 		cfg.sessionMaxAge = d
 	}
 	if v := os.Getenv("SCALER_ORPHAN_GRACE_PERIOD"); v != "" {
 		d, err := time.ParseDuration(v)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: invalid SCALER_ORPHAN_GRACE_PERIOD %q: %v\n", v, err)
-			os.Exit(1)
+			return config{}, fmt.Errorf("invalid SCALER_ORPHAN_GRACE_PERIOD %q: %w", v, err)
 		}
 		cfg.orphanGracePeriod = d
 	}
 
-	return cfg
+	return cfg, nil
 }
 
 func parseCleanupInterval(v string) (time.Duration, error) {
@@ -247,7 +1128,278 @@ func validateSessionMaxAge(d time.Duration) error {
 	return nil
 }
 
-func run(ctx context.Context, cfg config, logger *slog.Logger) error {
+// validateGPUDriver checks the --gpu-driver-* flags, which only the Windows
+// startup script reads.
+func validateGPUDriver(cfg config) error {
+	if cfg.gpuDriverVersion == "" && cfg.gpuDriverURL == "" && cfg.gpuDriverSHA256 == "" {
+		return nil
+	}
+	if cfg.provider != "gcp" || cfg.gcpPlatform != "windows" {
+		return errors.New("--gpu-driver-version requires --provider=gcp and --platform=windows")
+	}
+	if cfg.gpuDriverVersion == "" || cfg.gpuDriverURL == "" {
+		return errors.New("--gpu-driver-version and --gpu-driver-url must be set together")
+	}
+	if !strings.HasPrefix(cfg.gpuDriverURL, "https://") && !strings.HasPrefix(cfg.gpuDriverURL, "gs://") {
+		return fmt.Errorf("--gpu-driver-url must be an https:// or gs:// URL, got %q", cfg.gpuDriverURL)
+	}
+	if sum, err := hex.DecodeString(cfg.gpuDriverSHA256); err != nil || cfg.gpuDriverSHA256 != "" && len(sum) != sha256.Size {
+		return fmt.Errorf("--gpu-driver-sha256 must be 64 hex digits, got %q", cfg.gpuDriverSHA256)
+	}
+	return nil
+}
+
+// resolveProvider validates the platform/provider pair and fills in the
+// default provider. macOS guests can only run on Orka and Orka only serves
+// macOS, so the two must agree. The libvirt provider's fw_cfg bootstrap is
+// Linux-only.
+func resolveProvider(platform, provider string) (string, error) {
+	switch platform {
+	case "windows", "linux", "darwin":
+	default:
+		return "", fmt.Errorf("--platform must be 'windows', 'linux' or 'darwin', got %q", platform)
+	}
+	if provider == "" {
+		if platform == "darwin" {
+			return "orka", nil
+		}
+		return "gcp", nil
+	}
+	switch provider {
+	case "gcp":
+		if platform == "darwin" {
+			return "", fmt.Errorf("--platform=darwin requires --provider=orka (GCP cannot host macOS)")
+		}
+	case "orka":
+		if platform != "darwin" {
+			return "", fmt.Errorf("--provider=orka only supports --platform=darwin, got %q", platform)
+		}
+	case "libvirt":
+		if platform != "linux" {
+			return "", fmt.Errorf("--provider=libvirt only supports --platform=linux, got %q", platform)
+		}
+	default:
+		return "", fmt.Errorf("--provider must be 'gcp', 'orka' or 'libvirt', got %q", provider)
+	}
+	return provider, nil
+}
+
+// defaultVMPrefix returns the VM name prefix used when --vm-prefix is unset.
+func defaultVMPrefix(platform string) string {
+	switch platform {
+	case "linux":
+		return "linux-test"
+	case "darwin":
+		return "mac-test"
+	default:
+		return "win-test"
+	}
+}
+
+// vmProvider is the VM lifecycle surface the scaler drives. It is satisfied
+// by the GCP manager, the Orka manager for macOS pools and the libvirt
+// manager for on-premises lab machines.
+type vmProvider interface {
+	CreateVM(ctx context.Context, runnerName, jitConfig string) (string, error)
+	DeleteByRunnerName(ctx context.Context, runnerName string) error
+	DeleteAll(ctx context.Context)
+	MarkBusy(runnerName string)
+	ActiveCount() int
+	ActiveRunnerNames() []string
+	VMs() []vmstate.VM
+	Close()
+}
+
+// suspendable reports whether none of the pool's VMs get a GPU.
+func suspendable(cfg config) bool {
+	if cfg.gcpGPUType != "none" {
+		return false
+	}
+	for _, route := range cfg.routes {
+		if route.GPUType != "" && route.GPUType != "none" {
+			return false
+		}
+	}
+	for _, label := range cfg.sizeLabels {
+		if size, _, _ := gcpvm.ParseSizeLabel(label); size.GPU != "" && size.GPU != "none" {
+			return false
+		}
+	}
+	return true
+}
+
+// migCapable reports whether --mig-profile fits the GPU of the pool or of a
+// template route.
+func migCapable(cfg config) bool {
+	if gcpvm.MIGSlices(cfg.gcpGPUType, cfg.migProfile) > 0 {
+		return true
+	}
+	for _, route := range cfg.routes {
+		if gcpvm.MIGSlices(route.GPUType, cfg.migProfile) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// gcpManagerConfig maps the GCP flags onto the manager's configuration.
+func gcpManagerConfig(cfg config, vmPrefix string, script *startup.Script) gcpvm.ManagerConfig {
+	return gcpvm.ManagerConfig{
+		Project:              cfg.gcpProject,
+		Zones:                cfg.gcpZones,
+		Regions:              splitList(cfg.gcpRegions),
+		InstanceTemplate:     cfg.gcpInstanceTemplate,
+		JITConfigKMSKey:      cfg.jitConfigKMSKey,
+		GPUType:              cfg.gcpGPUType,
+		Platform:             cfg.gcpPlatform,
+		VMPrefix:             vmPrefix,
+		CleanupInterval:      cfg.gcpCleanupInterval,
+		OrphanGracePeriod:    cfg.orphanGracePeriod,
+		TemplateRoutes:       cfg.routes,
+		SizeLabels:           cfg.sizeLabels,
+		Metadata:             cfg.metadata,
+		Labels:               cfg.gcpLabels,
+		BootDiskSizeGB:       cfg.bootDiskSizeGB,
+		BootDiskType:         cfg.bootDiskType,
+		ImageFamily:          cfg.imageFamily,
+		GPUDriver:            cfg.gpuDriver(),
+		CacheDisks:           cfg.cacheDisks,
+		Reservations:         cfg.reservations,
+		GPUCheck:             cfg.gpuCheck,
+		BootPhases:           cfg.bootPhases,
+		ShutdownScript:       cfg.shutdownScript,
+		CacheDiskSizeGB:      cfg.cacheDiskSizeGB,
+		CacheDiskType:        cfg.cacheDiskType,
+		LocalSSDs:            cfg.localSSDs,
+		MIGProfile:           cfg.migProfile,
+		RunnerSlots:          cfg.runnerSlots,
+		Network:              cfg.network,
+		Subnetwork:           cfg.subnetwork,
+		NetworkTags:          splitList(cfg.networkTags),
+		NoExternalIP:         cfg.noExternalIP,
+		OrgPolicyCheck:       cfg.orgPolicyCheck,
+		AllowedMachineTypes:  splitList(cfg.allowedMachineTypes),
+		ManageFirewall:       cfg.manageFirewall,
+		ServiceAccount:       cfg.serviceAccount,
+		ServiceAccountScopes: splitList(cfg.serviceAccountScope),
+		StartupScript:        script,
+		ReuseVMs:             cfg.maxJobsPerVM > 1,
+		StoppedPoolSize:      max(cfg.stoppedPoolSize, cfg.suspendedPoolSize),
+		SuspendPool:          cfg.suspendedPoolSize > 0,
+		EstimateCost:         cfg.dailyBudgetUSD > 0 || cfg.idleCostAlertUSD > 0 || cfg.jobCosts,
+		BillingLabels:        cfg.billingLabels,
+		BreakerThreshold:     cfg.breakerThreshold,
+		BreakerBackoff:       cfg.breakerBackoff,
+		StockoutCooldown:     cfg.stockoutCooldown,
+		PreemptionWindow:     cfg.preemptionWindow,
+		StateFile:            cfg.stateFile,
+		RetryAttempts:        cfg.gcpRetryAttempts,
+		RetryBackoff:         cfg.gcpRetryBackoff,
+		APIRateLimit:         cfg.gcpAPIRateLimit,
+		QuotaCacheTTL:        cfg.gcpQuotaCacheTTL,
+
+		ImpersonateServiceAccount: cfg.impersonateSA,
+	}
+}
+
+func newVMProvider(ctx context.Context, cfg config, vmPrefix string) (vmProvider, error) {
+	var script *startup.Script
+	if cfg.startupScript != "" {
+		var err error
+		script, err = startup.Load(ctx, cfg.startupScript)
+		if err != nil {
+			return nil, err
+		}
+		var labels []string
+		for _, l := range cfg.buildLabels() {
+			labels = append(labels, l.Name)
+		}
+		script.Vars = startup.Vars{Labels: labels, ScaleSet: cfg.scaleSetName, Pool: vmPrefix}
+	}
+
+	switch cfg.provider {
+	case "libvirt":
+		mgr, err := libvirt.NewManager(ctx, libvirt.ManagerConfig{
+			URI:             cfg.libvirtURI,
+			BaseImage:       cfg.libvirtBaseImage,
+			ImageDir:        cfg.libvirtImageDir,
+			Network:         cfg.libvirtNetwork,
+			MemoryMiB:       cfg.libvirtMemoryMiB,
+			CPUs:            cfg.libvirtCPUs,
+			GPUs:            splitList(cfg.libvirtGPUs),
+			VMPrefix:        vmPrefix,
+			CleanupInterval: cfg.gcpCleanupInterval,
+			StartupScript:   script,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("creating libvirt VM manager: %w", err)
+		}
+		return mgr, nil
+	case "orka":
+		mgr, err := orka.NewManager(orka.ManagerConfig{
+			Endpoint:      cfg.orkaURL,
+			Token:         cfg.orkaToken,
+			Namespace:     cfg.orkaNamespace,
+			Image:         cfg.orkaImage,
+			CPU:           cfg.orkaCPU,
+			StartupScript: script,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("creating Orka VM manager: %w", err)
+		}
+		return mgr, nil
+	}
+
+	mgr, err := gcpvm.NewManager(ctx, gcpManagerConfig(cfg, vmPrefix, script))
+	if err != nil {
+		return nil, fmt.Errorf("creating GCP VM manager: %w", err)
+	}
+	return mgr, nil
+}
+
+func run(ctx context.Context, cfg config, logger *slog.Logger, logLevel *slog.LevelVar, history *eventHistory) error {
+	// The reloader compares against the flag/file settings, not the
+	// credentials fetched from secret stores below.
+	reloadBase := cfg
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = uuid.NewString()
+	}
+
+	// With --ha-lease, stand by until this replica is the leader, and stop
+	// as soon as it is not.
+	var election *leaderElection
+	if cfg.haLease != "" {
+		l, err := lease.New(ctx, cfg.haLease, hostname, cfg.haLeaseTTL)
+		if err != nil {
+			return fmt.Errorf("--ha-lease: %w", err)
+		}
+		election = &leaderElection{lease: l, ttl: cfg.haLeaseTTL, logger: logger, now: time.Now}
+		standbyCtx, stopStandby := context.WithCancel(ctx)
+		go runWatchdog(standbyCtx, election.live, logger)
+		err = election.wait(ctx)
+		stopStandby()
+		if err != nil {
+			return err
+		}
+		defer election.release(context.WithoutCancel(ctx))
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		defer cancel(nil)
+		go election.hold(ctx, func() { cancel(errLeadershipLost) })
+	}
+
+	creds, err := newCredentialRefresher(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+	if creds != nil {
+		if err := creds.load(ctx, &cfg); err != nil {
+			return fmt.Errorf("fetching credentials: %w", err)
+		}
+	}
+
 	// Create scaleset client
 	ssClient, err := cfg.scalesetClient()
 	if err != nil {
@@ -275,7 +1427,7 @@ func run(ctx context.Context, cfg config, logger *slog.Logger) error {
 		return fmt.Errorf("checking for existing scale set: %w", err)
 	}
 	if ss != nil {
-		logger.Info("reusing existing scale set", "name", ss.Name, "id", ss.ID)
+		logger.Info("reusing existing scale set", "event", eventScaleSetReady, "name", ss.Name, "id", ss.ID)
 		// Update labels in case they changed
 		ss, err = ssClient.UpdateRunnerScaleSet(ctx, ss.ID, &scaleset.RunnerScaleSet{
 			Name:          cfg.scaleSetName,
@@ -303,57 +1455,122 @@ func run(ctx context.Context, cfg config, logger *slog.Logger) error {
 	}
 
 	logger.Info("scale set created",
+		"event", eventScaleSetReady,
 		"name", ss.Name,
 		"id", ss.ID,
 		"labels", cfg.labels,
+		"user_labels", cfg.userLabels,
+		"template_routes", cfg.templateRoutes,
 	)
 
-	ssClient.SetSystemInfo(scaleset.SystemInfo{
-		System:     "gcp-runner-scaler",
-		Subsystem:  "scaler",
-		ScaleSetID: ss.ID,
-	})
+	ssClient.SetSystemInfo(systemInfo(ss.ID))
+	clientRef := &scalesetClientRef{client: ssClient}
+	if creds != nil {
+		supervise.Go(ctx, "credentials", func(ctx context.Context) { creds.run(ctx, cfg, clientRef) })
+	}
 
 	// Runner name prefix
 	vmPrefix := cfg.gcpVMPrefix
 	if vmPrefix == "" {
-		if cfg.gcpPlatform == "linux" {
-			vmPrefix = "linux-test"
-		} else {
-			vmPrefix = "win-test"
-		}
+		vmPrefix = defaultVMPrefix(cfg.gcpPlatform)
 	}
 
-	// Initialize GCP VM manager
-	vmManager, err := gcpvm.NewManager(ctx, gcpvm.ManagerConfig{
-		Project:           cfg.gcpProject,
-		Zones:             cfg.gcpZones,
-		InstanceTemplate:  cfg.gcpInstanceTemplate,
-		GPUType:           cfg.gcpGPUType,
-		Platform:          cfg.gcpPlatform,
-		VMPrefix:          vmPrefix,
-		CleanupInterval:   cfg.gcpCleanupInterval,
-		OrphanGracePeriod: cfg.orphanGracePeriod,
-	})
+	// Initialize the VM manager (GCP, or Orka for macOS pools)
+	vmManager, err := newVMProvider(ctx, cfg, vmPrefix)
 	if err != nil {
-		return fmt.Errorf("creating GCP VM manager: %w", err)
+		return err
 	}
 	defer vmManager.Close()
-
-	// Create message session
-	hostname, err := os.Hostname()
-	if err != nil {
-		hostname = uuid.NewString()
+	if fm, ok := vmManager.(firewallManager); ok && cfg.manageFirewall {
+		client, err := cfg.githubClient()
+		if err != nil {
+			return fmt.Errorf("--manage-firewall: %w", err)
+		}
+		if err := ensureFirewall(ctx, fm, client, logger); err != nil {
+			return fmt.Errorf("--manage-firewall: %w", err)
+		}
+	}
+	// A replica taking over from another leader adopts its VMs.
+	if adopter, ok := vmManager.(vmAdopter); ok && (cfg.adoptVMs || election != nil) {
+		client, err := cfg.githubClient()
+		if err != nil {
+			return fmt.Errorf("--adopt-vms: %w", err)
+		}
+		adoptVMs(ctx, adopter, client, vmPrefix, logger)
+	}
+	// The VMs restored from --state-file or adopted may have started or
+	// finished jobs since they were saved.
+	if syncer, ok := vmManager.(busySyncer); ok && vmManager.ActiveCount() > 0 && (cfg.stateFile != "" || cfg.adoptVMs || election != nil) {
+		client, err := cfg.githubClient()
+		if err != nil {
+			return fmt.Errorf("creating GitHub client: %w", err)
+		}
+		syncBusy(ctx, syncer, client, vmPrefix, logger)
 	}
 
+	// Create message session. A leader that died keeps its session until
+	// GitHub times it out, so a replica taking over retries for a while.
 	sessionClient, err := ssClient.MessageSessionClient(ctx, ss.ID, hostname)
+	for deadline := time.Now().Add(haSessionWait); err != nil && election != nil && time.Now().Before(deadline); {
+		logger.Warn("failed to create message session, retrying", "error", err)
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(haSessionRetry):
+		}
+		sessionClient, err = ssClient.MessageSessionClient(ctx, ss.ID, hostname)
+	}
 	if err != nil {
 		return fmt.Errorf("creating message session: %w", err)
 	}
-	defer sessionClient.Close(context.Background())
+	// A session that breaks later is replaced with a new one (see
+	// sessionRef), made with the current credentials.
+	session := newSessionRef(logger, sessionClient, func(ctx context.Context) (messageSession, error) {
+		s, err := clientRef.get().MessageSessionClient(ctx, ss.ID, hostname)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+	defer session.Close(context.Background())
+
+	// With template routes, size labels or priority labels, record each
+	// assigned job's runs-on labels so scale-up can pick the matching
+	// template and size, highest priority first.
+	var lstClient listener.Client = session
+	var jobs *assignedJobs
+	var claims *runnerClaims
+	if len(cfg.routes) > 0 || len(cfg.sizeLabels) > 0 || len(cfg.priorityLabels) > 0 {
+		jobs = &assignedJobs{priorities: cfg.priorityLabels}
+		lstClient = &labelRecordingClient{Client: session, jobs: jobs}
+	}
+	if cfg.recyclePriority {
+		claims = &runnerClaims{}
+	}
+	// With runner quotas, record each assigned job's repository and
+	// workflow until it completes.
+	var quotas *runnerQuotas
+	if len(cfg.runnerQuotas) > 0 {
+		quotas = newRunnerQuotas(cfg.runnerQuotas)
+		lstClient = &quotaRecordingClient{Client: lstClient, quotas: quotas}
+	}
+	// Record the jobs assigned as of each message, which the reconciler
+	// scales up to when a scale-up failed.
+	reconciler := newReconciler(cfg.reconcileInterval)
+	if reconciler != nil {
+		if stats := session.Session().Statistics; stats != nil {
+			reconciler.assigned.Store(int32(stats.TotalAssignedJobs))
+		}
+		lstClient = &demandRecordingClient{Client: lstClient, reconciler: reconciler}
+	}
+
+	// Record each poll so /healthz and the systemd watchdog can tell a
+	// wedged listener from a quiet one.
+	health := newHealthMonitor(cfg.healthMaxPollAge, vmManager, session.Session)
+	lstClient = &pollRecordingClient{Client: lstClient, health: health}
 
 	// Create listener
-	lst, err := listener.New(sessionClient, listener.Config{
+	lst, err := listener.New(lstClient, listener.Config{
 		ScaleSetID: ss.ID,
 		MaxRunners: cfg.maxRunners,
 		Logger:     logger.WithGroup("listener"),
@@ -366,15 +1583,143 @@ func run(ctx context.Context, cfg config, logger *slog.Logger) error {
 	gcpScaler := &gcpRunnerScaler{
 		logger:         logger.WithGroup("scaler"),
 		vmManager:      vmManager,
-		scalesetClient: ssClient,
+		scalesetClient: clientRef,
 		scaleSetID:     ss.ID,
 		maxRunners:     cfg.maxRunners,
 		minRunners:     cfg.minRunners,
 		vmPrefix:       vmPrefix,
+		assignedJobs:   jobs,
+		quotas:         quotas,
+		claims:         claims,
+		runners:        newRunnerSpans(),
+		activity:       newScalerActivity(),
+		latency:        newRunnerLatency(),
+		bootPhases:     cfg.bootPhases,
+
+		minRunnersSchedule: cfg.minRunnersSchedule,
+		scaleUpCooldown:    cfg.scaleUpCooldown,
+		createLimit:        newCreateLimiter(cfg.maxCreatesPerMinute, cfg.maxCreatesPerHour),
+		trend:              newQueueTrend(cfg.headroomPolicy),
+		handover:           election != nil,
+		drainTimeout:       cfg.drainTimeout,
+		drainTimeoutAction: cfg.drainTimeoutAction,
+		reconciler:         reconciler,
+	}
+	if cfg.breakerThreshold > 0 {
+		gcpScaler.breaker = breaker.New(cfg.breakerThreshold, cfg.breakerBackoff, breaker.MaxBackoff)
+	}
+	if cfg.dailyBudgetUSD > 0 {
+		gcpScaler.budget = newDailyBudget(cfg.dailyBudgetUSD)
+	}
+	if cfg.idleCostAlertUSD > 0 {
+		gcpScaler.idleCost = newIdleCost(cfg.idleCostAlertUSD)
+	}
+	if cfg.jobCosts {
+		gcpScaler.jobCosts = newJobCosts()
+	}
+	if labeler, ok := vmManager.(jobLabeler); ok && cfg.billingLabels {
+		gcpScaler.labeler = labeler
+	}
+	if cfg.fairShareDir != "" {
+		if err := os.MkdirAll(cfg.fairShareDir, 0o755); err != nil {
+			return fmt.Errorf("--fair-share-dir: %w", err)
+		}
+		gcpScaler.fairShare = newFairShare(cfg.fairShareDir, cfg.scaleSetName, cfg.gcpGPUType, cfg.fairShareWeight)
+	}
+	if cfg.maxJobsPerVM > 1 {
+		gcpScaler.reuse = &vmReuseLimits{maxJobs: cfg.maxJobsPerVM, maxAge: cfg.maxVMAge, grace: cfg.reuseGrace}
+	}
+	if cfg.auditLog != "" {
+		gcpScaler.auditLog, err = audit.Open(cfg.auditLog, int64(cfg.auditLogMaxSizeMB)<<20, cfg.auditLogMaxFiles)
+		if err != nil {
+			return fmt.Errorf("--audit-log: %w", err)
+		}
+		defer gcpScaler.auditLog.Close()
+	}
+	if cfg.notifyWebhook != "" {
+		gcpScaler.notifier = notify.New(cfg.notifyWebhook, cfg.scaleSetName, cfg.notifyInterval, logger.WithGroup("notify"))
+		supervise.Go(ctx, "stuck_boots", func(ctx context.Context) { gcpScaler.watchStuckBoots(ctx, cfg.notifyStuckBoot) })
+	}
+	if cfg.incidentProvider != "" {
+		pager, err := incident.New(cfg.incidentProvider, cfg.incidentKey, cfg.scaleSetName, "")
+		if err != nil {
+			return fmt.Errorf("--incident-provider: %w", err)
+		}
+		gcpScaler.stall = &createStall{}
+		w := &incidentWatcher{pager: pager, after: cfg.incidentAfter, stall: gcpScaler.stall, health: health, logger: logger.WithGroup("incident")}
+		supervise.Go(ctx, "incidents", w.run)
+	}
+	if cfg.annotateJobs {
+		client, err := cfg.githubClient()
+		if err != nil {
+			return fmt.Errorf("--annotate-jobs: %w", err)
+		}
+		gcpScaler.annotator = &jobAnnotator{client: client, logger: logger.WithGroup("annotate")}
+	}
+	if cfg.bootTimeout > 0 {
+		client, err := cfg.githubClient()
+		if err != nil {
+			return fmt.Errorf("--boot-timeout: %w", err)
+		}
+		w := &bootWatchdog{timeout: cfg.bootTimeout, status: client, logger: logger.WithGroup("boot"), online: make(map[string]time.Time)}
+		supervise.Go(ctx, "boot_timeout", func(ctx context.Context) { gcpScaler.watchBoots(ctx, w) })
+	}
+	if cfg.zombieTimeout > 0 {
+		client, err := cfg.githubClient()
+		if err != nil {
+			return fmt.Errorf("--zombie-timeout: %w", err)
+		}
+		w := &zombieReaper{bootWatchdog: bootWatchdog{timeout: cfg.zombieTimeout, status: client, logger: logger.WithGroup("zombie"), online: make(map[string]time.Time)}}
+		w.serial, _ = vmManager.(serialOutputReader)
+		supervise.Go(ctx, "zombies", func(ctx context.Context) { gcpScaler.watchZombies(ctx, w) })
+	}
+	if cfg.maxJobDuration > 0 {
+		w := &jobWatchdog{maxDuration: cfg.maxJobDuration, recycle: cfg.recycleStuckJobs, reported: make(map[string]bool)}
+		supervise.Go(ctx, "stuck_jobs", func(ctx context.Context) { gcpScaler.watchStuckJobs(ctx, w) })
+	}
+	if cfg.runnerSweepInterval > 0 {
+		client, err := cfg.githubClient()
+		if err != nil {
+			return fmt.Errorf("--runner-sweep-interval: %w", err)
+		}
+		w := &runnerSweeper{lister: client, logger: logger.WithGroup("runner_sweep")}
+		supervise.Go(ctx, "runner_sweep", func(ctx context.Context) { gcpScaler.watchOrphanRunners(ctx, w, cfg.runnerSweepInterval) })
+	}
+	if reader, ok := vmManager.(gpuCheckReader); ok && cfg.gpuCheck {
+		w := &gpuCheckWatcher{reader: reader, logger: logger.WithGroup("gpu_check"), passed: make(map[string]time.Time)}
+		supervise.Go(ctx, "gpu_check", func(ctx context.Context) { gcpScaler.watchGPUChecks(ctx, w) })
+	}
+	if notifier, ok := vmManager.(preemptionNotifier); ok {
+		gcpScaler.replacePreempted = cfg.replacePreempted
+		notifier.SetPreemptionHandler(func(runnerName string, busy bool) {
+			gcpScaler.handlePreempted(ctx, runnerName, busy)
+		})
+	}
+	if notifier, ok := vmManager.(shutdownNotifier); ok {
+		notifier.SetShutdownHandler(func(runnerName string, busy bool) {
+			gcpScaler.handleShutdown(ctx, runnerName, busy)
+		})
+	}
+	if cfg.metricsProject != "" {
+		exporter, err := cloudmetrics.New(ctx, cfg.metricsProject, cfg.scaleSetName)
+		if err != nil {
+			return fmt.Errorf("--metrics-project: %w", err)
+		}
+		supervise.Go(ctx, "metrics", func(ctx context.Context) { runMetricsExport(ctx, exporter, gcpScaler, cfg.metricsInterval, logger) })
+		logger.Info("exporting Cloud Monitoring metrics", "project", cfg.metricsProject, "interval", cfg.metricsInterval)
+	}
+	health.draining = gcpScaler.isDraining
+
+	go runWatchdog(ctx, func() bool { _, ok := health.live(); return ok }, logger)
+	if reconciler != nil {
+		supervise.Go(ctx, "reconciler", gcpScaler.runReconciler)
 	}
 
-	// Clean up scale set on exit, except after a graceful drain. A scaler
-	// that exits via drain mode (SIGUSR1, --session-max-age, or systemctl
+	// With --delete-scale-set-on-exit, clean up the scale set on exit,
+	// except after a graceful drain or with --ha-lease, where a standby
+	// takes the scale set over. By default it is always kept, so a scaler
+	// restarted after a crash reuses it, and `scaler teardown` deletes it.
+	// A scaler that exits via drain mode (SIGUSR1, --session-max-age, or systemctl
 	// reload) is being restarted, not decommissioned — preserving the scale
 	// set lets the next instance reuse the same ID via GetRunnerScaleSet
 	// above, so any in-flight runners keep their JIT registration valid.
@@ -385,13 +1730,13 @@ func run(ctx context.Context, cfg config, logger *slog.Logger) error {
 	// that LIFO ordering runs shutdown first; isDraining() then reflects
 	// the post-shutdown state.
 	defer func() {
-		if gcpScaler.isDraining() {
+		if !cfg.deleteScaleSet || gcpScaler.isDraining() || gcpScaler.handover {
 			logger.Info("preserving scale set for next scaler instance",
 				"id", ss.ID, "active_vms", vmManager.ActiveCount())
 			return
 		}
 		logger.Info("deleting scale set", "id", ss.ID)
-		if err := ssClient.DeleteRunnerScaleSet(context.WithoutCancel(ctx), ss.ID); err != nil {
+		if err := clientRef.get().DeleteRunnerScaleSet(context.WithoutCancel(ctx), ss.ID); err != nil {
 			logger.Error("failed to delete scale set", "error", err)
 		}
 	}()
@@ -402,18 +1747,26 @@ func run(ctx context.Context, cfg config, logger *slog.Logger) error {
 	//   2. Wait for "all VMs finished, exiting drain mode" in logs
 	//   3. Send SIGTERM (or: systemctl stop scaler-windows)
 	//   4. Replace binary, restart service
+	//
+	// With --drain-timeout, a drain still waiting for VMs at its deadline
+	// ends the run early (see enforceDrainTimeout).
+	runCtx, stopRun := context.WithCancelCause(ctx)
+	defer stopRun(nil)
 	var drainOnce sync.Once
 	requestDrain := func(reason string) {
 		drainOnce.Do(func() {
-			logger.Info("entering drain mode: no new jobs will be accepted, waiting for running VMs to finish", "reason", reason)
+			logger.Info("entering drain mode: no new jobs will be accepted, waiting for running VMs to finish", "event", eventDrainStarted, "reason", reason, "timeout", cfg.drainTimeout)
 			gcpScaler.setDraining(true)
 			lst.SetMaxRunners(0)
+			if cfg.drainTimeout > 0 {
+				go gcpScaler.enforceDrainTimeout(runCtx, stopRun)
+			}
 		})
 	}
 
 	drainCh := make(chan os.Signal, 1)
-	signal.Notify(drainCh, syscall.SIGUSR1)
-	defer signal.Stop(drainCh)
+	notifyDrain(drainCh)
+	defer stopNotify(drainCh)
 	go func() {
 		select {
 		case <-drainCh:
@@ -422,6 +1775,97 @@ func run(ctx context.Context, cfg config, logger *slog.Logger) error {
 		}
 	}()
 
+	admin := &adminAPI{
+		token:        cfg.adminToken,
+		logger:       logger,
+		scaleSet:     cfg.scaleSetName,
+		provider:     cfg.provider,
+		scaler:       gcpScaler,
+		listener:     lst,
+		requestDrain: requestDrain,
+		history:      history,
+		logLevel:     logLevel,
+	}
+	if cfg.provider == "gcp" {
+		admin.project = cfg.gcpProject
+	}
+	if cfg.controlSocket != "" {
+		if err := serveControlSocket(ctx, cfg.controlSocket, admin, logger); err != nil {
+			return fmt.Errorf("--control-socket: %w", err)
+		}
+		defer os.Remove(cfg.controlSocket)
+	}
+	if cfg.httpAddr != "" {
+		mux := http.NewServeMux()
+		health.register(mux)
+		dash := &dashboard{scaleSet: cfg.scaleSetName, provider: cfg.provider, scaler: gcpScaler, now: time.Now}
+		dash.register(mux)
+		if cfg.adminToken != "" {
+			admin.register(mux)
+		}
+		srv := &http.Server{Addr: cfg.httpAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("HTTP server failed", "addr", cfg.httpAddr, "error", err)
+			}
+		}()
+		defer srv.Shutdown(context.WithoutCancel(ctx))
+		logger.Info("serving HTTP endpoints", "addr", cfg.httpAddr, "admin_api", cfg.adminToken != "")
+	}
+
+	// SIGHUP re-reads the flags and --config file and applies the settings
+	// that can change live (see reloadableSettings) without dropping the
+	// message session.
+	reloader := &configReloader{
+		logger:   logger,
+		args:     os.Args[1:],
+		scaler:   gcpScaler,
+		listener: lst,
+		logLevel: logLevel,
+		updateLabels: func(ctx context.Context, labels []scaleset.Label) error {
+			_, err := clientRef.get().UpdateRunnerScaleSet(ctx, ss.ID, &scaleset.RunnerScaleSet{
+				Name:          cfg.scaleSetName,
+				RunnerGroupID: runnerGroupID,
+				Labels:        labels,
+				RunnerSetting: scaleset.RunnerSetting{
+					DisableUpdate: true,
+				},
+			})
+			return err
+		},
+		current: reloadBase,
+	}
+	reloadCh := make(chan os.Signal, 1)
+	notifyReload(reloadCh)
+	defer stopNotify(reloadCh)
+	go func() {
+		for {
+			select {
+			case <-reloadCh:
+				reloader.reload(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// SIGUSR2 cycles the log level (debug, info, warn, error) until the next
+	// restart or SIGHUP that changes --log-level, e.g. to capture a debug
+	// trace of zone selection without losing state.
+	levelCh := make(chan os.Signal, 1)
+	notifyLogLevel(levelCh)
+	defer stopNotify(levelCh)
+	go func() {
+		for {
+			select {
+			case <-levelCh:
+				logger.Warn("log level changed", "level", cycleLogLevel(logLevel), "source", "signal")
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
 	if cfg.sessionMaxAge > 0 {
 		go func() {
 			timer := time.NewTimer(cfg.sessionMaxAge)
@@ -435,29 +1879,139 @@ func run(ctx context.Context, cfg config, logger *slog.Logger) error {
 		logger.Info("session max age enabled", "duration", cfg.sessionMaxAge)
 	}
 
+	gcpScaler.removeAbandonedRunners(ctx)
 	defer gcpScaler.shutdown(context.WithoutCancel(ctx))
 
 	logger.Info("starting listener", "max_runners", cfg.maxRunners)
-	return lst.Run(ctx, gcpScaler)
+	// A broken message session is replaced, and a listener whose callback
+	// panicked is restarted after a backoff; either way the listener resumes
+	// from the session's job statistics.
+	panicBackoff := supervise.MinBackoff
+	for {
+		started := time.Now()
+		err = lst.Run(runCtx, recoveringScaler{gcpScaler})
+		if runCtx.Err() != nil {
+			break
+		}
+		var panicErr *supervise.PanicError
+		if errors.As(err, &panicErr) {
+			if time.Since(started) >= supervise.MaxBackoff {
+				panicBackoff = supervise.MinBackoff
+			}
+			logger.Warn("restarting the listener after a panic", "retry_in", panicBackoff)
+			select {
+			case <-runCtx.Done():
+				err = runCtx.Err()
+			case <-time.After(panicBackoff):
+			}
+			if runCtx.Err() != nil {
+				break
+			}
+			panicBackoff = min(2*panicBackoff, supervise.MaxBackoff)
+			continue
+		}
+		if !isSessionError(err) {
+			break
+		}
+		logger.Warn("message session broke, re-establishing it", "event", eventSessionLost, "error", err)
+		if err = session.reconnect(runCtx); err != nil {
+			break
+		}
+	}
+	if cause := context.Cause(runCtx); errors.Is(cause, errLeadershipLost) || errors.Is(cause, errDrainTimedOut) {
+		return cause
+	}
+	return err
 }
 
 // gcpRunnerScaler implements the listener.Scaler interface, creating and
 // deleting GCP VMs instead of Docker containers.
 type gcpRunnerScaler struct {
 	logger         *slog.Logger
-	vmManager      *gcpvm.Manager
-	scalesetClient *scaleset.Client
+	vmManager      vmProvider
+	scalesetClient *scalesetClientRef
 	scaleSetID     int
 	maxRunners     int
 	minRunners     int
 	vmPrefix       string
-
-	mu       sync.Mutex
-	draining bool
+	assignedJobs   *assignedJobs // nil unless --template-routes is set
+	quotas         *runnerQuotas // nil unless --runner-quotas is set
+	claims         *runnerClaims // nil unless --recycle-for-priority is set
+	runners        *runnerSpans
+	activity       *scalerActivity
+	latency        *runnerLatency
+	notifier       *notify.Notifier // nil unless --notify-webhook is set
+	annotator      *jobAnnotator    // nil unless --annotate-jobs is set
+	stall          *createStall     // nil unless --incident-provider is set
+	reuse          *vmReuseLimits   // nil unless --max-jobs-per-vm is above 1
+	jobCosts       *jobCosts        // nil unless --job-costs is set
+	labeler        jobLabeler       // nil unless --billing-labels is set
+	createFailures atomic.Int32
+	auditLog       *audit.Log // nil unless --audit-log is set
+	// minRunnersSchedule overrides minRunners during its windows.
+	minRunnersSchedule minRunnersSchedule
+	// scaleUpCooldown holds scale-ups for a while after lastRelease, when
+	// the scaler last deleted or stopped a VM.
+	scaleUpCooldown time.Duration
+	lastRelease     atomic.Int64 // Unix nanoseconds
+	// budget holds scale-ups once the day's projected VM spend exceeds
+	// --daily-budget-usd; nil without one.
+	budget *dailyBudget
+	// idleCost alerts when the day's idle VMs cost more than
+	// --idle-cost-alert-usd; nil without a threshold.
+	idleCost *idleCost
+	// createLimit caps how fast VMs are created; nil without a limit.
+	createLimit *createLimiter
+	// trend adds headroom to the target while the queue grows fast and caps
+	// scale-ups while it shrinks; nil without --headroom-policy.
+	trend *queueTrend
+	// breaker stops creates after --breaker-threshold failures in a row;
+	// nil without one.
+	breaker *breaker.Breaker
+	// fairShare caps the target at the pool's share of a GPU quota other
+	// pools use too; nil without --fair-share-dir.
+	fairShare *fairShare
+	// replacePreempted creates a VM in place of a preempted one.
+	replacePreempted bool
+	// bootPhases exports the runners in each boot phase as metrics.
+	bootPhases bool
+	// handover leaves the VMs to a standby on shutdown (--ha-lease).
+	handover bool
+	// drainTimeout ends a drain after that long with drainTimeoutAction
+	// (--drain-timeout); zero waits for the VMs however long they run.
+	drainTimeout       time.Duration
+	drainTimeoutAction string
+	// reconciler retries failed scale-ups; nil with --reconcile-interval=0.
+	reconciler *reconciler
+	// scaleMu serializes HandleDesiredRunnerCount between the listener and
+	// the reconciler.
+	scaleMu sync.Mutex
+	// creating counts the runners of scale-ups whose creates run in the
+	// background (see HandleDesiredRunnerCount), and creates waits for
+	// them.
+	creating atomic.Int32
+	creates  sync.WaitGroup
+
+	// Exported as Cloud Monitoring metrics.
+	desired             atomic.Int32
+	createsTotal        atomic.Int64
+	createFailuresTotal atomic.Int64
+
+	mu           sync.Mutex
+	draining     bool
+	drainStarted time.Time
+	paused       *pauseState // nil unless scale-ups are paused
+	// minOverride is the `scaler scale --min` override, which
+	// minOverrideTimer ends.
+	minOverride      *minRunnersOverride
+	minOverrideTimer *time.Timer
 }
 
 func (s *gcpRunnerScaler) setDraining(v bool) {
 	s.mu.Lock()
+	if v && !s.draining {
+		s.drainStarted = time.Now()
+	}
 	s.draining = v
 	s.mu.Unlock()
 }
@@ -468,26 +2022,199 @@ func (s *gcpRunnerScaler) isDraining() bool {
 	return s.draining
 }
 
+// setLimits updates the runner bounds; a SIGHUP config reload can change
+// them while the listener is running.
+func (s *gcpRunnerScaler) setLimits(maxRunners, minRunners int) {
+	s.mu.Lock()
+	s.maxRunners = maxRunners
+	s.minRunners = minRunners
+	s.mu.Unlock()
+}
+
+func (s *gcpRunnerScaler) limits() (maxRunners, minRunners int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxRunners, s.minRunners
+}
+
+// setMinRunnersSchedule replaces --min-runners-schedule; a SIGHUP config
+// reload can change it while the listener is running.
+func (s *gcpRunnerScaler) setMinRunnersSchedule(schedule minRunnersSchedule) {
+	s.mu.Lock()
+	s.minRunnersSchedule = schedule
+	s.mu.Unlock()
+}
+
+// minRunnersAt returns the runners to keep warm at t: that of a
+// `scaler scale --min` override in force, else the count of the
+// --min-runners-schedule window covering t, or else --min-runners.
+func (s *gcpRunnerScaler) minRunnersAt(t time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o := s.minOverride; o != nil && t.Before(o.Until) {
+		return o.MinRunners
+	}
+	if count, ok := s.minRunnersSchedule.at(t); ok {
+		return count
+	}
+	return s.minRunners
+}
+
+// released starts --scale-up-cooldown: the scaler deleted or stopped a VM
+// at now while the pool was above its desired size.
+func (s *gcpRunnerScaler) released(now time.Time) {
+	s.lastRelease.Store(now.UnixNano())
+}
+
+// coolingDown reports whether --scale-up-cooldown holds scale-ups at now.
+func (s *gcpRunnerScaler) coolingDown(now time.Time) bool {
+	last := s.lastRelease.Load()
+	return s.scaleUpCooldown > 0 && last != 0 && now.Sub(time.Unix(0, last)) < s.scaleUpCooldown
+}
+
 // HandleDesiredRunnerCount is called when the listener receives a new
 // desired runner count from the scale set API.
 func (s *gcpRunnerScaler) HandleDesiredRunnerCount(ctx context.Context, count int) (int, error) {
-	currentCount := s.vmManager.ActiveCount()
+	s.scaleMu.Lock()
+	defer s.scaleMu.Unlock()
+	currentCount := s.activeCount()
+	now := time.Now()
+	maxRunners, _ := s.limits()
+	minRunners := s.minRunnersAt(now)
+	rec := scaleAudit{
+		Time:        now,
+		PendingJobs: count,
+		Current:     currentCount,
+		MaxRunners:  maxRunners,
+		MinRunners:  minRunners,
+	}
 
 	if s.isDraining() {
+		s.desired.Store(0)
 		if currentCount == 0 {
-			s.logger.Info("all VMs finished, exiting drain mode")
+			s.logger.Info("all VMs finished, exiting drain mode", "event", eventDrainComplete)
+			s.notifier.Notify(ctx, notify.DrainComplete, "drain complete: all VMs finished, scaler exiting")
+			rec.Decision = decisionDrainComplete
+			s.recordDecision(rec)
 			return 0, errDrainComplete
 		}
 		s.logger.Info("draining", "active_vms", currentCount, "pending_jobs", count)
+		s.stall.clear()
+		rec.Decision, rec.Reason, rec.Result = decisionHold, "draining", currentCount
+		s.recordDecision(rec)
+		return currentCount, nil
+	}
+	if s.isPaused() {
+		s.desired.Store(int32(currentCount))
+		s.logger.Info("paused, not scaling up", "active_vms", currentCount, "pending_jobs", count)
+		s.stall.clear()
+		rec.Decision, rec.Reason, rec.Result = decisionHold, "paused", currentCount
+		s.recordDecision(rec)
 		return currentCount, nil
 	}
 
-	targetCount := min(s.maxRunners, s.minRunners+count)
+	// Jobs beyond their repository's or workflow's quota wait for a runner.
+	over, held := s.quotas.overQuota()
+	if over > 0 {
+		s.logger.Info("jobs held back by runner quotas", "pending_jobs", count, "over_quota", over, "quotas", held)
+		rec.OverQuota = over
+	}
+	demand := minRunners + max(count-over, 0)
+	trend := s.trend.observe(now, count)
+	if trend.headroom > 0 && demand < maxRunners {
+		s.logger.Info("queue growing fast, adding headroom", "jobs_per_minute", trend.perMinute, "headroom", trend.headroom)
+		rec.Headroom = min(trend.headroom, maxRunners-demand)
+		demand += rec.Headroom
+	}
+	targetCount := min(maxRunners, demand)
+	atFairShare := false
+	if share, ok := s.fairShareOf(now, targetCount); ok && targetCount > share {
+		s.logger.Info("capping the target at the pool's fair share of the GPU quota", "target", targetCount, "share", share)
+		targetCount, atFairShare = share, true
+	}
+	s.desired.Store(int32(targetCount))
+	if s.claims != nil && targetCount == currentCount && minRunners+count-over > maxRunners && s.recycleForPriority(ctx) {
+		// The recycled VM's place goes to the higher-priority job.
+		currentCount = s.activeCount()
+	}
+	rec.Target = targetCount
+	rec.Decision = decisionHold
+	budget := s.checkBudget(ctx, now)
+	s.checkIdleCost(ctx, now)
 
 	switch {
+	case targetCount > currentCount && budget.over():
+		s.logger.Info("over the daily budget, not scaling up", "current", currentCount, "target", targetCount, "projected_usd", budget.ProjectedUSD, "budget_usd", budget.LimitUSD)
+		rec.Reason = "over daily budget"
+	case targetCount > currentCount && s.coolingDown(now):
+		s.logger.Info("cooling down after a VM release, not scaling up yet", "current", currentCount, "target", targetCount)
+		rec.Reason = "cooling down after a VM release"
+	case targetCount > currentCount && trend.step == 0:
+		s.logger.Info("queue shrinking, not scaling up", "current", currentCount, "target", targetCount, "jobs_per_minute", trend.perMinute)
+		rec.Reason = "queue shrinking"
 	case targetCount > currentCount:
 		scaleUp := targetCount - currentCount
-		s.logger.Info("scaling up", "current", currentCount, "target", targetCount, "creating", scaleUp)
+		if trend.step > 0 && scaleUp > trend.step {
+			s.logger.Info("queue shrinking, scaling up conservatively", "target", targetCount, "creating", trend.step, "jobs_per_minute", trend.perMinute)
+			scaleUp = trend.step
+		}
+		plans := s.admitCreates(now, s.planVMs(ctx, scaleUp, maxRunners-currentCount))
+		if len(plans) == 0 {
+			s.logger.Info("backing off after failed creates, not scaling up", "current", currentCount, "target", targetCount, "until", s.breaker.Until())
+			rec.Reason = "backing off after failed creates"
+			break
+		}
+		if plans = s.throttleCreates(now, plans, &rec); len(plans) == 0 {
+			rec.Reason = "create rate limited"
+			break
+		}
+		s.logger.Info("scaling up", "event", eventScaleUp, "current", currentCount, "target", targetCount, "creating", scaleUp, "vms", len(plans))
+		rec.Decision = decisionScaleUp
+		s.createInBackground(ctx, rec, plans)
+		// Recorded once the creates finish.
+		return s.activeCount(), nil
+	case targetCount == currentCount:
+		// No scaling needed
+		s.stall.clear()
+		rec.Reason = "at target"
+		switch {
+		case minRunners+count > maxRunners:
+			rec.Reason = "at max runners"
+		case atFairShare:
+			rec.Reason = "at fair share"
+		case over > 0:
+			rec.Reason = "at runner quotas"
+		}
+	default:
+		// Scale-down is handled by HandleJobCompleted
+		s.stall.clear()
+		rec.Reason = "above target; VMs are deleted as their jobs complete"
+	}
+
+	rec.Result = s.activeCount()
+	s.recordDecision(rec)
+	return rec.Result, nil
+}
+
+// createInBackground creates the VMs of plans in a goroutine, so the
+// listener keeps polling while GCE provisions them, and records the
+// scale-up once they are all created or failed. The runners count as
+// active from now on (see activeCount).
+func (s *gcpRunnerScaler) createInBackground(ctx context.Context, rec scaleAudit, plans []vmPlan) {
+	runners := 0
+	for _, plan := range plans {
+		runners += plan.slots
+	}
+	s.creating.Add(int32(runners))
+	s.creates.Add(1)
+	go func() {
+		defer s.creates.Done()
+		defer supervise.Recover("scale_up")
+		ctx, span := tracer.Start(ctx, "scale_up", trace.WithAttributes(
+			attribute.Int("scaler.current", rec.Current),
+			attribute.Int("scaler.target", rec.Target),
+		))
+		defer span.End()
 
 		// Create the VMs concurrently. Each CreateVM blocks on the GCP insert
 		// operation (op.Wait), so doing them serially made a burst of N jobs
@@ -500,69 +2227,236 @@ func (s *gcpRunnerScaler) HandleDesiredRunnerCount(ctx context.Context, count in
 		const maxConcurrentCreates = 8
 		sem := make(chan struct{}, maxConcurrentCreates)
 		var wg sync.WaitGroup
-		for range scaleUp {
+		// Each create fills in its own slot, so no locking is needed.
+		outcomes := make([]vmOutcome, len(plans))
+		for i, plan := range plans {
 			sem <- struct{}{}
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
 				defer func() { <-sem }()
-
-				name := fmt.Sprintf("%s-%s", s.vmPrefix, uuid.NewString()[:8])
-
-				jit, err := s.scalesetClient.GenerateJitRunnerConfig(
-					ctx,
-					&scaleset.RunnerScaleSetJitRunnerSetting{Name: name},
-					s.scaleSetID,
-				)
-				if err != nil {
-					s.logger.Error("failed to generate JIT config", "error", err)
-					return
-				}
-
-				vmName, err := s.vmManager.CreateVM(ctx, name, jit.EncodedJITConfig)
-				if err != nil {
-					s.logger.Error("failed to create VM", "error", err)
-					// JIT config was generated (runner registered) but VM
-					// creation failed. Clean up the stale runner entry.
-					s.removeRunnerFromGitHub(ctx, name)
-					return
-				}
-
-				s.logger.Info("created runner VM", "vm", vmName, "runner", name)
+				outcomes[i] = s.createRunnerRecovered(ctx, plan)
+				// The provider tracks the runners now, or they failed.
+				s.creating.Add(-int32(plan.slots))
 			}()
 		}
 		wg.Wait()
-	case targetCount == currentCount:
-		// No scaling needed
-	default:
-		// Scale-down is handled by HandleJobCompleted
+		created := 0
+		for _, o := range outcomes {
+			if o.Error == "" {
+				created++
+			}
+		}
+		s.activity.scaled(scaleDecision{
+			Time:    rec.Time,
+			Pending: rec.PendingJobs,
+			Current: rec.Current,
+			Target:  rec.Target,
+			Created: created,
+			Failed:  len(plans) - created,
+		})
+		rec.VMs = outcomes
+		rec.Result = s.activeCount()
+		s.recordDecision(rec)
+	}()
+}
+
+// activeCount returns the number of runners tracked by the provider or
+// being created. A background create only shows up in the provider's count
+// once it has picked a zone, so until then it is counted from s.creating.
+func (s *gcpRunnerScaler) activeCount() int {
+	active := s.vmManager.ActiveCount()
+	creating := int(s.creating.Load())
+	if creating == 0 {
+		return active
+	}
+	pending := 0
+	for _, vm := range s.vmManager.VMs() {
+		if vm.Pending {
+			pending++
+		}
+	}
+	// Every pending create is one of ours, or comes on top of them.
+	return max(active, active-pending+creating)
+}
+
+// createRunner registers a new runner with GitHub and creates its VM, or,
+// for a plan with several slots, the runners sharing one VM.
+func (s *gcpRunnerScaler) createRunner(ctx context.Context, plan vmPlan) (outcome vmOutcome) {
+	if slotted, ok := s.vmManager.(slottedProvider); ok && plan.slots > 1 {
+		return s.createSlottedRunners(ctx, slotted, plan)
+	}
+	name := s.newRunnerName()
+	outcome.Runner = name
+	ctx, span := tracer.Start(ctx, "create_runner", trace.WithAttributes(attribute.String("runner.name", name)))
+	var err error
+	defer func() { endSpan(span, err) }()
+
+	jitCtx, jitSpan := tracer.Start(ctx, "github.GenerateJitRunnerConfig")
+	jit, err := s.scalesetClient.get().GenerateJitRunnerConfig(
+		jitCtx,
+		&scaleset.RunnerScaleSetJitRunnerSetting{Name: name},
+		s.scaleSetID,
+	)
+	endSpan(jitSpan, err)
+	if err != nil {
+		s.jitFailed(name, err)
+		outcome.Stage, outcome.Error = "jit_config", err.Error()
+		s.unclaimLabels(plan.labels)
+		return outcome
+	}
+	s.reconciler.jitSucceeded()
+	s.runnerRegistered(name, jit)
+	s.latency.registered(name, time.Now())
+
+	vmName, err := s.createVM(ctx, name, jit.EncodedJITConfig, plan.labels)
+	if err != nil {
+		s.logger.Error("failed to create VM", "event", eventVMCreateFailed, "runner", name, "error", err)
+		s.createFailed(ctx, err)
+		s.stall.failed(err, time.Now())
+		outcome.Stage, outcome.Error = "create_vm", err.Error()
+		s.latency.forget(name)
+		// JIT config was generated (runner registered) but VM
+		// creation failed. Clean up the stale runner entry.
+		s.removeRunnerFromGitHub(ctx, name)
+		return outcome
 	}
 
-	return s.vmManager.ActiveCount(), nil
+	s.runners.booting(ctx, name)
+	s.claims.set(name, plan.labels)
+	s.createSucceeded()
+	s.stall.clear()
+	outcome.VM = vmName
+	s.logger.Info("created runner VM", "event", eventVMCreated, "vm", vmName, "runner", name, "service_account", s.vmIdentity(ctx, plan.labels))
+	return outcome
+}
+
+// newRunnerName returns a fresh runner (and VM) name in the pool's prefix.
+// Its 48 random bits make a clash with a VM or runner left by an earlier
+// run unlikely, and a name the provider still tracks is never reused.
+func (s *gcpRunnerScaler) newRunnerName() string {
+	for {
+		id := strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+		name := fmt.Sprintf("%s-%s", s.vmPrefix, id)
+		if !s.nameInUse(name) {
+			return name
+		}
+	}
+}
+
+// nameInUse reports whether the provider tracks a VM or runner named name.
+func (s *gcpRunnerScaler) nameInUse(name string) bool {
+	for _, vm := range s.vmManager.VMs() {
+		if vm.Name == name || vm.RunnerName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// labelRoutedProvider is implemented by providers that can pick a VM
+// template and size from the job's runs-on labels.
+type labelRoutedProvider interface {
+	CreateVMForLabels(ctx context.Context, runnerName, jitConfig string, labels []string) (string, error)
+}
+
+// createVM creates a runner VM, routing it by labels, claimed by planVMs,
+// when template routes or size labels are configured.
+func (s *gcpRunnerScaler) createVM(ctx context.Context, runnerName, jitConfig string, labels []string) (string, error) {
+	routed, ok := s.vmManager.(labelRoutedProvider)
+	if s.assignedJobs == nil || !ok {
+		return s.vmManager.CreateVM(ctx, runnerName, jitConfig)
+	}
+	vmName, err := routed.CreateVMForLabels(ctx, runnerName, jitConfig, labels)
+	if err != nil {
+		s.unclaimLabels(labels)
+	}
+	return vmName, err
+}
+
+// claimLabels returns the labels of the oldest assigned job not yet covered
+// by a VM when template routes or size labels are configured.
+func (s *gcpRunnerScaler) claimLabels() []string {
+	if _, ok := s.vmManager.(labelRoutedProvider); !ok || s.assignedJobs == nil {
+		return nil
+	}
+	return s.assignedJobs.claim()
+}
+
+// unclaimLabels releases a claim after creating its VM failed, so the next
+// scale-up retries with the same labels.
+func (s *gcpRunnerScaler) unclaimLabels(labels []string) {
+	if s.assignedJobs != nil && labels != nil {
+		s.assignedJobs.unclaim(labels)
+	}
 }
 
 // HandleJobStarted is called when a job starts on one of our runners.
-func (s *gcpRunnerScaler) HandleJobStarted(_ context.Context, jobInfo *scaleset.JobStarted) error {
-	s.logger.Info("job started",
+func (s *gcpRunnerScaler) HandleJobStarted(ctx context.Context, jobInfo *scaleset.JobStarted) error {
+	now := time.Now()
+	boot, queue := s.latency.jobStarted(jobInfo, now)
+	attrs := []any{
+		"event", eventJobStarted,
 		"runner", jobInfo.RunnerName,
 		"job", jobInfo.JobDisplayName,
 		"workflow_run", jobInfo.WorkflowRunID,
-	)
+	}
+	if boot > 0 {
+		attrs = append(attrs, "boot_seconds", int(boot.Seconds()))
+	}
+	if queue > 0 {
+		attrs = append(attrs, "queue_seconds", int(queue.Seconds()))
+	}
+	s.logger.Info("job started", attrs...)
 	s.vmManager.MarkBusy(jobInfo.RunnerName)
+	s.claims.forget(jobInfo.RunnerName)
+	s.runners.jobStarted(ctx, jobInfo)
+	s.activity.jobStarted(jobInfo, now)
+	if s.assignedJobs != nil {
+		s.assignedJobs.forget(jobInfo.JobID)
+	}
+	if s.annotator != nil {
+		go s.annotateJob(context.WithoutCancel(ctx), jobInfo, boot)
+	}
+	if s.labeler != nil {
+		go s.labelJob(context.WithoutCancel(ctx), jobInfo)
+	}
 	return nil
 }
 
-// HandleJobCompleted is called when a job finishes. We delete the VM and
-// remove the runner from GitHub to prevent stale "offline" entries.
+// HandleJobCompleted is called when a job finishes. Unless the VM is reused
+// for another job, we delete it and remove the runner from GitHub to prevent
+// stale "offline" entries.
 func (s *gcpRunnerScaler) HandleJobCompleted(ctx context.Context, jobInfo *scaleset.JobCompleted) error {
 	s.logger.Info("job completed",
+		"event", eventJobCompleted,
 		"runner", jobInfo.RunnerName,
 		"result", jobInfo.Result,
 		"job", jobInfo.JobDisplayName,
 	)
-
-	if err := s.vmManager.DeleteByRunnerName(ctx, jobInfo.RunnerName); err != nil {
-		s.logger.Error("failed to delete VM after job completed", "runner", jobInfo.RunnerName, "error", err)
+	if s.assignedJobs != nil {
+		s.assignedJobs.forget(jobInfo.JobID)
+	}
+	s.quotas.forget(jobInfo.JobID)
+	s.claims.forget(jobInfo.RunnerName)
+	s.runners.finished(jobInfo.RunnerName, jobInfo.Result)
+	s.activity.jobFinished(jobInfo.RunnerName)
+	s.latency.forget(jobInfo.RunnerName)
+	s.recordJobCost(ctx, jobInfo, time.Now())
+
+	ctx, span := tracer.Start(ctx, "runner.cleanup", trace.WithAttributes(attribute.String("runner.name", jobInfo.RunnerName)))
+	defer span.End()
+
+	if s.reuseVM(ctx, jobInfo) {
+		return nil
+	}
+	// Only a VM the pool no longer needs starts --scale-up-cooldown; one
+	// freed at the desired size makes room for the next queued job.
+	surplus := s.activeCount() > int(s.desired.Load())
+	if err := s.releaseVM(ctx, jobInfo); err != nil {
+		s.logger.Error("failed to delete VM after job completed", "event", eventVMDeleteFailed, "runner", jobInfo.RunnerName, "error", err)
+	} else if surplus {
+		s.released(time.Now())
 	}
 
 	// Remove the runner from GitHub to prevent stale "offline" entries.
@@ -576,36 +2470,50 @@ func (s *gcpRunnerScaler) HandleJobCompleted(ctx context.Context, jobInfo *scale
 }
 
 // removeRunnerFromGitHub looks up a runner by name and removes it from
-// the GitHub Actions runner list.
-func (s *gcpRunnerScaler) removeRunnerFromGitHub(ctx context.Context, runnerName string) {
-	runner, err := s.scalesetClient.GetRunnerByName(ctx, runnerName)
+// the GitHub Actions runner list. It returns an error when the runner may
+// still be registered, e.g. because GitHub just assigned it a job; callers
+// deleting an idle runner's VM must then leave the VM alone.
+func (s *gcpRunnerScaler) removeRunnerFromGitHub(ctx context.Context, runnerName string) error {
+	runner, err := s.scalesetClient.get().GetRunnerByName(ctx, runnerName)
 	if err != nil {
 		s.logger.Warn("failed to look up runner for cleanup", "runner", runnerName, "error", err)
-		return
+		return err
 	}
 	if runner == nil {
 		s.logger.Info("runner already removed from GitHub", "runner", runnerName)
-		return
+		return nil
 	}
 
-	if err := s.scalesetClient.RemoveRunner(ctx, int64(runner.ID)); err != nil {
+	if err := s.scalesetClient.get().RemoveRunner(ctx, int64(runner.ID)); err != nil {
 		s.logger.Warn("failed to remove runner from GitHub", "runner", runnerName, "id", runner.ID, "error", err)
-		return
+		return err
 	}
 
-	s.logger.Info("removed runner from GitHub", "runner", runnerName, "id", runner.ID)
+	s.logger.Info("removed runner from GitHub", "event", eventRunnerRemoved, "runner", runnerName, "id", runner.ID)
+	return nil
 }
 
 func (s *gcpRunnerScaler) shutdown(ctx context.Context) {
+	// Creates still in flight would otherwise add VMs after DeleteAll.
+	s.creates.Wait()
+	s.fairShare.withdraw()
 	if s.isDraining() {
 		remaining := s.vmManager.ActiveCount()
 		if remaining > 0 {
-			s.logger.Info("shutdown while draining: leaving running VMs to finish", "remaining", remaining)
+			s.logger.Info("shutdown while draining: leaving running VMs to finish", "event", eventShutdown, "remaining", remaining)
 		}
 		return
 	}
-	s.logger.Info("shutting down, deleting all VMs and cleaning up runners")
+	if s.handover {
+		s.logger.Info("shutdown with --ha-lease: leaving VMs to the replica taking over", "event", eventShutdown, "remaining", s.vmManager.ActiveCount())
+		return
+	}
+	s.logger.Info("shutting down, deleting all VMs and cleaning up runners", "event", eventShutdown)
+	s.deleteAllVMs(ctx)
+}
 
+// deleteAllVMs deletes every VM and removes its runner from GitHub.
+func (s *gcpRunnerScaler) deleteAllVMs(ctx context.Context) {
 	// Get all tracked runner names before deleting VMs
 	runnerNames := s.vmManager.ActiveRunnerNames()
 
@@ -1,6 +1,15 @@
 package main
 
-import "testing"
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	gcpvm "extras/scaler/internal/gcp"
+	"extras/scaler/internal/vmstate"
+)
 
 func TestParseCleanupIntervalValid(t *testing.T) {
 	got, err := parseCleanupInterval("90s")
@@ -71,3 +80,443 @@ func TestDrainStateTransitions(t *testing.T) {
 		t.Fatal("setDraining(false) should make isDraining() false")
 	}
 }
+
+func TestResolveProviderDefaults(t *testing.T) {
+	tests := map[string]string{
+		"windows": "gcp",
+		"linux":   "gcp",
+		"darwin":  "orka",
+	}
+	for platform, want := range tests {
+		got, err := resolveProvider(platform, "")
+		if err != nil {
+			t.Fatalf("resolveProvider(%q, \"\") returned error: %v", platform, err)
+		}
+		if got != want {
+			t.Fatalf("resolveProvider(%q, \"\") = %q, want %q", platform, got, want)
+		}
+	}
+}
+
+func TestResolveProviderRejectsMismatches(t *testing.T) {
+	for _, tc := range []struct{ platform, provider string }{
+		{"darwin", "gcp"},
+		{"linux", "orka"},
+		{"windows", "azure"},
+		{"freebsd", ""},
+	} {
+		if _, err := resolveProvider(tc.platform, tc.provider); err == nil {
+			t.Fatalf("resolveProvider(%q, %q) should fail", tc.platform, tc.provider)
+		}
+	}
+}
+
+func TestDefaultVMPrefix(t *testing.T) {
+	tests := map[string]string{
+		"windows": "win-test",
+		"linux":   "linux-test",
+		"darwin":  "mac-test",
+	}
+	for platform, want := range tests {
+		if got := defaultVMPrefix(platform); got != want {
+			t.Fatalf("defaultVMPrefix(%q) = %q, want %q", platform, got, want)
+		}
+	}
+}
+
+func TestResolveProviderLibvirtIsLinuxOnly(t *testing.T) {
+	if got, err := resolveProvider("linux", "libvirt"); err != nil || got != "libvirt" {
+		t.Fatalf("resolveProvider(linux, libvirt) = %q, %v; want libvirt", got, err)
+	}
+	if _, err := resolveProvider("windows", "libvirt"); err == nil {
+		t.Fatal("resolveProvider(windows, libvirt) should fail")
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := splitList(" 0000:65:00.0,, 0000:b3:00.0 ")
+	if len(got) != 2 || got[0] != "0000:65:00.0" || got[1] != "0000:b3:00.0" {
+		t.Fatalf("splitList = %v, want [0000:65:00.0 0000:b3:00.0]", got)
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	got, err := parseKeyValues("team=gfx, cost-center=ci,empty=")
+	if err != nil {
+		t.Fatalf("parseKeyValues: %v", err)
+	}
+	if len(got) != 3 || got["team"] != "gfx" || got["cost-center"] != "ci" || got["empty"] != "" {
+		t.Fatalf("parseKeyValues = %v", got)
+	}
+	if got, err := parseKeyValues(""); err != nil || got != nil {
+		t.Fatalf("parseKeyValues(\"\") = %v, %v; want nil, nil", got, err)
+	}
+	for _, bad := range []string{"team", "=gfx", "team=a,team=b"} {
+		if _, err := parseKeyValues(bad); err == nil {
+			t.Fatalf("parseKeyValues(%q) should fail", bad)
+		}
+	}
+}
+
+func TestParseCacheDisks(t *testing.T) {
+	got, err := parseCacheDisks("us-east1-c=4, us-east1-d=2")
+	if err != nil {
+		t.Fatalf("parseCacheDisks: %v", err)
+	}
+	if len(got) != 2 || got["us-east1-c"] != 4 || got["us-east1-d"] != 2 {
+		t.Fatalf("parseCacheDisks = %v", got)
+	}
+	if got, err := parseCacheDisks(""); err != nil || got != nil {
+		t.Fatalf("parseCacheDisks(\"\") = %v, %v; want nil, nil", got, err)
+	}
+	for _, bad := range []string{"us-east1-c", "us-east1-c=0", "us-east1-c=two"} {
+		if _, err := parseCacheDisks(bad); err == nil {
+			t.Fatalf("parseCacheDisks(%q) should fail", bad)
+		}
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--cache-disks=us-east1-c=1", "--provider=libvirt", "--platform=linux"); err == nil {
+		t.Fatal("loadConfig should reject --cache-disks without --provider=gcp")
+	}
+}
+
+func TestParseReservations(t *testing.T) {
+	got, err := parseReservations("us-west1-a=t4-commit, us-west1-b=t4-commit-2")
+	if err != nil {
+		t.Fatalf("parseReservations: %v", err)
+	}
+	if len(got) != 2 || got["us-west1-a"] != "t4-commit" || got["us-west1-b"] != "t4-commit-2" {
+		t.Fatalf("parseReservations = %v", got)
+	}
+	for _, bad := range []string{"us-west1-a", "us-west1-a=", "us-west1-a=x,us-west1-a=y"} {
+		if _, err := parseReservations(bad); err == nil {
+			t.Fatalf("parseReservations(%q) should fail", bad)
+		}
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--reservations=us-west1-a=t4-commit", "--provider=libvirt", "--platform=linux"); err == nil {
+		t.Fatal("loadConfig should reject --reservations without --provider=gcp")
+	}
+}
+
+func TestLoadConfigValidatesLocalSSDs(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--local-ssds=2", "--provider=libvirt", "--platform=linux"); err == nil {
+		t.Fatal("loadConfig should reject --local-ssds without --provider=gcp")
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--local-ssds=2", "--suspended-pool-size=2", "--gcp-gpu-type=none"); err == nil {
+		t.Fatal("loadConfig should reject --local-ssds with a suspended pool")
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--local-ssds=2")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := gcpManagerConfig(cfg, "runner", nil).LocalSSDs; got != 2 {
+		t.Fatalf("LocalSSDs = %d, want 2", got)
+	}
+}
+
+func TestLoadConfigValidatesMIGProfile(t *testing.T) {
+	a100 := []string{"--url=https://github.com/o/r", "--platform=linux", "--gcp-gpu-type=nvidia-tesla-a100"}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--mig-profile=1g.5gb", "--gcp-gpu-type=nvidia-tesla-a100"); err == nil {
+		t.Fatal("loadConfig should reject --mig-profile on Windows")
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--platform=linux", "--mig-profile=1g.5gb"); err == nil {
+		t.Fatal("loadConfig should reject --mig-profile without an A100")
+	}
+	if _, err := testLoadConfig(t, append(a100, "--mig-profile=1g.5gb", "--max-jobs-per-vm=2")...); err == nil {
+		t.Fatal("loadConfig should reject --mig-profile with VM reuse")
+	}
+	cfg, err := testLoadConfig(t, append(a100, "--mig-profile=1g.5gb")...)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := gcpManagerConfig(cfg, "runner", nil).MIGProfile; got != "1g.5gb" {
+		t.Fatalf("MIGProfile = %q, want 1g.5gb", got)
+	}
+}
+
+func TestLoadConfigValidatesRunnerSlots(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--runner-slots=0"); err == nil {
+		t.Fatal("loadConfig should reject --runner-slots=0")
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--runner-slots=4", "--provider=libvirt", "--platform=linux"); err == nil {
+		t.Fatal("loadConfig should reject --runner-slots without --provider=gcp")
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--runner-slots=4", "--stopped-pool-size=2"); err == nil {
+		t.Fatal("loadConfig should reject --runner-slots with a stopped pool")
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--runner-slots=4")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := gcpManagerConfig(cfg, "runner", nil).RunnerSlots; got != 4 {
+		t.Fatalf("RunnerSlots = %d, want 4", got)
+	}
+}
+
+func TestLoadConfigValidatesBootPhases(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--boot-phases", "--provider=libvirt", "--platform=linux"); err == nil {
+		t.Fatal("loadConfig should reject --boot-phases without --provider=gcp")
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--boot-phases")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if !gcpManagerConfig(cfg, "runner", nil).BootPhases {
+		t.Fatal("BootPhases not passed to the manager")
+	}
+}
+
+func TestLoadConfigValidatesStateFile(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--state-file=/var/lib/scaler/state.json", "--provider=libvirt", "--platform=linux"); err == nil {
+		t.Fatal("loadConfig should reject --state-file without --provider=gcp")
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--state-file=/var/lib/scaler/state.json")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := gcpManagerConfig(cfg, "runner", nil).StateFile; got != "/var/lib/scaler/state.json" {
+		t.Fatalf("StateFile = %q", got)
+	}
+}
+
+func TestLoadConfigValidatesGCPRetry(t *testing.T) {
+	for _, arg := range []string{"--gcp-retry-attempts=0", "--gcp-retry-backoff=0s"} {
+		if _, err := testLoadConfig(t, "--url=https://github.com/o/r", arg); err == nil {
+			t.Errorf("loadConfig(%s) should fail", arg)
+		}
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--gcp-retry-attempts=6")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := gcpManagerConfig(cfg, "runner", nil); got.RetryAttempts != 6 || got.RetryBackoff != time.Second {
+		t.Fatalf("RetryAttempts, RetryBackoff = %d, %s", got.RetryAttempts, got.RetryBackoff)
+	}
+}
+
+func TestLoadConfigGCPAPIRateLimit(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--gcp-api-rate-limit=-1"); err == nil {
+		t.Fatal("loadConfig should reject a negative --gcp-api-rate-limit")
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--gcp-api-rate-limit=2.5")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := gcpManagerConfig(cfg, "runner", nil).APIRateLimit; got != 2.5 {
+		t.Fatalf("APIRateLimit = %v, want 2.5", got)
+	}
+}
+
+func TestLoadConfigGCPQuotaCacheTTL(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--gcp-quota-cache-ttl=-1s"); err == nil {
+		t.Fatal("loadConfig should reject a negative --gcp-quota-cache-ttl")
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := gcpManagerConfig(cfg, "runner", nil).QuotaCacheTTL; got != 30*time.Second {
+		t.Fatalf("QuotaCacheTTL = %s, want the 30s default", got)
+	}
+}
+
+func TestLoadConfigStockoutCooldown(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--gcp-stockout-cooldown=-1m"); err == nil {
+		t.Fatal("loadConfig should reject a negative --gcp-stockout-cooldown")
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := gcpManagerConfig(cfg, "runner", nil).StockoutCooldown; got != 10*time.Minute {
+		t.Fatalf("StockoutCooldown = %s, want the 10m default", got)
+	}
+}
+
+func TestLoadConfigPreemptionWindow(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--gcp-preemption-window=-1h"); err == nil {
+		t.Fatal("loadConfig should reject a negative --gcp-preemption-window")
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := gcpManagerConfig(cfg, "runner", nil).PreemptionWindow; got != 6*time.Hour {
+		t.Fatalf("PreemptionWindow = %s, want the 6h default", got)
+	}
+}
+
+func TestLoadConfigImpersonateServiceAccount(t *testing.T) {
+	for _, args := range [][]string{
+		{"--impersonate-service-account=ci-scaler"},
+		{"--impersonate-service-account=ci-scaler@p.iam.gserviceaccount.com", "--provider=libvirt", "--platform=linux"},
+	} {
+		if _, err := testLoadConfig(t, append([]string{"--url=https://github.com/o/r"}, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--impersonate-service-account=ci-scaler@p.iam.gserviceaccount.com")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := gcpManagerConfig(cfg, "runner", nil).ImpersonateServiceAccount; got != "ci-scaler@p.iam.gserviceaccount.com" {
+		t.Fatalf("ImpersonateServiceAccount = %q", got)
+	}
+}
+
+func TestLoadConfigCredentialFilesConflict(t *testing.T) {
+	for _, args := range [][]string{
+		{"--token-file=/run/secrets/token", "--token-secret=projects/p/secrets/token"},
+		{"--app-private-key-file=/run/secrets/key.pem", "--app-private-key-secret=projects/p/secrets/key"},
+		{"--env-file=/opt/scaler/scaler.env", "--vault-path=secret/data/ci/scaler"},
+	} {
+		if _, err := testLoadConfig(t, append([]string{"--url=https://github.com/o/r"}, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--env-file=/opt/scaler/scaler.env", "--token-file=/run/secrets/token"); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+}
+
+func TestLoadConfigNoExternalIP(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--no-external-ip", "--provider=libvirt", "--platform=linux"); err == nil {
+		t.Fatal("--no-external-ip should require --provider=gcp")
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--no-external-ip")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if !gcpManagerConfig(cfg, "runner", nil).NoExternalIP {
+		t.Fatal("NoExternalIP not passed to the manager")
+	}
+}
+
+func TestLoadConfigOrgPolicyCheck(t *testing.T) {
+	for _, args := range [][]string{
+		{"--org-policy-check", "--provider=libvirt", "--platform=linux"},
+		{"--allowed-machine-types=g2-standard-[8"},
+	} {
+		if _, err := testLoadConfig(t, append([]string{"--url=https://github.com/o/r"}, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--org-policy-check", "--allowed-machine-types=g2-standard-*, a2-highgpu-1g")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	mc := gcpManagerConfig(cfg, "runner", nil)
+	if !mc.OrgPolicyCheck || !slices.Equal(mc.AllowedMachineTypes, []string{"g2-standard-*", "a2-highgpu-1g"}) {
+		t.Fatalf("manager config = %v, %v", mc.OrgPolicyCheck, mc.AllowedMachineTypes)
+	}
+}
+
+func TestLoadConfigJITConfigKMSKey(t *testing.T) {
+	const key = "projects/p/locations/global/keyRings/ci/cryptoKeys/jit-config"
+	for _, args := range [][]string{
+		{"--jit-config-kms-key=jit-config"},
+		{"--jit-config-kms-key=" + key, "--provider=libvirt", "--platform=linux"},
+	} {
+		if _, err := testLoadConfig(t, append([]string{"--url=https://github.com/o/r"}, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--jit-config-kms-key="+key)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := gcpManagerConfig(cfg, "runner", nil).JITConfigKMSKey; got != key {
+		t.Fatalf("JITConfigKMSKey = %q", got)
+	}
+}
+
+func TestLoadConfigValidatesDrainTimeout(t *testing.T) {
+	for _, arg := range []string{"--drain-timeout=-1m", "--drain-timeout-action=kill"} {
+		if _, err := testLoadConfig(t, "--url=https://github.com/o/r", arg); err == nil {
+			t.Errorf("loadConfig(%s) should fail", arg)
+		}
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--drain-timeout=2h")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if cfg.drainTimeout != 2*time.Hour || cfg.drainTimeoutAction != drainTimeoutLeave {
+		t.Fatalf("drainTimeout, drainTimeoutAction = %s, %q, want 2h and leave", cfg.drainTimeout, cfg.drainTimeoutAction)
+	}
+}
+
+func TestLoadConfigValidatesImageFamily(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--image-family=images/slang-windows"); err == nil {
+		t.Fatal("loadConfig should reject a malformed --image-family")
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--image-family=slang-windows", "--provider=libvirt", "--platform=linux"); err == nil {
+		t.Fatal("loadConfig should reject --image-family without --provider=gcp")
+	}
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--image-family=projects/images-prod/global/images/family/slang-windows")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := gcpManagerConfig(cfg, "runner", nil).ImageFamily; got != "projects/images-prod/global/images/family/slang-windows" {
+		t.Fatalf("ImageFamily = %q", got)
+	}
+}
+
+func TestLoadConfigValidatesGPUDriver(t *testing.T) {
+	for _, args := range [][]string{
+		{"--gpu-driver-version=553.24"},
+		{"--gpu-driver-version=553.24", "--gpu-driver-url=http://example.com/553.24.exe"},
+		{"--gpu-driver-version=553.24", "--gpu-driver-url=gs://slang-drivers/553.24.exe", "--gpu-driver-sha256=abc"},
+		{"--gpu-driver-version=553.24", "--gpu-driver-url=gs://slang-drivers/553.24.exe", "--platform=linux"},
+	} {
+		if _, err := testLoadConfig(t, append([]string{"--url=https://github.com/o/r"}, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+	sum := strings.Repeat("AB", 32)
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--gpu-driver-version=553.24",
+		"--gpu-driver-url=gs://slang-drivers/553.24.exe", "--gpu-driver-sha256="+sum)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	want := gcpvm.GPUDriver{Version: "553.24", URL: "gs://slang-drivers/553.24.exe", SHA256: strings.Repeat("ab", 32)}
+	if got := gcpManagerConfig(cfg, "runner", nil).GPUDriver; got != want {
+		t.Fatalf("GPUDriver = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadConfigGCPRegionsExcludesZones(t *testing.T) {
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--gcp-regions=us-east1, us-west1")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := splitList(cfg.gcpRegions); !slices.Equal(got, []string{"us-east1", "us-west1"}) {
+		t.Fatalf("regions = %v", got)
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--gcp-regions=us-east1", "--gcp-zones=us-east1-c"); err == nil {
+		t.Fatal("loadConfig should reject --gcp-regions with an explicit --gcp-zones")
+	}
+}
+
+func TestBuildLabelsUserLabels(t *testing.T) {
+	cfg := config{labels: "Linux,self-hosted,GPU", userLabels: "benchmark, nightly,gpu,Nightly"}
+	var got []string
+	for _, l := range cfg.buildLabels() {
+		got = append(got, l.Name+"/"+l.Type)
+	}
+	want := []string{"Linux/System", "self-hosted/System", "GPU/System", "benchmark/User", "nightly/User"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("labels = %v, want %v", got, want)
+	}
+}
+
+func TestNewRunnerName(t *testing.T) {
+	s := &gcpRunnerScaler{vmPrefix: "win-test", vmManager: &fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-test-0123456789ab", Name: "win-test-0123456789ab"},
+	}}}
+	name := s.newRunnerName()
+	if !regexp.MustCompile(`^win-test-[0-9a-f]{12}$`).MatchString(name) {
+		t.Fatalf("newRunnerName() = %q, want the prefix and 12 hex digits", name)
+	}
+	if !s.nameInUse("win-test-0123456789ab") || s.nameInUse(name) {
+		t.Fatal("nameInUse should match the tracked VM only")
+	}
+}
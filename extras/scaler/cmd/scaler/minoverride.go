@@ -0,0 +1,66 @@
+package main
+
+import (
+	"time"
+)
+
+// minRunnersOverride pins the runners to keep warm until Until, e.g. ahead
+// of a planned load spike, over --min-runners and --min-runners-schedule.
+type minRunnersOverride struct {
+	MinRunners int       `json:"min_runners"`
+	Since      time.Time `json:"since"`
+	Until      time.Time `json:"until"`
+}
+
+// overrideMinRunners keeps minRunners warm for d, after which the
+// configured policy applies again. It replaces an earlier override.
+func (s *gcpRunnerScaler) overrideMinRunners(minRunners int, d time.Duration) minRunnersOverride {
+	now := time.Now()
+	o := minRunnersOverride{MinRunners: minRunners, Since: now, Until: now.Add(d)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.minOverrideTimer != nil {
+		s.minOverrideTimer.Stop()
+	}
+	s.minOverride = &o
+	s.minOverrideTimer = time.AfterFunc(d, func() { s.endMinRunnersOverride(&o) })
+	return o
+}
+
+// endMinRunnersOverride ends o when it is still the current override.
+func (s *gcpRunnerScaler) endMinRunnersOverride(o *minRunnersOverride) {
+	s.mu.Lock()
+	current := s.minOverride == o
+	if current {
+		s.minOverride, s.minOverrideTimer = nil, nil
+	}
+	s.mu.Unlock()
+	if current {
+		s.logger.Info("min runners override ended, back to the configured minimum",
+			"event", eventMinRunnersOverrideEnded, "min_runners", o.MinRunners, "min_runners_now", s.minRunnersAt(time.Now()))
+	}
+}
+
+// clearMinRunnersOverride ends the override now; it reports whether there
+// was one.
+func (s *gcpRunnerScaler) clearMinRunnersOverride() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.minOverride == nil {
+		return false
+	}
+	s.minOverrideTimer.Stop()
+	s.minOverride, s.minOverrideTimer = nil, nil
+	return true
+}
+
+// minRunnersOverrideAt returns the override in force at t, or nil.
+func (s *gcpRunnerScaler) minRunnersOverrideAt(t time.Time) *minRunnersOverride {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o := s.minOverride; o != nil && t.Before(o.Until) {
+		c := *o
+		return &c
+	}
+	return nil
+}
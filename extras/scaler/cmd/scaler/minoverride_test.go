@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for a logger writing from a timer.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestMinRunnersOverride(t *testing.T) {
+	schedule, err := parseMinRunnersSchedule("3 daily 00:00-23:59")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &gcpRunnerScaler{logger: slog.New(slog.NewTextHandler(io.Discard, nil)), minRunners: 1, minRunnersSchedule: schedule}
+	now := time.Now()
+
+	o := s.overrideMinRunners(6, time.Hour)
+	if got := s.minRunnersAt(now); got != 6 {
+		t.Fatalf("minRunnersAt = %d, want the override over the schedule", got)
+	}
+	if got := s.minRunnersOverrideAt(o.Until.Add(time.Second)); got != nil {
+		t.Fatalf("override after it ended = %+v, want nil", got)
+	}
+	if got := s.minRunnersAt(o.Until.Add(time.Second)); got == 6 {
+		t.Fatal("minRunnersAt after the override ended still returns it")
+	}
+
+	if !s.clearMinRunnersOverride() || s.minRunnersAt(now) == 6 {
+		t.Fatal("clearing did not end the override")
+	}
+	if s.clearMinRunnersOverride() {
+		t.Fatal("cleared an override twice")
+	}
+}
+
+func TestMinRunnersOverrideEnds(t *testing.T) {
+	var logs syncBuffer
+	s := &gcpRunnerScaler{logger: slog.New(slog.NewTextHandler(&logs, nil)), minRunners: 1}
+
+	// A replaced override's timer does not end its replacement.
+	s.overrideMinRunners(4, 10*time.Millisecond)
+	s.overrideMinRunners(5, time.Hour)
+	time.Sleep(50 * time.Millisecond)
+	if got := s.minRunnersAt(time.Now()); got != 5 {
+		t.Fatalf("minRunnersAt = %d, want the replacement's 5", got)
+	}
+
+	s.overrideMinRunners(4, 10*time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(logs.String(), "event=min_runners_override_ended") {
+		if time.Now().After(deadline) {
+			t.Fatalf("override did not end; logs:\n%s", logs.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := s.minRunnersAt(time.Now()); got != 1 {
+		t.Fatalf("minRunnersAt = %d, want --min-runners back", got)
+	}
+}
+
+func TestAdminOverrideMinRunners(t *testing.T) {
+	a, mux, _ := newTestAdmin()
+	a.scaler.logger = a.logger
+
+	for _, body := range []string{`{"min_runners": 2}`, `{"min_runners": -1, "duration": "1h"}`, `{"min_runners": 9, "duration": "1h"}`, `{"duration": "1h"}`} {
+		if code, _ := adminRequest(t, mux, http.MethodPut, "/api/v1/min-runners", body); code != http.StatusBadRequest {
+			t.Errorf("PUT %s: status %d, want 400", body, code)
+		}
+	}
+
+	_, st := adminRequest(t, mux, http.MethodPut, "/api/v1/min-runners", `{"min_runners": 3, "duration": "2h"}`)
+	if st.MinRunners != 3 || st.MinRunnersOverride == nil || st.MinRunnersOverride.Until.Sub(st.MinRunnersOverride.Since) != 2*time.Hour {
+		t.Fatalf("min runners = %d, override %+v; want 3 for 2h", st.MinRunners, st.MinRunnersOverride)
+	}
+	if _, st := adminRequest(t, mux, http.MethodDelete, "/api/v1/min-runners", ""); st.MinRunners != 1 || st.MinRunnersOverride != nil {
+		t.Fatalf("after reset: min runners = %d, override %+v; want --min-runners", st.MinRunners, st.MinRunnersOverride)
+	}
+	if code, _ := adminRequest(t, mux, http.MethodDelete, "/api/v1/min-runners", ""); code != http.StatusNotFound {
+		t.Fatalf("reset without an override: status %d, want 404", code)
+	}
+}
+
+func TestParseUntil(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	for v, want := range map[string]time.Duration{
+		"2h":                        2 * time.Hour,
+		"2026-10-16T17:30:00Z":      8*time.Hour + 30*time.Minute,
+		"2026-10-16T12:00:00-04:00": 7 * time.Hour,
+	} {
+		if got, err := parseUntil(v, now); err != nil || got != want {
+			t.Errorf("parseUntil(%q) = %s, %v; want %s", v, got, err, want)
+		}
+	}
+	for _, v := range []string{"0s", "-1h", "tomorrow", "2026-10-16T08:00:00Z"} {
+		if _, err := parseUntil(v, now); err == nil {
+			t.Errorf("parseUntil(%q) should fail", v)
+		}
+	}
+}
+
+func TestPrintStatusShowsMinRunnersOverride(t *testing.T) {
+	now := time.Now()
+	var out bytes.Buffer
+	printStatus(&out, adminStatus{ScaleSet: "windows-gpu", Provider: "gcp", MinRunners: 4, MinRunnersOverride: &minRunnersOverride{
+		MinRunners: 4, Since: now, Until: now.Add(2 * time.Hour),
+	}}, now)
+	if want := "Min runners pinned at 4 for 2h0m0s more\n"; !strings.Contains(out.String(), want) {
+		t.Fatalf("output missing %q:\n%s", want, out.String())
+	}
+}
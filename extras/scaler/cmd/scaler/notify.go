@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	gcpvm "extras/scaler/internal/gcp"
+	"extras/scaler/internal/notify"
+)
+
+const (
+	// createFailureThreshold is the number of consecutive failed VM creates
+	// that raises an alert; single failures are routine stockouts.
+	createFailureThreshold = 3
+	// stuckBootCheckInterval is how often watchStuckBoots scans the VMs.
+	stuckBootCheckInterval = time.Minute
+)
+
+// createSucceeded counts a VM create and resets the consecutive failure
+// count.
+func (s *gcpRunnerScaler) createSucceeded() {
+	s.createFailures.Store(0)
+	s.createsTotal.Add(1)
+	s.breaker.Success()
+}
+
+// createFailed counts a failed VM create and alerts on quota exhaustion or
+// once createFailureThreshold creates in a row have failed.
+func (s *gcpRunnerScaler) createFailed(ctx context.Context, err error) {
+	n := s.createFailures.Add(1)
+	s.createFailuresTotal.Add(1)
+	if until, opened := s.breaker.Failure(time.Now()); opened {
+		s.logger.Warn("VM creates keep failing, backing off", "event", eventCreateBackoff, "failures", n, "until", until)
+	}
+	switch {
+	case errors.Is(err, gcpvm.ErrNoQuota):
+		s.notifier.Notify(ctx, notify.QuotaExhausted, fmt.Sprintf("GPU quota exhausted in every configured region: %v", err))
+	case n >= createFailureThreshold:
+		s.notifier.Notify(ctx, notify.CreateFailures, fmt.Sprintf("%d consecutive VM creates failed; last error: %v", n, err))
+	}
+}
+
+// watchStuckBoots alerts on VMs that have not started a job stuckAfter after
+// creation, once per VM. Several newly stuck VMs share one alert.
+func (s *gcpRunnerScaler) watchStuckBoots(ctx context.Context, stuckAfter time.Duration) {
+	reported := make(map[string]bool)
+	ticker := time.NewTicker(stuckBootCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.checkStuckBoots(ctx, stuckAfter, time.Now(), reported)
+	}
+}
+
+func (s *gcpRunnerScaler) checkStuckBoots(ctx context.Context, stuckAfter time.Duration, now time.Time, reported map[string]bool) {
+	current := make(map[string]bool)
+	var stuck []string
+	for _, vm := range s.vmManager.VMs() {
+		current[vm.RunnerName] = true
+		since := vmBootStart(vm)
+		if vm.Pending || vm.Busy || since.IsZero() || now.Sub(since) < stuckAfter || reported[vm.RunnerName] {
+			continue
+		}
+		reported[vm.RunnerName] = true
+		desc := vm.RunnerName
+		if vm.Location != "" {
+			desc += " (" + vm.Location + ")"
+		}
+		stuck = append(stuck, desc)
+	}
+	for name := range reported {
+		if !current[name] {
+			delete(reported, name)
+		}
+	}
+	if len(stuck) > 0 {
+		s.notifier.Notify(ctx, notify.StuckBooting, fmt.Sprintf("%d VMs have not started a job %s after creation: %s",
+			len(stuck), stuckAfter, strings.Join(stuck, ", ")))
+	}
+}
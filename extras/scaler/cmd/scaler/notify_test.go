@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gcpvm "extras/scaler/internal/gcp"
+	"extras/scaler/internal/notify"
+	"extras/scaler/internal/vmstate"
+)
+
+// webhookRecorder collects the kind and text of every alert posted to it.
+type webhookRecorder struct {
+	mu     sync.Mutex
+	alerts []string
+}
+
+func (w *webhookRecorder) ServeHTTP(_ http.ResponseWriter, r *http.Request) {
+	var body struct{ Kind, Text string }
+	json.NewDecoder(r.Body).Decode(&body)
+	w.mu.Lock()
+	w.alerts = append(w.alerts, body.Kind+": "+body.Text)
+	w.mu.Unlock()
+}
+
+func newNotifyingScaler(t *testing.T, provider vmProvider) (*gcpRunnerScaler, *webhookRecorder) {
+	t.Helper()
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return &gcpRunnerScaler{
+		logger:    logger,
+		vmManager: provider,
+		notifier:  notify.New(srv.URL, "windows-gpu", time.Hour, logger),
+	}, rec
+}
+
+func TestCreateFailedAlerts(t *testing.T) {
+	s, rec := newNotifyingScaler(t, nil)
+	ctx := context.Background()
+
+	stockout := errors.New("ZONE_RESOURCE_POOL_EXHAUSTED")
+	s.createFailed(ctx, stockout)
+	s.createFailed(ctx, stockout)
+	s.createSucceeded()
+	s.createFailed(ctx, stockout)
+	s.createFailed(ctx, stockout)
+	if len(rec.alerts) != 0 {
+		t.Fatalf("alerts = %q, want none below the threshold", rec.alerts)
+	}
+	s.createFailed(ctx, stockout)
+	s.createFailed(ctx, fmt.Errorf("selecting zones: %w", gcpvm.ErrNoQuota))
+
+	want := []string{
+		"create_failures: [windows-gpu] 3 consecutive VM creates failed; last error: ZONE_RESOURCE_POOL_EXHAUSTED",
+		"quota_exhausted: [windows-gpu] GPU quota exhausted in every configured region: selecting zones: no GPU quota available in any configured region",
+	}
+	if strings.Join(rec.alerts, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("alerts = %q, want %q", rec.alerts, want)
+	}
+}
+
+func TestCheckStuckBoots(t *testing.T) {
+	now := time.Now()
+	provider := &fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-old", Location: "us-east1-c", CreatedAt: now.Add(-25 * time.Minute)},
+		{RunnerName: "win-busy", Busy: true, CreatedAt: now.Add(-25 * time.Minute)},
+		{RunnerName: "win-new", CreatedAt: now.Add(-5 * time.Minute)},
+		{RunnerName: "win-pending", Pending: true},
+	}}
+	s, rec := newNotifyingScaler(t, provider)
+	reported := make(map[string]bool)
+
+	s.checkStuckBoots(context.Background(), 20*time.Minute, now, reported)
+	want := "stuck_booting: [windows-gpu] 1 VMs have not started a job 20m0s after creation: win-old (us-east1-c)"
+	if len(rec.alerts) != 1 || rec.alerts[0] != want {
+		t.Fatalf("alerts = %q, want %q", rec.alerts, want)
+	}
+	if !reported["win-old"] || len(reported) != 1 {
+		t.Fatalf("reported = %v, want only win-old", reported)
+	}
+
+	provider.vms = provider.vms[1:]
+	s.checkStuckBoots(context.Background(), 20*time.Minute, now, reported)
+	if len(reported) != 0 {
+		t.Fatalf("reported = %v, want deleted VMs forgotten", reported)
+	}
+}
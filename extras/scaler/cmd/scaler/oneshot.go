@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	gcpvm "extras/scaler/internal/gcp"
+)
+
+// onceReconciler is implemented by providers that can make one cleanup
+// pass for a pool they know nothing of yet (see `scaler reconcile --once`).
+type onceReconciler interface {
+	vmProvider
+	ReconcileOnce(ctx context.Context, runners map[string]bool) gcpvm.ReconcileResult
+	AdoptVMs(ctx context.Context, runners map[string]bool) int
+}
+
+// newOnceReconciler creates the provider of a one-shot reconcile; a
+// variable for tests.
+var newOnceReconciler = func(ctx context.Context, cfg config, vmPrefix string) (onceReconciler, error) {
+	mcfg := gcpManagerConfig(cfg, vmPrefix, nil)
+	mcfg.OneShot = true
+	mgr, err := gcpvm.NewManager(ctx, mcfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating GCP VM manager: %w", err)
+	}
+	return mgr, nil
+}
+
+// reconcileOptions are the flags of `scaler reconcile` besides the
+// scaler's own.
+type reconcileOptions struct {
+	once    bool
+	confirm time.Duration
+}
+
+func (o *reconcileOptions) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.once, "once", false, "Make one reconciliation pass and exit")
+	fs.DurationVar(&o.confirm, "runner-confirm-delay", 2*time.Minute, "How long an offline runner without a VM must stay so before it is removed")
+}
+
+// runReconcile implements `scaler reconcile --once [flags]`. It takes the
+// same flags and config file as the service and makes one pass of the
+// cleanup the service does in the background, then exits, for cron or a
+// Kubernetes Job to run next to the service or while it is down.
+func runReconcile(ctx context.Context, args []string, out io.Writer) error {
+	var o reconcileOptions
+	fs := flag.NewFlagSet("scaler reconcile", flag.ContinueOnError)
+	o.register(fs)
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if !o.once {
+		return errors.New("scaler reconcile requires --once: the service reconciles continuously")
+	}
+	if cfg.provider != "gcp" {
+		return errors.New("scaler reconcile requires --provider=gcp")
+	}
+	if o.confirm < 0 {
+		return errors.New("--runner-confirm-delay must not be negative")
+	}
+	logger := slog.Default()
+	creds, err := newCredentialRefresher(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+	if creds != nil {
+		if err := creds.load(ctx, &cfg); err != nil {
+			return fmt.Errorf("fetching credentials: %w", err)
+		}
+	}
+	ssClient, err := cfg.scalesetClient()
+	if err != nil {
+		return fmt.Errorf("creating scaleset client: %w", err)
+	}
+	ghClient, err := cfg.githubClient()
+	if err != nil {
+		return fmt.Errorf("creating GitHub client: %w", err)
+	}
+	vmPrefix := cfg.gcpVMPrefix
+	if vmPrefix == "" {
+		vmPrefix = defaultVMPrefix(cfg.gcpPlatform)
+	}
+	rec, err := newOnceReconciler(ctx, cfg, vmPrefix)
+	if err != nil {
+		return err
+	}
+	defer rec.Close()
+
+	s := &gcpRunnerScaler{
+		logger:         logger,
+		vmManager:      rec,
+		scalesetClient: &scalesetClientRef{client: ssClient},
+		vmPrefix:       vmPrefix,
+	}
+	return reconcileOnce(ctx, s, rec, ghClient, runnersNamedAfterVMs(cfg), o.confirm, out)
+}
+
+// reconcileOnce has rec track the pool's live VMs whose runners lister
+// lists and delete its terminated and orphaned VMs, then, with sweep, removes
+// the pool's offline runners without a VM. Like the service's runner sweep
+// it only removes a runner found by two sweeps, confirm apart, and adopts
+// the VMs created in between before the second, so the runner of a VM
+// that a running scaler is creating is left alone.
+func reconcileOnce(ctx context.Context, s *gcpRunnerScaler, rec onceReconciler, lister runnerLister, sweep bool, confirm time.Duration, out io.Writer) error {
+	registered, err := poolRunners(ctx, lister, s.vmPrefix)
+	if err != nil {
+		return fmt.Errorf("listing GitHub runners: %w", err)
+	}
+	res := rec.ReconcileOnce(ctx, registered)
+	fmt.Fprintf(out, "adopted %d live VMs, deleted %d terminated and %d orphaned VMs\n", res.Adopted, res.Terminated, res.Orphans)
+	if !sweep {
+		fmt.Fprintln(out, "not removing offline runners: the pool's runners are not all named after their VMs")
+		return nil
+	}
+
+	w := &runnerSweeper{lister: lister, logger: s.logger}
+	s.sweepOrphanRunners(ctx, w)
+	removed := 0
+	if len(w.suspects) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(confirm):
+		}
+		registered, err := poolRunners(ctx, lister, s.vmPrefix)
+		if err != nil {
+			return fmt.Errorf("listing GitHub runners: %w", err)
+		}
+		rec.AdoptVMs(ctx, registered)
+		removed = s.sweepOrphanRunners(ctx, w)
+	}
+	fmt.Fprintf(out, "removed %d offline runners without a VM\n", removed)
+	return nil
+}
+
+// runnersNamedAfterVMs reports whether every runner of the pool runs on a
+// VM named after it, which is how a one-shot reconcile, tracking only the
+// VMs it adopts, tells the runners without a VM. VMs with several runners,
+// reused VMs and VMs started again from the stopped pool run runners of
+// other names.
+func runnersNamedAfterVMs(cfg config) bool {
+	return cfg.runnerSlots <= 1 && cfg.migProfile == "" && cfg.maxJobsPerVM <= 1 && cfg.stoppedPoolSize == 0 && cfg.suspendedPoolSize == 0
+}
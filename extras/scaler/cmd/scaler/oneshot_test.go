@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	gcpvm "extras/scaler/internal/gcp"
+	"extras/scaler/internal/github"
+	"extras/scaler/internal/vmstate"
+)
+
+// fakeOnceReconciler adopts the VMs of the runners it is given and, on a
+// later AdoptVMs, the VMs in created.
+type fakeOnceReconciler struct {
+	fakeAdminProvider
+	runners map[string]bool
+	created []string
+}
+
+func (r *fakeOnceReconciler) ReconcileOnce(_ context.Context, runners map[string]bool) gcpvm.ReconcileResult {
+	r.runners = runners
+	r.vms = append(r.vms, vmstate.VM{RunnerName: "win-test-running", Name: "win-test-running"})
+	return gcpvm.ReconcileResult{Adopted: 1, Terminated: 2}
+}
+
+func (r *fakeOnceReconciler) AdoptVMs(_ context.Context, runners map[string]bool) int {
+	adopted := 0
+	for _, name := range r.created {
+		if _, ok := runners[name]; ok {
+			r.vms = append(r.vms, vmstate.VM{RunnerName: name, Name: name})
+			adopted++
+		}
+	}
+	r.created = nil
+	return adopted
+}
+
+func TestReconcileOnce(t *testing.T) {
+	rec := &fakeOnceReconciler{}
+	client, actions := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      rec,
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "win-test",
+	}
+	lister := &fakeRunnerLister{runners: []github.Runner{
+		{Name: "win-test-running", Status: "offline"},
+		{Name: "win-test-lost", Status: "offline"},
+		{Name: "linux-test-lost", Status: "offline"},
+	}}
+
+	var out bytes.Buffer
+	if err := reconcileOnce(context.Background(), s, rec, lister, true, 0, &out); err != nil {
+		t.Fatalf("reconcileOnce: %v", err)
+	}
+	if len(rec.runners) != 2 || rec.runners["linux-test-lost"] {
+		t.Fatalf("reconciled with runners %v, want the pool's", rec.runners)
+	}
+	if len(actions.removed) != 1 || !strings.Contains(out.String(), "removed 1 offline runners") {
+		t.Fatalf("removed %v, output:\n%s\nwant win-test-lost removed", actions.removed, out.String())
+	}
+
+	actions.removed = nil
+	out.Reset()
+	if err := reconcileOnce(context.Background(), s, rec, lister, false, 0, &out); err != nil {
+		t.Fatalf("reconcileOnce: %v", err)
+	}
+	if len(actions.removed) != 0 || !strings.Contains(out.String(), "not removing offline runners") {
+		t.Fatalf("removed %v, output:\n%s\nwant no sweep", actions.removed, out.String())
+	}
+}
+
+func TestReconcileOnceSparesVMsCreatedBetweenSweeps(t *testing.T) {
+	// A running scaler creates win-test-booting after the first sweep; its
+	// runner stays offline while the VM boots.
+	rec := &fakeOnceReconciler{created: []string{"win-test-booting"}}
+	client, actions := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      rec,
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "win-test",
+	}
+	lister := &fakeRunnerLister{runners: []github.Runner{
+		{Name: "win-test-booting", Status: "offline"},
+		{Name: "win-test-lost", Status: "offline"},
+	}}
+
+	var out bytes.Buffer
+	if err := reconcileOnce(context.Background(), s, rec, lister, true, 0, &out); err != nil {
+		t.Fatalf("reconcileOnce: %v", err)
+	}
+	if len(actions.removed) != 1 || !strings.Contains(out.String(), "removed 1 offline runners") {
+		t.Fatalf("removed %v, output:\n%s\nwant only win-test-lost removed", actions.removed, out.String())
+	}
+}
+
+func TestRunReconcileRequiresOnce(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--url=https://github.com/o/r", "--token=t"}, "requires --once"},
+		{[]string{"--url=https://github.com/o/r", "--token=t", "--once", "--provider=orka", "--platform=darwin", "--orka-url=http://orka", "--orka-token=t", "--orka-image=sonoma"}, "requires --provider=gcp"},
+	} {
+		err := runReconcile(context.Background(), tc.args, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("runReconcile(%v) = %v, want %q", tc.args, err, tc.want)
+		}
+	}
+}
+
+func TestRunnersNamedAfterVMs(t *testing.T) {
+	if !runnersNamedAfterVMs(config{runnerSlots: 1, maxJobsPerVM: 1}) {
+		t.Fatal("a plain pool names its VMs after their runners")
+	}
+	for _, cfg := range []config{{runnerSlots: 2}, {migProfile: "3g.20gb"}, {maxJobsPerVM: 4}, {stoppedPoolSize: 2}} {
+		if runnersNamedAfterVMs(cfg) {
+			t.Errorf("runnersNamedAfterVMs(%+v) = true", cfg)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// runnerSweeper removes the pool's runners that GitHub lists as offline but
+// no VM runs, e.g. those of the VMs of a crashed scaler or host. Ephemeral
+// runners unregister themselves after their job, but a runner whose VM went
+// away first stays listed as offline for good.
+type runnerSweeper struct {
+	lister runnerLister
+	logger *slog.Logger
+	// suspects are the orphans the previous sweep found. A runner is only
+	// removed when two sweeps in a row find it, so one registered for a VM
+	// still being created is left alone.
+	suspects map[string]bool
+}
+
+func (s *gcpRunnerScaler) watchOrphanRunners(ctx context.Context, w *runnerSweeper, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.sweepOrphanRunners(ctx, w)
+	}
+}
+
+// sweepOrphanRunners removes the offline runners named with the pool's
+// prefix that the provider does not track and the previous sweep found
+// too. It returns the number removed.
+func (s *gcpRunnerScaler) sweepOrphanRunners(ctx context.Context, w *runnerSweeper) int {
+	runners, err := w.lister.Runners(ctx)
+	if err != nil {
+		w.logger.Warn("failed to list GitHub runners, not removing orphans", "error", err)
+		return 0
+	}
+	tracked := make(map[string]bool)
+	for _, vm := range s.vmManager.VMs() {
+		tracked[vm.RunnerName] = true
+	}
+	suspects := make(map[string]bool)
+	removed := 0
+	for _, r := range runners {
+		if !strings.HasPrefix(r.Name, s.vmPrefix+"-") || r.Status != "offline" || tracked[r.Name] {
+			continue
+		}
+		if !w.suspects[r.Name] {
+			suspects[r.Name] = true
+			continue
+		}
+		w.logger.Info("removing offline runner without a VM", "runner", r.Name)
+		s.removeRunnerFromGitHub(ctx, r.Name)
+		removed++
+	}
+	w.suspects = suspects
+	return removed
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"extras/scaler/internal/github"
+	"extras/scaler/internal/vmstate"
+)
+
+func TestSweepOrphanRunnersRemovesOfflineRunnersWithoutVMs(t *testing.T) {
+	provider := &fakeAdminProvider{vms: []vmstate.VM{{RunnerName: "win-test-booting", Pending: true}}}
+	client, actions := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      provider,
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "win-test",
+	}
+	lister := &fakeRunnerLister{runners: []github.Runner{
+		{Name: "win-test-lost", Status: "offline"},
+		{Name: "win-test-booting", Status: "offline"},
+		{Name: "win-test-running", Status: "online", Busy: true},
+		{Name: "linux-test-lost", Status: "offline"},
+	}}
+	w := &runnerSweeper{lister: lister, logger: s.logger}
+
+	if n := s.sweepOrphanRunners(context.Background(), w); n != 0 {
+		t.Fatalf("first sweep removed %d runners, want none until the second", n)
+	}
+	if n := s.sweepOrphanRunners(context.Background(), w); n != 1 || len(actions.removed) != 1 {
+		t.Fatalf("second sweep removed %d runners (%v), want win-test-lost", n, actions.removed)
+	}
+
+	// A runner that comes back online before the second sweep is kept.
+	lister.runners = []github.Runner{{Name: "win-test-flaky", Status: "offline"}}
+	s.sweepOrphanRunners(context.Background(), w)
+	lister.runners = []github.Runner{{Name: "win-test-flaky", Status: "online"}}
+	s.sweepOrphanRunners(context.Background(), w)
+	lister.runners = []github.Runner{{Name: "win-test-flaky", Status: "offline"}}
+	if n := s.sweepOrphanRunners(context.Background(), w); n != 0 {
+		t.Fatalf("sweep removed a runner seen online since the last sweep")
+	}
+
+	lister.err = errors.New("403 Forbidden")
+	if n := s.sweepOrphanRunners(context.Background(), w); n != 0 {
+		t.Fatal("a failed runner list should remove nothing")
+	}
+}
+
+func TestRunnerSweepIntervalIsValidated(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--runner-sweep-interval=-1m"); err == nil {
+		t.Fatal("loadConfig should reject a negative --runner-sweep-interval")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"time"
+)
+
+// pauseState is why, since when and until when scale-ups are paused.
+type pauseState struct {
+	Since time.Time `json:"since"`
+	// Until is when the pause ends by itself; nil pauses until a resume.
+	Until  *time.Time `json:"until,omitempty"`
+	Reason string     `json:"reason,omitempty"`
+}
+
+// pauseScaleUps stops scale-ups, for d or until resumed when d is zero.
+// Unlike draining it is reversible and the scaler keeps running: jobs
+// already on VMs finish and their VMs are deleted as usual, and cleanup
+// goes on. Pausing again replaces the reason and end of an earlier pause
+// but keeps its start.
+func (s *gcpRunnerScaler) pauseScaleUps(reason string, d time.Duration) pauseState {
+	now := time.Now()
+	p := pauseState{Since: now, Reason: reason}
+	if d > 0 {
+		until := now.Add(d)
+		p.Until = &until
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused != nil {
+		p.Since = s.paused.Since
+	}
+	s.paused = &p
+	return p
+}
+
+// setPaused pauses scale-ups until resumed, or resumes them.
+func (s *gcpRunnerScaler) setPaused(v bool) {
+	if v {
+		s.pauseScaleUps("", 0)
+		return
+	}
+	s.mu.Lock()
+	s.paused = nil
+	s.mu.Unlock()
+}
+
+// pauseStatus returns the current pause, or nil when scale-ups are not
+// paused. A pause whose Until has passed ends here.
+func (s *gcpRunnerScaler) pauseStatus() *pauseState {
+	now := time.Now()
+	s.mu.Lock()
+	p := s.paused
+	expired := p != nil && p.Until != nil && !now.Before(*p.Until)
+	if expired {
+		s.paused = nil
+	}
+	s.mu.Unlock()
+	if expired {
+		s.logger.Info("pause ended, scale-ups resumed", "event", eventPauseEnded,
+			"paused_for", now.Sub(p.Since).Round(time.Second), "reason", p.Reason)
+		return nil
+	}
+	if p == nil {
+		return nil
+	}
+	c := *p
+	return &c
+}
+
+func (s *gcpRunnerScaler) isPaused() bool {
+	return s.pauseStatus() != nil
+}
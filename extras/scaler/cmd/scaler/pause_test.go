@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"extras/scaler/internal/vmstate"
+)
+
+func TestPauseEndsAfterDuration(t *testing.T) {
+	var logs bytes.Buffer
+	s := &gcpRunnerScaler{logger: slog.New(slog.NewTextHandler(&logs, nil))}
+
+	first := s.pauseScaleUps("", 0)
+	p := s.pauseScaleUps("GCP maintenance", time.Hour)
+	if !p.Since.Equal(first.Since) || p.Reason != "GCP maintenance" || p.Until == nil {
+		t.Fatalf("pause = %+v, want the first pause's start with the new reason and end", p)
+	}
+	if !s.isPaused() {
+		t.Fatal("not paused before the pause ended")
+	}
+
+	past := time.Now().Add(-time.Second)
+	s.paused.Until = &past
+	if s.isPaused() {
+		t.Fatal("still paused after the pause ended")
+	}
+	if !strings.Contains(logs.String(), "event=pause_ended") {
+		t.Fatalf("logs missing pause_ended:\n%s", logs.String())
+	}
+
+	s.pauseScaleUps("", 0)
+	s.setPaused(false)
+	if s.isPaused() {
+		t.Fatal("resume did not end the pause")
+	}
+}
+
+func TestAdminPauseWithReasonAndDuration(t *testing.T) {
+	a, mux, _ := newTestAdmin()
+	a.scaler.logger = a.logger
+
+	for _, body := range []string{`{"duration": "soon"}`, `{"duration": "-1h"}`, `{"reason": 3}`} {
+		if code, _ := adminRequest(t, mux, http.MethodPost, "/api/v1/pause", body); code != http.StatusBadRequest {
+			t.Errorf("pause %s: status %d, want 400", body, code)
+		}
+	}
+	if a.scaler.isPaused() {
+		t.Fatal("a rejected pause paused scale-ups")
+	}
+
+	_, st := adminRequest(t, mux, http.MethodPost, "/api/v1/pause", `{"reason": "GCP maintenance", "duration": "2h"}`)
+	if !st.Paused || st.Pause == nil || st.Pause.Reason != "GCP maintenance" || st.Pause.Until == nil {
+		t.Fatalf("status = paused %v, pause %+v; want the reason and an end", st.Paused, st.Pause)
+	}
+	if d := st.Pause.Until.Sub(st.Pause.Since); d != 2*time.Hour {
+		t.Fatalf("pause lasts %s, want 2h", d)
+	}
+	if _, st := adminRequest(t, mux, http.MethodPost, "/api/v1/resume", ""); st.Paused || st.Pause != nil {
+		t.Fatalf("after resume: paused %v, pause %+v", st.Paused, st.Pause)
+	}
+}
+
+func TestReplaceRunnerVMWhilePausedOnlyDeletes(t *testing.T) {
+	provider := &zombieProvider{fakeAdminProvider: fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-stuck", Name: "win-stuck", CreatedAt: time.Now().Add(-time.Hour)},
+	}}}
+	client, actions := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      provider,
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "win",
+	}
+	s.pauseScaleUps("incident", 0)
+
+	s.replaceRunnerVM(context.Background(), "win-stuck", "boot_timeout")
+
+	if len(provider.deleted) != 1 || provider.deleted[0] != "win-stuck" {
+		t.Fatalf("deleted = %v, want win-stuck", provider.deleted)
+	}
+	if len(actions.registered) != 0 {
+		t.Fatalf("registered %v while paused, want no replacement", actions.registered)
+	}
+	if len(actions.removed) != 1 {
+		t.Fatalf("removed = %v, want the stuck runner's registration", actions.removed)
+	}
+}
+
+func TestPrintStatusShowsPause(t *testing.T) {
+	now := time.Now()
+	until := now.Add(90 * time.Minute)
+	var out bytes.Buffer
+	printStatus(&out, adminStatus{ScaleSet: "linux-gpu", Provider: "gcp", Paused: true, Pause: &pauseState{
+		Since: now.Add(-10 * time.Minute), Until: &until, Reason: "GCP maintenance",
+	}}, now)
+	if want := "Paused for 10m0s (GCP maintenance); resumes in 1h30m0s\n"; !strings.Contains(out.String(), want) {
+		t.Fatalf("output missing %q:\n%s", want, out.String())
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+)
+
+// preemptionNotifier is implemented by providers that notice spot VMs being
+// preempted before their runner's job ends.
+type preemptionNotifier interface {
+	SetPreemptionHandler(handler func(runnerName string, busy bool))
+}
+
+// shutdownNotifier is implemented by providers whose VMs report being
+// stopped with their runner running (see --shutdown-script).
+type shutdownNotifier interface {
+	SetShutdownHandler(handler func(runnerName string, busy bool))
+}
+
+// handlePreempted forgets a runner whose VM was preempted and removes its
+// registration, so GitHub does not wait for the runner-lost timeout before
+// failing the job it was running. With --replace-preempted a VM is created
+// in its place while the pool is below its desired size.
+func (s *gcpRunnerScaler) handlePreempted(ctx context.Context, runnerName string, busy bool) {
+	s.latency.forget(runnerName)
+	s.runners.finished(runnerName, "preempted")
+	s.activity.jobFinished(runnerName)
+	s.removeRunnerFromGitHub(ctx, runnerName)
+
+	if !s.replacePreempted || s.isDraining() || s.isPaused() {
+		return
+	}
+	active := s.activeCount()
+	if active >= int(s.desired.Load()) {
+		return
+	}
+	maxRunners, _ := s.limits()
+	outcome := s.createRunner(ctx, s.planVMs(ctx, 1, maxRunners-active)[0])
+	if outcome.VM != "" {
+		s.logger.Info("replaced preempted VM", "runner", outcome.Runner, "replaces", runnerName, "busy", busy)
+	}
+}
+
+// handleShutdown forgets a runner whose VM was stopped under it and removes
+// its registration, which would otherwise linger offline in GitHub.
+func (s *gcpRunnerScaler) handleShutdown(ctx context.Context, runnerName string, busy bool) {
+	s.latency.forget(runnerName)
+	s.runners.finished(runnerName, "shut down")
+	s.activity.jobFinished(runnerName)
+	s.removeRunnerFromGitHub(ctx, runnerName)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"extras/scaler/internal/vmstate"
+)
+
+// preemptProvider is a provider whose created VMs are counted as active.
+type preemptProvider struct {
+	fakeAdminProvider
+	created []string
+}
+
+func (p *preemptProvider) ActiveCount() int { return len(p.vms) + len(p.created) }
+
+func (p *preemptProvider) CreateVM(_ context.Context, runnerName, _ string) (string, error) {
+	p.created = append(p.created, runnerName)
+	return runnerName, nil
+}
+
+func newPreemptScaler(t *testing.T, replace bool) (*gcpRunnerScaler, *preemptProvider, *fakeActions) {
+	t.Helper()
+	provider := &preemptProvider{fakeAdminProvider: fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "spot-1", Busy: true},
+	}}}
+	client, actions := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:        provider,
+		scalesetClient:   client,
+		scaleSetID:       1,
+		vmPrefix:         "spot",
+		replacePreempted: replace,
+	}
+	s.desired.Store(2)
+	return s, provider, actions
+}
+
+func TestHandlePreemptedReplacesVMBelowDesired(t *testing.T) {
+	s, provider, actions := newPreemptScaler(t, true)
+
+	s.handlePreempted(context.Background(), "spot-2", true)
+
+	if len(actions.removed) != 1 {
+		t.Fatalf("removed = %v, want the preempted runner's registration", actions.removed)
+	}
+	if len(provider.created) != 1 || len(actions.registered) != 1 || actions.registered[0] != provider.created[0] {
+		t.Fatalf("created = %v, registered = %v, want one replacement runner", provider.created, actions.registered)
+	}
+
+	// The pool is back at its desired size.
+	s.handlePreempted(context.Background(), "spot-3", false)
+	if len(provider.created) != 1 {
+		t.Fatalf("created = %v, want no replacement at the desired size", provider.created)
+	}
+}
+
+func TestHandlePreemptedWithoutReplace(t *testing.T) {
+	s, provider, actions := newPreemptScaler(t, false)
+
+	s.handlePreempted(context.Background(), "spot-2", true)
+
+	if len(actions.removed) != 1 {
+		t.Fatalf("removed = %v, want the preempted runner's registration", actions.removed)
+	}
+	if len(provider.created) != 0 {
+		t.Fatalf("created = %v, want no replacement without --replace-preempted", provider.created)
+	}
+}
+
+func TestHandleShutdownRemovesRegistration(t *testing.T) {
+	s, provider, actions := newPreemptScaler(t, true)
+
+	s.handleShutdown(context.Background(), "spot-2", true)
+
+	if len(actions.removed) != 1 {
+		t.Fatalf("removed = %v, want the stopped runner's registration", actions.removed)
+	}
+	if len(provider.created) != 0 {
+		t.Fatalf("created = %v, want no replacement for a stopped VM", provider.created)
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--shutdown-script", "--provider=libvirt", "--platform=linux"); err == nil {
+		t.Fatal("loadConfig should reject --shutdown-script without --provider=gcp")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// parsePriorityLabels parses --priority-labels, the runs-on labels marking
+// job priority classes, highest first, e.g. "priority-high,priority-normal".
+func parsePriorityLabels(v string) ([]string, error) {
+	labels := splitList(v)
+	seen := make(map[string]bool, len(labels))
+	for _, l := range labels {
+		if seen[strings.ToLower(l)] {
+			return nil, fmt.Errorf("duplicate priority label %q", l)
+		}
+		seen[strings.ToLower(l)] = true
+	}
+	return labels, nil
+}
+
+// runnerClaims remembers the runs-on labels of the job each runner's VM was
+// created for, until a job starts on the runner.
+type runnerClaims struct {
+	mu     sync.Mutex
+	labels map[string][]string
+}
+
+func (c *runnerClaims) set(runnerName string, labels []string) {
+	if c == nil || labels == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.labels == nil {
+		c.labels = make(map[string][]string)
+	}
+	c.labels[runnerName] = labels
+}
+
+func (c *runnerClaims) get(runnerName string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	labels, ok := c.labels[runnerName]
+	return labels, ok
+}
+
+func (c *runnerClaims) forget(runnerName string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.labels, runnerName)
+}
+
+// recycleForPriority deletes an idle VM created for a lower-priority job, or
+// with no job at all (e.g. for --min-runners), when a higher-priority job
+// waits for a VM at --max-runners (see --recycle-for-priority). It deletes
+// at most one VM per call, the lowest-priority and most recently created, and
+// reports whether it did. The job the VM was created for goes back to the
+// queue. A VM whose runner GitHub would not remove, e.g. because it was just
+// assigned a job, is kept.
+func (s *gcpRunnerScaler) recycleForPriority(ctx context.Context) bool {
+	top, ok := s.assignedJobs.topUnclaimed()
+	if !ok {
+		return false
+	}
+	vms := s.vmManager.VMs()
+	runnersPerVM := make(map[string]int, len(vms))
+	for _, vm := range vms {
+		runnersPerVM[vm.Name]++
+	}
+	victim, victimRank := -1, top
+	for i, vm := range vms {
+		// A VM running several runners is never idle as a whole.
+		if vm.Pending || vm.Busy || runnersPerVM[vm.Name] > 1 {
+			continue
+		}
+		rank := len(s.assignedJobs.priorities)
+		if labels, ok := s.claims.get(vm.RunnerName); ok {
+			rank = s.assignedJobs.rank(labels)
+		}
+		if rank > victimRank || (rank == victimRank && victim >= 0 && vm.CreatedAt.After(vms[victim].CreatedAt)) {
+			victim, victimRank = i, rank
+		}
+	}
+	if victim < 0 {
+		return false
+	}
+	name := vms[victim].RunnerName
+	s.logger.Info("recycling idle VM for a higher-priority job", "runner", name, "vm", vms[victim].Name)
+	if err := s.removeRunnerFromGitHub(ctx, name); err != nil {
+		s.logger.Warn("not recycling VM whose registration could not be removed", "runner", name, "error", err)
+		return false
+	}
+	s.latency.forget(name)
+	s.runners.finished(name, "recycled")
+	if labels, ok := s.claims.get(name); ok {
+		s.unclaimLabels(labels)
+		s.claims.forget(name)
+	}
+	if err := s.vmManager.DeleteByRunnerName(ctx, name); err != nil {
+		s.logger.Error("failed to delete recycled VM", "event", eventVMDeleteFailed, "runner", name, "error", err)
+		return false
+	}
+	return true
+}
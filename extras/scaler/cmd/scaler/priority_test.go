@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/vmstate"
+)
+
+func TestPriorityLabelsAreValidatedAndAdvertised(t *testing.T) {
+	base := []string{"--url=https://github.com/o/r", "--labels=Linux,self-hosted"}
+	cfg, err := testLoadConfig(t, append(base, "--priority-labels=priority-high,priority-normal", "--recycle-for-priority")...)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	var got []string
+	for _, l := range cfg.buildLabels() {
+		got = append(got, l.Name)
+	}
+	if want := []string{"Linux", "self-hosted", "priority-high", "priority-normal"}; !slices.Equal(got, want) {
+		t.Fatalf("labels = %v, want %v", got, want)
+	}
+
+	for _, args := range [][]string{
+		{"--priority-labels=priority-high,Priority-High"},
+		{"--recycle-for-priority"},
+		{"--priority-labels=priority-high", "--provider=libvirt", "--platform=linux"},
+	} {
+		if _, err := testLoadConfig(t, append(base, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+}
+
+func TestAssignedJobsClaimByPriority(t *testing.T) {
+	q := &assignedJobs{priorities: []string{"priority-high", "priority-normal"}}
+	q.record(&scaleset.RunnerScaleSetMessage{
+		JobAssignedMessages: []*scaleset.JobAssigned{
+			{JobMessageBase: scaleset.JobMessageBase{JobID: "1", RequestLabels: []string{"Linux"}}},
+			{JobMessageBase: scaleset.JobMessageBase{JobID: "2", RequestLabels: []string{"Linux", "priority-normal"}}},
+			{JobMessageBase: scaleset.JobMessageBase{JobID: "3", RequestLabels: []string{"Linux", "Priority-High"}}},
+			{JobMessageBase: scaleset.JobMessageBase{JobID: "4", RequestLabels: []string{"Linux", "priority-high", "GCP-A100"}}},
+		},
+	})
+	if top, ok := q.topUnclaimed(); !ok || top != 0 {
+		t.Fatalf("topUnclaimed = %d, %v, want 0", top, ok)
+	}
+	for _, want := range [][]string{
+		{"Linux", "Priority-High"},
+		{"Linux", "priority-high", "GCP-A100"},
+		{"Linux", "priority-normal"},
+		{"Linux"},
+		nil,
+	} {
+		if got := q.claim(); !slices.Equal(got, want) {
+			t.Fatalf("claim = %v, want %v", got, want)
+		}
+	}
+	if _, ok := q.topUnclaimed(); ok {
+		t.Fatal("topUnclaimed should report nothing once every job is claimed")
+	}
+}
+
+func TestRecycleForPriority(t *testing.T) {
+	now := time.Now()
+	provider := &reuseProvider{fakeAdminProvider: fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-1", Name: "win-1", CreatedAt: now.Add(-time.Hour)},
+		{RunnerName: "win-2", Name: "win-2", CreatedAt: now.Add(-time.Minute)},
+		{RunnerName: "win-3", Name: "win-3", CreatedAt: now.Add(-time.Minute), Busy: true},
+		{RunnerName: "win-4", Name: "win-4", Pending: true},
+	}}}
+	client, actions := newFakeActionsClient(t)
+	jobs := &assignedJobs{priorities: []string{"priority-high", "priority-normal"}}
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      provider,
+		scalesetClient: client,
+		scaleSetID:     1,
+		assignedJobs:   jobs,
+		claims:         &runnerClaims{},
+	}
+	// win-1 is a warm VM; win-2 was created for a normal-priority job.
+	jobs.record(&scaleset.RunnerScaleSetMessage{
+		JobAssignedMessages: []*scaleset.JobAssigned{
+			{JobMessageBase: scaleset.JobMessageBase{JobID: "1", RequestLabels: []string{"priority-normal"}}},
+		},
+	})
+	s.claims.set("win-2", jobs.claim())
+
+	ctx := context.Background()
+	if s.recycleForPriority(ctx) {
+		t.Fatal("nothing should be recycled without a waiting job")
+	}
+	jobs.record(&scaleset.RunnerScaleSetMessage{
+		JobAssignedMessages: []*scaleset.JobAssigned{
+			{JobMessageBase: scaleset.JobMessageBase{JobID: "2", RequestLabels: []string{"priority-high"}}},
+		},
+	})
+	// GitHub would not remove win-1's runner, which just got a job.
+	actions.removeFailures = 1
+	if s.recycleForPriority(ctx) || len(provider.deleted) != 0 {
+		t.Fatalf("deleted = %v after the registration was kept, want none", provider.deleted)
+	}
+	if !s.recycleForPriority(ctx) || !slices.Equal(provider.deleted, []string{"win-1"}) {
+		t.Fatalf("deleted = %v, want the warm VM first", provider.deleted)
+	}
+	provider.vms = provider.vms[1:]
+	if !s.recycleForPriority(ctx) || !slices.Equal(provider.deleted, []string{"win-1", "win-2"}) {
+		t.Fatalf("deleted = %v, want the normal-priority VM next", provider.deleted)
+	}
+	if len(actions.removed) != 2 {
+		t.Fatalf("removed = %v, want both registrations", actions.removed)
+	}
+	// The normal-priority job waits for a VM again, behind the high one.
+	if got := jobs.claim(); !slices.Equal(got, []string{"priority-high"}) {
+		t.Fatalf("claim = %v, want the high-priority job", got)
+	}
+	if got := jobs.claim(); !slices.Equal(got, []string{"priority-normal"}) {
+		t.Fatalf("claim = %v, want the recycled VM's job", got)
+	}
+
+	provider.vms = provider.vms[1:]
+	jobs.record(&scaleset.RunnerScaleSetMessage{
+		JobAssignedMessages: []*scaleset.JobAssigned{
+			{JobMessageBase: scaleset.JobMessageBase{JobID: "3", RequestLabels: []string{"priority-high"}}},
+		},
+	})
+	if s.recycleForPriority(ctx) {
+		t.Fatal("busy and pending VMs must not be recycled")
+	}
+}
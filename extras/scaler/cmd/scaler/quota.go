@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/actions/scaleset"
+	"github.com/actions/scaleset/listener"
+)
+
+// parseRunnerQuotas parses --runner-quotas, e.g.
+// "shader-slang/slang=6,shader-slang/slang/.github/workflows/benchmark.yml=2".
+// A key is a repository, OWNER/REPO, or a workflow file in one; keys are
+// matched case-insensitively, as GitHub does.
+func parseRunnerQuotas(v string) (map[string]int, error) {
+	quotas, err := parseKeyValues(v)
+	if err != nil || quotas == nil {
+		return nil, err
+	}
+	out := make(map[string]int, len(quotas))
+	for key, limit := range quotas {
+		parts := strings.Split(key, "/")
+		workflow := len(parts) > 4 && parts[2] == ".github" && parts[3] == "workflows"
+		if slices.Contains(parts, "") || (len(parts) != 2 && !workflow) {
+			return nil, fmt.Errorf("%q is neither OWNER/REPO nor OWNER/REPO/.github/workflows/FILE", key)
+		}
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%s: quota %q is not a positive number", key, limit)
+		}
+		out[strings.ToLower(key)] = n
+	}
+	return out, nil
+}
+
+// quotaJob is a job assigned to the scale set that has not completed yet.
+type quotaJob struct {
+	jobID    string
+	repo     string // owner/repo
+	workflow string // owner/repo/.github/workflows/file, without the @ref
+}
+
+// runnerQuotas caps the runners one repository or workflow may use at once
+// (see --runner-quotas). GitHub dispatches a scale set's jobs to any of its
+// idle runners, so the scaler cannot keep a job off a runner; instead, jobs
+// beyond their quota do not count toward the desired runner count. They get a
+// runner once earlier jobs of the same repository or workflow complete.
+type runnerQuotas struct {
+	limits map[string]int
+
+	mu sync.Mutex
+	// jobs are in assignment order, so the earliest jobs get the quota.
+	jobs []quotaJob
+}
+
+func newRunnerQuotas(limits map[string]int) *runnerQuotas {
+	return &runnerQuotas{limits: limits}
+}
+
+func (q *runnerQuotas) record(msg *scaleset.RunnerScaleSetMessage) {
+	if msg == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range msg.JobAssignedMessages {
+		if slices.ContainsFunc(q.jobs, func(j quotaJob) bool { return j.jobID == job.JobID }) {
+			continue
+		}
+		workflow, _, _ := strings.Cut(job.JobWorkflowRef, "@")
+		q.jobs = append(q.jobs, quotaJob{
+			jobID:    job.JobID,
+			repo:     strings.ToLower(job.OwnerName + "/" + job.RepositoryName),
+			workflow: strings.ToLower(workflow),
+		})
+	}
+}
+
+// forget drops a job once it has completed; cancelled jobs complete too.
+func (q *runnerQuotas) forget(jobID string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs = slices.DeleteFunc(q.jobs, func(j quotaJob) bool { return j.jobID == jobID })
+}
+
+// overQuota returns how many of the assigned jobs exceed a quota, and how
+// many jobs each quota held back.
+func (q *runnerQuotas) overQuota() (int, map[string]int) {
+	if q == nil {
+		return 0, nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	using := make(map[string]int)
+	var held map[string]int
+	over := 0
+	for _, job := range q.jobs {
+		blocked := ""
+		for _, key := range []string{job.repo, job.workflow} {
+			if limit, ok := q.limits[key]; ok && using[key] >= limit {
+				blocked = key
+				break
+			}
+		}
+		if blocked != "" {
+			over++
+			if held == nil {
+				held = make(map[string]int)
+			}
+			held[blocked]++
+			continue
+		}
+		using[job.repo]++
+		using[job.workflow]++
+	}
+	return over, held
+}
+
+// quotaRecordingClient wraps the listener's session client to observe the
+// JobAssigned messages the listener itself discards.
+type quotaRecordingClient struct {
+	listener.Client
+	quotas *runnerQuotas
+}
+
+func (c *quotaRecordingClient) GetMessage(ctx context.Context, lastMessageID, maxCapacity int) (*scaleset.RunnerScaleSetMessage, error) {
+	msg, err := c.Client.GetMessage(ctx, lastMessageID, maxCapacity)
+	if err == nil {
+		c.quotas.record(msg)
+	}
+	return msg, err
+}
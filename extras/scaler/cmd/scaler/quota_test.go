@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"maps"
+	"testing"
+
+	"github.com/actions/scaleset"
+)
+
+func TestParseRunnerQuotas(t *testing.T) {
+	got, err := parseRunnerQuotas("Shader-Slang/slang=6, shader-slang/slang/.github/workflows/benchmark.yml=2")
+	if err != nil {
+		t.Fatalf("parseRunnerQuotas: %v", err)
+	}
+	want := map[string]int{"shader-slang/slang": 6, "shader-slang/slang/.github/workflows/benchmark.yml": 2}
+	if !maps.Equal(got, want) {
+		t.Fatalf("parseRunnerQuotas = %v, want %v", got, want)
+	}
+	for _, spec := range []string{
+		"shader-slang=2",
+		"shader-slang/slang/benchmark.yml=2",
+		"shader-slang//.github/workflows/ci.yml=2",
+		"shader-slang/slang=0",
+		"shader-slang/slang=two",
+	} {
+		if _, err := parseRunnerQuotas(spec); err == nil {
+			t.Errorf("parseRunnerQuotas(%q) should fail", spec)
+		}
+	}
+}
+
+func assignedMessage(jobs ...*scaleset.JobAssigned) *scaleset.RunnerScaleSetMessage {
+	return &scaleset.RunnerScaleSetMessage{JobAssignedMessages: jobs}
+}
+
+func assigned(id, repo, workflow string) *scaleset.JobAssigned {
+	job := &scaleset.JobAssigned{}
+	job.JobID = id
+	job.OwnerName = "shader-slang"
+	job.RepositoryName = repo
+	job.JobWorkflowRef = "shader-slang/" + repo + "/.github/workflows/" + workflow + "@refs/heads/master"
+	return job
+}
+
+func TestRunnerQuotasHoldBackLaterJobs(t *testing.T) {
+	q := newRunnerQuotas(map[string]int{
+		"shader-slang/slang": 3,
+		"shader-slang/slang/.github/workflows/benchmark.yml": 1,
+	})
+	q.record(assignedMessage(
+		assigned("1", "slang", "benchmark.yml"),
+		assigned("2", "slang", "benchmark.yml"),
+		assigned("3", "slang", "ci.yml"),
+		assigned("4", "slang", "ci.yml"),
+		assigned("5", "slang", "ci.yml"),
+		assigned("6", "slang-rhi", "ci.yml"),
+	))
+	// A redelivered message does not count its jobs twice.
+	q.record(assignedMessage(assigned("6", "slang-rhi", "ci.yml")))
+
+	over, held := q.overQuota()
+	// Job 2 is held by its workflow's quota, so job 5 is the repository's
+	// fourth.
+	if over != 2 || held["shader-slang/slang/.github/workflows/benchmark.yml"] != 1 || held["shader-slang/slang"] != 1 {
+		t.Fatalf("overQuota = %d, %v; want job 2 held by its workflow and 5 by the repository", over, held)
+	}
+
+	q.forget("1")
+	if over, _ := q.overQuota(); over != 1 {
+		t.Fatalf("overQuota after job 1 completed = %d, want 1", over)
+	}
+	var none *runnerQuotas
+	if over, _ := none.overQuota(); over != 0 {
+		t.Fatalf("overQuota without quotas = %d, want 0", over)
+	}
+}
+
+func TestHandleDesiredRunnerCountAppliesQuotas(t *testing.T) {
+	s := &gcpRunnerScaler{
+		logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:  &fakePingProvider{}, // 2 active VMs
+		maxRunners: 4,
+		quotas:     newRunnerQuotas(map[string]int{"shader-slang/slang": 2}),
+	}
+	s.quotas.record(assignedMessage(
+		assigned("1", "slang", "ci.yml"),
+		assigned("2", "slang", "ci.yml"),
+		assigned("3", "slang", "ci.yml"),
+		assigned("4", "slang", "ci.yml"),
+	))
+	// Two jobs have runners; the other two wait for them to complete.
+	if got, err := s.HandleDesiredRunnerCount(context.Background(), 4); err != nil || got != 2 {
+		t.Fatalf("HandleDesiredRunnerCount = %d, %v, want 2", got, err)
+	}
+	if got := s.desired.Load(); got != 2 {
+		t.Fatalf("desired = %d, want 2", got)
+	}
+}
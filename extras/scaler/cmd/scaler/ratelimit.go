@@ -0,0 +1,106 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// createLimiter caps how fast the scaler creates VMs (see
+// --max-creates-per-minute and --max-creates-per-hour), so a burst of jobs,
+// e.g. a 300-job matrix, does not create hundreds of VMs at once or run
+// into GCP API rate limits. Each limit is a token bucket that holds up to
+// the limit, refills at the limit per period and starts full; a VM create
+// takes a token from every bucket.
+type createLimiter struct {
+	mu        sync.Mutex
+	buckets   []tokenBucket
+	throttled int64 // VM creates held back, cumulative
+}
+
+type tokenBucket struct {
+	limit  float64
+	period time.Duration
+	tokens float64
+	last   time.Time
+}
+
+// newCreateLimiter returns a limiter for the given limits, or nil when both
+// are 0.
+func newCreateLimiter(perMinute, perHour int) *createLimiter {
+	l := &createLimiter{}
+	for _, b := range []tokenBucket{
+		{limit: float64(perMinute), period: time.Minute},
+		{limit: float64(perHour), period: time.Hour},
+	} {
+		if b.limit > 0 {
+			b.tokens = b.limit
+			l.buckets = append(l.buckets, b)
+		}
+	}
+	if len(l.buckets) == 0 {
+		return nil
+	}
+	return l
+}
+
+// refill adds the tokens earned since the last call.
+func (l *createLimiter) refill(now time.Time) {
+	for i := range l.buckets {
+		b := &l.buckets[i]
+		if !b.last.IsZero() && now.After(b.last) {
+			b.tokens = min(b.limit, b.tokens+b.limit*float64(now.Sub(b.last))/float64(b.period))
+		}
+		b.last = now
+	}
+}
+
+// availableLocked returns the creates every bucket has a token for.
+func (l *createLimiter) availableLocked() int {
+	n := -1
+	for _, b := range l.buckets {
+		if n < 0 || int(b.tokens) < n {
+			n = int(b.tokens)
+		}
+	}
+	return n
+}
+
+// take takes tokens for up to n creates and returns how many it got. The
+// rest count as throttled.
+func (l *createLimiter) take(now time.Time, n int) int {
+	if l == nil {
+		return n
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(now)
+	granted := min(n, l.availableLocked())
+	for i := range l.buckets {
+		l.buckets[i].tokens -= float64(granted)
+	}
+	l.throttled += int64(n - granted)
+	return granted
+}
+
+// available returns how many VMs could be created right now, and the VM
+// creates held back so far.
+func (l *createLimiter) available(now time.Time) (int, int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(now)
+	return l.availableLocked(), l.throttled
+}
+
+// throttleCreates drops the plans beyond what the create rate limit allows
+// right now, returning their labels to the queue so a later scale-up plans
+// them again.
+func (s *gcpRunnerScaler) throttleCreates(now time.Time, plans []vmPlan, rec *scaleAudit) []vmPlan {
+	granted := s.createLimit.take(now, len(plans))
+	if granted == len(plans) {
+		return plans
+	}
+	s.logger.Info("create rate limit reached, deferring VM creates", "planned", len(plans), "deferred", len(plans)-granted)
+	s.deferPlans(plans[granted:])
+	rec.Throttled = len(plans) - granted
+	return plans[:granted]
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestCreateLimiterRefills(t *testing.T) {
+	if newCreateLimiter(0, 0) != nil {
+		t.Fatal("no limits should mean no limiter")
+	}
+	l := newCreateLimiter(4, 10)
+	now := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+	if got := l.take(now, 6); got != 4 {
+		t.Fatalf("take(6) = %d, want the minute's 4", got)
+	}
+	// Half a minute refills 2 of the minute's tokens.
+	if got := l.take(now.Add(30*time.Second), 3); got != 2 {
+		t.Fatalf("take(3) after 30s = %d, want 2", got)
+	}
+	if got := l.take(now.Add(10*time.Minute), 5); got != 4 {
+		t.Fatalf("take(5) after 10m = %d, want 4", got)
+	}
+	// A minute later the minute's bucket is full again, but the hour's
+	// has a single token.
+	if got := l.take(now.Add(11*time.Minute), 3); got != 1 {
+		t.Fatalf("take(3) after 11m = %d, want 1", got)
+	}
+	if n, throttled := l.available(now.Add(11 * time.Minute)); n != 0 || throttled != 6 {
+		t.Fatalf("available = %d, %d throttled; want 0 and 6", n, throttled)
+	}
+
+	var none *createLimiter
+	if got := none.take(now, 300); got != 300 {
+		t.Fatalf("take without a limiter = %d, want 300", got)
+	}
+}
+
+func TestScaleUpIsRateLimited(t *testing.T) {
+	provider := &slowProvider{release: make(chan struct{})}
+	close(provider.release)
+	client, _ := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      provider,
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "win",
+		maxRunners:     300,
+		createLimit:    newCreateLimiter(2, 0),
+	}
+	ctx := context.Background()
+	if got, err := s.HandleDesiredRunnerCount(ctx, 300); err != nil || got != 2 {
+		t.Fatalf("HandleDesiredRunnerCount = %d, %v, want 2 runners being created", got, err)
+	}
+	s.creates.Wait()
+	// The bucket is empty until it refills.
+	if got, err := s.HandleDesiredRunnerCount(ctx, 300); err != nil || got != 2 {
+		t.Fatalf("HandleDesiredRunnerCount = %d, %v, want no more VMs", got, err)
+	}
+	if sample := s.metricsSample(); !sample.RateLimited || sample.CreateTokens != 0 || sample.CreatesThrottled != 298+298 {
+		t.Fatalf("sample = %+v, want an empty bucket and 596 throttled", sample)
+	}
+}
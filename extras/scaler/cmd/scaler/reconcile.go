@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/actions/scaleset"
+	"github.com/actions/scaleset/listener"
+)
+
+const (
+	// jitRetryBackoff is the wait before retrying a scale-up whose JIT
+	// config request failed; it doubles after every failure in a row, up to
+	// maxJITRetryBackoff.
+	jitRetryBackoff    = 10 * time.Second
+	maxJITRetryBackoff = 5 * time.Minute
+)
+
+// reconciler fills the gap a failed scale-up leaves. The listener only
+// asks for runners when a message arrives, and passes 0 after a poll
+// without one, so the runners of a failed create were otherwise missing
+// until the queue changed. Every --reconcile-interval, and once the backoff
+// after a failed JIT config request ends, the scaler compares the runners
+// the last message's jobs need with the active ones and scales up the
+// difference. It is safe for concurrent use; a nil *reconciler does
+// nothing.
+type reconciler struct {
+	interval time.Duration
+	// assigned is the jobs assigned to the scale set as of the last
+	// message.
+	assigned atomic.Int32
+	// wake tells the loop to pick up a new retryAt.
+	wake chan struct{}
+
+	mu sync.Mutex
+	// failures counts the JIT config requests failed in a row, and
+	// retryAt is when to retry them; zero while none failed.
+	failures int
+	retryAt  time.Time
+}
+
+func newReconciler(interval time.Duration) *reconciler {
+	if interval <= 0 {
+		return nil
+	}
+	return &reconciler{interval: interval, wake: make(chan struct{}, 1)}
+}
+
+// jitFailed queues a retry of a scale-up whose JIT config request failed,
+// and returns how long it waits.
+func (r *reconciler) jitFailed(now time.Time) time.Duration {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures++
+	wait := jitRetryBackoff
+	for i := 1; i < r.failures && wait < maxJITRetryBackoff; i++ {
+		wait *= 2
+	}
+	wait = min(wait, maxJITRetryBackoff)
+	r.retryAt = now.Add(wait)
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return wait
+}
+
+// jitSucceeded ends the backoff after failed JIT config requests.
+func (r *reconciler) jitSucceeded() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.failures, r.retryAt = 0, time.Time{}
+	r.mu.Unlock()
+}
+
+// retryIn returns how long until the queued retry, or false without one.
+func (r *reconciler) retryIn(now time.Time) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.retryAt.IsZero() {
+		return 0, false
+	}
+	return max(r.retryAt.Sub(now), 0), true
+}
+
+// retrying takes the queued retry off the queue; jitFailed queues another
+// one, with a longer wait, if it fails again.
+func (r *reconciler) retrying() {
+	r.mu.Lock()
+	r.retryAt = time.Time{}
+	r.mu.Unlock()
+}
+
+// backingOff reports whether a failed JIT config request is still being
+// waited out at now.
+func (r *reconciler) backingOff(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return now.Before(r.retryAt)
+}
+
+// jitFailed logs a failed JIT config request for runner and queues a retry
+// of its scale-up.
+func (s *gcpRunnerScaler) jitFailed(runner string, err error) {
+	now := time.Now()
+	s.logger.Error("failed to generate JIT config", "event", eventJITFailed, "runner", runner, "error", err)
+	s.stall.failed(err, now)
+	if wait := s.reconciler.jitFailed(now); wait > 0 {
+		s.logger.Info("retrying the scale-up after a failed JIT config request", "retry_in", wait)
+	}
+}
+
+// demandRecordingClient wraps the listener's session client to record the
+// jobs assigned to the scale set for the reconciler.
+type demandRecordingClient struct {
+	listener.Client
+	reconciler *reconciler
+}
+
+func (c *demandRecordingClient) GetMessage(ctx context.Context, lastMessageID, maxCapacity int) (*scaleset.RunnerScaleSetMessage, error) {
+	msg, err := c.Client.GetMessage(ctx, lastMessageID, maxCapacity)
+	if msg != nil && msg.Statistics != nil {
+		c.reconciler.assigned.Store(int32(msg.Statistics.TotalAssignedJobs))
+	}
+	return msg, err
+}
+
+// runReconciler reconciles every interval and when a queued retry is due,
+// until ctx is done.
+func (s *gcpRunnerScaler) runReconciler(ctx context.Context) {
+	r := s.reconciler
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	retry := time.NewTimer(r.interval)
+	defer retry.Stop()
+	for {
+		retry.Stop()
+		var retryC <-chan time.Time
+		if wait, ok := r.retryIn(time.Now()); ok {
+			retry.Reset(wait)
+			retryC = retry.C
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.wake:
+			continue
+		case <-ticker.C:
+		case <-retryC:
+			r.retrying()
+		}
+		s.reconcile(ctx, time.Now())
+	}
+}
+
+// reconcile scales up when fewer runners are active than the jobs of the
+// last message need, e.g. after failed creates. The scale-up goes through
+// HandleDesiredRunnerCount, so it is held for the same reasons as one the
+// listener asks for.
+func (s *gcpRunnerScaler) reconcile(ctx context.Context, now time.Time) {
+	r := s.reconciler
+	if s.isDraining() || s.isPaused() || r.backingOff(now) {
+		return
+	}
+	assigned := int(r.assigned.Load())
+	maxRunners, _ := s.limits()
+	want := min(maxRunners, s.minRunnersAt(now)+assigned)
+	active := s.activeCount()
+	if active >= want {
+		return
+	}
+	s.logger.Info("fewer runners than the queue needs, reconciling", "event", eventReconcile, "active", active, "want", want, "assigned_jobs", assigned)
+	if _, err := s.HandleDesiredRunnerCount(ctx, assigned); err != nil {
+		s.logger.Warn("reconciling failed", "error", err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestReconcilerBacksOffAfterFailedJITConfigs(t *testing.T) {
+	r := newReconciler(time.Minute)
+	now := time.Now()
+	for i, want := range []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second} {
+		if got := r.jitFailed(now); got != want {
+			t.Fatalf("wait after %d failures = %s, want %s", i+1, got, want)
+		}
+	}
+	for range 10 {
+		r.jitFailed(now)
+	}
+	if wait, ok := r.retryIn(now); !ok || wait != maxJITRetryBackoff {
+		t.Fatalf("retryIn = %s, %v; want the %s cap", wait, ok, maxJITRetryBackoff)
+	}
+	select {
+	case <-r.wake:
+	default:
+		t.Fatal("jitFailed did not wake the loop")
+	}
+	r.jitSucceeded()
+	if _, ok := r.retryIn(now); ok || r.backingOff(now) {
+		t.Fatal("a JIT config success should end the backoff")
+	}
+	if newReconciler(0) != nil {
+		t.Fatal("--reconcile-interval=0 should disable the reconciler")
+	}
+}
+
+func TestReconcileRetriesFailedScaleUp(t *testing.T) {
+	provider := &slotProvider{slots: 4}
+	client, f := newFakeActionsClient(t)
+	f.jitFailures = 1
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      provider,
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "a100",
+		maxRunners:     10,
+		reconciler:     newReconciler(time.Minute),
+	}
+	ctx := context.Background()
+	s.reconciler.assigned.Store(4)
+	if _, err := s.HandleDesiredRunnerCount(ctx, 4); err != nil {
+		t.Fatalf("HandleDesiredRunnerCount: %v", err)
+	}
+	s.creates.Wait()
+	if got := provider.ActiveCount(); got != 0 {
+		t.Fatalf("runners after a failed JIT config = %d, want 0", got)
+	}
+
+	// The listener's next poll finds no message; the retry waits out its
+	// backoff, then creates the runners the queue still needs.
+	if _, err := s.HandleDesiredRunnerCount(ctx, 0); err != nil {
+		t.Fatalf("HandleDesiredRunnerCount: %v", err)
+	}
+	now := time.Now()
+	s.reconcile(ctx, now)
+	s.creates.Wait()
+	if got := provider.ActiveCount(); got != 0 {
+		t.Fatalf("runners during the backoff = %d, want 0", got)
+	}
+	s.reconcile(ctx, now.Add(jitRetryBackoff))
+	s.creates.Wait()
+	if got := provider.ActiveCount(); got != 4 {
+		t.Fatalf("runners after reconciling = %d, want 4", got)
+	}
+
+	// Nothing to do once the runners are there, or while draining.
+	s.reconcile(ctx, now.Add(jitRetryBackoff))
+	s.reconciler.assigned.Store(8)
+	s.setDraining(true)
+	s.reconcile(ctx, now.Add(jitRetryBackoff))
+	s.creates.Wait()
+	if got := len(f.registered); got != 4 {
+		t.Fatalf("runners registered = %d, want 4", got)
+	}
+}
+
+func TestReconcileIntervalIsValidated(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--reconcile-interval=-1s"); err == nil {
+		t.Fatal("loadConfig should reject a negative --reconcile-interval")
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	gcpvm "extras/scaler/internal/gcp"
+)
+
+// reloadableSettings are the flags a SIGHUP applies to the running scaler
+// without dropping the message session. Changes to anything else are logged
+// and take effect on the next restart.
+var reloadableSettings = map[string]bool{
+	"max-runners":          true,
+	"min-runners":          true,
+	"min-runners-schedule": true,
+	"gcp-zones":            true,
+	"labels":               true,
+	"user-labels":          true,
+	"gcp-cleanup-interval": true,
+	"log-level":            true,
+	"gpu-driver-version":   true,
+	"gpu-driver-url":       true,
+	"gpu-driver-sha256":    true,
+}
+
+type configChange struct {
+	setting  string
+	old, new string
+}
+
+// configSettings renders cfg as flag name -> value, using the flag
+// definitions so every setting is covered without listing the fields twice.
+func configSettings(cfg config) map[string]string {
+	var bound config
+	fs := flag.NewFlagSet("settings", flag.ContinueOnError)
+	registerFlags(fs, &bound)
+	// The flags point into bound, so overwriting it makes them read cfg.
+	bound = cfg
+	settings := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) { settings[f.Name] = f.Value.String() })
+	return settings
+}
+
+// diffConfig returns the reloadable settings that differ between old and
+// next, and the names of changed settings that need a restart.
+func diffConfig(old, next config) (changes []configChange, needsRestart []string) {
+	oldSettings, nextSettings := configSettings(old), configSettings(next)
+	names := make([]string, 0, len(nextSettings))
+	for name := range nextSettings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if oldSettings[name] == nextSettings[name] {
+			continue
+		}
+		if !reloadableSettings[name] {
+			needsRestart = append(needsRestart, name)
+			continue
+		}
+		changes = append(changes, configChange{setting: name, old: oldSettings[name], new: nextSettings[name]})
+	}
+	return changes, needsRestart
+}
+
+type maxRunnersSetter interface {
+	SetMaxRunners(count int)
+}
+
+// zoneSetter, cleanupIntervalSetter and gpuDriverSetter are implemented by
+// providers that can change those settings live (the GCP manager).
+type zoneSetter interface {
+	SetZones(zones string) error
+}
+
+type cleanupIntervalSetter interface {
+	SetCleanupInterval(d time.Duration)
+}
+
+type gpuDriverSetter interface {
+	SetGPUDriver(d gcpvm.GPUDriver)
+}
+
+// configReloader re-reads the configuration on SIGHUP and applies the
+// reloadable settings to the running scaler.
+type configReloader struct {
+	logger   *slog.Logger
+	args     []string
+	scaler   *gcpRunnerScaler
+	listener maxRunnersSetter
+	logLevel *slog.LevelVar
+	// updateLabels pushes new scale set labels to GitHub.
+	updateLabels func(ctx context.Context, labels []scaleset.Label) error
+
+	current config
+}
+
+func (r *configReloader) reload(ctx context.Context) {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	next, err := loadConfig(fs, r.args)
+	if err != nil {
+		r.logger.Error("config reload failed, keeping current settings", "error", err)
+		return
+	}
+	r.apply(ctx, next)
+}
+
+func (r *configReloader) apply(ctx context.Context, next config) {
+	changes, needsRestart := diffConfig(r.current, next)
+	if len(changes) == 0 && len(needsRestart) == 0 {
+		r.logger.Info("config reloaded, no changes")
+		return
+	}
+	if len(needsRestart) > 0 {
+		r.logger.Warn("config changes need a restart to take effect", "settings", strings.Join(needsRestart, ","))
+	}
+	for _, c := range changes {
+		if err := r.applyChange(ctx, c.setting, next); err != nil {
+			r.logger.Error("failed to apply config change", "setting", c.setting, "error", err)
+			continue
+		}
+		r.logger.Info("config changed", "event", eventConfigChanged, "setting", c.setting, "old", c.old, "new", c.new)
+	}
+}
+
+func (r *configReloader) applyChange(ctx context.Context, setting string, next config) error {
+	switch setting {
+	case "max-runners", "min-runners":
+		if setting == "max-runners" {
+			r.current.maxRunners = next.maxRunners
+		} else {
+			r.current.minRunners = next.minRunners
+		}
+		r.scaler.setLimits(r.current.maxRunners, r.current.minRunners)
+		// Draining pins the listener at zero; leave it there.
+		if setting == "max-runners" && !r.scaler.isDraining() {
+			r.listener.SetMaxRunners(next.maxRunners)
+		}
+	case "min-runners-schedule":
+		r.scaler.setMinRunnersSchedule(next.minRunnersSchedule)
+		r.current.minRunnersScheduleSpec = next.minRunnersScheduleSpec
+		r.current.minRunnersSchedule = next.minRunnersSchedule
+	case "labels", "user-labels":
+		if err := r.updateLabels(ctx, next.buildLabels()); err != nil {
+			return fmt.Errorf("updating scale set labels: %w", err)
+		}
+		r.current.labels = next.labels
+		r.current.userLabels = next.userLabels
+	case "gcp-zones":
+		zs, ok := r.scaler.vmManager.(zoneSetter)
+		if !ok {
+			return fmt.Errorf("provider %s has no zones", r.current.provider)
+		}
+		if err := zs.SetZones(next.gcpZones); err != nil {
+			return err
+		}
+		r.current.gcpZones = next.gcpZones
+	case "gcp-cleanup-interval":
+		cs, ok := r.scaler.vmManager.(cleanupIntervalSetter)
+		if !ok {
+			return fmt.Errorf("provider %s cannot change its cleanup interval live", r.current.provider)
+		}
+		cs.SetCleanupInterval(next.gcpCleanupInterval)
+		r.current.gcpCleanupInterval = next.gcpCleanupInterval
+	case "gpu-driver-version", "gpu-driver-url", "gpu-driver-sha256":
+		ds, ok := r.scaler.vmManager.(gpuDriverSetter)
+		if !ok {
+			return fmt.Errorf("provider %s cannot change its GPU driver live", r.current.provider)
+		}
+		// loadConfig has validated the three flags together.
+		ds.SetGPUDriver(next.gpuDriver())
+		r.current.gpuDriverVersion = next.gpuDriverVersion
+		r.current.gpuDriverURL = next.gpuDriverURL
+		r.current.gpuDriverSHA256 = next.gpuDriverSHA256
+	case "log-level":
+		// loadConfig has validated the level.
+		if err := r.logLevel.UnmarshalText([]byte(next.logLevel)); err != nil {
+			return err
+		}
+		r.current.logLevel = next.logLevel
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	gcpvm "extras/scaler/internal/gcp"
+)
+
+// fakeReloadProvider is a vmProvider that records live reconfiguration.
+type fakeReloadProvider struct {
+	vmProvider
+	zones           string
+	zonesErr        error
+	cleanupInterval time.Duration
+	gpuDriver       gcpvm.GPUDriver
+}
+
+func (p *fakeReloadProvider) SetZones(zones string) error {
+	if p.zonesErr != nil {
+		return p.zonesErr
+	}
+	p.zones = zones
+	return nil
+}
+
+func (p *fakeReloadProvider) SetCleanupInterval(d time.Duration) { p.cleanupInterval = d }
+
+func (p *fakeReloadProvider) SetGPUDriver(d gcpvm.GPUDriver) { p.gpuDriver = d }
+
+type fakeListener struct{ maxRunners int }
+
+func (l *fakeListener) SetMaxRunners(count int) { l.maxRunners = count }
+
+func baseReloadConfig() config {
+	return config{
+		registrationURL:    "https://github.com/shader-slang/slang",
+		labels:             "Linux,self-hosted",
+		maxRunners:         4,
+		gcpZones:           "us-east1-c",
+		gcpCleanupInterval: 2 * time.Minute,
+		gcpProject:         "slang-runners",
+		provider:           "gcp",
+	}
+}
+
+func TestDiffConfigSplitsReloadableSettings(t *testing.T) {
+	old := baseReloadConfig()
+	next := old
+	next.maxRunners = 8
+	next.gcpProject = "other-project"
+
+	changes, needsRestart := diffConfig(old, next)
+	want := []configChange{{setting: "max-runners", old: "4", new: "8"}}
+	if !slices.Equal(changes, want) {
+		t.Fatalf("changes = %+v, want %+v", changes, want)
+	}
+	if !slices.Equal(needsRestart, []string{"gcp-project"}) {
+		t.Fatalf("needsRestart = %v, want [gcp-project]", needsRestart)
+	}
+}
+
+func TestConfigReloaderAppliesLiveSettings(t *testing.T) {
+	provider := &fakeReloadProvider{}
+	lst := &fakeListener{}
+	var pushed []scaleset.Label
+	cur := baseReloadConfig()
+	logLevel := new(slog.LevelVar)
+	r := &configReloader{
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		scaler:   &gcpRunnerScaler{vmManager: provider, maxRunners: cur.maxRunners},
+		listener: lst,
+		logLevel: logLevel,
+		updateLabels: func(_ context.Context, labels []scaleset.Label) error {
+			pushed = labels
+			return nil
+		},
+		current: cur,
+	}
+
+	next := cur
+	next.maxRunners = 10
+	next.minRunners = 2
+	next.labels = "Linux,self-hosted,GPU"
+	next.gcpZones = "us-east1-c,us-west1-a"
+	next.gcpCleanupInterval = 5 * time.Minute
+	next.logLevel = "debug"
+	next.gpuDriverVersion = "553.24"
+	next.gpuDriverURL = "gs://slang-drivers/553.24.exe"
+	next.minRunnersScheduleSpec = "3 daily 00:00-24:00"
+	next.minRunnersSchedule, _ = parseMinRunnersSchedule(next.minRunnersScheduleSpec)
+	r.apply(context.Background(), next)
+
+	if maxRunners, minRunners := r.scaler.limits(); maxRunners != 10 || minRunners != 2 {
+		t.Fatalf("limits = %d/%d, want 10/2", maxRunners, minRunners)
+	}
+	if lst.maxRunners != 10 {
+		t.Fatalf("listener max runners = %d, want 10", lst.maxRunners)
+	}
+	if got := r.scaler.minRunnersAt(time.Now()); got != 3 {
+		t.Fatalf("scheduled min runners = %d, want 3", got)
+	}
+	if len(pushed) != 3 || pushed[2].Name != "GPU" {
+		t.Fatalf("pushed labels = %+v, want Linux,self-hosted,GPU", pushed)
+	}
+	if provider.zones != next.gcpZones {
+		t.Fatalf("provider zones = %q, want %q", provider.zones, next.gcpZones)
+	}
+	if provider.cleanupInterval != 5*time.Minute {
+		t.Fatalf("provider cleanup interval = %v, want 5m", provider.cleanupInterval)
+	}
+	if want := (gcpvm.GPUDriver{Version: "553.24", URL: "gs://slang-drivers/553.24.exe"}); provider.gpuDriver != want {
+		t.Fatalf("provider GPU driver = %+v, want %+v", provider.gpuDriver, want)
+	}
+	if logLevel.Level() != slog.LevelDebug {
+		t.Fatalf("log level = %v, want DEBUG", logLevel.Level())
+	}
+	if changes, _ := diffConfig(r.current, next); len(changes) != 0 {
+		t.Fatalf("current config still differs after reload: %+v", changes)
+	}
+}
+
+func TestConfigReloaderKeepsDrainAndFailedSettings(t *testing.T) {
+	provider := &fakeReloadProvider{zonesErr: errors.New("invalid zone(s)")}
+	lst := &fakeListener{}
+	cur := baseReloadConfig()
+	scaler := &gcpRunnerScaler{vmManager: provider, maxRunners: cur.maxRunners}
+	scaler.setDraining(true)
+	r := &configReloader{
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		scaler:   scaler,
+		listener: lst,
+		current:  cur,
+	}
+
+	next := cur
+	next.maxRunners = 10
+	next.gcpZones = "bogus"
+	r.apply(context.Background(), next)
+
+	if lst.maxRunners != 0 {
+		t.Fatalf("listener max runners = %d; draining should keep it at 0", lst.maxRunners)
+	}
+	if r.current.gcpZones != cur.gcpZones {
+		t.Fatalf("current zones = %q; a failed SetZones should keep %q", r.current.gcpZones, cur.gcpZones)
+	}
+}
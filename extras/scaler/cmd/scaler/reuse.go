@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/actions/scaleset"
+)
+
+// vmReuser is implemented by providers whose VMs can run another job: the
+// runner is registered again under the same name and the VM picks up the
+// new JIT config instead of shutting down.
+type vmReuser interface {
+	ReuseVM(ctx context.Context, runnerName, jitConfig string) error
+}
+
+// vmReuseLimits bounds how long a VM is kept when --max-jobs-per-vm is
+// above 1. A VM reaching either limit is deleted as usual after its job.
+type vmReuseLimits struct {
+	maxJobs int
+	maxAge  time.Duration // 0 means no age limit
+	// grace keeps a VM freed above the desired runner count for another
+	// job that long (see --reuse-grace).
+	grace time.Duration
+}
+
+// reuseAboveDesired is the reuseBlocker that --reuse-grace lifts.
+const reuseAboveDesired = "above desired runner count"
+
+// reuseBlocker returns why the VM that ran jobInfo must not take another
+// job, or "" when it can be reused.
+func (s *gcpRunnerScaler) reuseBlocker(jobInfo *scaleset.JobCompleted, now time.Time) string {
+	if jobInfo.Result != "succeeded" {
+		// A failed or cancelled job may have left the VM in a bad state.
+		return "job " + jobInfo.Result
+	}
+	if s.isDraining() {
+		return "draining"
+	}
+	if s.isPaused() {
+		return "paused"
+	}
+	if s.activeCount() > int(s.desired.Load()) {
+		return reuseAboveDesired
+	}
+	for _, vm := range s.vmManager.VMs() {
+		if vm.RunnerName != jobInfo.RunnerName {
+			continue
+		}
+		if vm.Jobs >= s.reuse.maxJobs {
+			return "max jobs per VM reached"
+		}
+		if s.reuse.maxAge > 0 && now.Sub(vm.CreatedAt) >= s.reuse.maxAge {
+			return "max VM age reached"
+		}
+		return ""
+	}
+	return "VM not tracked"
+}
+
+// reuseVM keeps the VM of a completed job for another one when reuse is
+// enabled and the VM is within its limits. It reports whether the VM was
+// kept; otherwise the caller deletes it. Any failure along the way falls
+// back to deleting, so a VM is never left without a registered runner.
+func (s *gcpRunnerScaler) reuseVM(ctx context.Context, jobInfo *scaleset.JobCompleted) bool {
+	if s.reuse == nil || jobInfo.RunnerName == "" {
+		return false
+	}
+	reuser, ok := s.vmManager.(vmReuser)
+	if !ok {
+		return false
+	}
+	name := jobInfo.RunnerName
+	reason := s.reuseBlocker(jobInfo, time.Now())
+	grace := reason == reuseAboveDesired && s.reuse.grace > 0
+	if reason != "" && !grace {
+		s.logger.Debug("not reusing runner VM", "runner", name, "reason", reason)
+		return false
+	}
+
+	// The ephemeral runner is done; remove any leftover registration so the
+	// name can be registered again.
+	s.removeRunnerFromGitHub(ctx, name)
+	jitCtx, jitSpan := tracer.Start(ctx, "github.GenerateJitRunnerConfig")
+	jit, err := s.scalesetClient.get().GenerateJitRunnerConfig(
+		jitCtx,
+		&scaleset.RunnerScaleSetJitRunnerSetting{Name: name},
+		s.scaleSetID,
+	)
+	endSpan(jitSpan, err)
+	if err != nil {
+		s.logger.Warn("failed to generate JIT config for VM reuse, deleting the VM", "event", eventJITFailed, "runner", name, "error", err)
+		return false
+	}
+	s.runnerRegistered(name, jit)
+	if err := reuser.ReuseVM(ctx, name, jit.EncodedJITConfig); err != nil {
+		s.logger.Warn("failed to hand the VM a new JIT config, deleting it", "runner", name, "error", err)
+		return false
+	}
+	s.latency.registered(name, time.Now())
+	s.runners.booting(ctx, name)
+	if grace {
+		s.logger.Info("keeping runner VM for another job", "event", eventVMReused, "runner", name, "grace", s.reuse.grace)
+		ctx := context.WithoutCancel(ctx)
+		time.AfterFunc(s.reuse.grace, func() { s.endReuseGrace(ctx, name) })
+		return true
+	}
+	s.logger.Info("reusing runner VM for another job", "event", eventVMReused, "runner", name)
+	return true
+}
+
+// endReuseGrace deletes a VM kept by --reuse-grace that is still idle while
+// the pool is above its desired size. Its registration goes first, so no
+// job lands on the VM while it is deleted; a VM whose runner GitHub would
+// not remove, e.g. because it was just assigned a job, is kept.
+func (s *gcpRunnerScaler) endReuseGrace(ctx context.Context, name string) {
+	idle := false
+	for _, vm := range s.vmManager.VMs() {
+		if vm.RunnerName == name {
+			idle = !vm.Busy
+		}
+	}
+	if !idle || s.activeCount() <= int(s.desired.Load()) {
+		return
+	}
+	s.logger.Info("deleting runner VM idle past its reuse grace", "runner", name, "grace", s.reuse.grace)
+	if err := s.removeRunnerFromGitHub(ctx, name); err != nil {
+		s.logger.Warn("keeping runner VM whose registration could not be removed", "runner", name, "error", err)
+		return
+	}
+	s.latency.forget(name)
+	s.runners.finished(name, "reuse grace expired")
+	if err := s.vmManager.DeleteByRunnerName(ctx, name); err != nil {
+		s.logger.Error("failed to delete VM after its reuse grace", "event", eventVMDeleteFailed, "runner", name, "error", err)
+		return
+	}
+	s.released(time.Now())
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/vmstate"
+)
+
+// reuseProvider is a provider that supports VM reuse.
+type reuseProvider struct {
+	fakeAdminProvider
+	reused  []string
+	deleted []string
+}
+
+func (p *reuseProvider) DeleteByRunnerName(_ context.Context, runnerName string) error {
+	p.deleted = append(p.deleted, runnerName)
+	return nil
+}
+
+func (p *reuseProvider) ActiveCount() int { return len(p.vms) }
+
+func (p *reuseProvider) ReuseVM(_ context.Context, runnerName, _ string) error {
+	p.reused = append(p.reused, runnerName)
+	return nil
+}
+
+func newReuseScaler(now time.Time) (*gcpRunnerScaler, *reuseProvider) {
+	provider := &reuseProvider{fakeAdminProvider: fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "build-1", Busy: true, Jobs: 1, CreatedAt: now.Add(-time.Hour)},
+		{RunnerName: "build-2", Busy: true, Jobs: 3, CreatedAt: now.Add(-time.Hour)},
+		{RunnerName: "build-3", Busy: true, Jobs: 1, CreatedAt: now.Add(-5 * time.Hour)},
+	}}}
+	s := &gcpRunnerScaler{
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager: provider,
+		reuse:     &vmReuseLimits{maxJobs: 3, maxAge: 4 * time.Hour},
+	}
+	s.desired.Store(3)
+	return s, provider
+}
+
+func TestReuseBlocker(t *testing.T) {
+	now := time.Date(2026, 5, 11, 0, 0, 0, 0, time.UTC)
+	s, _ := newReuseScaler(now)
+	completed := func(runner, result string) *scaleset.JobCompleted {
+		return &scaleset.JobCompleted{RunnerName: runner, Result: result}
+	}
+
+	for _, tc := range []struct {
+		job  *scaleset.JobCompleted
+		want string
+	}{
+		{completed("build-1", "succeeded"), ""},
+		{completed("build-1", "failed"), "job failed"},
+		{completed("build-2", "succeeded"), "max jobs per VM reached"},
+		{completed("build-3", "succeeded"), "max VM age reached"},
+		{completed("build-9", "succeeded"), "VM not tracked"},
+	} {
+		if got := s.reuseBlocker(tc.job, now); got != tc.want {
+			t.Errorf("reuseBlocker(%s, %s) = %q, want %q", tc.job.RunnerName, tc.job.Result, got, tc.want)
+		}
+	}
+
+	s.desired.Store(2)
+	if got := s.reuseBlocker(completed("build-1", "succeeded"), now); got != "above desired runner count" {
+		t.Fatalf("reuseBlocker above desired count = %q", got)
+	}
+	s.desired.Store(3)
+	s.setPaused(true)
+	if got := s.reuseBlocker(completed("build-1", "succeeded"), now); got != "paused" {
+		t.Fatalf("reuseBlocker while paused = %q", got)
+	}
+	s.setPaused(false)
+	s.setDraining(true)
+	if got := s.reuseBlocker(completed("build-1", "succeeded"), now); got != "draining" {
+		t.Fatalf("reuseBlocker while draining = %q", got)
+	}
+}
+
+func TestReuseVMSkippedWithoutReuse(t *testing.T) {
+	now := time.Now()
+	job := &scaleset.JobCompleted{RunnerName: "build-2", Result: "succeeded"}
+
+	// Neither call may reach GitHub: the scaler has no client.
+	s, provider := newReuseScaler(now)
+	if s.reuseVM(context.Background(), job) {
+		t.Fatal("a VM at its job limit must not be reused")
+	}
+	s.reuse = nil
+	job.RunnerName = "build-1"
+	if s.reuseVM(context.Background(), job) {
+		t.Fatal("VMs must not be reused unless --max-jobs-per-vm is above 1")
+	}
+	if len(provider.reused) != 0 {
+		t.Fatalf("reused = %v, want none", provider.reused)
+	}
+
+	s.reuse = &vmReuseLimits{maxJobs: 3}
+	s.vmManager = &fakeAdminProvider{vms: provider.vms}
+	if s.reuseVM(context.Background(), job) {
+		t.Fatal("a provider without reuse support must not reuse VMs")
+	}
+}
+
+func TestReuseGraceKeepsVMAboveDesired(t *testing.T) {
+	now := time.Now()
+	s, provider := newReuseScaler(now)
+	client, actions := newFakeActionsClient(t)
+	s.scalesetClient, s.scaleSetID = client, 1
+	s.reuse.grace = time.Hour
+	s.desired.Store(2)
+
+	job := &scaleset.JobCompleted{RunnerName: "build-1", Result: "succeeded"}
+	if !s.reuseVM(context.Background(), job) {
+		t.Fatal("--reuse-grace should keep a VM freed above the desired runner count")
+	}
+	if len(provider.reused) != 1 || len(actions.registered) != 1 {
+		t.Fatalf("reused = %v, registered = %v, want build-1 registered again", provider.reused, actions.registered)
+	}
+
+	// Busy again, or needed by the pool: the VM stays.
+	s.endReuseGrace(context.Background(), "build-1")
+	provider.vms[0].Busy = false
+	s.desired.Store(3)
+	s.endReuseGrace(context.Background(), "build-1")
+	if len(provider.deleted) != 0 {
+		t.Fatalf("deleted = %v, want none", provider.deleted)
+	}
+
+	// GitHub would not remove the runner, which just got a job.
+	s.desired.Store(2)
+	actions.removeFailures = 1
+	s.endReuseGrace(context.Background(), "build-1")
+	if len(provider.deleted) != 0 {
+		t.Fatalf("deleted = %v after the registration was kept, want none", provider.deleted)
+	}
+
+	s.endReuseGrace(context.Background(), "build-1")
+	if len(provider.deleted) != 1 || len(actions.removed) != 2 {
+		t.Fatalf("deleted = %v, removed = %v, want the idle VM and its registration gone", provider.deleted, actions.removed)
+	}
+}
+
+func TestCheckStuckBootsCountsFromReuse(t *testing.T) {
+	now := time.Now()
+	provider := &fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-reused", CreatedAt: now.Add(-2 * time.Hour), ReusedAt: now.Add(-5 * time.Minute)},
+	}}
+	s, rec := newNotifyingScaler(t, provider)
+	s.checkStuckBoots(context.Background(), 20*time.Minute, now, make(map[string]bool))
+	if len(rec.alerts) != 0 {
+		t.Fatalf("alerts = %q, want none for a VM reused 5 minutes ago", rec.alerts)
+	}
+}
+
+func TestLoadConfigVMReuse(t *testing.T) {
+	base := []string{"--url=https://github.com/shader-slang/slang", "--token=t", "--name=build"}
+	cfg, err := testLoadConfig(t, append(base, "--max-jobs-per-vm=5", "--max-vm-age=4h")...)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if !gcpManagerConfig(cfg, "build", nil).ReuseVMs {
+		t.Fatal("--max-jobs-per-vm above 1 should enable VM reuse in the GCP manager")
+	}
+	if gcpManagerConfig(config{maxJobsPerVM: 1}, "build", nil).ReuseVMs {
+		t.Fatal("VM reuse should be off by default")
+	}
+
+	for _, args := range [][]string{
+		{"--max-jobs-per-vm=0"},
+		{"--max-vm-age=-1h"},
+		{"--max-jobs-per-vm=2", "--provider=libvirt", "--platform=linux"},
+		{"--reuse-grace=5m"},
+		{"--max-jobs-per-vm=2", "--reuse-grace=-1m"},
+		{"--scale-up-cooldown=-1s"},
+	} {
+		if _, err := testLoadConfig(t, append(base, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+}
+
+func TestScaleUpCooldownOnlyAboveDesired(t *testing.T) {
+	provider := &reuseProvider{fakeAdminProvider: fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "build-1", Name: "build-1", Busy: true},
+		{RunnerName: "build-2", Name: "build-2", Busy: true},
+	}}}
+	client, _ := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:       provider,
+		scalesetClient:  client,
+		scaleSetID:      1,
+		scaleUpCooldown: time.Minute,
+	}
+	ctx := context.Background()
+
+	// The queue still needs both runners: the freed slot is refilled.
+	s.desired.Store(2)
+	if err := s.HandleJobCompleted(ctx, &scaleset.JobCompleted{RunnerName: "build-1", Result: "succeeded"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.coolingDown(time.Now()) {
+		t.Fatal("a VM released at the desired size started the cooldown")
+	}
+
+	provider.vms = provider.vms[1:]
+	s.desired.Store(0)
+	if err := s.HandleJobCompleted(ctx, &scaleset.JobCompleted{RunnerName: "build-2", Result: "succeeded"}); err != nil {
+		t.Fatal(err)
+	}
+	if !s.coolingDown(time.Now()) {
+		t.Fatal("a VM released above the desired size should start the cooldown")
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/actions/scaleset"
+	"github.com/actions/scaleset/listener"
+
+	gcpvm "extras/scaler/internal/gcp"
+)
+
+// parseTemplateRoutes parses --template-routes. Entries are comma-separated
+// label=template pairs, optionally suffixed with /gpu-type when the routed
+// template attaches a different accelerator than --gcp-gpu-type, e.g.
+// "GCP-A100=linux-gpu-a100/nvidia-tesla-a100,GCP-T4=linux-gpu-runner".
+func parseTemplateRoutes(v string) ([]gcpvm.TemplateRoute, error) {
+	var routes []gcpvm.TemplateRoute
+	seen := make(map[string]bool)
+	for _, entry := range splitList(v) {
+		label, target, ok := strings.Cut(entry, "=")
+		label = strings.TrimSpace(label)
+		target = strings.TrimSpace(target)
+		if !ok || label == "" || target == "" {
+			return nil, fmt.Errorf("invalid route %q (want label=template[/gpu-type])", entry)
+		}
+		template, gpuType, _ := strings.Cut(target, "/")
+		if template == "" {
+			return nil, fmt.Errorf("invalid route %q: empty template", entry)
+		}
+		key := strings.ToLower(label)
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate route for label %q", label)
+		}
+		seen[key] = true
+		routes = append(routes, gcpvm.TemplateRoute{
+			Label:            label,
+			InstanceTemplate: template,
+			GPUType:          gpuType,
+		})
+	}
+	return routes, nil
+}
+
+// routeLabels returns the labels named by routes that are not already in
+// labels. The scale set must advertise every routed label, otherwise GitHub
+// never assigns those jobs to it.
+func routeLabels(labels []string, routes []gcpvm.TemplateRoute) []string {
+	routed := make([]string, len(routes))
+	for i, r := range routes {
+		routed[i] = r.Label
+	}
+	return missingLabels(labels, routed)
+}
+
+// missingLabels returns the labels of want that are not already in labels,
+// ignoring case and duplicates.
+func missingLabels(labels, want []string) []string {
+	have := make(map[string]bool, len(labels))
+	for _, l := range labels {
+		have[strings.ToLower(l)] = true
+	}
+	var extra []string
+	for _, l := range want {
+		if !have[strings.ToLower(l)] {
+			have[strings.ToLower(l)] = true
+			extra = append(extra, l)
+		}
+	}
+	return extra
+}
+
+// parseSizeLabels parses --size-labels, a comma-separated list of size
+// labels such as gpu-a100, cpu-32 or mem-128.
+func parseSizeLabels(v string) ([]string, error) {
+	labels := splitList(v)
+	for _, label := range labels {
+		_, ok, err := gcpvm.ParseSizeLabel(label)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%q is not a size label (want gpu-TYPE, cpu-N or mem-N)", label)
+		}
+	}
+	return labels, nil
+}
+
+// assignedJob is a job GitHub has assigned to the scale set that has not yet
+// started on a runner.
+type assignedJob struct {
+	jobID   string
+	labels  []string
+	claimed bool
+	// priority ranks the job by --priority-labels; lower runs first.
+	priority int
+}
+
+// assignedJobs remembers the runs-on labels of jobs assigned to the scale
+// set, so scale-up can pick a template per job. The listener only hands the
+// scaler a desired count, so the labels are captured from the raw messages
+// by labelRecordingClient.
+//
+// Routing is best effort: every runner in a scale set carries all of the
+// scale set's labels, so GitHub may dispatch a queued GCP-T4 job to an idle
+// A100 VM when both kinds are queued at once. Jobs still run; they just may
+// land on a larger GPU than they asked for.
+type assignedJobs struct {
+	// priorities are the --priority-labels, highest first.
+	priorities []string
+
+	mu   sync.Mutex
+	jobs []*assignedJob
+}
+
+// rank returns the priority of a job with the given runs-on labels: the
+// index of its highest --priority-labels label, or len(priorities) for a job
+// without one.
+func (q *assignedJobs) rank(labels []string) int {
+	for i, p := range q.priorities {
+		if slices.ContainsFunc(labels, func(l string) bool { return strings.EqualFold(l, p) }) {
+			return i
+		}
+	}
+	return len(q.priorities)
+}
+
+func (q *assignedJobs) size() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.jobs)
+}
+
+func (q *assignedJobs) record(msg *scaleset.RunnerScaleSetMessage) {
+	if msg == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range msg.JobAssignedMessages {
+		q.jobs = append(q.jobs, &assignedJob{jobID: job.JobID, labels: job.RequestLabels, priority: q.rank(job.RequestLabels)})
+	}
+}
+
+// claim returns the labels of the oldest of the highest-priority assigned
+// jobs that no VM has been created for yet, or nil when every assigned job
+// is covered (e.g. VMs created for --min-runners). With --max-runners
+// reached, high-priority jobs thus get the VMs that free up first.
+func (q *assignedJobs) claim() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var next *assignedJob
+	for _, job := range q.jobs {
+		if !job.claimed && (next == nil || job.priority < next.priority) {
+			next = job
+		}
+	}
+	if next == nil {
+		return nil
+	}
+	next.claimed = true
+	return next.labels
+}
+
+// topUnclaimed returns the priority of the highest-priority assigned job
+// that no VM has been created for yet.
+func (q *assignedJobs) topUnclaimed() (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	top, ok := 0, false
+	for _, job := range q.jobs {
+		if !job.claimed && (!ok || job.priority < top) {
+			top, ok = job.priority, true
+		}
+	}
+	return top, ok
+}
+
+// unclaim releases a claim after VM creation failed so the next scale-up
+// retries with the same labels.
+func (q *assignedJobs) unclaim(labels []string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range q.jobs {
+		if job.claimed && slices.Equal(job.labels, labels) {
+			job.claimed = false
+			return
+		}
+	}
+}
+
+// forget drops a job once it has started or completed (cancelled jobs
+// complete without ever starting).
+func (q *assignedJobs) forget(jobID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, job := range q.jobs {
+		if job.jobID == jobID {
+			q.jobs = append(q.jobs[:i], q.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// labelRecordingClient wraps the listener's session client to observe the
+// JobAssigned messages the listener itself discards.
+type labelRecordingClient struct {
+	listener.Client
+	jobs *assignedJobs
+}
+
+func (c *labelRecordingClient) GetMessage(ctx context.Context, lastMessageID, maxCapacity int) (*scaleset.RunnerScaleSetMessage, error) {
+	msg, err := c.Client.GetMessage(ctx, lastMessageID, maxCapacity)
+	if err == nil {
+		c.jobs.record(msg)
+	}
+	return msg, err
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/actions/scaleset"
+
+	gcpvm "extras/scaler/internal/gcp"
+)
+
+func TestParseTemplateRoutes(t *testing.T) {
+	routes, err := parseTemplateRoutes("GCP-A100=linux-gpu-a100/nvidia-tesla-a100, GCP-T4=linux-gpu-runner")
+	if err != nil {
+		t.Fatalf("parseTemplateRoutes: %v", err)
+	}
+	want := []gcpvm.TemplateRoute{
+		{Label: "GCP-A100", InstanceTemplate: "linux-gpu-a100", GPUType: "nvidia-tesla-a100"},
+		{Label: "GCP-T4", InstanceTemplate: "linux-gpu-runner"},
+	}
+	if !slices.Equal(routes, want) {
+		t.Fatalf("routes = %+v, want %+v", routes, want)
+	}
+
+	if routes, err := parseTemplateRoutes(""); err != nil || routes != nil {
+		t.Fatalf("empty value = %+v, %v; want nil, nil", routes, err)
+	}
+
+	for _, bad := range []string{"GCP-A100", "=tmpl", "GCP-A100=", "GCP-A100=/nvidia-tesla-a100", "a=x,A=y"} {
+		if _, err := parseTemplateRoutes(bad); err == nil {
+			t.Fatalf("parseTemplateRoutes(%q) should fail", bad)
+		}
+	}
+}
+
+func TestBuildLabelsAdvertisesRoutedLabels(t *testing.T) {
+	cfg := config{
+		labels: "Linux,self-hosted,gcp-a100",
+		routes: []gcpvm.TemplateRoute{
+			{Label: "GCP-A100", InstanceTemplate: "a100"},
+			{Label: "GCP-L4", InstanceTemplate: "l4"},
+		},
+	}
+	var got []string
+	for _, l := range cfg.buildLabels() {
+		got = append(got, l.Name)
+	}
+	want := []string{"Linux", "self-hosted", "gcp-a100", "GCP-L4"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("labels = %v, want %v", got, want)
+	}
+}
+
+func TestSizeLabelsAreValidatedAndAdvertised(t *testing.T) {
+	cfg, err := testLoadConfig(t, "--url=https://github.com/o/r", "--labels=Linux,self-hosted,cpu-32", "--size-labels=gpu-a100, cpu-32,mem-128")
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	var got []string
+	for _, l := range cfg.buildLabels() {
+		got = append(got, l.Name)
+	}
+	want := []string{"Linux", "self-hosted", "cpu-32", "gpu-a100", "mem-128"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("labels = %v, want %v", got, want)
+	}
+	if got := gcpManagerConfig(cfg, "runner", nil).SizeLabels; !slices.Equal(got, []string{"gpu-a100", "cpu-32", "mem-128"}) {
+		t.Fatalf("SizeLabels = %v", got)
+	}
+
+	for _, bad := range []string{"gpu-h200", "cpu-0", "GCP-A100"} {
+		if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--size-labels="+bad); err == nil {
+			t.Errorf("loadConfig should reject --size-labels=%s", bad)
+		}
+	}
+}
+
+func TestAssignedJobsClaimInOrder(t *testing.T) {
+	q := &assignedJobs{}
+	q.record(&scaleset.RunnerScaleSetMessage{
+		JobAssignedMessages: []*scaleset.JobAssigned{
+			{JobMessageBase: scaleset.JobMessageBase{JobID: "1", RequestLabels: []string{"GCP-A100"}}},
+			{JobMessageBase: scaleset.JobMessageBase{JobID: "2", RequestLabels: []string{"GCP-T4"}}},
+		},
+	})
+
+	if got := q.claim(); !slices.Equal(got, []string{"GCP-A100"}) {
+		t.Fatalf("first claim = %v, want [GCP-A100]", got)
+	}
+	if got := q.claim(); !slices.Equal(got, []string{"GCP-T4"}) {
+		t.Fatalf("second claim = %v, want [GCP-T4]", got)
+	}
+	if got := q.claim(); got != nil {
+		t.Fatalf("claim with every job covered = %v, want nil", got)
+	}
+
+	// A failed create releases its claim for the next scale-up.
+	q.unclaim([]string{"GCP-A100"})
+	if got := q.claim(); !slices.Equal(got, []string{"GCP-A100"}) {
+		t.Fatalf("claim after unclaim = %v, want [GCP-A100]", got)
+	}
+
+	q.forget("1")
+	q.forget("2")
+	if len(q.jobs) != 0 {
+		t.Fatalf("jobs after forget = %d, want 0", len(q.jobs))
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	// Schedules name IANA time zones, which minimal hosts may lack.
+	_ "time/tzdata"
+)
+
+// minRunnersWindow is one entry of --min-runners-schedule: count warm
+// runners on days from start until end (minutes after midnight in loc). A
+// window ending before it starts runs past midnight into the next day.
+type minRunnersWindow struct {
+	count      int
+	days       [7]bool // indexed by time.Weekday
+	start, end int
+	loc        *time.Location
+}
+
+// minRunnersSchedule is a parsed --min-runners-schedule. Outside its
+// windows --min-runners applies.
+type minRunnersSchedule []minRunnersWindow
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseMinRunnersSchedule parses comma-separated "COUNT DAYS HH:MM-HH:MM
+// [ZONE]" windows, e.g. "3 Mon-Fri 08:00-20:00 America/Los_Angeles,1 Sat
+// 10:00-14:00". DAYS is a day, a range of days (Fri-Mon wraps) or "daily";
+// ZONE is an IANA time zone and defaults to UTC.
+func parseMinRunnersSchedule(v string) (minRunnersSchedule, error) {
+	var schedule minRunnersSchedule
+	for _, entry := range splitList(v) {
+		w, err := parseMinRunnersWindow(entry)
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %w", entry, err)
+		}
+		schedule = append(schedule, w)
+	}
+	return schedule, nil
+}
+
+func parseMinRunnersWindow(entry string) (minRunnersWindow, error) {
+	fields := strings.Fields(entry)
+	if len(fields) != 3 && len(fields) != 4 {
+		return minRunnersWindow{}, fmt.Errorf("want COUNT DAYS HH:MM-HH:MM [ZONE]")
+	}
+	var w minRunnersWindow
+	var err error
+	if w.count, err = strconv.Atoi(fields[0]); err != nil || w.count < 0 {
+		return minRunnersWindow{}, fmt.Errorf("count %q is not a non-negative number", fields[0])
+	}
+	if w.days, err = parseDays(fields[1]); err != nil {
+		return minRunnersWindow{}, err
+	}
+	from, to, ok := strings.Cut(fields[2], "-")
+	if !ok {
+		return minRunnersWindow{}, fmt.Errorf("time range %q is not HH:MM-HH:MM", fields[2])
+	}
+	if w.start, err = parseClock(from); err != nil {
+		return minRunnersWindow{}, err
+	}
+	if w.end, err = parseClock(to); err != nil {
+		return minRunnersWindow{}, err
+	}
+	if w.start == w.end {
+		return minRunnersWindow{}, fmt.Errorf("time range %q is empty", fields[2])
+	}
+	w.loc = time.UTC
+	if len(fields) == 4 {
+		if w.loc, err = time.LoadLocation(fields[3]); err != nil {
+			return minRunnersWindow{}, fmt.Errorf("time zone %q: %w", fields[3], err)
+		}
+	}
+	return w, nil
+}
+
+// parseDays parses a day ("Mon"), a range of days ("Mon-Fri") or "daily".
+func parseDays(v string) ([7]bool, error) {
+	var days [7]bool
+	if strings.EqualFold(v, "daily") {
+		for i := range days {
+			days[i] = true
+		}
+		return days, nil
+	}
+	from, to, isRange := strings.Cut(strings.ToLower(v), "-")
+	first, ok := weekdays[from]
+	last := first
+	if isRange {
+		var lastOK bool
+		last, lastOK = weekdays[to]
+		ok = ok && lastOK
+	}
+	if !ok {
+		return days, fmt.Errorf("days %q are not a day, a range like Mon-Fri, or daily", v)
+	}
+	for d := first; ; d = (d + 1) % 7 {
+		days[d] = true
+		if d == last {
+			break
+		}
+	}
+	return days, nil
+}
+
+// parseClock parses HH:MM, or 24:00 for the end of the day, into minutes
+// after midnight.
+func parseClock(v string) (int, error) {
+	h, m, ok := strings.Cut(v, ":")
+	hours, herr := strconv.Atoi(h)
+	minutes, merr := strconv.Atoi(m)
+	if !ok || herr != nil || merr != nil || hours < 0 || minutes < 0 || minutes > 59 || hours > 24 || hours == 24 && minutes > 0 {
+		return 0, fmt.Errorf("time %q is not HH:MM", v)
+	}
+	return hours*60 + minutes, nil
+}
+
+// at returns the count of the first window covering t.
+func (s minRunnersSchedule) at(t time.Time) (count int, ok bool) {
+	for _, w := range s {
+		if w.covers(t) {
+			return w.count, true
+		}
+	}
+	return 0, false
+}
+
+func (w minRunnersWindow) covers(t time.Time) bool {
+	t = t.In(w.loc)
+	minute := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.days[t.Weekday()] && minute >= w.start && minute < w.end
+	}
+	// The window started the day before when t is before its end.
+	if minute < w.end {
+		return w.days[(t.Weekday()+6)%7]
+	}
+	return w.days[t.Weekday()] && minute >= w.start
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMinRunnersSchedule(t *testing.T) {
+	schedule, err := parseMinRunnersSchedule("3 Mon-Fri 08:00-17:00 America/Los_Angeles, 1 Fri-Sun 22:00-02:00, 5 daily 12:00-13:00 UTC")
+	if err != nil {
+		t.Fatalf("parseMinRunnersSchedule: %v", err)
+	}
+	pacific, _ := time.LoadLocation("America/Los_Angeles")
+	for _, tc := range []struct {
+		at    time.Time
+		count int
+		ok    bool
+	}{
+		// Wednesday 2026-10-14.
+		{at: time.Date(2026, 10, 14, 8, 0, 0, 0, pacific), count: 3, ok: true},
+		{at: time.Date(2026, 10, 14, 16, 59, 0, 0, pacific), count: 3, ok: true},
+		{at: time.Date(2026, 10, 14, 17, 0, 0, 0, pacific), ok: false},
+		{at: time.Date(2026, 10, 14, 7, 59, 0, 0, pacific), ok: false},
+		// Saturday 2026-10-17: not a weekday, but inside the overnight window.
+		{at: time.Date(2026, 10, 17, 10, 0, 0, 0, pacific), ok: false},
+		{at: time.Date(2026, 10, 17, 23, 0, 0, 0, time.UTC), count: 1, ok: true},
+		// Monday 01:00 UTC belongs to Sunday's window.
+		{at: time.Date(2026, 10, 19, 1, 0, 0, 0, time.UTC), count: 1, ok: true},
+		// Thursday 01:00 UTC does not: Wednesday is outside Fri-Sun.
+		{at: time.Date(2026, 10, 15, 1, 0, 0, 0, time.UTC), ok: false},
+		// The first matching window wins: 12:30 UTC is 05:30 Pacific.
+		{at: time.Date(2026, 10, 14, 12, 30, 0, 0, time.UTC), count: 5, ok: true},
+	} {
+		count, ok := schedule.at(tc.at)
+		if count != tc.count || ok != tc.ok {
+			t.Errorf("at(%s) = %d, %v; want %d, %v", tc.at, count, ok, tc.count, tc.ok)
+		}
+	}
+
+	for _, bad := range []string{
+		"3 Mon-Fri",
+		"-1 Mon 08:00-20:00",
+		"3 Weekdays 08:00-20:00",
+		"3 Mon 8-20",
+		"3 Mon 08:00-24:30",
+		"3 Mon 08:00-08:00",
+		"3 Mon 08:00-20:00 PST8",
+	} {
+		if _, err := parseMinRunnersSchedule(bad); err == nil {
+			t.Errorf("parseMinRunnersSchedule(%q) should fail", bad)
+		}
+	}
+}
+
+func TestMinRunnersAtFallsBackToMinRunners(t *testing.T) {
+	schedule, err := parseMinRunnersSchedule("3 Mon-Fri 08:00-20:00")
+	if err != nil {
+		t.Fatalf("parseMinRunnersSchedule: %v", err)
+	}
+	s := &gcpRunnerScaler{minRunners: 1, minRunnersSchedule: schedule}
+	if got := s.minRunnersAt(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)); got != 3 {
+		t.Fatalf("Friday morning min runners = %d, want 3", got)
+	}
+	if got := s.minRunnersAt(time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)); got != 1 {
+		t.Fatalf("Saturday morning min runners = %d, want --min-runners (1)", got)
+	}
+	s.setMinRunnersSchedule(nil)
+	if got := s.minRunnersAt(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)); got != 1 {
+		t.Fatalf("min runners without a schedule = %d, want 1", got)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/secevents"
+	"extras/scaler/internal/supervise"
+)
+
+// securityEvents are the events --security-events ships to SecOps: who
+// registered and removed runners, which VMs ran as which identity, and
+// who changed the scaler's behavior.
+var securityEvents = map[string]bool{
+	eventRunnerRegistered: true,
+	eventRunnerRemoved:    true,
+	eventVMCreated:        true,
+	eventVMDeleted:        true,
+	eventVMAdopted:        true,
+	eventDrainStarted:     true,
+	eventDrainComplete:    true,
+	eventDrainTimeout:     true,
+	eventConfigChanged:    true,
+	eventAdminAction:      true,
+}
+
+// identityReporter is implemented by providers whose VMs run as a cloud
+// identity (GCP).
+type identityReporter interface {
+	ServiceAccount(ctx context.Context, labels []string) (string, error)
+}
+
+// setupSecurityEvents returns logger with the security events it logs also
+// shipped to --security-events, and a function that writes those still
+// queued and stops. Without the flag it returns logger as it is.
+func setupSecurityEvents(ctx context.Context, cfg config, logger *slog.Logger) (*slog.Logger, func(), error) {
+	if cfg.securityEvents == "" {
+		return logger, func() {}, nil
+	}
+	sink, err := secevents.New(ctx, cfg.securityEvents)
+	if err != nil {
+		return nil, nil, err
+	}
+	exporter := secevents.NewExporter(sink)
+	// The exporter outlives ctx so the events of the shutdown itself are
+	// written too.
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer supervise.Recover("security_events")
+		exporter.Run(runCtx)
+	}()
+	stop := func() {
+		cancel()
+		<-done
+	}
+	return slog.New(withSecurityEvents(logger.Handler(), exporter, cfg.scaleSetName)), stop, nil
+}
+
+// withSecurityEvents returns h with the records of securityEvents also
+// emitted to exporter.
+func withSecurityEvents(h slog.Handler, exporter *secevents.Exporter, scaleSet string) slog.Handler {
+	next, ok := h.(*historyHandler)
+	if !ok {
+		next = &historyHandler{Handler: h}
+	} else {
+		copied := *next
+		next = &copied
+	}
+	next.security = func(e historyEvent) {
+		if securityEvents[e.Event] {
+			exporter.Emit(secevents.Event{Time: e.Time, Event: e.Event, ScaleSet: scaleSet, Message: e.Message, Attrs: e.Attrs})
+		}
+	}
+	return next
+}
+
+// runnerRegistered logs that GitHub registered runner name, whose JIT
+// config jit is.
+func (s *gcpRunnerScaler) runnerRegistered(name string, jit *scaleset.RunnerScaleSetJitRunnerConfig) {
+	attrs := []any{"event", eventRunnerRegistered, "runner", name}
+	if jit.Runner != nil {
+		attrs = append(attrs, "runner_id", jit.Runner.ID)
+	}
+	s.logger.Info("registered runner with GitHub", attrs...)
+}
+
+// vmIdentity returns the service account VMs for a job with labels run as,
+// or "" when the provider has none or it can't be read.
+func (s *gcpRunnerScaler) vmIdentity(ctx context.Context, labels []string) string {
+	ir, ok := s.vmManager.(identityReporter)
+	if !ok {
+		return ""
+	}
+	email, err := ir.ServiceAccount(ctx, labels)
+	if err != nil {
+		s.logger.Debug("failed to read the VMs' service account", "error", err)
+		return ""
+	}
+	return email
+}
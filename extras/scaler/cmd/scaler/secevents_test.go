@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"extras/scaler/internal/secevents"
+)
+
+type fakeSecuritySink struct {
+	mu     sync.Mutex
+	events []secevents.Event
+}
+
+func (f *fakeSecuritySink) Write(_ context.Context, events []secevents.Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, events...)
+	return nil
+}
+
+func (f *fakeSecuritySink) String() string { return "fake" }
+
+func TestSecurityEventsShipsOnlySecurityEvents(t *testing.T) {
+	history := newEventHistory(10)
+	sink := &fakeSecuritySink{}
+	exporter := secevents.NewExporter(sink)
+	h := newHistoryHandler(slog.NewTextHandler(io.Discard, nil), history)
+	logger := slog.New(withSecurityEvents(h, exporter, "linux-gpu")).With("pool", "linux-gpu")
+
+	logger.Info("created runner VM", "event", eventVMCreated, "vm", "linux-test-1", "service_account", "ci-runner@p.iam.gserviceaccount.com")
+	logger.Info("job started", "event", eventJobStarted, "runner", "linux-test-1")
+	logger.Info("admin API: drain requested", "event", eventAdminAction, "action", "drain", "remote", "10.0.0.7:51234")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	exporter.Run(ctx)
+
+	if len(sink.events) != 2 {
+		t.Fatalf("shipped %d events, want vm_created and admin_action: %+v", len(sink.events), sink.events)
+	}
+	created := sink.events[0]
+	if created.Event != eventVMCreated || created.ScaleSet != "linux-gpu" || created.Attrs["service_account"] != "ci-runner@p.iam.gserviceaccount.com" || created.Attrs["pool"] != "linux-gpu" {
+		t.Fatalf("vm_created = %+v", created)
+	}
+	if got := sink.events[1].Attrs["remote"]; got != "10.0.0.7:51234" {
+		t.Fatalf("admin_action remote = %q", got)
+	}
+	if len(history.list(0, "")) != 3 {
+		t.Fatal("the history should still record every event")
+	}
+}
+
+func TestLoadConfigSecurityEvents(t *testing.T) {
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--security-events=projects/p/topics/t"); err == nil {
+		t.Fatal("--security-events without a kind should fail")
+	}
+	if _, err := testLoadConfig(t, "--url=https://github.com/o/r", "--security-events=logging:projects/p/logs/scaler-security"); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+}
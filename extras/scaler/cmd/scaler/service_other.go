@@ -0,0 +1,33 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runAsService reports whether the scaler ran as a Windows service, which
+// it never does here.
+func runAsService() bool { return false }
+
+// runService implements `scaler service`, which only Windows has.
+func runService(context.Context, []string, io.Writer) error {
+	return errors.New("scaler service is only available on Windows; use the systemd units in deploy/")
+}
+
+// notifyDrain relays SIGUSR1, which enters drain mode, to c.
+func notifyDrain(c chan<- os.Signal) { signal.Notify(c, syscall.SIGUSR1) }
+
+// notifyReload relays SIGHUP, which re-reads the flags and --config, to c.
+func notifyReload(c chan<- os.Signal) { signal.Notify(c, syscall.SIGHUP) }
+
+// notifyLogLevel relays SIGUSR2, which cycles the log level, to c.
+func notifyLogLevel(c chan<- os.Signal) { signal.Notify(c, syscall.SIGUSR2) }
+
+// stopNotify stops relaying to c.
+func stopNotify(c chan<- os.Signal) { signal.Stop(c) }
@@ -0,0 +1,227 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// The service controls that stand in for the signals the scaler takes
+// elsewhere: `sc.exe control NAME 128` enters drain mode as SIGUSR1 does,
+// 129 cycles the log level as SIGUSR2 does, and paramchange re-reads the
+// flags and --config as SIGHUP does.
+const (
+	controlDrain    = svc.Cmd(128)
+	controlLogLevel = svc.Cmd(129)
+)
+
+// Event IDs of the scaler's Event Log entries, by level.
+const (
+	eventIDInfo    = 1
+	eventIDWarning = 2
+	eventIDError   = 3
+)
+
+// serviceControl is the os.Signal a service control is relayed as.
+type serviceControl svc.Cmd
+
+func (c serviceControl) String() string { return fmt.Sprintf("service control %d", svc.Cmd(c)) }
+func (c serviceControl) Signal()        {}
+
+// controlRelays maps each service control to the channels notifyDrain,
+// notifyReload and notifyLogLevel registered for it.
+var controlRelays = struct {
+	sync.Mutex
+	chans map[svc.Cmd][]chan<- os.Signal
+}{chans: make(map[svc.Cmd][]chan<- os.Signal)}
+
+func relayControl(cmd svc.Cmd, c chan<- os.Signal) {
+	controlRelays.Lock()
+	defer controlRelays.Unlock()
+	controlRelays.chans[cmd] = append(controlRelays.chans[cmd], c)
+}
+
+// deliverControl relays cmd to its channels, dropping it for a channel
+// whose last one is still pending, as signal.Notify does.
+func deliverControl(cmd svc.Cmd) {
+	controlRelays.Lock()
+	defer controlRelays.Unlock()
+	for _, c := range controlRelays.chans[cmd] {
+		select {
+		case c <- serviceControl(cmd):
+		default:
+		}
+	}
+}
+
+// notifyDrain relays SIGUSR1's stand-in, control 128, to c.
+func notifyDrain(c chan<- os.Signal) { relayControl(controlDrain, c) }
+
+// notifyReload relays paramchange, and SIGHUP for completeness, to c.
+func notifyReload(c chan<- os.Signal) {
+	relayControl(svc.ParamChange, c)
+	signal.Notify(c, syscall.SIGHUP)
+}
+
+// notifyLogLevel relays SIGUSR2's stand-in, control 129, to c.
+func notifyLogLevel(c chan<- os.Signal) { relayControl(controlLogLevel, c) }
+
+// stopNotify stops relaying to c.
+func stopNotify(c chan<- os.Signal) {
+	signal.Stop(c)
+	controlRelays.Lock()
+	defer controlRelays.Unlock()
+	for cmd, chans := range controlRelays.chans {
+		controlRelays.chans[cmd] = slices.DeleteFunc(chans, func(ch chan<- os.Signal) bool { return ch == c })
+	}
+}
+
+// runAsService runs the scaler under the service control manager when the
+// process is a Windows service, and reports whether it was.
+func runAsService() bool {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return false
+	}
+	// The service's name, and so its Event Log source, is only known once
+	// the service control manager calls Execute.
+	if err := svc.Run("", &scalerService{}); err != nil {
+		os.Exit(1)
+	}
+	return true
+}
+
+// scalerService runs the scaler as a Windows service, logging to the Event
+// Log under the service's name.
+type scalerService struct{}
+
+func (s *scalerService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	elog, err := eventlog.Open(args[0])
+	if err != nil {
+		return true, 1
+	}
+	defer elog.Close()
+
+	cfg, err := loadConfig(flag.NewFlagSet(args[0], flag.ContinueOnError), os.Args[1:])
+	if err != nil {
+		elog.Error(eventIDError, "invalid configuration: "+err.Error())
+		return true, 2
+	}
+	// loadConfig has validated the format and level.
+	logger, logLevel, _ := newSinkLogger(func(level slog.Level, line string) error {
+		switch {
+		case level >= slog.LevelError:
+			return elog.Error(eventIDError, line)
+		case level >= slog.LevelWarn:
+			return elog.Warning(eventIDWarning, line)
+		default:
+			return elog.Info(eventIDInfo, line)
+		}
+	}, cfg.logFormat, cfg.logLevel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan int, 1)
+	go func() { done <- serve(ctx, cfg, logger, logLevel) }()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown | svc.AcceptParamChange}
+
+	for {
+		select {
+		case code := <-done:
+			return true, uint32(code)
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				// Deleting the VMs can take a while.
+				status <- svc.Status{State: svc.StopPending, WaitHint: uint32((2 * time.Minute).Milliseconds())}
+				cancel()
+			case svc.ParamChange, controlDrain, controlLogLevel:
+				deliverControl(req.Cmd)
+			}
+		}
+	}
+}
+
+// runService implements `scaler service install NAME [flags]` and `scaler
+// service remove NAME`, which register the scaler, run with the flags, as
+// a Windows service and Event Log source, or unregister it.
+func runService(_ context.Context, args []string, out io.Writer) error {
+	if len(args) < 2 || (args[0] != "install" && args[0] != "remove") || (args[0] == "remove" && len(args) != 2) {
+		return errors.New("usage: scaler service install NAME [flags] | scaler service remove NAME")
+	}
+	name := args[1]
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to the service control manager (needs an elevated prompt): %w", err)
+	}
+	defer m.Disconnect()
+	if args[0] == "remove" {
+		return removeService(m, name, out)
+	}
+
+	flags := args[2:]
+	if _, err := loadConfig(flag.NewFlagSet("scaler service install", flag.ContinueOnError), flags); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName:      "GitHub Runner Scaler (" + name + ")",
+		Description:      "Creates VMs for the GitHub Actions jobs of a runner scale set.",
+		StartType:        mgr.StartAutomatic,
+		DelayedAutoStart: true,
+	}, append([]string{"run"}, flags...)...)
+	if err != nil {
+		return fmt.Errorf("creating service %s: %w", name, err)
+	}
+	defer s.Close()
+	// Restart=always, RestartSec=10 in the systemd units.
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: 10 * time.Second}}, uint32((24 * time.Hour).Seconds())); err == nil {
+		err = s.SetRecoveryActionsOnNonCrashFailures(true)
+	}
+	if err == nil {
+		err = eventlog.InstallAsEventCreate(name, eventlog.Error|eventlog.Warning|eventlog.Info)
+	}
+	if err != nil {
+		s.Delete()
+		return fmt.Errorf("setting up service %s: %w", name, err)
+	}
+	fmt.Fprintf(out, "installed service %s; start it with: sc.exe start %s\n", name, name)
+	return nil
+}
+
+func removeService(m *mgr.Mgr, name string, out io.Writer) error {
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s: %w", name, err)
+	}
+	defer s.Close()
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("deleting service %s: %w", name, err)
+	}
+	if err := eventlog.Remove(name); err != nil {
+		return fmt.Errorf("removing Event Log source %s: %w", name, err)
+	}
+	fmt.Fprintf(out, "removed service %s; it stops once the scaler exits\n", name)
+	return nil
+}
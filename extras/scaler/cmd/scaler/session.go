@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/actions/scaleset"
+	"github.com/actions/scaleset/listener"
+)
+
+const (
+	// sessionRetryMin and sessionRetryMax bound the backoff between
+	// attempts to create a new message session after the old one broke.
+	sessionRetryMin = 5 * time.Second
+	sessionRetryMax = 2 * time.Minute
+	// sessionCloseTimeout bounds deleting a broken session, which fails
+	// anyway when GitHub cannot be reached.
+	sessionCloseTimeout = 10 * time.Second
+)
+
+// messageSession is the listener's view of a message session, which
+// *scaleset.MessageSessionClient implements.
+type messageSession interface {
+	listener.Client
+	Close(ctx context.Context) error
+}
+
+// sessionError marks an error of the message session itself, as opposed
+// to one of the scaler handling a message, so run knows a new session may
+// get the listener going again.
+type sessionError struct{ err error }
+
+func (e *sessionError) Error() string { return e.err.Error() }
+func (e *sessionError) Unwrap() error { return e.err }
+
+// sessionRef holds the message session the listener polls. When the session
+// breaks, e.g. on a token GitHub no longer refreshes, a network partition
+// or a run of 5xx errors, reconnect swaps in a new one and the listener
+// resumes on it. Exiting instead would leave the restart to systemd.
+type sessionRef struct {
+	logger *slog.Logger
+	// connect creates a new session.
+	connect func(ctx context.Context) (messageSession, error)
+	// retryMin and retryMax bound the backoff between connect attempts.
+	retryMin, retryMax time.Duration
+
+	mu      sync.Mutex
+	session messageSession
+}
+
+func newSessionRef(logger *slog.Logger, session messageSession, connect func(context.Context) (messageSession, error)) *sessionRef {
+	return &sessionRef{
+		logger:   logger,
+		connect:  connect,
+		retryMin: sessionRetryMin,
+		retryMax: sessionRetryMax,
+		session:  session,
+	}
+}
+
+func (r *sessionRef) get() messageSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.session
+}
+
+func (r *sessionRef) GetMessage(ctx context.Context, lastMessageID, maxCapacity int) (*scaleset.RunnerScaleSetMessage, error) {
+	msg, err := r.get().GetMessage(ctx, lastMessageID, maxCapacity)
+	if err != nil {
+		return nil, &sessionError{err}
+	}
+	return msg, nil
+}
+
+func (r *sessionRef) DeleteMessage(ctx context.Context, messageID int) error {
+	if err := r.get().DeleteMessage(ctx, messageID); err != nil {
+		return &sessionError{err}
+	}
+	return nil
+}
+
+func (r *sessionRef) Session() scaleset.RunnerScaleSetSession {
+	return r.get().Session()
+}
+
+// Close deletes the current session.
+func (r *sessionRef) Close(ctx context.Context) error {
+	return r.get().Close(ctx)
+}
+
+// reconnect deletes the broken session and creates a new one, retrying
+// with a jittered backoff until it succeeds or ctx is done. GitHub allows
+// one session per scale set, so while the broken one cannot be deleted the
+// new one is refused until GitHub times the old one out.
+func (r *sessionRef) reconnect(ctx context.Context) error {
+	closeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sessionCloseTimeout)
+	if err := r.get().Close(closeCtx); err != nil {
+		r.logger.Debug("failed to delete the broken message session", "error", err)
+	}
+	cancel()
+
+	delay := r.retryMin
+	for attempt := 1; ; attempt++ {
+		session, err := r.connect(ctx)
+		if err == nil {
+			r.mu.Lock()
+			r.session = session
+			r.mu.Unlock()
+			r.logger.Info("message session re-established", "event", eventSessionResumed, "attempts", attempt)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		// Wait between half and all of delay, so scalers that lost their
+		// sessions together do not retry in step.
+		wait := delay/2 + rand.N(delay/2+1)
+		r.logger.Warn("failed to create message session, retrying", "attempt", attempt, "retry_in", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(wait):
+		}
+		delay = min(2*delay, r.retryMax)
+	}
+}
+
+// Compile-time check that sessionRef implements listener.Client.
+var _ listener.Client = (*sessionRef)(nil)
+
+// isSessionError reports whether err came from the message session.
+func isSessionError(err error) bool {
+	var se *sessionError
+	return errors.As(err, &se)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/actions/scaleset"
+)
+
+type fakeSession struct {
+	id     int
+	err    error
+	closed bool
+}
+
+func (s *fakeSession) GetMessage(context.Context, int, int) (*scaleset.RunnerScaleSetMessage, error) {
+	return nil, s.err
+}
+
+func (s *fakeSession) DeleteMessage(context.Context, int) error { return s.err }
+
+func (s *fakeSession) Session() scaleset.RunnerScaleSetSession {
+	return scaleset.RunnerScaleSetSession{Statistics: &scaleset.RunnerScaleSetStatistic{TotalAssignedJobs: s.id}}
+}
+
+func (s *fakeSession) Close(context.Context) error {
+	s.closed = true
+	return s.err
+}
+
+func TestSessionRefReconnects(t *testing.T) {
+	broken := &fakeSession{id: 1, err: errors.New("token expired")}
+	attempts := 0
+	next := &fakeSession{id: 2}
+	r := newSessionRef(slog.New(slog.DiscardHandler), broken, func(context.Context) (messageSession, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("session already exists")
+		}
+		return next, nil
+	})
+	r.retryMin, r.retryMax = time.Millisecond, 2*time.Millisecond
+
+	// Errors of the session are told apart from the scaler's, also once
+	// the listener wrapped them.
+	_, err := r.GetMessage(context.Background(), 0, 1)
+	if !isSessionError(fmt.Errorf("failed to get message: %w", err)) {
+		t.Fatalf("GetMessage error %v is not a session error", err)
+	}
+	if isSessionError(errors.New("failed to handle job started")) {
+		t.Fatal("a scaler error is a session error")
+	}
+
+	if err := r.reconnect(context.Background()); err != nil {
+		t.Fatalf("reconnect: %v", err)
+	}
+	if !broken.closed || attempts != 3 {
+		t.Fatalf("closed = %v after %d attempts, want the broken session deleted and 3 attempts", broken.closed, attempts)
+	}
+	if got := r.Session().Statistics.TotalAssignedJobs; got != 2 {
+		t.Fatalf("session = %d, want the new one", got)
+	}
+	if _, err := r.GetMessage(context.Background(), 0, 1); err != nil {
+		t.Fatalf("GetMessage on the new session: %v", err)
+	}
+}
+
+func TestSessionRefReconnectStopsWithContext(t *testing.T) {
+	r := newSessionRef(slog.New(slog.DiscardHandler), &fakeSession{}, func(context.Context) (messageSession, error) {
+		return nil, errors.New("unreachable")
+	})
+	r.retryMin, r.retryMax = time.Hour, time.Hour
+	ctx, cancel := context.WithCancelCause(context.Background())
+	errStop := errors.New("stop")
+	time.AfterFunc(10*time.Millisecond, func() { cancel(errStop) })
+	if err := r.reconnect(ctx); !errors.Is(err, errStop) {
+		t.Fatalf("reconnect = %v, want the context's cause", err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/actions/scaleset"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	gcpvm "extras/scaler/internal/gcp"
+)
+
+// slottedProvider is implemented by providers whose VMs can run several
+// runners at once, e.g. one per MIG slice of an A100 or --runner-slots.
+type slottedProvider interface {
+	RunnerSlots(ctx context.Context, labels []string) (int, error)
+	CreateSlottedVM(ctx context.Context, runners []gcpvm.RunnerSlot, labels []string) (string, error)
+}
+
+// vmPlan is a VM to create during a scale-up: the runs-on labels it is
+// routed by and how many runners it runs.
+type vmPlan struct {
+	labels []string
+	slots  int
+}
+
+// planVMs plans the VMs for the given number of new runners. A VM can run
+// several of them, but the plans never add up to more than limit runners, so
+// a scale-up stays within --max-runners.
+func (s *gcpRunnerScaler) planVMs(ctx context.Context, runners, limit int) []vmPlan {
+	var plans []vmPlan
+	for planned := 0; planned < runners; {
+		plan := vmPlan{labels: s.claimLabels(), slots: 1}
+		if slotted, ok := s.vmManager.(slottedProvider); ok {
+			slots, err := slotted.RunnerSlots(ctx, plan.labels)
+			if err != nil {
+				s.logger.Warn("failed to look up runner slots, creating a single-runner VM", "error", err)
+			} else {
+				plan.slots = max(1, min(slots, limit-planned))
+			}
+		}
+		plans = append(plans, plan)
+		planned += plan.slots
+	}
+	return plans
+}
+
+// deferPlans gives up plans a scale-up will not create now, returning their
+// labels to the queue so a later scale-up plans them again.
+func (s *gcpRunnerScaler) deferPlans(plans []vmPlan) {
+	for _, plan := range plans {
+		s.unclaimLabels(plan.labels)
+	}
+}
+
+// createSlottedRunners registers plan.slots runners with GitHub and creates
+// one VM running all of them.
+func (s *gcpRunnerScaler) createSlottedRunners(ctx context.Context, slotted slottedProvider, plan vmPlan) (outcome vmOutcome) {
+	ctx, span := tracer.Start(ctx, "create_runners", trace.WithAttributes(attribute.Int("runner.slots", plan.slots)))
+	var err error
+	defer func() { endSpan(span, err) }()
+
+	runners := make([]gcpvm.RunnerSlot, 0, plan.slots)
+	// abandon removes the runners registered so far after a failure.
+	abandon := func() {
+		for _, r := range runners {
+			s.latency.forget(r.Name)
+			s.removeRunnerFromGitHub(ctx, r.Name)
+		}
+		s.unclaimLabels(plan.labels)
+	}
+	for range plan.slots {
+		name := s.newRunnerName()
+		var jit *scaleset.RunnerScaleSetJitRunnerConfig
+		jit, err = s.scalesetClient.get().GenerateJitRunnerConfig(
+			ctx,
+			&scaleset.RunnerScaleSetJitRunnerSetting{Name: name},
+			s.scaleSetID,
+		)
+		if err != nil {
+			s.jitFailed(name, err)
+			outcome.Runner, outcome.Stage, outcome.Error = name, "jit_config", err.Error()
+			abandon()
+			return outcome
+		}
+		s.reconciler.jitSucceeded()
+		s.runnerRegistered(name, jit)
+		s.latency.registered(name, time.Now())
+		runners = append(runners, gcpvm.RunnerSlot{Name: name, JITConfig: jit.EncodedJITConfig})
+	}
+	outcome.Runner = runners[0].Name
+
+	vmName, err := slotted.CreateSlottedVM(ctx, runners, plan.labels)
+	if err != nil {
+		s.logger.Error("failed to create VM", "event", eventVMCreateFailed, "runner", outcome.Runner, "slots", plan.slots, "error", err)
+		s.createFailed(ctx, err)
+		s.stall.failed(err, time.Now())
+		outcome.Stage, outcome.Error = "create_vm", err.Error()
+		abandon()
+		return outcome
+	}
+
+	for _, r := range runners {
+		s.runners.booting(ctx, r.Name)
+	}
+	s.createSucceeded()
+	s.stall.clear()
+	outcome.VM = vmName
+	s.logger.Info("created runner VM", "event", eventVMCreated, "vm", vmName, "runner", outcome.Runner, "slots", plan.slots, "service_account", s.vmIdentity(ctx, plan.labels))
+	return outcome
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	gcpvm "extras/scaler/internal/gcp"
+)
+
+// slotProvider is a provider whose VMs run up to slots runners each.
+type slotProvider struct {
+	fakeAdminProvider
+	slots int
+
+	mu      sync.Mutex
+	created [][]string
+}
+
+func (p *slotProvider) ActiveCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, runners := range p.created {
+		n += len(runners)
+	}
+	return n
+}
+
+func (p *slotProvider) RunnerSlots(context.Context, []string) (int, error) { return p.slots, nil }
+
+func (p *slotProvider) CreateSlottedVM(_ context.Context, runners []gcpvm.RunnerSlot, _ []string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var names []string
+	for _, r := range runners {
+		names = append(names, r.Name)
+	}
+	p.created = append(p.created, names)
+	return names[0], nil
+}
+
+func TestScaleUpPacksRunnersIntoSlots(t *testing.T) {
+	provider := &slotProvider{slots: 3}
+	client, actions := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      provider,
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "a100",
+		maxRunners:     5,
+	}
+
+	if _, err := s.HandleDesiredRunnerCount(context.Background(), 8); err != nil {
+		t.Fatalf("HandleDesiredRunnerCount: %v", err)
+	}
+	s.creates.Wait()
+
+	// Five runners fit --max-runners: a full VM and one with two slots.
+	if len(provider.created) != 2 || provider.ActiveCount() != 5 {
+		t.Fatalf("created = %v, want VMs with 3 and 2 runners", provider.created)
+	}
+	if len(actions.registered) != 5 {
+		t.Fatalf("registered = %v, want one runner per slot", actions.registered)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	gcpvm "extras/scaler/internal/gcp"
+	"extras/scaler/internal/vmstate"
+)
+
+// statusOptions are the flags of `scaler status`.
+type statusOptions struct {
+	target *adminFlags
+	json   bool
+}
+
+func (o *statusOptions) register(fs *flag.FlagSet) {
+	o.target = newAdminFlags(fs)
+	fs.BoolVar(&o.json, "json", false, "Print the raw JSON")
+}
+
+// runStatus implements `scaler status [flags]`, printing a running scaler's
+// state, VMs and GPU capacity from its admin API.
+func runStatus(ctx context.Context, args []string, out io.Writer) error {
+	var o statusOptions
+	fs := flag.NewFlagSet("scaler status", flag.ContinueOnError)
+	o.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := o.target.client()
+	if err != nil {
+		return err
+	}
+	body, err := client.do(ctx, http.MethodGet, "/api/v1/status", nil)
+	if err != nil {
+		return err
+	}
+	if o.json {
+		_, err := out.Write(body)
+		return err
+	}
+	var st adminStatus
+	if err := json.Unmarshal(body, &st); err != nil {
+		return err
+	}
+	printStatus(out, st, time.Now())
+	return nil
+}
+
+// vmState describes what vm is doing: creating, booting, idle or busy.
+func vmState(vm vmstate.VM) string {
+	switch {
+	case vm.Pending:
+		return "creating"
+	case vm.Busy:
+		return "busy"
+	case vm.Phase == gcpvm.BootPhaseFailed:
+		return "boot failed: " + vm.BootError
+	case vm.Phase != "" && vm.Phase != gcpvm.BootPhaseRunnerOnline:
+		return "booting (" + vm.Phase + ")"
+	}
+	return "idle"
+}
+
+// vmAge is how long ago vm was created, or "-" when unknown.
+func vmAge(vm vmstate.VM, now time.Time) string {
+	if vm.CreatedAt.IsZero() {
+		return "-"
+	}
+	return now.Sub(vm.CreatedAt).Round(time.Second).String()
+}
+
+func printStatus(out io.Writer, st adminStatus, now time.Time) {
+	state := "running"
+	switch {
+	case st.Draining:
+		state = "draining"
+	case st.Paused:
+		state = "paused"
+	}
+	fmt.Fprintf(out, "Scale set %s (%s): %s, %d VMs, min %d, max %d\n",
+		st.ScaleSet, st.Provider, state, len(st.VMs), st.MinRunners, st.MaxRunners)
+	// Scalers from before the status had a build leave it empty.
+	if st.Build.Version != "" {
+		fmt.Fprintf(out, "Running %s\n", st.Build)
+	}
+	if o := st.MinRunnersOverride; o != nil {
+		fmt.Fprintf(out, "Min runners pinned at %d for %s more\n", o.MinRunners, max(o.Until.Sub(now), 0).Round(time.Second))
+	}
+	if p := st.Pause; p != nil {
+		fmt.Fprintf(out, "Paused for %s", now.Sub(p.Since).Round(time.Second))
+		if p.Reason != "" {
+			fmt.Fprintf(out, " (%s)", p.Reason)
+		}
+		if p.Until != nil {
+			fmt.Fprintf(out, "; resumes in %s", max(p.Until.Sub(now), 0).Round(time.Second))
+		}
+		fmt.Fprintln(out)
+	}
+	if d := st.Drain; d != nil {
+		fmt.Fprintf(out, "Draining for %s: %d VMs left, %d busy", now.Sub(d.StartedAt).Round(time.Second), d.RemainingVMs, d.BusyVMs)
+		if d.Deadline != nil {
+			fmt.Fprintf(out, "; timeout in %s, then %s them", max(d.Deadline.Sub(now), 0).Round(time.Second), d.TimeoutAction)
+		}
+		fmt.Fprintln(out)
+	}
+	if len(st.Deleting) > 0 {
+		fmt.Fprintf(out, "Deleting %d VMs: %s\n", len(st.Deleting), strings.Join(st.Deleting, ", "))
+	}
+	if b := st.Budget; b != nil {
+		fmt.Fprintf(out, "Daily budget $%.2f: $%.2f spent, $%.2f projected at $%.2f/h",
+			b.LimitUSD, b.SpentUSD, b.ProjectedUSD, b.HourlyUSD)
+		if b.over() {
+			fmt.Fprint(out, "; not scaling up")
+		}
+		fmt.Fprintln(out)
+	}
+	if c := st.IdleCost; c != nil {
+		fmt.Fprintf(out, "Idle today: %.1f of %.1f VM hours (%.0f%%), $%.2f of the $%.2f alert threshold\n",
+			c.IdleVMHours, c.VMHours, 100*c.idleFraction(), c.IdleUSD, c.ThresholdUSD)
+	}
+	if f := st.FairShare; f != nil {
+		fmt.Fprintf(out, "Fair share %d of %d GPUs between %d pools (weight %d, wants %d)\n", f.Share, f.Capacity, f.Pools, f.Weight, f.Demand)
+	}
+	if until := st.CreateBackoffUntil; until != nil {
+		fmt.Fprintf(out, "Backing off after failed creates; next probe in %s\n", max(until.Sub(now), 0).Round(time.Second))
+	}
+	if c := st.Capacity; c != nil && len(c.ZoneBackoffs) > 0 {
+		zones := make([]string, 0, len(c.ZoneBackoffs))
+		for zone, until := range c.ZoneBackoffs {
+			zones = append(zones, fmt.Sprintf("%s (probe in %s)", zone, max(until.Sub(now), 0).Round(time.Second)))
+		}
+		sort.Strings(zones)
+		fmt.Fprintf(out, "Zones skipped after failed creates: %s\n", strings.Join(zones, ", "))
+	}
+	if c := st.Capacity; c != nil && len(c.ZoneCooldowns) > 0 {
+		zones := make([]string, 0, len(c.ZoneCooldowns))
+		for _, z := range c.ZoneCooldowns {
+			zones = append(zones, fmt.Sprintf("%s %s (retry in %s)", z.Zone, z.GPUType, max(z.Until.Sub(now), 0).Round(time.Second)))
+		}
+		fmt.Fprintf(out, "Zones skipped after stockouts: %s\n", strings.Join(zones, ", "))
+	}
+	if c := st.Capacity; c != nil && len(c.ZonePreemptions) > 0 {
+		zones := make([]string, 0, len(c.ZonePreemptions))
+		for zone, p := range c.ZonePreemptions {
+			zones = append(zones, fmt.Sprintf("%s (%d preempted, %d created)", zone, p.Preempted, p.Created))
+		}
+		sort.Strings(zones)
+		fmt.Fprintf(out, "Zones with preempted Spot VMs: %s\n", strings.Join(zones, ", "))
+	}
+	if image := st.Image; image != nil {
+		fmt.Fprintf(out, "Image %s from family %s, published %s, checked %s ago",
+			image.Name, image.Family, image.CreatedAt.Format(time.DateOnly), now.Sub(image.CheckedAt).Round(time.Second))
+		if image.OutdatedVMs > 0 {
+			fmt.Fprintf(out, "; %d VMs on earlier images", image.OutdatedVMs)
+		}
+		fmt.Fprintln(out)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	if len(st.VMs) > 0 {
+		fmt.Fprintln(w, "\nRUNNER\tVM\tLOCATION\tSTATE\tAGE")
+		for _, vm := range st.VMs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", vm.RunnerName, vm.Name, vm.Location, vmState(vm), vmAge(vm, now))
+		}
+	}
+	if c := st.Capacity; c != nil {
+		if len(c.Quotas) > 0 {
+			fmt.Fprintln(w, "\nREGION\tGPU\tLIMIT\tUSAGE\tAVAILABLE\tCHECKED")
+			for _, q := range c.Quotas {
+				fmt.Fprintf(w, "%s\t%s\t%.0f\t%.0f\t%.0f\t%s ago\n",
+					q.Region, q.GPUType, q.Limit, q.Usage, q.Available, now.Sub(q.CheckedAt).Round(time.Second))
+			}
+		}
+		if len(c.StockoutsByZone) > 0 {
+			recent := make(map[string]int)
+			for _, s := range c.RecentStockouts {
+				recent[s.Zone]++
+			}
+			zones := make([]string, 0, len(c.StockoutsByZone))
+			for zone := range c.StockoutsByZone {
+				zones = append(zones, zone)
+			}
+			sort.Strings(zones)
+			fmt.Fprintln(w, "\nZONE\tSTOCKOUTS (1h)\tSTOCKOUTS (total)")
+			for _, zone := range zones {
+				fmt.Fprintf(w, "%s\t%d\t%d\n", zone, recent[zone], c.StockoutsByZone[zone])
+			}
+		}
+		if len(c.Reservations) > 0 {
+			fmt.Fprintf(w, "\nVMs in reservations: %d, on demand: %d\n", c.ReservedVMs, c.OnDemandVMs)
+			fmt.Fprintln(w, "RESERVATION\tZONE\tCOUNT\tIN USE\tCHECKED")
+			for _, r := range c.Reservations {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s ago\n",
+					r.Name, r.Zone, r.Count, r.InUse, now.Sub(r.CheckedAt).Round(time.Second))
+			}
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gcpvm "extras/scaler/internal/gcp"
+	"extras/scaler/internal/vmstate"
+)
+
+// capacityProvider is a fakeAdminProvider reporting GPU capacity.
+type capacityProvider struct {
+	fakeAdminProvider
+	capacity gcpvm.Capacity
+}
+
+func (p *capacityProvider) Capacity() gcpvm.Capacity { return p.capacity }
+
+func testCapacity(now time.Time) gcpvm.Capacity {
+	return gcpvm.Capacity{
+		Quotas: []gcpvm.RegionQuota{
+			{Region: "us-east1", GPUType: "nvidia-tesla-t4", Limit: 16, Usage: 16, Available: 0, CheckedAt: now.Add(-time.Minute)},
+		},
+		RecentStockouts: []gcpvm.Stockout{{Zone: "us-east1-d", GPUType: "nvidia-tesla-t4", Time: now.Add(-5 * time.Minute)}},
+		StockoutsByZone: map[string]int64{"us-east1-d": 4},
+	}
+}
+
+func TestStatusCommandShowsCapacity(t *testing.T) {
+	now := time.Now()
+	a, mux, _ := newTestAdmin()
+	a.scaler.vmManager = &capacityProvider{
+		fakeAdminProvider: fakeAdminProvider{vms: []vmstate.VM{
+			{RunnerName: "win-1", Name: "win-1", Location: "us-east1-c", Busy: true, CreatedAt: now.Add(-10 * time.Minute)},
+		}},
+		capacity: testCapacity(now),
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var out bytes.Buffer
+	if err := runStatus(context.Background(), []string{"--addr", srv.URL, "--token", "s3cret"}, &out); err != nil {
+		t.Fatalf("runStatus: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"Scale set windows-gpu (gcp): running, 1 VMs, min 1, max 4",
+		"win-1   win-1  us-east1-c  busy",
+		"us-east1  nvidia-tesla-t4  16     16     0          1m0s ago",
+		"us-east1-d  1               4",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestPrintStatusShowsReservations(t *testing.T) {
+	now := time.Now()
+	c := gcpvm.Capacity{
+		Reservations: []gcpvm.ReservationUsage{{Name: "t4-commit", Zone: "us-west1-a", Count: 8, InUse: 6, CheckedAt: now.Add(-time.Minute)}},
+		ReservedVMs:  5,
+		OnDemandVMs:  3,
+	}
+	var out bytes.Buffer
+	printStatus(&out, adminStatus{ScaleSet: "windows-gpu", Provider: "gcp", Capacity: &c}, now)
+	got := out.String()
+	for _, want := range []string{
+		"VMs in reservations: 5, on demand: 3",
+		"t4-commit    us-west1-a  8      6       1m0s ago",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestAdminStatusOmitsCapacityWithoutQuota(t *testing.T) {
+	_, mux, _ := newTestAdmin()
+	code, st := adminRequest(t, mux, http.MethodGet, "/api/v1/status", "")
+	if code != http.StatusOK || st.Capacity != nil {
+		t.Fatalf("status %d, capacity %+v; want no capacity for a provider without quota", code, st.Capacity)
+	}
+}
+
+func TestPrintStatusShowsBootPhase(t *testing.T) {
+	vms := []vmstate.VM{
+		{RunnerName: "win-1", Name: "win-1", Location: "us-east1-c", Phase: "driver-ok"},
+		{RunnerName: "win-2", Name: "win-2", Location: "us-east1-c", Phase: "failed", BootError: "nvidia-smi failed"},
+		{RunnerName: "win-3", Name: "win-3", Location: "us-east1-c", Phase: "runner-online"},
+	}
+	var out bytes.Buffer
+	printStatus(&out, adminStatus{ScaleSet: "windows-gpu", Provider: "gcp", VMs: vms}, time.Now())
+	got := out.String()
+	for _, want := range []string{
+		"win-1   win-1  us-east1-c  booting (driver-ok)",
+		"win-2   win-2  us-east1-c  boot failed: nvidia-smi failed",
+		"win-3   win-3  us-east1-c  idle",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestPrintStatusShowsImage(t *testing.T) {
+	now := time.Now()
+	image := gcpvm.ImageVersion{
+		Family:      "slang-windows",
+		Name:        "slang-windows-20261012",
+		CreatedAt:   time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC),
+		CheckedAt:   now.Add(-2 * time.Minute),
+		OutdatedVMs: 3,
+	}
+	var out bytes.Buffer
+	printStatus(&out, adminStatus{ScaleSet: "windows-gpu", Provider: "gcp", Image: &image}, now)
+	want := "Image slang-windows-20261012 from family slang-windows, published 2026-10-12, checked 2m0s ago; 3 VMs on earlier images\n"
+	if got := out.String(); !strings.Contains(got, want) {
+		t.Fatalf("output missing %q:\n%s", want, got)
+	}
+}
+
+func TestPrintStatusShowsDeletingVMs(t *testing.T) {
+	var out bytes.Buffer
+	printStatus(&out, adminStatus{ScaleSet: "windows-gpu", Provider: "gcp", Deleting: []string{"win-1", "win-2"}}, time.Now())
+	if got := out.String(); !strings.Contains(got, "Deleting 2 VMs: win-1, win-2\n") {
+		t.Errorf("output missing the VMs being deleted:\n%s", got)
+	}
+}
+
+func TestPrintStatusShowsZoneCooldowns(t *testing.T) {
+	now := time.Now()
+	capacity := gcpvm.Capacity{ZoneCooldowns: []gcpvm.ZoneCooldown{{Zone: "us-east1-d", GPUType: "nvidia-l4", Until: now.Add(4 * time.Minute)}}}
+	var out bytes.Buffer
+	printStatus(&out, adminStatus{ScaleSet: "linux-gpu", Provider: "gcp", Capacity: &capacity}, now)
+	if want := "Zones skipped after stockouts: us-east1-d nvidia-l4 (retry in 4m0s)\n"; !strings.Contains(out.String(), want) {
+		t.Fatalf("output missing %q:\n%s", want, out.String())
+	}
+}
+
+func TestPrintStatusShowsZonePreemptions(t *testing.T) {
+	capacity := gcpvm.Capacity{ZonePreemptions: map[string]gcpvm.ZonePreemptions{
+		"us-east1-d": {Created: 4, Preempted: 2},
+		"us-east1-b": {Preempted: 1},
+	}}
+	var out bytes.Buffer
+	printStatus(&out, adminStatus{ScaleSet: "linux-gpu", Provider: "gcp", Capacity: &capacity}, time.Now())
+	if want := "Zones with preempted Spot VMs: us-east1-b (1 preempted, 0 created), us-east1-d (2 preempted, 4 created)\n"; !strings.Contains(out.String(), want) {
+		t.Fatalf("output missing %q:\n%s", want, out.String())
+	}
+}
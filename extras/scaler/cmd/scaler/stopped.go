@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+
+	"github.com/actions/scaleset"
+)
+
+// vmStopper is implemented by providers that can keep a finished runner's
+// VM stopped for a later create to start, instead of deleting it.
+type vmStopper interface {
+	StopByRunnerName(ctx context.Context, runnerName string) error
+}
+
+// releaseVM stops or deletes the VM that ran jobInfo once it is not reused.
+// Only a VM whose job succeeded is kept; a failed or cancelled job may have
+// left it in a bad state. A draining scaler is about to exit, and the
+// stopped pool does not outlive the process.
+func (s *gcpRunnerScaler) releaseVM(ctx context.Context, jobInfo *scaleset.JobCompleted) error {
+	stopper, ok := s.vmManager.(vmStopper)
+	if ok && jobInfo.Result == "succeeded" && !s.isDraining() {
+		return stopper.StopByRunnerName(ctx, jobInfo.RunnerName)
+	}
+	return s.vmManager.DeleteByRunnerName(ctx, jobInfo.RunnerName)
+}
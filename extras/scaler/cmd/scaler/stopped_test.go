@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actions/scaleset"
+)
+
+// stopProvider is a provider that can keep VMs stopped.
+type stopProvider struct {
+	fakeAdminProvider
+	stopped []string
+	deleted []string
+}
+
+func (p *stopProvider) StopByRunnerName(_ context.Context, runnerName string) error {
+	p.stopped = append(p.stopped, runnerName)
+	return nil
+}
+
+func (p *stopProvider) DeleteByRunnerName(_ context.Context, runnerName string) error {
+	p.deleted = append(p.deleted, runnerName)
+	return nil
+}
+
+func TestReleaseVMStopsOnlyAfterSuccess(t *testing.T) {
+	provider := &stopProvider{}
+	s := &gcpRunnerScaler{vmManager: provider}
+	ctx := context.Background()
+
+	for _, job := range []*scaleset.JobCompleted{
+		{RunnerName: "win-1", Result: "succeeded"},
+		{RunnerName: "win-2", Result: "failed"},
+		{RunnerName: "win-3", Result: "canceled"},
+	} {
+		if err := s.releaseVM(ctx, job); err != nil {
+			t.Fatalf("releaseVM(%s): %v", job.RunnerName, err)
+		}
+	}
+	s.setDraining(true)
+	if err := s.releaseVM(ctx, &scaleset.JobCompleted{RunnerName: "win-4", Result: "succeeded"}); err != nil {
+		t.Fatalf("releaseVM(win-4): %v", err)
+	}
+
+	if len(provider.stopped) != 1 || provider.stopped[0] != "win-1" {
+		t.Fatalf("stopped = %v, want win-1", provider.stopped)
+	}
+	if len(provider.deleted) != 3 {
+		t.Fatalf("deleted = %v, want win-2, win-3 and win-4 (draining)", provider.deleted)
+	}
+}
+
+func TestLoadConfigStoppedPool(t *testing.T) {
+	base := []string{"--url=https://github.com/shader-slang/slang", "--token=t", "--name=windows-gpu"}
+	for _, args := range [][]string{
+		{"--stopped-pool-size=-1"},
+		{"--stopped-pool-size=2", "--provider=libvirt", "--platform=linux"},
+	} {
+		if _, err := testLoadConfig(t, append(base, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+	cfg, err := testLoadConfig(t, append(base, "--stopped-pool-size=4")...)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if got := gcpManagerConfig(cfg, "win", nil).StoppedPoolSize; got != 4 {
+		t.Fatalf("StoppedPoolSize = %d, want 4", got)
+	}
+}
+
+func TestLoadConfigSuspendedPool(t *testing.T) {
+	base := []string{"--url=https://github.com/shader-slang/slang", "--token=t", "--name=windows-build", "--gcp-gpu-type=none"}
+	for _, args := range [][]string{
+		{"--suspended-pool-size=-1"},
+		{"--suspended-pool-size=2", "--stopped-pool-size=2"},
+		{"--suspended-pool-size=2", "--gcp-gpu-type=nvidia-tesla-t4"},
+		{"--suspended-pool-size=2", "--template-routes=GCP-A100=win-a100/nvidia-tesla-a100"},
+	} {
+		if _, err := testLoadConfig(t, append(base, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+	cfg, err := testLoadConfig(t, append(base, "--suspended-pool-size=3")...)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	mc := gcpManagerConfig(cfg, "win", nil)
+	if mc.StoppedPoolSize != 3 || !mc.SuspendPool {
+		t.Fatalf("StoppedPoolSize = %d, SuspendPool = %v, want a suspended pool of 3", mc.StoppedPoolSize, mc.SuspendPool)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"extras/scaler/internal/notify"
+)
+
+// jobWatchdog reports jobs that have run longer than maxDuration, e.g. on a
+// runner frozen by a hung driver install, which would otherwise hold its
+// VM for hours until GitHub's job timeout. With recycle set their VMs are
+// deleted, which fails the job.
+type jobWatchdog struct {
+	maxDuration time.Duration
+	recycle     bool
+	// reported holds the runners whose job was already reported, so each
+	// stuck job is reported once.
+	reported map[string]bool
+}
+
+// minMaxJobDuration keeps the job watchdog's check interval, a quarter of
+// --max-job-duration, above zero.
+const minMaxJobDuration = time.Minute
+
+func (s *gcpRunnerScaler) watchStuckJobs(ctx context.Context, w *jobWatchdog) {
+	watchEvery(ctx, w.maxDuration, func(now time.Time) { s.checkStuckJobs(ctx, w, now) })
+}
+
+// checkStuckJobs reports the jobs started maxDuration before now that are
+// still running, deletes their VMs when w.recycle is set, and returns how
+// many it found. Only jobs started since the scaler did are known.
+func (s *gcpRunnerScaler) checkStuckJobs(ctx context.Context, w *jobWatchdog, now time.Time) int {
+	jobs, _ := s.activity.snapshot()
+	busy := make(map[string]string)
+	for _, vm := range s.vmManager.VMs() {
+		if vm.Busy {
+			busy[vm.RunnerName] = vm.Location
+		}
+	}
+	names := make([]string, 0, len(jobs))
+	for name := range jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var stuck []string
+	for _, name := range names {
+		job := jobs[name]
+		location, ok := busy[name]
+		if !ok || now.Sub(job.StartedAt) < w.maxDuration || w.reported[name] {
+			continue
+		}
+		s.logger.Warn("job exceeded --max-job-duration",
+			"event", eventJobStuck,
+			"runner", name,
+			"location", location,
+			"job", job.Name,
+			"workflow_run", job.WorkflowRunID,
+			"running", now.Sub(job.StartedAt).Round(time.Second),
+			"recycle", w.recycle,
+		)
+		desc := fmt.Sprintf("%s (%s)", job.Name, name)
+		if w.recycle {
+			// A failed delete is retried on the next check.
+			if err := s.vmManager.DeleteByRunnerName(ctx, name); err != nil {
+				s.logger.Error("failed to delete VM of stuck job", "event", eventVMDeleteFailed, "runner", name, "error", err)
+				continue
+			}
+			s.latency.forget(name)
+			s.runners.finished(name, "stuck")
+			s.activity.jobFinished(name)
+			s.removeRunnerFromGitHub(ctx, name)
+		} else {
+			w.reported[name] = true
+		}
+		stuck = append(stuck, desc)
+	}
+	for name := range w.reported {
+		if _, ok := jobs[name]; !ok {
+			delete(w.reported, name)
+		}
+	}
+	if len(stuck) > 0 {
+		msg := fmt.Sprintf("%d jobs have run longer than %s: %s", len(stuck), w.maxDuration, strings.Join(stuck, ", "))
+		if w.recycle {
+			msg += "; their VMs were deleted"
+		}
+		s.notifier.Notify(ctx, notify.StuckJob, msg)
+	}
+	return len(stuck)
+}
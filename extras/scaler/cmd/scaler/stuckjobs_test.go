@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/vmstate"
+)
+
+func TestCheckStuckJobsReportsJobsOverMaxDuration(t *testing.T) {
+	now := time.Now()
+	provider := &zombieProvider{fakeAdminProvider: fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-hung", Name: "win-hung", Location: "us-east1-c", Busy: true},
+		{RunnerName: "win-short", Busy: true},
+		{RunnerName: "win-gone", Busy: true},
+	}}}
+	var logs bytes.Buffer
+	s := &gcpRunnerScaler{
+		logger:    slog.New(slog.NewTextHandler(&logs, nil)),
+		vmManager: provider,
+		activity:  newScalerActivity(),
+	}
+	s.activity.jobStarted(&scaleset.JobStarted{JobMessageBase: scaleset.JobMessageBase{JobDisplayName: "build"}, RunnerName: "win-hung"}, now.Add(-7*time.Hour))
+	s.activity.jobStarted(&scaleset.JobStarted{RunnerName: "win-short"}, now.Add(-time.Hour))
+	// A job whose VM is no longer tracked is not reported.
+	s.activity.jobStarted(&scaleset.JobStarted{RunnerName: "win-lost"}, now.Add(-7*time.Hour))
+	w := &jobWatchdog{maxDuration: 6 * time.Hour, reported: map[string]bool{}}
+
+	if n := s.checkStuckJobs(context.Background(), w, now); n != 1 {
+		t.Fatalf("found %d stuck jobs, want 1", n)
+	}
+	if !strings.Contains(logs.String(), "event=job_stuck") || !strings.Contains(logs.String(), "runner=win-hung") {
+		t.Fatalf("log does not report the stuck job:\n%s", logs.String())
+	}
+	if len(provider.deleted) != 0 {
+		t.Fatalf("deleted = %v, want nothing without recycle", provider.deleted)
+	}
+	// Each stuck job is reported once.
+	if n := s.checkStuckJobs(context.Background(), w, now.Add(time.Minute)); n != 0 {
+		t.Fatalf("second check found %d stuck jobs, want 0", n)
+	}
+}
+
+func TestCheckStuckJobsRecyclesVMs(t *testing.T) {
+	now := time.Now()
+	provider := &zombieProvider{fakeAdminProvider: fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-hung", Name: "win-hung", Busy: true},
+	}}}
+	client, actions := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.DiscardHandler),
+		vmManager:      provider,
+		activity:       newScalerActivity(),
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "win",
+	}
+	s.activity.jobStarted(&scaleset.JobStarted{RunnerName: "win-hung"}, now.Add(-7*time.Hour))
+	w := &jobWatchdog{maxDuration: 6 * time.Hour, recycle: true, reported: map[string]bool{}}
+
+	if n := s.checkStuckJobs(context.Background(), w, now); n != 1 {
+		t.Fatalf("found %d stuck jobs, want 1", n)
+	}
+	if want := []string{"win-hung"}; !reflect.DeepEqual(provider.deleted, want) {
+		t.Fatalf("deleted = %v, want %v", provider.deleted, want)
+	}
+	if len(actions.removed) != 1 {
+		t.Fatalf("runners removed from GitHub = %v, want the stuck job's", actions.removed)
+	}
+	if jobs, _ := s.activity.size(); jobs != 0 {
+		t.Fatalf("activity still tracks %d jobs, want the recycled one forgotten", jobs)
+	}
+}
+
+func TestMaxJobDurationIsValidated(t *testing.T) {
+	base := []string{"--url=https://github.com/o/r"}
+	if _, err := testLoadConfig(t, append(base, "--max-job-duration=6h", "--recycle-stuck-jobs")...); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	for _, args := range [][]string{
+		{"--max-job-duration=-1h"},
+		{"--max-job-duration=3ns"},
+		{"--recycle-stuck-jobs"},
+	} {
+		if _, err := testLoadConfig(t, append(base, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+}
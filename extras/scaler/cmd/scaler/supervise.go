@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+
+	"github.com/actions/scaleset"
+	"github.com/actions/scaleset/listener"
+
+	"extras/scaler/internal/supervise"
+)
+
+// recoveringScaler turns a panic in one of the listener's callbacks into a
+// *supervise.PanicError, which ends the listener's Run like any error; run
+// then starts it again after a backoff instead of the process crashing.
+type recoveringScaler struct {
+	listener.Scaler
+}
+
+func (s recoveringScaler) HandleJobStarted(ctx context.Context, jobInfo *scaleset.JobStarted) (err error) {
+	defer supervise.Catch("listener.job_started", &err)
+	return s.Scaler.HandleJobStarted(ctx, jobInfo)
+}
+
+func (s recoveringScaler) HandleJobCompleted(ctx context.Context, jobInfo *scaleset.JobCompleted) (err error) {
+	defer supervise.Catch("listener.job_completed", &err)
+	return s.Scaler.HandleJobCompleted(ctx, jobInfo)
+}
+
+func (s recoveringScaler) HandleDesiredRunnerCount(ctx context.Context, count int) (_ int, err error) {
+	defer supervise.Catch("listener.desired_runner_count", &err)
+	return s.Scaler.HandleDesiredRunnerCount(ctx, count)
+}
+
+// createRunnerRecovered is createRunner for a background create, with a
+// panic recovered as a failed create so the rest of the scale-up is still
+// recorded.
+func (s *gcpRunnerScaler) createRunnerRecovered(ctx context.Context, plan vmPlan) (outcome vmOutcome) {
+	var err error
+	defer func() {
+		if err != nil {
+			outcome.Error = err.Error()
+		}
+	}()
+	defer supervise.Catch("scale_up", &err)
+	return s.createRunner(ctx, plan)
+}
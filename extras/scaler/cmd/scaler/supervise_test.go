@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/actions/scaleset"
+
+	"extras/scaler/internal/supervise"
+)
+
+type panickingScaler struct{}
+
+func (panickingScaler) HandleJobStarted(context.Context, *scaleset.JobStarted) error {
+	var vm *vmPlan
+	_ = vm.slots
+	return nil
+}
+
+func (panickingScaler) HandleJobCompleted(context.Context, *scaleset.JobCompleted) error {
+	panic("boom")
+}
+
+func (panickingScaler) HandleDesiredRunnerCount(_ context.Context, count int) (int, error) {
+	return count, nil
+}
+
+func TestRecoveringScalerTurnsPanicsIntoErrors(t *testing.T) {
+	s := recoveringScaler{panickingScaler{}}
+	var panicErr *supervise.PanicError
+	if err := s.HandleJobStarted(context.Background(), &scaleset.JobStarted{}); !errors.As(err, &panicErr) || panicErr.Subsystem != "listener.job_started" {
+		t.Fatalf("HandleJobStarted = %v, want the nil dereference as a PanicError", err)
+	}
+	if err := s.HandleJobCompleted(context.Background(), &scaleset.JobCompleted{}); !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+		t.Fatalf("HandleJobCompleted = %v, want the panic as a PanicError", err)
+	}
+	if n, err := s.HandleDesiredRunnerCount(context.Background(), 3); n != 3 || err != nil {
+		t.Fatalf("HandleDesiredRunnerCount = %d, %v, want the scaler's result", n, err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/actions/scaleset"
+)
+
+// scaleSetRemover is the part of *scaleset.Client that `scaler teardown`
+// uses.
+type scaleSetRemover interface {
+	GetRunnerGroupByName(ctx context.Context, runnerGroup string) (*scaleset.RunnerGroup, error)
+	GetRunnerScaleSet(ctx context.Context, runnerGroupID int, runnerScaleSetName string) (*scaleset.RunnerScaleSet, error)
+	DeleteRunnerScaleSet(ctx context.Context, runnerScaleSetID int) error
+}
+
+// runTeardown implements `scaler teardown [flags]`. It takes the same flags
+// and config file as the service and deletes the pool's scale set, which
+// the service keeps across restarts unless --delete-scale-set-on-exit is
+// set, and with --manage-firewall its firewall rules. Stop the service
+// first: its runners lose their registration with the scale set.
+func runTeardown(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("scaler teardown", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	creds, err := newCredentialRefresher(ctx, cfg, slog.Default())
+	if err != nil {
+		return err
+	}
+	if creds != nil {
+		if err := creds.load(ctx, &cfg); err != nil {
+			return fmt.Errorf("fetching credentials: %w", err)
+		}
+	}
+	client, err := cfg.scalesetClient()
+	if err != nil {
+		return fmt.Errorf("creating scaleset client: %w", err)
+	}
+	if err := teardownScaleSet(ctx, client, cfg.runnerGroup, cfg.scaleSetName, out); err != nil {
+		return err
+	}
+	if cfg.manageFirewall {
+		return teardownFirewall(ctx, cfg, out)
+	}
+	return nil
+}
+
+// teardownScaleSet deletes the scale set named name in runnerGroup, if it
+// exists.
+func teardownScaleSet(ctx context.Context, client scaleSetRemover, runnerGroup, name string, out io.Writer) error {
+	runnerGroupID := 1
+	if runnerGroup != scaleset.DefaultRunnerGroup {
+		rg, err := client.GetRunnerGroupByName(ctx, runnerGroup)
+		if err != nil {
+			return fmt.Errorf("runner group %q: %w", runnerGroup, err)
+		}
+		runnerGroupID = rg.ID
+	}
+	ss, err := client.GetRunnerScaleSet(ctx, runnerGroupID, name)
+	if err != nil {
+		return fmt.Errorf("scale set %q: %w", name, err)
+	}
+	if ss == nil {
+		fmt.Fprintf(out, "scale set %s does not exist\n", name)
+		return nil
+	}
+	if err := client.DeleteRunnerScaleSet(ctx, ss.ID); err != nil {
+		return fmt.Errorf("deleting scale set %q: %w", name, err)
+	}
+	fmt.Fprintf(out, "deleted scale set %s (id %d)\n", name, ss.ID)
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/actions/scaleset"
+)
+
+type fakeScaleSets struct {
+	groups  map[string]int
+	sets    map[int]map[string]int // runner group ID -> name -> scale set ID
+	deleted []int
+}
+
+func (f *fakeScaleSets) GetRunnerGroupByName(_ context.Context, name string) (*scaleset.RunnerGroup, error) {
+	return &scaleset.RunnerGroup{ID: f.groups[name], Name: name}, nil
+}
+
+func (f *fakeScaleSets) GetRunnerScaleSet(_ context.Context, groupID int, name string) (*scaleset.RunnerScaleSet, error) {
+	id, ok := f.sets[groupID][name]
+	if !ok {
+		return nil, nil
+	}
+	return &scaleset.RunnerScaleSet{ID: id, Name: name, RunnerGroupID: groupID}, nil
+}
+
+func (f *fakeScaleSets) DeleteRunnerScaleSet(_ context.Context, id int) error {
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+func TestTeardownScaleSet(t *testing.T) {
+	f := &fakeScaleSets{
+		groups: map[string]int{"gpu": 7},
+		sets:   map[int]map[string]int{1: {"windows-gpu-runners": 3}, 7: {"windows-gpu-runners": 9}},
+	}
+	var out bytes.Buffer
+	if err := teardownScaleSet(context.Background(), f, "gpu", "windows-gpu-runners", &out); err != nil {
+		t.Fatalf("teardownScaleSet: %v", err)
+	}
+	if len(f.deleted) != 1 || f.deleted[0] != 9 {
+		t.Fatalf("deleted = %v, want the gpu group's scale set 9", f.deleted)
+	}
+	if !strings.Contains(out.String(), "deleted scale set windows-gpu-runners") {
+		t.Fatalf("output = %q", out.String())
+	}
+
+	// A missing scale set is not an error.
+	out.Reset()
+	if err := teardownScaleSet(context.Background(), f, scaleset.DefaultRunnerGroup, "linux-gpu-runners", &out); err != nil {
+		t.Fatalf("teardownScaleSet of a missing scale set: %v", err)
+	}
+	if len(f.deleted) != 1 || !strings.Contains(out.String(), "does not exist") {
+		t.Fatalf("deleted = %v, output = %q, want nothing deleted", f.deleted, out.String())
+	}
+}
+
+func TestRunTeardownRejectsInvalidConfig(t *testing.T) {
+	var out bytes.Buffer
+	err := runTeardown(context.Background(), []string{"--max-runners=-1"}, &out)
+	if err == nil || !strings.Contains(err.Error(), "invalid configuration") {
+		t.Fatalf("runTeardown error = %v, want the config rejected", err)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// topEventWindow is how many of each scaler's recent events `scaler top`
+// reads to find its errors.
+const topEventWindow = 200
+
+// topFetchTimeout bounds each refresh's requests to one scaler, so a hung
+// scaler shows as an error rather than freezing the screen.
+const topFetchTimeout = 5 * time.Second
+
+// maxTopMessage bounds an error's line in `scaler top`.
+const maxTopMessage = 120
+
+// topPool is one scaler as `scaler top` last saw it.
+type topPool struct {
+	// target is the scaler's control socket or admin API address.
+	target string
+	status adminStatus
+	errors []historyEvent
+	err    error
+}
+
+// topOptions are the flags of `scaler top`.
+type topOptions struct {
+	target   *adminFlags
+	interval time.Duration
+	errors   int
+	once     bool
+}
+
+func (o *topOptions) register(fs *flag.FlagSet) {
+	o.target = newAdminFlags(fs)
+	fs.DurationVar(&o.interval, "interval", 2*time.Second, "How often to refresh")
+	fs.IntVar(&o.errors, "errors", 5, "Number of recent errors to show")
+	fs.BoolVar(&o.once, "once", false, "Print the view once, without clearing the screen, and exit")
+}
+
+// runTop implements `scaler top [flags]`, a live view of the pools of the
+// running scalers: their desired and actual runner counts, each VM's zone,
+// age and job, and their recent errors, redrawn every --interval until
+// interrupted. Without --socket or --addr it shows every scaler with a
+// control socket on the host.
+func runTop(ctx context.Context, args []string, out io.Writer) error {
+	var o topOptions
+	fs := flag.NewFlagSet("scaler top", flag.ContinueOnError)
+	o.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if o.interval <= 0 {
+		return errors.New("--interval must be positive")
+	}
+	if o.errors < 0 {
+		return errors.New("--errors must not be negative")
+	}
+	clients, err := o.target.clients()
+	if err != nil {
+		return err
+	}
+	if o.once {
+		renderTop(out, fetchTop(ctx, clients), o.errors, time.Now())
+		return nil
+	}
+
+	// Hide the cursor while redrawing, and clear the screen before each
+	// frame, written at once so it does not flicker.
+	fmt.Fprint(out, "\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\n")
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+	for {
+		pools := fetchTop(ctx, clients)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var frame bytes.Buffer
+		frame.WriteString("\x1b[H\x1b[2J")
+		renderTop(&frame, pools, o.errors, time.Now())
+		if _, err := out.Write(frame.Bytes()); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetchTop reads the status and recent errors of each scaler, in parallel.
+func fetchTop(ctx context.Context, clients []*adminClient) []topPool {
+	pools := make([]topPool, len(clients))
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		pools[i].target = client.socket
+		if client.socket == "" {
+			pools[i].target = client.addr
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, topFetchTimeout)
+			defer cancel()
+			pools[i].err = fetchTopPool(ctx, client, &pools[i])
+		}()
+	}
+	wg.Wait()
+	return pools
+}
+
+func fetchTopPool(ctx context.Context, client *adminClient, pool *topPool) error {
+	body, err := client.do(ctx, http.MethodGet, "/api/v1/status", nil)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, &pool.status); err != nil {
+		return err
+	}
+	body, err = client.do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/events?limit=%d", topEventWindow), nil)
+	if err != nil {
+		return err
+	}
+	var events []historyEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return err
+	}
+	for _, e := range events {
+		if e.Level == "ERROR" {
+			pool.errors = append(pool.errors, e)
+		}
+	}
+	return nil
+}
+
+// renderTop writes one frame of `scaler top`: a row per pool, a row per VM
+// and the errorCount most recent errors of all pools.
+func renderTop(out io.Writer, pools []topPool, errorCount int, now time.Time) {
+	vms, busy := 0, 0
+	for _, p := range pools {
+		vms += len(p.status.VMs)
+		for _, vm := range p.status.VMs {
+			if vm.Busy {
+				busy++
+			}
+		}
+	}
+	fmt.Fprintf(out, "scaler top - %s - %d pools, %d VMs, %d busy\n\n", now.Format(time.TimeOnly), len(pools), vms, busy)
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POOL\tSTATE\tDESIRED\tACTUAL\tBUSY\tBOOTING\tIDLE\tMIN\tMAX")
+	for _, p := range pools {
+		if p.err != nil {
+			fmt.Fprintf(w, "%s\terror: %v\n", p.target, p.err)
+			continue
+		}
+		st := p.status
+		state := "running"
+		switch {
+		case st.Draining:
+			state = "draining"
+		case st.Paused:
+			state = "paused"
+		}
+		counts := make(map[string]int)
+		for _, vm := range st.VMs {
+			switch s := vmState(vm); {
+			case s == "busy", s == "idle":
+				counts[s]++
+			default:
+				counts["booting"]++
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n", st.ScaleSet, state, st.Desired, len(st.VMs),
+			counts["busy"], counts["booting"], counts["idle"], st.MinRunners, st.MaxRunners)
+	}
+
+	if vms > 0 {
+		fmt.Fprintln(w, "\nPOOL\tRUNNER\tZONE\tSTATE\tAGE\tJOB")
+		for _, p := range pools {
+			for _, vm := range p.status.VMs {
+				job := "-"
+				if j, ok := p.status.Jobs[vm.RunnerName]; ok {
+					job = fmt.Sprintf("%s (%s)", j.Name, now.Sub(j.StartedAt).Round(time.Second))
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", p.status.ScaleSet, vm.RunnerName, vm.Location, vmState(vm), vmAge(vm, now), job)
+			}
+		}
+	}
+
+	type poolError struct {
+		pool string
+		historyEvent
+	}
+	var errs []poolError
+	for _, p := range pools {
+		for _, e := range p.errors {
+			errs = append(errs, poolError{p.status.ScaleSet, e})
+		}
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Time.After(errs[j].Time) })
+	if len(errs) > errorCount {
+		errs = errs[:errorCount]
+	}
+	if len(errs) > 0 {
+		fmt.Fprintln(w, "\nTIME\tPOOL\tERROR")
+		for _, e := range errs {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", e.Time.Local().Format(time.TimeOnly), e.pool, topMessage(e.historyEvent))
+		}
+	}
+	w.Flush()
+}
+
+// topMessage is an error's message and attributes on one line, cut to
+// maxTopMessage characters.
+func topMessage(e historyEvent) string {
+	keys := make([]string, 0, len(e.Attrs))
+	for k := range e.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := []string{e.Message}
+	for _, k := range keys {
+		parts = append(parts, k+"="+e.Attrs[k])
+	}
+	msg := strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+	if r := []rune(msg); len(r) > maxTopMessage {
+		msg = string(r[:maxTopMessage-1]) + "…"
+	}
+	return msg
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"extras/scaler/internal/vmstate"
+)
+
+func TestRenderTop(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	pools := []topPool{
+		{
+			target: "/run/scaler-windows/scaler.sock",
+			status: adminStatus{
+				ScaleSet: "windows-gpu", Desired: 3, MinRunners: 1, MaxRunners: 5,
+				VMs: []vmstate.VM{
+					{RunnerName: "win-1", Location: "us-east1-c", Busy: true, CreatedAt: now.Add(-10 * time.Minute)},
+					{RunnerName: "win-2", Pending: true},
+				},
+				Jobs: map[string]runningJob{"win-1": {Name: "build (windows)", StartedAt: now.Add(-4 * time.Minute)}},
+			},
+			errors: []historyEvent{
+				{Time: now.Add(-time.Hour), Message: "old failure"},
+				{Time: now.Add(-time.Minute), Message: "failed to create VM", Attrs: map[string]string{"zone": "us-east1-c", "error": "ZONE_RESOURCE_POOL_EXHAUSTED"}},
+			},
+		},
+		{target: "/run/scaler-linux/scaler.sock", err: io.ErrUnexpectedEOF},
+	}
+
+	var out bytes.Buffer
+	renderTop(&out, pools, 1, now)
+	// Compare with the table's padding collapsed.
+	var lines []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		lines = append(lines, strings.Join(strings.Fields(line), " "))
+	}
+	got := strings.Join(lines, "\n")
+	for _, want := range []string{
+		"2 pools, 2 VMs, 1 busy",
+		"windows-gpu running 3 2 1 1 0 1 5",
+		"/run/scaler-linux/scaler.sock error: unexpected EOF",
+		"windows-gpu win-1 us-east1-c busy 10m0s build (windows) (4m0s)",
+		"windows-gpu win-2 creating - -",
+		"failed to create VM error=ZONE_RESOURCE_POOL_EXHAUSTED zone=us-east1-c",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "old failure") {
+		t.Errorf("output shows more than --errors=1 errors:\n%s", got)
+	}
+}
+
+func TestRunTopOnce(t *testing.T) {
+	a, _, _ := newTestAdmin()
+	a.history = newEventHistory(10)
+	a.scaler.desired.Store(2)
+	slog.New(newHistoryHandler(slog.NewTextHandler(io.Discard, nil), a.history)).Error("failed to delete VM", "vm", "win-9")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "scaler.sock")
+	if err := serveControlSocket(ctx, path, a, a.logger); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runTop(ctx, []string{"--socket", path, "--once"}, &out); err != nil {
+		t.Fatalf("scaler top: %v", err)
+	}
+	if got := out.String(); !strings.Contains(strings.Join(strings.Fields(got), " "), "windows-gpu running 2") || !strings.Contains(got, "failed to delete VM vm=win-9") || strings.Contains(got, "\x1b[") {
+		t.Fatalf("output:\n%q", got)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/actions/scaleset"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("extras/scaler/cmd/scaler")
+
+// setupTracing installs an OTLP/gRPC trace exporter when --otlp-endpoint or
+// the standard OTEL_EXPORTER_OTLP_(TRACES_)ENDPOINT variable is set, and
+// returns a function that flushes it. Otherwise spans are no-ops.
+func setupTracing(ctx context.Context, cfg config) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if cfg.otlpEndpoint == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" &&
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return noop, nil
+	}
+
+	var opts []otlptracegrpc.Option
+	if cfg.otlpEndpoint != "" {
+		opts = append(opts, otlptracegrpc.WithEndpointURL(cfg.otlpEndpoint))
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return noop, fmt.Errorf("creating OTLP trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName("gcp-runner-scaler"),
+		attribute.String("scaler.scale_set", cfg.scaleSetName),
+		attribute.String("scaler.provider", cfg.provider),
+	))
+	if err != nil {
+		return noop, fmt.Errorf("building trace resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// endSpan records err on span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// runnerSpans holds the open lifecycle span of each runner: "runner.boot"
+// from VM creation until its job starts, then "runner.job" until the job
+// completes. Together with the scale-up spans they cover a job from the
+// scale decision to completion. A nil *runnerSpans records nothing.
+type runnerSpans struct {
+	mu    sync.Mutex
+	spans map[string]trace.Span
+}
+
+func newRunnerSpans() *runnerSpans {
+	return &runnerSpans{spans: make(map[string]trace.Span)}
+}
+
+// booting starts the boot span for runnerName as a child of ctx's span.
+func (r *runnerSpans) booting(ctx context.Context, runnerName string) {
+	if r == nil {
+		return
+	}
+	_, span := tracer.Start(ctx, "runner.boot", trace.WithAttributes(attribute.String("runner.name", runnerName)))
+	r.replace(runnerName, span)
+}
+
+// jobStarted ends the boot span and starts the job span, linked to the boot
+// span so the job can be traced back to the scale-up that created its
+// runner. A "job.queue" span records the job's wait from queueing to runner
+// assignment using GitHub's timestamps.
+func (r *runnerSpans) jobStarted(ctx context.Context, job *scaleset.JobStarted) {
+	if r == nil {
+		return
+	}
+	attrs := trace.WithAttributes(
+		attribute.String("runner.name", job.RunnerName),
+		attribute.String("job.id", job.JobID),
+		attribute.String("job.name", job.JobDisplayName),
+		attribute.Int64("workflow_run.id", job.WorkflowRunID),
+	)
+	opts := []trace.SpanStartOption{attrs}
+	r.mu.Lock()
+	if boot, ok := r.spans[job.RunnerName]; ok {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: boot.SpanContext()}))
+	}
+	r.mu.Unlock()
+
+	if !job.QueueTime.IsZero() && !job.RunnerAssignTime.IsZero() {
+		_, queue := tracer.Start(ctx, "job.queue", append(opts, trace.WithTimestamp(job.QueueTime))...)
+		queue.End(trace.WithTimestamp(job.RunnerAssignTime))
+	}
+	_, span := tracer.Start(ctx, "runner.job", opts...)
+	r.replace(job.RunnerName, span)
+}
+
+// finished ends whatever span runnerName still has open.
+func (r *runnerSpans) finished(runnerName, result string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	span, ok := r.spans[runnerName]
+	delete(r.spans, runnerName)
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+	if result != "" {
+		span.SetAttributes(attribute.String("job.result", result))
+	}
+	span.End()
+}
+
+func (r *runnerSpans) size() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.spans)
+}
+
+func (r *runnerSpans) replace(runnerName string, span trace.Span) {
+	r.mu.Lock()
+	prev, ok := r.spans[runnerName]
+	r.spans[runnerName] = span
+	r.mu.Unlock()
+	if ok {
+		prev.End()
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actions/scaleset"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRunnerSpansCoverBootQueueAndJob(t *testing.T) {
+	// The package tracer delegates to the first global provider set, so
+	// this is the only test that installs one.
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	spans := newRunnerSpans()
+	spans.booting(context.Background(), "linux-test-1")
+
+	queued := time.Now().Add(-4 * time.Minute)
+	job := &scaleset.JobStarted{RunnerName: "linux-test-1"}
+	job.JobID = "job-7"
+	job.QueueTime = queued
+	job.RunnerAssignTime = queued.Add(3 * time.Minute)
+	spans.jobStarted(context.Background(), job)
+	spans.finished("linux-test-1", "succeeded")
+	spans.finished("linux-test-1", "succeeded") // already ended: no-op
+
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range rec.Ended() {
+		byName[s.Name()] = s
+	}
+	boot, queue, run := byName["runner.boot"], byName["job.queue"], byName["runner.job"]
+	if len(rec.Ended()) != 3 || boot == nil || queue == nil || run == nil {
+		t.Fatalf("ended spans = %v, want runner.boot, job.queue, runner.job", byName)
+	}
+	if got := queue.EndTime().Sub(queue.StartTime()); got != 3*time.Minute {
+		t.Fatalf("job.queue duration = %v, want GitHub's 3m queue time", got)
+	}
+	if links := run.Links(); len(links) != 1 || links[0].SpanContext.SpanID() != boot.SpanContext().SpanID() {
+		t.Fatal("runner.job should link to the runner's boot span")
+	}
+
+	var nilSpans *runnerSpans
+	nilSpans.booting(context.Background(), "x")
+	nilSpans.finished("x", "")
+}
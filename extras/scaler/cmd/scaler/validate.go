@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/actions/scaleset"
+
+	gcpvm "extras/scaler/internal/gcp"
+	"extras/scaler/internal/startup"
+)
+
+// runValidate implements `scaler validate [flags]`. It loads the
+// configuration exactly as the service would and checks it against GitHub
+// and GCP without creating anything, so a bad config fails here instead of
+// in a systemd restart loop. Each check prints one line to out.
+func runValidate(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("scaler validate", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	fmt.Fprintf(out, "ok    configuration (provider %s)\n", cfg.provider)
+
+	failed := 0
+	report := func(name string, errs ...error) {
+		ok := true
+		for _, err := range errs {
+			if err != nil {
+				ok = false
+				fmt.Fprintf(out, "FAIL  %s: %v\n", name, err)
+			}
+		}
+		if ok {
+			fmt.Fprintf(out, "ok    %s\n", name)
+		} else {
+			failed++
+		}
+	}
+
+	creds, err := newCredentialRefresher(ctx, cfg, slog.Default())
+	if err == nil && creds != nil {
+		err = creds.load(ctx, &cfg)
+	}
+	report("credentials", err)
+	if err == nil {
+		report("GitHub access to "+cfg.registrationURL, checkGitHub(ctx, cfg))
+	}
+
+	var script *startup.Script
+	if cfg.startupScript != "" {
+		script, err = startup.Load(ctx, cfg.startupScript)
+		report("startup script "+cfg.startupScript, err)
+	}
+
+	switch cfg.provider {
+	case "gcp":
+		vmPrefix := cfg.gcpVMPrefix
+		if vmPrefix == "" {
+			vmPrefix = defaultVMPrefix(cfg.gcpPlatform)
+		}
+		report("GCP project "+cfg.gcpProject, gcpvm.Check(ctx, gcpManagerConfig(cfg, vmPrefix, script))...)
+	default:
+		fmt.Fprintf(out, "skip  %s provider (no remote checks)\n", cfg.provider)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+// checkGitHub authenticates and resolves the runner group and scale set the
+// service would use. A scale set that does not exist yet is fine; the
+// service creates it.
+func checkGitHub(ctx context.Context, cfg config) error {
+	client, err := cfg.scalesetClient()
+	if err != nil {
+		return err
+	}
+	runnerGroupID := 1
+	if cfg.runnerGroup != scaleset.DefaultRunnerGroup {
+		rg, err := client.GetRunnerGroupByName(ctx, cfg.runnerGroup)
+		if err != nil {
+			return fmt.Errorf("runner group %q: %w", cfg.runnerGroup, err)
+		}
+		runnerGroupID = rg.ID
+	}
+	if _, err := client.GetRunnerScaleSet(ctx, runnerGroupID, cfg.scaleSetName); err != nil {
+		return fmt.Errorf("scale set %q: %w", cfg.scaleSetName, err)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRunValidateRejectsInvalidConfig(t *testing.T) {
+	path := writeConfigFile(t, "url: https://github.com/o/r\nmax-runnerz: 4\n")
+	var out bytes.Buffer
+	err := runValidate(context.Background(), []string{"--config=" + path}, &out)
+	if err == nil || !strings.Contains(err.Error(), "max-runnerz") {
+		t.Fatalf("runValidate error = %v, want the unknown key named", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("output = %q, want nothing before the config loads", out.String())
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/actions/scaleset"
+)
+
+// Set when building releases:
+//
+//	go build -ldflags "-X main.version=v1.4.0 -X main.commit=$(git rev-parse HEAD) \
+//	  -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/scaler
+//
+// Without them the commit, and the commit's date for the build date, come
+// from the VCS stamp go build adds in a git checkout.
+var (
+	version   = "dev"
+	commit    string
+	buildDate string
+)
+
+// buildInfo identifies the scaler build that is running.
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// currentBuild returns the ldflags values, filling the commit and date from
+// the binary's VCS stamp where they were not set.
+func currentBuild() buildInfo {
+	b := buildInfo{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	var modified bool
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if b.Commit == "" {
+				b.Commit = s.Value
+			}
+		case "vcs.time":
+			// Another commit's date would be misleading.
+			if b.BuildDate == "" && commit == "" {
+				b.BuildDate = s.Value
+			}
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if modified && commit == "" && b.Commit != "" {
+		b.Commit += "-dirty"
+	}
+	return b
+}
+
+func (b buildInfo) String() string {
+	s := "scaler " + b.Version
+	if b.Commit != "" {
+		s += " (commit " + b.Commit
+		if b.BuildDate != "" {
+			s += ", built " + b.BuildDate
+		}
+		s += ")"
+	}
+	return fmt.Sprintf("%s, %s", s, b.GoVersion)
+}
+
+// logAttrs are the build's attributes for the startup log line.
+func (b buildInfo) logAttrs() []any {
+	return []any{"version", b.Version, "commit", b.Commit, "build_date", b.BuildDate, "go_version", b.GoVersion}
+}
+
+// systemInfo is what the scale set client reports about the scaler to
+// GitHub with each request.
+func systemInfo(scaleSetID int) scaleset.SystemInfo {
+	b := currentBuild()
+	return scaleset.SystemInfo{
+		System:     "gcp-runner-scaler",
+		Subsystem:  "scaler",
+		Version:    b.Version,
+		CommitSHA:  b.Commit,
+		ScaleSetID: scaleSetID,
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setBuild(t *testing.T, v, c, date string) {
+	t.Helper()
+	origVersion, origCommit, origDate := version, commit, buildDate
+	t.Cleanup(func() { version, commit, buildDate = origVersion, origCommit, origDate })
+	version, commit, buildDate = v, c, date
+}
+
+func TestCurrentBuildUsesLdflags(t *testing.T) {
+	setBuild(t, "v1.4.0", "0123abc", "2026-10-01T12:00:00Z")
+	b := currentBuild()
+	if b.Version != "v1.4.0" || b.Commit != "0123abc" || b.BuildDate != "2026-10-01T12:00:00Z" || b.GoVersion == "" {
+		t.Fatalf("build = %+v, want the ldflags values", b)
+	}
+	if got, want := b.String(), "scaler v1.4.0 (commit 0123abc, built 2026-10-01T12:00:00Z), "+b.GoVersion; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+
+	info := systemInfo(42)
+	if info.Version != "v1.4.0" || info.CommitSHA != "0123abc" || info.ScaleSetID != 42 || info.System != "gcp-runner-scaler" {
+		t.Fatalf("systemInfo = %+v", info)
+	}
+}
+
+func TestLoadConfigVersionSkipsValidation(t *testing.T) {
+	cfg, err := loadConfig(flag.NewFlagSet("scaler", flag.ContinueOnError), []string{"--version"})
+	if err != nil || !cfg.showVersion {
+		t.Fatalf("loadConfig(--version) = %v, showVersion %v; want it accepted without --url", err, cfg.showVersion)
+	}
+}
+
+func TestPrintStatusShowsBuild(t *testing.T) {
+	setBuild(t, "v1.4.0", "0123abc", "")
+	var out bytes.Buffer
+	printStatus(&out, adminStatus{ScaleSet: "linux-gpu", Provider: "gcp", Build: currentBuild()}, time.Now())
+	if want := "Running scaler v1.4.0 (commit 0123abc"; !strings.Contains(out.String(), want) {
+		t.Fatalf("output missing %q:\n%s", want, out.String())
+	}
+}
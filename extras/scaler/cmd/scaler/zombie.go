@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// serialOutputReader is implemented by providers that can read the console
+// output of a runner's VM (see *gcp.Manager.SerialOutput).
+type serialOutputReader interface {
+	SerialOutput(ctx context.Context, runnerName string) (string, error)
+}
+
+// zombieReaper deletes the VMs its bootWatchdog finds, e.g. after a bad JIT
+// config or a startup script that crashed. Unlike --boot-timeout it does not replace
+// them: a VM that boots but never runs a job would otherwise count as an
+// active runner, and hold its quota, until the scaler exits.
+type zombieReaper struct {
+	bootWatchdog
+	// serial captures the VM's console output before it is deleted; nil
+	// when the provider has none.
+	serial serialOutputReader
+}
+
+func (s *gcpRunnerScaler) watchZombies(ctx context.Context, w *zombieReaper) {
+	watchEvery(ctx, w.timeout, func(now time.Time) { s.reapZombies(ctx, w, now) })
+}
+
+// reapZombies deletes the VMs that have run no job and whose runner is not
+// online timeout after their boot, and returns how many it deleted.
+func (s *gcpRunnerScaler) reapZombies(ctx context.Context, w *zombieReaper, now time.Time) int {
+	reaped := 0
+	for _, b := range w.stuck(ctx, s.vmManager.VMs(), now) {
+		vm := b.vm
+		var serial string
+		if w.serial != nil {
+			var err error
+			if serial, err = w.serial.SerialOutput(ctx, vm.RunnerName); err != nil {
+				w.logger.Warn("failed to read the VM's serial output", "runner", vm.RunnerName, "error", err)
+			}
+		}
+		s.logger.Warn("runner never came online, deleting its VM",
+			"event", eventVMZombie,
+			"runner", vm.RunnerName,
+			"vm", vm.Name,
+			"location", vm.Location,
+			"status", b.status,
+			"age", b.age.Round(time.Second),
+			"phase", vm.Phase,
+			"boot_error", vm.BootError,
+			"serial_output", serial,
+		)
+		// The runner may have come online and been given a job since its
+		// status was looked up; GitHub then will not remove it.
+		if err := s.removeRunnerFromGitHub(ctx, vm.RunnerName); err != nil {
+			s.logger.Warn("keeping zombie VM whose registration could not be removed", "runner", vm.RunnerName, "error", err)
+			continue
+		}
+		s.latency.forget(vm.RunnerName)
+		s.runners.finished(vm.RunnerName, "zombie")
+		if err := s.vmManager.DeleteByRunnerName(ctx, vm.RunnerName); err != nil {
+			s.logger.Error("failed to delete VM", "event", eventVMDeleteFailed, "runner", vm.RunnerName, "error", err)
+			continue
+		}
+		reaped++
+	}
+	return reaped
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"extras/scaler/internal/vmstate"
+)
+
+// zombieProvider records the runners whose VM it deletes, and serves every
+// VM's serial output.
+type zombieProvider struct {
+	fakeAdminProvider
+	deleted []string
+}
+
+func (p *zombieProvider) DeleteByRunnerName(_ context.Context, runnerName string) error {
+	p.deleted = append(p.deleted, runnerName)
+	return nil
+}
+
+func (p *zombieProvider) SerialOutput(context.Context, string) (string, error) {
+	return "startup-script: runner config failed: invalid JIT config", nil
+}
+
+func TestReapZombiesDeletesVMsWhoseRunnerNeverCameOnline(t *testing.T) {
+	now := time.Now()
+	provider := &zombieProvider{fakeAdminProvider: fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-zombie", Name: "win-zombie", Location: "us-east1-c", CreatedAt: now.Add(-time.Hour)},
+		{RunnerName: "win-offline", CreatedAt: now.Add(-time.Hour)},
+		{RunnerName: "win-idle", CreatedAt: now.Add(-time.Hour)},
+		{RunnerName: "win-busy", Busy: true, CreatedAt: now.Add(-time.Hour)},
+		{RunnerName: "win-new", CreatedAt: now.Add(-5 * time.Minute)},
+		{RunnerName: "win-pending", Pending: true},
+	}}}
+	client, actions := newFakeActionsClient(t)
+	var logs bytes.Buffer
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(&logs, nil)),
+		vmManager:      provider,
+		scalesetClient: client,
+		scaleSetID:     1,
+		vmPrefix:       "win",
+	}
+	status := &fakeRunnerStatus{statuses: map[string]string{"win-offline": "offline", "win-idle": "online"}}
+	w := &zombieReaper{bootWatchdog: bootWatchdog{timeout: 30 * time.Minute, status: status, logger: s.logger, online: map[string]time.Time{}}, serial: provider}
+
+	if n := s.reapZombies(context.Background(), w, now); n != 2 {
+		t.Fatalf("reaped %d VMs, want 2", n)
+	}
+	if want := []string{"win-zombie", "win-offline"}; !reflect.DeepEqual(provider.deleted, want) {
+		t.Fatalf("deleted = %v, want %v", provider.deleted, want)
+	}
+	if len(actions.removed) != 2 {
+		t.Fatalf("runners removed from GitHub = %v, want both zombies'", actions.removed)
+	}
+	if !strings.Contains(logs.String(), "event=vm_zombie") || !strings.Contains(logs.String(), "invalid JIT config") {
+		t.Fatalf("log does not capture the zombie's serial output:\n%s", logs.String())
+	}
+
+	// An online runner is looked up once per boot.
+	status.lookups = nil
+	provider.vms = provider.vms[2:3]
+	s.reapZombies(context.Background(), w, now.Add(time.Minute))
+	if len(status.lookups) != 0 {
+		t.Fatalf("lookups = %v, want none for a runner seen online", status.lookups)
+	}
+}
+
+func TestReapZombiesKeepsVMWhoseRunnerGitHubWouldNotRemove(t *testing.T) {
+	now := time.Now()
+	provider := &zombieProvider{fakeAdminProvider: fakeAdminProvider{vms: []vmstate.VM{
+		{RunnerName: "win-zombie", CreatedAt: now.Add(-time.Hour)},
+	}}}
+	client, actions := newFakeActionsClient(t)
+	s := &gcpRunnerScaler{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		vmManager:      provider,
+		scalesetClient: client,
+		scaleSetID:     1,
+	}
+	status := &fakeRunnerStatus{statuses: map[string]string{"win-zombie": "offline"}}
+	w := &zombieReaper{bootWatchdog: bootWatchdog{timeout: 30 * time.Minute, status: status, logger: s.logger, online: map[string]time.Time{}}}
+
+	// The runner came online and was given a job after its status lookup.
+	actions.removeFailures = 1
+	if n := s.reapZombies(context.Background(), w, now); n != 0 || len(provider.deleted) != 0 {
+		t.Fatalf("reaped %d, deleted = %v, want the VM kept", n, provider.deleted)
+	}
+}
+
+func TestZombieTimeoutIsValidated(t *testing.T) {
+	base := []string{"--url=https://github.com/o/r"}
+	if _, err := testLoadConfig(t, append(base, "--zombie-timeout=45m", "--boot-timeout=15m")...); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	for _, args := range [][]string{
+		{"--zombie-timeout=-1m"},
+		{"--zombie-timeout=3ns"},
+		{"--zombie-timeout=15m", "--boot-timeout=15m"},
+	} {
+		if _, err := testLoadConfig(t, append(base, args...)...); err == nil {
+			t.Errorf("loadConfig(%v) should fail", args)
+		}
+	}
+}
@@ -92,6 +92,7 @@ gcloud compute ssh "${VM_NAME}" --zone="${ZONE}" --project="${PROJECT}" --comman
     sudo mv /tmp/scaler /opt/scaler/scaler
     sudo chmod 755 /opt/scaler/scaler
     sudo chown scaler:scaler /opt/scaler/scaler
+    /opt/scaler/scaler --version
 
     failed=0
     for svc in \$SERVICES; do
@@ -0,0 +1,102 @@
+// Package audit appends JSON records to a size-rotated JSONL file.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Log appends one JSON object per line to a file. When a write would take
+// the file past maxBytes it is renamed to path.1 (shifting older files up
+// to path.<maxFiles>, the oldest being removed) and a new file is started.
+// A nil *Log discards records.
+type Log struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Open opens or creates path for appending. maxFiles is the number of
+// rotated files kept besides the current one.
+func Open(path string, maxBytes int64, maxFiles int) (*Log, error) {
+	l := &Log{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+	l.file, l.size = f, info.Size()
+	return nil
+}
+
+// Write appends record as a JSON line.
+func (l *Log) Write(record any) error {
+	if l == nil {
+		return nil
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.size > 0 && l.size+int64(len(line)) > l.maxBytes {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(l.rotated(l.maxFiles))
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		os.Rename(l.rotated(i), l.rotated(i+1))
+	}
+	if l.maxFiles > 0 {
+		if err := os.Rename(l.path, l.rotated(1)); err != nil {
+			return fmt.Errorf("rotating audit log: %w", err)
+		}
+	} else if err := os.Remove(l.path); err != nil {
+		return fmt.Errorf("rotating audit log: %w", err)
+	}
+	return l.open()
+}
+
+func (l *Log) rotated(n int) string {
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+// Close closes the current file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readLines(t *testing.T, path string) []map[string]int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+	var lines []map[string]int
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var m map[string]int
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("%s: bad line %q: %v", path, sc.Text(), err)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func TestLogAppendsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	for i := range 2 {
+		l, err := Open(path, 1<<20, 2)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		if err := l.Write(map[string]int{"n": i}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		l.Close()
+	}
+	if got := readLines(t, path); len(got) != 2 || got[1]["n"] != 1 {
+		t.Fatalf("lines = %v, want both records", got)
+	}
+}
+
+func TestLogRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	// Each record is 9 bytes ({"n":N}\n), so two fit in 20 bytes.
+	l, err := Open(path, 20, 2)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer l.Close()
+	for i := range 7 {
+		if err := l.Write(map[string]int{"n": i}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	for file, want := range map[string][]int{path: {6}, path + ".1": {4, 5}, path + ".2": {2, 3}} {
+		got := readLines(t, file)
+		if len(got) != len(want) {
+			t.Fatalf("%s = %v, want n=%v", filepath.Base(file), got, want)
+		}
+		for i, n := range want {
+			if got[i]["n"] != n {
+				t.Fatalf("%s = %v, want n=%v", filepath.Base(file), got, want)
+			}
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatal("rotation kept more than maxFiles old files")
+	}
+
+	var nilLog *Log
+	if err := nilLog.Write(map[string]int{"n": 0}); err != nil {
+		t.Fatalf("nil Log Write: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+// Package breaker is a circuit breaker for VM creates: after too many
+// failures in a row it stops letting creates through for a backoff that
+// doubles with every failed retry, then lets a single probe through to see
+// whether creates work again.
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// States reported by State.
+const (
+	Closed   = "closed"    // creates go through
+	Open     = "open"      // creates are held until the backoff ends
+	HalfOpen = "half-open" // the next create probes
+)
+
+// MaxBackoff caps the backoff of the scaler's breakers, which probe at
+// least this often.
+const MaxBackoff = 30 * time.Minute
+
+// Breaker counts consecutive failures. It is safe for concurrent use; a nil
+// *Breaker lets everything through.
+type Breaker struct {
+	threshold  int
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	mu       sync.Mutex
+	failures int
+	wait     time.Duration // the current backoff; 0 while closed
+	until    time.Time     // end of the current backoff
+	// probeUntil is set while a probe is in flight. A probe whose outcome
+	// is never reported, e.g. because it was abandoned before reaching
+	// GCP, frees the breaker for another one after a backoff.
+	probeUntil time.Time
+}
+
+// New returns a Breaker that opens after threshold failures in a row, for
+// backoff at first and up to maxBackoff after failed probes.
+func New(threshold int, backoff, maxBackoff time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, backoff: backoff, maxBackoff: maxBackoff}
+}
+
+// State returns the breaker's state at now.
+func (b *Breaker) State(now time.Time) string {
+	if b == nil {
+		return Closed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stateLocked(now)
+}
+
+func (b *Breaker) stateLocked(now time.Time) string {
+	switch {
+	case b.wait == 0:
+		return Closed
+	case now.Before(b.until) || now.Before(b.probeUntil):
+		return Open
+	default:
+		return HalfOpen
+	}
+}
+
+// Until returns when the current backoff ends; zero while closed.
+func (b *Breaker) Until() time.Time {
+	if b == nil {
+		return time.Time{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.wait == 0 {
+		return time.Time{}
+	}
+	return b.until
+}
+
+// Allow returns how many of n creates may go through at now: all of them
+// while closed, none while open, and one, the probe, while half-open.
+func (b *Breaker) Allow(now time.Time, n int) int {
+	if b == nil || n == 0 {
+		return n
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.stateLocked(now) {
+	case Closed:
+		return n
+	case Open:
+		return 0
+	}
+	b.probeUntil = now.Add(b.wait)
+	return 1
+}
+
+// Success records a create that worked, closing the breaker.
+func (b *Breaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures, b.wait, b.until, b.probeUntil = 0, 0, time.Time{}, time.Time{}
+}
+
+// Failure records a failed create at now. It returns the end of the backoff
+// when the failure opened the breaker, or reopened it after a failed probe.
+func (b *Breaker) Failure(now time.Time) (time.Time, bool) {
+	if b == nil {
+		return time.Time{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures < b.threshold || now.Before(b.until) {
+		// Creates started before the breaker opened fail in its backoff.
+		return time.Time{}, false
+	}
+	if b.wait == 0 {
+		b.wait = b.backoff
+	} else {
+		b.wait = min(2*b.wait, b.maxBackoff)
+	}
+	b.until, b.probeUntil = now.Add(b.wait), time.Time{}
+	return b.until, true
+}
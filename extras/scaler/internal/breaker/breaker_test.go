@@ -0,0 +1,76 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensBacksOffAndProbes(t *testing.T) {
+	b := New(3, time.Minute, 4*time.Minute)
+	now := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+
+	for range 2 {
+		if _, opened := b.Failure(now); opened {
+			t.Fatal("the breaker should stay closed below the threshold")
+		}
+	}
+	if got := b.Allow(now, 5); got != 5 {
+		t.Fatalf("Allow while closed = %d, want 5", got)
+	}
+	if until, opened := b.Failure(now); !opened || !until.Equal(now.Add(time.Minute)) {
+		t.Fatalf("third failure = %v, %v; want open for a minute", until, opened)
+	}
+	// A create started before the breaker opened does not extend it.
+	if _, opened := b.Failure(now.Add(time.Second)); opened {
+		t.Fatal("a failure during the backoff should not reopen the breaker")
+	}
+	if got := b.Allow(now.Add(30*time.Second), 5); got != 0 || b.State(now.Add(30*time.Second)) != Open {
+		t.Fatalf("Allow while open = %d, want 0", got)
+	}
+
+	// Half-open: one probe, and nothing else while it runs.
+	now = now.Add(time.Minute)
+	if b.State(now) != HalfOpen || b.Allow(now, 5) != 1 || b.Allow(now, 5) != 0 {
+		t.Fatal("the half-open breaker should let exactly one probe through")
+	}
+	// The failed probe doubles the backoff, up to the maximum.
+	for _, want := range []time.Duration{2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+		until, opened := b.Failure(now)
+		if !opened || until.Sub(now) != want {
+			t.Fatalf("failed probe = %v, %v; want open for %s", until.Sub(now), opened, want)
+		}
+		now = until
+		b.Allow(now, 1)
+	}
+	b.Success()
+	if b.State(now) != Closed || b.Allow(now, 5) != 5 || !b.Until().IsZero() {
+		t.Fatal("a successful probe should close the breaker")
+	}
+}
+
+func TestBreakerFreesAnAbandonedProbe(t *testing.T) {
+	b := New(1, time.Minute, time.Hour)
+	now := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+	b.Failure(now)
+	now = now.Add(time.Minute)
+	if b.Allow(now, 1) != 1 {
+		t.Fatal("the probe should go through")
+	}
+	if b.Allow(now.Add(59*time.Second), 1) != 0 {
+		t.Fatal("a second probe should wait for the first")
+	}
+	if b.Allow(now.Add(time.Minute), 1) != 1 {
+		t.Fatal("a probe that never reported back should free the breaker after a backoff")
+	}
+}
+
+func TestNilBreakerAllowsEverything(t *testing.T) {
+	var b *Breaker
+	if b.Allow(time.Now(), 3) != 3 || b.State(time.Now()) != Closed {
+		t.Fatal("a nil breaker should let everything through")
+	}
+	if _, opened := b.Failure(time.Now()); opened {
+		t.Fatal("a nil breaker should never open")
+	}
+	b.Success()
+}
@@ -0,0 +1,225 @@
+// Package cloudmetrics publishes the scaler's runner counts as Cloud
+// Monitoring custom metrics, so alerting policies and GCP dashboards work
+// without a Prometheus stack.
+package cloudmetrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	monitoring "google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+
+	"extras/scaler/internal/histogram"
+)
+
+// metricPrefix is the type prefix of every metric the scaler writes.
+const metricPrefix = "custom.googleapis.com/runner_scaler/"
+
+// Sample is one reading of the scaler's state.
+type Sample struct {
+	Active  int // VMs tracked, including creates in flight
+	Desired int // target VM count of the last scaling decision
+	Busy    int // VMs running a job
+
+	// Cumulative since the scaler started; alert on their rate.
+	Creates        int64
+	CreateFailures int64
+	BootSeconds    histogram.Snapshot // runner registration to job assignment
+	QueueSeconds   histogram.Snapshot // job queued to job started
+
+	// GPU quota per region (GCP only), and stockouts per zone, cumulative.
+	Quotas    []Quota
+	Stockouts map[string]int64
+
+	// Usage of the configured reservations (GCP only), and the VMs running
+	// in one or on demand. Only written when Reservations is non-empty.
+	Reservations []Reservation
+	ReservedVMs  int
+	OnDemandVMs  int
+
+	// The VM creates the create rate limit allows right now, and those it
+	// deferred, cumulative. Only written when RateLimited is set.
+	RateLimited      bool
+	CreateTokens     int
+	CreatesThrottled int64
+
+	// Idle runners by the boot phase their VM last reported (GCP only).
+	// Only written when non-nil.
+	BootPhases map[string]int
+
+	// Estimated VM cost in USD of the completed jobs per repository,
+	// cumulative (GCP only). Only written when non-nil.
+	JobCostUSD map[string]float64
+}
+
+// Quota is a region's GPU quota.
+type Quota struct {
+	Region    string
+	GPUType   string
+	Limit     float64
+	Usage     float64
+	Available float64
+}
+
+// Reservation is a reservation's size and use, by the scaler's VMs and
+// any others.
+type Reservation struct {
+	Name  string
+	Zone  string
+	Count int64
+	InUse int64
+}
+
+// Exporter writes Samples to Cloud Monitoring as time series labelled with
+// the scale set name.
+type Exporter struct {
+	project  string
+	scaleSet string
+	start    time.Time // start of the cumulative counters
+	svc      *monitoring.Service
+}
+
+// New returns an Exporter writing to project. Credentials come from the
+// environment and need roles/monitoring.metricWriter on the project.
+func New(ctx context.Context, project, scaleSet string, opts ...option.ClientOption) (*Exporter, error) {
+	svc, err := monitoring.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating Cloud Monitoring client: %w", err)
+	}
+	return &Exporter{project: project, scaleSet: scaleSet, start: time.Now(), svc: svc}, nil
+}
+
+// Export writes s as points at now.
+func (e *Exporter) Export(ctx context.Context, s Sample, now time.Time) error {
+	end := now.UTC().Format(time.RFC3339Nano)
+	start := e.start.UTC().Format(time.RFC3339Nano)
+	gauge := func(name string, v int) *monitoring.TimeSeries {
+		return e.series(name, "GAUGE", &monitoring.TimeInterval{EndTime: end}, int64(v), nil)
+	}
+	cumulative := func(name string, v int64) *monitoring.TimeSeries {
+		return e.series(name, "CUMULATIVE", &monitoring.TimeInterval{StartTime: start, EndTime: end}, v, nil)
+	}
+	req := &monitoring.CreateTimeSeriesRequest{TimeSeries: []*monitoring.TimeSeries{
+		gauge("active_runners", s.Active),
+		gauge("desired_runners", s.Desired),
+		gauge("busy_runners", s.Busy),
+		cumulative("vm_creates", s.Creates),
+		cumulative("vm_create_failures", s.CreateFailures),
+	}}
+	for _, q := range s.Quotas {
+		labels := map[string]string{"region": q.Region, "gpu_type": q.GPUType}
+		for _, m := range []struct {
+			name string
+			v    float64
+		}{
+			{"gpu_quota_limit", q.Limit},
+			{"gpu_quota_usage", q.Usage},
+			{"gpu_quota_available", q.Available},
+		} {
+			ts := e.series(m.name, "GAUGE", &monitoring.TimeInterval{EndTime: end}, 0, labels)
+			ts.ValueType = "DOUBLE"
+			ts.Points[0].Value = &monitoring.TypedValue{DoubleValue: &m.v}
+			req.TimeSeries = append(req.TimeSeries, ts)
+		}
+	}
+	zones := make([]string, 0, len(s.Stockouts))
+	for zone := range s.Stockouts {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		req.TimeSeries = append(req.TimeSeries, e.series("zone_stockouts", "CUMULATIVE",
+			&monitoring.TimeInterval{StartTime: start, EndTime: end}, s.Stockouts[zone], map[string]string{"zone": zone}))
+	}
+	if len(s.Reservations) > 0 {
+		req.TimeSeries = append(req.TimeSeries, gauge("reserved_vms", s.ReservedVMs), gauge("on_demand_vms", s.OnDemandVMs))
+	}
+	for _, r := range s.Reservations {
+		labels := map[string]string{"reservation": r.Name, "zone": r.Zone}
+		req.TimeSeries = append(req.TimeSeries,
+			e.series("reservation_count", "GAUGE", &monitoring.TimeInterval{EndTime: end}, r.Count, labels),
+			e.series("reservation_in_use", "GAUGE", &monitoring.TimeInterval{EndTime: end}, r.InUse, labels))
+	}
+	if s.RateLimited {
+		req.TimeSeries = append(req.TimeSeries, gauge("create_tokens", s.CreateTokens), cumulative("vm_creates_throttled", s.CreatesThrottled))
+	}
+	phases := make([]string, 0, len(s.BootPhases))
+	for phase := range s.BootPhases {
+		phases = append(phases, phase)
+	}
+	sort.Strings(phases)
+	for _, phase := range phases {
+		req.TimeSeries = append(req.TimeSeries, e.series("boot_phase_runners", "GAUGE",
+			&monitoring.TimeInterval{EndTime: end}, int64(s.BootPhases[phase]), map[string]string{"phase": phase}))
+	}
+	repos := make([]string, 0, len(s.JobCostUSD))
+	for repo := range s.JobCostUSD {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	for _, repo := range repos {
+		v := s.JobCostUSD[repo]
+		ts := e.series("job_cost_usd", "CUMULATIVE", &monitoring.TimeInterval{StartTime: start, EndTime: end}, 0, map[string]string{"repository": repo})
+		ts.ValueType = "DOUBLE"
+		ts.Points[0].Value = &monitoring.TypedValue{DoubleValue: &v}
+		req.TimeSeries = append(req.TimeSeries, ts)
+	}
+	// Cloud Monitoring rejects empty distributions.
+	for _, d := range []struct {
+		name string
+		h    histogram.Snapshot
+	}{
+		{"runner_boot_seconds", s.BootSeconds},
+		{"job_queue_seconds", s.QueueSeconds},
+	} {
+		if d.h.Count == 0 {
+			continue
+		}
+		ts := e.series(d.name, "CUMULATIVE", &monitoring.TimeInterval{StartTime: start, EndTime: end}, 0, nil)
+		ts.ValueType = "DISTRIBUTION"
+		ts.Points[0].Value = &monitoring.TypedValue{DistributionValue: distribution(d.h)}
+		req.TimeSeries = append(req.TimeSeries, ts)
+	}
+	if _, err := e.svc.Projects.TimeSeries.Create("projects/"+e.project, req).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("writing time series: %w", err)
+	}
+	return nil
+}
+
+func distribution(h histogram.Snapshot) *monitoring.Distribution {
+	return &monitoring.Distribution{
+		Count:                 h.Count,
+		Mean:                  h.Mean,
+		SumOfSquaredDeviation: h.SumOfSquaredDeviation,
+		BucketOptions:         &monitoring.BucketOptions{ExplicitBuckets: &monitoring.Explicit{Bounds: h.Bounds}},
+		BucketCounts:          h.Counts,
+	}
+}
+
+// series returns an INT64 time series of v labelled with the scale set and
+// labels.
+func (e *Exporter) series(name, kind string, interval *monitoring.TimeInterval, v int64, labels map[string]string) *monitoring.TimeSeries {
+	metricLabels := map[string]string{"scale_set": e.scaleSet}
+	for k, v := range labels {
+		metricLabels[k] = v
+	}
+	return &monitoring.TimeSeries{
+		Metric: &monitoring.Metric{
+			Type:   metricPrefix + name,
+			Labels: metricLabels,
+		},
+		Resource: &monitoring.MonitoredResource{
+			Type:   "global",
+			Labels: map[string]string{"project_id": e.project},
+		},
+		MetricKind: kind,
+		ValueType:  "INT64",
+		Points: []*monitoring.Point{{
+			Interval: interval,
+			Value:    &monitoring.TypedValue{Int64Value: &v},
+		}},
+	}
+}
@@ -0,0 +1,305 @@
+package cloudmetrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	monitoring "google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+
+	"extras/scaler/internal/histogram"
+)
+
+func TestExportWritesSeries(t *testing.T) {
+	var path string
+	var req monitoring.CreateTimeSeriesRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	e, err := New(context.Background(), "slang-runners", "windows-gpu",
+		option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	e.start = time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+	now := e.start.Add(time.Hour)
+	err = e.Export(context.Background(), Sample{Active: 3, Desired: 4, Busy: 2, Creates: 10, CreateFailures: 1}, now)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	if path != "/v3/projects/slang-runners/timeSeries" {
+		t.Fatalf("path = %q", path)
+	}
+	want := map[string]struct {
+		kind  string
+		value int64
+	}{
+		"active_runners":     {"GAUGE", 3},
+		"desired_runners":    {"GAUGE", 4},
+		"busy_runners":       {"GAUGE", 2},
+		"vm_creates":         {"CUMULATIVE", 10},
+		"vm_create_failures": {"CUMULATIVE", 1},
+	}
+	if len(req.TimeSeries) != len(want) {
+		t.Fatalf("series = %d, want %d", len(req.TimeSeries), len(want))
+	}
+	for _, ts := range req.TimeSeries {
+		name := ts.Metric.Type[len(metricPrefix):]
+		w, ok := want[name]
+		if !ok {
+			t.Fatalf("unexpected metric %q", ts.Metric.Type)
+		}
+		p := ts.Points[0]
+		if ts.MetricKind != w.kind || *p.Value.Int64Value != w.value {
+			t.Errorf("%s = %s %d, want %s %d", name, ts.MetricKind, *p.Value.Int64Value, w.kind, w.value)
+		}
+		if ts.Metric.Labels["scale_set"] != "windows-gpu" || ts.Resource.Labels["project_id"] != "slang-runners" {
+			t.Errorf("%s labels = %v / %v", name, ts.Metric.Labels, ts.Resource.Labels)
+		}
+		if p.Interval.EndTime != "2026-03-02T15:00:00Z" {
+			t.Errorf("%s end time = %q", name, p.Interval.EndTime)
+		}
+		if (w.kind == "CUMULATIVE") != (p.Interval.StartTime == "2026-03-02T14:00:00Z") {
+			t.Errorf("%s start time = %q", name, p.Interval.StartTime)
+		}
+	}
+}
+
+func TestExportWritesLatencyDistributions(t *testing.T) {
+	var req monitoring.CreateTimeSeriesRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+	e, err := New(context.Background(), "slang-runners", "windows-gpu",
+		option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	boot := histogram.New(60, 300)
+	boot.Observe(120)
+	boot.Observe(180)
+	err = e.Export(context.Background(), Sample{BootSeconds: boot.Snapshot(), QueueSeconds: histogram.New(60).Snapshot()}, time.Now())
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	if len(req.TimeSeries) != 6 {
+		t.Fatalf("series = %d, want 5 counts plus the non-empty boot distribution", len(req.TimeSeries))
+	}
+	ts := req.TimeSeries[5]
+	d := ts.Points[0].Value.DistributionValue
+	if ts.Metric.Type != metricPrefix+"runner_boot_seconds" || ts.ValueType != "DISTRIBUTION" || d == nil {
+		t.Fatalf("last series = %s %s", ts.Metric.Type, ts.ValueType)
+	}
+	if d.Count != 2 || d.Mean != 150 || !slices.Equal(d.BucketCounts, []int64{0, 2, 0}) ||
+		!slices.Equal(d.BucketOptions.ExplicitBuckets.Bounds, []float64{60, 300}) {
+		t.Fatalf("distribution = %+v", d)
+	}
+}
+
+func TestExportWritesQuotaAndStockouts(t *testing.T) {
+	var req monitoring.CreateTimeSeriesRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+	e, err := New(context.Background(), "slang-runners", "windows-gpu",
+		option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	err = e.Export(context.Background(), Sample{
+		Quotas:    []Quota{{Region: "us-east1", GPUType: "nvidia-l4", Limit: 16, Usage: 12, Available: 4}},
+		Stockouts: map[string]int64{"us-east1-d": 3, "us-east1-b": 1},
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	got := make(map[string]*monitoring.TimeSeries)
+	for _, ts := range req.TimeSeries[5:] {
+		key := ts.Metric.Type[len(metricPrefix):] + " " + ts.Metric.Labels["region"] + ts.Metric.Labels["zone"]
+		got[key] = ts
+	}
+	for key, want := range map[string]float64{
+		"gpu_quota_limit us-east1":     16,
+		"gpu_quota_usage us-east1":     12,
+		"gpu_quota_available us-east1": 4,
+	} {
+		ts := got[key]
+		if ts == nil || ts.ValueType != "DOUBLE" || *ts.Points[0].Value.DoubleValue != want {
+			t.Fatalf("%s = %+v, want DOUBLE %v", key, ts, want)
+		}
+		if ts.Metric.Labels["gpu_type"] != "nvidia-l4" || ts.Metric.Labels["scale_set"] != "windows-gpu" {
+			t.Fatalf("%s labels = %v", key, ts.Metric.Labels)
+		}
+	}
+	for key, want := range map[string]int64{"zone_stockouts us-east1-d": 3, "zone_stockouts us-east1-b": 1} {
+		ts := got[key]
+		if ts == nil || ts.MetricKind != "CUMULATIVE" || *ts.Points[0].Value.Int64Value != want {
+			t.Fatalf("%s = %+v, want CUMULATIVE %d", key, ts, want)
+		}
+	}
+	if len(got) != 5 {
+		t.Fatalf("capacity series = %d, want 3 quota gauges and 2 stockout counters", len(got))
+	}
+}
+
+func TestExportWritesReservations(t *testing.T) {
+	var req monitoring.CreateTimeSeriesRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+	e, err := New(context.Background(), "slang-runners", "windows-gpu",
+		option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	err = e.Export(context.Background(), Sample{
+		Reservations: []Reservation{{Name: "t4-commit", Zone: "us-west1-a", Count: 8, InUse: 6}},
+		ReservedVMs:  5,
+		OnDemandVMs:  3,
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	got := make(map[string]int64)
+	for _, ts := range req.TimeSeries[5:] {
+		got[ts.Metric.Type[len(metricPrefix):]+" "+ts.Metric.Labels["reservation"]] = *ts.Points[0].Value.Int64Value
+	}
+	want := map[string]int64{
+		"reserved_vms ":                5,
+		"on_demand_vms ":               3,
+		"reservation_count t4-commit":  8,
+		"reservation_in_use t4-commit": 6,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("series = %v, want %v", got, want)
+	}
+	for key, v := range want {
+		if got[key] != v {
+			t.Fatalf("%s = %d, want %d", key, got[key], v)
+		}
+	}
+}
+
+func TestExportWritesBootPhases(t *testing.T) {
+	var req monitoring.CreateTimeSeriesRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+	e, err := New(context.Background(), "slang-runners", "windows-gpu",
+		option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	err = e.Export(context.Background(), Sample{
+		BootPhases: map[string]int{"started": 2, "driver-ok": 1, "failed": 0},
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	var phases []string
+	got := make(map[string]int64)
+	for _, ts := range req.TimeSeries[5:] {
+		if ts.Metric.Type != metricPrefix+"boot_phase_runners" {
+			t.Fatalf("unexpected series %s", ts.Metric.Type)
+		}
+		phases = append(phases, ts.Metric.Labels["phase"])
+		got[ts.Metric.Labels["phase"]] = *ts.Points[0].Value.Int64Value
+	}
+	if strings.Join(phases, ",") != "driver-ok,failed,started" {
+		t.Fatalf("phases = %v, want one series per phase in order", phases)
+	}
+	if got["started"] != 2 || got["driver-ok"] != 1 || got["failed"] != 0 {
+		t.Fatalf("series = %v", got)
+	}
+}
+
+func TestExportWritesCreateRateLimit(t *testing.T) {
+	var req monitoring.CreateTimeSeriesRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+	e, err := New(context.Background(), "slang-runners", "windows-gpu",
+		option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := e.Export(context.Background(), Sample{CreateTokens: 3, CreatesThrottled: 40}, time.Now()); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if len(req.TimeSeries) != 5 {
+		t.Fatalf("series = %d, want none for the rate limit unless it is set", len(req.TimeSeries))
+	}
+	if err := e.Export(context.Background(), Sample{RateLimited: true, CreateTokens: 3, CreatesThrottled: 40}, time.Now()); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	got := make(map[string]string)
+	for _, ts := range req.TimeSeries[5:] {
+		got[ts.Metric.Type[len(metricPrefix):]] = fmt.Sprintf("%s %d", ts.MetricKind, *ts.Points[0].Value.Int64Value)
+	}
+	if got["create_tokens"] != "GAUGE 3" || got["vm_creates_throttled"] != "CUMULATIVE 40" || len(got) != 2 {
+		t.Fatalf("series = %v", got)
+	}
+}
+
+func TestExportWritesJobCosts(t *testing.T) {
+	var req monitoring.CreateTimeSeriesRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+	e, err := New(context.Background(), "slang-runners", "windows-gpu",
+		option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	err = e.Export(context.Background(), Sample{
+		JobCostUSD: map[string]float64{"shader-slang/slang": 12.5, "shader-slang/slang-rhi": 0.75},
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	var got []string
+	for _, ts := range req.TimeSeries[5:] {
+		if ts.Metric.Type != metricPrefix+"job_cost_usd" || ts.MetricKind != "CUMULATIVE" || ts.ValueType != "DOUBLE" {
+			t.Fatalf("unexpected series %s %s %s", ts.Metric.Type, ts.MetricKind, ts.ValueType)
+		}
+		got = append(got, fmt.Sprintf("%s=%v", ts.Metric.Labels["repository"], *ts.Points[0].Value.DoubleValue))
+	}
+	if strings.Join(got, ",") != "shader-slang/slang=12.5,shader-slang/slang-rhi=0.75" {
+		t.Fatalf("series = %v, want one per repository in order", got)
+	}
+}
@@ -0,0 +1,178 @@
+package costreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"extras/scaler/internal/gcp"
+)
+
+// BillingRow is the part of a row of the Cloud Billing export to BigQuery
+// that Reconcile uses. The resource name is only in the detailed usage
+// cost export.
+type BillingRow struct {
+	Cost    number `json:"cost"`
+	Credits []struct {
+		Amount number `json:"amount"`
+	} `json:"credits"`
+	Labels   []billingLabel `json:"labels"`
+	Resource struct {
+		Name string `json:"name"`
+	} `json:"resource"`
+	UsageStartTime timestamp `json:"usage_start_time"`
+}
+
+type billingLabel struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// net returns the row's cost after credits, such as sustained use
+// discounts, which the export lists as negative amounts.
+func (r BillingRow) net() float64 {
+	total := float64(r.Cost)
+	for _, c := range r.Credits {
+		total += float64(c.Amount)
+	}
+	return total
+}
+
+func (r BillingRow) label(key string) string {
+	for _, l := range r.Labels {
+		if l.Key == key {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+// ReadBillingExport parses billing export rows exported from BigQuery as
+// newline-delimited JSON (bq extract) or as a JSON array (bq query
+// --format=json).
+func ReadBillingExport(r io.Reader) ([]BillingRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	var rows []BillingRow
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, fmt.Errorf("parsing billing export: %w", err)
+		}
+		return rows, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var row BillingRow
+		if err := dec.Decode(&row); err != nil {
+			return nil, fmt.Errorf("parsing billing export row %d: %w", len(rows)+1, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Reconcile returns the jobs of the period [start, end) with their
+// estimated costs replaced by what the billing export billed for their VMs.
+// jobs are all the audit logs record, so that rows of jobs outside the
+// period are told apart from those of no job. A row
+// belongs to the job whose VM it names and whose runner's lifetime, from
+// the hour the VM was created or reused in to the job's completion, its
+// usage started in; rows of the standard export, which name no resource,
+// go to the job of their run ID label. The export bills by the hour, so a
+// VM reused within the hour bills the whole hour to one of its jobs.
+//
+// Rows of the scaler's VMs that belong to no job of the period, e.g. a
+// boot that never got a job or the disks of a stopped VM, are returned per
+// pool label as unattributed when their usage started in the period.
+func Reconcile(jobs []Job, rows []BillingRow, start, end time.Time) (billed []Job, unattributed map[string]float64) {
+	billed = make([]Job, len(jobs))
+	byVM := make(map[string][]int)
+	byRun := make(map[int64][]int)
+	for i, job := range jobs {
+		billed[i] = job
+		billed[i].CostUSD = 0
+		byVM[job.VM] = append(byVM[job.VM], i)
+		byRun[job.WorkflowRun] = append(byRun[job.WorkflowRun], i)
+	}
+	within := func(job Job, t time.Time) bool {
+		from := job.Time.Add(-time.Duration(job.UptimeSeconds) * time.Second).Truncate(time.Hour)
+		return !t.Before(from) && !t.After(job.Time)
+	}
+	unattributed = make(map[string]float64)
+	for _, row := range rows {
+		t := time.Time(row.UsageStartTime)
+		match := -1
+		if vm := path.Base(row.Resource.Name); row.Resource.Name != "" {
+			for _, i := range byVM[vm] {
+				if within(jobs[i], t) {
+					match = i
+					break
+				}
+			}
+		} else if runID, err := strconv.ParseInt(row.label(gcp.LabelRunID), 10, 64); err == nil {
+			for _, i := range byRun[runID] {
+				if within(jobs[i], t) {
+					match = i
+					break
+				}
+			}
+		}
+		if match >= 0 {
+			billed[match].CostUSD += row.net()
+			continue
+		}
+		if pool := row.label(gcp.LabelPool); pool != "" && !t.Before(start) && t.Before(end) {
+			unattributed[pool] += row.net()
+		}
+	}
+	inPeriod := billed[:0]
+	for _, job := range billed {
+		if !job.Time.Before(start) && job.Time.Before(end) {
+			inPeriod = append(inPeriod, job)
+		}
+	}
+	return inPeriod, unattributed
+}
+
+// number is a JSON number that bq's JSON output may quote.
+type number float64
+
+func (n *number) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*n = number(v)
+	return nil
+}
+
+// timestamp is a BigQuery TIMESTAMP as RFC 3339, or as bq prints it, e.g.
+// "2026-03-03 01:00:00 UTC".
+type timestamp time.Time
+
+func (ts *timestamp) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999 MST", "2006-01-02 15:04:05.999999999"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			*ts = timestamp(t)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid timestamp %q", s)
+}
@@ -0,0 +1,86 @@
+package costreport
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"extras/scaler/internal/gcp"
+)
+
+func TestReadBillingExport(t *testing.T) {
+	array := `[{"cost":"1.25","credits":[{"amount":"-0.25"}],"labels":[{"key":"scaler-pool","value":"win-gpu"}],
+		"resource":{"name":"projects/p/zones/us-east1-c/instances/win-gpu-1"},"usage_start_time":"2026-03-03 01:00:00 UTC"}]`
+	ndjson := `{"cost":1.25,"credits":[{"amount":-0.25}],"labels":[{"key":"scaler-pool","value":"win-gpu"}],"resource":{"name":"projects/p/zones/us-east1-c/instances/win-gpu-1"},"usage_start_time":"2026-03-03T01:00:00Z"}
+{"cost":0.5,"usage_start_time":"2026-03-03T02:00:00Z"}
+`
+	for name, input := range map[string]string{"array": array, "ndjson": ndjson} {
+		rows, err := ReadBillingExport(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("%s: ReadBillingExport: %v", name, err)
+		}
+		row := rows[0]
+		if row.net() != 1 || row.label(gcp.LabelPool) != "win-gpu" || !time.Time(row.UsageStartTime).Equal(time.Date(2026, 3, 3, 1, 0, 0, 0, time.UTC)) {
+			t.Fatalf("%s: row = %+v", name, row)
+		}
+	}
+	if _, err := ReadBillingExport(strings.NewReader(`{"cost":1,"usage_start_time":"yesterday"}`)); err == nil {
+		t.Fatal("ReadBillingExport should reject an invalid timestamp")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	start := time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	jobs := []Job{
+		// Ran 01:30-02:30 on win-gpu-1, so billed for its 01:00 and 02:00 hours.
+		{Time: start.Add(150 * time.Minute), Pool: "win-gpu", Repository: "shader-slang/slang", Workflow: "ci.yml", WorkflowRun: 1, VM: "win-gpu-1", UptimeSeconds: 3600, CostUSD: 9},
+		// Reused win-gpu-1 at 05:00 after it idled through 03:00 and 04:00.
+		{Time: start.Add(5*time.Hour + 20*time.Minute), Pool: "win-gpu", Repository: "shader-slang/slang", Workflow: "ci.yml", WorkflowRun: 2, VM: "win-gpu-1", UptimeSeconds: 1200, CostUSD: 9},
+		// Completed before the period.
+		{Time: start.Add(-30 * time.Minute), Pool: "win-gpu", WorkflowRun: 3, VM: "win-gpu-2", UptimeSeconds: 1200, CostUSD: 9},
+	}
+	row := func(vm string, runID string, hour int, cost float64) BillingRow {
+		var r BillingRow
+		if vm != "" {
+			r.Resource.Name = "projects/p/zones/us-east1-c/instances/" + vm
+		}
+		r.Labels = append(r.Labels, billingLabel{gcp.LabelPool, "win-gpu"})
+		if runID != "" {
+			r.Labels = append(r.Labels, billingLabel{gcp.LabelRunID, runID})
+		}
+		r.Cost = number(cost)
+		r.UsageStartTime = timestamp(start.Add(time.Duration(hour) * time.Hour))
+		return r
+	}
+	rows := []BillingRow{
+		row("win-gpu-1", "", 1, 1),
+		row("win-gpu-1", "", 2, 1),
+		row("win-gpu-1", "", 3, 0.5), // idle
+		row("win-gpu-1", "", 4, 0.5), // idle
+		row("win-gpu-1", "", 5, 1),
+		row("", "2", 5, 0.25),       // a standard export row, by run ID
+		row("win-gpu-2", "", -1, 2), // the job before the period
+		row("win-gpu-3", "", 7, 3),  // a boot without a job
+	}
+	billed, unattributed := Reconcile(jobs, rows, start, end)
+	if len(billed) != 2 {
+		t.Fatalf("billed %d jobs, want the 2 of the period", len(billed))
+	}
+	if billed[0].CostUSD != 2 || billed[1].CostUSD != 1.25 {
+		t.Fatalf("billed = %v and %v, want 2 and 1.25", billed[0].CostUSD, billed[1].CostUSD)
+	}
+	if math.Abs(unattributed["win-gpu"]-4) > 1e-9 || len(unattributed) != 1 {
+		t.Fatalf("unattributed = %v, want win-gpu 4", unattributed)
+	}
+
+	r := NewBilled(billed, unattributed, start, end)
+	md := r.Markdown()
+	if !strings.Contains(md, "Cloud Billing export") || !strings.Contains(md, "| `win-gpu` | 4.00 |") {
+		t.Fatalf("Markdown =\n%s", md)
+	}
+	if !strings.Contains(r.CSV(), ",unattributed,win-gpu,0,0.00,4.00") {
+		t.Fatalf("CSV =\n%s", r.CSV())
+	}
+}
@@ -0,0 +1,245 @@
+// Package costreport totals the job costs the scaler records in its audit
+// log (see --job-costs) per pool, repository and workflow over a period,
+// and renders the totals as Markdown, CSV or aligned text.
+package costreport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// decisionJobCost marks the audit log lines Read parses.
+const decisionJobCost = "job_cost"
+
+// Job is the cost of one completed job, as recorded in the audit log.
+type Job struct {
+	Time          time.Time `json:"time"`
+	Decision      string    `json:"decision"`
+	Pool          string    `json:"pool"`
+	Repository    string    `json:"repository"`
+	Workflow      string    `json:"workflow"`
+	WorkflowRun   int64     `json:"workflow_run"`
+	VM            string    `json:"vm"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	CostUSD       float64   `json:"cost_usd"`
+}
+
+// Read returns the jobs of audit log r that completed in [start, end), or
+// from start on when end is zero.
+// Scaling decisions and lines that are not JSON, e.g. one cut short by a
+// crash, are skipped.
+func Read(r io.Reader, start, end time.Time) ([]Job, error) {
+	var jobs []Job
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if !bytes.Contains(line, []byte(`"`+decisionJobCost+`"`)) {
+			continue
+		}
+		var job Job
+		if err := json.Unmarshal(line, &job); err != nil || job.Decision != decisionJobCost {
+			continue
+		}
+		if job.Time.Before(start) || !end.IsZero() && !job.Time.Before(end) {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, sc.Err()
+}
+
+// Row is the total of one pool, repository or workflow.
+type Row struct {
+	Name    string
+	Jobs    int
+	VMHours float64
+	CostUSD float64
+}
+
+func (r *Row) add(job Job) {
+	r.Jobs++
+	r.VMHours += float64(job.UptimeSeconds) / 3600
+	r.CostUSD += job.CostUSD
+}
+
+// Report is the cost of the jobs of a period. Each breakdown lists the
+// most expensive first.
+type Report struct {
+	Start, End   time.Time
+	Total        Row
+	Pools        []Row
+	Repositories []Row
+	Workflows    []Row // named "<repository> <workflow file>"
+	// Billed is set when the costs come from the billing export (see
+	// Reconcile) rather than estimates, and Unattributed then holds the
+	// billed costs of no job per pool.
+	Billed       bool
+	Unattributed []Row
+}
+
+// NewBilled is New for jobs whose costs Reconcile took from the billing
+// export, with the costs it could not attribute to a job.
+func NewBilled(jobs []Job, unattributed map[string]float64, start, end time.Time) *Report {
+	r := New(jobs, start, end)
+	r.Billed = true
+	rows := make(map[string]*Row)
+	for pool, usd := range unattributed {
+		group(rows, pool).CostUSD = usd
+	}
+	r.Unattributed = sorted(rows)
+	return r
+}
+
+// New totals jobs for the period [start, end).
+func New(jobs []Job, start, end time.Time) *Report {
+	r := &Report{Start: start, End: end, Total: Row{Name: "total"}}
+	pools := make(map[string]*Row)
+	repos := make(map[string]*Row)
+	workflows := make(map[string]*Row)
+	for _, job := range jobs {
+		r.Total.add(job)
+		group(pools, orUnknown(job.Pool)).add(job)
+		group(repos, orUnknown(job.Repository)).add(job)
+		group(workflows, orUnknown(job.Repository)+" "+orUnknown(job.Workflow)).add(job)
+	}
+	r.Pools, r.Repositories, r.Workflows = sorted(pools), sorted(repos), sorted(workflows)
+	return r
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "(unknown)"
+	}
+	return s
+}
+
+func group(rows map[string]*Row, name string) *Row {
+	row, ok := rows[name]
+	if !ok {
+		row = &Row{Name: name}
+		rows[name] = row
+	}
+	return row
+}
+
+func sorted(rows map[string]*Row) []Row {
+	out := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, *row)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CostUSD != out[j].CostUSD {
+			return out[i].CostUSD > out[j].CostUSD
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Title names the report's period.
+func (r *Report) Title() string {
+	return fmt.Sprintf("Runner cost %s to %s", r.Start.UTC().Format(time.DateTime), r.End.UTC().Format(time.DateTime))
+}
+
+// section is one of a report's breakdowns.
+type section struct {
+	heading, kind string
+	rows          []Row
+}
+
+func (r *Report) sections() []section {
+	return []section{
+		{"Pool", "pool", r.Pools},
+		{"Repository", "repository", r.Repositories},
+		{"Workflow", "workflow", r.Workflows},
+		{"Unattributed", "unattributed", r.Unattributed},
+	}
+}
+
+// source describes where the report's costs come from.
+func (r *Report) source() string {
+	if r.Billed {
+		return "Costs are from the Cloud Billing export."
+	}
+	return "Costs are estimates from list prices."
+}
+
+// Markdown renders the report as Markdown tables, e.g. for a GitHub issue.
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s UTC\n\n", r.Title())
+	fmt.Fprintf(&b, "**$%.2f** for %d jobs on %.1f VM hours. %s\n", r.Total.CostUSD, r.Total.Jobs, r.Total.VMHours, r.source())
+	for _, s := range r.sections() {
+		if s.kind == "unattributed" {
+			if len(s.rows) == 0 {
+				continue
+			}
+			b.WriteString("\n## Unattributed\n\nBilled for the pools' VMs outside any job of the period, e.g. boots that got no job.\n\n")
+			b.WriteString("| Pool | Cost (USD) |\n|---|--:|\n")
+			for _, row := range s.rows {
+				fmt.Fprintf(&b, "| `%s` | %.2f |\n", row.Name, row.CostUSD)
+			}
+			continue
+		}
+		fmt.Fprintf(&b, "\n## By %s\n\n", strings.ToLower(s.heading))
+		fmt.Fprintf(&b, "| %s | Jobs | VM hours | Cost (USD) |\n|---|--:|--:|--:|\n", s.heading)
+		for _, row := range s.rows {
+			fmt.Fprintf(&b, "| `%s` | %d | %.1f | %.2f |\n", row.Name, row.Jobs, row.VMHours, row.CostUSD)
+		}
+	}
+	return b.String()
+}
+
+// CSV renders the report as one CSV row per pool, repository and workflow,
+// plus the total, for a spreadsheet.
+func (r *Report) CSV() string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write([]string{"start", "end", "kind", "name", "jobs", "vm_hours", "cost_usd"})
+	start, end := r.Start.UTC().Format(time.RFC3339), r.End.UTC().Format(time.RFC3339)
+	write := func(kind string, row Row) {
+		w.Write([]string{start, end, kind, row.Name, strconv.Itoa(row.Jobs),
+			strconv.FormatFloat(row.VMHours, 'f', 2, 64), strconv.FormatFloat(row.CostUSD, 'f', 2, 64)})
+	}
+	write("total", r.Total)
+	for _, s := range r.sections() {
+		for _, row := range s.rows {
+			write(s.kind, row)
+		}
+	}
+	w.Flush()
+	return b.String()
+}
+
+// Text renders the report as aligned plain text, limited to the top rows of
+// each breakdown (0 lists all), e.g. for a chat message.
+func (r *Report) Text(top int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s UTC: $%.2f for %d jobs on %.1f VM hours\n", r.Title(), r.Total.CostUSD, r.Total.Jobs, r.Total.VMHours)
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	for _, s := range r.sections() {
+		if s.kind == "unattributed" && len(s.rows) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "\n%s\tJobs\tVM hours\tUSD\n", s.heading)
+		rows := s.rows
+		if top > 0 && len(rows) > top {
+			rows = rows[:top]
+		}
+		for _, row := range rows {
+			fmt.Fprintf(tw, "%s\t%d\t%.1f\t%.2f\n", row.Name, row.Jobs, row.VMHours, row.CostUSD)
+		}
+	}
+	tw.Flush()
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package costreport
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testLog = `{"time":"2026-03-02T23:00:00Z","decision":"job_cost","pool":"win-gpu","repository":"shader-slang/slang","workflow":"ci.yml","uptime_seconds":3600,"cost_usd":1.5}
+{"time":"2026-03-03T01:00:00Z","pending_jobs":1,"current":0,"target":1,"decision":"scale_up","result":1}
+{"time":"2026-03-03T02:00:00Z","decision":"job_cost","pool":"win-gpu","repository":"shader-slang/slang","workflow":"ci.yml","uptime_seconds":5400,"cost_usd":2.25}
+{"time":"2026-03-03T03:00:00Z","decision":"job_cost","pool":"linux-build","repository":"shader-slang/slang","workflow":"nightly.yml","uptime_seconds":1800,"cost_usd":0.25}
+{"time":"2026-03-03T04:00:00Z","decision":"job_cost","pool":"win-gpu","repository":"shader-slang/slang-rhi","uptime_seconds":7200,"cost_usd":3}
+{"time":"2026-03-03T05:00:00Z","decision":"job_co
+{"time":"2026-03-04T00:00:00Z","decision":"job_cost","pool":"win-gpu","repository":"shader-slang/slang","workflow":"ci.yml","uptime_seconds":3600,"cost_usd":1.5}
+`
+
+func TestReportTotalsThePeriod(t *testing.T) {
+	start := time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	jobs, err := Read(strings.NewReader(testLog), start, end)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(jobs) != 3 {
+		t.Fatalf("read %d jobs, want the 3 of the period", len(jobs))
+	}
+	if all, err := Read(strings.NewReader(testLog), time.Time{}, time.Time{}); err != nil || len(all) != 5 {
+		t.Fatalf("Read without an end = %d jobs, %v; want all 5", len(all), err)
+	}
+	r := New(jobs, start, end)
+	if r.Total.Jobs != 3 || r.Total.CostUSD != 5.5 || r.Total.VMHours != 4 {
+		t.Fatalf("total = %+v", r.Total)
+	}
+	if len(r.Pools) != 2 || r.Pools[0] != (Row{Name: "win-gpu", Jobs: 2, VMHours: 3.5, CostUSD: 5.25}) {
+		t.Fatalf("pools = %+v, want win-gpu first", r.Pools)
+	}
+	if got := r.Repositories[0].Name; got != "shader-slang/slang-rhi" {
+		t.Fatalf("most expensive repository = %s, want shader-slang/slang-rhi", got)
+	}
+	var workflows []string
+	for _, row := range r.Workflows {
+		workflows = append(workflows, row.Name)
+	}
+	if got := strings.Join(workflows, ","); got != "shader-slang/slang-rhi (unknown),shader-slang/slang ci.yml,shader-slang/slang nightly.yml" {
+		t.Fatalf("workflows = %s", got)
+	}
+}
+
+func TestReportRendering(t *testing.T) {
+	start := time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)
+	r := New([]Job{
+		{Pool: "win-gpu", Repository: "shader-slang/slang", Workflow: "ci.yml", UptimeSeconds: 5400, CostUSD: 2.25},
+	}, start, start.Add(24*time.Hour))
+
+	md := r.Markdown()
+	for _, want := range []string{
+		"# Runner cost 2026-03-03 00:00:00 to 2026-03-04 00:00:00 UTC",
+		"**$2.25** for 1 jobs on 1.5 VM hours",
+		"| `win-gpu` | 1 | 1.5 | 2.25 |",
+		"| `shader-slang/slang ci.yml` | 1 | 1.5 | 2.25 |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown missing %q:\n%s", want, md)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(r.CSV()), "\n")
+	if len(lines) != 5 || lines[0] != "start,end,kind,name,jobs,vm_hours,cost_usd" ||
+		lines[1] != "2026-03-03T00:00:00Z,2026-03-04T00:00:00Z,total,total,1,1.50,2.25" ||
+		lines[4] != "2026-03-03T00:00:00Z,2026-03-04T00:00:00Z,workflow,shader-slang/slang ci.yml,1,1.50,2.25" {
+		t.Fatalf("CSV =\n%s", r.CSV())
+	}
+
+	if text := r.Text(10); !strings.Contains(text, "$2.25 for 1 jobs") || !strings.Contains(text, "win-gpu") {
+		t.Fatalf("Text =\n%s", text)
+	}
+}
@@ -0,0 +1,156 @@
+package gcp
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+)
+
+// liveInstance is a live VM named with VMPrefix, as AdoptVMs sees it.
+type liveInstance struct {
+	name    string
+	created time.Time
+	// slots is the VM's runner-slots metadata; 1 without it.
+	slots int
+}
+
+// AdoptVMs tracks the live VMs named with VMPrefix that the manager does
+// not know, e.g. because the scaler crashed before it could save them (see
+// StateFile), so their jobs are not cut short and no VM is created for the
+// jobs they serve. A VM runs the runner it is named after; runners maps the
+// name of every registered runner to whether it is running a job, and a VM
+// whose runner is not registered is left alone. So is a VM running several
+// runners, since only the first one's name is known. It returns the number
+// of VMs adopted.
+func (m *Manager) AdoptVMs(ctx context.Context, runners map[string]bool) int {
+	adopted := 0
+	for _, zone := range strings.Split(m.zones(), ",") {
+		listCtx, cancel := context.WithTimeout(ctx, cleanupZoneScanTimeout)
+		instances, err := m.listLiveInstances(listCtx, zone)
+		cancel()
+		if err != nil {
+			slog.Warn("failed to list live VMs to adopt", "zone", zone, "error", err)
+			continue
+		}
+		for _, inst := range instances {
+			if m.isKnownVM(inst.name) {
+				continue
+			}
+			busy, registered := runners[inst.name]
+			switch {
+			case !registered:
+				slog.Warn("not adopting a VM whose runner is not registered", "vm", inst.name, "zone", zone)
+				continue
+			case inst.slots > 1:
+				slog.Warn("not adopting a VM running several runners", "vm", inst.name, "zone", zone, "slots", inst.slots)
+				continue
+			}
+			m.mu.Lock()
+			if _, ok := m.vms[inst.name]; !ok {
+				m.vms[inst.name] = &vmInfo{vmName: inst.name, zone: zone, busy: busy, createdAt: inst.created}
+				adopted++
+				slog.Info("adopted running VM", "event", "vm_adopted", "vm", inst.name, "zone", zone, "busy", busy)
+			}
+			m.mu.Unlock()
+		}
+	}
+	return adopted
+}
+
+// SyncBusy sets the busy flags of the tracked runners that runners lists,
+// which maps the name of every registered runner to whether it is running a
+// job, so the VMs of a previous run, restored from StateFile or adopted,
+// count as busy or idle from the start rather than from their next job
+// event. A runner that started a job since counts it. Runners not listed
+// are left alone. It returns the number of flags changed.
+func (m *Manager) SyncBusy(runners map[string]bool) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed := 0
+	for runnerName, vm := range m.vms {
+		busy, registered := runners[runnerName]
+		if !registered || vm.busy == busy {
+			continue
+		}
+		vm.busy = busy
+		if busy {
+			vm.jobs++
+		}
+		changed++
+		slog.Info("synced runner busy state from GitHub", "runner", runnerName, "vm", vm.vmName, "busy", busy)
+	}
+	return changed
+}
+
+// isKnownVM reports whether vmName is tracked, being created or deleted, or
+// in the stopped pool.
+func (m *Manager) isKnownVM(vmName string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.pendingCreates[vmName]; ok {
+		return true
+	}
+	if _, ok := m.deleting[vmName]; ok {
+		return true
+	}
+	if _, ok := m.stopped[vmName]; ok {
+		return true
+	}
+	for _, vm := range m.vms {
+		if vm.vmName == vmName {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) listLiveInstances(ctx context.Context, zone string) ([]liveInstance, error) {
+	if m.listLiveInstancesFunc != nil {
+		return m.listLiveInstancesFunc(ctx, zone)
+	}
+	var instances []liveInstance
+	err := m.retry(ctx, "List", func(ctx context.Context) error {
+		instances = nil
+		it := m.instancesClient.List(ctx, &computepb.ListInstancesRequest{
+			Project: m.config.Project,
+			Zone:    zone,
+			Filter:  proto.String(liveFilter(m.config.VMPrefix)),
+		})
+		for {
+			instance, err := it.Next()
+			if err == iterator.Done {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !isLiveStatus(instance.GetStatus()) {
+				continue
+			}
+			inst := liveInstance{name: instance.GetName(), slots: 1}
+			if created, err := time.Parse(time.RFC3339, instance.GetCreationTimestamp()); err == nil {
+				inst.created = created
+			} else {
+				inst.created = m.now()
+			}
+			for _, item := range instance.GetMetadata().GetItems() {
+				if item.GetKey() == "runner-slots" {
+					if n, err := strconv.Atoi(item.GetValue()); err == nil {
+						inst.slots = n
+					}
+				}
+			}
+			instances = append(instances, inst)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
@@ -0,0 +1,83 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAdoptVMsTracksRegisteredRunners(t *testing.T) {
+	created := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+	m := &Manager{
+		config:         ManagerConfig{Zones: "us-east1-c,us-east1-d,us-central1-a", VMPrefix: "win-test"},
+		vms:            map[string]*vmInfo{"win-test-known": {vmName: "win-test-known", zone: "us-east1-c"}},
+		pendingCreates: map[string]zoneCandidate{"win-test-new": {zone: "us-east1-d"}},
+	}
+	m.listLiveInstancesFunc = func(_ context.Context, zone string) ([]liveInstance, error) {
+		switch zone {
+		case "us-east1-c":
+			return []liveInstance{
+				{name: "win-test-known", created: created, slots: 1},
+				{name: "win-test-busy", created: created, slots: 1},
+				{name: "win-test-gone", created: created, slots: 1},
+			}, nil
+		case "us-east1-d":
+			return []liveInstance{
+				{name: "win-test-new", created: created, slots: 1},
+				{name: "win-test-idle", created: created, slots: 1},
+				{name: "win-test-slots", created: created, slots: 2},
+			}, nil
+		}
+		return nil, errors.New("list failed")
+	}
+	runners := map[string]bool{"win-test-busy": true, "win-test-idle": false, "win-test-slots": true, "win-test-known": true}
+
+	if n := m.AdoptVMs(context.Background(), runners); n != 2 {
+		t.Fatalf("AdoptVMs = %d, want 2", n)
+	}
+	if vm := m.vms["win-test-busy"]; vm == nil || !vm.busy || vm.zone != "us-east1-c" || !vm.createdAt.Equal(created) {
+		t.Fatalf("win-test-busy = %+v, want adopted busy in us-east1-c", vm)
+	}
+	if vm := m.vms["win-test-idle"]; vm == nil || vm.busy || vm.template != "" {
+		t.Fatalf("win-test-idle = %+v, want adopted idle", vm)
+	}
+	for _, name := range []string{"win-test-gone", "win-test-new", "win-test-slots"} {
+		if _, ok := m.vms[name]; ok {
+			t.Errorf("%s should not be adopted", name)
+		}
+	}
+	if m.vms["win-test-known"].busy {
+		t.Fatal("a tracked VM should be left as it is")
+	}
+	// A second pass finds nothing new.
+	if n := m.AdoptVMs(context.Background(), runners); n != 0 {
+		t.Fatalf("second AdoptVMs = %d, want 0", n)
+	}
+}
+
+func TestSyncBusySetsFlagsFromGitHub(t *testing.T) {
+	m := &Manager{vms: map[string]*vmInfo{
+		"win-test-started":  {vmName: "win-test-started", jobs: 1},
+		"win-test-finished": {vmName: "win-test-finished", busy: true, jobs: 1},
+		"win-test-same":     {vmName: "win-test-same", busy: true, jobs: 1},
+		"win-test-unlisted": {vmName: "win-test-unlisted", busy: true, jobs: 1},
+	}}
+	runners := map[string]bool{"win-test-started": true, "win-test-finished": false, "win-test-same": true, "win-test-other": true}
+
+	if n := m.SyncBusy(runners); n != 2 {
+		t.Fatalf("SyncBusy = %d, want 2", n)
+	}
+	if vm := m.vms["win-test-started"]; !vm.busy || vm.jobs != 2 {
+		t.Fatalf("win-test-started = %+v, want busy with its new job counted", vm)
+	}
+	if vm := m.vms["win-test-finished"]; vm.busy || vm.jobs != 1 {
+		t.Fatalf("win-test-finished = %+v, want idle", vm)
+	}
+	if !m.vms["win-test-same"].busy || !m.vms["win-test-unlisted"].busy {
+		t.Fatal("runners already in sync or not listed should be left alone")
+	}
+	if _, ok := m.vms["win-test-other"]; ok {
+		t.Fatal("SyncBusy should not track untracked runners")
+	}
+}
@@ -0,0 +1,147 @@
+package gcp
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"strings"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+)
+
+// aggregatedList lists the instances matching filter in every zone of the
+// project with one instances.aggregatedList call, so a cleanup pass costs
+// one List call however many zones are configured. It returns the instances
+// by zone, and the zones the API could not reach, whose instances are
+// missing from the result.
+func (m *Manager) aggregatedList(ctx context.Context, filter string) (map[string][]*computepb.Instance, map[string]bool, error) {
+	var pairs []compute.InstancesScopedListPair
+	err := m.retry(ctx, "AggregatedList", func(ctx context.Context) error {
+		pairs = nil
+		if m.aggregatedListFunc != nil {
+			var err error
+			pairs, err = m.aggregatedListFunc(ctx, filter)
+			return err
+		}
+		it := m.instancesClient.AggregatedList(ctx, &computepb.AggregatedListInstancesRequest{
+			Project:              m.config.Project,
+			Filter:               proto.String(filter),
+			ReturnPartialSuccess: proto.Bool(true),
+		})
+		for {
+			pair, err := it.Next()
+			if err == iterator.Done {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			pairs = append(pairs, pair)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	byZone := make(map[string][]*computepb.Instance)
+	unreachable := make(map[string]bool)
+	for _, pair := range pairs {
+		// Keys name the scope, e.g. "zones/us-east1-c".
+		if !strings.HasPrefix(pair.Key, "zones/") {
+			continue
+		}
+		zone := path.Base(pair.Key)
+		if pair.Value.GetWarning().GetCode() == computepb.Warning_UNREACHABLE.String() {
+			unreachable[zone] = true
+			continue
+		}
+		byZone[zone] = append(byZone[zone], pair.Value.GetInstances()...)
+	}
+	return byZone, unreachable, nil
+}
+
+// terminatedVMsByZone returns the names of the VMs the cleanup pass deletes,
+// by zone. It lists every zone, so VMs left in a zone that is no longer
+// configured are cleaned up too. Zones that could not be listed are logged
+// and left out; a zone listed partly keeps the names read.
+func (m *Manager) terminatedVMsByZone(ctx context.Context) map[string][]string {
+	byZone := make(map[string][]string)
+	if m.listTerminated != nil {
+		for _, zone := range splitZones(m.zones()) {
+			listCtx, cancel := context.WithTimeout(ctx, cleanupZoneScanTimeout)
+			names, err := m.listTerminated(listCtx, zone)
+			cancel()
+			if err != nil {
+				slog.Warn("failed to list instances for cleanup", "zone", zone, "error", err)
+			}
+			if len(names) > 0 {
+				byZone[zone] = names
+			}
+		}
+		return byZone
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, cleanupZoneScanTimeout)
+	instances, unreachable, err := m.aggregatedList(listCtx, m.terminatedFilter())
+	cancel()
+	if err != nil {
+		slog.Warn("failed to list instances for cleanup", "error", err)
+		return byZone
+	}
+	for zone := range unreachable {
+		slog.Warn("zone unreachable, skipping it in cleanup", "zone", zone)
+	}
+	for zone, list := range instances {
+		for _, instance := range list {
+			byZone[zone] = append(byZone[zone], instance.GetName())
+		}
+	}
+	return byZone
+}
+
+// liveVMsInZones returns the names of the live VMs in zones, and the zones
+// that could not be listed, whose tracked VMs must not be evicted.
+func (m *Manager) liveVMsInZones(ctx context.Context, zones map[string]struct{}) (map[string]bool, map[string]bool) {
+	live := make(map[string]bool)
+	failed := make(map[string]bool)
+	if m.listLive != nil {
+		for zone := range zones {
+			listCtx, cancel := context.WithTimeout(ctx, cleanupZoneScanTimeout)
+			names, err := m.listLive(listCtx, zone)
+			cancel()
+			if err != nil {
+				slog.Warn("reconcile: failed to list live VMs", "zone", zone, "error", err)
+				failed[zone] = true
+				continue
+			}
+			for _, name := range names {
+				live[name] = true
+			}
+		}
+		return live, failed
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, cleanupZoneScanTimeout)
+	instances, unreachable, err := m.aggregatedList(listCtx, liveFilter(m.config.VMPrefix))
+	cancel()
+	if err != nil {
+		slog.Warn("reconcile: failed to list live VMs", "error", err)
+	}
+	for zone := range zones {
+		if err != nil || unreachable[zone] {
+			if err == nil {
+				slog.Warn("reconcile: zone unreachable, keeping its VMs", "zone", zone)
+			}
+			failed[zone] = true
+			continue
+		}
+		for _, instance := range instances[zone] {
+			if isLiveStatus(instance.GetStatus()) {
+				live[instance.GetName()] = true
+			}
+		}
+	}
+	return live, failed
+}
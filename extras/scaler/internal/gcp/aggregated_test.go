@@ -0,0 +1,111 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"testing"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func scopedList(names ...string) *computepb.InstancesScopedList {
+	list := &computepb.InstancesScopedList{}
+	for _, name := range names {
+		list.Instances = append(list.Instances, &computepb.Instance{Name: proto.String(name), Status: proto.String("RUNNING")})
+	}
+	return list
+}
+
+func TestCleanupListsAllZonesInOneCall(t *testing.T) {
+	m := &Manager{
+		config: ManagerConfig{VMPrefix: "win-runner", Zones: "us-east1-c,us-east1-d,us-west1-a"},
+		vms: map[string]*vmInfo{
+			"runner-a": {vmName: "win-runner-a", zone: "us-east1-c"},
+		},
+	}
+	var filters []string
+	m.aggregatedListFunc = func(_ context.Context, filter string) ([]compute.InstancesScopedListPair, error) {
+		filters = append(filters, filter)
+		if filter == liveFilter("win-runner") {
+			return nil, nil
+		}
+		return []compute.InstancesScopedListPair{
+			{Key: "zones/us-east1-c", Value: scopedList("win-runner-a")},
+			{Key: "zones/us-east1-d", Value: &computepb.InstancesScopedList{}},
+			// A zone no longer configured is cleaned up too.
+			{Key: "zones/europe-west4-a", Value: scopedList("win-runner-old")},
+			{Key: "regions/us-east1", Value: scopedList("win-runner-regional")},
+		}, nil
+	}
+	var deleted []string
+	m.deleteVMFunc = func(_ context.Context, vmName, zone string) error {
+		deleted = append(deleted, vmName+"@"+zone)
+		return nil
+	}
+
+	m.doCleanupTerminatedVMs(context.Background())
+
+	if want := []string{cleanupFilter("win-runner")}; !slices.Equal(filters, want) {
+		t.Fatalf("aggregated list filters = %v, want %v and no reconcile list once nothing is tracked", filters, want)
+	}
+	sort.Strings(deleted)
+	if want := []string{"win-runner-a@us-east1-c", "win-runner-old@europe-west4-a"}; !slices.Equal(deleted, want) {
+		t.Fatalf("deleted = %v, want %v", deleted, want)
+	}
+	if len(m.vms) != 0 {
+		t.Fatalf("tracked VMs = %v, want the deleted VM dropped", m.vms)
+	}
+}
+
+func TestReconcileWithAggregatedList(t *testing.T) {
+	m := &Manager{
+		config: ManagerConfig{VMPrefix: "linux-test"},
+		vms: map[string]*vmInfo{
+			"runner-a": {vmName: "linux-test-a", zone: "us-east1-c"},
+			"runner-b": {vmName: "linux-test-b", zone: "us-east1-c"},
+			"runner-c": {vmName: "linux-test-c", zone: "us-west1-a"},
+		},
+	}
+	calls := 0
+	m.aggregatedListFunc = func(_ context.Context, filter string) ([]compute.InstancesScopedListPair, error) {
+		calls++
+		if filter != liveFilter("linux-test") {
+			t.Fatalf("filter = %q, want the live filter", filter)
+		}
+		return []compute.InstancesScopedListPair{
+			{Key: "zones/us-east1-c", Value: scopedList("linux-test-a")},
+			{Key: "zones/us-west1-a", Value: &computepb.InstancesScopedList{
+				Warning: &computepb.Warning{Code: proto.String(computepb.Warning_UNREACHABLE.String())},
+			}},
+		}, nil
+	}
+
+	m.reconcileTrackedVMs(context.Background())
+
+	if calls != 1 {
+		t.Fatalf("aggregated list calls = %d, want 1", calls)
+	}
+	if _, ok := m.vms["runner-a"]; !ok {
+		t.Fatal("runner-a should remain while its VM is live")
+	}
+	if _, ok := m.vms["runner-b"]; ok {
+		t.Fatal("runner-b should be removed once its VM is gone")
+	}
+	if _, ok := m.vms["runner-c"]; !ok {
+		t.Fatal("runner-c should remain while its zone is unreachable")
+	}
+
+	// A failed list evicts nothing.
+	m.aggregatedListFunc = func(context.Context, string) ([]compute.InstancesScopedListPair, error) {
+		return nil, errors.New("list failed")
+	}
+	m.vms["runner-b"] = &vmInfo{vmName: "linux-test-b", zone: "us-east1-c"}
+	m.reconcileTrackedVMs(context.Background())
+	if len(m.vms) != 3 {
+		t.Fatalf("tracked VMs = %v, want all kept when the list fails", m.vms)
+	}
+}
@@ -0,0 +1,376 @@
+package gcp
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/protobuf/proto"
+)
+
+// bakeGuestAttributeNamespace is where the bake scripts report the
+// provisioning result: bake/status is "running", "done", or "failed: " and
+// the reason.
+const bakeGuestAttributeNamespace = "bake"
+
+const (
+	defaultBakeMachineType = "n1-standard-8"
+	defaultBakeDiskSizeGB  = 100
+	defaultBakeTimeout     = 2 * time.Hour
+	bakePollInterval       = 30 * time.Second
+)
+
+//go:embed bake.ps1
+var windowsBakeScript string
+
+//go:embed bake.sh
+var linuxBakeScript string
+
+// BakeConfig describes an image bake (see Bake).
+type BakeConfig struct {
+	Project  string
+	Zone     string
+	Platform string // "windows" or "linux"
+	// BaseImage is the image, or image family, the builder VM boots from,
+	// e.g. "projects/windows-cloud/global/images/family/windows-2022".
+	BaseImage string
+	// MachineType defaults to n1-standard-8. GPUType attaches one GPU of
+	// that type, so drivers can be installed and tested; "none" or empty
+	// bakes without one.
+	MachineType string
+	GPUType     string
+	DiskSizeGB  int64
+	Network     string
+	Subnetwork  string
+	// ServiceAccount (email) is the builder's identity, e.g. for reading
+	// installers from Cloud Storage. Empty uses the project's default.
+	ServiceAccount string
+	// Script provisions the builder: installs drivers, build tools and
+	// SDKs. It runs as root (SYSTEM on Windows) through the platform's
+	// bake wrapper; a non-zero exit fails the bake.
+	Script string
+	// ImageFamily (a name in Project) is the family the new image joins;
+	// ImageName defaults to the family and the bake's UTC timestamp.
+	ImageFamily string
+	ImageName   string
+	Labels      map[string]string
+	// Timeout bounds provisioning; 2h when zero.
+	Timeout time.Duration
+	// KeepBuilder keeps the builder VM after a failed bake, for
+	// debugging. It is deleted after a successful one either way.
+	KeepBuilder bool
+	// ImpersonateServiceAccount, when set, is the service account whose
+	// credentials the bake's API calls use (see
+	// ManagerConfig.ImpersonateServiceAccount).
+	ImpersonateServiceAccount string
+}
+
+// baker runs a bake against Compute Engine. The func fields replace the
+// API calls in tests.
+type baker struct {
+	config          BakeConfig
+	instancesClient *compute.InstancesClient
+	imagesClient    *compute.ImagesClient
+	pollInterval    time.Duration
+	now             func() time.Time
+
+	insertFunc          func(context.Context, *computepb.Instance) error
+	guestAttributesFunc func(ctx context.Context, vmName string) (map[string]string, error)
+	statusFunc          func(ctx context.Context, vmName string) (string, error)
+	insertImageFunc     func(context.Context, *computepb.Image) error
+	deleteFunc          func(ctx context.Context, vmName string) error
+}
+
+// Bake boots a builder VM from BaseImage, runs Script on it, and creates an
+// image in ImageFamily from its boot disk once the script succeeded and the
+// VM shut down. It returns the new image's name. The builder VM is deleted
+// unless the bake failed with KeepBuilder set.
+func Bake(ctx context.Context, cfg BakeConfig) (string, error) {
+	opts, err := clientOptions(ctx, cfg.ImpersonateServiceAccount)
+	if err != nil {
+		return "", err
+	}
+	instancesClient, err := compute.NewInstancesRESTClient(ctx, opts...)
+	if err != nil {
+		return "", fmt.Errorf("creating instances client: %w", err)
+	}
+	defer instancesClient.Close()
+	imagesClient, err := compute.NewImagesRESTClient(ctx, opts...)
+	if err != nil {
+		return "", fmt.Errorf("creating images client: %w", err)
+	}
+	defer imagesClient.Close()
+
+	b := &baker{
+		config:          cfg,
+		instancesClient: instancesClient,
+		imagesClient:    imagesClient,
+		pollInterval:    bakePollInterval,
+		now:             time.Now,
+	}
+	return b.bake(ctx)
+}
+
+func (b *baker) bake(ctx context.Context) (_ string, err error) {
+	cfg := &b.config
+	if cfg.ImageName == "" {
+		cfg.ImageName = fmt.Sprintf("%s-%s", cfg.ImageFamily, b.now().UTC().Format("20060102-150405"))
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultBakeTimeout
+	}
+	// The builder shares the image's name; instances and images are
+	// separate namespaces.
+	vmName := cfg.ImageName
+
+	slog.Info("creating builder VM", "vm", vmName, "zone", cfg.Zone, "base_image", cfg.BaseImage)
+	if err := b.insert(ctx, b.builder(vmName)); err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil && cfg.KeepBuilder {
+			slog.Warn("bake failed, keeping the builder VM", "vm", vmName, "zone", cfg.Zone, "error", err)
+			return
+		}
+		// The caller's context may be what ended the bake.
+		deleteCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupDeleteTimeout)
+		defer cancel()
+		if derr := b.delete(deleteCtx, vmName); derr != nil {
+			slog.Warn("failed to delete builder VM", "vm", vmName, "zone", cfg.Zone, "error", derr)
+		}
+	}()
+
+	if err := b.waitForProvisioning(ctx, vmName); err != nil {
+		return "", err
+	}
+	slog.Info("provisioning done, creating image", "vm", vmName, "image", cfg.ImageName, "family", cfg.ImageFamily)
+	if err := b.insertImage(ctx, &computepb.Image{
+		Name:        proto.String(cfg.ImageName),
+		Family:      proto.String(cfg.ImageFamily),
+		SourceDisk:  proto.String(fmt.Sprintf("projects/%s/zones/%s/disks/%s", cfg.Project, cfg.Zone, vmName)),
+		Description: proto.String("Baked by scaler bake from " + cfg.BaseImage),
+		Labels:      cfg.Labels,
+	}); err != nil {
+		return "", err
+	}
+	return cfg.ImageName, nil
+}
+
+// waitForProvisioning polls the builder until its script reported a result
+// and, after success, the VM shut down so its disk can be imaged.
+func (b *baker) waitForProvisioning(ctx context.Context, vmName string) error {
+	ctx, cancel := context.WithTimeout(ctx, b.config.Timeout)
+	defer cancel()
+	ticker := time.NewTicker(b.pollInterval)
+	defer ticker.Stop()
+	done := false
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("builder VM %s did not finish provisioning within %s", vmName, b.config.Timeout)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		status, err := b.status(ctx, vmName)
+		if err != nil {
+			slog.Debug("failed to read builder VM status", "vm", vmName, "error", err)
+			continue
+		}
+		if !done {
+			attrs, err := b.guestAttributes(ctx, vmName)
+			if err != nil {
+				slog.Debug("failed to read bake status", "vm", vmName, "error", err)
+				continue
+			}
+			result := attrs["status"]
+			if reason, ok := strings.CutPrefix(result, "failed: "); ok {
+				return fmt.Errorf("provisioning script failed on %s: %s", vmName, reason)
+			}
+			done = result == "done"
+			if done {
+				slog.Info("provisioning script finished, waiting for the builder to shut down", "vm", vmName)
+			}
+		}
+		if status == computepb.Instance_TERMINATED.String() {
+			if !done {
+				return fmt.Errorf("builder VM %s shut down before its provisioning script finished", vmName)
+			}
+			return nil
+		}
+	}
+}
+
+// builder returns the builder VM: BaseImage on its boot disk, the
+// platform's bake wrapper as its startup script and Script as the
+// bake-script metadata the wrapper runs.
+func (b *baker) builder(vmName string) *computepb.Instance {
+	cfg := b.config
+	machineType := cfg.MachineType
+	if machineType == "" {
+		machineType = defaultBakeMachineType
+	}
+	diskSizeGB := cfg.DiskSizeGB
+	if diskSizeGB <= 0 {
+		diskSizeGB = defaultBakeDiskSizeGB
+	}
+	scriptKey, wrapper := "windows-startup-script-ps1", windowsBakeScript
+	if cfg.Platform == "linux" {
+		scriptKey, wrapper = "startup-script", linuxBakeScript
+	}
+	network := cfg.Network
+	if network == "" {
+		network = "global/networks/default"
+	}
+
+	vm := &computepb.Instance{
+		Name:        proto.String(vmName),
+		MachineType: proto.String(fmt.Sprintf("zones/%s/machineTypes/%s", cfg.Zone, machineType)),
+		Disks: []*computepb.AttachedDisk{{
+			Boot:       proto.Bool(true),
+			AutoDelete: proto.Bool(true),
+			DeviceName: proto.String(vmName),
+			InitializeParams: &computepb.AttachedDiskInitializeParams{
+				SourceImage: proto.String(cfg.BaseImage),
+				DiskSizeGb:  proto.Int64(diskSizeGB),
+				DiskType:    proto.String(fmt.Sprintf("zones/%s/diskTypes/pd-balanced", cfg.Zone)),
+			},
+		}},
+		NetworkInterfaces: []*computepb.NetworkInterface{{
+			Network: proto.String(network),
+			// Installers are downloaded from the internet.
+			AccessConfigs: []*computepb.AccessConfig{{
+				Name: proto.String("External NAT"),
+				Type: proto.String(computepb.AccessConfig_ONE_TO_ONE_NAT.String()),
+			}},
+		}},
+		Metadata: &computepb.Metadata{Items: []*computepb.Items{
+			{Key: proto.String(scriptKey), Value: proto.String(wrapper)},
+			{Key: proto.String("bake-script"), Value: proto.String(cfg.Script)},
+			{Key: proto.String("enable-guest-attributes"), Value: proto.String("TRUE")},
+		}},
+		Labels: cfg.Labels,
+	}
+	if cfg.Subnetwork != "" {
+		vm.NetworkInterfaces[0].Subnetwork = proto.String(cfg.Subnetwork)
+	}
+	if cfg.ServiceAccount != "" {
+		vm.ServiceAccounts = []*computepb.ServiceAccount{{
+			Email:  proto.String(cfg.ServiceAccount),
+			Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"},
+		}}
+	}
+	if cfg.GPUType != "" && cfg.GPUType != "none" {
+		vm.GuestAccelerators = []*computepb.AcceleratorConfig{{
+			AcceleratorType:  proto.String(fmt.Sprintf("zones/%s/acceleratorTypes/%s", cfg.Zone, cfg.GPUType)),
+			AcceleratorCount: proto.Int32(1),
+		}}
+		// GPU VMs cannot live-migrate.
+		vm.Scheduling = &computepb.Scheduling{
+			OnHostMaintenance: proto.String(computepb.Scheduling_TERMINATE.String()),
+		}
+	}
+	return vm
+}
+
+func (b *baker) insert(ctx context.Context, vm *computepb.Instance) error {
+	if b.insertFunc != nil {
+		return b.insertFunc(ctx, vm)
+	}
+	op, err := b.instancesClient.Insert(ctx, &computepb.InsertInstanceRequest{
+		Project:          b.config.Project,
+		Zone:             b.config.Zone,
+		InstanceResource: vm,
+	})
+	if err != nil {
+		return fmt.Errorf("creating builder VM %s: %w", vm.GetName(), err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("waiting for builder VM %s: %w", vm.GetName(), err)
+	}
+	return nil
+}
+
+func (b *baker) status(ctx context.Context, vmName string) (string, error) {
+	if b.statusFunc != nil {
+		return b.statusFunc(ctx, vmName)
+	}
+	vm, err := b.instancesClient.Get(ctx, &computepb.GetInstanceRequest{
+		Project:  b.config.Project,
+		Zone:     b.config.Zone,
+		Instance: vmName,
+	})
+	if err != nil {
+		return "", fmt.Errorf("reading builder VM %s: %w", vmName, err)
+	}
+	return vm.GetStatus(), nil
+}
+
+func (b *baker) guestAttributes(ctx context.Context, vmName string) (map[string]string, error) {
+	if b.guestAttributesFunc != nil {
+		return b.guestAttributesFunc(ctx, vmName)
+	}
+	ga, err := b.instancesClient.GetGuestAttributes(ctx, &computepb.GetGuestAttributesInstanceRequest{
+		Project:   b.config.Project,
+		Zone:      b.config.Zone,
+		Instance:  vmName,
+		QueryPath: proto.String(bakeGuestAttributeNamespace + "/"),
+	})
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading guest attributes of %s: %w", vmName, err)
+	}
+	attrs := make(map[string]string)
+	for _, item := range ga.GetQueryValue().GetItems() {
+		attrs[item.GetKey()] = item.GetValue()
+	}
+	return attrs, nil
+}
+
+func (b *baker) insertImage(ctx context.Context, image *computepb.Image) error {
+	if b.insertImageFunc != nil {
+		return b.insertImageFunc(ctx, image)
+	}
+	op, err := b.imagesClient.Insert(ctx, &computepb.InsertImageRequest{
+		Project:       b.config.Project,
+		ImageResource: image,
+	})
+	if err != nil {
+		return fmt.Errorf("creating image %s: %w", image.GetName(), err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("waiting for image %s: %w", image.GetName(), err)
+	}
+	return nil
+}
+
+func (b *baker) delete(ctx context.Context, vmName string) error {
+	if b.deleteFunc != nil {
+		return b.deleteFunc(ctx, vmName)
+	}
+	op, err := b.instancesClient.Delete(ctx, &computepb.DeleteInstanceRequest{
+		Project:  b.config.Project,
+		Zone:     b.config.Zone,
+		Instance: vmName,
+	})
+	if err != nil {
+		return fmt.Errorf("deleting builder VM %s: %w", vmName, err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("waiting for builder VM deletion %s: %w", vmName, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+# Windows Image Bake Script
+#
+# Startup script of the builder VM `scaler bake` creates. It:
+# 1. Runs the provisioning script from the bake-script metadata as SYSTEM
+# 2. Reboots and runs it again while it exits with 3010 (reboot required),
+#    e.g. after a driver install
+# 3. Reports the result in the bake/status guest attribute
+# 4. On success, generalizes the VM with GCESysprep, which shuts it down so
+#    the scaler can image the boot disk
+#
+# The script runs on every boot, so the provisioning script must be
+# idempotent. Once a result is reported, later boots do nothing.
+
+$ErrorActionPreference = "Stop"
+
+$bakeDir = "C:\scaler-bake"
+$logFile = "$bakeDir\bake.log"
+$doneMarker = "$bakeDir\done"
+$metadata = "http://metadata.google.internal/computeMetadata/v1/instance"
+
+New-Item -ItemType Directory -Path $bakeDir -Force | Out-Null
+
+function Write-Log {
+    param([string]$Message)
+    $line = "$(Get-Date -Format 'yyyy-MM-dd HH:mm:ss') - $Message"
+    Write-Host $line
+    Add-Content -Path $logFile -Value $line
+}
+
+function Set-BakeStatus {
+    param([string]$Value)
+    try {
+        Invoke-RestMethod -Method Put -Uri "$metadata/guest-attributes/bake/status" -Headers @{ "Metadata-Flavor" = "Google" } -Body $Value -TimeoutSec 10 | Out-Null
+    }
+    catch {
+        Write-Log "WARNING: Failed to report bake status: $_"
+    }
+}
+
+if (Test-Path $doneMarker) {
+    Write-Log "Bake already finished; nothing to do"
+    exit 0
+}
+
+Set-BakeStatus "running"
+Write-Log "Reading provisioning script from metadata"
+try {
+    $script = Invoke-RestMethod -Uri "$metadata/attributes/bake-script" -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 30
+}
+catch {
+    Set-BakeStatus "failed: cannot read bake-script metadata"
+    exit 1
+}
+$scriptPath = "$bakeDir\provision.ps1"
+Set-Content -Path $scriptPath -Value $script
+
+Write-Log "Running provisioning script"
+& powershell.exe -NoProfile -ExecutionPolicy Bypass -File $scriptPath *>> $logFile
+$exitCode = $LASTEXITCODE
+if ($exitCode -eq 3010) {
+    Write-Log "Provisioning script requested a reboot; it runs again after it"
+    Restart-Computer -Force
+    exit 0
+}
+if ($exitCode -ne 0) {
+    Write-Log "ERROR: Provisioning script exited with $exitCode"
+    Set-BakeStatus "failed: provisioning script exited with $exitCode"
+    New-Item -ItemType File -Path $doneMarker -Force | Out-Null
+    exit 1
+}
+
+Write-Log "Provisioning done, generalizing the VM"
+Remove-Item -Path $scriptPath -Force
+Set-BakeStatus "done"
+# Leave the scaler time to read the result before the VM stops.
+Start-Sleep -Seconds 90
+# GCESysprep clears per-VM state and shuts the VM down. The image's VMs run
+# their own startup scripts on first boot; the bake directory goes with it.
+Remove-Item -Path $bakeDir -Recurse -Force -ErrorAction SilentlyContinue
+GCESysprep
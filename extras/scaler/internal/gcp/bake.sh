@@ -0,0 +1,65 @@
+#!/bin/bash
+# Linux Image Bake Script
+#
+# Startup script of the builder VM `scaler bake` creates. It:
+# 1. Runs the provisioning script from the bake-script metadata as root
+# 2. Reports the result in the bake/status guest attribute
+# 3. On success, clears per-VM state and shuts down so the scaler can image
+#    the boot disk
+#
+# The script runs on every boot, so a provisioning script that reboots
+# (e.g. after a driver install) runs again and must be idempotent. Once a
+# result is reported, later boots do nothing.
+
+set -euo pipefail
+
+METADATA="http://metadata.google.internal/computeMetadata/v1/instance"
+LOG=/var/log/scaler-bake.log
+DONE_MARKER=/var/lib/scaler-bake.done
+
+log() {
+  echo "$(date '+%Y-%m-%d %H:%M:%S') - $*" | tee -a "$LOG"
+}
+
+report_status() {
+  curl -sf -X PUT --data "$1" -H "Metadata-Flavor: Google" \
+    "$METADATA/guest-attributes/bake/status" >/dev/null ||
+    log "WARNING: Failed to report bake status"
+}
+
+if [ -f "$DONE_MARKER" ]; then
+  log "Bake already finished; nothing to do"
+  exit 0
+fi
+
+report_status "running"
+log "Reading provisioning script from metadata"
+SCRIPT=$(mktemp /tmp/bake-script.XXXXXX)
+if ! curl -sf -H "Metadata-Flavor: Google" "$METADATA/attributes/bake-script" -o "$SCRIPT"; then
+  report_status "failed: cannot read bake-script metadata"
+  exit 1
+fi
+chmod +x "$SCRIPT"
+
+log "Running provisioning script"
+set +e
+bash "$SCRIPT" 2>&1 | tee -a "$LOG"
+status=${PIPESTATUS[0]}
+set -e
+rm -f "$SCRIPT"
+if [ "$status" -ne 0 ]; then
+  log "ERROR: Provisioning script exited with $status"
+  report_status "failed: provisioning script exited with $status"
+  touch "$DONE_MARKER"
+  exit 1
+fi
+
+log "Provisioning done, cleaning up per-VM state"
+# VMs booted from the image get their own machine ID and SSH host keys.
+truncate -s 0 /etc/machine-id
+rm -f /var/lib/dbus/machine-id /etc/ssh/ssh_host_*
+cloud-init clean --logs 2>/dev/null || true
+report_status "done"
+# Leave the scaler time to read the result before the VM stops.
+sleep 90
+shutdown -h now
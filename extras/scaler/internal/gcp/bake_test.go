@@ -0,0 +1,133 @@
+package gcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// newTestBaker returns a baker whose builder reports the given bake
+// statuses, then shuts down.
+func newTestBaker(cfg BakeConfig, statuses ...string) (*baker, *[]string) {
+	var calls []string
+	polls := 0
+	b := &baker{
+		config:       cfg,
+		pollInterval: time.Millisecond,
+		now:          func() time.Time { return time.Date(2026, 10, 16, 8, 30, 0, 0, time.UTC) },
+		insertFunc: func(_ context.Context, vm *computepb.Instance) error {
+			calls = append(calls, "insert "+vm.GetName())
+			return nil
+		},
+		statusFunc: func(context.Context, string) (string, error) {
+			polls++
+			if polls > len(statuses) {
+				return computepb.Instance_TERMINATED.String(), nil
+			}
+			return computepb.Instance_RUNNING.String(), nil
+		},
+		guestAttributesFunc: func(context.Context, string) (map[string]string, error) {
+			if polls > len(statuses) {
+				return map[string]string{}, nil
+			}
+			return map[string]string{"status": statuses[polls-1]}, nil
+		},
+		insertImageFunc: func(_ context.Context, image *computepb.Image) error {
+			calls = append(calls, "image "+image.GetName()+" family "+image.GetFamily()+" from "+image.GetSourceDisk())
+			return nil
+		},
+		deleteFunc: func(_ context.Context, vmName string) error {
+			calls = append(calls, "delete "+vmName)
+			return nil
+		},
+	}
+	return b, &calls
+}
+
+func TestBakeCreatesImageAfterProvisioning(t *testing.T) {
+	b, calls := newTestBaker(BakeConfig{
+		Project:     "slang-runners",
+		Zone:        "us-east1-c",
+		ImageFamily: "windows-gpu-runner",
+	}, "running", "running", "done")
+
+	name, err := b.bake(context.Background())
+	if err != nil {
+		t.Fatalf("bake: %v", err)
+	}
+	if name != "windows-gpu-runner-20261016-083000" {
+		t.Fatalf("image name = %q", name)
+	}
+	want := []string{
+		"insert windows-gpu-runner-20261016-083000",
+		"image windows-gpu-runner-20261016-083000 family windows-gpu-runner from projects/slang-runners/zones/us-east1-c/disks/windows-gpu-runner-20261016-083000",
+		"delete windows-gpu-runner-20261016-083000",
+	}
+	if strings.Join(*calls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("calls = %q, want %q", *calls, want)
+	}
+}
+
+func TestBakeFailsOnScriptFailure(t *testing.T) {
+	b, calls := newTestBaker(BakeConfig{ImageFamily: "linux-gpu-runner", ImageName: "linux-gpu-runner-v7"},
+		"running", "failed: provisioning script exited with 2")
+	_, err := b.bake(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "exited with 2") {
+		t.Fatalf("bake error = %v, want the script's failure", err)
+	}
+	if got := (*calls)[len(*calls)-1]; got != "delete linux-gpu-runner-v7" {
+		t.Fatalf("last call = %q, want the builder deleted", got)
+	}
+
+	b, calls = newTestBaker(BakeConfig{ImageFamily: "linux-gpu-runner", ImageName: "linux-gpu-runner-v7", KeepBuilder: true},
+		"failed: cannot read bake-script metadata")
+	if _, err := b.bake(context.Background()); err == nil {
+		t.Fatal("bake should fail")
+	}
+	if len(*calls) != 1 {
+		t.Fatalf("calls = %q, want the builder kept", *calls)
+	}
+}
+
+func TestBakeFailsWhenBuilderStopsEarly(t *testing.T) {
+	// The builder shuts down without ever reporting a result.
+	b, _ := newTestBaker(BakeConfig{ImageFamily: "linux-gpu-runner"})
+	if _, err := b.bake(context.Background()); err == nil || !strings.Contains(err.Error(), "shut down before") {
+		t.Fatalf("bake error = %v", err)
+	}
+}
+
+func TestBakeBuilder(t *testing.T) {
+	b := &baker{config: BakeConfig{
+		Zone:      "us-east1-c",
+		Platform:  "linux",
+		BaseImage: "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+		GPUType:   "nvidia-tesla-t4",
+		Script:    "apt-get install -y vulkan-tools",
+	}}
+	vm := b.builder("linux-gpu-runner-v7")
+	if got := vm.GetMachineType(); got != "zones/us-east1-c/machineTypes/n1-standard-8" {
+		t.Fatalf("machine type = %q", got)
+	}
+	if got := vm.GetDisks()[0].GetInitializeParams().GetSourceImage(); got != b.config.BaseImage {
+		t.Fatalf("source image = %q", got)
+	}
+	if len(vm.GetGuestAccelerators()) != 1 || vm.GetScheduling().GetOnHostMaintenance() != "TERMINATE" {
+		t.Fatalf("accelerators = %v, scheduling = %v", vm.GetGuestAccelerators(), vm.GetScheduling())
+	}
+	metadata := map[string]string{}
+	for _, item := range vm.GetMetadata().GetItems() {
+		metadata[item.GetKey()] = item.GetValue()
+	}
+	if metadata["startup-script"] != linuxBakeScript || metadata["bake-script"] != b.config.Script || metadata["enable-guest-attributes"] != "TRUE" {
+		t.Fatalf("metadata = %v", metadata)
+	}
+
+	b.config.GPUType = "none"
+	if vm := b.builder("build-v7"); vm.GetGuestAccelerators() != nil || vm.GetScheduling() != nil {
+		t.Fatal("a bake without a GPU should attach none")
+	}
+}
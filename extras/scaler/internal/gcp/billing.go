@@ -0,0 +1,120 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// The billing labels stamped on VMs with BillingLabels. GCP copies a VM's
+// labels into each usage row of the Cloud Billing export, so spend can be
+// broken down by pool and by the job a VM ran.
+const (
+	LabelPool     = "scaler-pool"
+	LabelRepo     = "scaler-repo"
+	LabelWorkflow = "scaler-workflow"
+	LabelRunID    = "scaler-run-id"
+)
+
+// JobLabels describes the job a VM runs, for its billing labels.
+type JobLabels struct {
+	Repository string // "<owner>/<repo>"
+	Workflow   string // the workflow file, e.g. "ci.yml"
+	RunID      int64
+}
+
+// LabelValue turns s into a valid label value: lowercase, with each
+// character a label cannot hold replaced by "_", and cut to 63 characters.
+func LabelValue(s string) string {
+	b := []byte(strings.ToLower(s))
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 63 {
+		b = b[:63]
+	}
+	return string(b)
+}
+
+// instanceLabels returns the labels of a new VM: Labels, plus the pool's
+// billing label with BillingLabels.
+func (m *Manager) instanceLabels() map[string]string {
+	if !m.config.BillingLabels {
+		return m.config.Labels
+	}
+	labels := maps.Clone(m.config.Labels)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[LabelPool] = LabelValue(m.config.VMPrefix)
+	return labels
+}
+
+// SetJobLabels stamps the VM of runnerName with the billing labels of the
+// job it started, keeping its other labels. A reused VM's job labels are
+// replaced by each job's, and a VM running several runners carries those
+// of the last job to start. It does nothing without BillingLabels.
+func (m *Manager) SetJobLabels(ctx context.Context, runnerName string, job JobLabels) error {
+	if !m.config.BillingLabels {
+		return nil
+	}
+	m.mu.Lock()
+	vm, ok := m.vms[runnerName]
+	var vmName, zone string
+	if ok {
+		vmName, zone = vm.vmName, vm.zone
+	}
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("runner %q is not tracked", runnerName)
+	}
+	return m.setLabels(ctx, vmName, zone, map[string]string{
+		LabelPool:     LabelValue(m.config.VMPrefix),
+		LabelRepo:     LabelValue(job.Repository),
+		LabelWorkflow: LabelValue(job.Workflow),
+		LabelRunID:    strconv.FormatInt(job.RunID, 10),
+	})
+}
+
+// setLabels sets labels on the instance, keeping its other labels.
+func (m *Manager) setLabels(ctx context.Context, vmName, zone string, labels map[string]string) (err error) {
+	if m.setLabelsFunc != nil {
+		return m.setLabelsFunc(ctx, vmName, zone, labels)
+	}
+	ctx, span := tracer.Start(ctx, "gcp.SetLabels")
+	defer func() { endSpan(span, err) }()
+
+	inst, err := m.getInstance(ctx, vmName, zone)
+	if err != nil {
+		return fmt.Errorf("reading instance %s: %w", vmName, err)
+	}
+	merged := maps.Clone(inst.GetLabels())
+	if merged == nil {
+		merged = make(map[string]string)
+	}
+	maps.Copy(merged, labels)
+	// The fingerprint makes SetLabels fail on a concurrent change instead
+	// of dropping it.
+	op, err := m.instancesClient.SetLabels(ctx, &computepb.SetLabelsInstanceRequest{
+		Project:  m.config.Project,
+		Zone:     zone,
+		Instance: vmName,
+		InstancesSetLabelsRequestResource: &computepb.InstancesSetLabelsRequest{
+			Labels:           merged,
+			LabelFingerprint: inst.LabelFingerprint,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("setting labels of %s: %w", vmName, err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("waiting for label update of %s: %w", vmName, err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package gcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLabelValue(t *testing.T) {
+	for in, want := range map[string]string{
+		"shader-slang/slang": "shader-slang_slang",
+		"CI Build.yml":       "ci_build_yml",
+		"win-gpu":            "win-gpu",
+	} {
+		if got := LabelValue(in); got != want {
+			t.Errorf("LabelValue(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := LabelValue(strings.Repeat("a", 100)); len(got) != 63 {
+		t.Errorf("LabelValue of 100 characters has %d, want 63", len(got))
+	}
+}
+
+func TestSetJobLabels(t *testing.T) {
+	m := &Manager{
+		config: ManagerConfig{VMPrefix: "win-gpu", Labels: map[string]string{"team": "infra"}, BillingLabels: true},
+		vms:    map[string]*vmInfo{"win-gpu-1": {vmName: "win-gpu-1", zone: "us-east1-c"}},
+	}
+	if labels := m.instanceLabels(); labels["team"] != "infra" || labels[LabelPool] != "win-gpu" {
+		t.Fatalf("instanceLabels = %v", labels)
+	}
+	if _, ok := m.config.Labels[LabelPool]; ok {
+		t.Fatal("instanceLabels should not change the configured labels")
+	}
+
+	var gotVM, gotZone string
+	var got map[string]string
+	m.setLabelsFunc = func(_ context.Context, vmName, zone string, labels map[string]string) error {
+		gotVM, gotZone, got = vmName, zone, labels
+		return nil
+	}
+	err := m.SetJobLabels(context.Background(), "win-gpu-1", JobLabels{Repository: "shader-slang/slang", Workflow: "ci.yml", RunID: 4242})
+	if err != nil {
+		t.Fatalf("SetJobLabels: %v", err)
+	}
+	if gotVM != "win-gpu-1" || gotZone != "us-east1-c" {
+		t.Fatalf("labelled %s in %s", gotVM, gotZone)
+	}
+	want := map[string]string{LabelPool: "win-gpu", LabelRepo: "shader-slang_slang", LabelWorkflow: "ci_yml", LabelRunID: "4242"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("label %s = %q, want %q", k, got[k], v)
+		}
+	}
+	if err := m.SetJobLabels(context.Background(), "win-gpu-2", JobLabels{}); err == nil {
+		t.Fatal("SetJobLabels of an untracked runner should fail")
+	}
+
+	m.config.BillingLabels = false
+	got = nil
+	if err := m.SetJobLabels(context.Background(), "win-gpu-1", JobLabels{}); err != nil || got != nil {
+		t.Fatalf("SetJobLabels without BillingLabels = %v, labels %v; want a no-op", err, got)
+	}
+	if labels := m.instanceLabels(); labels[LabelPool] != "" {
+		t.Fatalf("instanceLabels without BillingLabels = %v", labels)
+	}
+}
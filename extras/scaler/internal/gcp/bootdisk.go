@@ -0,0 +1,61 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// overridesBootDisk reports whether BootDiskSizeGB, BootDiskType or
+// ImageFamily is set.
+func (m *Manager) overridesBootDisk() bool {
+	return m.config.BootDiskSizeGB > 0 || m.config.BootDiskType != "" || m.config.ImageFamily != ""
+}
+
+// bootDisks returns the disk list for a VM created from template in zone with
+// the configured boot disk size/type applied, booting from image (a
+// self-link) when set. An insert that sets any disk
+// replaces the template's whole disk list, so the template's disks are
+// copied and only the boot disk's initialize params are changed.
+func (m *Manager) bootDisks(ctx context.Context, template, zone, image string) ([]*computepb.AttachedDisk, error) {
+	props, err := m.templateProperties(ctx, template)
+	if err != nil {
+		return nil, err
+	}
+	templateDisks := props.GetDisks()
+
+	disks := make([]*computepb.AttachedDisk, 0, len(templateDisks))
+	foundBoot := false
+	for _, d := range templateDisks {
+		disk := proto.Clone(d).(*computepb.AttachedDisk)
+		if disk.InitializeParams == nil {
+			disk.InitializeParams = &computepb.AttachedDiskInitializeParams{}
+		}
+		params := disk.InitializeParams
+		if disk.GetBoot() {
+			foundBoot = true
+			if m.config.BootDiskSizeGB > 0 {
+				params.DiskSizeGb = proto.Int64(m.config.BootDiskSizeGB)
+			}
+			if m.config.BootDiskType != "" {
+				params.DiskType = proto.String(m.config.BootDiskType)
+			}
+			if image != "" {
+				params.SourceImage = proto.String(image)
+			}
+		}
+		// Templates name disk types globally ("pd-ssd"); instance inserts
+		// need the zonal form.
+		if t := params.GetDiskType(); t != "" && !strings.Contains(t, "/") {
+			params.DiskType = proto.String(fmt.Sprintf("zones/%s/diskTypes/%s", zone, t))
+		}
+		disks = append(disks, disk)
+	}
+	if !foundBoot {
+		return nil, fmt.Errorf("instance template %s has no boot disk to override", template)
+	}
+	return disks, nil
+}
@@ -0,0 +1,134 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func testTemplateDisks() []*computepb.AttachedDisk {
+	return []*computepb.AttachedDisk{
+		{
+			Boot: proto.Bool(true),
+			InitializeParams: &computepb.AttachedDiskInitializeParams{
+				SourceImage: proto.String("projects/slang-runners/global/images/windows-gpu-runner"),
+				DiskSizeGb:  proto.Int64(100),
+				DiskType:    proto.String("pd-balanced"),
+			},
+		},
+		{
+			InitializeParams: &computepb.AttachedDiskInitializeParams{
+				DiskSizeGb: proto.Int64(375),
+				DiskType:   proto.String("local-ssd"),
+			},
+		},
+	}
+}
+
+func TestCreateVMOverridesBootDisk(t *testing.T) {
+	template := testTemplateDisks()
+	var templateReads int
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			InstanceTemplate: "windows-gpu-runner",
+			GPUType:          "none",
+			BootDiskSizeGB:   200,
+			BootDiskType:     "pd-ssd",
+		},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		templatePropertiesFunc: func(_ context.Context, name string) (*computepb.InstanceProperties, error) {
+			templateReads++
+			if name != "windows-gpu-runner" {
+				t.Fatalf("template = %q, want windows-gpu-runner", name)
+			}
+			return &computepb.InstanceProperties{Disks: template}, nil
+		},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-d", region: "us-east1"}}, nil
+	}
+	var reqs []*computepb.InsertInstanceRequest
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		reqs = append(reqs, r)
+		return nil
+	}
+
+	for _, name := range []string{"win-test-1", "win-test-2"} {
+		if _, err := m.CreateVM(context.Background(), name, "jit"); err != nil {
+			t.Fatalf("CreateVM(%s): %v", name, err)
+		}
+	}
+	if templateReads != 1 {
+		t.Fatalf("template reads = %d, want 1 (cached)", templateReads)
+	}
+
+	disks := reqs[0].GetInstanceResource().GetDisks()
+	if len(disks) != 2 {
+		t.Fatalf("disks = %d, want template's 2", len(disks))
+	}
+	boot := disks[0].GetInitializeParams()
+	if boot.GetDiskSizeGb() != 200 {
+		t.Fatalf("boot disk size = %d, want 200", boot.GetDiskSizeGb())
+	}
+	if boot.GetDiskType() != "zones/us-east1-d/diskTypes/pd-ssd" {
+		t.Fatalf("boot disk type = %q, want zonal pd-ssd", boot.GetDiskType())
+	}
+	if boot.GetSourceImage() != "projects/slang-runners/global/images/windows-gpu-runner" {
+		t.Fatalf("boot disk lost the template's source image: %q", boot.GetSourceImage())
+	}
+	if got := disks[1].GetInitializeParams().GetDiskType(); got != "zones/us-east1-d/diskTypes/local-ssd" {
+		t.Fatalf("scratch disk type = %q, want zonal local-ssd", got)
+	}
+	if template[0].GetInitializeParams().GetDiskSizeGb() != 100 {
+		t.Fatal("override mutated the cached template disks")
+	}
+}
+
+func TestCreateVMWithoutBootDiskOverrideKeepsTemplateDisks(t *testing.T) {
+	m := &Manager{
+		config:         ManagerConfig{Project: "test-project", InstanceTemplate: "t", GPUType: "none"},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		templatePropertiesFunc: func(context.Context, string) (*computepb.InstanceProperties, error) {
+			t.Fatal("template should not be read without an override")
+			return nil, nil
+		},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-d", region: "us-east1"}}, nil
+	}
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		if len(r.GetInstanceResource().GetDisks()) != 0 {
+			t.Fatal("insert should leave disks to the template")
+		}
+		return nil
+	}
+	if _, err := m.CreateVM(context.Background(), "vm-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+}
+
+func TestCreateVMBootDiskTemplateErrorReleasesReservation(t *testing.T) {
+	m := &Manager{
+		config:         ManagerConfig{Project: "test-project", InstanceTemplate: "t", GPUType: "none", BootDiskSizeGB: 200},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		templatePropertiesFunc: func(context.Context, string) (*computepb.InstanceProperties, error) {
+			return nil, errors.New("template not found")
+		},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-d", region: "us-east1"}}, nil
+	}
+	if _, err := m.CreateVM(context.Background(), "vm-1", "jit"); err == nil {
+		t.Fatal("CreateVM should fail when the template cannot be read")
+	}
+	if got := m.ActiveCount(); got != 0 {
+		t.Fatalf("ActiveCount = %d, want 0 after a failed create", got)
+	}
+}
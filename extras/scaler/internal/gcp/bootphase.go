@@ -0,0 +1,94 @@
+package gcp
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// bootPhaseInterval is how often the manager reads the boot phase of
+// booting VMs (see ManagerConfig.BootPhases).
+const bootPhaseInterval = 15 * time.Second
+
+// Boot phases the startup scripts report in the runner/phase guest
+// attribute, in order. A boot that fails ends in BootPhaseFailed with the
+// reason in runner/error.
+const (
+	BootPhaseStarted          = "started"
+	BootPhaseDriverOK         = "driver-ok"
+	BootPhaseRunnerConfigured = "runner-configured"
+	BootPhaseRunnerOnline     = "runner-online"
+	BootPhaseFailed           = "failed"
+)
+
+// BootPhases lists the boot phases in order, then BootPhaseFailed.
+var BootPhases = []string{
+	BootPhaseStarted, BootPhaseDriverOK, BootPhaseRunnerConfigured, BootPhaseRunnerOnline, BootPhaseFailed,
+}
+
+func (m *Manager) watchBootPhases(ctx context.Context) {
+	ticker := time.NewTicker(bootPhaseInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m.pollBootPhases(ctx)
+	}
+}
+
+// pollBootPhases reads the boot phase of every tracked VM whose runner has
+// not come online, failed or started a job, once per VM however many
+// runners it runs.
+func (m *Manager) pollBootPhases(ctx context.Context) {
+	m.mu.Lock()
+	booting := make(map[string]string) // VM name -> zone
+	for _, vm := range m.vms {
+		if !vm.busy && vm.phase != BootPhaseRunnerOnline && vm.phase != BootPhaseFailed {
+			booting[vm.vmName] = vm.zone
+		}
+	}
+	m.mu.Unlock()
+
+	for vmName, zone := range booting {
+		attrs, err := m.guestAttributes(ctx, vmName, zone)
+		if err != nil {
+			slog.Debug("failed to read boot phase", "vm", vmName, "zone", zone, "error", err)
+			continue
+		}
+		m.setBootPhase(vmName, attrs["phase"], attrs["error"])
+	}
+}
+
+// setBootPhase records a phase vmName reported, logging each change.
+func (m *Manager) setBootPhase(vmName, phase, bootError string) {
+	if phase == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	changed := false
+	var since time.Time
+	for _, vm := range m.vms {
+		if vm.vmName != vmName || vm.phase == phase {
+			continue
+		}
+		vm.phase, vm.bootError = phase, bootError
+		changed = true
+		since = vm.createdAt
+		if vm.reusedAt.After(since) {
+			since = vm.reusedAt
+		}
+	}
+	if !changed {
+		return
+	}
+	if phase == BootPhaseFailed {
+		slog.Warn("VM boot failed", "event", "vm_boot_failed", "vm", vmName, "error", bootError, "elapsed", now.Sub(since).Round(time.Second))
+		return
+	}
+	slog.Info("VM boot phase", "event", "vm_boot_phase", "vm", vmName, "phase", phase, "elapsed", now.Sub(since).Round(time.Second))
+}
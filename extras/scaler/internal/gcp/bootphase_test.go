@@ -0,0 +1,63 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPollBootPhases(t *testing.T) {
+	phases := map[string]map[string]string{
+		"a100-1": {"phase": BootPhaseDriverOK},
+		"win-2":  {"phase": BootPhaseFailed, "error": "nvidia-smi failed"},
+	}
+	reads := map[string]int{}
+	m := &Manager{
+		vms: map[string]*vmInfo{
+			// Two runners on one VM.
+			"a100-1": {vmName: "a100-1", zone: "us-central1-a"},
+			"a100-2": {vmName: "a100-1", zone: "us-central1-a", slot: 1},
+			"win-2":  {vmName: "win-2", zone: "us-east1-c"},
+			"win-3":  {vmName: "win-3", zone: "us-east1-c", busy: true},
+			"win-4":  {vmName: "win-4", zone: "us-east1-c", phase: BootPhaseRunnerOnline},
+		},
+		getGuestAttributesFunc: func(_ context.Context, vmName, _ string) (map[string]string, error) {
+			reads[vmName]++
+			return phases[vmName], nil
+		},
+	}
+	m.pollBootPhases(context.Background())
+
+	if reads["a100-1"] != 1 || reads["win-2"] != 1 || len(reads) != 2 {
+		t.Fatalf("guest attribute reads = %v, want a100-1 and win-2 once each", reads)
+	}
+	for _, runner := range []string{"a100-1", "a100-2"} {
+		if got := m.vms[runner].phase; got != BootPhaseDriverOK {
+			t.Fatalf("%s phase = %q, want %q", runner, got, BootPhaseDriverOK)
+		}
+	}
+	if vm := m.vms["win-2"]; vm.phase != BootPhaseFailed || vm.bootError != "nvidia-smi failed" {
+		t.Fatalf("win-2 phase = %q, error = %q", vm.phase, vm.bootError)
+	}
+
+	// A failed boot is not read again.
+	clear(reads)
+	m.pollBootPhases(context.Background())
+	if reads["win-2"] != 0 {
+		t.Fatal("a failed VM's phase was read again")
+	}
+}
+
+func TestReuseVMResetsBootPhase(t *testing.T) {
+	m := &Manager{
+		vms: map[string]*vmInfo{"win-1": {vmName: "win-1", zone: "us-east1-c", phase: BootPhaseRunnerOnline}},
+		setJITConfigFunc: func(context.Context, string, string, string) error {
+			return nil
+		},
+	}
+	if err := m.ReuseVM(context.Background(), "win-1", "jit"); err != nil {
+		t.Fatalf("ReuseVM: %v", err)
+	}
+	if got := m.vms["win-1"].phase; got != "" {
+		t.Fatalf("phase after reuse = %q, want none until the VM reports again", got)
+	}
+}
@@ -0,0 +1,77 @@
+package gcp
+
+import (
+	"log/slog"
+	"time"
+
+	"extras/scaler/internal/breaker"
+)
+
+// zoneBreakerLocked returns zone's circuit breaker, or nil without
+// BreakerThreshold. The caller must hold m.mu.
+func (m *Manager) zoneBreakerLocked(zone string) *breaker.Breaker {
+	if m.config.BreakerThreshold <= 0 {
+		return nil
+	}
+	b, ok := m.zoneBreakers[zone]
+	if !ok {
+		if m.zoneBreakers == nil {
+			m.zoneBreakers = make(map[string]*breaker.Breaker)
+		}
+		b = breaker.New(m.config.BreakerThreshold, m.config.BreakerBackoff, breaker.MaxBackoff)
+		m.zoneBreakers[zone] = b
+	}
+	return b
+}
+
+// closedZonesLocked drops the candidates whose zone is backing off after
+// failed creates. A half-open zone stays, for a create to probe it. The
+// caller must hold m.mu.
+func (m *Manager) closedZonesLocked(candidates []zoneCandidate) []zoneCandidate {
+	if len(m.zoneBreakers) == 0 {
+		return candidates
+	}
+	now := m.now()
+	var out []zoneCandidate
+	for _, c := range candidates {
+		if m.zoneBreakers[c.zone].State(now) != breaker.Open {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// zoneCreateFailed records a failed insert in zone, opening its breaker
+// after BreakerThreshold in a row.
+func (m *Manager) zoneCreateFailed(zone string, err error) {
+	m.mu.Lock()
+	b := m.zoneBreakerLocked(zone)
+	m.mu.Unlock()
+	if until, opened := b.Failure(m.now()); opened {
+		slog.Warn("creates keep failing in zone, skipping it", "event", "zone_backoff", "zone", zone, "until", until, "error", err)
+	}
+}
+
+// zoneCreateSucceeded records a successful insert in zone, closing its
+// breaker.
+func (m *Manager) zoneCreateSucceeded(zone string) {
+	m.mu.Lock()
+	b := m.zoneBreakers[zone]
+	m.mu.Unlock()
+	b.Success()
+}
+
+// zoneBackoffs returns the end of the backoff of each zone whose breaker is
+// open or half-open. The caller must hold m.mu.
+func (m *Manager) zoneBackoffsLocked() map[string]time.Time {
+	var out map[string]time.Time
+	for zone, b := range m.zoneBreakers {
+		if until := b.Until(); !until.IsZero() {
+			if out == nil {
+				out = make(map[string]time.Time)
+			}
+			out[zone] = until
+		}
+	}
+	return out
+}
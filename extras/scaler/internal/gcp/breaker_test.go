@@ -0,0 +1,70 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+func TestZoneBreakerSkipsFailingZone(t *testing.T) {
+	now := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+	zones := []zoneCandidate{{zone: "us-east1-c", region: "us-east1"}, {zone: "us-east1-d", region: "us-east1"}}
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			InstanceTemplate: "linux-runner",
+			GPUType:          "none",
+			Platform:         "linux",
+			BreakerThreshold: 2,
+			BreakerBackoff:   time.Minute,
+		},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		nowFunc:        func() time.Time { return now },
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return append([]zoneCandidate(nil), zones...), nil
+	}
+	stockedOut := true
+	inserts := make(map[string]int)
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		inserts[r.GetZone()]++
+		if r.GetZone() == "us-east1-c" && stockedOut {
+			return errors.New("ZONE_RESOURCE_POOL_EXHAUSTED")
+		}
+		return nil
+	}
+
+	for _, name := range []string{"linux-1", "linux-2", "linux-3", "linux-4"} {
+		if _, err := m.CreateVM(context.Background(), name, "jit"); err != nil {
+			t.Fatalf("CreateVM(%s): %v", name, err)
+		}
+	}
+	// The zone takes two failed inserts, then is skipped.
+	if inserts["us-east1-c"] != 2 || inserts["us-east1-d"] != 4 {
+		t.Fatalf("inserts = %v, want us-east1-c skipped after 2 failures", inserts)
+	}
+	if until := m.Capacity().ZoneBackoffs["us-east1-c"]; !until.Equal(now.Add(time.Minute)) {
+		t.Fatalf("ZoneBackoffs = %v, want us-east1-c for a minute", m.Capacity().ZoneBackoffs)
+	}
+
+	// Alone and still backing off, the zone fails the create without an
+	// insert.
+	zones = zones[:1]
+	if _, err := m.CreateVM(context.Background(), "linux-5", "jit"); err == nil || inserts["us-east1-c"] != 2 {
+		t.Fatalf("CreateVM during the backoff = %v with %d inserts, want no insert", err, inserts["us-east1-c"])
+	}
+	// After the backoff a create probes the zone, and its success closes
+	// the breaker.
+	now = now.Add(time.Minute)
+	stockedOut = false
+	if _, err := m.CreateVM(context.Background(), "linux-6", "jit"); err != nil {
+		t.Fatalf("probe CreateVM: %v", err)
+	}
+	if inserts["us-east1-c"] != 3 || len(m.Capacity().ZoneBackoffs) != 0 {
+		t.Fatalf("inserts = %v, backoffs = %v; want the probe to close the breaker", inserts, m.Capacity().ZoneBackoffs)
+	}
+}
@@ -0,0 +1,187 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// cacheDiskDeviceName is how the startup scripts find the build cache
+	// disk (/dev/disk/by-id/google-build-cache on Linux, the disk's serial
+	// number on Windows).
+	cacheDiskDeviceName = "build-cache"
+	// defaultCacheDiskSizeGB is the size of new cache disks when
+	// CacheDiskSizeGB is unset. Regional pd-standard disks cannot be
+	// smaller.
+	defaultCacheDiskSizeGB = 200
+	defaultCacheDiskType   = "pd-balanced"
+)
+
+// cacheDiskName is the name of a zone's index'th build cache disk.
+func cacheDiskName(vmPrefix, zone string, index int) string {
+	return fmt.Sprintf("%s-cache-%s-%d", vmPrefix, zone, index)
+}
+
+// reserveCacheDisk picks a build cache disk of zone that no VM is using and
+// reserves it for vmName, creating the disk if it does not exist yet. It
+// returns nil when the zone has no cache disks or all are in use; the VM is
+// then created without one. The reservation lasts as long as vmName is
+// tracked, pending or in the stopped pool, so a failed create leaves none
+// behind. GCE detaches the disk when the VM is deleted.
+func (m *Manager) reserveCacheDisk(ctx context.Context, vmName, zone string) *computepb.AttachedDisk {
+	for i := 0; i < m.config.CacheDisks[zone]; i++ {
+		name := cacheDiskName(m.config.VMPrefix, zone, i)
+		m.mu.Lock()
+		if user, ok := m.cacheDiskUsers[name]; ok && m.vmExistsLocked(user) {
+			m.mu.Unlock()
+			continue
+		}
+		if m.cacheDiskUsers == nil {
+			m.cacheDiskUsers = make(map[string]string)
+		}
+		m.cacheDiskUsers[name] = vmName
+		m.mu.Unlock()
+
+		free, err := m.ensureCacheDisk(ctx, zone, name)
+		if err != nil {
+			slog.Warn("failed to prepare build cache disk", "disk", name, "zone", zone, "error", err)
+		}
+		if err != nil || !free {
+			m.releaseCacheDisk(name, vmName)
+			continue
+		}
+		slog.Info("attaching build cache disk", "disk", name, "vm", vmName, "zone", zone)
+		return &computepb.AttachedDisk{
+			Source:     proto.String(fmt.Sprintf("projects/%s/regions/%s/disks/%s", m.config.Project, zoneRegion(zone), name)),
+			DeviceName: proto.String(cacheDiskDeviceName),
+			Mode:       proto.String(computepb.AttachedDisk_READ_WRITE.String()),
+			Type:       proto.String(computepb.AttachedDisk_PERSISTENT.String()),
+			AutoDelete: proto.Bool(false),
+		}
+	}
+	return nil
+}
+
+// releaseCacheDisk drops vmName's reservation of a cache disk before
+// vmName is retried in another zone.
+func (m *Manager) releaseCacheDisk(name, vmName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cacheDiskUsers[name] == vmName {
+		delete(m.cacheDiskUsers, name)
+	}
+}
+
+// vmExistsLocked reports whether vmName is tracked, being created or in
+// the stopped pool. The caller must hold m.mu.
+func (m *Manager) vmExistsLocked(vmName string) bool {
+	if _, ok := m.pendingCreates[vmName]; ok {
+		return true
+	}
+	if _, ok := m.stopped[vmName]; ok {
+		return true
+	}
+	for _, vm := range m.vms {
+		if vm.vmName == vmName {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureCacheDisk creates the named cache disk if it does not exist and
+// reports whether it is free to attach. A disk still attached to a VM this
+// manager no longer tracks, such as one being deleted, is not.
+func (m *Manager) ensureCacheDisk(ctx context.Context, zone, name string) (bool, error) {
+	region := zoneRegion(zone)
+	disk, err := m.getCacheDisk(ctx, region, name)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		replica, err := m.cacheDiskReplicaZone(ctx, zone)
+		if err != nil {
+			return false, err
+		}
+		sizeGB, diskType := m.config.CacheDiskSizeGB, m.config.CacheDiskType
+		if sizeGB <= 0 {
+			sizeGB = defaultCacheDiskSizeGB
+		}
+		if diskType == "" {
+			diskType = defaultCacheDiskType
+		}
+		slog.Info("creating build cache disk", "disk", name, "zones", []string{zone, replica}, "size_gb", sizeGB, "type", diskType)
+		return true, m.insertCacheDisk(ctx, region, &computepb.Disk{
+			Name:   proto.String(name),
+			SizeGb: proto.Int64(sizeGB),
+			Type:   proto.String(fmt.Sprintf("projects/%s/regions/%s/diskTypes/%s", m.config.Project, region, diskType)),
+			ReplicaZones: []string{
+				fmt.Sprintf("projects/%s/zones/%s", m.config.Project, zone),
+				fmt.Sprintf("projects/%s/zones/%s", m.config.Project, replica),
+			},
+			Labels: m.config.Labels,
+		})
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading disk %s: %w", name, err)
+	}
+	return len(disk.GetUsers()) == 0, nil
+}
+
+// cacheDiskReplicaZone picks the second zone of a regional cache disk for
+// zone: another configured zone in its region, or else the region's first
+// other zone.
+func (m *Manager) cacheDiskReplicaZone(ctx context.Context, zone string) (string, error) {
+	region := zoneRegion(zone)
+	for _, z := range splitZones(m.zones()) {
+		if z != zone && zoneRegion(z) == region {
+			return z, nil
+		}
+	}
+	zones, err := m.regionZones(ctx, region)
+	if err != nil {
+		return "", fmt.Errorf("listing zones in %s: %w", region, err)
+	}
+	sort.Strings(zones)
+	for _, z := range zones {
+		if z != zone {
+			return z, nil
+		}
+	}
+	return "", fmt.Errorf("region %s has no second zone for a regional disk", region)
+}
+
+func (m *Manager) getCacheDisk(ctx context.Context, region, name string) (*computepb.Disk, error) {
+	if m.getCacheDiskFunc != nil {
+		return m.getCacheDiskFunc(ctx, region, name)
+	}
+	return m.regionDisksClient.Get(ctx, &computepb.GetRegionDiskRequest{
+		Project: m.config.Project,
+		Region:  region,
+		Disk:    name,
+	})
+}
+
+func (m *Manager) insertCacheDisk(ctx context.Context, region string, disk *computepb.Disk) error {
+	if m.insertCacheDiskFunc != nil {
+		return m.insertCacheDiskFunc(ctx, region, disk)
+	}
+	op, err := m.regionDisksClient.Insert(ctx, &computepb.InsertRegionDiskRequest{
+		Project:      m.config.Project,
+		Region:       region,
+		DiskResource: disk,
+	})
+	if err != nil {
+		return fmt.Errorf("creating disk %s: %w", disk.GetName(), err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("waiting for disk %s: %w", disk.GetName(), err)
+	}
+	return nil
+}
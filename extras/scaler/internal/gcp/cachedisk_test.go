@@ -0,0 +1,124 @@
+package gcp
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/protobuf/proto"
+)
+
+func newCacheDiskManager(t *testing.T, disks map[string]*computepb.Disk) (*Manager, *[]*computepb.InsertInstanceRequest) {
+	t.Helper()
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			Zones:            "us-east1-c,us-east1-d",
+			InstanceTemplate: "win-build",
+			GPUType:          "none",
+			VMPrefix:         "win-build",
+			CacheDisks:       map[string]int{"us-east1-c": 1},
+		},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		templatePropertiesFunc: func(context.Context, string) (*computepb.InstanceProperties, error) {
+			return &computepb.InstanceProperties{Disks: []*computepb.AttachedDisk{{
+				Boot:             proto.Bool(true),
+				InitializeParams: &computepb.AttachedDiskInitializeParams{DiskType: proto.String("pd-ssd")},
+			}}}, nil
+		},
+		getCacheDiskFunc: func(_ context.Context, _, name string) (*computepb.Disk, error) {
+			if d, ok := disks[name]; ok {
+				return d, nil
+			}
+			return nil, &googleapi.Error{Code: http.StatusNotFound}
+		},
+		insertCacheDiskFunc: func(_ context.Context, region string, disk *computepb.Disk) error {
+			if region != "us-east1" {
+				t.Fatalf("cache disk region = %s, want us-east1", region)
+			}
+			disks[disk.GetName()] = disk
+			return nil
+		},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-c", region: "us-east1"}}, nil
+	}
+	var reqs []*computepb.InsertInstanceRequest
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		reqs = append(reqs, r)
+		return nil
+	}
+	return m, &reqs
+}
+
+// cacheDiskSource returns the source of req's build cache disk, if any.
+func cacheDiskSource(req *computepb.InsertInstanceRequest) string {
+	for _, d := range req.GetInstanceResource().GetDisks() {
+		if d.GetDeviceName() == cacheDiskDeviceName {
+			return d.GetSource()
+		}
+	}
+	return ""
+}
+
+func TestCreateVMAttachesCacheDisk(t *testing.T) {
+	disks := map[string]*computepb.Disk{}
+	m, reqs := newCacheDiskManager(t, disks)
+
+	if _, err := m.CreateVM(context.Background(), "win-build-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	disk := disks["win-build-cache-us-east1-c-0"]
+	if disk == nil {
+		t.Fatalf("cache disk was not created: %v", disks)
+	}
+	wantZones := []string{"projects/test-project/zones/us-east1-c", "projects/test-project/zones/us-east1-d"}
+	if got := disk.GetReplicaZones(); len(got) != 2 || got[0] != wantZones[0] || got[1] != wantZones[1] {
+		t.Fatalf("replica zones = %v, want %v", got, wantZones)
+	}
+	req := (*reqs)[0]
+	if got := cacheDiskSource(req); got != "projects/test-project/regions/us-east1/disks/win-build-cache-us-east1-c-0" {
+		t.Fatalf("cache disk source = %q", got)
+	}
+	if len(req.GetInstanceResource().GetDisks()) != 2 || !req.GetInstanceResource().GetDisks()[0].GetBoot() {
+		t.Fatal("the template's boot disk must be kept next to the cache disk")
+	}
+
+	// The only disk is reserved for win-build-1.
+	if _, err := m.CreateVM(context.Background(), "win-build-2", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if got := cacheDiskSource((*reqs)[1]); got != "" {
+		t.Fatalf("second VM got cache disk %q while the first holds it", got)
+	}
+
+	// Once win-build-1 is gone, the disk is free again.
+	delete(m.vms, "win-build-1")
+	if _, err := m.CreateVM(context.Background(), "win-build-3", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if got := cacheDiskSource((*reqs)[2]); got == "" {
+		t.Fatal("third VM got no cache disk after the first was deleted")
+	}
+}
+
+func TestCreateVMSkipsAttachedCacheDisk(t *testing.T) {
+	disks := map[string]*computepb.Disk{
+		// Still attached to a VM being deleted.
+		"win-build-cache-us-east1-c-0": {Users: []string{"projects/test-project/zones/us-east1-c/instances/win-build-old"}},
+	}
+	m, reqs := newCacheDiskManager(t, disks)
+
+	if _, err := m.CreateVM(context.Background(), "win-build-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if got := cacheDiskSource((*reqs)[0]); got != "" {
+		t.Fatalf("VM got cache disk %q that is still attached elsewhere", got)
+	}
+	if len(m.cacheDiskUsers) != 0 {
+		t.Fatalf("cacheDiskUsers = %v, want the skipped disk unreserved", m.cacheDiskUsers)
+	}
+}
@@ -0,0 +1,120 @@
+package gcp
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// maxRecentStockouts caps the stockouts Capacity reports.
+	maxRecentStockouts = 50
+	// stockoutWindow is how long a stockout counts as recent.
+	stockoutWindow = time.Hour
+)
+
+// RegionQuota is a region's GPU quota as of the last zone selection that
+// read it. Zone selection only reads quota when creating a VM, so CheckedAt
+// shows how current it is.
+type RegionQuota struct {
+	Region    string    `json:"region"`
+	GPUType   string    `json:"gpu_type"`
+	Limit     float64   `json:"limit"`
+	Usage     float64   `json:"usage"`
+	Available float64   `json:"available"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Stockout is a create that failed with ZONE_RESOURCE_POOL_EXHAUSTED or a
+// similar out-of-resources error.
+type Stockout struct {
+	Zone    string    `json:"zone"`
+	GPUType string    `json:"gpu_type"`
+	Time    time.Time `json:"time"`
+}
+
+// Capacity is the manager's view of the capacity it can create VMs in.
+type Capacity struct {
+	Quotas []RegionQuota `json:"quotas"`
+	// RecentStockouts are the stockouts within the last hour, oldest first.
+	RecentStockouts []Stockout `json:"recent_stockouts"`
+	// StockoutsByZone counts every stockout since the manager started.
+	StockoutsByZone map[string]int64 `json:"stockouts_by_zone"`
+	// Reservations is the last-read usage of the configured reservations.
+	// ReservedVMs and OnDemandVMs split the tracked VMs by whether they
+	// run in one.
+	Reservations []ReservationUsage `json:"reservations"`
+	ReservedVMs  int                `json:"reserved_vms"`
+	OnDemandVMs  int                `json:"on_demand_vms"`
+	// ZoneBackoffs maps each zone skipped after failed creates to the end
+	// of its backoff, when it is probed again (see BreakerThreshold).
+	ZoneBackoffs map[string]time.Time `json:"zone_backoffs,omitempty"`
+	// ZoneCooldowns are the zones skipped after a stockout (see
+	// StockoutCooldown).
+	ZoneCooldowns []ZoneCooldown `json:"zone_cooldowns,omitempty"`
+	// ZonePreemptions maps each zone where a Spot VM was preempted within
+	// PreemptionWindow to its creates and preemptions over the window.
+	ZonePreemptions map[string]ZonePreemptions `json:"zone_preemptions,omitempty"`
+}
+
+func (m *Manager) recordQuota(q RegionQuota) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.quotas == nil {
+		m.quotas = make(map[string]RegionQuota)
+	}
+	m.quotas[q.Region+"/"+q.GPUType] = q
+}
+
+func (m *Manager) recordStockout(zone, gpuType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stockoutTotals == nil {
+		m.stockoutTotals = make(map[string]int64)
+	}
+	m.stockoutTotals[zone]++
+	m.stockouts = append(m.stockouts, Stockout{Zone: zone, GPUType: gpuType, Time: m.now()})
+	if m.config.StockoutCooldown > 0 {
+		if m.stockoutUntil == nil {
+			m.stockoutUntil = make(map[zoneGPU]time.Time)
+		}
+		m.stockoutUntil[zoneGPU{zone, gpuType}] = m.now().Add(m.config.StockoutCooldown)
+	}
+	if len(m.stockouts) > maxRecentStockouts {
+		m.stockouts = m.stockouts[len(m.stockouts)-maxRecentStockouts:]
+	}
+}
+
+// Capacity returns the last-read quota of each region, sorted by region and
+// GPU type, the recent stockouts and reservation usage.
+func (m *Manager) Capacity() Capacity {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := Capacity{
+		Quotas:          make([]RegionQuota, 0, len(m.quotas)),
+		RecentStockouts: []Stockout{},
+		StockoutsByZone: make(map[string]int64, len(m.stockoutTotals)),
+	}
+	for _, q := range m.quotas {
+		c.Quotas = append(c.Quotas, q)
+	}
+	sort.Slice(c.Quotas, func(i, j int) bool {
+		if c.Quotas[i].Region != c.Quotas[j].Region {
+			return c.Quotas[i].Region < c.Quotas[j].Region
+		}
+		return c.Quotas[i].GPUType < c.Quotas[j].GPUType
+	})
+	cutoff := m.now().Add(-stockoutWindow)
+	for _, s := range m.stockouts {
+		if s.Time.After(cutoff) {
+			c.RecentStockouts = append(c.RecentStockouts, s)
+		}
+	}
+	for zone, n := range m.stockoutTotals {
+		c.StockoutsByZone[zone] = n
+	}
+	c.ZoneBackoffs = m.zoneBackoffsLocked()
+	c.ZoneCooldowns = m.zoneCooldownsLocked()
+	c.ZonePreemptions = m.zonePreemptionsLocked()
+	m.reservationCapacity(&c)
+	return c
+}
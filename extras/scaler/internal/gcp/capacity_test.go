@@ -0,0 +1,69 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+func TestCapacityRecordsStockouts(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := &Manager{
+		config:         ManagerConfig{Project: "test-project", InstanceTemplate: "t", GPUType: "nvidia-l4"},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		nowFunc:        func() time.Time { return now },
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{
+			{zone: "us-east1-d", region: "us-east1", available: 8},
+			{zone: "us-east1-b", region: "us-east1", available: 8},
+		}, nil
+	}
+	m.insertVMFunc = func(_ context.Context, req *computepb.InsertInstanceRequest) error {
+		if req.GetZone() == "us-east1-d" {
+			return errors.New("ZONE_RESOURCE_POOL_EXHAUSTED")
+		}
+		return nil
+	}
+
+	if _, err := m.CreateVM(context.Background(), "runner-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if _, err := m.CreateVM(context.Background(), "runner-2", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+
+	c := m.Capacity()
+	if len(c.RecentStockouts) != 1 || c.RecentStockouts[0].Zone != "us-east1-d" || !c.RecentStockouts[0].Time.Equal(now) {
+		t.Fatalf("recent stockouts = %+v, want only the one within the last hour", c.RecentStockouts)
+	}
+	if c.RecentStockouts[0].GPUType != "nvidia-l4" {
+		t.Fatalf("stockout GPU type = %q, want nvidia-l4", c.RecentStockouts[0].GPUType)
+	}
+	if c.StockoutsByZone["us-east1-d"] != 2 || len(c.StockoutsByZone) != 1 {
+		t.Fatalf("stockouts by zone = %v, want us-east1-d: 2", c.StockoutsByZone)
+	}
+}
+
+func TestCapacityKeepsLatestQuotaPerRegion(t *testing.T) {
+	m := &Manager{}
+	m.recordQuota(RegionQuota{Region: "us-west1", GPUType: "nvidia-l4", Limit: 8, Usage: 2, Available: 6})
+	m.recordQuota(RegionQuota{Region: "us-east1", GPUType: "nvidia-l4", Limit: 16, Usage: 4, Available: 12})
+	m.recordQuota(RegionQuota{Region: "us-west1", GPUType: "nvidia-l4", Limit: 8, Usage: 8, Available: 0})
+
+	c := m.Capacity()
+	if len(c.Quotas) != 2 || c.Quotas[0].Region != "us-east1" || c.Quotas[1].Region != "us-west1" {
+		t.Fatalf("quotas = %+v, want us-east1 and us-west1 in order", c.Quotas)
+	}
+	if c.Quotas[1].Usage != 8 || c.Quotas[1].Available != 0 {
+		t.Fatalf("us-west1 quota = %+v, want the latest reading", c.Quotas[1])
+	}
+	if c.RecentStockouts == nil || c.StockoutsByZone == nil {
+		t.Fatal("empty stockouts should be non-nil so JSON has [] and {}")
+	}
+}
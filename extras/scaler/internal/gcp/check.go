@@ -0,0 +1,103 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// Check verifies, without creating or deleting anything, that cfg would
+// work: the ambient credentials can reach the project, every configured zone
+// exists (or Regions yields zones) and every instance template, including
+// routed ones, can be read, with NoExternalIP that the VMs' subnetworks
+// have Cloud NAT, and that the templates' VMs pass OrgPolicyCheck and
+// AllowedMachineTypes in every zone. It returns one error per problem.
+func Check(ctx context.Context, cfg ManagerConfig) []error {
+	if err := validateMetadata(cfg.Metadata); err != nil {
+		return []error{err}
+	}
+	if err := ValidateLabels(cfg.Labels); err != nil {
+		return []error{err}
+	}
+
+	opts, err := clientOptions(ctx, cfg.ImpersonateServiceAccount)
+	if err != nil {
+		return []error{err}
+	}
+	regionsClient, err := compute.NewRegionsRESTClient(ctx, opts...)
+	if err != nil {
+		return []error{fmt.Errorf("creating regions client: %w", err)}
+	}
+	defer regionsClient.Close()
+	templatesClient, err := compute.NewInstanceTemplatesRESTClient(ctx, opts...)
+	if err != nil {
+		return []error{fmt.Errorf("creating instance templates client: %w", err)}
+	}
+	defer templatesClient.Close()
+	zonesClient, err := compute.NewZonesRESTClient(ctx, opts...)
+	if err != nil {
+		return []error{fmt.Errorf("creating zones client: %w", err)}
+	}
+	defer zonesClient.Close()
+
+	if cfg.GPUType == "" {
+		cfg.GPUType = "nvidia-tesla-t4"
+	}
+	m := &Manager{
+		config:          cfg,
+		regionsClient:   regionsClient,
+		templatesClient: templatesClient,
+		getZoneFunc: func(ctx context.Context, zone string) error {
+			_, err := zonesClient.Get(ctx, &computepb.GetZoneRequest{Project: cfg.Project, Zone: zone})
+			return err
+		},
+	}
+	return m.check(ctx)
+}
+
+func (m *Manager) check(ctx context.Context) []error {
+	var errs []error
+	if len(m.config.Regions) > 0 {
+		if err := m.discoverZones(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	zones := splitZones(m.zones())
+	if err := validateZones(zones); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, zone := range zones {
+			if err := m.getZoneFunc(ctx, zone); err != nil {
+				errs = append(errs, fmt.Errorf("zone %s: %w", zone, err))
+			}
+		}
+	}
+
+	for _, template := range m.templateNames() {
+		if _, err := m.templateProperties(ctx, template); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if m.config.NoExternalIP && len(errs) == 0 {
+		errs = append(errs, m.checkPrivateEgress(ctx)...)
+	}
+	if (m.config.OrgPolicyCheck || len(m.config.AllowedMachineTypes) > 0) && len(errs) == 0 {
+		errs = append(errs, m.checkOrgPolicies(ctx)...)
+	}
+	return errs
+}
+
+// templateNames returns the instance template and the routed ones, each
+// once.
+func (m *Manager) templateNames() []string {
+	templates := []string{m.config.InstanceTemplate}
+	for _, route := range m.config.TemplateRoutes {
+		if !slices.Contains(templates, route.InstanceTemplate) {
+			templates = append(templates, route.InstanceTemplate)
+		}
+	}
+	return templates
+}
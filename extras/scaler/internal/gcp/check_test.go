@@ -0,0 +1,65 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+func TestCheckReportsEveryProblem(t *testing.T) {
+	var reads []string
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			Zones:            "us-east1-c,us-east9-z",
+			InstanceTemplate: "linux-gpu-runner",
+			TemplateRoutes: []TemplateRoute{
+				{Label: "GCP-A100", InstanceTemplate: "linux-gpu-a100"},
+				{Label: "GCP-T4", InstanceTemplate: "linux-gpu-runner"},
+			},
+		},
+		getZoneFunc: func(_ context.Context, zone string) error {
+			if zone == "us-east9-z" {
+				return errors.New("not found")
+			}
+			return nil
+		},
+		templatePropertiesFunc: func(_ context.Context, name string) (*computepb.InstanceProperties, error) {
+			reads = append(reads, name)
+			if name == "linux-gpu-a100" {
+				return nil, errors.New("instance template linux-gpu-a100 not found")
+			}
+			return &computepb.InstanceProperties{}, nil
+		},
+	}
+
+	errs := m.check(context.Background())
+	if len(errs) != 2 {
+		t.Fatalf("errors = %v, want the missing zone and template", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "us-east9-z") || !strings.Contains(errs[1].Error(), "linux-gpu-a100") {
+		t.Fatalf("errors = %v", errs)
+	}
+	if len(reads) != 2 {
+		t.Fatalf("template reads = %v, want each template once", reads)
+	}
+}
+
+func TestCheckRejectsMalformedZones(t *testing.T) {
+	m := &Manager{
+		config: ManagerConfig{Project: "test-project", Zones: "invalid-zone", InstanceTemplate: "t"},
+		getZoneFunc: func(context.Context, string) error {
+			t.Fatal("malformed zones should not be looked up")
+			return nil
+		},
+		templatePropertiesFunc: func(context.Context, string) (*computepb.InstanceProperties, error) {
+			return &computepb.InstanceProperties{}, nil
+		},
+	}
+	if errs := m.check(context.Background()); len(errs) != 1 {
+		t.Fatalf("errors = %v, want one invalid zone error", errs)
+	}
+}
@@ -0,0 +1,234 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// The cost model behind EstimateCost: approximate us-central1 on-demand
+// list prices in USD. Actual prices vary by region and change over time,
+// and committed use or reservation discounts are not applied, so the
+// estimate is a ceiling for budgeting rather than a bill.
+var (
+	// seriesRates are the per-vCPU and per-GB-of-memory hourly prices of
+	// each machine series.
+	seriesRates = map[string]struct{ cpu, memGB float64 }{
+		"n1":  {0.031611, 0.004237},
+		"n2":  {0.031611, 0.004237},
+		"n2d": {0.027502, 0.003686},
+		"e2":  {0.021811, 0.002923},
+		"c2":  {0.03398, 0.00455},
+		"g2":  {0.024988, 0.002928},
+		"a2":  {0.031611, 0.004237},
+	}
+	// gpuRates are the hourly prices of one GPU, by accelerator type.
+	gpuRates = map[string]float64{
+		"nvidia-tesla-t4":   0.35,
+		"nvidia-tesla-p4":   0.60,
+		"nvidia-tesla-p100": 1.46,
+		"nvidia-tesla-v100": 2.48,
+		"nvidia-l4":         0.5591,
+		"nvidia-tesla-a100": 2.934,
+		"nvidia-a100-80gb":  3.93,
+	}
+	// diskRates are the monthly prices of one GB of each disk type.
+	diskRates = map[string]float64{
+		"pd-standard":        0.04,
+		"pd-balanced":        0.10,
+		"pd-ssd":             0.17,
+		"pd-extreme":         0.125,
+		"hyperdisk-balanced": 0.08,
+		"local-ssd":          0.08,
+	}
+)
+
+const (
+	// hoursPerMonth converts the monthly disk prices.
+	hoursPerMonth = 730
+	// windowsLicenseRate is the hourly Windows Server license price per
+	// vCPU.
+	windowsLicenseRate = 0.046
+	// spotFraction is the share of the on-demand price a Spot VM costs, a
+	// rough average of Spot's dynamic prices.
+	spotFraction = 0.4
+	// defaultDiskGB is the size of a disk whose template leaves it to the
+	// image.
+	defaultDiskGB = 10
+	// localSSDGB is the size of one local SSD.
+	localSSDGB = 375
+)
+
+// machineShape returns the vCPUs, memory in GB and bundled GPUs of
+// machineType, e.g. "n1-standard-8", "n2-custom-8-32768", "g2-standard-24"
+// or "zones/us-east1-c/machineTypes/a2-highgpu-2g".
+func machineShape(machineType string) (series string, cpus, memGB float64, gpus int, gpu string, err error) {
+	name := path.Base(machineType)
+	parts := strings.Split(name, "-")
+	series = parts[0]
+	if series == "custom" {
+		// The legacy custom-CPUS-MEMMB is an n1 type.
+		series, parts = "n1", append([]string{"n1"}, parts...)
+	}
+	number := func(s string) float64 {
+		n, convErr := strconv.Atoi(strings.TrimSuffix(s, "g"))
+		if convErr != nil && err == nil {
+			err = fmt.Errorf("unknown machine type %q", name)
+		}
+		return float64(n)
+	}
+	if len(parts) < 2 {
+		return "", 0, 0, 0, "", fmt.Errorf("unknown machine type %q", name)
+	}
+	switch class := parts[1]; {
+	case class == "custom" && len(parts) >= 4:
+		cpus, memGB = number(parts[2]), number(parts[3])/1024
+	case series == "e2" && len(parts) == 2:
+		// The shared-core types.
+		shared := map[string][2]float64{"micro": {0.25, 1}, "small": {0.5, 2}, "medium": {1, 4}}
+		shape, ok := shared[class]
+		if !ok {
+			return "", 0, 0, 0, "", fmt.Errorf("unknown machine type %q", name)
+		}
+		cpus, memGB = shape[0], shape[1]
+	case series == "a2" && len(parts) == 3:
+		// a2-highgpu-Ng and a2-ultragpu-Ng have 12 vCPUs and 85 or 170 GB
+		// per A100.
+		gpus = int(number(parts[2]))
+		cpus, memGB, gpu = 12*float64(gpus), 85*float64(gpus), "nvidia-tesla-a100"
+		if class == "ultragpu" {
+			memGB, gpu = 170*float64(gpus), "nvidia-a100-80gb"
+		}
+	case len(parts) == 3:
+		cpus = number(parts[2])
+		perCPU := map[string]float64{"standard": 4, "highmem": 8, "highcpu": 1}
+		if series == "n1" {
+			perCPU = map[string]float64{"standard": 3.75, "highmem": 6.5, "highcpu": 0.9}
+		}
+		ratio, ok := perCPU[class]
+		if !ok {
+			return "", 0, 0, 0, "", fmt.Errorf("unknown machine type %q", name)
+		}
+		memGB = cpus * ratio
+		if series == "g2" {
+			// g2 types bundle their L4s.
+			gpus, gpu = 1, "nvidia-l4"
+			switch cpus {
+			case 24:
+				gpus = 2
+			case 48:
+				gpus = 4
+			case 96:
+				gpus = 8
+			}
+		}
+	default:
+		return "", 0, 0, 0, "", fmt.Errorf("unknown machine type %q", name)
+	}
+	if err != nil {
+		return "", 0, 0, 0, "", err
+	}
+	if _, ok := seriesRates[series]; !ok {
+		return "", 0, 0, 0, "", fmt.Errorf("no price for machine series %q", series)
+	}
+	return series, cpus, memGB, gpus, gpu, nil
+}
+
+// hourlyCost estimates the hourly price of vm, inserted from a template with
+// props: vm's machine type, accelerators, disks and scheduling override the
+// template's. Disks attached by source, like cache disks, are billed
+// whether or not a VM uses them, so they are left out.
+func hourlyCost(props *computepb.InstanceProperties, vm *computepb.Instance, windows bool) (float64, error) {
+	machineType := vm.GetMachineType()
+	if machineType == "" {
+		machineType = props.GetMachineType()
+	}
+	accelerators := vm.GetGuestAccelerators()
+	if accelerators == nil {
+		accelerators = props.GetGuestAccelerators()
+	}
+	disks := vm.GetDisks()
+	if disks == nil {
+		disks = props.GetDisks()
+	}
+	scheduling := vm.GetScheduling()
+	if scheduling == nil {
+		scheduling = props.GetScheduling()
+	}
+
+	series, cpus, memGB, gpus, gpu, err := machineShape(machineType)
+	if err != nil {
+		return 0, err
+	}
+	rates := seriesRates[series]
+	compute := cpus*rates.cpu + memGB*rates.memGB
+	if gpus > 0 {
+		// The bundled GPUs are the only ones such a VM can have.
+		compute += float64(gpus) * gpuRates[gpu]
+	} else {
+		for _, a := range accelerators {
+			rate, ok := gpuRates[path.Base(a.GetAcceleratorType())]
+			if !ok {
+				return 0, fmt.Errorf("no price for accelerator %q", path.Base(a.GetAcceleratorType()))
+			}
+			compute += float64(a.GetAcceleratorCount()) * rate
+		}
+	}
+	if scheduling.GetProvisioningModel() == computepb.Scheduling_SPOT.String() || scheduling.GetPreemptible() {
+		compute *= spotFraction
+	}
+	if windows {
+		compute += cpus * windowsLicenseRate
+	}
+
+	storage := 0.0
+	for _, d := range disks {
+		if d.GetType() == computepb.AttachedDisk_SCRATCH.String() {
+			storage += localSSDGB * diskRates["local-ssd"]
+			continue
+		}
+		params := d.GetInitializeParams()
+		if params == nil {
+			continue
+		}
+		diskType := path.Base(params.GetDiskType())
+		if diskType == "." {
+			diskType = "pd-standard"
+		}
+		rate, ok := diskRates[diskType]
+		if !ok {
+			return 0, fmt.Errorf("no price for disk type %q", diskType)
+		}
+		size := params.GetDiskSizeGb()
+		if size == 0 {
+			size = defaultDiskGB
+		}
+		storage += float64(size) * rate
+	}
+	return compute + storage/hoursPerMonth, nil
+}
+
+// estimateCost returns the estimated hourly price of vm, created from
+// template, or 0 when EstimateCost is off or the price of some part of vm is
+// unknown.
+func (m *Manager) estimateCost(ctx context.Context, template string, vm *computepb.Instance) float64 {
+	if !m.config.EstimateCost {
+		return 0
+	}
+	props, err := m.templateProperties(ctx, template)
+	if err != nil {
+		slog.Warn("cannot estimate VM cost", "template", template, "error", err)
+		return 0
+	}
+	cost, err := hourlyCost(props, vm, m.config.Platform == "windows")
+	if err != nil {
+		slog.Warn("cannot estimate VM cost", "template", template, "error", err)
+		return 0
+	}
+	return cost
+}
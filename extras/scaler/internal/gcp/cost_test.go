@@ -0,0 +1,117 @@
+package gcp
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMachineShape(t *testing.T) {
+	for _, tc := range []struct {
+		machineType string
+		cpus, memGB float64
+		gpus        int
+		wantErr     bool
+	}{
+		{machineType: "n1-standard-8", cpus: 8, memGB: 30},
+		{machineType: "zones/us-east1-c/machineTypes/n2-highmem-16", cpus: 16, memGB: 128},
+		{machineType: "custom-8-30720", cpus: 8, memGB: 30},
+		{machineType: "n2-custom-8-32768", cpus: 8, memGB: 32},
+		{machineType: "e2-medium", cpus: 1, memGB: 4},
+		{machineType: "g2-standard-24", cpus: 24, memGB: 96, gpus: 2},
+		{machineType: "a2-highgpu-2g", cpus: 24, memGB: 170, gpus: 2},
+		{machineType: "m3-megamem-64", wantErr: true},
+		{machineType: "n1-weird-8", wantErr: true},
+		{machineType: "n1-standard-many", wantErr: true},
+	} {
+		_, cpus, memGB, gpus, _, err := machineShape(tc.machineType)
+		if (err != nil) != tc.wantErr || cpus != tc.cpus || memGB != tc.memGB || gpus != tc.gpus {
+			t.Errorf("machineShape(%q) = %v vCPUs, %v GB, %d GPUs, %v", tc.machineType, cpus, memGB, gpus, err)
+		}
+	}
+}
+
+func TestEverySizeGPUHasAPrice(t *testing.T) {
+	for name, gpu := range sizeGPUs {
+		if _, ok := gpuRates[gpu.accelerator]; !ok {
+			t.Errorf("gpu-%s: no price for %s", name, gpu.accelerator)
+		}
+	}
+}
+
+func TestHourlyCost(t *testing.T) {
+	props := &computepb.InstanceProperties{
+		MachineType: proto.String("n1-standard-8"),
+		GuestAccelerators: []*computepb.AcceleratorConfig{{
+			AcceleratorType:  proto.String("nvidia-tesla-t4"),
+			AcceleratorCount: proto.Int32(1),
+		}},
+		Disks: []*computepb.AttachedDisk{{
+			Boot: proto.Bool(true),
+			InitializeParams: &computepb.AttachedDiskInitializeParams{
+				DiskSizeGb: proto.Int64(730),
+				DiskType:   proto.String("pd-ssd"),
+			},
+		}},
+	}
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+	linux := 8*0.031611 + 30*0.004237 + 0.35 + 0.17
+	if got, err := hourlyCost(props, &computepb.Instance{}, false); err != nil || !near(got, linux) {
+		t.Fatalf("hourlyCost = %v, %v, want %v", got, err, linux)
+	}
+	windows := linux + 8*0.046
+	if got, _ := hourlyCost(props, &computepb.Instance{}, true); !near(got, windows) {
+		t.Fatalf("hourlyCost on Windows = %v, want %v", got, windows)
+	}
+
+	// A sized L4 VM: the g2 type bundles the GPU its insert also names.
+	sized := &computepb.Instance{
+		MachineType: proto.String("zones/us-east1-c/machineTypes/g2-standard-8"),
+		GuestAccelerators: []*computepb.AcceleratorConfig{{
+			AcceleratorType:  proto.String("zones/us-east1-c/acceleratorTypes/nvidia-l4"),
+			AcceleratorCount: proto.Int32(1),
+		}},
+		Scheduling: &computepb.Scheduling{ProvisioningModel: proto.String(computepb.Scheduling_SPOT.String())},
+	}
+	spot := (8*0.024988+32*0.002928+0.5591)*spotFraction + 0.17
+	if got, _ := hourlyCost(props, sized, false); !near(got, spot) {
+		t.Fatalf("hourlyCost of a Spot g2 VM = %v, want %v", got, spot)
+	}
+
+	props.GuestAccelerators[0].AcceleratorType = proto.String("nvidia-h100-80gb")
+	if _, err := hourlyCost(props, &computepb.Instance{}, false); err == nil {
+		t.Fatal("hourlyCost should fail for an accelerator without a price")
+	}
+}
+
+func TestCreateVMRecordsEstimatedCost(t *testing.T) {
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			InstanceTemplate: "linux-gpu-runner",
+			GPUType:          "none",
+			Platform:         "linux",
+			EstimateCost:     true,
+		},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		templatePropertiesFunc: func(context.Context, string) (*computepb.InstanceProperties, error) {
+			return &computepb.InstanceProperties{MachineType: proto.String("e2-medium")}, nil
+		},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-d", region: "us-east1"}}, nil
+	}
+	m.insertVMFunc = func(context.Context, *computepb.InsertInstanceRequest) error { return nil }
+
+	if _, err := m.CreateVM(context.Background(), "linux-test-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	vms := m.VMs()
+	if want := 0.021811 + 4*0.002923; len(vms) != 1 || math.Abs(vms[0].HourlyCost-want) > 1e-9 {
+		t.Fatalf("VMs = %+v, want one costing %v per hour", vms, want)
+	}
+}
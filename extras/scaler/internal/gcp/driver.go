@@ -0,0 +1,45 @@
+package gcp
+
+import (
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// GPUDriver is the NVIDIA driver the Windows startup script installs when
+// the VM's image has another version (see ManagerConfig.GPUDriver).
+type GPUDriver struct {
+	// Version is the driver version nvidia-smi reports, e.g. "553.24".
+	Version string
+	// URL is the driver installer, https:// or gs://.
+	URL string
+	// SHA256 is the installer's checksum; empty skips the check.
+	SHA256 string
+}
+
+// SetGPUDriver changes the driver VMs created from now on install. VMs
+// already running, or kept in the stopped pool, keep the one they booted
+// with.
+func (m *Manager) SetGPUDriver(d GPUDriver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config.GPUDriver = d
+}
+
+// driverMetadataItems returns the metadata naming the driver a VM of
+// profile installs: none for CPU-only pools or without GPUDriver.Version.
+func (m *Manager) driverMetadataItems(profile vmProfile) []*computepb.Items {
+	m.mu.Lock()
+	d := m.config.GPUDriver
+	m.mu.Unlock()
+	if d.Version == "" || profile.gpuType == "none" {
+		return nil
+	}
+	items := []*computepb.Items{
+		{Key: proto.String("gpu-driver-version"), Value: proto.String(d.Version)},
+		{Key: proto.String("gpu-driver-url"), Value: proto.String(d.URL)},
+	}
+	if d.SHA256 != "" {
+		items = append(items, &computepb.Items{Key: proto.String("gpu-driver-sha256"), Value: proto.String(d.SHA256)})
+	}
+	return items
+}
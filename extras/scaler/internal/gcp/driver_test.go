@@ -0,0 +1,41 @@
+package gcp
+
+import "testing"
+
+func TestDriverMetadata(t *testing.T) {
+	m := &Manager{}
+	gpu := vmProfile{gpuType: "nvidia-tesla-t4"}
+	if got := m.driverMetadataItems(gpu); len(got) != 0 {
+		t.Fatalf("metadata = %v, want none without a driver version", got)
+	}
+
+	m.SetGPUDriver(GPUDriver{Version: "553.24", URL: "gs://drivers/553.24.exe", SHA256: "abc"})
+	got := map[string]string{}
+	for _, item := range m.driverMetadataItems(gpu) {
+		got[item.GetKey()] = item.GetValue()
+	}
+	want := map[string]string{
+		"gpu-driver-version": "553.24",
+		"gpu-driver-url":     "gs://drivers/553.24.exe",
+		"gpu-driver-sha256":  "abc",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("metadata = %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %q, want %q", key, got[key], value)
+		}
+		if !reservedMetadataKeys[key] {
+			t.Errorf("%s is not reserved", key)
+		}
+	}
+
+	if got := m.driverMetadataItems(vmProfile{gpuType: "none"}); len(got) != 0 {
+		t.Fatalf("metadata = %v, want none for a CPU-only pool", got)
+	}
+	m.SetGPUDriver(GPUDriver{Version: "553.24", URL: "https://example.com/553.24.exe"})
+	if got := m.driverMetadataItems(gpu); len(got) != 2 {
+		t.Fatalf("metadata = %v, want no checksum", got)
+	}
+}
@@ -0,0 +1,189 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/iterator"
+)
+
+// checkPrivateEgress verifies that every subnetwork VMs are created in, per
+// instance template and region of the configured zones, is covered by a
+// Cloud NAT, without which VMs without external IPs can't reach GitHub. A
+// subnetwork without Private Google Access only gets a warning: Google API
+// calls then go out through the NAT too. It returns one error per problem.
+func (m *Manager) checkPrivateEgress(ctx context.Context) []error {
+	var regions []string
+	for _, zone := range splitZones(m.zones()) {
+		if region := zoneRegion(zone); region != "" && !slices.Contains(regions, region) {
+			regions = append(regions, region)
+		}
+	}
+
+	var errs []error
+	seen := make(map[string]bool)
+	for _, template := range m.templateNames() {
+		props, err := m.templateProperties(ctx, template)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, region := range regions {
+			link := m.primarySubnetwork(props, region)
+			if seen[link] {
+				continue
+			}
+			seen[link] = true
+			if err := m.checkSubnetworkEgress(ctx, link); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+// checkSubnetworkEgress checks the subnetwork at link (see
+// checkPrivateEgress).
+func (m *Manager) checkSubnetworkEgress(ctx context.Context, link string) error {
+	parts := strings.Split(resourcePath(link), "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "regions" || parts[4] != "subnetworks" {
+		return fmt.Errorf("cannot parse subnetwork %q", link)
+	}
+	project, region, name := parts[1], parts[3], parts[5]
+	subnet, routers, err := m.subnetworkEgress(ctx, project, region, name)
+	if err != nil {
+		return fmt.Errorf("subnetwork %s in %s: %w", name, region, err)
+	}
+	if !natCovers(routers, subnet) {
+		return fmt.Errorf("subnetwork %s in %s has no Cloud NAT, which VMs without external IPs need to reach GitHub", name, region)
+	}
+	if !subnet.GetPrivateIpGoogleAccess() {
+		slog.Warn("subnetwork has no Private Google Access; Google API traffic goes through Cloud NAT",
+			"subnetwork", name, "region", region)
+	}
+	return nil
+}
+
+// primarySubnetwork returns the subnetwork the primary interface of a VM
+// from props lands in in region (see networkInterfaces). An interface on a
+// network alone lands in the auto-mode subnetwork named after it.
+func (m *Manager) primarySubnetwork(props *computepb.InstanceProperties, region string) string {
+	if m.config.Subnetwork != "" {
+		return subnetworkURL(m.config.Project, region, m.config.Subnetwork)
+	}
+	var nic *computepb.NetworkInterface
+	if nics := props.GetNetworkInterfaces(); len(nics) > 0 {
+		nic = nics[0]
+	}
+	if m.config.Network == "" && nic.GetSubnetwork() != "" {
+		return nic.GetSubnetwork()
+	}
+	network := m.config.Network
+	if network == "" {
+		network = nic.GetNetwork()
+	}
+	if network == "" {
+		network = "default"
+	}
+	project := m.config.Project
+	if parts := strings.Split(resourcePath(networkURL(m.config.Project, network)), "/"); len(parts) > 1 {
+		project = parts[1]
+	}
+	return subnetworkURL(project, region, path.Base(network))
+}
+
+// natCovers reports whether a Cloud NAT of routers translates subnet's
+// addresses.
+func natCovers(routers []*computepb.Router, subnet *computepb.Subnetwork) bool {
+	for _, router := range routers {
+		if resourcePath(router.GetNetwork()) != resourcePath(subnet.GetNetwork()) {
+			continue
+		}
+		for _, nat := range router.GetNats() {
+			switch nat.GetSourceSubnetworkIpRangesToNat() {
+			case "ALL_SUBNETWORKS_ALL_IP_RANGES", "ALL_SUBNETWORKS_ALL_PRIMARY_IP_RANGES":
+				return true
+			case "LIST_OF_SUBNETWORKS":
+				for _, s := range nat.GetSubnetworks() {
+					if resourcePath(s.GetName()) == resourcePath(subnet.GetSelfLink()) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// subnetworkEgress returns a subnetwork and the Cloud Routers of its
+// region, which hold its NAT configs.
+func (m *Manager) subnetworkEgress(ctx context.Context, project, region, name string) (*computepb.Subnetwork, []*computepb.Router, error) {
+	if m.subnetworkEgressFunc != nil {
+		return m.subnetworkEgressFunc(ctx, project, region, name)
+	}
+	opts, err := clientOptions(ctx, m.config.ImpersonateServiceAccount)
+	if err != nil {
+		return nil, nil, err
+	}
+	subnetworksClient, err := compute.NewSubnetworksRESTClient(ctx, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating subnetworks client: %w", err)
+	}
+	defer subnetworksClient.Close()
+	routersClient, err := compute.NewRoutersRESTClient(ctx, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating routers client: %w", err)
+	}
+	defer routersClient.Close()
+
+	var subnet *computepb.Subnetwork
+	err = m.retry(ctx, "Subnetworks.Get", func(ctx context.Context) error {
+		var err error
+		subnet, err = subnetworksClient.Get(ctx, &computepb.GetSubnetworkRequest{
+			Project:    project,
+			Region:     region,
+			Subnetwork: name,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var routers []*computepb.Router
+	err = m.retry(ctx, "Routers.List", func(ctx context.Context) error {
+		routers = nil
+		it := routersClient.List(ctx, &computepb.ListRoutersRequest{
+			Project: project,
+			Region:  region,
+		})
+		for {
+			router, err := it.Next()
+			if err == iterator.Done {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			routers = append(routers, router)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return subnet, routers, nil
+}
+
+// resourcePath strips the API host and version off a self-link, so it
+// compares equal to a partial URL of the same resource.
+func resourcePath(link string) string {
+	if i := strings.Index(link, "projects/"); i >= 0 {
+		return link[i:]
+	}
+	return link
+}
@@ -0,0 +1,96 @@
+package gcp
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func newEgressTestManager(t *testing.T, cfg ManagerConfig, routers map[string][]*computepb.Router) (*Manager, *[]string) {
+	t.Helper()
+	cfg.Project = "slang-runners"
+	cfg.InstanceTemplate = "linux-gpu-runner"
+	cfg.NoExternalIP = true
+	var checked []string
+	m := &Manager{
+		config: cfg,
+		templatePropertiesFunc: func(context.Context, string) (*computepb.InstanceProperties, error) {
+			return &computepb.InstanceProperties{
+				NetworkInterfaces: []*computepb.NetworkInterface{{
+					Network: proto.String("https://www.googleapis.com/compute/v1/projects/slang-runners/global/networks/prod-ci"),
+				}},
+			}, nil
+		},
+		subnetworkEgressFunc: func(_ context.Context, project, region, name string) (*computepb.Subnetwork, []*computepb.Router, error) {
+			link := "projects/" + project + "/regions/" + region + "/subnetworks/" + name
+			checked = append(checked, link)
+			return &computepb.Subnetwork{
+				SelfLink:              proto.String("https://www.googleapis.com/compute/v1/" + link),
+				Network:               proto.String("https://www.googleapis.com/compute/v1/projects/" + project + "/global/networks/prod-ci"),
+				PrivateIpGoogleAccess: proto.Bool(true),
+			}, routers[region], nil
+		},
+	}
+	return m, &checked
+}
+
+func natRouter(ranges string, subnetworks ...string) *computepb.Router {
+	nat := &computepb.RouterNat{SourceSubnetworkIpRangesToNat: proto.String(ranges)}
+	for _, s := range subnetworks {
+		nat.Subnetworks = append(nat.Subnetworks, &computepb.RouterNatSubnetworkToNat{Name: proto.String(s)})
+	}
+	return &computepb.Router{
+		Network: proto.String("projects/slang-runners/global/networks/prod-ci"),
+		Nats:    []*computepb.RouterNat{nat},
+	}
+}
+
+func TestCheckPrivateEgressRequiresNATPerRegion(t *testing.T) {
+	m, checked := newEgressTestManager(t, ManagerConfig{Zones: "us-east1-c,us-east1-d,us-west1-a"}, map[string][]*computepb.Router{
+		"us-east1": {natRouter("ALL_SUBNETWORKS_ALL_IP_RANGES")},
+	})
+
+	errs := m.checkPrivateEgress(context.Background())
+	want := []string{
+		"projects/slang-runners/regions/us-east1/subnetworks/prod-ci",
+		"projects/slang-runners/regions/us-west1/subnetworks/prod-ci",
+	}
+	if !slices.Equal(*checked, want) {
+		t.Fatalf("checked %v, want the auto-mode subnetwork of each region once: %v", *checked, want)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "us-west1 has no Cloud NAT") {
+		t.Fatalf("errs = %v, want us-west1 reported", errs)
+	}
+}
+
+func TestCheckPrivateEgressListedSubnetworks(t *testing.T) {
+	for _, tc := range []struct {
+		listed string
+		ok     bool
+	}{
+		{"https://www.googleapis.com/compute/v1/projects/slang-runners/regions/us-east1/subnetworks/ci", true},
+		{"projects/slang-runners/regions/us-east1/subnetworks/other", false},
+	} {
+		m, _ := newEgressTestManager(t, ManagerConfig{Zones: "us-east1-c", Subnetwork: "ci"}, map[string][]*computepb.Router{
+			"us-east1": {natRouter("LIST_OF_SUBNETWORKS", tc.listed)},
+		})
+		if errs := m.checkPrivateEgress(context.Background()); (len(errs) == 0) != tc.ok {
+			t.Errorf("NAT for %s: errs = %v, want ok = %v", tc.listed, errs, tc.ok)
+		}
+	}
+}
+
+func TestCheckPrivateEgressIgnoresOtherNetworks(t *testing.T) {
+	router := natRouter("ALL_SUBNETWORKS_ALL_IP_RANGES")
+	router.Network = proto.String("projects/slang-runners/global/networks/default")
+	m, _ := newEgressTestManager(t, ManagerConfig{Zones: "us-east1-c"}, map[string][]*computepb.Router{
+		"us-east1": {router},
+	})
+	if errs := m.checkPrivateEgress(context.Background()); len(errs) != 1 {
+		t.Fatalf("errs = %v, want the other VPC's NAT not to count", errs)
+	}
+}
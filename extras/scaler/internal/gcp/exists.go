@@ -0,0 +1,60 @@
+package gcp
+
+import (
+	"context"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// isAlreadyExists reports whether an insert failed because the zone already
+// has an instance of that name, e.g. "googleapi: Error 409: The resource
+// 'projects/p/zones/z/instances/win-1' already exists, alreadyExists".
+func isAlreadyExists(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "alreadyexists") || strings.Contains(msg, "already exists")
+}
+
+// isOwnInstance reports whether the instance req would create already
+// exists as req's own: an earlier try of the same create, such as one whose
+// response was lost to a timeout, went through. The instance is recognized
+// by its jit-config, which is unique to the runner; an instance of the same
+// name with another one is a different VM.
+func (m *Manager) isOwnInstance(ctx context.Context, req *computepb.InsertInstanceRequest) bool {
+	inst, err := m.getInstance(ctx, req.GetInstanceResource().GetName(), req.GetZone())
+	if err != nil {
+		return false
+	}
+	want := metadataItem(req.GetInstanceResource().GetMetadata(), "jit-config")
+	return want != "" && metadataItem(inst.GetMetadata(), "jit-config") == want
+}
+
+func (m *Manager) getInstance(ctx context.Context, vmName, zone string) (*computepb.Instance, error) {
+	if m.getInstanceFunc != nil {
+		return m.getInstanceFunc(ctx, vmName, zone)
+	}
+	var inst *computepb.Instance
+	err := m.retry(ctx, "Get", func(ctx context.Context) error {
+		var err error
+		inst, err = m.instancesClient.Get(ctx, &computepb.GetInstanceRequest{
+			Project:  m.config.Project,
+			Zone:     zone,
+			Instance: vmName,
+		})
+		return err
+	})
+	return inst, err
+}
+
+// metadataItem returns the value of md's item key, or "" without one.
+func metadataItem(md *computepb.Metadata, key string) string {
+	for _, item := range md.GetItems() {
+		if item.GetKey() == key {
+			return item.GetValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,55 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCreateVMAdoptsInstanceOfAnEarlierInsert(t *testing.T) {
+	m := &Manager{
+		config:         ManagerConfig{Project: "test-project", InstanceTemplate: "t", GPUType: "none", BreakerThreshold: 1, BreakerBackoff: time.Minute},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-c", region: "us-east1"}, {zone: "us-east1-d", region: "us-east1"}}, nil
+	}
+	inserts := 0
+	m.insertVMFunc = func(context.Context, *computepb.InsertInstanceRequest) error {
+		inserts++
+		return errors.New("googleapi: Error 409: The resource 'projects/test-project/zones/us-east1-c/instances/runner-1' already exists, alreadyExists")
+	}
+	existingJIT := "jit-1"
+	m.getInstanceFunc = func(_ context.Context, vmName, zone string) (*computepb.Instance, error) {
+		return &computepb.Instance{Name: proto.String(vmName), Metadata: &computepb.Metadata{Items: []*computepb.Items{
+			{Key: proto.String("jit-config"), Value: proto.String(existingJIT)},
+		}}}, nil
+	}
+
+	if _, err := m.CreateVM(context.Background(), "runner-1", "jit-1"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if vm := m.vms["runner-1"]; vm == nil || vm.zone != "us-east1-c" {
+		t.Fatalf("tracked VM = %+v, want the existing one in us-east1-c", vm)
+	}
+
+	// An instance of the name with another runner's JIT config is a
+	// different VM: the create fails without trying another zone.
+	existingJIT = "someone-else"
+	inserts = 0
+	_, err := m.CreateVM(context.Background(), "runner-2", "jit-2")
+	if err == nil || inserts != 1 {
+		t.Fatalf("CreateVM = %v after %d inserts, want a failure after one", err, inserts)
+	}
+	if _, ok := m.vms["runner-2"]; ok || len(m.pendingCreates) != 0 {
+		t.Fatal("a failed create should not be tracked")
+	}
+	if len(m.Capacity().ZoneBackoffs) != 0 {
+		t.Fatal("a name collision should not count against the zone")
+	}
+}
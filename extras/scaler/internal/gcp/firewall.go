@@ -0,0 +1,333 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+)
+
+// firewallDescription marks the firewall rules ManageFirewall owns, so
+// EnsureFirewall and DeleteFirewall never touch a rule someone else named
+// alike.
+const firewallDescription = "Managed by the runner scaler; deleted by `scaler teardown`."
+
+// Firewall rule priorities: the deny rule sits just above the implied
+// allow-all egress rule (65535), so rules of the VPC's own at a lower
+// number still let runners reach more.
+const (
+	firewallAllowPriority = 1000
+	firewallDenyPriority  = 65000
+)
+
+// maxFirewallRanges is the most destination ranges put in one rule; longer
+// lists are split over numbered rules.
+const maxFirewallRanges = 256
+
+// googleAPIRanges are the private.googleapis.com and
+// restricted.googleapis.com VIPs, through which VMs reach Google APIs
+// when *.googleapis.com resolves to them.
+var googleAPIRanges = []string{"199.36.153.8/30", "199.36.153.4/30"}
+
+// iapRange is the range Identity-Aware Proxy's TCP forwarding connects
+// from.
+const iapRange = "35.235.240.0/20"
+
+// FirewallTag returns the network tag VMs get with ManageFirewall, which
+// the firewall rules target.
+func (m *Manager) FirewallTag() string {
+	return firewallTag(m.config.VMPrefix)
+}
+
+func firewallTag(vmPrefix string) string {
+	tag := "scaler-" + vmPrefix
+	// Leave room for the rule name suffixes within GCE's 63 characters.
+	if len(tag) > 45 {
+		tag = strings.TrimRight(tag[:45], "-")
+	}
+	return tag
+}
+
+// networkTags returns the network tags of a VM from template: NetworkTags
+// or the template's, plus FirewallTag with ManageFirewall.
+func (m *Manager) networkTags(ctx context.Context, template string) ([]string, error) {
+	tags := slices.Clone(m.config.NetworkTags)
+	if len(tags) == 0 {
+		props, err := m.templateProperties(ctx, template)
+		if err != nil {
+			return nil, err
+		}
+		tags = slices.Clone(props.GetTags().GetItems())
+	}
+	if m.config.ManageFirewall && !slices.Contains(tags, m.FirewallTag()) {
+		tags = append(tags, m.FirewallTag())
+	}
+	return tags, nil
+}
+
+// EnsureFirewall creates, or updates to match, the firewall rules of the
+// VMs' VPC that let VMs tagged FirewallTag reach only githubRanges and
+// Google APIs over HTTPS, and be reached only from IAP on SSH (Linux) or
+// RDP (Windows), and deletes the ones it created before that are no longer
+// needed.
+func (m *Manager) EnsureFirewall(ctx context.Context, githubRanges []string) error {
+	network, err := m.vmNetwork(ctx)
+	if err != nil {
+		return err
+	}
+	want := m.firewallRules(network, githubRanges)
+	fw, closeFn, err := m.firewallClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+	existing, err := m.ownFirewallRules(ctx, fw)
+	if err != nil {
+		return err
+	}
+
+	have := make(map[string]*computepb.Firewall, len(existing))
+	for _, rule := range existing {
+		have[rule.GetName()] = rule
+	}
+	for _, rule := range want {
+		current, ok := have[rule.GetName()]
+		delete(have, rule.GetName())
+		switch {
+		case !ok:
+			if err := fw.insert(ctx, rule); err != nil {
+				return fmt.Errorf("creating firewall rule %s: %w", rule.GetName(), err)
+			}
+			slog.Info("created firewall rule", "rule", rule.GetName(), "network", path.Base(network))
+		case firewallSpec(current) != firewallSpec(rule):
+			if err := fw.update(ctx, rule); err != nil {
+				return fmt.Errorf("updating firewall rule %s: %w", rule.GetName(), err)
+			}
+			slog.Info("updated firewall rule", "rule", rule.GetName(), "network", path.Base(network))
+		}
+	}
+	// Left over from a longer GitHub range list, or another network.
+	for name := range have {
+		if err := fw.delete(ctx, name); err != nil {
+			return fmt.Errorf("deleting firewall rule %s: %w", name, err)
+		}
+		slog.Info("deleted stale firewall rule", "rule", name)
+	}
+	return nil
+}
+
+// DeleteFirewall deletes the firewall rules EnsureFirewall created for the
+// pool cfg configures.
+func DeleteFirewall(ctx context.Context, cfg ManagerConfig) ([]string, error) {
+	m := &Manager{config: cfg}
+	fw, closeFn, err := m.firewallClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+	return m.deleteFirewall(ctx, fw)
+}
+
+func (m *Manager) deleteFirewall(ctx context.Context, fw firewallClient) ([]string, error) {
+	rules, err := m.ownFirewallRules(ctx, fw)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for _, rule := range rules {
+		if err := fw.delete(ctx, rule.GetName()); err != nil {
+			return deleted, fmt.Errorf("deleting firewall rule %s: %w", rule.GetName(), err)
+		}
+		deleted = append(deleted, rule.GetName())
+	}
+	return deleted, nil
+}
+
+// firewallRules returns the rules EnsureFirewall keeps on network.
+func (m *Manager) firewallRules(network string, githubRanges []string) []*computepb.Firewall {
+	tag := m.FirewallTag()
+	rule := func(name, direction string, priority int32) *computepb.Firewall {
+		return &computepb.Firewall{
+			Name:        proto.String(tag + "-" + name),
+			Description: proto.String(firewallDescription),
+			Network:     proto.String(network),
+			Direction:   proto.String(direction),
+			Priority:    proto.Int32(priority),
+			TargetTags:  []string{tag},
+		}
+	}
+	https := []*computepb.Allowed{{IPProtocol: proto.String("tcp"), Ports: []string{"443"}}}
+
+	deny := rule("deny-egress", "EGRESS", firewallDenyPriority)
+	deny.DestinationRanges = []string{"0.0.0.0/0"}
+	deny.Denied = []*computepb.Denied{{IPProtocol: proto.String("all")}}
+	google := rule("google-apis", "EGRESS", firewallAllowPriority)
+	google.DestinationRanges = googleAPIRanges
+	google.Allowed = https
+	port := "22"
+	if m.config.Platform == "windows" {
+		port = "3389"
+	}
+	iap := rule("iap", "INGRESS", firewallAllowPriority)
+	iap.SourceRanges = []string{iapRange}
+	iap.Allowed = []*computepb.Allowed{{IPProtocol: proto.String("tcp"), Ports: []string{port}}}
+	rules := []*computepb.Firewall{deny, google, iap}
+
+	for i, chunk := range slices.Collect(slices.Chunk(githubRanges, maxFirewallRanges)) {
+		github := rule("github-"+strconv.Itoa(i), "EGRESS", firewallAllowPriority)
+		github.DestinationRanges = chunk
+		github.Allowed = https
+		rules = append(rules, github)
+	}
+	return rules
+}
+
+// firewallSpec returns what EnsureFirewall sets of rule, in a form that
+// compares equal when nothing needs updating.
+func firewallSpec(rule *computepb.Firewall) string {
+	sorted := func(s []string) []string {
+		s = slices.Clone(s)
+		slices.Sort(s)
+		return s
+	}
+	var allowed, denied []string
+	for _, a := range rule.GetAllowed() {
+		allowed = append(allowed, a.GetIPProtocol()+":"+strings.Join(a.GetPorts(), ","))
+	}
+	for _, d := range rule.GetDenied() {
+		denied = append(denied, d.GetIPProtocol()+":"+strings.Join(d.GetPorts(), ","))
+	}
+	return fmt.Sprint(resourcePath(rule.GetNetwork()), rule.GetDirection(), rule.GetPriority(),
+		sorted(rule.GetDestinationRanges()), sorted(rule.GetSourceRanges()), sorted(rule.GetTargetTags()),
+		sorted(allowed), sorted(denied), rule.GetDisabled())
+}
+
+// ownFirewallRules returns the project's firewall rules EnsureFirewall
+// created for this pool.
+func (m *Manager) ownFirewallRules(ctx context.Context, fw firewallClient) ([]*computepb.Firewall, error) {
+	rules, err := fw.list(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing firewall rules: %w", err)
+	}
+	prefix := m.FirewallTag() + "-"
+	var own []*computepb.Firewall
+	for _, rule := range rules {
+		if strings.HasPrefix(rule.GetName(), prefix) && rule.GetDescription() == firewallDescription {
+			own = append(own, rule)
+		}
+	}
+	return own, nil
+}
+
+// vmNetwork returns the VPC the VMs' primary interfaces are on, which must
+// be the same for every template.
+func (m *Manager) vmNetwork(ctx context.Context) (string, error) {
+	if m.config.Network != "" {
+		return networkURL(m.config.Project, m.config.Network), nil
+	}
+	var network string
+	for _, template := range m.templateNames() {
+		props, err := m.templateProperties(ctx, template)
+		if err != nil {
+			return "", err
+		}
+		n := networkURL(m.config.Project, "default")
+		if nics := props.GetNetworkInterfaces(); len(nics) > 0 && nics[0].GetNetwork() != "" {
+			n = resourcePath(nics[0].GetNetwork())
+		}
+		if network != "" && n != network {
+			return "", fmt.Errorf("instance templates are on networks %s and %s; the firewall rules need one", path.Base(network), path.Base(n))
+		}
+		network = n
+	}
+	return network, nil
+}
+
+// firewallClient is the part of the Firewalls API EnsureFirewall uses.
+type firewallClient interface {
+	list(ctx context.Context) ([]*computepb.Firewall, error)
+	insert(ctx context.Context, rule *computepb.Firewall) error
+	update(ctx context.Context, rule *computepb.Firewall) error
+	delete(ctx context.Context, name string) error
+}
+
+// firewallClient returns a client for the project's firewall rules and a
+// function that closes it.
+func (m *Manager) firewallClient(ctx context.Context) (firewallClient, func(), error) {
+	if m.firewallClientFunc != nil {
+		return m.firewallClientFunc(), func() {}, nil
+	}
+	opts, err := clientOptions(ctx, m.config.ImpersonateServiceAccount)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := compute.NewFirewallsRESTClient(ctx, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating firewalls client: %w", err)
+	}
+	return &restFirewalls{m: m, client: client}, func() { client.Close() }, nil
+}
+
+type restFirewalls struct {
+	m      *Manager
+	client *compute.FirewallsClient
+}
+
+func (f *restFirewalls) list(ctx context.Context) ([]*computepb.Firewall, error) {
+	var rules []*computepb.Firewall
+	err := f.m.retry(ctx, "Firewalls.List", func(ctx context.Context) error {
+		rules = nil
+		it := f.client.List(ctx, &computepb.ListFirewallsRequest{Project: f.m.config.Project})
+		for {
+			rule, err := it.Next()
+			if err == iterator.Done {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+		}
+	})
+	return rules, err
+}
+
+func (f *restFirewalls) insert(ctx context.Context, rule *computepb.Firewall) error {
+	return f.wait(ctx, "Firewalls.Insert", func(ctx context.Context) (*compute.Operation, error) {
+		return f.client.Insert(ctx, &computepb.InsertFirewallRequest{Project: f.m.config.Project, FirewallResource: rule})
+	})
+}
+
+func (f *restFirewalls) update(ctx context.Context, rule *computepb.Firewall) error {
+	return f.wait(ctx, "Firewalls.Update", func(ctx context.Context) (*compute.Operation, error) {
+		return f.client.Update(ctx, &computepb.UpdateFirewallRequest{Project: f.m.config.Project, Firewall: rule.GetName(), FirewallResource: rule})
+	})
+}
+
+func (f *restFirewalls) delete(ctx context.Context, name string) error {
+	return f.wait(ctx, "Firewalls.Delete", func(ctx context.Context) (*compute.Operation, error) {
+		return f.client.Delete(ctx, &computepb.DeleteFirewallRequest{Project: f.m.config.Project, Firewall: name})
+	})
+}
+
+// wait makes call, retried, and waits for its operation.
+func (f *restFirewalls) wait(ctx context.Context, what string, call func(context.Context) (*compute.Operation, error)) error {
+	var op *compute.Operation
+	err := f.m.retry(ctx, what, func(ctx context.Context) error {
+		var err error
+		op, err = call(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return op.Wait(ctx)
+}
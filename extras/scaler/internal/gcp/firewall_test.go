@@ -0,0 +1,133 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+type fakeFirewalls struct {
+	rules   map[string]*computepb.Firewall
+	changes []string
+}
+
+func (f *fakeFirewalls) list(context.Context) ([]*computepb.Firewall, error) {
+	var rules []*computepb.Firewall
+	for _, rule := range f.rules {
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (f *fakeFirewalls) insert(_ context.Context, rule *computepb.Firewall) error {
+	f.rules[rule.GetName()] = rule
+	f.changes = append(f.changes, "insert "+rule.GetName())
+	return nil
+}
+
+func (f *fakeFirewalls) update(_ context.Context, rule *computepb.Firewall) error {
+	f.rules[rule.GetName()] = rule
+	f.changes = append(f.changes, "update "+rule.GetName())
+	return nil
+}
+
+func (f *fakeFirewalls) delete(_ context.Context, name string) error {
+	delete(f.rules, name)
+	f.changes = append(f.changes, "delete "+name)
+	return nil
+}
+
+func newFirewallManager(t *testing.T) (*Manager, *fakeFirewalls) {
+	t.Helper()
+	fw := &fakeFirewalls{rules: map[string]*computepb.Firewall{
+		// Someone else's rule, named like the pool's.
+		"scaler-linux-gpu-allow-pypi": {Name: proto.String("scaler-linux-gpu-allow-pypi"), Description: proto.String("hand-built")},
+	}}
+	m := &Manager{
+		config: ManagerConfig{Project: "p", InstanceTemplate: "linux-gpu", Platform: "linux", VMPrefix: "linux-gpu", ManageFirewall: true},
+		templatePropertiesFunc: func(context.Context, string) (*computepb.InstanceProperties, error) {
+			return &computepb.InstanceProperties{
+				NetworkInterfaces: []*computepb.NetworkInterface{{Network: proto.String("https://www.googleapis.com/compute/v1/projects/p/global/networks/ci")}},
+				Tags:              &computepb.Tags{Items: []string{"ci"}},
+			}, nil
+		},
+		firewallClientFunc: func() firewallClient { return fw },
+	}
+	return m, fw
+}
+
+func TestEnsureFirewall(t *testing.T) {
+	m, fw := newFirewallManager(t)
+	ranges := make([]string, maxFirewallRanges+1)
+	for i := range ranges {
+		ranges[i] = fmt.Sprintf("10.0.%d.%d/32", i/256, i%256)
+	}
+
+	if err := m.EnsureFirewall(context.Background(), ranges); err != nil {
+		t.Fatalf("EnsureFirewall: %v", err)
+	}
+	if len(fw.changes) != 5 {
+		t.Fatalf("changes = %v, want the deny, Google APIs, IAP and two GitHub rules created", fw.changes)
+	}
+	deny := fw.rules["scaler-linux-gpu-deny-egress"]
+	if deny.GetNetwork() != "projects/p/global/networks/ci" || deny.GetDirection() != "EGRESS" || !slices.Equal(deny.GetTargetTags(), []string{"scaler-linux-gpu"}) {
+		t.Fatalf("deny rule = %v", deny)
+	}
+	if got := fw.rules["scaler-linux-gpu-iap"].GetAllowed()[0].GetPorts(); !slices.Equal(got, []string{"22"}) {
+		t.Fatalf("IAP rule ports = %v, want SSH on Linux", got)
+	}
+	if got := fw.rules["scaler-linux-gpu-github-1"].GetDestinationRanges(); len(got) != 1 {
+		t.Fatalf("github-1 has %d ranges, want the one past the first rule's", len(got))
+	}
+
+	// Unchanged rules are left alone, edited ones reset and stale ones
+	// deleted.
+	fw.changes = nil
+	fw.rules["scaler-linux-gpu-google-apis"].Priority = proto.Int32(2000)
+	if err := m.EnsureFirewall(context.Background(), ranges[:2]); err != nil {
+		t.Fatalf("EnsureFirewall: %v", err)
+	}
+	slices.Sort(fw.changes)
+	want := []string{"delete scaler-linux-gpu-github-1", "update scaler-linux-gpu-github-0", "update scaler-linux-gpu-google-apis"}
+	if !slices.Equal(fw.changes, want) {
+		t.Fatalf("changes = %v, want %v", fw.changes, want)
+	}
+
+	deleted, err := m.deleteFirewall(context.Background(), fw)
+	if err != nil {
+		t.Fatalf("deleteFirewall: %v", err)
+	}
+	if len(deleted) != 4 || len(fw.rules) != 1 || fw.rules["scaler-linux-gpu-allow-pypi"] == nil {
+		t.Fatalf("deleted %v, left %d rules, want only the hand-built one kept", deleted, len(fw.rules))
+	}
+}
+
+func TestNetworkTagsAddFirewallTag(t *testing.T) {
+	m, _ := newFirewallManager(t)
+	tags, err := m.networkTags(context.Background(), "linux-gpu")
+	if err != nil {
+		t.Fatalf("networkTags: %v", err)
+	}
+	if !slices.Equal(tags, []string{"ci", "scaler-linux-gpu"}) {
+		t.Fatalf("tags = %v, want the template's and the firewall tag", tags)
+	}
+	m.config.NetworkTags = []string{"gpu"}
+	if tags, _ = m.networkTags(context.Background(), "linux-gpu"); !slices.Equal(tags, []string{"gpu", "scaler-linux-gpu"}) {
+		t.Fatalf("tags = %v, want NetworkTags and the firewall tag", tags)
+	}
+}
+
+func TestVMNetworkRejectsSeveralNetworks(t *testing.T) {
+	m, _ := newFirewallManager(t)
+	m.config.TemplateRoutes = []TemplateRoute{{InstanceTemplate: "linux-a100"}}
+	m.templatePropertiesFunc = func(_ context.Context, template string) (*computepb.InstanceProperties, error) {
+		return &computepb.InstanceProperties{NetworkInterfaces: []*computepb.NetworkInterface{{Network: proto.String("projects/p/global/networks/" + template)}}}, nil
+	}
+	if _, err := m.vmNetwork(context.Background()); err == nil {
+		t.Fatal("vmNetwork should fail for templates on different networks")
+	}
+}
@@ -0,0 +1,83 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/protobuf/proto"
+)
+
+// guestAttributeNamespace is the guest attributes namespace the startup
+// scripts report to, e.g. runner/gpu-check.
+const guestAttributeNamespace = "runner"
+
+// GPUCheck returns the result of the startup script's GPU smoke test on the
+// runner's VM (see ManagerConfig.GPUCheck): "" or "running" until it has
+// finished, "ok", "skipped" on a VM without a GPU, or "failed: " and the
+// reason.
+func (m *Manager) GPUCheck(ctx context.Context, runnerName string) (string, error) {
+	m.mu.Lock()
+	vm, ok := m.vms[runnerName]
+	m.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("no VM found for runner %q", runnerName)
+	}
+	attrs, err := m.guestAttributes(ctx, vm.vmName, vm.zone)
+	if err != nil {
+		return "", err
+	}
+	return attrs["gpu-check"], nil
+}
+
+// guestAttributeMetadataItems returns the metadata enabling guest
+// attributes, when GPUCheck, BootPhases or ShutdownScript is set, and the
+// GPU smoke test.
+func (m *Manager) guestAttributeMetadataItems() []*computepb.Items {
+	var items []*computepb.Items
+	if m.config.GPUCheck {
+		items = append(items, &computepb.Items{
+			Key:   proto.String("gpu-check"),
+			Value: proto.String("true"),
+		})
+	}
+	if !m.config.GPUCheck && !m.config.BootPhases && !m.config.ShutdownScript {
+		return items
+	}
+	if _, ok := m.config.Metadata["enable-guest-attributes"]; !ok {
+		items = append(items, &computepb.Items{
+			Key:   proto.String("enable-guest-attributes"),
+			Value: proto.String("TRUE"),
+		})
+	}
+	return items
+}
+
+// guestAttributes returns the attributes the VM's startup script reported,
+// by key. A VM that has reported none has an empty map.
+func (m *Manager) guestAttributes(ctx context.Context, vmName, zone string) (map[string]string, error) {
+	if m.getGuestAttributesFunc != nil {
+		return m.getGuestAttributesFunc(ctx, vmName, zone)
+	}
+	ga, err := m.instancesClient.GetGuestAttributes(ctx, &computepb.GetGuestAttributesInstanceRequest{
+		Project:   m.config.Project,
+		Zone:      zone,
+		Instance:  vmName,
+		QueryPath: proto.String(guestAttributeNamespace + "/"),
+	})
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading guest attributes of %s in %s: %w", vmName, zone, err)
+	}
+	attrs := make(map[string]string)
+	for _, item := range ga.GetQueryValue().GetItems() {
+		attrs[item.GetKey()] = item.GetValue()
+	}
+	return attrs, nil
+}
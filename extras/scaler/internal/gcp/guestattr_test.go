@@ -0,0 +1,56 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+func TestGPUCheckReadsGuestAttribute(t *testing.T) {
+	m := &Manager{
+		vms: map[string]*vmInfo{"a100-2": {vmName: "a100-1", zone: "us-central1-a", slot: 1}},
+		getGuestAttributesFunc: func(_ context.Context, vmName, zone string) (map[string]string, error) {
+			if vmName != "a100-1" || zone != "us-central1-a" {
+				t.Fatalf("read guest attributes of %s in %s", vmName, zone)
+			}
+			return map[string]string{"gpu-check": "ok"}, nil
+		},
+	}
+	got, err := m.GPUCheck(context.Background(), "a100-2")
+	if err != nil || got != "ok" {
+		t.Fatalf("GPUCheck = %q, %v; want ok", got, err)
+	}
+	if _, err := m.GPUCheck(context.Background(), "a100-9"); err == nil {
+		t.Fatal("GPUCheck of an unknown runner should fail")
+	}
+}
+
+func TestGPUCheckMetadata(t *testing.T) {
+	m := &Manager{config: ManagerConfig{GPUCheck: true}}
+	keys := func(items []*computepb.Items) map[string]string {
+		out := map[string]string{}
+		for _, item := range items {
+			out[item.GetKey()] = item.GetValue()
+		}
+		return out
+	}
+	got := keys(m.guestAttributeMetadataItems())
+	if got["gpu-check"] != "true" || got["enable-guest-attributes"] != "TRUE" {
+		t.Fatalf("metadata = %v", got)
+	}
+	// Metadata the operator set is not duplicated.
+	m.config.Metadata = map[string]string{"enable-guest-attributes": "TRUE"}
+	if got := keys(m.guestAttributeMetadataItems()); len(got) != 1 {
+		t.Fatalf("metadata = %v, want only gpu-check", got)
+	}
+	m.config.GPUCheck = false
+	if got := m.guestAttributeMetadataItems(); len(got) != 0 {
+		t.Fatalf("metadata = %v, want none without GPUCheck", got)
+	}
+	m.config.Metadata = nil
+	m.config.BootPhases = true
+	if got := keys(m.guestAttributeMetadataItems()); len(got) != 1 || got["enable-guest-attributes"] != "TRUE" {
+		t.Fatalf("metadata = %v, want only guest attributes enabled with BootPhases", got)
+	}
+}
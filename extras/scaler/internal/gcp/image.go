@@ -0,0 +1,158 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// imageRefreshInterval is how often the manager checks ImageFamily for a
+// newer image.
+const imageRefreshInterval = 10 * time.Minute
+
+// ImageVersion is the image new VMs boot from when ManagerConfig.ImageFamily
+// is set: the family's newest image as of CheckedAt.
+type ImageVersion struct {
+	Family string `json:"family"`
+	Name   string `json:"name"`
+	// CreatedAt is when the image was published.
+	CreatedAt time.Time `json:"created_at"`
+	CheckedAt time.Time `json:"checked_at"`
+	// OutdatedVMs counts the tracked VMs that boot from an earlier image.
+	OutdatedVMs int `json:"outdated_vms"`
+}
+
+// ParseImageFamily splits an image family, either a name in defaultProject
+// or "projects/PROJECT/global/images/family/NAME", into its project and
+// name.
+func ParseImageFamily(family, defaultProject string) (project, name string, err error) {
+	if !strings.Contains(family, "/") {
+		return defaultProject, family, nil
+	}
+	parts := strings.Split(family, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "global" || parts[3] != "images" || parts[4] != "family" || parts[1] == "" || parts[5] == "" {
+		return "", "", fmt.Errorf("image family %q is neither a name nor projects/PROJECT/global/images/family/NAME", family)
+	}
+	return parts[1], parts[5], nil
+}
+
+func (m *Manager) watchImageFamily(ctx context.Context) {
+	ticker := time.NewTicker(imageRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := m.refreshImage(ctx); err != nil {
+			slog.Warn("failed to check image family, keeping the current image", "family", m.config.ImageFamily, "image", m.Image().Name, "error", err)
+		}
+	}
+}
+
+// refreshImage reads ImageFamily's newest image, which VMs created from now
+// on boot from. When it changed, the stopped pool's VMs of earlier images
+// are deleted, since starting one would bring the old image back.
+func (m *Manager) refreshImage(ctx context.Context) error {
+	project, family, err := ParseImageFamily(m.config.ImageFamily, m.config.Project)
+	if err != nil {
+		return err
+	}
+	image, err := m.getImageFromFamily(ctx, project, family)
+	if err != nil {
+		return err
+	}
+	created, _ := time.Parse(time.RFC3339, image.GetCreationTimestamp())
+
+	m.mu.Lock()
+	previous := m.image.Name
+	m.image = ImageVersion{Family: m.config.ImageFamily, Name: image.GetName(), CreatedAt: created, CheckedAt: m.now()}
+	m.imageLink = image.GetSelfLink()
+	m.mu.Unlock()
+	if previous == image.GetName() {
+		return nil
+	}
+	if previous == "" {
+		slog.Info("booting new VMs from image family", "family", m.config.ImageFamily, "image", image.GetName())
+		return nil
+	}
+	slog.Info("new image published, new VMs will boot from it", "event", "image_updated",
+		"family", m.config.ImageFamily, "image", image.GetName(), "previous", previous)
+	m.deleteOutdatedStopped(ctx, image.GetName())
+	return nil
+}
+
+// RefreshImage reads ImageFamily's newest image now rather than at the
+// next periodic check.
+func (m *Manager) RefreshImage(ctx context.Context) error {
+	if m.config.ImageFamily == "" {
+		return errors.New("no image family configured (--image-family)")
+	}
+	return m.refreshImage(ctx)
+}
+
+// currentImage returns the name and self-link of the image new VMs boot
+// from; both are empty without ImageFamily.
+func (m *Manager) currentImage() (name, link string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.image.Name, m.imageLink
+}
+
+// Image returns the image new VMs boot from, with the number of VMs still
+// on an earlier one. Its Name is empty without ImageFamily.
+func (m *Manager) Image() ImageVersion {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	image := m.image
+	if image.Name == "" {
+		return image
+	}
+	for _, vm := range m.vms {
+		// VMs adopted from a previous run have no known image.
+		if vm.slot == 0 && vm.image != "" && vm.image != image.Name {
+			image.OutdatedVMs++
+		}
+	}
+	return image
+}
+
+// deleteOutdatedStopped deletes the stopped pool's VMs that boot from
+// another image than image.
+func (m *Manager) deleteOutdatedStopped(ctx context.Context, image string) {
+	m.mu.Lock()
+	outdated := make(map[string]string) // VM name -> zone
+	for vmName, vm := range m.stopped {
+		if !vm.inUse && vm.image != image {
+			outdated[vmName] = vm.zone
+			delete(m.stopped, vmName)
+		}
+	}
+	m.mu.Unlock()
+	for vmName, zone := range outdated {
+		slog.Info("deleting stopped VM of an earlier image", "vm", vmName, "zone", zone)
+		if err := m.deleteVMForCleanup(ctx, vmName, zone); err != nil {
+			slog.Warn("failed to delete stopped VM", "vm", vmName, "zone", zone, "error", err)
+		}
+	}
+}
+
+func (m *Manager) getImageFromFamily(ctx context.Context, project, family string) (*computepb.Image, error) {
+	if m.getImageFromFamilyFunc != nil {
+		return m.getImageFromFamilyFunc(ctx, project, family)
+	}
+	image, err := m.imagesClient.GetFromFamily(ctx, &computepb.GetFromFamilyImageRequest{
+		Project: project,
+		Family:  family,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading image family %s in %s: %w", family, project, err)
+	}
+	return image, nil
+}
@@ -0,0 +1,115 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseImageFamily(t *testing.T) {
+	for _, tc := range []struct {
+		family, project, name string
+	}{
+		{"slang-windows", "slang-runners", "slang-windows"},
+		{"projects/images-prod/global/images/family/slang-windows", "images-prod", "slang-windows"},
+	} {
+		project, name, err := ParseImageFamily(tc.family, "slang-runners")
+		if err != nil || project != tc.project || name != tc.name {
+			t.Errorf("ParseImageFamily(%q) = %q, %q, %v; want %q, %q", tc.family, project, name, err, tc.project, tc.name)
+		}
+	}
+	for _, bad := range []string{"images/slang-windows", "projects/p/global/images/slang-windows", "projects//global/images/family/x"} {
+		if _, _, err := ParseImageFamily(bad, "slang-runners"); err == nil {
+			t.Errorf("ParseImageFamily(%q) should fail", bad)
+		}
+	}
+}
+
+func TestNewImageReachesLaterCreates(t *testing.T) {
+	latest := "slang-windows-v1"
+	var deleted []string
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "slang-runners",
+			InstanceTemplate: "win-build",
+			GPUType:          "none",
+			ImageFamily:      "slang-windows",
+			StoppedPoolSize:  2,
+		},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		templatePropertiesFunc: func(context.Context, string) (*computepb.InstanceProperties, error) {
+			return &computepb.InstanceProperties{Disks: []*computepb.AttachedDisk{{
+				Boot:             proto.Bool(true),
+				InitializeParams: &computepb.AttachedDiskInitializeParams{SourceImage: proto.String("projects/slang-runners/global/images/baked-by-hand")},
+			}}}, nil
+		},
+		selectZonesFunc: func(context.Context) ([]zoneCandidate, error) {
+			return []zoneCandidate{{zone: "us-east1-c", region: "us-east1"}}, nil
+		},
+		getImageFromFamilyFunc: func(_ context.Context, project, family string) (*computepb.Image, error) {
+			if project != "slang-runners" || family != "slang-windows" {
+				t.Fatalf("read image family %s in %s", family, project)
+			}
+			return &computepb.Image{
+				Name:              proto.String(latest),
+				SelfLink:          proto.String("https://www.googleapis.com/compute/v1/projects/slang-runners/global/images/" + latest),
+				CreationTimestamp: proto.String("2026-10-01T12:00:00.000-07:00"),
+			}, nil
+		},
+		deleteVMFunc: func(_ context.Context, vmName, _ string) error {
+			deleted = append(deleted, vmName)
+			return nil
+		},
+	}
+	var images []string
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		images = append(images, r.GetInstanceResource().GetDisks()[0].GetInitializeParams().GetSourceImage())
+		return nil
+	}
+
+	if err := m.refreshImage(context.Background()); err != nil {
+		t.Fatalf("refreshImage: %v", err)
+	}
+	if _, err := m.CreateVM(context.Background(), "win-build-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if _, err := m.CreateVM(context.Background(), "win-build-2", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	m.stopped = map[string]*stoppedVM{"win-build-0": {zone: "us-east1-c", template: "win-build", image: "slang-windows-v1"}}
+
+	latest = "slang-windows-v2"
+	if err := m.refreshImage(context.Background()); err != nil {
+		t.Fatalf("refreshImage: %v", err)
+	}
+	if _, err := m.CreateVM(context.Background(), "win-build-3", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	want := []string{
+		"https://www.googleapis.com/compute/v1/projects/slang-runners/global/images/slang-windows-v1",
+		"https://www.googleapis.com/compute/v1/projects/slang-runners/global/images/slang-windows-v1",
+		"https://www.googleapis.com/compute/v1/projects/slang-runners/global/images/slang-windows-v2",
+	}
+	if len(images) != len(want) || images[0] != want[0] || images[1] != want[1] || images[2] != want[2] {
+		t.Fatalf("boot images = %v, want %v", images, want)
+	}
+
+	image := m.Image()
+	if image.Name != "slang-windows-v2" || image.OutdatedVMs != 2 || image.CreatedAt.Year() != 2026 {
+		t.Fatalf("Image() = %+v, want v2 with 2 outdated VMs", image)
+	}
+	if len(m.stopped) != 0 || len(deleted) != 1 || deleted[0] != "win-build-0" {
+		t.Fatalf("stopped pool = %v, deleted %v; want the v1 VM deleted", m.stopped, deleted)
+	}
+
+	// A VM of the earlier image is deleted rather than stopped.
+	if err := m.StopByRunnerName(context.Background(), "win-build-1"); err != nil {
+		t.Fatalf("StopByRunnerName: %v", err)
+	}
+	if len(m.stopped) != 0 || deleted[len(deleted)-1] != "win-build-1" {
+		t.Fatalf("stopped pool = %v, deleted %v; want win-build-1 deleted", m.stopped, deleted)
+	}
+}
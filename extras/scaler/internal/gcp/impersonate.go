@@ -0,0 +1,31 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+// cloudPlatformScope is the OAuth scope the compute clients ask for.
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// clientOptions returns the options the compute clients are created with:
+// none, or with serviceAccount set, the service account's credentials,
+// impersonated with the ambient ones. Those then only need
+// roles/iam.serviceAccountTokenCreator on serviceAccount, not the compute
+// roles.
+func clientOptions(ctx context.Context, serviceAccount string) ([]option.ClientOption, error) {
+	if serviceAccount == "" {
+		return nil, nil
+	}
+	ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: serviceAccount,
+		Scopes:          []string{cloudPlatformScope},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("impersonating %s: %w", serviceAccount, err)
+	}
+	return []option.ClientOption{option.WithTokenSource(ts)}, nil
+}
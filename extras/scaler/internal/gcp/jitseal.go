@@ -0,0 +1,58 @@
+package gcp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	cloudkms "google.golang.org/api/cloudkms/v1"
+)
+
+// jitConfigKMSKeyItem is the metadata item naming the Cloud KMS key the
+// VM's jit-config items are encrypted with (see JITConfigKMSKey). The
+// startup scripts decrypt them with it when it is set.
+const jitConfigKMSKeyItem = "jit-config-kms-key"
+
+// sealJITConfig returns jitConfig encrypted with JITConfigKMSKey, as the
+// base64 ciphertext KMS returns, or jitConfig as it is without a key.
+func (m *Manager) sealJITConfig(ctx context.Context, jitConfig string) (_ string, err error) {
+	if m.config.JITConfigKMSKey == "" {
+		return jitConfig, nil
+	}
+	if m.sealJITConfigFunc != nil {
+		return m.sealJITConfigFunc(ctx, jitConfig)
+	}
+	ctx, span := tracer.Start(ctx, "kms.Encrypt")
+	defer func() { endSpan(span, err) }()
+
+	var resp *cloudkms.EncryptResponse
+	err = m.retry(ctx, "Encrypt", func(ctx context.Context) error {
+		var err error
+		resp, err = m.kms.Projects.Locations.KeyRings.CryptoKeys.Encrypt(m.config.JITConfigKMSKey, &cloudkms.EncryptRequest{
+			Plaintext: base64.StdEncoding.EncodeToString([]byte(jitConfig)),
+		}).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("encrypting the JIT config with %s: %w", m.config.JITConfigKMSKey, err)
+	}
+	return resp.Ciphertext, nil
+}
+
+// sealRunners returns runners with their JIT configs sealed (see
+// sealJITConfig).
+func (m *Manager) sealRunners(ctx context.Context, runners []RunnerSlot) ([]RunnerSlot, error) {
+	if m.config.JITConfigKMSKey == "" {
+		return runners, nil
+	}
+	sealed := make([]RunnerSlot, len(runners))
+	for i, r := range runners {
+		jitConfig, err := m.sealJITConfig(ctx, r.JITConfig)
+		if err != nil {
+			return nil, err
+		}
+		sealed[i] = r
+		sealed[i].JITConfig = jitConfig
+	}
+	return sealed, nil
+}
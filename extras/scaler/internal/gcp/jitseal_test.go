@@ -0,0 +1,75 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+const testKMSKey = "projects/p/locations/global/keyRings/ci/cryptoKeys/jit-config"
+
+func fakeSeal(_ context.Context, jitConfig string) (string, error) {
+	return "sealed:" + jitConfig, nil
+}
+
+func TestCreateSlottedVMSealsJITConfigs(t *testing.T) {
+	m, reqs, _ := newSlotsManager(t)
+	m.config.JITConfigKMSKey = testKMSKey
+	m.sealJITConfigFunc = fakeSeal
+
+	if _, err := m.CreateSlottedVM(context.Background(), []RunnerSlot{
+		{Name: "a100-1", JITConfig: "jit-1"},
+		{Name: "a100-2", JITConfig: "jit-2"},
+	}, nil); err != nil {
+		t.Fatalf("CreateSlottedVM: %v", err)
+	}
+	req := (*reqs)[0]
+	for key, want := range map[string]string{
+		"jit-config":         "sealed:jit-1",
+		"jit-config-1":       "sealed:jit-2",
+		"jit-config-kms-key": testKMSKey,
+	} {
+		if got := metadataValue(req, key); got != want {
+			t.Errorf("metadata %s = %q, want %q", key, got, want)
+		}
+	}
+	if err := validateMetadata(map[string]string{jitConfigKMSKeyItem: "x"}); err == nil {
+		t.Fatal("jit-config-kms-key should be a reserved metadata key")
+	}
+}
+
+func TestCreateVMFailsWhenSealingFails(t *testing.T) {
+	m, reqs, _ := newSlotsManager(t)
+	m.config.JITConfigKMSKey = testKMSKey
+	m.sealJITConfigFunc = func(context.Context, string) (string, error) {
+		return "", errors.New("permission denied")
+	}
+
+	if _, err := m.CreateVM(context.Background(), "a100-1", "jit-1"); err == nil {
+		t.Fatal("CreateVM should fail when the JIT config can't be encrypted")
+	}
+	if len(*reqs) != 0 {
+		t.Fatalf("inserted %d VMs, want none with a plaintext JIT config", len(*reqs))
+	}
+	if m.ActiveCount() != 0 {
+		t.Fatalf("ActiveCount = %d, want the failed create released", m.ActiveCount())
+	}
+}
+
+func TestSetJITConfigSeals(t *testing.T) {
+	var got string
+	m := &Manager{
+		config:            ManagerConfig{JITConfigKMSKey: testKMSKey},
+		sealJITConfigFunc: fakeSeal,
+		setJITConfigFunc: func(_ context.Context, _, _, jitConfig string) error {
+			got = jitConfig
+			return nil
+		},
+	}
+	if err := m.setJITConfig(context.Background(), "build-1", "us-east1-c", "jit-2"); err != nil {
+		t.Fatalf("setJITConfig: %v", err)
+	}
+	if got != "sealed:jit-2" {
+		t.Fatalf("jit-config = %q, want it sealed", got)
+	}
+}
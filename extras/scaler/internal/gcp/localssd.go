@@ -0,0 +1,40 @@
+package gcp
+
+import (
+	"fmt"
+	"path"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// withLocalSSDs replaces the template's scratch disks in disks with count
+// local NVMe SSDs. GCE names them google-local-nvme-ssd-0, -1, ... under
+// /dev/disk/by-id on Linux, where the startup script finds them.
+func withLocalSSDs(disks []*computepb.AttachedDisk, zone string, count int) []*computepb.AttachedDisk {
+	out := make([]*computepb.AttachedDisk, 0, len(disks)+count)
+	for _, d := range disks {
+		if !isLocalSSD(d) {
+			out = append(out, d)
+		}
+	}
+	for i := 0; i < count; i++ {
+		out = append(out, &computepb.AttachedDisk{
+			Type:       proto.String(computepb.AttachedDisk_SCRATCH.String()),
+			Interface:  proto.String(computepb.AttachedDisk_NVME.String()),
+			AutoDelete: proto.Bool(true),
+			InitializeParams: &computepb.AttachedDiskInitializeParams{
+				DiskType: proto.String(fmt.Sprintf("zones/%s/diskTypes/local-ssd", zone)),
+			},
+		})
+	}
+	return out
+}
+
+// isLocalSSD reports whether a template disk is a local SSD. Templates do not
+// always set the disk's type to SCRATCH, but local SSDs always have the
+// local-ssd disk type.
+func isLocalSSD(d *computepb.AttachedDisk) bool {
+	return d.GetType() == computepb.AttachedDisk_SCRATCH.String() ||
+		path.Base(d.GetInitializeParams().GetDiskType()) == "local-ssd"
+}
@@ -0,0 +1,50 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+func TestCreateVMReplacesLocalSSDs(t *testing.T) {
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			InstanceTemplate: "windows-gpu-runner",
+			GPUType:          "none",
+			LocalSSDs:        2,
+		},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		templatePropertiesFunc: func(context.Context, string) (*computepb.InstanceProperties, error) {
+			// The template's boot disk and one local SSD.
+			return &computepb.InstanceProperties{Disks: testTemplateDisks()}, nil
+		},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-d", region: "us-east1"}}, nil
+	}
+	var req *computepb.InsertInstanceRequest
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		req = r
+		return nil
+	}
+
+	if _, err := m.CreateVM(context.Background(), "runner-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	disks := req.GetInstanceResource().GetDisks()
+	if len(disks) != 3 || !disks[0].GetBoot() {
+		t.Fatalf("disks = %v, want the boot disk and 2 local SSDs", disks)
+	}
+	if got := disks[0].GetInitializeParams().GetDiskSizeGb(); got != 100 {
+		t.Fatalf("boot disk size = %d, want the template's 100", got)
+	}
+	for _, d := range disks[1:] {
+		if d.GetType() != "SCRATCH" || d.GetInterface() != "NVME" ||
+			d.GetInitializeParams().GetDiskType() != "zones/us-east1-d/diskTypes/local-ssd" {
+			t.Fatalf("local SSD = %v, want an NVMe scratch disk in us-east1-d", d)
+		}
+	}
+}
@@ -5,8 +5,10 @@ package gcp
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"log/slog"
+	"path"
 	"sort"
 	"strings"
 	"sync"
@@ -14,15 +16,28 @@ import (
 
 	compute "cloud.google.com/go/compute/apiv1"
 	computepb "cloud.google.com/go/compute/apiv1/computepb"
-	"google.golang.org/api/iterator"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	orgpolicy "google.golang.org/api/orgpolicy/v2"
 	"google.golang.org/protobuf/proto"
 
-	regionspb "cloud.google.com/go/compute/apiv1/computepb"
+	"extras/scaler/internal/breaker"
+	"extras/scaler/internal/startup"
+	"extras/scaler/internal/supervise"
+	"extras/scaler/internal/vmstate"
 )
 
+// ErrNoQuota is wrapped by CreateVM's error when no configured region has GPU
+// quota left for another VM.
+var ErrNoQuota = errors.New("no GPU quota available in any configured region")
+
 const (
 	cleanupZoneScanTimeout = 30 * time.Second
 	cleanupDeleteTimeout   = 45 * time.Second
+	// deleteWaitTimeout bounds a background wait for a VM's deletion.
+	deleteWaitTimeout      = 5 * time.Minute
 	defaultCleanupInterval = 2 * time.Minute
 	// defaultOrphanGracePeriod is the time a tracked VM is allowed to sit
 	// idle (never marked busy by HandleJobStarted) before the periodic
@@ -41,17 +56,202 @@ var linuxStartupScript string
 
 // ManagerConfig holds the GCP configuration for VM management.
 type ManagerConfig struct {
-	Project          string // GCP project ID
-	Zones            string // Comma-separated preferred zones (e.g., "us-east1-c,us-west1-a")
-	InstanceTemplate string // Name of the instance template
-	GPUType          string // GPU accelerator type (e.g., "nvidia-tesla-t4")
-	Platform         string // "windows" or "linux"
-	VMPrefix         string // VM name prefix for cleanup (e.g., "win-runner" or "linux-runner")
+	Project          string   // GCP project ID
+	Zones            string   // Comma-separated preferred zones (e.g., "us-east1-c,us-west1-a")
+	Regions          []string // When set, Zones is discovered at startup: the zones in these regions offering GPUType and every route's
+	InstanceTemplate string   // Name of the instance template
+	GPUType          string   // GPU accelerator type (e.g., "nvidia-tesla-t4")
+	Platform         string   // "windows" or "linux"
+	VMPrefix         string   // VM name prefix for cleanup (e.g., "win-runner" or "linux-runner")
 	CleanupInterval  time.Duration
 	// OrphanGracePeriod is the maximum time a tracked VM may remain idle
 	// (busy == false) before being evicted as an orphan. A negative value
 	// disables eviction. Zero (unset) uses defaultOrphanGracePeriod.
 	OrphanGracePeriod time.Duration
+	// TemplateRoutes lets one scale set serve heterogeneous GPU jobs: a
+	// runner created for a job whose runs-on labels include a route's label
+	// uses that route's template instead of InstanceTemplate. Routes are
+	// matched in order; the first hit wins.
+	TemplateRoutes []TemplateRoute
+	// Metadata items and resource labels attached to every VM, e.g. for
+	// billing attribution (team=gfx, cost-center=ci). Labels set here
+	// replace the instance template's labels.
+	Metadata map[string]string
+	Labels   map[string]string
+	// BootDiskSizeGB and BootDiskType ("pd-ssd", "pd-balanced", ...)
+	// override the template's boot disk when set, so an image that outgrows
+	// the template does not require rebaking it.
+	BootDiskSizeGB int64
+	BootDiskType   string
+	// Network and Subnetwork (names or self-links) move the VM's primary
+	// interface off the template's VPC, and NetworkTags replace the
+	// template's tags, so one template can serve several VPCs.
+	Network     string
+	Subnetwork  string
+	NetworkTags []string
+	// NoExternalIP drops the template's access configs, so VMs get no
+	// public address. NewManager and Check then require Cloud NAT on the
+	// subnetworks VMs are created in, without which runners can't reach
+	// GitHub.
+	NoExternalIP bool
+	// OrgPolicyCheck validates each VM against the project's effective
+	// gcp.resourceLocations and compute.vmExternalIpAccess constraints
+	// before it is inserted, so a violation fails with the constraint
+	// named instead of GCE's error halfway through a scale-up. Zones the
+	// policy forbids are skipped. Reading the policies needs
+	// roles/orgpolicy.policyViewer.
+	OrgPolicyCheck bool
+	// AllowedMachineTypes, when set, are the machine types (path.Match
+	// patterns, e.g. "g2-standard-*") VMs may be created with, mirroring
+	// an organization's custom constraint, which the scaler can't
+	// evaluate itself.
+	AllowedMachineTypes []string
+	// ManageFirewall tags every VM with FirewallTag, for the firewall
+	// rules EnsureFirewall keeps on their VPC.
+	ManageFirewall bool
+	// ServiceAccount (email) and ServiceAccountScopes replace the template's
+	// service account, so pools sharing a template can hold different
+	// permissions.
+	ServiceAccount       string
+	ServiceAccountScopes []string
+	// StartupScript, when set, replaces the embedded startup script.
+	StartupScript *startup.Script
+	// ReuseVMs keeps a VM's startup script waiting for a new JIT config
+	// (see ReuseVM) after its runner exits, instead of shutting down.
+	ReuseVMs bool
+	// StoppedPoolSize is how many finished VMs StopByRunnerName keeps
+	// stopped for later creates to start instead of inserting a new VM.
+	StoppedPoolSize int
+	// SuspendPool suspends the pool's VMs instead of stopping them, so a
+	// resumed VM keeps its memory: installed SDKs, warm caches and the
+	// startup script waiting for a new JIT config, as with ReuseVMs.
+	SuspendPool bool
+	// CacheDisks maps a zone to the number of regional build cache disks
+	// its VMs take turns using, one VM per disk (see reserveCacheDisk).
+	// New disks get CacheDiskSizeGB and CacheDiskType ("pd-balanced" when
+	// empty).
+	CacheDisks      map[string]int
+	CacheDiskSizeGB int64
+	CacheDiskType   string
+	// LocalSSDs attaches that many local NVMe SSDs to each VM, replacing
+	// the template's, as scratch space the startup scripts mount for jobs
+	// whose temporary I/O is disk-bound. Their data does not survive a
+	// stop or suspend.
+	LocalSSDs int
+	// MIGProfile (e.g. "1g.5gb") partitions the A100 GPUs of new VMs into
+	// MIG slices of that profile, one runner per slice (see RunnerSlots).
+	// VMs with other GPUs are unaffected.
+	MIGProfile string
+	// RunnerSlots is how many runners each VM runs at once, each with its
+	// own JIT config, for VMs MIGProfile does not partition. The VM is
+	// deleted once all of them finish. 0 means 1.
+	RunnerSlots int
+	// Reservations maps a zone to a specific reservation (e.g. committed
+	// T4 capacity) that new VMs in the zone are created in while it has
+	// room. Zones with room are preferred over the rest; other VMs are
+	// created on demand.
+	Reservations map[string]string
+	// GPUCheck has the startup scripts run a GPU smoke test before the
+	// runner starts and report its result as a guest attribute (see
+	// GPUCheck). A VM whose check fails does not start its runner.
+	GPUCheck bool
+	// BootPhases has the manager read the boot phase each VM's startup
+	// script reports as a guest attribute (see BootPhases) until its
+	// runner is online, for VMs, status and boot diagnostics.
+	BootPhases bool
+	// ImageFamily (a name in Project, or
+	// "projects/PROJECT/global/images/family/NAME") replaces the boot image
+	// of every template with the family's newest image, which the manager
+	// checks for updates every imageRefreshInterval (see Image).
+	ImageFamily string
+	// GPUDriver, when its Version is set, has the Windows startup script on
+	// GPU VMs install that NVIDIA driver if the image has another one, so a
+	// driver upgrade needs neither a new image nor a new scaler build. It
+	// can be changed while running with SetGPUDriver.
+	GPUDriver GPUDriver
+	// ShutdownScript gives VMs a shutdown script that, when the VM is
+	// stopped or preempted with its runner running, stops the runner
+	// gracefully and reports why in the runner/shutdown guest attribute,
+	// which the manager reads every shutdownCheckInterval (see
+	// SetShutdownHandler).
+	ShutdownScript bool
+	// SizeLabels are the size labels (see ParseSizeLabel), e.g. gpu-a100,
+	// cpu-32 or mem-128, that jobs may put in their runs-on labels to pick
+	// the machine type and GPU of their VM, overriding the template's.
+	SizeLabels []string
+	// EstimateCost has the manager estimate each new VM's hourly price from
+	// its machine type, GPUs and disks (see hourlyCost), for VMs.
+	EstimateCost bool
+	// BillingLabels stamps each VM with the pool's billing label when it
+	// is created, and with those of its job when the job starts (see
+	// SetJobLabels).
+	BillingLabels bool
+	// BreakerThreshold is how many creates in a row may fail in a zone
+	// before the zone is skipped for BreakerBackoff, doubling after every
+	// failed probe (see closedZonesLocked). 0 never skips a zone.
+	BreakerThreshold int
+	BreakerBackoff   time.Duration
+	// StockoutCooldown is how long a zone is skipped for VMs of a GPU type
+	// after a create there ran out of resources, unless every candidate
+	// zone is (see stockedOutZonesLocked). 0 only skips it for the create
+	// that hit the stockout.
+	StockoutCooldown time.Duration
+	// PreemptionWindow is how far back the preemptions of the manager's
+	// Spot VMs count against their zone: zone selection prefers the zones
+	// whose VMs were preempted least (see survivingZonesLocked). 0 ignores
+	// preemptions.
+	PreemptionWindow time.Duration
+	// ImpersonateServiceAccount, when set, is the service account (email)
+	// whose credentials the compute clients use, impersonated with the
+	// ambient ones, so the scaler itself can run under an identity without
+	// compute roles.
+	ImpersonateServiceAccount string
+	// JITConfigKMSKey, when set, is the Cloud KMS key
+	// ("projects/P/locations/L/keyRings/R/cryptoKeys/K") the JIT configs
+	// are encrypted with before they go into instance metadata, which
+	// anyone with compute.instances.get can read. The VMs' service account
+	// decrypts them at boot and needs roles/cloudkms.cryptoKeyDecrypter on
+	// the key.
+	JITConfigKMSKey string
+	// StateFile, when set, is where the manager keeps the tracked VMs, so a
+	// restarted scaler picks up the VMs of the previous run (see
+	// restoreState).
+	StateFile string
+	// OneShot creates a manager for ReconcileOnce: it starts none of the
+	// background loops and never reads or writes StateFile, which belongs
+	// to the scaler running the pool.
+	OneShot bool
+	// RetryAttempts is how many times an Insert, Delete, List or
+	// Regions.Get call is tried while it fails on a transient error, such
+	// as a rate limit or a 503 (see retry). 0 or 1 tries once.
+	// RetryBackoff is the first wait between tries; 0 waits a second.
+	RetryAttempts int
+	RetryBackoff  time.Duration
+	// APIRateLimit caps those calls, and their retries, at that many per
+	// second, with bursts of a second's worth (see apiLimiter). 0 does not
+	// limit them.
+	APIRateLimit float64
+	// QuotaCacheTTL is how long a region's quota read serves the zone
+	// selection of later creates (see regionQuota). 0 reads it for every
+	// create, sharing only reads in flight.
+	QuotaCacheTTL time.Duration
+}
+
+// TemplateRoute maps a runs-on label to an instance template.
+type TemplateRoute struct {
+	Label            string // e.g. "GCP-A100"
+	InstanceTemplate string // e.g. "linux-gpu-runner-a100"
+	// GPUType is the accelerator the template attaches, used for the
+	// regional quota lookup. Empty inherits ManagerConfig.GPUType.
+	GPUType string
+}
+
+// vmProfile is the template and accelerator a single VM is created with,
+// and the size a job asked for.
+type vmProfile struct {
+	instanceTemplate string
+	gpuType          string
+	size             VMSize
 }
 
 type vmInfo struct {
@@ -59,12 +259,49 @@ type vmInfo struct {
 	zone      string
 	busy      bool
 	createdAt time.Time
+	// template is the instance template the VM was created from; empty for
+	// VMs adopted from a previous run.
+	template string
+	// reusedAt is when ReuseVM last handed the VM a new JIT config.
+	reusedAt time.Time
+	// jobs counts the jobs the VM has started.
+	jobs int
+	// slot is the runner's index on a VM that runs several runners (see
+	// CreateSlottedVM). Each runner has its own entry, sharing vmName.
+	slot int
+	// reservation is the reservation the VM was created in; empty for
+	// on-demand VMs.
+	reservation string
+	// image is the ImageFamily image the VM boots from; empty without
+	// ImageFamily and for adopted VMs.
+	image string
+	// phase is the last boot phase the VM reported, and bootError the
+	// reason of a failed boot (see BootPhases).
+	phase     string
+	bootError string
+	// size is the size the VM was created with (see SizeLabels).
+	size VMSize
+	// hourlyCost is the VM's estimated price per hour (see EstimateCost).
+	// A VM running several runners has it on slot 0 only.
+	hourlyCost float64
 }
 
 type zoneCandidate struct {
 	zone      string
 	region    string
 	available float64
+	// gpuType is the accelerator whose quota available refers to. Pending
+	// reservations only count against candidates of the same type.
+	gpuType string
+	// slot is set on the pending creates of a multi-runner VM's other
+	// runners, which do not reserve quota of their own.
+	slot int
+	// reservation is the reservation to create the VM in, if any.
+	reservation string
+	// vmName and startedAt are set on pending creates, which StateFile
+	// records so a restart can finish a create a crash cut short.
+	vmName    string
+	startedAt time.Time
 }
 
 // Manager handles creating and deleting GCP VMs for GitHub Actions runners.
@@ -72,13 +309,49 @@ type Manager struct {
 	config          ManagerConfig
 	instancesClient *compute.InstancesClient
 	regionsClient   *compute.RegionsClient
-	cancelCleanup   context.CancelFunc
-	cleanupPass     func(context.Context)
-	listTerminated  func(context.Context, string) ([]string, error)
-	listLive        func(context.Context, string) ([]string, error)
-	deleteVMFunc    func(context.Context, string, string) error
-	selectZonesFunc func(context.Context) ([]zoneCandidate, error)
-	insertVMFunc    func(context.Context, *computepb.InsertInstanceRequest) error
+	templatesClient *compute.InstanceTemplatesClient
+	// zoneOperationsClient lists preemptions (see checkPreemptions).
+	zoneOperationsClient *compute.ZoneOperationsClient
+	regionDisksClient    *compute.RegionDisksClient
+	reservationsClient   *compute.ReservationsClient
+	imagesClient         *compute.ImagesClient
+	// kms encrypts JIT configs; nil without JITConfigKMSKey.
+	kms           *cloudkms.Service
+	cancelCleanup context.CancelFunc
+	// cleanupIntervalCh carries SetCleanupInterval updates to the running
+	// cleanup loop's ticker.
+	cleanupIntervalCh      chan time.Duration
+	cleanupPass            func(context.Context)
+	listTerminated         func(context.Context, string) ([]string, error)
+	listLive               func(context.Context, string) ([]string, error)
+	listLiveInstancesFunc  func(context.Context, string) ([]liveInstance, error)
+	aggregatedListFunc     func(ctx context.Context, filter string) ([]compute.InstancesScopedListPair, error)
+	deleteVMFunc           func(context.Context, string, string) error
+	selectZonesFunc        func(context.Context) ([]zoneCandidate, error)
+	insertVMFunc           func(context.Context, *computepb.InsertInstanceRequest) error
+	templatePropertiesFunc func(context.Context, string) (*computepb.InstanceProperties, error)
+	regionZonesFunc        func(context.Context, string) ([]string, error)
+	acceleratorZonesFunc   func(context.Context, string) ([]string, error)
+	getZoneFunc            func(context.Context, string) error
+	getInstanceFunc        func(ctx context.Context, vmName, zone string) (*computepb.Instance, error)
+	getRegionFunc          func(ctx context.Context, region string) (*computepb.Region, error)
+	subnetworkEgressFunc   func(ctx context.Context, project, region, name string) (*computepb.Subnetwork, []*computepb.Router, error)
+	effectivePolicyFunc    func(ctx context.Context, constraint string) (*orgpolicy.GoogleCloudOrgpolicyV2Policy, error)
+	firewallClientFunc     func() firewallClient
+	setJITConfigFunc       func(ctx context.Context, vmName, zone, jitConfig string) error
+	sealJITConfigFunc      func(ctx context.Context, jitConfig string) (string, error)
+	listPreemptedFunc      func(context.Context, string) ([]preemption, error)
+	stopVMFunc             func(ctx context.Context, vmName, zone string) error
+	startVMFunc            func(ctx context.Context, vmName, zone string) error
+	suspendVMFunc          func(ctx context.Context, vmName, zone string) error
+	resumeVMFunc           func(ctx context.Context, vmName, zone string) error
+	getCacheDiskFunc       func(ctx context.Context, region, name string) (*computepb.Disk, error)
+	insertCacheDiskFunc    func(ctx context.Context, region string, disk *computepb.Disk) error
+	getReservationFunc     func(ctx context.Context, zone, name string) (*computepb.Reservation, error)
+	getGuestAttributesFunc func(ctx context.Context, vmName, zone string) (map[string]string, error)
+	getImageFromFamilyFunc func(ctx context.Context, project, family string) (*computepb.Image, error)
+	getSerialOutputFunc    func(ctx context.Context, vmName, zone string) (string, error)
+	setLabelsFunc          func(ctx context.Context, vmName, zone string, labels map[string]string) error
 	// beforeOrphanDelete is a test hook used to simulate races between the
 	// orphan candidate snapshot and the pre-delete revalidation.
 	beforeOrphanDelete func(orphanCandidate)
@@ -87,27 +360,136 @@ type Manager struct {
 	// time.Now when this is nil so existing tests that construct
 	// Manager directly keep working.
 	nowFunc func() time.Time
+	// apiLimit holds Compute API calls to APIRateLimit; nil without one.
+	apiLimit *apiLimiter
 
 	mu sync.Mutex
 	// runnerName -> vmInfo
 	vms            map[string]*vmInfo
 	pendingCreates map[string]zoneCandidate
 	nextNonGPUZone int
+	// templateCache maps instance template name -> its properties.
+	templateCache map[string]*computepb.InstanceProperties
+	// orgPolicies are the effective policies OrgPolicyCheck evaluates.
+	orgPolicies orgPolicies
+	// quotas maps region/gpu-type -> its last-read quota; stockouts and
+	// stockoutTotals record out-of-resources creates (see Capacity).
+	quotas         map[string]RegionQuota
+	stockouts      []Stockout
+	stockoutTotals map[string]int64
+	// stockoutUntil is when each zone's cooldown after a stockout ends
+	// (see StockoutCooldown).
+	stockoutUntil map[zoneGPU]time.Time
+	// zoneCreates and zonePreemptions record when VMs were created and
+	// preempted in each zone, within PreemptionWindow.
+	zoneCreates     map[string][]time.Time
+	zonePreemptions map[string][]time.Time
+	// quotaReads holds the last quota read of each region and GPU type
+	// (see regionQuota).
+	quotaReads map[regionGPU]*quotaRead
+	// zoneBreakers maps a zone to its circuit breaker (see
+	// BreakerThreshold).
+	zoneBreakers map[string]*breaker.Breaker
+	// onPreempted is called for each tracked VM found preempted.
+	onPreempted func(runnerName string, busy bool)
+	// onShutdown is called for each tracked VM whose shutdown script
+	// reported it stopping with its runner running.
+	onShutdown func(runnerName string, busy bool)
+	// stopped maps VM name -> VM kept stopped for a later create.
+	stopped map[string]*stoppedVM
+	// deleting maps VM name -> zone of the VMs whose deletion is awaited
+	// in the background (see DeleteByRunnerName); deletes counts those
+	// waits so Close can let them finish.
+	deleting map[string]string
+	deletes  sync.WaitGroup
+	// cacheDiskUsers maps build cache disk name -> the VM it is reserved
+	// for.
+	cacheDiskUsers map[string]string
+	// reservations maps zone/name -> the reservation's last-read usage.
+	reservations map[string]ReservationUsage
+	// image is ImageFamily's image new VMs boot from, and imageLink its
+	// self-link.
+	image     ImageVersion
+	imageLink string
+
+	// stateMu serializes StateFile writes; savedState is the last one.
+	stateMu       sync.Mutex
+	stateRestored bool
+	savedState    []byte
+	// abandonedCreates are the runners of the creates a previous run
+	// recorded but never finished (see AbandonedCreates).
+	abandonedCreates []string
 }
 
 // NewManager creates a new GCP VM manager.
 func NewManager(ctx context.Context, cfg ManagerConfig) (*Manager, error) {
-	instancesClient, err := compute.NewInstancesRESTClient(ctx)
+	if err := validateMetadata(cfg.Metadata); err != nil {
+		return nil, err
+	}
+	if err := ValidateLabels(cfg.Labels); err != nil {
+		return nil, err
+	}
+
+	opts, err := clientOptions(ctx, cfg.ImpersonateServiceAccount)
+	if err != nil {
+		return nil, err
+	}
+	instancesClient, err := compute.NewInstancesRESTClient(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("creating instances client: %w", err)
 	}
 
-	regionsClient, err := compute.NewRegionsRESTClient(ctx)
+	regionsClient, err := compute.NewRegionsRESTClient(ctx, opts...)
 	if err != nil {
 		instancesClient.Close()
 		return nil, fmt.Errorf("creating regions client: %w", err)
 	}
 
+	templatesClient, err := compute.NewInstanceTemplatesRESTClient(ctx, opts...)
+	if err != nil {
+		instancesClient.Close()
+		regionsClient.Close()
+		return nil, fmt.Errorf("creating instance templates client: %w", err)
+	}
+
+	zoneOperationsClient, err := compute.NewZoneOperationsRESTClient(ctx, opts...)
+	if err != nil {
+		instancesClient.Close()
+		regionsClient.Close()
+		templatesClient.Close()
+		return nil, fmt.Errorf("creating zone operations client: %w", err)
+	}
+
+	regionDisksClient, err := compute.NewRegionDisksRESTClient(ctx, opts...)
+	if err != nil {
+		instancesClient.Close()
+		regionsClient.Close()
+		templatesClient.Close()
+		zoneOperationsClient.Close()
+		return nil, fmt.Errorf("creating region disks client: %w", err)
+	}
+
+	reservationsClient, err := compute.NewReservationsRESTClient(ctx, opts...)
+	if err != nil {
+		instancesClient.Close()
+		regionsClient.Close()
+		templatesClient.Close()
+		zoneOperationsClient.Close()
+		regionDisksClient.Close()
+		return nil, fmt.Errorf("creating reservations client: %w", err)
+	}
+
+	imagesClient, err := compute.NewImagesRESTClient(ctx, opts...)
+	if err != nil {
+		instancesClient.Close()
+		regionsClient.Close()
+		templatesClient.Close()
+		zoneOperationsClient.Close()
+		regionDisksClient.Close()
+		reservationsClient.Close()
+		return nil, fmt.Errorf("creating images client: %w", err)
+	}
+
 	if cfg.GPUType == "" {
 		cfg.GPUType = "nvidia-tesla-t4"
 	}
@@ -122,22 +504,84 @@ func NewManager(ctx context.Context, cfg ManagerConfig) (*Manager, error) {
 	cleanupCtx, cancelCleanup := context.WithCancel(ctx)
 
 	mgr := &Manager{
-		config:          cfg,
-		instancesClient: instancesClient,
-		regionsClient:   regionsClient,
-		cancelCleanup:   cancelCleanup,
-		nowFunc:         time.Now,
-		vms:             make(map[string]*vmInfo),
-		pendingCreates:  make(map[string]zoneCandidate),
+		config:               cfg,
+		instancesClient:      instancesClient,
+		regionsClient:        regionsClient,
+		templatesClient:      templatesClient,
+		zoneOperationsClient: zoneOperationsClient,
+		regionDisksClient:    regionDisksClient,
+		reservationsClient:   reservationsClient,
+		imagesClient:         imagesClient,
+		cancelCleanup:        cancelCleanup,
+		cleanupIntervalCh:    make(chan time.Duration, 1),
+		nowFunc:              time.Now,
+		vms:                  make(map[string]*vmInfo),
+		pendingCreates:       make(map[string]zoneCandidate),
+		apiLimit:             newAPILimiter(cfg.APIRateLimit),
+	}
+
+	if cfg.JITConfigKMSKey != "" {
+		if mgr.kms, err = cloudkms.NewService(ctx, opts...); err != nil {
+			mgr.Close()
+			return nil, fmt.Errorf("creating KMS client: %w", err)
+		}
+	}
+	if len(cfg.Regions) > 0 {
+		if err := mgr.discoverZones(ctx); err != nil {
+			mgr.Close()
+			return nil, err
+		}
+	}
+	if cfg.NoExternalIP {
+		if errs := mgr.checkPrivateEgress(ctx); len(errs) > 0 {
+			mgr.Close()
+			return nil, errors.Join(errs...)
+		}
+	}
+	if cfg.OrgPolicyCheck {
+		if mgr.orgPolicies, err = mgr.readOrgPolicies(ctx); err != nil {
+			mgr.Close()
+			return nil, err
+		}
+	}
+	if cfg.ImageFamily != "" {
+		if err := mgr.refreshImage(ctx); err != nil {
+			mgr.Close()
+			return nil, err
+		}
+		if !cfg.OneShot {
+			supervise.Go(cleanupCtx, "gcp.image_family", mgr.watchImageFamily)
+		}
+	}
+	if cfg.OneShot {
+		mgr.config.StateFile = ""
+		return mgr, nil
+	}
+	if cfg.StateFile != "" {
+		if err := mgr.restoreState(ctx); err != nil {
+			mgr.Close()
+			return nil, err
+		}
+		supervise.Go(cleanupCtx, "gcp.state_file", mgr.watchState)
 	}
 
 	// Start background loop to clean up TERMINATED VMs.
 	// VMs self-terminate via shutdown in the startup script after the job
 	// completes. The scaler normally deletes them via HandleJobCompleted,
 	// but after a restart or if the deletion fails, they linger as
-	// TERMINATED. This loop catches those orphans.
+	// TERMINATED. This loop catches those orphans. Like the other
+	// background loops, it is restarted if it panics.
 	if cfg.VMPrefix != "" {
-		go mgr.cleanupTerminatedVMs(cleanupCtx)
+		supervise.Go(cleanupCtx, "gcp.cleanup", mgr.cleanupTerminatedVMs)
+	}
+	// Spot VMs (set up in the instance template) can be preempted mid-job;
+	// drop them from tracking as soon as GCE reports it.
+	supervise.Go(cleanupCtx, "gcp.preemptions", mgr.watchPreemptions)
+	if cfg.BootPhases {
+		supervise.Go(cleanupCtx, "gcp.boot_phases", mgr.watchBootPhases)
+	}
+	if cfg.ShutdownScript {
+		supervise.Go(cleanupCtx, "gcp.shutdowns", mgr.watchShutdowns)
 	}
 
 	return mgr, nil
@@ -159,11 +603,22 @@ func (m *Manager) now() time.Time {
 	return time.Now()
 }
 
-// Close shuts down the manager.
+// Close shuts down the manager, after the deletions still in flight.
 func (m *Manager) Close() {
 	m.cancelCleanup()
+	m.deletes.Wait()
+	if m.config.StateFile != "" {
+		if err := m.saveState(); err != nil {
+			slog.Warn("failed to save state file", "path", m.config.StateFile, "error", err)
+		}
+	}
 	m.instancesClient.Close()
 	m.regionsClient.Close()
+	m.templatesClient.Close()
+	m.zoneOperationsClient.Close()
+	m.regionDisksClient.Close()
+	m.reservationsClient.Close()
+	m.imagesClient.Close()
 }
 
 // ActiveCount returns the number of VMs currently tracked or being created.
@@ -189,15 +644,174 @@ func (m *Manager) ActiveRunnerNames() []string {
 	return names
 }
 
+// VMs returns the tracked VMs and pending creates.
+func (m *Manager) VMs() []vmstate.VM {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	vms := make([]vmstate.VM, 0, len(m.vms)+len(m.pendingCreates))
+	for name, vm := range m.vms {
+		vms = append(vms, vmstate.VM{
+			RunnerName: name, Name: vm.vmName, Location: vm.zone, Busy: vm.busy,
+			CreatedAt: vm.createdAt, ReusedAt: vm.reusedAt, Jobs: vm.jobs,
+			Reservation: vm.reservation, Phase: vm.phase, BootError: vm.bootError,
+			Image: vm.image, Size: vm.size.String(), HourlyCost: vm.hourlyCost,
+		})
+	}
+	for name, candidate := range m.pendingCreates {
+		if _, ok := m.vms[name]; !ok {
+			vms = append(vms, vmstate.VM{RunnerName: name, Location: candidate.zone, Pending: true})
+		}
+	}
+	vmstate.Sort(vms)
+	return vms
+}
+
+// Zones returns the candidate zones for new VMs.
+func (m *Manager) Zones() []string {
+	return splitZones(m.zones())
+}
+
 // MarkBusy marks a runner as busy (job started).
 func (m *Manager) MarkBusy(runnerName string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if vm, ok := m.vms[runnerName]; ok {
 		vm.busy = true
+		vm.jobs++
 	}
 }
 
+// zones returns the configured zones value. It can change at runtime via
+// SetZones, so reads go through the lock.
+func (m *Manager) zones() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.config.Zones
+}
+
+// Ping checks that the Compute API is reachable with the manager's
+// credentials by reading the first configured zone's region.
+func (m *Manager) Ping(ctx context.Context) error {
+	zones := splitZones(m.zones())
+	if len(zones) == 0 {
+		return fmt.Errorf("no zones configured")
+	}
+	_, err := m.regionsClient.Get(ctx, &computepb.GetRegionRequest{
+		Project: m.config.Project,
+		Region:  zoneRegion(zones[0]),
+	})
+	return err
+}
+
+// SetZones replaces the candidate zones used for new VMs and cleanup scans.
+// VMs already running in a dropped zone stay tracked until their job
+// completes; reconciliation lists zones from the tracked VMs themselves.
+func (m *Manager) SetZones(zonesValue string) error {
+	zones := splitZones(zonesValue)
+	if len(zones) == 0 {
+		return fmt.Errorf("no zones configured")
+	}
+	if err := validateZones(zones); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config.Zones = zonesValue
+	return nil
+}
+
+// SetCleanupInterval changes how often the cleanup loop scans for
+// terminated VMs. Non-positive values restore the default.
+func (m *Manager) SetCleanupInterval(d time.Duration) {
+	if d <= 0 {
+		d = defaultCleanupInterval
+	}
+	m.mu.Lock()
+	m.config.CleanupInterval = d
+	m.mu.Unlock()
+	if m.cleanupIntervalCh == nil {
+		return
+	}
+	// Keep only the latest value if the loop has not picked up the last one.
+	select {
+	case <-m.cleanupIntervalCh:
+	default:
+	}
+	m.cleanupIntervalCh <- d
+}
+
+// reservedMetadataKeys are set by the scaler itself on every VM.
+var reservedMetadataKeys = map[string]bool{
+	"jit-config":                  true,
+	jitConfigKMSKeyItem:           true,
+	"startup-script":              true,
+	"windows-startup-script-ps1":  true,
+	"shutdown-script":             true,
+	"windows-shutdown-script-ps1": true,
+	"expect-gpu":                  true,
+	"runner-reuse":                true,
+	"runner-slots":                true,
+	"mig-profile":                 true,
+	"gpu-check":                   true,
+	"gpu-driver-version":          true,
+	"gpu-driver-url":              true,
+	"gpu-driver-sha256":           true,
+}
+
+func validateMetadata(metadata map[string]string) error {
+	for key := range metadata {
+		if reservedMetadataKeys[key] {
+			return fmt.Errorf("metadata key %q is reserved for the scaler", key)
+		}
+	}
+	return nil
+}
+
+// ValidateLabels checks labels against GCP's resource label rules: keys
+// start with a lowercase letter, and keys and values are at most 63
+// lowercase letters, digits, underscores or dashes.
+func ValidateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if key == "" || key[0] < 'a' || key[0] > 'z' || !isLabelText(key) {
+			return fmt.Errorf("invalid GCP label key %q", key)
+		}
+		if !isLabelText(value) {
+			return fmt.Errorf("invalid GCP label value %q for key %q", value, key)
+		}
+	}
+	return nil
+}
+
+func isLabelText(s string) bool {
+	if len(s) > 63 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// extraMetadataItems returns the configured metadata as sorted items so
+// requests are deterministic.
+func (m *Manager) extraMetadataItems() []*computepb.Items {
+	keys := make([]string, 0, len(m.config.Metadata))
+	for key := range m.config.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	items := make([]*computepb.Items, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, &computepb.Items{
+			Key:   proto.String(key),
+			Value: proto.String(m.config.Metadata[key]),
+		})
+	}
+	return items
+}
+
 func splitZones(zonesValue string) []string {
 	parts := strings.Split(zonesValue, ",")
 	zones := make([]string, 0, len(parts))
@@ -237,15 +851,21 @@ func validateZones(zones []string) error {
 	return nil
 }
 
-// selectZones picks candidate zones for creating a VM. For GPU VMs, it checks
-// quota availability across regions. For non-GPU VMs (GPUType == "none"),
-// it round-robins through configured zones.
+// selectZones picks candidate zones for creating a VM with the pool's
+// default GPU type.
 func (m *Manager) selectZones(ctx context.Context) ([]zoneCandidate, error) {
+	return m.selectZonesForGPU(ctx, m.config.GPUType)
+}
+
+// selectZonesForGPU picks candidate zones for creating a VM. For GPU VMs, it
+// checks quota availability for gpuType across regions. For non-GPU VMs
+// (gpuType == "none"), it round-robins through configured zones.
+func (m *Manager) selectZonesForGPU(ctx context.Context, gpuType string) ([]zoneCandidate, error) {
 	if m.selectZonesFunc != nil {
 		return m.selectZonesFunc(ctx)
 	}
 
-	zones := splitZones(m.config.Zones)
+	zones := splitZones(m.zones())
 	if len(zones) == 0 {
 		return nil, fmt.Errorf("no zones configured")
 	}
@@ -256,7 +876,7 @@ func (m *Manager) selectZones(ctx context.Context) ([]zoneCandidate, error) {
 	// Non-GPU VMs: all configured zones are candidates. CreateVM reserves
 	// one under the manager lock, so concurrent creates still round-robin
 	// instead of all observing the same active VM count.
-	if m.config.GPUType == "none" {
+	if gpuType == "none" {
 		candidates := make([]zoneCandidate, 0, len(zones))
 		for _, zone := range zones {
 			candidates = append(candidates, zoneCandidate{zone: zone, region: zoneRegion(zone)})
@@ -286,42 +906,31 @@ func (m *Manager) selectZones(ctx context.Context) ([]zoneCandidate, error) {
 
 	var quotas []regionQuota
 
-	quotaMetric := gpuQuotaMetric(m.config.GPUType)
-
 	for region := range regionZones {
-		req := &regionspb.GetRegionRequest{
-			Project: m.config.Project,
-			Region:  region,
-		}
-		regionInfo, err := m.regionsClient.Get(ctx, req)
+		q, err := m.regionQuota(ctx, region, gpuType)
 		if err != nil {
 			slog.Warn("failed to get region info", "region", region, "error", err)
 			continue
 		}
-
-		for _, q := range regionInfo.GetQuotas() {
-			if q.GetMetric() == quotaMetric {
-				// GCP's reported usage already includes our running VMs,
-				// so we only need limit - usage (no double-subtraction).
-				available := q.GetLimit() - q.GetUsage()
-				quotas = append(quotas, regionQuota{
-					region:    region,
-					available: available,
-					order:     regionOrder[region],
-				})
-				slog.Debug("region quota",
-					"region", region,
-					"limit", q.GetLimit(),
-					"usage", q.GetUsage(),
-					"available", available,
-				)
-				break
-			}
+		if q == nil {
+			continue
 		}
+		quotas = append(quotas, regionQuota{
+			region:    region,
+			available: q.Available,
+			order:     regionOrder[region],
+		})
+		slog.Debug("region quota",
+			"region", region,
+			"limit", q.Limit,
+			"usage", q.Usage,
+			"available", q.Available,
+			"checked_at", q.CheckedAt,
+		)
 	}
 
 	if len(quotas) == 0 {
-		return nil, fmt.Errorf("no regions with %s quota found", m.config.GPUType)
+		return nil, fmt.Errorf("no regions with %s quota found", gpuType)
 	}
 
 	// Sort by available quota (most available first)
@@ -334,7 +943,7 @@ func (m *Manager) selectZones(ctx context.Context) ([]zoneCandidate, error) {
 
 	best := quotas[0]
 	if best.available <= 0 {
-		return nil, fmt.Errorf("no GPU quota available in any configured region (best: %s with %.0f available)", best.region, best.available)
+		return nil, fmt.Errorf("%w (best: %s with %.0f available)", ErrNoQuota, best.region, best.available)
 	}
 
 	candidates := make([]zoneCandidate, 0, len(zones))
@@ -351,7 +960,7 @@ func (m *Manager) selectZones(ctx context.Context) ([]zoneCandidate, error) {
 		}
 	}
 	if len(candidates) == 0 {
-		return nil, fmt.Errorf("no GPU quota available in any configured region")
+		return nil, ErrNoQuota
 	}
 	return candidates, nil
 }
@@ -393,18 +1002,92 @@ func gpuQuotaMetric(gpuType string) string {
 }
 
 // CreateVM creates a new GPU VM from the instance template, trying candidate
-// zones in quota order and falling through on zonal resource stockouts.
+// zones in quota order and falling through on zonal resource stockouts and
+// to the next region when a region's quota turns out to be exceeded.
 func (m *Manager) CreateVM(ctx context.Context, runnerName, jitConfig string) (string, error) {
-	candidates, err := m.selectZones(ctx)
+	return m.createVM(ctx, []RunnerSlot{{Name: runnerName, JITConfig: jitConfig}}, m.defaultProfile(), "")
+}
+
+// CreateVMForLabels is CreateVM for a runner created on behalf of a job with
+// the given runs-on labels. The first TemplateRoute whose label the job
+// requested selects the template; otherwise the default template is used.
+// SizeLabels among the labels then size the VM.
+func (m *Manager) CreateVMForLabels(ctx context.Context, runnerName, jitConfig string, labels []string) (string, error) {
+	return m.createVM(ctx, []RunnerSlot{{Name: runnerName, JITConfig: jitConfig}}, m.profileForLabels(labels), "")
+}
+
+func (m *Manager) defaultProfile() vmProfile {
+	return vmProfile{instanceTemplate: m.config.InstanceTemplate, gpuType: m.config.GPUType}
+}
+
+// profileForLabels resolves TemplateRoutes and SizeLabels against a job's
+// runs-on labels.
+func (m *Manager) profileForLabels(labels []string) vmProfile {
+	return withSize(m.routeProfile(labels), m.sizeForLabels(labels))
+}
+
+// routeProfile resolves TemplateRoutes against a job's runs-on labels.
+// GitHub treats labels case-insensitively, so matching does too.
+func (m *Manager) routeProfile(labels []string) vmProfile {
+	for _, route := range m.config.TemplateRoutes {
+		for _, label := range labels {
+			if !strings.EqualFold(label, route.Label) {
+				continue
+			}
+			profile := vmProfile{instanceTemplate: route.InstanceTemplate, gpuType: route.GPUType}
+			if profile.gpuType == "" {
+				profile.gpuType = m.config.GPUType
+			}
+			return profile
+		}
+	}
+	return m.defaultProfile()
+}
+
+// createVM creates the VM for runners in the best candidate zone. The VM is
+// named after the first runner. avoidZone, if set, is skipped unless it is
+// the only candidate.
+func (m *Manager) createVM(ctx context.Context, runners []RunnerSlot, profile vmProfile, avoidZone string) (_ string, err error) {
+	runnerName, jitConfig := runners[0].Name, runners[0].JITConfig
+	runnerNames := make([]string, len(runners))
+	for i, r := range runners {
+		runnerNames[i] = r.Name
+	}
+	ctx, span := tracer.Start(ctx, "gcp.CreateVM", trace.WithAttributes(
+		attribute.String("runner.name", runnerName),
+		attribute.String("gcp.instance_template", profile.instanceTemplate),
+		attribute.String("gcp.gpu_type", profile.gpuType),
+		attribute.Int("gcp.runner_slots", len(runners)),
+		attribute.String("gcp.vm_size", profile.size.String()),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if m.config.StoppedPoolSize > 0 && len(runners) == 1 {
+		if vmName := m.startStoppedVM(ctx, runnerName, jitConfig, profile, avoidZone); vmName != "" {
+			span.SetAttributes(attribute.Bool("gcp.started_stopped_vm", true))
+			return vmName, nil
+		}
+	}
+
+	zonesCtx, zonesSpan := tracer.Start(ctx, "gcp.SelectZones")
+	candidates, err := m.selectZonesForGPU(zonesCtx, profile.gpuType)
+	zonesSpan.SetAttributes(attribute.Int("gcp.candidate_zones", len(candidates)))
+	endSpan(zonesSpan, err)
 	if err != nil {
 		return "", fmt.Errorf("selecting zones: %w", err)
 	}
+	for i := range candidates {
+		candidates[i].gpuType = profile.gpuType
+	}
+	if avoidZone != "" && len(candidates) > 1 {
+		candidates = removeZoneCandidate(candidates, avoidZone)
+	}
 
 	vmName := runnerName
 
 	templateURL := fmt.Sprintf(
 		"projects/%s/global/instanceTemplates/%s",
-		m.config.Project, m.config.InstanceTemplate,
+		m.config.Project, profile.instanceTemplate,
 	)
 
 	// Select the startup script and metadata key based on platform
@@ -416,6 +1099,12 @@ func (m *Manager) CreateVM(ctx context.Context, runnerName, jitConfig string) (s
 		scriptKey = "windows-startup-script-ps1"
 		scriptContent = windowsStartupScript
 	}
+	if m.config.StartupScript != nil {
+		scriptContent, err = m.config.StartupScript.Render(runnerName)
+		if err != nil {
+			return "", err
+		}
+	}
 
 	// Tell the VM whether this pool expects a GPU, so the startup script can
 	// treat a missing accelerator as a fatal misconfiguration on GPU pools
@@ -424,18 +1113,77 @@ func (m *Manager) CreateVM(ctx context.Context, runnerName, jitConfig string) (s
 	// from the pool's own --gcp-gpu-type config rather than guessed from the
 	// VM's PCI state, so the expectation is authoritative per pool.
 	expectGPU := "true"
-	if m.config.GPUType == "none" {
+	if profile.gpuType == "none" {
 		expectGPU = "false"
 	}
 
+	// The other metadata can be read by anyone who can read the instance,
+	// so the JIT configs go in encrypted with JITConfigKMSKey.
+	if runners, err = m.sealRunners(ctx, runners); err != nil {
+		return "", err
+	}
+	jitConfig = runners[0].JITConfig
+	metadataItems := append([]*computepb.Items{
+		{
+			Key:   proto.String("jit-config"),
+			Value: proto.String(jitConfig),
+		},
+		{
+			Key:   proto.String(scriptKey),
+			Value: proto.String(scriptContent),
+		},
+		{
+			Key:   proto.String("expect-gpu"),
+			Value: proto.String(expectGPU),
+		},
+	}, m.extraMetadataItems()...)
+	if m.config.JITConfigKMSKey != "" {
+		metadataItems = append(metadataItems, &computepb.Items{
+			Key:   proto.String(jitConfigKMSKeyItem),
+			Value: proto.String(m.config.JITConfigKMSKey),
+		})
+	}
+	if m.config.ReuseVMs || m.config.SuspendPool {
+		metadataItems = append(metadataItems, &computepb.Items{
+			Key:   proto.String("runner-reuse"),
+			Value: proto.String("true"),
+		})
+	}
+	metadataItems = append(metadataItems, m.slotMetadataItems(runners, profile)...)
+	metadataItems = append(metadataItems, m.guestAttributeMetadataItems()...)
+	metadataItems = append(metadataItems, m.driverMetadataItems(profile)...)
+	metadataItems = append(metadataItems, m.shutdownMetadataItems()...)
+	image, imageLink := m.currentImage()
+	machineType, err := m.sizedMachineType(ctx, profile)
+	if err != nil {
+		return "", err
+	}
+	if candidates, err = m.allowedCandidates(ctx, profile, machineType, vmName, candidates); err != nil {
+		return "", err
+	}
+
 	var stockoutErrors []string
+	quotaFailures := 0
+	// skipReservations is set once a create in a reservation failed; the
+	// retry creates the VM on demand.
+	skipReservations := len(m.config.Reservations) == 0
 	for len(candidates) > 0 {
-		candidate, err := m.reserveCreate(runnerName, candidates)
+		pick := candidates
+		if !skipReservations {
+			if reserved := m.reservedCandidates(ctx, candidates); len(reserved) > 0 {
+				pick = reserved
+			}
+		}
+		candidate, err := m.reserveCreate(runnerNames, profile.gpuType, pick)
+		if err != nil && len(pick) != len(candidates) {
+			// The reserved zones' regions are out of quota.
+			candidate, err = m.reserveCreate(runnerNames, profile.gpuType, candidates)
+		}
 		if err != nil {
 			return "", err
 		}
 		zone := candidate.zone
-		slog.Info("selected zone", "zone", zone, "region", candidate.region, "available_gpus", candidate.available)
+		slog.Info("selected zone", "zone", zone, "region", candidate.region, "available_gpus", candidate.available, "reservation", candidate.reservation)
 
 		req := &computepb.InsertInstanceRequest{
 			Project: m.config.Project,
@@ -443,46 +1191,123 @@ func (m *Manager) CreateVM(ctx context.Context, runnerName, jitConfig string) (s
 			InstanceResource: &computepb.Instance{
 				Name: proto.String(vmName),
 				Metadata: &computepb.Metadata{
-					Items: []*computepb.Items{
-						{
-							Key:   proto.String("jit-config"),
-							Value: proto.String(jitConfig),
-						},
-						{
-							Key:   proto.String(scriptKey),
-							Value: proto.String(scriptContent),
-						},
-						{
-							Key:   proto.String("expect-gpu"),
-							Value: proto.String(expectGPU),
-						},
-					},
+					Items: metadataItems,
 				},
+				Labels: m.instanceLabels(),
 			},
 			SourceInstanceTemplate: proto.String(templateURL),
 		}
+		cacheDisk := m.reserveCacheDisk(ctx, vmName, zone)
+		if m.overridesBootDisk() || m.config.LocalSSDs > 0 || cacheDisk != nil {
+			disks, err := m.bootDisks(ctx, profile.instanceTemplate, zone, imageLink)
+			if err != nil {
+				m.releaseCreate(runnerNames...)
+				return "", err
+			}
+			if m.config.LocalSSDs > 0 {
+				disks = withLocalSSDs(disks, zone, m.config.LocalSSDs)
+			}
+			if cacheDisk != nil {
+				disks = append(disks, cacheDisk)
+				span.SetAttributes(attribute.String("gcp.cache_disk", path.Base(cacheDisk.GetSource())))
+			}
+			req.InstanceResource.Disks = disks
+		}
+		if m.overridesNetwork() {
+			nics, err := m.networkInterfaces(ctx, profile.instanceTemplate, zone)
+			if err != nil {
+				m.releaseCreate(runnerNames...)
+				return "", err
+			}
+			req.InstanceResource.NetworkInterfaces = nics
+		}
+		if err := m.applySize(ctx, req.InstanceResource, profile, machineType, zone); err != nil {
+			m.releaseCreate(runnerNames...)
+			return "", err
+		}
+		if candidate.reservation != "" {
+			req.InstanceResource.ReservationAffinity = specificReservation(candidate.reservation)
+		}
+		if len(m.config.NetworkTags) > 0 || m.config.ManageFirewall {
+			tags, err := m.networkTags(ctx, profile.instanceTemplate)
+			if err != nil {
+				m.releaseCreate(runnerNames...)
+				return "", err
+			}
+			req.InstanceResource.Tags = &computepb.Tags{Items: tags}
+		}
+		if m.overridesServiceAccount() {
+			accounts, err := m.serviceAccounts(ctx, profile.instanceTemplate)
+			if err != nil {
+				m.releaseCreate(runnerNames...)
+				return "", err
+			}
+			req.InstanceResource.ServiceAccounts = accounts
+		}
 
-		if err := m.insertVM(ctx, req); err != nil {
-			m.releaseCreate(runnerName)
+		m.saveCreateIntent(runnerName)
+		err = m.insertVM(ctx, req)
+		if isAlreadyExists(err) && m.isOwnInstance(ctx, req) {
+			slog.Info("VM already created by an earlier try of its insert, adopting it", "vm", vmName, "zone", zone)
+			err = nil
+		}
+		if err != nil {
+			m.releaseCreate(runnerNames...)
+			// The next candidate zone has its own cache disks.
+			m.releaseCacheDisk(path.Base(cacheDisk.GetSource()), vmName)
+			if isAlreadyExists(err) {
+				// Not the zone's fault, and no other zone would do: another
+				// VM has the name.
+				return "", fmt.Errorf("VM name %s is taken by another instance in %s: %w", vmName, zone, err)
+			}
+			if candidate.reservation != "" {
+				// The reservation may have filled up since it was read,
+				// or no longer match the template.
+				slog.Warn("create in reservation failed, retrying on demand", "zone", zone, "reservation", candidate.reservation, "error", err)
+				skipReservations = true
+				continue
+			}
+			m.zoneCreateFailed(zone, err)
 			if isZoneResourceExhausted(err) {
 				slog.Warn("zone resource exhausted, trying next candidate zone", "zone", zone, "error", err)
+				m.recordStockout(zone, profile.gpuType)
 				stockoutErrors = append(stockoutErrors, fmt.Sprintf("%s: %v", zone, err))
 				candidates = removeZoneCandidate(candidates, zone)
 				continue
 			}
+			if isQuotaExceeded(err) {
+				// GPU quota is regional, so the region's other zones
+				// would fail the same way. The quota read that picked
+				// this region was stale or raced another create.
+				slog.Warn("region quota exceeded, trying the next region", "zone", zone, "region", candidate.region, "error", err)
+				m.forgetQuota(candidate.region, profile.gpuType)
+				quotaFailures++
+				stockoutErrors = append(stockoutErrors, fmt.Sprintf("%s: %v", zone, err))
+				candidates = removeRegionCandidates(candidates, candidate.region)
+				continue
+			}
 			return "", err
 		}
 
-		m.completeCreate(runnerName, vmName, candidate)
+		m.completeCreate(runnerNames, vmName, profile, image, candidate, m.estimateCost(ctx, profile.instanceTemplate, req.InstanceResource))
+		m.zoneCreateSucceeded(zone)
+		m.clearStockout(zone, profile.gpuType)
+		span.SetAttributes(attribute.String("gcp.zone", zone))
 
-		slog.Info("VM created", "vm", vmName, "zone", zone)
+		slog.Info("VM created", "vm", vmName, "zone", zone, "template", profile.instanceTemplate, "reservation", candidate.reservation, "image", image)
 		return vmName, nil
 	}
 
+	if quotaFailures > 0 && quotaFailures == len(stockoutErrors) {
+		return "", fmt.Errorf("all candidate regions are out of %s quota: %s: %w", profile.gpuType, strings.Join(stockoutErrors, "; "), ErrNoQuota)
+	}
+	if quotaFailures > 0 {
+		return "", fmt.Errorf("all candidate zones are out of stock or quota for %s: %s", profile.gpuType, strings.Join(stockoutErrors, "; "))
+	}
 	if len(stockoutErrors) > 0 {
-		return "", fmt.Errorf("all candidate zones are out of stock for %s: %s", m.config.GPUType, strings.Join(stockoutErrors, "; "))
+		return "", fmt.Errorf("all candidate zones are out of stock for %s: %s", profile.gpuType, strings.Join(stockoutErrors, "; "))
 	}
-	return "", fmt.Errorf("no candidate zones available for %s", m.config.GPUType)
+	return "", fmt.Errorf("no candidate zones available for %s", profile.gpuType)
 }
 
 func removeZoneCandidate(candidates []zoneCandidate, zone string) []zoneCandidate {
@@ -495,35 +1320,68 @@ func removeZoneCandidate(candidates []zoneCandidate, zone string) []zoneCandidat
 	return filtered
 }
 
-func (m *Manager) reserveCreate(runnerName string, candidates []zoneCandidate) (zoneCandidate, error) {
+func removeRegionCandidates(candidates []zoneCandidate, region string) []zoneCandidate {
+	filtered := make([]zoneCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.region != region {
+			filtered = append(filtered, candidate)
+		}
+	}
+	return filtered
+}
+
+// reserveCreate picks a zone for a VM running runnerNames and records a
+// pending create for each runner.
+func (m *Manager) reserveCreate(runnerNames []string, gpuType string, candidates []zoneCandidate) (zoneCandidate, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, ok := m.vms[runnerName]; ok {
-		return zoneCandidate{}, fmt.Errorf("runner %q is already tracked", runnerName)
-	}
-	if _, ok := m.pendingCreates[runnerName]; ok {
-		return zoneCandidate{}, fmt.Errorf("runner %q already has a pending create", runnerName)
+	for _, runnerName := range runnerNames {
+		if _, ok := m.vms[runnerName]; ok {
+			return zoneCandidate{}, fmt.Errorf("runner %q is already tracked", runnerName)
+		}
+		if _, ok := m.pendingCreates[runnerName]; ok {
+			return zoneCandidate{}, fmt.Errorf("runner %q already has a pending create", runnerName)
+		}
 	}
 	if len(candidates) == 0 {
-		return zoneCandidate{}, fmt.Errorf("no candidate zones available for %s", m.config.GPUType)
+		return zoneCandidate{}, fmt.Errorf("no candidate zones available for %s", gpuType)
 	}
+	if candidates = m.closedZonesLocked(candidates); len(candidates) == 0 {
+		return zoneCandidate{}, fmt.Errorf("every candidate zone for %s is backing off after failed creates", gpuType)
+	}
+	candidates = m.stockedOutZonesLocked(candidates, gpuType)
+	candidates, best := m.survivingZonesLocked(candidates)
 
 	var selected zoneCandidate
-	if m.config.GPUType == "none" {
+	if gpuType == "none" {
 		// selectZones returns the full configured zone set for non-GPU
-		// pools, so this counter rotates through a stable ring.
-		selected = candidates[m.nextNonGPUZone%len(candidates)]
+		// pools, so this counter rotates through a stable ring, of the
+		// zones whose Spot VMs were preempted least.
+		selected = candidates[m.nextNonGPUZone%best]
 		m.nextNonGPUZone++
 	} else {
+		// The zones whose Spot VMs were preempted least come first, and
+		// the others only when those lack quota.
 		var err error
-		selected, err = m.selectGPUZone(candidates)
+		selected, err = m.selectGPUZone(gpuType, candidates[:best])
+		if err != nil && best < len(candidates) {
+			selected, err = m.selectGPUZone(gpuType, candidates[best:])
+		}
 		if err != nil {
 			return zoneCandidate{}, err
 		}
 	}
 
-	m.pendingCreates[runnerName] = selected
+	// In a half-open zone, this create is the probe.
+	m.zoneBreakerLocked(selected.zone).Allow(m.now(), 1)
+	for i, runnerName := range runnerNames {
+		slot := selected
+		slot.slot = i
+		slot.vmName = runnerNames[0]
+		slot.startedAt = m.now()
+		m.pendingCreates[runnerName] = slot
+	}
 	return selected, nil
 }
 
@@ -533,16 +1391,28 @@ func (m *Manager) reserveCreate(runnerName string, candidates []zoneCandidate) (
 // eligible while its reported availability exceeds the reservations already
 // pending against it. Among eligible candidates it keeps selectZones' region
 // ordering (most-available region first) but, within the chosen region,
-// spreads onto the zone with the fewest pending reservations — otherwise
-// concurrent creates would all herd onto the first zone in the region and
-// recreate the zonal stockouts this fan-out is meant to avoid. The caller must
-// hold m.mu.
-func (m *Manager) selectGPUZone(candidates []zoneCandidate) (zoneCandidate, error) {
+// spreads onto the zone with the fewest VMs, tracked or pending, earlier
+// zones first on a tie — otherwise every create would herd onto the first
+// zone in the region, and a stockout or maintenance event there would hit
+// the whole pool at once. Only pending creates of the same GPU type count
+// against a region, since each accelerator has its own quota. The caller
+// must hold m.mu.
+func (m *Manager) selectGPUZone(gpuType string, candidates []zoneCandidate) (zoneCandidate, error) {
 	pendingByRegion := make(map[string]int)
-	pendingByZone := make(map[string]int)
+	vmsByZone := make(map[string]int)
 	for _, pending := range m.pendingCreates {
-		pendingByRegion[pending.region]++
-		pendingByZone[pending.zone]++
+		if pending.slot > 0 {
+			continue
+		}
+		vmsByZone[pending.zone]++
+		if pending.gpuType == gpuType {
+			pendingByRegion[pending.region]++
+		}
+	}
+	for _, vm := range m.vms {
+		if vm.slot == 0 {
+			vmsByZone[vm.zone]++
+		}
 	}
 
 	var selected zoneCandidate
@@ -557,27 +1427,37 @@ func (m *Manager) selectGPUZone(candidates []zoneCandidate) (zoneCandidate, erro
 			// candidates are region-ordered, so once we leave the
 			// already-selected region there is no better choice.
 			return selected, nil
-		case pendingByZone[candidate.zone] < pendingByZone[selected.zone]:
+		case vmsByZone[candidate.zone] < vmsByZone[selected.zone]:
 			selected = candidate
 		}
 	}
 	if selected.zone == "" {
-		return zoneCandidate{}, fmt.Errorf("no candidate zones have unreserved %s quota", m.config.GPUType)
+		return zoneCandidate{}, fmt.Errorf("no candidate zones have unreserved %s quota: %w", gpuType, ErrNoQuota)
 	}
 	return selected, nil
 }
 
-func (m *Manager) releaseCreate(runnerName string) {
+func (m *Manager) releaseCreate(runnerNames ...string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	delete(m.pendingCreates, runnerName)
+	for _, runnerName := range runnerNames {
+		delete(m.pendingCreates, runnerName)
+	}
 }
 
-func (m *Manager) completeCreate(runnerName, vmName string, candidate zoneCandidate) {
+func (m *Manager) completeCreate(runnerNames []string, vmName string, profile vmProfile, image string, candidate zoneCandidate, hourlyCost float64) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	delete(m.pendingCreates, runnerName)
-	m.vms[runnerName] = &vmInfo{vmName: vmName, zone: candidate.zone, createdAt: m.now()}
+	now := m.now()
+	m.quotaCreatedLocked(candidate.region, profile.gpuType)
+	m.recordZoneCreateLocked(candidate.zone, now)
+	for i, runnerName := range runnerNames {
+		delete(m.pendingCreates, runnerName)
+		m.vms[runnerName] = &vmInfo{vmName: vmName, zone: candidate.zone, createdAt: now, template: profile.instanceTemplate, slot: i, reservation: candidate.reservation, image: image, size: profile.size}
+		if i == 0 {
+			m.vms[runnerName].hourlyCost = hourlyCost
+		}
+	}
 }
 
 func (m *Manager) insertVM(ctx context.Context, req *computepb.InsertInstanceRequest) error {
@@ -585,12 +1465,28 @@ func (m *Manager) insertVM(ctx context.Context, req *computepb.InsertInstanceReq
 		return m.insertVMFunc(ctx, req)
 	}
 
-	op, err := m.instancesClient.Insert(ctx, req)
+	// The request ID makes a retried insert return the first one's
+	// operation rather than create a second VM.
+	if req.RequestId == nil {
+		req.RequestId = proto.String(uuid.NewString())
+	}
+	zoneAttr := trace.WithAttributes(attribute.String("gcp.zone", req.GetZone()))
+	var op *compute.Operation
+	err := m.retry(ctx, "Insert", func(ctx context.Context) error {
+		insertCtx, span := tracer.Start(ctx, "gcp.Insert", zoneAttr)
+		var err error
+		op, err = m.instancesClient.Insert(insertCtx, req)
+		endSpan(span, err)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("inserting instance in %s: %w", req.GetZone(), err)
 	}
 
-	if err := op.Wait(ctx); err != nil {
+	waitCtx, span := tracer.Start(ctx, "gcp.WaitOperation", zoneAttr)
+	err = op.Wait(waitCtx)
+	endSpan(span, err)
+	if err != nil {
 		return fmt.Errorf("waiting for instance creation in %s: %w", req.GetZone(), err)
 	}
 
@@ -607,7 +1503,21 @@ func isZoneResourceExhausted(err error) bool {
 		strings.Contains(msg, "does not have enough resources")
 }
 
-// DeleteByRunnerName deletes the VM associated with a runner name.
+// isQuotaExceeded reports whether an insert failed on a (regional) quota,
+// e.g. "Quota 'NVIDIA_L4_GPUS' exceeded. Limit: 8.0 in region us-east1."
+func isQuotaExceeded(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "quota_exceeded") ||
+		(strings.Contains(msg, "quota '") && strings.Contains(msg, "exceeded"))
+}
+
+// DeleteByRunnerName deletes the VM associated with a runner name. A VM
+// running several runners is only deleted with its last runner. It returns
+// once GCE accepted the deletion, which is awaited in the background: a
+// delete takes about a minute, which the listener should not wait for.
 func (m *Manager) DeleteByRunnerName(ctx context.Context, runnerName string) error {
 	m.mu.Lock()
 	vm, ok := m.vms[runnerName]
@@ -618,12 +1528,76 @@ func (m *Manager) DeleteByRunnerName(ctx context.Context, runnerName string) err
 	vmName := vm.vmName
 	zone := vm.zone
 	delete(m.vms, runnerName)
+	shared := m.runsOtherSlotsLocked(vmName)
 	m.mu.Unlock()
 
-	return m.deleteVM(ctx, vmName, zone)
+	if shared {
+		slog.Info("VM kept for its other runners", "vm", vmName, "zone", zone, "runner", runnerName)
+		return nil
+	}
+	return m.deleteInBackground(ctx, vmName, zone)
 }
 
-// DeleteAll deletes all tracked VMs. Used during shutdown.
+// deleteInBackground issues the deletion of vmName and waits for it in a
+// goroutine, tracking the VM as deleting until then. A deletion that fails
+// after being issued is logged; the cleanup loop retries terminated VMs.
+func (m *Manager) deleteInBackground(ctx context.Context, vmName, zone string) error {
+	var wait func(context.Context) error
+	if hook := m.deleteVMFunc; hook != nil {
+		wait = func(ctx context.Context) error { return hook(ctx, vmName, zone) }
+	} else {
+		var err error
+		if wait, err = m.beginDelete(ctx, vmName, zone); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	if m.deleting == nil {
+		m.deleting = make(map[string]string)
+	}
+	m.deleting[vmName] = zone
+	m.mu.Unlock()
+
+	m.deletes.Add(1)
+	go func() {
+		defer m.deletes.Done()
+		defer supervise.Recover("gcp.delete_wait")
+		// The deletion was issued, so it outlives the listener's context.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), deleteWaitTimeout)
+		defer cancel()
+		err := wait(ctx)
+		m.mu.Lock()
+		delete(m.deleting, vmName)
+		m.mu.Unlock()
+		if err != nil {
+			slog.Warn("failed to delete VM", "event", "vm_delete_failed", "vm", vmName, "zone", zone, "error", err)
+		}
+	}()
+	return nil
+}
+
+// Deleting returns the names of the VMs whose deletion is still in flight.
+func (m *Manager) Deleting() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.deleting))
+	for name := range m.deleting {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isDeleting reports whether vmName's deletion is still in flight.
+func (m *Manager) isDeleting(vmName string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.deleting[vmName]
+	return ok
+}
+
+// DeleteAll deletes all tracked VMs and the stopped pool. Used during
+// shutdown.
 func (m *Manager) DeleteAll(ctx context.Context) {
 	m.mu.Lock()
 	vms := make(map[string]*vmInfo)
@@ -632,34 +1606,65 @@ func (m *Manager) DeleteAll(ctx context.Context) {
 	}
 	m.mu.Unlock()
 
+	deleted := make(map[string]bool)
 	for rn, vm := range vms {
-		if err := m.deleteVM(ctx, vm.vmName, vm.zone); err != nil {
-			slog.Error("failed to delete VM during cleanup", "vm", vm.vmName, "error", err)
+		// A VM running several runners is tracked once per runner.
+		if !deleted[vm.vmName] {
+			deleted[vm.vmName] = true
+			if err := m.deleteVM(ctx, vm.vmName, vm.zone); err != nil {
+				slog.Error("failed to delete VM during cleanup", "vm", vm.vmName, "error", err)
+			}
 		}
 		m.mu.Lock()
 		delete(m.vms, rn)
 		m.mu.Unlock()
 	}
+	m.deleteStopped(ctx)
 }
 
 func (m *Manager) deleteVM(ctx context.Context, vmName, zone string) error {
-	req := &computepb.DeleteInstanceRequest{
-		Project:  m.config.Project,
-		Zone:     zone,
-		Instance: vmName,
-	}
-
-	op, err := m.instancesClient.Delete(ctx, req)
+	wait, err := m.beginDelete(ctx, vmName, zone)
 	if err != nil {
-		return fmt.Errorf("deleting instance %s in %s: %w", vmName, zone, err)
+		return err
 	}
+	return wait(ctx)
+}
 
-	if err := op.Wait(ctx); err != nil {
-		return fmt.Errorf("waiting for instance deletion %s in %s: %w", vmName, zone, err)
+// beginDelete issues the deletion of vmName and returns a func that waits
+// for it to finish.
+func (m *Manager) beginDelete(ctx context.Context, vmName, zone string) (func(context.Context) error, error) {
+	attrs := trace.WithAttributes(
+		attribute.String("gcp.instance", vmName),
+		attribute.String("gcp.zone", zone),
+	)
+	req := &computepb.DeleteInstanceRequest{
+		Project:   m.config.Project,
+		Zone:      zone,
+		Instance:  vmName,
+		RequestId: proto.String(uuid.NewString()),
+	}
+	var op *compute.Operation
+	err := m.retry(ctx, "Delete", func(ctx context.Context) error {
+		deleteCtx, span := tracer.Start(ctx, "gcp.DeleteVM", attrs)
+		var err error
+		op, err = m.instancesClient.Delete(deleteCtx, req)
+		endSpan(span, err)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("deleting instance %s in %s: %w", vmName, zone, err)
 	}
 
-	slog.Info("VM deleted", "vm", vmName, "zone", zone)
-	return nil
+	return func(ctx context.Context) error {
+		waitCtx, span := tracer.Start(ctx, "gcp.WaitOperation", attrs)
+		err := op.Wait(waitCtx)
+		endSpan(span, err)
+		if err != nil {
+			return fmt.Errorf("waiting for instance deletion %s in %s: %w", vmName, zone, err)
+		}
+		slog.Info("VM deleted", "event", "vm_deleted", "vm", vmName, "zone", zone)
+		return nil
+	}, nil
 }
 
 // cleanupTerminatedVMs periodically scans all configured zones for VMs
@@ -667,8 +1672,27 @@ func (m *Manager) deleteVM(ctx context.Context, vmName, zone string) error {
 // This catches VMs that self-terminated (via shutdown in the startup script)
 // but weren't cleaned up by the scaler (e.g., after a restart).
 func (m *Manager) cleanupTerminatedVMs(ctx context.Context) {
-	ticker := time.NewTicker(m.config.CleanupInterval)
+	m.mu.Lock()
+	interval := m.config.CleanupInterval
+	m.mu.Unlock()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	// Stop watching for interval changes with the loop, which is restarted
+	// with a watcher of its own if it panics.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case d := <-m.cleanupIntervalCh:
+				ticker.Reset(d)
+				slog.Info("cleanup interval changed", "interval", d)
+			}
+		}
+	}()
 
 	m.runCleanupLoop(ctx, ticker.C)
 }
@@ -705,39 +1729,19 @@ func (m *Manager) removeTrackedVMByVMName(vmName string) {
 	defer m.mu.Unlock()
 	for runnerName, vm := range m.vms {
 		if runnerName == vmName || vm.vmName == vmName {
+			// Keep looking: a VM can run several runners.
 			delete(m.vms, runnerName)
-			return
-		}
-	}
-}
-
-func (m *Manager) listVMNamesByFilter(ctx context.Context, zone, filter string) ([]string, error) {
-	req := &computepb.ListInstancesRequest{
-		Project: m.config.Project,
-		Zone:    zone,
-		Filter:  proto.String(filter),
-	}
-
-	it := m.instancesClient.List(ctx, req)
-	var names []string
-	for {
-		instance, err := it.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return names, err
 		}
-		names = append(names, instance.GetName())
 	}
-	return names, nil
 }
 
-func (m *Manager) listTerminatedVMNames(ctx context.Context, zone string) ([]string, error) {
-	if m.listTerminated != nil {
-		return m.listTerminated(ctx, zone)
+// terminatedFilter matches the VMs the cleanup pass deletes.
+func (m *Manager) terminatedFilter() string {
+	if m.config.SuspendPool {
+		// A previous process may have left its pool suspended.
+		return fmt.Sprintf("name=%s-* AND (status=TERMINATED OR status=SUSPENDED)", m.config.VMPrefix)
 	}
-	return m.listVMNamesByFilter(ctx, zone, cleanupFilter(m.config.VMPrefix))
+	return cleanupFilter(m.config.VMPrefix)
 }
 
 func liveFilter(vmPrefix string) string {
@@ -753,37 +1757,6 @@ func isLiveStatus(status string) bool {
 	}
 }
 
-func (m *Manager) listLiveVMNames(ctx context.Context, zone string) ([]string, error) {
-	if m.listLive != nil {
-		return m.listLive(ctx, zone)
-	}
-	if m.instancesClient == nil {
-		return nil, nil
-	}
-
-	req := &computepb.ListInstancesRequest{
-		Project: m.config.Project,
-		Zone:    zone,
-		Filter:  proto.String(liveFilter(m.config.VMPrefix)),
-	}
-
-	it := m.instancesClient.List(ctx, req)
-	var names []string
-	for {
-		instance, err := it.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return names, err
-		}
-		if isLiveStatus(instance.GetStatus()) {
-			names = append(names, instance.GetName())
-		}
-	}
-	return names, nil
-}
-
 func (m *Manager) deleteVMForCleanup(ctx context.Context, vmName, zone string) error {
 	if m.deleteVMFunc != nil {
 		return m.deleteVMFunc(ctx, vmName, zone)
@@ -792,29 +1765,40 @@ func (m *Manager) deleteVMForCleanup(ctx context.Context, vmName, zone string) e
 }
 
 func (m *Manager) doCleanupTerminatedVMs(ctx context.Context) {
-	zones := strings.Split(m.config.Zones, ",")
+	m.deleteTerminatedVMs(ctx)
+
+	// Reconcile: remove tracked VMs that no longer exist as live instances.
+	// This prevents ActiveCount() from drifting above reality, which would
+	// cause the scaler to stop creating new VMs.
+	m.reconcileTrackedVMs(ctx)
+
+	// Evict orphans: tear down tracked VMs that are alive in GCP but have
+	// never been dispatched a job. Catches the #11115 wedge where a
+	// runner registers with empty labels and never goes busy, leaving
+	// ActiveCount > 0 forever and blocking drain.
+	m.evictStaleOrphans(ctx)
+}
+
+// deleteTerminatedVMs deletes the VMs terminatedVMsByZone finds, except
+// those of the stopped pool or already being deleted, and stops tracking
+// them. It returns the number deleted.
+func (m *Manager) deleteTerminatedVMs(ctx context.Context) int {
+	byZone := m.terminatedVMsByZone(ctx)
+	zones := make([]string, 0, len(byZone))
+	for zone := range byZone {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
 	deletedCount := 0
 
 	for _, zone := range zones {
-		zone = strings.TrimSpace(zone)
-		if zone == "" {
-			continue
-		}
-
-		listCtx, cancelList := context.WithTimeout(ctx, cleanupZoneScanTimeout)
-		names, err := m.listTerminatedVMNames(listCtx, zone)
-		cancelList()
-		if err != nil {
-			slog.Warn("failed to list instances for cleanup", "zone", zone, "error", err)
-			if len(names) == 0 {
+		for _, name := range byZone[zone] {
+			if m.isStopped(name) || m.isDeleting(name) {
 				continue
 			}
-		}
-
-		for _, name := range names {
 			slog.Info("cleaning up terminated VM", "vm", name, "zone", zone)
 			deleteCtx, cancelDelete := context.WithTimeout(ctx, cleanupDeleteTimeout)
-			err = m.deleteVMForCleanup(deleteCtx, name, zone)
+			err := m.deleteVMForCleanup(deleteCtx, name, zone)
 			cancelDelete()
 			if err != nil {
 				slog.Warn("failed to delete terminated VM", "vm", name, "zone", zone, "error", err)
@@ -828,17 +1812,7 @@ func (m *Manager) doCleanupTerminatedVMs(ctx context.Context) {
 	}
 
 	slog.Info("terminated VM cleanup pass completed", "terminated_vms_deleted", deletedCount)
-
-	// Reconcile: remove tracked VMs that no longer exist as live instances.
-	// This prevents ActiveCount() from drifting above reality, which would
-	// cause the scaler to stop creating new VMs.
-	m.reconcileTrackedVMs(ctx)
-
-	// Evict orphans: tear down tracked VMs that are alive in GCP but have
-	// never been dispatched a job. Catches the #11115 wedge where a
-	// runner registers with empty labels and never goes busy, leaving
-	// ActiveCount > 0 forever and blocking drain.
-	m.evictStaleOrphans(ctx)
+	return deletedCount
 }
 
 // reconcileTrackedVMs checks all tracked VMs against actual GCP instance state
@@ -846,7 +1820,7 @@ func (m *Manager) doCleanupTerminatedVMs(ctx context.Context) {
 // remain in PROVISIONING or STAGING long enough for cleanup to run, so those
 // states must stay tracked just like RUNNING instances.
 func (m *Manager) reconcileTrackedVMs(ctx context.Context) {
-	if m.instancesClient == nil && m.listLive == nil {
+	if m.instancesClient == nil && m.listLive == nil && m.aggregatedListFunc == nil {
 		return // No GCP client (test mode), skip reconciliation
 	}
 
@@ -867,21 +1841,7 @@ func (m *Manager) reconcileTrackedVMs(ctx context.Context) {
 	m.mu.Unlock()
 
 	// Collect all live VM names across zones.
-	liveVMs := make(map[string]bool)
-	failedZones := make(map[string]bool)
-	for zone := range zoneVMs {
-		listCtx, cancel := context.WithTimeout(ctx, cleanupZoneScanTimeout)
-		names, err := m.listLiveVMNames(listCtx, zone)
-		cancel()
-		if err != nil {
-			slog.Warn("reconcile: failed to list live VMs", "zone", zone, "error", err)
-			failedZones[zone] = true
-			continue
-		}
-		for _, name := range names {
-			liveVMs[name] = true
-		}
-	}
+	liveVMs, failedZones := m.liveVMsInZones(ctx, zoneVMs)
 
 	// Remove tracked entries whose VMs are no longer live.
 	// Skip VMs in zones where the list call failed.
@@ -921,6 +1881,24 @@ type orphanCandidate struct {
 	age        time.Duration
 }
 
+// wholeVMCandidates keeps one candidate per VM whose tracked runners,
+// counted in runners by VM name, are all candidates: a VM running several
+// runners is only evicted once every runner left on it is an orphan.
+func wholeVMCandidates(candidates []orphanCandidate, runners map[string]int) []orphanCandidate {
+	idle := make(map[string]int)
+	for _, c := range candidates {
+		idle[c.vmName]++
+	}
+	whole := candidates[:0]
+	for _, c := range candidates {
+		if idle[c.vmName] == runners[c.vmName] {
+			whole = append(whole, c)
+			idle[c.vmName] = -1
+		}
+	}
+	return whole
+}
+
 func (m *Manager) orphanCandidateStillIdle(c orphanCandidate) bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -935,6 +1913,12 @@ func (m *Manager) removeOrphanCandidateIfIdle(c orphanCandidate) bool {
 
 	if vm, ok := m.vms[c.runnerName]; ok && !vm.busy && vm.vmName == c.vmName && vm.zone == c.zone {
 		delete(m.vms, c.runnerName)
+		// The VM's other runners went with it.
+		for runnerName, vm := range m.vms {
+			if !vm.busy && vm.vmName == c.vmName && vm.zone == c.zone {
+				delete(m.vms, runnerName)
+			}
+		}
 		return true
 	}
 	return false
@@ -966,15 +1950,21 @@ func (m *Manager) removeOrphanCandidateIfIdle(c orphanCandidate) bool {
 // For the orphan scenario this targets (runner registered with empty
 // labels, never receives a job, MarkBusy never fires) this race is
 // essentially unreachable.
-func (m *Manager) evictStaleOrphans(ctx context.Context) {
+//
+// It returns the number of VMs deleted.
+func (m *Manager) evictStaleOrphans(ctx context.Context) int {
 	grace := m.config.OrphanGracePeriod
 	if grace <= 0 {
-		return
+		return 0
 	}
 
 	now := m.now()
 	m.mu.Lock()
 	candidates := make([]orphanCandidate, 0)
+	runners := make(map[string]int)
+	for _, vm := range m.vms {
+		runners[vm.vmName]++
+	}
 	for runnerName, vm := range m.vms {
 		if vm.busy {
 			continue
@@ -984,7 +1974,12 @@ func (m *Manager) evictStaleOrphans(ctx context.Context) {
 		if vm.createdAt.IsZero() {
 			continue
 		}
-		age := now.Sub(vm.createdAt)
+		// A reused VM is idle since it got its new JIT config.
+		idleSince := vm.createdAt
+		if vm.reusedAt.After(idleSince) {
+			idleSince = vm.reusedAt
+		}
+		age := now.Sub(idleSince)
 		if age < grace {
 			continue
 		}
@@ -995,6 +1990,7 @@ func (m *Manager) evictStaleOrphans(ctx context.Context) {
 			age:        age,
 		})
 	}
+	candidates = wholeVMCandidates(candidates, runners)
 	m.mu.Unlock()
 
 	deleted := 0
@@ -1014,6 +2010,7 @@ func (m *Manager) evictStaleOrphans(ctx context.Context) {
 		}
 
 		slog.Warn("evicting orphan VM: tracked but never went busy",
+			"event", "vm_evicted",
 			"runner", c.runnerName,
 			"vm", c.vmName,
 			"zone", c.zone,
@@ -1044,4 +2041,5 @@ func (m *Manager) evictStaleOrphans(ctx context.Context) {
 			"tracked_after", m.ActiveCount(),
 		)
 	}
+	return deleted
 }
@@ -11,6 +11,8 @@ import (
 	"time"
 
 	computepb "cloud.google.com/go/compute/apiv1/computepb"
+
+	"extras/scaler/internal/startup"
 )
 
 func TestCleanupFilter(t *testing.T) {
@@ -447,6 +449,53 @@ func TestCreateVMAllCandidateZonesStockout(t *testing.T) {
 	}
 }
 
+func TestCreateVMFailsOverToNextRegionOnQuotaExceeded(t *testing.T) {
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			InstanceTemplate: "linux-gpu-runner-sm80plus-l4",
+			GPUType:          "nvidia-l4",
+			Platform:         "linux",
+		},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{
+			{zone: "us-east1-d", region: "us-east1", available: 16},
+			{zone: "us-east1-b", region: "us-east1", available: 16},
+			{zone: "us-central1-a", region: "us-central1", available: 8},
+		}, nil
+	}
+
+	var attempts []string
+	m.insertVMFunc = func(_ context.Context, req *computepb.InsertInstanceRequest) error {
+		attempts = append(attempts, req.GetZone())
+		if req.GetZone() == "us-central1-a" {
+			return nil
+		}
+		return errors.New("QUOTA_EXCEEDED: Quota 'NVIDIA_L4_GPUS' exceeded.  Limit: 16.0 in region us-east1.")
+	}
+
+	if _, err := m.CreateVM(context.Background(), "linux-sm80plus-test", "jit-config"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if !slices.Equal(attempts, []string{"us-east1-d", "us-central1-a"}) {
+		t.Fatalf("attempted zones = %v, want us-east1-d then the next region", attempts)
+	}
+	if got := m.vms["linux-sm80plus-test"].zone; got != "us-central1-a" {
+		t.Fatalf("tracked zone = %s, want us-central1-a", got)
+	}
+
+	m.insertVMFunc = func(context.Context, *computepb.InsertInstanceRequest) error {
+		return errors.New("Quota 'NVIDIA_L4_GPUS' exceeded.  Limit: 16.0 in region us-east1.")
+	}
+	_, err := m.CreateVM(context.Background(), "linux-sm80plus-test-2", "jit-config")
+	if !errors.Is(err, ErrNoQuota) {
+		t.Fatalf("CreateVM error = %v, want it to wrap ErrNoQuota when every region is out of quota", err)
+	}
+}
+
 func TestCreateVMStopsOnNonStockoutError(t *testing.T) {
 	m := &Manager{
 		config: ManagerConfig{
@@ -624,6 +673,9 @@ func TestCreateVMConcurrentGPUCreatesRespectPendingQuota(t *testing.T) {
 	if !strings.Contains(err.Error(), "no candidate zones have unreserved nvidia-l4 quota") {
 		t.Fatalf("second CreateVM error = %q, want unreserved quota error", err)
 	}
+	if !errors.Is(err, ErrNoQuota) {
+		t.Fatalf("second CreateVM error = %q, want it to wrap ErrNoQuota", err)
+	}
 	if got := m.ActiveCount(); got != 1 {
 		t.Fatalf("active count after rejected second create = %d, want 1", got)
 	}
@@ -718,6 +770,46 @@ func TestCreateVMConcurrentGPUCreatesSpreadAcrossZonesInRegion(t *testing.T) {
 	}
 }
 
+func TestCreateVMSequentialGPUCreatesSpreadAcrossZonesInRegion(t *testing.T) {
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			InstanceTemplate: "linux-gpu-runner",
+			GPUType:          "nvidia-tesla-t4",
+			Platform:         "linux",
+		},
+		vms: map[string]*vmInfo{
+			// A VM running several runners counts once.
+			"linux-old-0": {vmName: "linux-old", zone: "us-east1-c"},
+			"linux-old-1": {vmName: "linux-old", zone: "us-east1-c", slot: 1},
+		},
+		pendingCreates: map[string]zoneCandidate{},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{
+			{zone: "us-east1-c", region: "us-east1", available: 8},
+			{zone: "us-east1-d", region: "us-east1", available: 8},
+			{zone: "us-east1-b", region: "us-east1", available: 8},
+			{zone: "us-west1-a", region: "us-west1", available: 4},
+		}, nil
+	}
+	var zones []string
+	m.insertVMFunc = func(_ context.Context, req *computepb.InsertInstanceRequest) error {
+		zones = append(zones, req.GetZone())
+		return nil
+	}
+
+	for i := range 5 {
+		if _, err := m.CreateVM(context.Background(), fmt.Sprintf("linux-%d", i), "jit-config"); err != nil {
+			t.Fatalf("CreateVM: %v", err)
+		}
+	}
+	want := []string{"us-east1-d", "us-east1-b", "us-east1-c", "us-east1-d", "us-east1-b"}
+	if !slices.Equal(zones, want) {
+		t.Fatalf("zones = %v, want %v: the least-loaded zone of the best region each time", zones, want)
+	}
+}
+
 // TestCreateVMStampsExpectGPUMetadata verifies that CreateVM stamps the
 // "expect-gpu" instance-metadata key from the pool's GPUType: "true" for GPU
 // pools and "false" for CPU-only pools (GPUType == "none"). The Linux startup
@@ -790,6 +882,19 @@ func TestIsZoneResourceExhausted(t *testing.T) {
 	}
 }
 
+func TestIsQuotaExceeded(t *testing.T) {
+	for msg, want := range map[string]bool{
+		"QUOTA_EXCEEDED: Quota 'NVIDIA_T4_GPUS' exceeded.  Limit: 4.0 in region us-east1.": true,
+		"Quota 'CPUS' exceeded.  Limit: 24.0 in region us-west1.":                          true,
+		"ZONE_RESOURCE_POOL_EXHAUSTED":                                                     false,
+		"permission denied":                                                                false,
+	} {
+		if got := isQuotaExceeded(errors.New(msg)); got != want {
+			t.Errorf("isQuotaExceeded(%q) = %v, want %v", msg, got, want)
+		}
+	}
+}
+
 // fakeClock returns a closure suitable for Manager.now that always
 // returns the same fixed time. Tests use it to drive evictStaleOrphans
 // deterministically without sleeping.
@@ -980,3 +1085,261 @@ func TestEvictStaleOrphansRetainsTrackingWhenBusyDuringDelete(t *testing.T) {
 		t.Fatal("busy flag should have survived")
 	}
 }
+
+func TestCreateVMForLabelsRoutesToTemplate(t *testing.T) {
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			Zones:            "us-east1-d",
+			InstanceTemplate: "linux-gpu-runner",
+			GPUType:          "nvidia-tesla-t4",
+			Platform:         "linux",
+			TemplateRoutes: []TemplateRoute{
+				{Label: "GCP-A100", InstanceTemplate: "linux-gpu-runner-a100", GPUType: "nvidia-tesla-a100"},
+				{Label: "GCP-L4", InstanceTemplate: "linux-gpu-runner-l4"},
+			},
+		},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-d", region: "us-east1", available: 4}}, nil
+	}
+
+	var templates []string
+	m.insertVMFunc = func(_ context.Context, req *computepb.InsertInstanceRequest) error {
+		templates = append(templates, req.GetSourceInstanceTemplate())
+		return nil
+	}
+
+	ctx := context.Background()
+	if _, err := m.CreateVMForLabels(ctx, "runner-a100", "jit", []string{"self-hosted", "gcp-a100"}); err != nil {
+		t.Fatalf("CreateVMForLabels(a100): %v", err)
+	}
+	if _, err := m.CreateVMForLabels(ctx, "runner-default", "jit", []string{"self-hosted"}); err != nil {
+		t.Fatalf("CreateVMForLabels(default): %v", err)
+	}
+	if _, err := m.CreateVM(ctx, "runner-warm", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+
+	want := []string{
+		"projects/test-project/global/instanceTemplates/linux-gpu-runner-a100",
+		"projects/test-project/global/instanceTemplates/linux-gpu-runner",
+		"projects/test-project/global/instanceTemplates/linux-gpu-runner",
+	}
+	if !slices.Equal(templates, want) {
+		t.Fatalf("templates = %v, want %v", templates, want)
+	}
+
+	if got := m.profileForLabels([]string{"GCP-L4"}); got.gpuType != "nvidia-tesla-t4" || got.instanceTemplate != "linux-gpu-runner-l4" {
+		t.Fatalf("route without GPU type = %+v, want l4 template inheriting the pool GPU type", got)
+	}
+}
+
+func TestSetZonesValidatesAndApplies(t *testing.T) {
+	m := &Manager{config: ManagerConfig{Zones: "us-east1-c", GPUType: "none"}}
+
+	if err := m.SetZones("invalid-zone"); err == nil {
+		t.Fatal("SetZones should reject an invalid zone")
+	}
+	if err := m.SetZones(" , "); err == nil {
+		t.Fatal("SetZones should reject an empty zone list")
+	}
+	if got := m.zones(); got != "us-east1-c" {
+		t.Fatalf("zones after rejected update = %q, want us-east1-c", got)
+	}
+
+	if err := m.SetZones("us-west1-a,us-central1-a"); err != nil {
+		t.Fatalf("SetZones: %v", err)
+	}
+	candidates, err := m.selectZones(context.Background())
+	if err != nil {
+		t.Fatalf("selectZones: %v", err)
+	}
+	var got []string
+	for _, c := range candidates {
+		got = append(got, c.zone)
+	}
+	if want := []string{"us-west1-a", "us-central1-a"}; !slices.Equal(got, want) {
+		t.Fatalf("candidate zones = %v, want %v", got, want)
+	}
+}
+
+func TestSetCleanupIntervalKeepsLatestValue(t *testing.T) {
+	m := &Manager{cleanupIntervalCh: make(chan time.Duration, 1)}
+
+	m.SetCleanupInterval(time.Minute)
+	m.SetCleanupInterval(5 * time.Minute)
+	if got := <-m.cleanupIntervalCh; got != 5*time.Minute {
+		t.Fatalf("queued interval = %v, want 5m", got)
+	}
+
+	m.SetCleanupInterval(0)
+	if got := m.config.CleanupInterval; got != defaultCleanupInterval {
+		t.Fatalf("CleanupInterval after 0 = %v, want default %v", got, defaultCleanupInterval)
+	}
+}
+
+func TestCreateVMAttachesExtraMetadataAndLabels(t *testing.T) {
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			InstanceTemplate: "linux-build-runner",
+			GPUType:          "none",
+			Platform:         "linux",
+			Metadata:         map[string]string{"owner": "gfx", "enable-oslogin": "TRUE"},
+			Labels:           map[string]string{"team": "gfx", "cost-center": "ci"},
+		},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-d", region: "us-east1"}}, nil
+	}
+
+	var req *computepb.InsertInstanceRequest
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		req = r
+		return nil
+	}
+	if _, err := m.CreateVM(context.Background(), "build-test", "jit-config"); err != nil {
+		t.Fatalf("CreateVM returned error: %v", err)
+	}
+
+	var keys []string
+	for _, item := range req.GetInstanceResource().GetMetadata().GetItems() {
+		keys = append(keys, item.GetKey())
+	}
+	want := []string{"jit-config", "startup-script", "expect-gpu", "enable-oslogin", "owner"}
+	if !slices.Equal(keys, want) {
+		t.Fatalf("metadata keys = %v, want %v", keys, want)
+	}
+	if got := req.GetInstanceResource().GetLabels(); got["team"] != "gfx" || got["cost-center"] != "ci" {
+		t.Fatalf("labels = %v, want team=gfx, cost-center=ci", got)
+	}
+}
+
+func TestCreateVMRendersStartupScriptOverride(t *testing.T) {
+	script, err := startup.Parse("startup.ps1", "Write-Host {{.RunnerName}} {{.ScaleSet}}")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	script.Vars.ScaleSet = "windows-gpu-runners"
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			InstanceTemplate: "windows-gpu-runner",
+			GPUType:          "none",
+			StartupScript:    script,
+		},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-d", region: "us-east1"}}, nil
+	}
+	var req *computepb.InsertInstanceRequest
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		req = r
+		return nil
+	}
+	if _, err := m.CreateVM(context.Background(), "win-test-1", "jit-config"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+
+	for _, item := range req.GetInstanceResource().GetMetadata().GetItems() {
+		if item.GetKey() == "windows-startup-script-ps1" {
+			if got := item.GetValue(); got != "Write-Host win-test-1 windows-gpu-runners" {
+				t.Fatalf("startup script = %q, want rendered override", got)
+			}
+			return
+		}
+	}
+	t.Fatal("windows-startup-script-ps1 metadata missing")
+}
+
+func TestValidateMetadataAndLabels(t *testing.T) {
+	if err := validateMetadata(map[string]string{"jit-config": "x"}); err == nil {
+		t.Fatal("validateMetadata should reject scaler-owned keys")
+	}
+	if err := ValidateLabels(map[string]string{"team": "gfx", "cost_center": ""}); err != nil {
+		t.Fatalf("ValidateLabels rejected valid labels: %v", err)
+	}
+	for _, bad := range []map[string]string{
+		{"Team": "gfx"},
+		{"1team": "gfx"},
+		{"team": "GFX"},
+		{"team": strings.Repeat("a", 64)},
+	} {
+		if err := ValidateLabels(bad); err == nil {
+			t.Fatalf("ValidateLabels(%v) should fail", bad)
+		}
+	}
+}
+
+func TestVMsListsTrackedAndPending(t *testing.T) {
+	created := time.Now()
+	m := &Manager{
+		vms: map[string]*vmInfo{
+			"win-test-b": {vmName: "win-test-b", zone: "us-east1-c", busy: true, createdAt: created},
+		},
+		pendingCreates: map[string]zoneCandidate{
+			"win-test-a": {zone: "us-west1-a"},
+			"win-test-b": {zone: "us-east1-c"},
+		},
+	}
+	vms := m.VMs()
+	if len(vms) != 2 {
+		t.Fatalf("VMs = %+v, want one tracked and one pending", vms)
+	}
+	if vms[0].RunnerName != "win-test-a" || !vms[0].Pending || vms[0].Location != "us-west1-a" {
+		t.Fatalf("vms[0] = %+v, want pending win-test-a in us-west1-a", vms[0])
+	}
+	if vms[1].Name != "win-test-b" || !vms[1].Busy || vms[1].Pending || !vms[1].CreatedAt.Equal(created) {
+		t.Fatalf("vms[1] = %+v, want busy tracked win-test-b", vms[1])
+	}
+}
+
+func TestDeleteByRunnerNameAwaitsDeletionInBackground(t *testing.T) {
+	release := make(chan struct{})
+	m := &Manager{
+		config: ManagerConfig{Zones: "us-east1-c"},
+		vms: map[string]*vmInfo{
+			"runner-a": {vmName: "win-runner-a", zone: "us-east1-c"},
+		},
+	}
+	m.deleteVMFunc = func(context.Context, string, string) error {
+		<-release
+		return nil
+	}
+	deleted := 0
+	m.listTerminated = func(context.Context, string) ([]string, error) {
+		return []string{"win-runner-a"}, nil
+	}
+
+	if err := m.DeleteByRunnerName(context.Background(), "runner-a"); err != nil {
+		t.Fatalf("DeleteByRunnerName: %v", err)
+	}
+	if n := m.ActiveCount(); n != 0 {
+		t.Fatalf("ActiveCount while deleting = %d, want 0", n)
+	}
+	if got := m.Deleting(); !slices.Equal(got, []string{"win-runner-a"}) {
+		t.Fatalf("Deleting() = %v, want [win-runner-a]", got)
+	}
+	// The cleanup loop leaves a VM being deleted alone.
+	m.deleteVMFunc = func(context.Context, string, string) error {
+		deleted++
+		return nil
+	}
+	m.doCleanupTerminatedVMs(context.Background())
+	if deleted != 0 {
+		t.Fatalf("cleanup deleted a VM whose deletion is in flight")
+	}
+
+	close(release)
+	m.deletes.Wait()
+	if got := m.Deleting(); len(got) != 0 {
+		t.Fatalf("Deleting() after the deletion finished = %v, want none", got)
+	}
+}
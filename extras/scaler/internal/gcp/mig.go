@@ -0,0 +1,58 @@
+package gcp
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+)
+
+// migSlicesPerGPU maps each A100 GPU type to the number of MIG instances of
+// each profile one GPU fits.
+var migSlicesPerGPU = map[string]map[string]int{
+	"nvidia-tesla-a100": {
+		"1g.5gb":  7,
+		"1g.10gb": 4,
+		"2g.10gb": 3,
+		"3g.20gb": 2,
+		"4g.20gb": 1,
+		"7g.40gb": 1,
+	},
+	"nvidia-a100-80gb": {
+		"1g.10gb": 7,
+		"1g.20gb": 4,
+		"2g.20gb": 3,
+		"3g.40gb": 2,
+		"4g.40gb": 1,
+		"7g.80gb": 1,
+	},
+}
+
+// MIGSlices returns how many MIG instances of profile one GPU of gpuType
+// fits, or 0 when the GPU does not support MIG or the profile.
+func MIGSlices(gpuType, profile string) int {
+	return migSlicesPerGPU[gpuType][profile]
+}
+
+// machineTypeGPUs matches the GPU count of machine types with bundled GPUs,
+// e.g. a2-highgpu-2g.
+var machineTypeGPUs = regexp.MustCompile(`-(\d+)g$`)
+
+// templateGPUs returns the number of GPUs a VM created from template gets:
+// its guest accelerators, or for A2 machine types, which bundle their GPUs,
+// the count in the machine type's name.
+func (m *Manager) templateGPUs(ctx context.Context, template string) (int, error) {
+	props, err := m.templateProperties(ctx, template)
+	if err != nil {
+		return 0, err
+	}
+	gpus := 0
+	for _, acc := range props.GetGuestAccelerators() {
+		gpus += int(acc.GetAcceleratorCount())
+	}
+	if gpus == 0 {
+		if match := machineTypeGPUs.FindStringSubmatch(props.GetMachineType()); match != nil {
+			gpus, _ = strconv.Atoi(match[1])
+		}
+	}
+	return max(gpus, 1), nil
+}
@@ -0,0 +1,78 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRunnerSlotsForMIGProfile(t *testing.T) {
+	templates := map[string]*computepb.InstanceProperties{
+		"linux-a100": {
+			MachineType: proto.String("a2-highgpu-2g"),
+		},
+		"linux-a100-80": {
+			GuestAccelerators: []*computepb.AcceleratorConfig{{AcceleratorCount: proto.Int32(1)}},
+		},
+	}
+	m := &Manager{
+		config: ManagerConfig{
+			InstanceTemplate: "linux-t4",
+			GPUType:          "nvidia-tesla-t4",
+			MIGProfile:       "1g.10gb",
+			TemplateRoutes: []TemplateRoute{
+				{Label: "GCP-A100", InstanceTemplate: "linux-a100", GPUType: "nvidia-tesla-a100"},
+				{Label: "GCP-A100-80", InstanceTemplate: "linux-a100-80", GPUType: "nvidia-a100-80gb"},
+			},
+		},
+		templatePropertiesFunc: func(_ context.Context, name string) (*computepb.InstanceProperties, error) {
+			return templates[name], nil
+		},
+	}
+
+	for _, tc := range []struct {
+		labels []string
+		want   int
+	}{
+		{nil, 1},                     // T4s have no MIG
+		{[]string{"GCP-A100"}, 8},    // 2 GPUs of 4 1g.10gb slices
+		{[]string{"GCP-A100-80"}, 7}, // 1 GPU of 7 1g.10gb slices
+	} {
+		got, err := m.RunnerSlots(context.Background(), tc.labels)
+		if err != nil {
+			t.Fatalf("RunnerSlots(%v): %v", tc.labels, err)
+		}
+		if got != tc.want {
+			t.Errorf("RunnerSlots(%v) = %d, want %d", tc.labels, got, tc.want)
+		}
+	}
+}
+
+func TestRunnerSlotsWithoutMIG(t *testing.T) {
+	m := &Manager{config: ManagerConfig{InstanceTemplate: "linux-build", GPUType: "none", RunnerSlots: 4}}
+	got, err := m.RunnerSlots(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("RunnerSlots: %v", err)
+	}
+	if got != 4 {
+		t.Fatalf("RunnerSlots = %d, want the configured 4", got)
+	}
+	m.config.RunnerSlots = 0
+	if got, _ := m.RunnerSlots(context.Background(), nil); got != 1 {
+		t.Fatalf("RunnerSlots = %d, want 1 by default", got)
+	}
+}
+
+func TestMIGSlices(t *testing.T) {
+	if got := MIGSlices("nvidia-tesla-a100", "1g.5gb"); got != 7 {
+		t.Errorf("MIGSlices(a100, 1g.5gb) = %d, want 7", got)
+	}
+	if got := MIGSlices("nvidia-a100-80gb", "1g.5gb"); got != 0 {
+		t.Errorf("MIGSlices(a100-80gb, 1g.5gb) = %d, want 0 (no such profile)", got)
+	}
+	if got := MIGSlices("nvidia-l4", "1g.5gb"); got != 0 {
+		t.Errorf("MIGSlices(l4, 1g.5gb) = %d, want 0", got)
+	}
+}
@@ -0,0 +1,77 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// overridesNetwork reports whether Network or Subnetwork is set, or the
+// template's external IPs are dropped.
+func (m *Manager) overridesNetwork() bool {
+	return m.config.Network != "" || m.config.Subnetwork != "" || m.config.NoExternalIP
+}
+
+// networkInterfaces returns the template's network interfaces with the
+// first one moved onto the configured network/subnetwork, and without their
+// access configs with NoExternalIP. Like disks, an insert that sets
+// interfaces replaces the template's, so the rest of each NIC (access
+// configs for external IPs, NIC type, ...) is copied over.
+func (m *Manager) networkInterfaces(ctx context.Context, template, zone string) ([]*computepb.NetworkInterface, error) {
+	props, err := m.templateProperties(ctx, template)
+	if err != nil {
+		return nil, err
+	}
+
+	nics := make([]*computepb.NetworkInterface, 0, len(props.GetNetworkInterfaces()))
+	for _, nic := range props.GetNetworkInterfaces() {
+		nics = append(nics, proto.Clone(nic).(*computepb.NetworkInterface))
+	}
+	if len(nics) == 0 {
+		nics = append(nics, &computepb.NetworkInterface{})
+	}
+
+	nic := nics[0]
+	if m.config.Network != "" {
+		nic.Network = proto.String(networkURL(m.config.Project, m.config.Network))
+		// The template's subnetwork belongs to the template's VPC.
+		// Without an explicit one, auto-mode networks pick the region's.
+		nic.Subnetwork = nil
+	}
+	if m.config.Subnetwork != "" {
+		region := zoneRegion(zone)
+		if region == "" {
+			return nil, fmt.Errorf("cannot derive region from zone %q", zone)
+		}
+		nic.Subnetwork = proto.String(subnetworkURL(m.config.Project, region, m.config.Subnetwork))
+	}
+	if m.config.NoExternalIP {
+		for _, nic := range nics {
+			nic.AccessConfigs = nil
+			nic.Ipv6AccessConfigs = nil
+		}
+	}
+	return nics, nil
+}
+
+// networkURL expands a bare network name to a partial URL; self-links are
+// used as given.
+func networkURL(project, network string) string {
+	if strings.Contains(network, "/") {
+		return network
+	}
+	return fmt.Sprintf("projects/%s/global/networks/%s", project, network)
+}
+
+// subnetworkURL expands a bare subnetwork name to the subnetwork of that name
+// in region, so one name works across a multi-region zone list when every
+// region has a like-named subnet. Self-links are used as given.
+func subnetworkURL(project, region, subnetwork string) string {
+	if strings.Contains(subnetwork, "/") {
+		return subnetwork
+	}
+	return fmt.Sprintf("projects/%s/regions/%s/subnetworks/%s", project, region, subnetwork)
+}
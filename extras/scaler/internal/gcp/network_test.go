@@ -0,0 +1,103 @@
+package gcp
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func newNetworkTestManager(t *testing.T, cfg ManagerConfig) (*Manager, *[]*computepb.InsertInstanceRequest) {
+	t.Helper()
+	cfg.Project = "slang-runners"
+	cfg.InstanceTemplate = "linux-gpu-runner"
+	cfg.GPUType = "none"
+	m := &Manager{
+		config:         cfg,
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		templatePropertiesFunc: func(context.Context, string) (*computepb.InstanceProperties, error) {
+			return &computepb.InstanceProperties{
+				NetworkInterfaces: []*computepb.NetworkInterface{{
+					Network:       proto.String("projects/slang-runners/global/networks/prod-ci"),
+					Subnetwork:    proto.String("projects/slang-runners/regions/us-east1/subnetworks/prod-ci"),
+					AccessConfigs: []*computepb.AccessConfig{{Name: proto.String("External NAT")}},
+				}},
+				Tags: &computepb.Tags{Items: []string{"prod-runner"}},
+			}, nil
+		},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-west1-a", region: "us-west1"}}, nil
+	}
+	var reqs []*computepb.InsertInstanceRequest
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		reqs = append(reqs, r)
+		return nil
+	}
+	return m, &reqs
+}
+
+func TestCreateVMOverridesNetworkAndTags(t *testing.T) {
+	m, reqs := newNetworkTestManager(t, ManagerConfig{
+		Network:     "staging-ci",
+		Subnetwork:  "staging-ci",
+		NetworkTags: []string{"staging-runner", "allow-iap"},
+	})
+	if _, err := m.CreateVM(context.Background(), "vm-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+
+	inst := (*reqs)[0].GetInstanceResource()
+	nics := inst.GetNetworkInterfaces()
+	if len(nics) != 1 {
+		t.Fatalf("network interfaces = %d, want 1", len(nics))
+	}
+	if got := nics[0].GetNetwork(); got != "projects/slang-runners/global/networks/staging-ci" {
+		t.Fatalf("network = %q", got)
+	}
+	if got := nics[0].GetSubnetwork(); got != "projects/slang-runners/regions/us-west1/subnetworks/staging-ci" {
+		t.Fatalf("subnetwork = %q, want the selected zone's region", got)
+	}
+	if len(nics[0].GetAccessConfigs()) != 1 {
+		t.Fatal("template access config (external IP) was dropped")
+	}
+	if got := inst.GetTags().GetItems(); !slices.Equal(got, []string{"staging-runner", "allow-iap"}) {
+		t.Fatalf("tags = %v", got)
+	}
+}
+
+func TestCreateVMNetworkOnlyDropsTemplateSubnetwork(t *testing.T) {
+	m, reqs := newNetworkTestManager(t, ManagerConfig{
+		Network: "projects/shared-vpc/global/networks/staging-ci",
+	})
+	if _, err := m.CreateVM(context.Background(), "vm-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	nic := (*reqs)[0].GetInstanceResource().GetNetworkInterfaces()[0]
+	if nic.GetNetwork() != "projects/shared-vpc/global/networks/staging-ci" {
+		t.Fatalf("network = %q, want self-link used as given", nic.GetNetwork())
+	}
+	if nic.Subnetwork != nil {
+		t.Fatalf("subnetwork = %q; the template's subnet belongs to another VPC", nic.GetSubnetwork())
+	}
+	if (*reqs)[0].GetInstanceResource().GetTags() != nil {
+		t.Fatal("tags should be left to the template when none are configured")
+	}
+}
+
+func TestCreateVMNoExternalIPDropsAccessConfigs(t *testing.T) {
+	m, reqs := newNetworkTestManager(t, ManagerConfig{NoExternalIP: true})
+	if _, err := m.CreateVM(context.Background(), "vm-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	nic := (*reqs)[0].GetInstanceResource().GetNetworkInterfaces()[0]
+	if len(nic.GetAccessConfigs()) != 0 {
+		t.Fatalf("access configs = %v, want none", nic.GetAccessConfigs())
+	}
+	if nic.GetSubnetwork() != "projects/slang-runners/regions/us-east1/subnetworks/prod-ci" {
+		t.Fatalf("subnetwork = %q, want the template's", nic.GetSubnetwork())
+	}
+}
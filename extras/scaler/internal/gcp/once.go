@@ -0,0 +1,38 @@
+package gcp
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ReconcileResult is what ReconcileOnce did.
+type ReconcileResult struct {
+	// Adopted is the number of live VMs tracked because GitHub lists their
+	// runners.
+	Adopted int
+	// Terminated is the number of terminated VMs deleted.
+	Terminated int
+	// Orphans is the number of adopted VMs deleted for staying idle past
+	// OrphanGracePeriod.
+	Orphans int
+}
+
+// ReconcileOnce makes one pass of the cleanup a running manager does in
+// the background, for a manager created with OneShot that knows nothing of
+// the pool yet. It tracks the pool's live VMs whose runners are registered
+// (see AdoptVMs; runners maps the name of every registered runner to
+// whether it is running a job), deletes the pool's terminated VMs, and
+// deletes the tracked VMs that stayed idle past OrphanGracePeriod.
+//
+// With StoppedPoolSize set, terminated VMs are kept: they may be the
+// stopped pool of a scaler running the same pool.
+func (m *Manager) ReconcileOnce(ctx context.Context, runners map[string]bool) ReconcileResult {
+	res := ReconcileResult{Adopted: m.AdoptVMs(ctx, runners)}
+	if m.config.StoppedPoolSize > 0 {
+		slog.Info("keeping terminated VMs, which may be a stopped pool", "stopped_pool_size", m.config.StoppedPoolSize)
+	} else {
+		res.Terminated = m.deleteTerminatedVMs(ctx)
+	}
+	res.Orphans = m.evictStaleOrphans(ctx)
+	return res
+}
@@ -0,0 +1,64 @@
+package gcp
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+)
+
+func newOnceManager(deleted *[]string) *Manager {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	m := &Manager{
+		config:         ManagerConfig{VMPrefix: "linux-test", Zones: "us-central1-a", OrphanGracePeriod: 30 * time.Minute, OneShot: true},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		nowFunc:        func() time.Time { return now },
+	}
+	m.listLiveInstancesFunc = func(context.Context, string) ([]liveInstance, error) {
+		return []liveInstance{
+			{name: "linux-test-busy", created: now.Add(-2 * time.Hour), slots: 1},
+			{name: "linux-test-wedged", created: now.Add(-time.Hour), slots: 1},
+			{name: "linux-test-booting", created: now.Add(-time.Minute), slots: 1},
+			{name: "linux-test-done", created: now.Add(-time.Hour), slots: 1},
+		}, nil
+	}
+	m.listTerminated = func(context.Context, string) ([]string, error) {
+		return []string{"linux-test-preempted"}, nil
+	}
+	m.deleteVMFunc = func(_ context.Context, vmName, _ string) error {
+		*deleted = append(*deleted, vmName)
+		return nil
+	}
+	return m
+}
+
+func TestReconcileOnce(t *testing.T) {
+	var deleted []string
+	m := newOnceManager(&deleted)
+	runners := map[string]bool{"linux-test-busy": true, "linux-test-wedged": false, "linux-test-booting": false}
+
+	res := m.ReconcileOnce(context.Background(), runners)
+	if res != (ReconcileResult{Adopted: 3, Terminated: 1, Orphans: 1}) {
+		t.Fatalf("ReconcileOnce = %+v", res)
+	}
+	sort.Strings(deleted)
+	if want := []string{"linux-test-preempted", "linux-test-wedged"}; !slices.Equal(deleted, want) {
+		t.Fatalf("deleted %v, want %v: the terminated VM and the one idle past the grace period", deleted, want)
+	}
+	if m.ActiveCount() != 2 {
+		t.Fatalf("tracking %d VMs, want the busy and the booting one", m.ActiveCount())
+	}
+}
+
+func TestReconcileOnceKeepsStoppedPool(t *testing.T) {
+	var deleted []string
+	m := newOnceManager(&deleted)
+	m.config.StoppedPoolSize = 2
+	m.config.OrphanGracePeriod = -1
+
+	if res := m.ReconcileOnce(context.Background(), nil); res != (ReconcileResult{}) || len(deleted) != 0 {
+		t.Fatalf("ReconcileOnce = %+v, deleted %v; want the terminated VMs kept", res, deleted)
+	}
+}
@@ -0,0 +1,291 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	orgpolicy "google.golang.org/api/orgpolicy/v2"
+)
+
+// The organization policy constraints OrgPolicyCheck evaluates.
+const (
+	locationsConstraint  = "gcp.resourceLocations"
+	externalIPConstraint = "compute.vmExternalIpAccess"
+)
+
+// orgPolicyTTL is how long the effective policies are used before they are
+// read again.
+const orgPolicyTTL = time.Hour
+
+// orgPolicies are the project's effective policies for the constraints
+// OrgPolicyCheck evaluates.
+type orgPolicies struct {
+	locations  listPolicy
+	externalIP listPolicy
+	readAt     time.Time
+}
+
+// listPolicy is the unconditional part of an effective list constraint.
+// Rules with a condition (on tags) are skipped: the scaler can't tell
+// whether they apply, so it leaves those VMs to GCE.
+type listPolicy struct {
+	allowAll, denyAll bool
+	allowed, denied   []string
+}
+
+func newListPolicy(policy *orgpolicy.GoogleCloudOrgpolicyV2Policy) listPolicy {
+	var p listPolicy
+	if policy == nil || policy.Spec == nil {
+		return p
+	}
+	for _, rule := range policy.Spec.Rules {
+		if rule.Condition != nil {
+			continue
+		}
+		p.allowAll = p.allowAll || rule.AllowAll
+		p.denyAll = p.denyAll || rule.DenyAll
+		if rule.Values != nil {
+			p.allowed = append(p.allowed, rule.Values.AllowedValues...)
+			p.denied = append(p.denied, rule.Values.DeniedValues...)
+		}
+	}
+	return p
+}
+
+// allows reports whether the policy allows a resource, given match, which
+// reports whether a value covers it. Values match can't evaluate (ok false)
+// never deny it: the check must not block what GCE would allow.
+func (p listPolicy) allows(match func(value string) (matched, ok bool)) bool {
+	if p.allowAll {
+		return true
+	}
+	if p.denyAll {
+		return false
+	}
+	for _, value := range p.denied {
+		if matched, ok := match(strings.TrimPrefix(value, "is:")); matched && ok {
+			return false
+		}
+	}
+	if len(p.allowed) == 0 {
+		return true
+	}
+	for _, value := range p.allowed {
+		if matched, ok := match(strings.TrimPrefix(value, "is:")); matched || !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// locationMatcher matches gcp.resourceLocations values against zone: the
+// zone or its region, by name or as an "in:REGION-locations" value group,
+// and the "in:us-locations" group. Other multi-region groups can't be
+// evaluated without their member lists.
+func locationMatcher(zone string) func(string) (bool, bool) {
+	region := zoneRegion(zone)
+	return func(value string) (bool, bool) {
+		group, ok := strings.CutPrefix(value, "in:")
+		if !ok {
+			return value == zone || value == region, true
+		}
+		group = strings.TrimSuffix(group, "-locations")
+		switch {
+		case group == zone || group == region:
+			return true, true
+		case strings.ContainsAny(group, "0123456789"):
+			// Another region or zone.
+			return false, true
+		case group == "us":
+			return strings.HasPrefix(region, "us-"), true
+		}
+		return false, false
+	}
+}
+
+// violations returns why a VM named vmName in zone, with an external IP or
+// not, would violate the policies.
+func (p orgPolicies) violations(project, zone, vmName string, externalIP bool) []string {
+	var reasons []string
+	if !p.locations.allows(locationMatcher(zone)) {
+		reasons = append(reasons, fmt.Sprintf("%s is not an allowed location (constraints/%s)", zone, locationsConstraint))
+	}
+	instance := fmt.Sprintf("projects/%s/zones/%s/instances/%s", project, zone, vmName)
+	if externalIP && !p.externalIP.allows(func(value string) (bool, bool) {
+		return resourcePath(value) == instance, true
+	}) {
+		reasons = append(reasons, fmt.Sprintf("VMs may not have external IPs (constraints/%s); set --no-external-ip", externalIPConstraint))
+	}
+	return reasons
+}
+
+// allowedCandidates returns the candidate zones a VM of profile named vmName
+// with machineType ("" for the template's) may be created in without
+// violating AllowedMachineTypes or, with OrgPolicyCheck, the project's
+// organization policies. It fails, naming the constraints, when no zone is
+// left, so a create that GCE would reject fails before its insert.
+func (m *Manager) allowedCandidates(ctx context.Context, profile vmProfile, machineType, vmName string, candidates []zoneCandidate) ([]zoneCandidate, error) {
+	if !m.config.OrgPolicyCheck && len(m.config.AllowedMachineTypes) == 0 {
+		return candidates, nil
+	}
+	props, err := m.templateProperties(ctx, profile.instanceTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if machineType == "" {
+		machineType = path.Base(props.GetMachineType())
+	}
+	if err := m.checkMachineType(machineType); err != nil {
+		return nil, err
+	}
+	if !m.config.OrgPolicyCheck {
+		return candidates, nil
+	}
+
+	policies := m.currentOrgPolicies(ctx)
+	externalIP := m.hasExternalIP(props)
+	var allowed []zoneCandidate
+	var violations []string
+	for _, candidate := range candidates {
+		if reasons := policies.violations(m.config.Project, candidate.zone, vmName, externalIP); len(reasons) > 0 {
+			violations = append(violations, candidate.zone+": "+strings.Join(reasons, ", "))
+			continue
+		}
+		allowed = append(allowed, candidate)
+	}
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("organization policy forbids VM %s in every candidate zone: %s", vmName, strings.Join(violations, "; "))
+	}
+	if len(violations) > 0 {
+		slog.Warn("skipping zones organization policy forbids", "vm", vmName, "violations", strings.Join(violations, "; "))
+	}
+	return allowed, nil
+}
+
+// checkMachineType returns an error when AllowedMachineTypes is set and no
+// pattern of it matches machineType.
+func (m *Manager) checkMachineType(machineType string) error {
+	if len(m.config.AllowedMachineTypes) == 0 {
+		return nil
+	}
+	for _, pattern := range m.config.AllowedMachineTypes {
+		if ok, _ := path.Match(pattern, machineType); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("machine type %s is not one of the allowed machine types %s", machineType, strings.Join(m.config.AllowedMachineTypes, ","))
+}
+
+// hasExternalIP reports whether VMs from props get an external address.
+func (m *Manager) hasExternalIP(props *computepb.InstanceProperties) bool {
+	if m.config.NoExternalIP {
+		return false
+	}
+	for _, nic := range props.GetNetworkInterfaces() {
+		if len(nic.GetAccessConfigs()) > 0 || len(nic.GetIpv6AccessConfigs()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOrgPolicies is Check's part of allowedCandidates: it reports every
+// template and zone whose VMs would violate the checked constraints.
+func (m *Manager) checkOrgPolicies(ctx context.Context) []error {
+	var policies orgPolicies
+	if m.config.OrgPolicyCheck {
+		var err error
+		if policies, err = m.readOrgPolicies(ctx); err != nil {
+			return []error{err}
+		}
+	}
+	var errs []error
+	for _, template := range m.templateNames() {
+		props, err := m.templateProperties(ctx, template)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := m.checkMachineType(path.Base(props.GetMachineType())); err != nil {
+			errs = append(errs, fmt.Errorf("instance template %s: %w", template, err))
+		}
+		for _, zone := range splitZones(m.zones()) {
+			if reasons := policies.violations(m.config.Project, zone, template, m.hasExternalIP(props)); len(reasons) > 0 {
+				errs = append(errs, fmt.Errorf("instance template %s in %s: %s", template, zone, strings.Join(reasons, ", ")))
+			}
+		}
+	}
+	return errs
+}
+
+// currentOrgPolicies returns the effective policies, read again once they
+// are orgPolicyTTL old. A failed read keeps the last ones for another
+// orgPolicyTTL.
+func (m *Manager) currentOrgPolicies(ctx context.Context) orgPolicies {
+	m.mu.Lock()
+	policies := m.orgPolicies
+	m.mu.Unlock()
+	if m.now().Sub(policies.readAt) < orgPolicyTTL {
+		return policies
+	}
+	fresh, err := m.readOrgPolicies(ctx)
+	if err != nil {
+		slog.Warn("failed to read organization policies, using the last ones", "error", err)
+		fresh = policies
+		fresh.readAt = m.now()
+	}
+	m.mu.Lock()
+	m.orgPolicies = fresh
+	m.mu.Unlock()
+	return fresh
+}
+
+// readOrgPolicies reads the project's effective policies for the
+// constraints OrgPolicyCheck evaluates.
+func (m *Manager) readOrgPolicies(ctx context.Context) (orgPolicies, error) {
+	locations, err := m.effectivePolicy(ctx, locationsConstraint)
+	if err != nil {
+		return orgPolicies{}, err
+	}
+	externalIP, err := m.effectivePolicy(ctx, externalIPConstraint)
+	if err != nil {
+		return orgPolicies{}, err
+	}
+	return orgPolicies{
+		locations:  newListPolicy(locations),
+		externalIP: newListPolicy(externalIP),
+		readAt:     m.now(),
+	}, nil
+}
+
+// effectivePolicy returns the project's effective policy for constraint,
+// which merges those set on its folders and organization.
+func (m *Manager) effectivePolicy(ctx context.Context, constraint string) (*orgpolicy.GoogleCloudOrgpolicyV2Policy, error) {
+	if m.effectivePolicyFunc != nil {
+		return m.effectivePolicyFunc(ctx, constraint)
+	}
+	opts, err := clientOptions(ctx, m.config.ImpersonateServiceAccount)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := orgpolicy.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating Org Policy client: %w", err)
+	}
+	name := fmt.Sprintf("projects/%s/policies/%s", m.config.Project, constraint)
+	var policy *orgpolicy.GoogleCloudOrgpolicyV2Policy
+	err = m.retry(ctx, "GetEffectivePolicy", func(ctx context.Context) error {
+		var err error
+		policy, err = svc.Projects.Policies.GetEffectivePolicy(name).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading the effective policy for constraints/%s (needs roles/orgpolicy.policyViewer): %w", constraint, err)
+	}
+	return policy, nil
+}
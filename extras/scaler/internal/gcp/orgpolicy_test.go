@@ -0,0 +1,145 @@
+package gcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	orgpolicy "google.golang.org/api/orgpolicy/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+func listValues(allowed, denied []string) *orgpolicy.GoogleCloudOrgpolicyV2Policy {
+	return &orgpolicy.GoogleCloudOrgpolicyV2Policy{Spec: &orgpolicy.GoogleCloudOrgpolicyV2PolicySpec{
+		Rules: []*orgpolicy.GoogleCloudOrgpolicyV2PolicySpecPolicyRule{{
+			Values: &orgpolicy.GoogleCloudOrgpolicyV2PolicySpecPolicyRuleStringValues{AllowedValues: allowed, DeniedValues: denied},
+		}},
+	}}
+}
+
+func TestLocationPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		allowed, denied []string
+		zone            string
+		want            bool
+	}{
+		{nil, nil, "us-east1-c", true},
+		{[]string{"in:us-locations"}, nil, "us-east1-c", true},
+		{[]string{"in:us-locations"}, nil, "europe-west4-a", false},
+		{[]string{"in:us-central1-locations", "europe-west4"}, nil, "europe-west4-a", true},
+		{[]string{"in:us-central1-locations"}, nil, "us-east1-c", false},
+		{[]string{"is:us-east1-c"}, nil, "us-east1-c", true},
+		{nil, []string{"in:us-east1-locations"}, "us-east1-c", false},
+		{nil, []string{"in:us-east1-locations"}, "us-west1-a", true},
+		// Groups without a known member list never deny.
+		{[]string{"in:eu-locations"}, nil, "europe-west4-a", true},
+		{nil, []string{"in:eu-locations"}, "europe-west4-a", true},
+	} {
+		p := newListPolicy(listValues(tc.allowed, tc.denied))
+		if got := p.allows(locationMatcher(tc.zone)); got != tc.want {
+			t.Errorf("allowed %v denied %v: allows(%s) = %v, want %v", tc.allowed, tc.denied, tc.zone, got, tc.want)
+		}
+	}
+}
+
+func TestListPolicySkipsConditionalRules(t *testing.T) {
+	p := newListPolicy(&orgpolicy.GoogleCloudOrgpolicyV2Policy{Spec: &orgpolicy.GoogleCloudOrgpolicyV2PolicySpec{
+		Rules: []*orgpolicy.GoogleCloudOrgpolicyV2PolicySpecPolicyRule{
+			{DenyAll: true, Condition: &orgpolicy.GoogleTypeExpr{Expression: "resource.matchTag('123/env', 'prod')"}},
+		},
+	}})
+	if !p.allows(locationMatcher("us-east1-c")) {
+		t.Fatal("a conditional deny should be left to GCE")
+	}
+}
+
+// newOrgPolicyManager returns a slots manager whose template has an
+// external IP and the project's policies in policies, by constraint.
+func newOrgPolicyManager(t *testing.T, policies map[string]*orgpolicy.GoogleCloudOrgpolicyV2Policy) (*Manager, *[]*computepb.InsertInstanceRequest) {
+	m, reqs, _ := newSlotsManager(t)
+	m.config.OrgPolicyCheck = true
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{
+			{zone: "europe-west4-a", region: "europe-west4", available: 4},
+			{zone: "us-central1-a", region: "us-central1", available: 1},
+		}, nil
+	}
+	m.templatePropertiesFunc = func(context.Context, string) (*computepb.InstanceProperties, error) {
+		return &computepb.InstanceProperties{
+			MachineType: proto.String("a2-highgpu-1g"),
+			NetworkInterfaces: []*computepb.NetworkInterface{{
+				AccessConfigs: []*computepb.AccessConfig{{Name: proto.String("External NAT")}},
+			}},
+		}, nil
+	}
+	m.effectivePolicyFunc = func(_ context.Context, constraint string) (*orgpolicy.GoogleCloudOrgpolicyV2Policy, error) {
+		return policies[constraint], nil
+	}
+	return m, reqs
+}
+
+func TestCreateVMSkipsZonesOutsideAllowedLocations(t *testing.T) {
+	m, reqs := newOrgPolicyManager(t, map[string]*orgpolicy.GoogleCloudOrgpolicyV2Policy{
+		locationsConstraint: listValues([]string{"in:us-locations"}, nil),
+	})
+
+	if _, err := m.CreateVM(context.Background(), "a100-1", "jit-1"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if len(*reqs) != 1 || (*reqs)[0].GetZone() != "us-central1-a" {
+		t.Fatalf("inserted %d VMs, want one in us-central1-a", len(*reqs))
+	}
+}
+
+func TestCreateVMFailsFastOnExternalIPPolicy(t *testing.T) {
+	m, reqs := newOrgPolicyManager(t, map[string]*orgpolicy.GoogleCloudOrgpolicyV2Policy{
+		externalIPConstraint: {Spec: &orgpolicy.GoogleCloudOrgpolicyV2PolicySpec{
+			Rules: []*orgpolicy.GoogleCloudOrgpolicyV2PolicySpecPolicyRule{{DenyAll: true}},
+		}},
+	})
+
+	_, err := m.CreateVM(context.Background(), "a100-1", "jit-1")
+	if err == nil || !strings.Contains(err.Error(), "constraints/compute.vmExternalIpAccess") {
+		t.Fatalf("CreateVM error = %v, want the violated constraint named", err)
+	}
+	if len(*reqs) != 0 || m.ActiveCount() != 0 {
+		t.Fatalf("inserted %d VMs and tracks %d, want none", len(*reqs), m.ActiveCount())
+	}
+
+	m.config.NoExternalIP = true
+	if _, err := m.CreateVM(context.Background(), "a100-1", "jit-1"); err != nil {
+		t.Fatalf("CreateVM with NoExternalIP: %v", err)
+	}
+}
+
+func TestCreateVMChecksAllowedMachineTypes(t *testing.T) {
+	m, reqs := newOrgPolicyManager(t, nil)
+	m.config.OrgPolicyCheck = false
+	m.config.AllowedMachineTypes = []string{"g2-standard-*"}
+
+	_, err := m.CreateVM(context.Background(), "a100-1", "jit-1")
+	if err == nil || !strings.Contains(err.Error(), "a2-highgpu-1g") {
+		t.Fatalf("CreateVM error = %v, want the machine type named", err)
+	}
+	if len(*reqs) != 0 {
+		t.Fatalf("inserted %d VMs, want none", len(*reqs))
+	}
+
+	m.config.AllowedMachineTypes = []string{"g2-standard-*", "a2-*"}
+	if _, err := m.CreateVM(context.Background(), "a100-1", "jit-1"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+}
+
+func TestCheckOrgPolicies(t *testing.T) {
+	m, _ := newOrgPolicyManager(t, map[string]*orgpolicy.GoogleCloudOrgpolicyV2Policy{
+		locationsConstraint: listValues([]string{"in:us-locations"}, nil),
+	})
+	m.config.Zones = "us-central1-a,europe-west4-a"
+
+	errs := m.checkOrgPolicies(context.Background())
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "europe-west4-a is not an allowed location") {
+		t.Fatalf("checkOrgPolicies = %v, want europe-west4-a reported", errs)
+	}
+}
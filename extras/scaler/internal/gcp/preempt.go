@@ -0,0 +1,131 @@
+package gcp
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+)
+
+// preemptionCheckInterval is how often the zones of tracked VMs are checked
+// for preempted instances. The cleanup loop would notice the TERMINATED VM
+// too, but only every CleanupInterval.
+const preemptionCheckInterval = 30 * time.Second
+
+// preemptedFilter matches the operations GCE records when it preempts a
+// spot (or preemptible) instance.
+const preemptedFilter = `operationType = "compute.instances.preempted"`
+
+// SetPreemptionHandler registers a function called when a tracked VM is
+// preempted, after the manager has stopped tracking it. busy reports
+// whether the VM was running a job. The handler runs on the preemption
+// watch goroutine.
+func (m *Manager) SetPreemptionHandler(handler func(runnerName string, busy bool)) {
+	m.mu.Lock()
+	m.onPreempted = handler
+	m.mu.Unlock()
+}
+
+func (m *Manager) watchPreemptions(ctx context.Context) {
+	ticker := time.NewTicker(preemptionCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m.checkPreemptions(ctx)
+	}
+}
+
+// preemption is a preempted instance and when GCE preempted it.
+type preemption struct {
+	vmName string
+	at     time.Time
+}
+
+// checkPreemptions drops tracked VMs that GCE has preempted. A VM started
+// from the stopped pool keeps its name, so preemptions from before the VM
+// was last started are ignored.
+func (m *Manager) checkPreemptions(ctx context.Context) {
+	m.mu.Lock()
+	zones := make(map[string]bool)
+	for _, vm := range m.vms {
+		zones[vm.zone] = true
+	}
+	m.mu.Unlock()
+
+	for zone := range zones {
+		listCtx, cancel := context.WithTimeout(ctx, cleanupZoneScanTimeout)
+		preemptions, err := m.listPreempted(listCtx, zone)
+		cancel()
+		if err != nil {
+			slog.Warn("failed to list preempted VMs", "zone", zone, "error", err)
+			continue
+		}
+		for _, p := range preemptions {
+			m.preempted(p, zone)
+		}
+	}
+}
+
+func (m *Manager) preempted(p preemption, zone string) {
+	vmName := p.vmName
+	m.mu.Lock()
+	// A VM can run several runners, each tracked on its own.
+	busy := make(map[string]bool)
+	for rn, vm := range m.vms {
+		if vm.vmName == vmName && vm.zone == zone && (p.at.IsZero() || !p.at.Before(vm.createdAt)) {
+			busy[rn] = vm.busy
+			delete(m.vms, rn)
+		}
+	}
+	if len(busy) > 0 {
+		m.recordZonePreemptionLocked(zone, m.now())
+	}
+	handler := m.onPreempted
+	m.mu.Unlock()
+
+	for runnerName, busy := range busy {
+		slog.Warn("VM preempted", "event", "vm_preempted", "vm", vmName, "zone", zone, "runner", runnerName, "busy", busy)
+		if handler != nil {
+			handler(runnerName, busy)
+		}
+	}
+}
+
+// listPreempted returns the instances preempted in zone, as far back as
+// GCE keeps the operations.
+func (m *Manager) listPreempted(ctx context.Context, zone string) ([]preemption, error) {
+	if m.listPreemptedFunc != nil {
+		return m.listPreemptedFunc(ctx, zone)
+	}
+	if m.zoneOperationsClient == nil {
+		return nil, nil
+	}
+	it := m.zoneOperationsClient.List(ctx, &computepb.ListZoneOperationsRequest{
+		Project: m.config.Project,
+		Zone:    zone,
+		Filter:  proto.String(preemptedFilter),
+	})
+	var preemptions []preemption
+	for {
+		op, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return preemptions, err
+		}
+		// An unparsable time is zero, which counts as a preemption of the
+		// current boot.
+		at, _ := time.Parse(time.RFC3339, op.GetInsertTime())
+		preemptions = append(preemptions, preemption{vmName: path.Base(op.GetTargetLink()), at: at})
+	}
+	return preemptions, nil
+}
@@ -0,0 +1,50 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheckPreemptionsDropsPreemptedVMs(t *testing.T) {
+	m := &Manager{
+		vms: map[string]*vmInfo{
+			"runner-busy":  {vmName: "runner-busy", zone: "us-east1-c", busy: true},
+			"runner-idle":  {vmName: "runner-idle", zone: "us-east1-c"},
+			"runner-other": {vmName: "runner-other", zone: "us-west1-b"},
+		},
+	}
+	var listed []string
+	m.listPreemptedFunc = func(_ context.Context, zone string) ([]preemption, error) {
+		listed = append(listed, zone)
+		if zone == "us-east1-c" {
+			// An old preemption of a VM this manager never tracked is ignored.
+			return []preemption{{vmName: "runner-busy"}, {vmName: "runner-gone"}}, nil
+		}
+		return nil, nil
+	}
+	preempted := map[string]bool{}
+	m.SetPreemptionHandler(func(runnerName string, busy bool) {
+		preempted[runnerName] = busy
+	})
+
+	m.checkPreemptions(context.Background())
+
+	if len(listed) != 2 {
+		t.Fatalf("listed zones = %v, want the two zones with tracked VMs", listed)
+	}
+	if len(preempted) != 1 || !preempted["runner-busy"] {
+		t.Fatalf("preempted = %v, want runner-busy (busy)", preempted)
+	}
+	if _, ok := m.vms["runner-busy"]; ok {
+		t.Fatal("preempted VM is still tracked")
+	}
+	if len(m.vms) != 2 {
+		t.Fatalf("tracked VMs = %d, want 2", len(m.vms))
+	}
+
+	// The operation stays listed, but the VM is only reported once.
+	m.checkPreemptions(context.Background())
+	if len(preempted) != 1 {
+		t.Fatalf("preempted = %v after second check, want no new reports", preempted)
+	}
+}
@@ -0,0 +1,131 @@
+package gcp
+
+import (
+	"context"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// regionGPU keys the quota reads: each accelerator has its own quota.
+type regionGPU struct{ region, gpuType string }
+
+// quotaRead is a read of a region's quota for a GPU type, shared by the
+// creates that select zones while it is in flight or fresh.
+type quotaRead struct {
+	done chan struct{}
+	// Set once done is closed; quota is nil when the region has no quota
+	// for the GPU type.
+	quota *RegionQuota
+	err   error
+	// created counts the VMs created in the region since the read began,
+	// which its usage may not include yet. Guarded by Manager.mu.
+	created int
+}
+
+// regionQuota returns region's quota for gpuType, or nil when the region
+// has none. Concurrent callers share one Regions.Get call, and for
+// QuotaCacheTTL after it later callers reuse its result, less the VMs
+// created in the region since. A scale-up of N VMs would otherwise read
+// every region's quota N times within seconds.
+func (m *Manager) regionQuota(ctx context.Context, region, gpuType string) (*RegionQuota, error) {
+	key := regionGPU{region, gpuType}
+	m.mu.Lock()
+	r := m.quotaReads[key]
+	if r != nil {
+		select {
+		case <-r.done:
+			if r.err != nil || r.quota == nil || m.now().Sub(r.quota.CheckedAt) >= m.config.QuotaCacheTTL {
+				r = nil
+			}
+		default:
+		}
+	}
+	if r == nil {
+		r = &quotaRead{done: make(chan struct{})}
+		if m.quotaReads == nil {
+			m.quotaReads = make(map[regionGPU]*quotaRead)
+		}
+		m.quotaReads[key] = r
+		m.mu.Unlock()
+		r.quota, r.err = m.readRegionQuota(ctx, region, gpuType)
+		close(r.done)
+		if r.quota != nil {
+			m.recordQuota(*r.quota)
+		}
+		m.mu.Lock()
+	} else {
+		m.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-r.done:
+		}
+		m.mu.Lock()
+	}
+	defer m.mu.Unlock()
+	if r.err != nil || r.quota == nil {
+		return nil, r.err
+	}
+	q := *r.quota
+	q.Usage += float64(r.created)
+	q.Available -= float64(r.created)
+	return &q, nil
+}
+
+// readRegionQuota reads region's quota for gpuType from the Compute API.
+func (m *Manager) readRegionQuota(ctx context.Context, region, gpuType string) (*RegionQuota, error) {
+	var info *computepb.Region
+	err := m.retry(ctx, "Regions.Get", func(ctx context.Context) error {
+		var err error
+		if m.getRegionFunc != nil {
+			info, err = m.getRegionFunc(ctx, region)
+			return err
+		}
+		info, err = m.regionsClient.Get(ctx, &computepb.GetRegionRequest{
+			Project: m.config.Project,
+			Region:  region,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	metric := gpuQuotaMetric(gpuType)
+	for _, q := range info.GetQuotas() {
+		if q.GetMetric() == metric {
+			// GCP's reported usage already includes our running VMs, so
+			// we only need limit - usage (no double-subtraction).
+			return &RegionQuota{
+				Region:    region,
+				GPUType:   gpuType,
+				Limit:     q.GetLimit(),
+				Usage:     q.GetUsage(),
+				Available: q.GetLimit() - q.GetUsage(),
+				CheckedAt: m.now(),
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// quotaCreatedLocked counts a VM created in region against the quota read
+// in use for gpuType. The caller must hold m.mu.
+func (m *Manager) quotaCreatedLocked(region, gpuType string) {
+	if r := m.quotaReads[regionGPU{region, gpuType}]; r != nil {
+		r.created++
+	}
+}
+
+// forgetQuota drops the quota read of region for gpuType, so the next
+// create reads it afresh, e.g. after a create there exceeded the quota.
+func (m *Manager) forgetQuota(region, gpuType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r := m.quotaReads[regionGPU{region, gpuType}]; r != nil {
+		select {
+		case <-r.done:
+			delete(m.quotaReads, regionGPU{region, gpuType})
+		default:
+		}
+	}
+}
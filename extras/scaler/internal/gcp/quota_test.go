@@ -0,0 +1,133 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func testRegion(limit, usage float64) *computepb.Region {
+	return &computepb.Region{Quotas: []*computepb.Quota{
+		{Metric: proto.String("CPUS"), Limit: proto.Float64(100)},
+		{Metric: proto.String("NVIDIA_L4_GPUS"), Limit: proto.Float64(limit), Usage: proto.Float64(usage)},
+	}}
+}
+
+func TestRegionQuotaCoalescesReads(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := &Manager{
+		config:         ManagerConfig{Project: "test-project", InstanceTemplate: "t", GPUType: "nvidia-l4", Zones: "us-east1-b,us-east1-c", QuotaCacheTTL: 30 * time.Second},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		nowFunc:        func() time.Time { return now },
+	}
+	var reads atomic.Int32
+	release := make(chan struct{})
+	m.getRegionFunc = func(_ context.Context, region string) (*computepb.Region, error) {
+		reads.Add(1)
+		<-release
+		return testRegion(8, 2), nil
+	}
+	m.insertVMFunc = func(context.Context, *computepb.InsertInstanceRequest) error { return nil }
+
+	// The creates of a burst share one read.
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.CreateVM(context.Background(), fmt.Sprintf("runner-%d", i), "jit")
+			errs <- err
+		}()
+	}
+	for deadline := time.Now().Add(2 * time.Second); reads.Load() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the quota read")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("CreateVM: %v", err)
+		}
+	}
+	if n := reads.Load(); n != 1 {
+		t.Fatalf("Regions.Get calls = %d, want 1 for the burst", n)
+	}
+
+	// A fresh read serves later creates, less the VMs created since.
+	q, err := m.regionQuota(context.Background(), "us-east1", "nvidia-l4")
+	if err != nil || q == nil {
+		t.Fatalf("regionQuota = %v, %v", q, err)
+	}
+	if q.Available != 2 || q.Usage != 6 || reads.Load() != 1 {
+		t.Fatalf("cached quota = %+v after %d reads, want 2 of 8 available without a read", q, reads.Load())
+	}
+
+	// Once stale, or after a create exceeded it, the quota is read again.
+	now = now.Add(30 * time.Second)
+	if q, _ := m.regionQuota(context.Background(), "us-east1", "nvidia-l4"); reads.Load() != 2 || q.Available != 6 {
+		t.Fatalf("quota = %+v after %d reads, want a new read", q, reads.Load())
+	}
+	m.forgetQuota("us-east1", "nvidia-l4")
+	m.regionQuota(context.Background(), "us-east1", "nvidia-l4")
+	if n := reads.Load(); n != 3 {
+		t.Fatalf("Regions.Get calls = %d, want a read after forgetQuota", n)
+	}
+	if c := m.Capacity(); len(c.Quotas) != 1 || c.Quotas[0].Available != 6 {
+		t.Fatalf("Capacity quotas = %+v, want the last read", c.Quotas)
+	}
+}
+
+func TestRegionQuotaRetriesFailedReads(t *testing.T) {
+	m := &Manager{config: ManagerConfig{QuotaCacheTTL: time.Minute}}
+	fail := true
+	reads := 0
+	m.getRegionFunc = func(context.Context, string) (*computepb.Region, error) {
+		reads++
+		if fail {
+			return nil, errors.New("boom")
+		}
+		return testRegion(4, 0), nil
+	}
+	if _, err := m.regionQuota(context.Background(), "us-east1", "nvidia-l4"); err == nil {
+		t.Fatal("regionQuota should fail with the read")
+	}
+	fail = false
+	if q, err := m.regionQuota(context.Background(), "us-east1", "nvidia-l4"); err != nil || q.Available != 4 || reads != 2 {
+		t.Fatalf("regionQuota = %+v, %v after %d reads, want a new read", q, err, reads)
+	}
+	// A region without quota for the GPU type is nil.
+	if q, err := m.regionQuota(context.Background(), "us-east1", "nvidia-tesla-t4"); err != nil || q != nil {
+		t.Fatalf("regionQuota(T4) = %+v, %v, want nil", q, err)
+	}
+}
+
+func TestRegionQuotaWithoutCache(t *testing.T) {
+	m := &Manager{}
+	reads := 0
+	m.getRegionFunc = func(context.Context, string) (*computepb.Region, error) {
+		reads++
+		return testRegion(4, 0), nil
+	}
+	for range 3 {
+		if _, err := m.regionQuota(context.Background(), "us-east1", "nvidia-l4"); err != nil {
+			t.Fatalf("regionQuota: %v", err)
+		}
+	}
+	if reads != 3 {
+		t.Fatalf("Regions.Get calls = %d, want one per lookup without QuotaCacheTTL", reads)
+	}
+}
@@ -0,0 +1,67 @@
+package gcp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// apiLimiter is a token bucket holding the Compute API calls retry makes to
+// a rate, so a burst of creates and deletes does not run into the API's
+// rate limits. It allows bursts of one second's worth of calls. A nil
+// *apiLimiter does not limit.
+type apiLimiter struct {
+	rate  float64 // calls per second
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newAPILimiter returns a limiter of rate calls per second, or nil for a
+// rate of 0.
+func newAPILimiter(rate float64) *apiLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	burst := max(rate, 1)
+	return &apiLimiter{rate: rate, burst: burst, now: time.Now, tokens: burst}
+}
+
+// reserve takes a token and returns how long the caller must wait before
+// using it.
+func (l *apiLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// wait blocks until the caller may make a call, or ctx is done.
+func (l *apiLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	d := l.reserve()
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,35 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAPILimiter(t *testing.T) {
+	if (*apiLimiter)(nil).wait(context.Background()) != nil || newAPILimiter(0) != nil {
+		t.Fatal("a zero rate should not limit")
+	}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := newAPILimiter(2)
+	l.now = func() time.Time { return now }
+	// A burst of a second's worth goes through, then calls are spaced.
+	for i, want := range []time.Duration{0, 0, 500 * time.Millisecond, time.Second} {
+		if got := l.reserve(); got != want {
+			t.Fatalf("reserve %d = %s, want %s", i, got, want)
+		}
+	}
+	now = now.Add(3 * time.Second)
+	if got := l.reserve(); got != 0 {
+		t.Fatalf("reserve after the queue drained = %s, want 0", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = newAPILimiter(0.001)
+	l.reserve()
+	if err := l.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wait = %v, want the context's error", err)
+	}
+}
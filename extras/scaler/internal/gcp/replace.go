@@ -0,0 +1,51 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// ReplaceVM deletes the runner's VM and creates one for newRunnerName from
+// the same instance template and size, in a different zone when another candidate
+// is available. It is meant for VMs whose runner never came online, where
+// the zone (or the host the VM landed on) is the likely culprit. The old VM
+// stops being tracked even if deleting it fails; a VM whose runner cannot
+// register shuts itself down and is removed by the cleanup loop.
+func (m *Manager) ReplaceVM(ctx context.Context, runnerName, newRunnerName, jitConfig string) (string, error) {
+	m.mu.Lock()
+	vm, ok := m.vms[runnerName]
+	shared := false
+	if ok {
+		delete(m.vms, runnerName)
+		shared = m.runsOtherSlotsLocked(vm.vmName)
+	}
+	m.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("runner %q is not tracked", runnerName)
+	}
+	// A VM running several runners is kept for the others.
+	if !shared {
+		if err := m.deleteVMForCleanup(ctx, vm.vmName, vm.zone); err != nil {
+			slog.Warn("failed to delete VM being replaced", "vm", vm.vmName, "zone", vm.zone, "error", err)
+		}
+	}
+	return m.createVM(ctx, []RunnerSlot{{Name: newRunnerName, JITConfig: jitConfig}}, withSize(m.profileForTemplate(vm.template), vm.size), vm.zone)
+}
+
+// profileForTemplate returns the profile a VM created from template was
+// created with; VMs adopted from a previous run have no template recorded
+// and get the default.
+func (m *Manager) profileForTemplate(template string) vmProfile {
+	for _, route := range m.config.TemplateRoutes {
+		if route.InstanceTemplate != template || template == m.config.InstanceTemplate {
+			continue
+		}
+		profile := vmProfile{instanceTemplate: route.InstanceTemplate, gpuType: route.GPUType}
+		if profile.gpuType == "" {
+			profile.gpuType = m.config.GPUType
+		}
+		return profile
+	}
+	return m.defaultProfile()
+}
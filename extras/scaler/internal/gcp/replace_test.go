@@ -0,0 +1,85 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+func newReplaceManager(zones ...string) (*Manager, *[]*computepb.InsertInstanceRequest, *[]string) {
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			InstanceTemplate: "linux-gpu-runner",
+			GPUType:          "nvidia-tesla-t4",
+			Platform:         "linux",
+			TemplateRoutes: []TemplateRoute{
+				{Label: "GCP-A100", InstanceTemplate: "linux-gpu-runner-a100", GPUType: "nvidia-tesla-a100"},
+			},
+		},
+		vms: map[string]*vmInfo{
+			"runner-stuck": {vmName: "runner-stuck", zone: "us-east1-c", template: "linux-gpu-runner-a100"},
+		},
+		pendingCreates: map[string]zoneCandidate{},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		var candidates []zoneCandidate
+		for _, z := range zones {
+			candidates = append(candidates, zoneCandidate{zone: z, region: zoneRegion(z), available: 4})
+		}
+		return candidates, nil
+	}
+	var inserts []*computepb.InsertInstanceRequest
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		inserts = append(inserts, r)
+		return nil
+	}
+	var deleted []string
+	m.deleteVMFunc = func(_ context.Context, vmName, zone string) error {
+		deleted = append(deleted, vmName+"@"+zone)
+		return nil
+	}
+	return m, &inserts, &deleted
+}
+
+func TestReplaceVMMovesToAnotherZone(t *testing.T) {
+	m, inserts, deleted := newReplaceManager("us-east1-c", "us-east1-d")
+
+	vmName, err := m.ReplaceVM(context.Background(), "runner-stuck", "runner-retry", "jit-2")
+	if err != nil {
+		t.Fatalf("ReplaceVM: %v", err)
+	}
+	if vmName != "runner-retry" {
+		t.Fatalf("vmName = %q, want runner-retry", vmName)
+	}
+	if len(*deleted) != 1 || (*deleted)[0] != "runner-stuck@us-east1-c" {
+		t.Fatalf("deleted = %v, want the stuck VM", *deleted)
+	}
+	req := (*inserts)[0]
+	if req.GetZone() != "us-east1-d" {
+		t.Fatalf("replacement zone = %s, want us-east1-d (not the stuck VM's zone)", req.GetZone())
+	}
+	if got := req.GetSourceInstanceTemplate(); got != "projects/test-project/global/instanceTemplates/linux-gpu-runner-a100" {
+		t.Fatalf("replacement template = %s, want the stuck VM's", got)
+	}
+	if _, ok := m.vms["runner-stuck"]; ok {
+		t.Fatal("replaced VM is still tracked")
+	}
+	if _, ok := m.vms["runner-retry"]; !ok {
+		t.Fatal("replacement VM is not tracked")
+	}
+}
+
+func TestReplaceVMReusesOnlyZone(t *testing.T) {
+	m, inserts, _ := newReplaceManager("us-east1-c")
+	if _, err := m.ReplaceVM(context.Background(), "runner-stuck", "runner-retry", "jit-2"); err != nil {
+		t.Fatalf("ReplaceVM: %v", err)
+	}
+	if got := (*inserts)[0].GetZone(); got != "us-east1-c" {
+		t.Fatalf("replacement zone = %s, want the only candidate", got)
+	}
+	if _, err := m.ReplaceVM(context.Background(), "runner-stuck", "runner-retry-2", "jit-3"); err == nil {
+		t.Fatal("ReplaceVM of an untracked runner should fail")
+	}
+}
@@ -0,0 +1,121 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// reservationNameKey is the reservation affinity key selecting reservations
+// by name.
+const reservationNameKey = "compute.googleapis.com/reservation-name"
+
+// ReservationUsage is a reservation's size and use as of the last create
+// that read it.
+type ReservationUsage struct {
+	Name  string `json:"name"`
+	Zone  string `json:"zone"`
+	Count int64  `json:"count"`
+	// InUse counts every VM consuming the reservation, including ones the
+	// scaler did not create.
+	InUse     int64     `json:"in_use"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// reservedCandidates returns the candidates whose zone has a reservation
+// (see ManagerConfig.Reservations) with room for another VM, marked to
+// create into it. Creates in flight count against the room, since GCE only
+// counts a VM once it exists.
+func (m *Manager) reservedCandidates(ctx context.Context, candidates []zoneCandidate) []zoneCandidate {
+	var reserved []zoneCandidate
+	for _, candidate := range candidates {
+		name := m.config.Reservations[candidate.zone]
+		if name == "" {
+			continue
+		}
+		r, err := m.getReservation(ctx, candidate.zone, name)
+		if err != nil {
+			slog.Warn("failed to read reservation, creating on demand", "reservation", name, "zone", candidate.zone, "error", err)
+			continue
+		}
+		usage := ReservationUsage{
+			Name:      name,
+			Zone:      candidate.zone,
+			Count:     r.GetSpecificReservation().GetCount(),
+			InUse:     r.GetSpecificReservation().GetInUseCount(),
+			CheckedAt: m.now(),
+		}
+		m.mu.Lock()
+		if m.reservations == nil {
+			m.reservations = make(map[string]ReservationUsage)
+		}
+		m.reservations[candidate.zone+"/"+name] = usage
+		pending := int64(0)
+		for _, p := range m.pendingCreates {
+			if p.reservation == name && p.zone == candidate.zone && p.slot == 0 {
+				pending++
+			}
+		}
+		m.mu.Unlock()
+		if usage.InUse+pending < usage.Count {
+			candidate.reservation = name
+			reserved = append(reserved, candidate)
+		}
+	}
+	return reserved
+}
+
+// specificReservation returns the affinity that creates a VM in the named
+// reservation and nowhere else.
+func specificReservation(name string) *computepb.ReservationAffinity {
+	return &computepb.ReservationAffinity{
+		ConsumeReservationType: proto.String(computepb.ReservationAffinity_SPECIFIC_RESERVATION.String()),
+		Key:                    proto.String(reservationNameKey),
+		Values:                 []string{name},
+	}
+}
+
+// reservationCapacity fills in c's reservation usage and how many of the
+// tracked VMs run in a reservation. The caller must hold m.mu.
+func (m *Manager) reservationCapacity(c *Capacity) {
+	c.Reservations = make([]ReservationUsage, 0, len(m.reservations))
+	for _, r := range m.reservations {
+		c.Reservations = append(c.Reservations, r)
+	}
+	sort.Slice(c.Reservations, func(i, j int) bool {
+		if c.Reservations[i].Zone != c.Reservations[j].Zone {
+			return c.Reservations[i].Zone < c.Reservations[j].Zone
+		}
+		return c.Reservations[i].Name < c.Reservations[j].Name
+	})
+	for _, vm := range m.vms {
+		switch {
+		case vm.slot > 0:
+			// Counted with the VM's first runner.
+		case vm.reservation != "":
+			c.ReservedVMs++
+		default:
+			c.OnDemandVMs++
+		}
+	}
+}
+
+func (m *Manager) getReservation(ctx context.Context, zone, name string) (*computepb.Reservation, error) {
+	if m.getReservationFunc != nil {
+		return m.getReservationFunc(ctx, zone, name)
+	}
+	r, err := m.reservationsClient.Get(ctx, &computepb.GetReservationRequest{
+		Project:     m.config.Project,
+		Zone:        zone,
+		Reservation: name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading reservation %s in %s: %w", name, zone, err)
+	}
+	return r, nil
+}
@@ -0,0 +1,112 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func newReservationManager(t *testing.T, inUse *int64) (*Manager, *[]*computepb.InsertInstanceRequest) {
+	t.Helper()
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			InstanceTemplate: "win-gpu",
+			GPUType:          "nvidia-tesla-t4",
+			Reservations:     map[string]string{"us-west1-a": "t4-commit"},
+		},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+	}
+	// us-east1 has more quota, but us-west1-a has reserved capacity.
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{
+			{zone: "us-east1-c", region: "us-east1", available: 8},
+			{zone: "us-west1-a", region: "us-west1", available: 4},
+		}, nil
+	}
+	m.getReservationFunc = func(_ context.Context, zone, name string) (*computepb.Reservation, error) {
+		if zone != "us-west1-a" || name != "t4-commit" {
+			t.Fatalf("read reservation %s in %s", name, zone)
+		}
+		return &computepb.Reservation{SpecificReservation: &computepb.AllocationSpecificSKUReservation{
+			Count:      proto.Int64(2),
+			InUseCount: proto.Int64(*inUse),
+		}}, nil
+	}
+	var reqs []*computepb.InsertInstanceRequest
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		reqs = append(reqs, r)
+		return nil
+	}
+	return m, &reqs
+}
+
+func TestCreateVMPrefersReservationWithRoom(t *testing.T) {
+	inUse := int64(1)
+	m, reqs := newReservationManager(t, &inUse)
+
+	if _, err := m.CreateVM(context.Background(), "win-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	req := (*reqs)[0]
+	affinity := req.GetInstanceResource().GetReservationAffinity()
+	if req.GetZone() != "us-west1-a" || affinity.GetConsumeReservationType() != "SPECIFIC_RESERVATION" ||
+		len(affinity.GetValues()) != 1 || affinity.GetValues()[0] != "t4-commit" {
+		t.Fatalf("zone = %s, affinity = %v; want the t4-commit reservation", req.GetZone(), affinity)
+	}
+
+	// The reservation is now full.
+	inUse = 2
+	if _, err := m.CreateVM(context.Background(), "win-2", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	req = (*reqs)[1]
+	if req.GetZone() != "us-east1-c" || req.GetInstanceResource().GetReservationAffinity() != nil {
+		t.Fatalf("zone = %s, affinity = %v; want an on-demand VM", req.GetZone(), req.GetInstanceResource().GetReservationAffinity())
+	}
+
+	c := m.Capacity()
+	if c.ReservedVMs != 1 || c.OnDemandVMs != 1 {
+		t.Fatalf("reserved = %d, on demand = %d; want 1 each", c.ReservedVMs, c.OnDemandVMs)
+	}
+	if len(c.Reservations) != 1 || c.Reservations[0].Count != 2 || c.Reservations[0].InUse != 2 {
+		t.Fatalf("reservations = %+v", c.Reservations)
+	}
+}
+
+func TestCreateVMCountsPendingCreatesAgainstReservation(t *testing.T) {
+	inUse := int64(1)
+	m, _ := newReservationManager(t, &inUse)
+	m.pendingCreates["win-0"] = zoneCandidate{zone: "us-west1-a", region: "us-west1", gpuType: "nvidia-tesla-t4", reservation: "t4-commit"}
+
+	reserved := m.reservedCandidates(context.Background(), []zoneCandidate{{zone: "us-west1-a", region: "us-west1", available: 4}})
+	if len(reserved) != 0 {
+		t.Fatalf("reserved = %v, want none while the last slot is being created", reserved)
+	}
+}
+
+func TestCreateVMRetriesOnDemandWhenReservationFails(t *testing.T) {
+	inUse := int64(0)
+	m, reqs := newReservationManager(t, &inUse)
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		*reqs = append(*reqs, r)
+		if r.GetInstanceResource().GetReservationAffinity() != nil {
+			return errors.New("reservation t4-commit does not have available resources")
+		}
+		return nil
+	}
+
+	if _, err := m.CreateVM(context.Background(), "win-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if len(*reqs) != 2 || (*reqs)[1].GetInstanceResource().GetReservationAffinity() != nil {
+		t.Fatalf("inserts = %d, want a second, on-demand insert", len(*reqs))
+	}
+	if vm := m.vms["win-1"]; vm == nil || vm.reservation != "" {
+		t.Fatalf("tracked = %+v, want an on-demand VM", vm)
+	}
+}
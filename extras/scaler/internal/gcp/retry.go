@@ -0,0 +1,83 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// defaultRetryBackoff is the first wait between tries of a Compute API
+	// call without RetryBackoff.
+	defaultRetryBackoff = time.Second
+	// maxRetryBackoff caps the wait between tries.
+	maxRetryBackoff = 30 * time.Second
+)
+
+// retry runs call, one Compute API call named what, until it succeeds,
+// fails with an error that is not transient (see isRetryable), or has been
+// tried RetryAttempts times. The wait between tries starts at RetryBackoff
+// and doubles, with jitter, up to maxRetryBackoff. Each try waits for
+// APIRateLimit first. It returns the last error.
+func (m *Manager) retry(ctx context.Context, what string, call func(context.Context) error) error {
+	delay := m.config.RetryBackoff
+	if delay <= 0 {
+		delay = defaultRetryBackoff
+	}
+	for attempt := 1; ; attempt++ {
+		if err := m.apiLimit.wait(ctx); err != nil {
+			return err
+		}
+		err := call(ctx)
+		if err == nil || attempt >= m.config.RetryAttempts || !isRetryable(err) {
+			return err
+		}
+		// Wait between half and all of delay, so the retries of calls that
+		// failed together spread out.
+		wait := delay/2 + rand.N(delay/2+1)
+		slog.Warn("Compute API call failed, retrying", "call", what, "attempt", attempt, "retry_in", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay = min(2*delay, maxRetryBackoff)
+	}
+}
+
+// isRetryable reports whether a Compute API call failed on a transient
+// error that the same call may not hit again: a rate limit (429, or 403
+// with a rate limit reason), a server error, or a dropped connection. A
+// quota on resources such as GPUs or CPUs (see isQuotaExceeded), a zone
+// out of capacity and any other client error are permanent; creates move
+// on to another zone for them instead.
+func isRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		case http.StatusForbidden:
+			for _, item := range apiErr.Errors {
+				if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,92 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestIsRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limited", &googleapi.Error{Code: http.StatusTooManyRequests}, true},
+		{"unavailable", fmt.Errorf("inserting instance: %w", &googleapi.Error{Code: http.StatusServiceUnavailable}), true},
+		{"server error", &googleapi.Error{Code: http.StatusInternalServerError}, true},
+		{"rate limit quota", &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}, true},
+		{"dropped connection", &url.Error{Op: "Post", URL: "https://compute.googleapis.com", Err: io.ErrUnexpectedEOF}, true},
+		{"GPU quota", &googleapi.Error{Code: http.StatusForbidden, Message: "Quota 'NVIDIA_L4_GPUS' exceeded. Limit: 8.0 in region us-east1.", Errors: []googleapi.ErrorItem{{Reason: "quotaExceeded"}}}, false},
+		{"not found", &googleapi.Error{Code: http.StatusNotFound}, false},
+		{"bad request", &googleapi.Error{Code: http.StatusBadRequest}, false},
+		{"canceled", context.Canceled, false},
+		{"operation error", errors.New("ZONE_RESOURCE_POOL_EXHAUSTED"), false},
+	} {
+		if got := isRetryable(tc.err); got != tc.want {
+			t.Errorf("isRetryable(%s) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	m := &Manager{config: ManagerConfig{RetryAttempts: 3, RetryBackoff: time.Millisecond}}
+	unavailable := &googleapi.Error{Code: http.StatusServiceUnavailable}
+
+	calls := 0
+	err := m.retry(context.Background(), "Insert", func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return unavailable
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("retry = %v after %d calls, want success on the third", err, calls)
+	}
+
+	calls = 0
+	err = m.retry(context.Background(), "Insert", func(context.Context) error {
+		calls++
+		return unavailable
+	})
+	if !errors.Is(err, unavailable) || calls != 3 {
+		t.Fatalf("retry = %v after %d calls, want the last error after 3", err, calls)
+	}
+
+	calls = 0
+	notFound := &googleapi.Error{Code: http.StatusNotFound}
+	err = m.retry(context.Background(), "Delete", func(context.Context) error {
+		calls++
+		return notFound
+	})
+	if !errors.Is(err, notFound) || calls != 1 {
+		t.Fatalf("retry = %v after %d calls, want a permanent error at once", err, calls)
+	}
+
+	// Without RetryAttempts a call is tried once.
+	calls = 0
+	(&Manager{}).retry(context.Background(), "List", func(context.Context) error {
+		calls++
+		return unavailable
+	})
+	if calls != 1 {
+		t.Fatalf("calls without RetryAttempts = %d, want 1", calls)
+	}
+
+	// A canceled context stops the retries.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	slow := &Manager{config: ManagerConfig{RetryAttempts: 5, RetryBackoff: time.Hour}}
+	if err := slow.retry(ctx, "List", func(context.Context) error { calls++; return unavailable }); !errors.Is(err, unavailable) || calls != 1 {
+		t.Fatalf("retry after cancel = %v after %d calls", err, calls)
+	}
+}
@@ -0,0 +1,89 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// ReuseVM hands the runner's VM a new JIT config for its next job. The VM
+// must have been created with ReuseVMs: its startup script then waits for
+// the jit-config metadata to change after the runner exits, and starts a
+// runner with the new value. The JIT config must be for the same runner
+// name. The VM is tracked as idle again from now on, so orphan eviction
+// applies if no job arrives.
+func (m *Manager) ReuseVM(ctx context.Context, runnerName, jitConfig string) error {
+	m.mu.Lock()
+	vm, ok := m.vms[runnerName]
+	var vmName, zone string
+	if ok {
+		// Mark the VM idle before the new config reaches it, so a job
+		// starting right away is not overwritten back to idle.
+		vm.busy = false
+		vm.reusedAt = m.now()
+		vm.phase, vm.bootError = "", ""
+		vmName, zone = vm.vmName, vm.zone
+	}
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("runner %q is not tracked", runnerName)
+	}
+	return m.setJITConfig(ctx, vmName, zone, jitConfig)
+}
+
+// setJITConfig replaces the instance's jit-config metadata item, keeping
+// the others. With JITConfigKMSKey, the JIT config is sealed first and the
+// key recorded too, for VMs created before it was set.
+func (m *Manager) setJITConfig(ctx context.Context, vmName, zone, jitConfig string) (err error) {
+	if jitConfig, err = m.sealJITConfig(ctx, jitConfig); err != nil {
+		return err
+	}
+	if m.setJITConfigFunc != nil {
+		return m.setJITConfigFunc(ctx, vmName, zone, jitConfig)
+	}
+	ctx, span := tracer.Start(ctx, "gcp.SetMetadata")
+	defer func() { endSpan(span, err) }()
+
+	inst, err := m.instancesClient.Get(ctx, &computepb.GetInstanceRequest{
+		Project:  m.config.Project,
+		Zone:     zone,
+		Instance: vmName,
+	})
+	if err != nil {
+		return fmt.Errorf("reading instance %s: %w", vmName, err)
+	}
+	// The metadata carries the fingerprint SetMetadata needs to detect
+	// concurrent changes.
+	md := inst.GetMetadata()
+	if md == nil {
+		md = &computepb.Metadata{}
+	}
+	items := map[string]string{"jit-config": jitConfig}
+	if m.config.JITConfigKMSKey != "" {
+		items[jitConfigKMSKeyItem] = m.config.JITConfigKMSKey
+	}
+	for _, item := range md.Items {
+		if value, ok := items[item.GetKey()]; ok {
+			item.Value = proto.String(value)
+			delete(items, item.GetKey())
+		}
+	}
+	for key, value := range items {
+		md.Items = append(md.Items, &computepb.Items{Key: proto.String(key), Value: proto.String(value)})
+	}
+	op, err := m.instancesClient.SetMetadata(ctx, &computepb.SetMetadataInstanceRequest{
+		Project:          m.config.Project,
+		Zone:             zone,
+		Instance:         vmName,
+		MetadataResource: md,
+	})
+	if err != nil {
+		return fmt.Errorf("setting metadata of %s: %w", vmName, err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("waiting for metadata update of %s: %w", vmName, err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+func TestCreateVMMarksReuse(t *testing.T) {
+	m := &Manager{
+		config:         ManagerConfig{Project: "test-project", InstanceTemplate: "t", GPUType: "none", ReuseVMs: true},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-d", region: "us-east1"}}, nil
+	}
+	var req *computepb.InsertInstanceRequest
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		req = r
+		return nil
+	}
+	if _, err := m.CreateVM(context.Background(), "build-test", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	var reuse string
+	for _, item := range req.GetInstanceResource().GetMetadata().GetItems() {
+		if item.GetKey() == "runner-reuse" {
+			reuse = item.GetValue()
+		}
+	}
+	if reuse != "true" {
+		t.Fatalf("runner-reuse metadata = %q, want true", reuse)
+	}
+	if err := validateMetadata(map[string]string{"runner-reuse": "false"}); err == nil {
+		t.Fatal("runner-reuse should be a reserved metadata key")
+	}
+}
+
+func TestReuseVMSetsJITConfigAndMarksIdle(t *testing.T) {
+	now := time.Date(2026, 5, 11, 0, 0, 0, 0, time.UTC)
+	created := now.Add(-time.Hour)
+	var gotVM, gotZone, gotConfig string
+	m := &Manager{
+		nowFunc: fakeClock(now),
+		vms: map[string]*vmInfo{
+			"build-1": {vmName: "build-1", zone: "us-east1-c", createdAt: created},
+		},
+		setJITConfigFunc: func(_ context.Context, vmName, zone, jitConfig string) error {
+			gotVM, gotZone, gotConfig = vmName, zone, jitConfig
+			return nil
+		},
+	}
+	m.MarkBusy("build-1")
+
+	if err := m.ReuseVM(context.Background(), "build-1", "jit-2"); err != nil {
+		t.Fatalf("ReuseVM: %v", err)
+	}
+	if gotVM != "build-1" || gotZone != "us-east1-c" || gotConfig != "jit-2" {
+		t.Fatalf("setJITConfig(%q, %q, %q), want build-1 in us-east1-c with jit-2", gotVM, gotZone, gotConfig)
+	}
+	vms := m.VMs()
+	if len(vms) != 1 {
+		t.Fatalf("VMs = %d, want 1", len(vms))
+	}
+	if vms[0].Busy {
+		t.Fatal("reused VM should be idle until its next job starts")
+	}
+	if !vms[0].ReusedAt.Equal(now) || !vms[0].CreatedAt.Equal(created) {
+		t.Fatalf("ReusedAt = %v, CreatedAt = %v; want %v and the original creation time", vms[0].ReusedAt, vms[0].CreatedAt, now)
+	}
+	if vms[0].Jobs != 1 {
+		t.Fatalf("Jobs = %d, want 1", vms[0].Jobs)
+	}
+}
+
+func TestReuseVMErrors(t *testing.T) {
+	m := &Manager{
+		vms: map[string]*vmInfo{"build-1": {vmName: "build-1", zone: "us-east1-c"}},
+		setJITConfigFunc: func(context.Context, string, string, string) error {
+			return errors.New("metadata fingerprint mismatch")
+		},
+	}
+	if err := m.ReuseVM(context.Background(), "build-2", "jit"); err == nil {
+		t.Fatal("ReuseVM of an untracked runner should fail")
+	}
+	if err := m.ReuseVM(context.Background(), "build-1", "jit"); err == nil {
+		t.Fatal("ReuseVM should report a failed metadata update")
+	}
+}
+
+func TestEvictStaleOrphansCountsIdleTimeFromReuse(t *testing.T) {
+	now := time.Date(2026, 5, 11, 0, 0, 0, 0, time.UTC)
+	m := &Manager{
+		config:  ManagerConfig{OrphanGracePeriod: 30 * time.Minute},
+		nowFunc: fakeClock(now),
+		vms: map[string]*vmInfo{
+			"build-1": {vmName: "build-1", zone: "us-east1-c", createdAt: now.Add(-3 * time.Hour), reusedAt: now.Add(-10 * time.Minute)},
+		},
+		deleteVMFunc: func(context.Context, string, string) error {
+			t.Fatal("a VM reused within the grace period should not be evicted")
+			return nil
+		},
+	}
+	m.evictStaleOrphans(context.Background())
+	if _, ok := m.vms["build-1"]; !ok {
+		t.Fatal("recently reused VM was dropped from tracking")
+	}
+}
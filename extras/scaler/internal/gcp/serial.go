@@ -0,0 +1,56 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// serialTailLines is how many lines of a VM's serial console SerialOutput
+// returns.
+const serialTailLines = 40
+
+// SerialOutput returns the last lines the runner's VM wrote to its serial
+// console, where the startup scripts log, to diagnose a VM that never got
+// its runner online.
+func (m *Manager) SerialOutput(ctx context.Context, runnerName string) (string, error) {
+	m.mu.Lock()
+	vm, ok := m.vms[runnerName]
+	m.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("no VM found for runner %q", runnerName)
+	}
+	out, err := m.serialOutput(ctx, vm.vmName, vm.zone)
+	if err != nil {
+		return "", err
+	}
+	return tailLines(out, serialTailLines), nil
+}
+
+func (m *Manager) serialOutput(ctx context.Context, vmName, zone string) (string, error) {
+	if m.getSerialOutputFunc != nil {
+		return m.getSerialOutputFunc(ctx, vmName, zone)
+	}
+	out, err := m.instancesClient.GetSerialPortOutput(ctx, &computepb.GetSerialPortOutputInstanceRequest{
+		Project:  m.config.Project,
+		Zone:     zone,
+		Instance: vmName,
+		Port:     proto.Int32(1),
+	})
+	if err != nil {
+		return "", fmt.Errorf("reading serial output of %s in %s: %w", vmName, zone, err)
+	}
+	return out.GetContents(), nil
+}
+
+// tailLines returns the last n lines of s, without a trailing newline.
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\r\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
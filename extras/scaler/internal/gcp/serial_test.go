@@ -0,0 +1,35 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSerialOutputReturnsTheTail(t *testing.T) {
+	var out strings.Builder
+	for i := range 100 {
+		fmt.Fprintf(&out, "line %d\n", i)
+	}
+	m := &Manager{
+		vms: map[string]*vmInfo{"win-1": {vmName: "win-1", zone: "us-east1-c"}},
+		getSerialOutputFunc: func(_ context.Context, vmName, zone string) (string, error) {
+			if vmName != "win-1" || zone != "us-east1-c" {
+				t.Errorf("serial output of %s in %s", vmName, zone)
+			}
+			return out.String(), nil
+		},
+	}
+	got, err := m.SerialOutput(context.Background(), "win-1")
+	if err != nil {
+		t.Fatalf("SerialOutput: %v", err)
+	}
+	lines := strings.Split(got, "\n")
+	if len(lines) != serialTailLines || lines[0] != "line 60" || lines[len(lines)-1] != "line 99" {
+		t.Fatalf("SerialOutput = %d lines from %q to %q", len(lines), lines[0], lines[len(lines)-1])
+	}
+	if _, err := m.SerialOutput(context.Background(), "win-2"); err == nil {
+		t.Fatal("SerialOutput of an unknown runner should fail")
+	}
+}
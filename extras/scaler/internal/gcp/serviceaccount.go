@@ -0,0 +1,76 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+const scopePrefix = "https://www.googleapis.com/auth/"
+
+// overridesServiceAccount reports whether ServiceAccount or
+// ServiceAccountScopes is set.
+func (m *Manager) overridesServiceAccount() bool {
+	return m.config.ServiceAccount != "" || len(m.config.ServiceAccountScopes) > 0
+}
+
+// serviceAccounts returns the service account to attach to VMs. Setting only
+// scopes keeps the template's account; setting only the account grants it
+// cloud-platform, leaving access control to its IAM roles.
+func (m *Manager) serviceAccounts(ctx context.Context, template string) ([]*computepb.ServiceAccount, error) {
+	email := m.config.ServiceAccount
+	if email == "" {
+		props, err := m.templateProperties(ctx, template)
+		if err != nil {
+			return nil, err
+		}
+		accounts := props.GetServiceAccounts()
+		if len(accounts) == 0 {
+			return nil, fmt.Errorf("instance template %s has no service account to apply scopes to", template)
+		}
+		email = accounts[0].GetEmail()
+	}
+
+	scopes := m.config.ServiceAccountScopes
+	if len(scopes) == 0 {
+		scopes = []string{"cloud-platform"}
+	}
+	return []*computepb.ServiceAccount{{
+		Email:  proto.String(email),
+		Scopes: expandScopes(scopes),
+	}}, nil
+}
+
+// ServiceAccount returns the email of the service account VMs created for
+// a job with labels run as: ServiceAccount, or their instance template's.
+// It is empty for a template without one.
+func (m *Manager) ServiceAccount(ctx context.Context, labels []string) (string, error) {
+	if m.config.ServiceAccount != "" {
+		return m.config.ServiceAccount, nil
+	}
+	props, err := m.templateProperties(ctx, m.routeProfile(labels).instanceTemplate)
+	if err != nil {
+		return "", err
+	}
+	if accounts := props.GetServiceAccounts(); len(accounts) > 0 {
+		return accounts[0].GetEmail(), nil
+	}
+	return "", nil
+}
+
+// expandScopes turns short scope names ("devstorage.read_write") into full
+// scope URLs; URLs are used as given.
+func expandScopes(scopes []string) []string {
+	out := make([]string, len(scopes))
+	for i, s := range scopes {
+		if strings.Contains(s, "://") {
+			out[i] = s
+		} else {
+			out[i] = scopePrefix + s
+		}
+	}
+	return out
+}
@@ -0,0 +1,85 @@
+package gcp
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func newServiceAccountTestManager(cfg ManagerConfig) (*Manager, *[]*computepb.InsertInstanceRequest) {
+	cfg.Project = "slang-runners"
+	cfg.InstanceTemplate = "linux-gpu-runner"
+	cfg.GPUType = "none"
+	m := &Manager{
+		config:         cfg,
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		templatePropertiesFunc: func(context.Context, string) (*computepb.InstanceProperties, error) {
+			return &computepb.InstanceProperties{
+				ServiceAccounts: []*computepb.ServiceAccount{{
+					Email:  proto.String("runner@slang-runners.iam.gserviceaccount.com"),
+					Scopes: []string{scopePrefix + "cloud-platform"},
+				}},
+			}, nil
+		},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-d", region: "us-east1"}}, nil
+	}
+	var reqs []*computepb.InsertInstanceRequest
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		reqs = append(reqs, r)
+		return nil
+	}
+	return m, &reqs
+}
+
+func TestCreateVMOverridesServiceAccount(t *testing.T) {
+	m, reqs := newServiceAccountTestManager(ManagerConfig{
+		ServiceAccount:       "benchmark@slang-runners.iam.gserviceaccount.com",
+		ServiceAccountScopes: []string{"devstorage.read_write", "https://www.googleapis.com/auth/logging.write"},
+	})
+	if _, err := m.CreateVM(context.Background(), "vm-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	accounts := (*reqs)[0].GetInstanceResource().GetServiceAccounts()
+	if len(accounts) != 1 || accounts[0].GetEmail() != "benchmark@slang-runners.iam.gserviceaccount.com" {
+		t.Fatalf("service accounts = %v", accounts)
+	}
+	want := []string{scopePrefix + "devstorage.read_write", scopePrefix + "logging.write"}
+	if !slices.Equal(accounts[0].GetScopes(), want) {
+		t.Fatalf("scopes = %v, want %v", accounts[0].GetScopes(), want)
+	}
+}
+
+func TestCreateVMServiceAccountDefaults(t *testing.T) {
+	m, reqs := newServiceAccountTestManager(ManagerConfig{ServiceAccount: "benchmark@slang-runners.iam.gserviceaccount.com"})
+	if _, err := m.CreateVM(context.Background(), "vm-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if got := (*reqs)[0].GetInstanceResource().GetServiceAccounts()[0].GetScopes(); !slices.Equal(got, []string{scopePrefix + "cloud-platform"}) {
+		t.Fatalf("scopes = %v, want cloud-platform when only the account is set", got)
+	}
+
+	m, reqs = newServiceAccountTestManager(ManagerConfig{ServiceAccountScopes: []string{"devstorage.read_only"}})
+	if _, err := m.CreateVM(context.Background(), "vm-2", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if got := (*reqs)[0].GetInstanceResource().GetServiceAccounts()[0].GetEmail(); got != "runner@slang-runners.iam.gserviceaccount.com" {
+		t.Fatalf("email = %q, want the template's account when only scopes are set", got)
+	}
+}
+
+func TestServiceAccountReportsTheVMsIdentity(t *testing.T) {
+	m, _ := newServiceAccountTestManager(ManagerConfig{})
+	if got, err := m.ServiceAccount(context.Background(), nil); err != nil || got != "runner@slang-runners.iam.gserviceaccount.com" {
+		t.Fatalf("ServiceAccount = %q, %v; want the template's", got, err)
+	}
+	m.config.ServiceAccount = "benchmark@slang-runners.iam.gserviceaccount.com"
+	if got, _ := m.ServiceAccount(context.Background(), nil); got != m.config.ServiceAccount {
+		t.Fatalf("ServiceAccount = %q, want --service-account", got)
+	}
+}
@@ -0,0 +1,115 @@
+package gcp
+
+import (
+	"context"
+	_ "embed"
+	"log/slog"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// shutdownCheckInterval is how often the manager reads the runner/shutdown
+// guest attribute of tracked VMs (see ManagerConfig.ShutdownScript). It is
+// shorter than a spot VM's 30 second preemption notice.
+const shutdownCheckInterval = 15 * time.Second
+
+// Reasons the shutdown scripts report in the runner/shutdown guest
+// attribute.
+const (
+	ShutdownPreempted = "preempted"
+	ShutdownStopped   = "stopped"
+)
+
+//go:embed shutdown.ps1
+var windowsShutdownScript string
+
+//go:embed shutdown.sh
+var linuxShutdownScript string
+
+// SetShutdownHandler registers a function called when a tracked VM reports
+// going down with its runner still running, other than by preemption (see
+// SetPreemptionHandler), after the manager has stopped tracking it. The
+// handler runs on the shutdown watch goroutine.
+func (m *Manager) SetShutdownHandler(handler func(runnerName string, busy bool)) {
+	m.mu.Lock()
+	m.onShutdown = handler
+	m.mu.Unlock()
+}
+
+func (m *Manager) watchShutdowns(ctx context.Context) {
+	ticker := time.NewTicker(shutdownCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m.pollShutdowns(ctx)
+	}
+}
+
+// pollShutdowns reads the runner/shutdown guest attribute of every tracked
+// VM, once per VM however many runners it runs.
+func (m *Manager) pollShutdowns(ctx context.Context) {
+	m.mu.Lock()
+	vms := make(map[string]string) // VM name -> zone
+	for _, vm := range m.vms {
+		vms[vm.vmName] = vm.zone
+	}
+	m.mu.Unlock()
+
+	for vmName, zone := range vms {
+		attrs, err := m.guestAttributes(ctx, vmName, zone)
+		if err != nil {
+			slog.Debug("failed to read shutdown reason", "vm", vmName, "zone", zone, "error", err)
+			continue
+		}
+		switch reason := attrs["shutdown"]; reason {
+		case "":
+		case ShutdownPreempted:
+			// The preemption operation shows up later; the zero time
+			// counts as a preemption of the current boot.
+			m.preempted(preemption{vmName: vmName}, zone)
+		default:
+			m.shutDown(vmName, zone, reason)
+		}
+	}
+}
+
+// shutDown drops the runners of vmName, which its shutdown script reported
+// going down for reason.
+func (m *Manager) shutDown(vmName, zone, reason string) {
+	m.mu.Lock()
+	busy := make(map[string]bool)
+	for rn, vm := range m.vms {
+		if vm.vmName == vmName && vm.zone == zone {
+			busy[rn] = vm.busy
+			delete(m.vms, rn)
+		}
+	}
+	handler := m.onShutdown
+	m.mu.Unlock()
+
+	for runnerName, busy := range busy {
+		slog.Warn("VM shut down with its runner running", "event", "vm_shutdown", "vm", vmName, "zone", zone, "runner", runnerName, "busy", busy, "reason", reason)
+		if handler != nil {
+			handler(runnerName, busy)
+		}
+	}
+}
+
+// shutdownMetadataItems returns the platform's shutdown script when
+// ShutdownScript is set.
+func (m *Manager) shutdownMetadataItems() []*computepb.Items {
+	if !m.config.ShutdownScript {
+		return nil
+	}
+	key, script := "windows-shutdown-script-ps1", windowsShutdownScript
+	if m.config.Platform == "linux" {
+		key, script = "shutdown-script", linuxShutdownScript
+	}
+	return []*computepb.Items{{Key: proto.String(key), Value: proto.String(script)}}
+}
@@ -0,0 +1,64 @@
+# Windows Runner Shutdown Script
+#
+# GCE runs this when the VM is stopped, deleted or preempted (spot VMs get
+# 30 seconds). When a runner is still running its job, it:
+# 1. Reports why the VM is going down in the runner/shutdown guest
+#    attribute (preempted or stopped), so the scaler removes the runner's
+#    registration at once instead of leaving it offline in GitHub
+# 2. Sends each runner Ctrl+C, which cancels its job and closes its session
+#    with GitHub, and waits briefly for them to exit
+# A VM shutting itself down after its job has no runner left and reports
+# nothing.
+
+$logFile = "C:\actions-runner\shutdown.log"
+
+function Write-Log {
+    param([string]$Message)
+    $line = "$(Get-Date -Format 'yyyy-MM-dd HH:mm:ss') - $Message"
+    Write-Host $line
+    Add-Content -Path $logFile -Value $line -ErrorAction SilentlyContinue
+}
+
+$listeners = @(Get-Process -Name "Runner.Listener" -ErrorAction SilentlyContinue)
+if ($listeners.Count -eq 0) {
+    exit 0
+}
+
+$reason = "stopped"
+try {
+    if ((Invoke-RestMethod -Uri "http://metadata.google.internal/computeMetadata/v1/instance/preempted" -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 5) -eq "TRUE") {
+        $reason = "preempted"
+    }
+}
+catch {
+}
+Write-Log "VM $reason with a runner running; stopping it"
+try {
+    Invoke-RestMethod -Method Put -Uri "http://metadata.google.internal/computeMetadata/v1/instance/guest-attributes/runner/shutdown" -Headers @{ "Metadata-Flavor" = "Google" } -Body $reason -TimeoutSec 5 | Out-Null
+}
+catch {
+    Write-Log "WARNING: Failed to report guest attribute shutdown: $_"
+}
+
+# Windows has no SIGINT; attaching to the runner's console and raising
+# Ctrl+C there is what the runner handles as a graceful stop.
+Add-Type -Namespace Scaler -Name Console -MemberDefinition @"
+[DllImport("kernel32.dll")] public static extern bool AttachConsole(uint pid);
+[DllImport("kernel32.dll")] public static extern bool FreeConsole();
+[DllImport("kernel32.dll")] public static extern bool SetConsoleCtrlHandler(System.IntPtr handler, bool add);
+[DllImport("kernel32.dll")] public static extern bool GenerateConsoleCtrlEvent(uint ctrlEvent, uint processGroup);
+"@
+[Scaler.Console]::SetConsoleCtrlHandler([System.IntPtr]::Zero, $true) | Out-Null
+foreach ($listener in $listeners) {
+    [Scaler.Console]::FreeConsole() | Out-Null
+    if ([Scaler.Console]::AttachConsole([uint32]$listener.Id)) {
+        [Scaler.Console]::GenerateConsoleCtrlEvent(0, 0) | Out-Null
+    }
+}
+[Scaler.Console]::FreeConsole() | Out-Null
+
+$deadline = (Get-Date).AddSeconds(20)
+while ((Get-Date) -lt $deadline -and @(Get-Process -Name "Runner.Listener" -ErrorAction SilentlyContinue).Count -gt 0) {
+    Start-Sleep -Seconds 1
+}
+Write-Log "Runner stopped"
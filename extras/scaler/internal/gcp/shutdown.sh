@@ -0,0 +1,45 @@
+#!/bin/bash
+# Linux Runner Shutdown Script
+#
+# GCE runs this as root when the VM is stopped, deleted or preempted (spot
+# VMs get 30 seconds). When a runner is still running its job, it:
+# 1. Reports why the VM is going down in the runner/shutdown guest
+#    attribute (preempted or stopped), so the scaler removes the runner's
+#    registration at once instead of leaving it offline in GitHub
+# 2. Interrupts each runner, which cancels its job and closes its session
+#    with GitHub, and waits briefly for them to exit
+# A VM shutting itself down after its job has no runner left and reports
+# nothing.
+
+LOG_FILE="/tmp/runner-shutdown.log"
+
+log() {
+  echo "$(date '+%Y-%m-%d %H:%M:%S') - $1" | tee -a "$LOG_FILE"
+}
+
+metadata() {
+  curl -sf --max-time 5 --connect-timeout 2 -H "Metadata-Flavor: Google" \
+    "http://metadata.google.internal/computeMetadata/v1/instance/$1" 2>/dev/null || true
+}
+
+listeners="$(pgrep -f 'bin/Runner.Listener' || true)"
+if [ -z "$listeners" ]; then
+  exit 0
+fi
+
+reason=stopped
+if [ "$(metadata preempted)" = "TRUE" ]; then
+  reason=preempted
+fi
+log "VM ${reason} with a runner running; stopping it"
+curl -sf --max-time 5 --connect-timeout 2 -X PUT --data "$reason" -H "Metadata-Flavor: Google" \
+  "http://metadata.google.internal/computeMetadata/v1/instance/guest-attributes/runner/shutdown" >/dev/null 2>&1 ||
+  log "WARNING: Failed to report guest attribute shutdown"
+
+# shellcheck disable=SC2086 # one PID per word
+kill -INT $listeners 2>/dev/null || true
+for _ in $(seq 1 20); do
+  pgrep -f 'bin/Runner.Listener' >/dev/null || break
+  sleep 1
+done
+log "Runner stopped"
@@ -0,0 +1,65 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPollShutdownsDropsStoppedVMs(t *testing.T) {
+	reasons := map[string]string{
+		"spot-1": ShutdownPreempted,
+		"win-2":  ShutdownStopped,
+	}
+	reads := map[string]int{}
+	m := &Manager{
+		vms: map[string]*vmInfo{
+			"spot-1": {vmName: "spot-1", zone: "us-east1-c", busy: true},
+			// Two runners on one VM.
+			"win-2": {vmName: "win-2", zone: "us-east1-c", busy: true},
+			"win-3": {vmName: "win-2", zone: "us-east1-c", slot: 1},
+			"win-4": {vmName: "win-4", zone: "us-east1-c"},
+		},
+		getGuestAttributesFunc: func(_ context.Context, vmName, _ string) (map[string]string, error) {
+			reads[vmName]++
+			return map[string]string{"shutdown": reasons[vmName]}, nil
+		},
+	}
+	preempted := map[string]bool{}
+	m.SetPreemptionHandler(func(runnerName string, busy bool) { preempted[runnerName] = busy })
+	shutDown := map[string]bool{}
+	m.SetShutdownHandler(func(runnerName string, busy bool) { shutDown[runnerName] = busy })
+
+	m.pollShutdowns(context.Background())
+
+	if len(reads) != 3 || reads["win-2"] != 1 {
+		t.Fatalf("guest attribute reads = %v, want each VM once", reads)
+	}
+	if len(preempted) != 1 || !preempted["spot-1"] {
+		t.Fatalf("preempted = %v, want spot-1 (busy)", preempted)
+	}
+	if len(shutDown) != 2 || !shutDown["win-2"] || shutDown["win-3"] {
+		t.Fatalf("shut down = %v, want win-2 (busy) and win-3", shutDown)
+	}
+	if _, ok := m.vms["win-4"]; !ok || len(m.vms) != 1 {
+		t.Fatalf("tracked VMs = %v, want only win-4", m.vms)
+	}
+}
+
+func TestShutdownScriptMetadata(t *testing.T) {
+	m := &Manager{config: ManagerConfig{ShutdownScript: true, Platform: "linux"}}
+	items := m.shutdownMetadataItems()
+	if len(items) != 1 || items[0].GetKey() != "shutdown-script" || items[0].GetValue() != linuxShutdownScript {
+		t.Fatalf("metadata = %v, want the Linux shutdown script", items)
+	}
+	m.config.Platform = "windows"
+	if items := m.shutdownMetadataItems(); len(items) != 1 || items[0].GetKey() != "windows-shutdown-script-ps1" {
+		t.Fatalf("metadata = %v, want the Windows shutdown script", items)
+	}
+	if items := m.guestAttributeMetadataItems(); len(items) != 1 || items[0].GetKey() != "enable-guest-attributes" {
+		t.Fatalf("guest attribute metadata = %v, want guest attributes enabled", items)
+	}
+	m.config.ShutdownScript = false
+	if items := m.shutdownMetadataItems(); len(items) != 0 {
+		t.Fatalf("metadata = %v, want none without ShutdownScript", items)
+	}
+}
@@ -0,0 +1,237 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultSizedCPUs is the vCPU count of a VM whose size changes its
+// machine series (see sizeGPUs) without naming a cpu- or mem- size.
+const defaultSizedCPUs = 8
+
+// sizeGPU is a GPU a gpu- size label can name: its accelerator type and
+// the machine series it attaches to.
+type sizeGPU struct {
+	accelerator string
+	series      string
+}
+
+var sizeGPUs = map[string]sizeGPU{
+	"t4":   {accelerator: "nvidia-tesla-t4", series: "n1"},
+	"p4":   {accelerator: "nvidia-tesla-p4", series: "n1"},
+	"p100": {accelerator: "nvidia-tesla-p100", series: "n1"},
+	"v100": {accelerator: "nvidia-tesla-v100", series: "n1"},
+	"l4":   {accelerator: "nvidia-l4", series: "g2"},
+	"a100": {accelerator: "nvidia-tesla-a100", series: "a2"},
+}
+
+// g2CPUs are the vCPU counts of the g2-standard machine types, which have
+// 4 GB of memory per vCPU.
+var g2CPUs = []int{4, 8, 12, 16, 24, 32, 48, 96}
+
+// VMSize is the VM shape a job asks for with size labels in its runs-on
+// labels (see ManagerConfig.SizeLabels). Zero fields keep the template's.
+type VMSize struct {
+	// GPU is a key of sizeGPUs, e.g. "a100", or "none".
+	GPU      string
+	CPUs     int
+	MemoryGB int
+}
+
+// IsZero reports whether s keeps the template's shape.
+func (s VMSize) IsZero() bool { return s == VMSize{} }
+
+// String returns s as its size labels, e.g. "gpu-a100,cpu-32,mem-128".
+func (s VMSize) String() string {
+	var labels []string
+	if s.GPU != "" {
+		labels = append(labels, "gpu-"+s.GPU)
+	}
+	if s.CPUs > 0 {
+		labels = append(labels, "cpu-"+strconv.Itoa(s.CPUs))
+	}
+	if s.MemoryGB > 0 {
+		labels = append(labels, "mem-"+strconv.Itoa(s.MemoryGB))
+	}
+	return strings.Join(labels, ",")
+}
+
+// ParseSizeLabel parses a size label: gpu-TYPE (t4, p4, p100, v100, l4,
+// a100 or none), cpu-N (vCPUs) or mem-N (GB of memory). ok is false for
+// labels that are not size labels; err is set for malformed ones.
+func ParseSizeLabel(label string) (size VMSize, ok bool, err error) {
+	kind, value, found := strings.Cut(strings.ToLower(label), "-")
+	if !found {
+		return VMSize{}, false, nil
+	}
+	switch kind {
+	case "gpu":
+		if _, known := sizeGPUs[value]; !known && value != "none" {
+			return VMSize{}, true, fmt.Errorf("size label %q names an unknown GPU (want t4, p4, p100, v100, l4, a100 or none)", label)
+		}
+		return VMSize{GPU: value}, true, nil
+	case "cpu", "mem":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > 12288 {
+			return VMSize{}, true, fmt.Errorf("size label %q wants a positive %s count", label, kind)
+		}
+		if kind == "cpu" {
+			return VMSize{CPUs: n}, true, nil
+		}
+		return VMSize{MemoryGB: n}, true, nil
+	}
+	return VMSize{}, false, nil
+}
+
+// sizeForLabels combines the SizeLabels among a job's runs-on labels. The
+// first label of each kind wins.
+func (m *Manager) sizeForLabels(labels []string) VMSize {
+	var size VMSize
+	for _, label := range labels {
+		if !m.isSizeLabel(label) {
+			continue
+		}
+		s, _, err := ParseSizeLabel(label)
+		if err != nil {
+			continue
+		}
+		if size.GPU == "" {
+			size.GPU = s.GPU
+		}
+		if size.CPUs == 0 {
+			size.CPUs = s.CPUs
+		}
+		if size.MemoryGB == 0 {
+			size.MemoryGB = s.MemoryGB
+		}
+	}
+	return size
+}
+
+func (m *Manager) isSizeLabel(label string) bool {
+	for _, l := range m.config.SizeLabels {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// withSize returns profile resized to size; a GPU size replaces the
+// profile's GPU type, so quota and zone selection follow it.
+func withSize(profile vmProfile, size VMSize) vmProfile {
+	profile.size = size
+	switch {
+	case size.GPU == "none":
+		profile.gpuType = "none"
+	case size.GPU != "":
+		profile.gpuType = sizeGPUs[size.GPU].accelerator
+	}
+	return profile
+}
+
+// sizedMachineType returns the machine type a VM of profile is created
+// with: "" for the template's, a predefined type for the a2 and g2 series,
+// whose GPUs come with the machine type, and a custom type otherwise.
+func (m *Manager) sizedMachineType(ctx context.Context, profile vmProfile) (string, error) {
+	size := profile.size
+	if size.IsZero() {
+		return "", nil
+	}
+	props, err := m.templateProperties(ctx, profile.instanceTemplate)
+	if err != nil {
+		return "", err
+	}
+	templateSeries := machineSeries(props.GetMachineType())
+	series := templateSeries
+	if gpu, ok := sizeGPUs[size.GPU]; ok {
+		series = gpu.series
+	}
+	if size.CPUs == 0 && size.MemoryGB == 0 {
+		if series == templateSeries {
+			return "", nil
+		}
+		size.CPUs = defaultSizedCPUs
+	}
+
+	switch series {
+	case "a2":
+		if size.CPUs > 12 || size.MemoryGB > 85 {
+			return "", fmt.Errorf("size %s does not fit a2-highgpu-1g (12 vCPUs, 85 GB)", size)
+		}
+		return "a2-highgpu-1g", nil
+	case "g2":
+		for _, cpus := range g2CPUs {
+			if cpus >= size.CPUs && 4*cpus >= size.MemoryGB {
+				return fmt.Sprintf("g2-standard-%d", cpus), nil
+			}
+		}
+		return "", fmt.Errorf("size %s does not fit any g2-standard machine type", size)
+	}
+
+	cpus, memoryGB := size.CPUs, size.MemoryGB
+	if cpus == 0 {
+		cpus = (memoryGB + 3) / 4
+	}
+	// Custom machine types have 1 or an even number of vCPUs.
+	if cpus > 1 && cpus%2 == 1 {
+		cpus++
+	}
+	if memoryGB == 0 {
+		memoryGB = 4 * cpus
+	}
+	if series == "n1" {
+		return fmt.Sprintf("custom-%d-%d", cpus, memoryGB*1024), nil
+	}
+	return fmt.Sprintf("%s-custom-%d-%d", series, cpus, memoryGB*1024), nil
+}
+
+// machineSeries returns the series of a machine type name or URL, e.g. n1
+// for n1-standard-8 and for the legacy custom-8-30720.
+func machineSeries(machineType string) string {
+	name := machineType[strings.LastIndex(machineType, "/")+1:]
+	series, _, _ := strings.Cut(name, "-")
+	if series == "custom" {
+		return "n1"
+	}
+	return series
+}
+
+// applySize sets the machine type and GPU of a VM of profile in zone on vm,
+// overriding its template's.
+func (m *Manager) applySize(ctx context.Context, vm *computepb.Instance, profile vmProfile, machineType, zone string) error {
+	if machineType != "" {
+		vm.MachineType = proto.String(fmt.Sprintf("zones/%s/machineTypes/%s", zone, machineType))
+	}
+	if profile.size.GPU == "" {
+		return nil
+	}
+	props, err := m.templateProperties(ctx, profile.instanceTemplate)
+	if err != nil {
+		return err
+	}
+	if profile.size.GPU == "none" {
+		// An insert cannot remove the template's accelerators.
+		if len(props.GetGuestAccelerators()) > 0 {
+			return fmt.Errorf("instance template %s attaches a GPU, so it cannot create gpu-none VMs", profile.instanceTemplate)
+		}
+		return nil
+	}
+	vm.GuestAccelerators = []*computepb.AcceleratorConfig{{
+		AcceleratorType:  proto.String(fmt.Sprintf("zones/%s/acceleratorTypes/%s", zone, profile.gpuType)),
+		AcceleratorCount: proto.Int32(1),
+	}}
+	// GPU VMs cannot live migrate.
+	scheduling := &computepb.Scheduling{}
+	if props.GetScheduling() != nil {
+		scheduling = proto.Clone(props.GetScheduling()).(*computepb.Scheduling)
+	}
+	scheduling.OnHostMaintenance = proto.String(computepb.Scheduling_TERMINATE.String())
+	vm.Scheduling = scheduling
+	return nil
+}
@@ -0,0 +1,127 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseSizeLabel(t *testing.T) {
+	for _, tc := range []struct {
+		label   string
+		want    VMSize
+		size    bool
+		wantErr bool
+	}{
+		{label: "gpu-a100", want: VMSize{GPU: "a100"}, size: true},
+		{label: "GPU-T4", want: VMSize{GPU: "t4"}, size: true},
+		{label: "gpu-none", want: VMSize{GPU: "none"}, size: true},
+		{label: "cpu-32", want: VMSize{CPUs: 32}, size: true},
+		{label: "mem-128", want: VMSize{MemoryGB: 128}, size: true},
+		{label: "gpu-h200", size: true, wantErr: true},
+		{label: "cpu-many", size: true, wantErr: true},
+		{label: "mem-0", size: true, wantErr: true},
+		{label: "self-hosted"},
+		{label: "Linux"},
+	} {
+		got, size, err := ParseSizeLabel(tc.label)
+		if got != tc.want || size != tc.size || (err != nil) != tc.wantErr {
+			t.Errorf("ParseSizeLabel(%q) = %+v, %v, %v", tc.label, got, size, err)
+		}
+	}
+}
+
+func TestSizedMachineType(t *testing.T) {
+	m := &Manager{
+		templatePropertiesFunc: func(_ context.Context, name string) (*computepb.InstanceProperties, error) {
+			if name == "cpu-runner" {
+				return &computepb.InstanceProperties{MachineType: proto.String("n2-standard-16")}, nil
+			}
+			return &computepb.InstanceProperties{MachineType: proto.String("n1-standard-8")}, nil
+		},
+	}
+	for _, tc := range []struct {
+		template string
+		size     VMSize
+		want     string
+		wantErr  bool
+	}{
+		{template: "gpu-runner", want: ""},
+		{template: "gpu-runner", size: VMSize{GPU: "v100"}, want: ""},
+		{template: "gpu-runner", size: VMSize{GPU: "t4", CPUs: 16, MemoryGB: 64}, want: "custom-16-65536"},
+		{template: "gpu-runner", size: VMSize{CPUs: 7}, want: "custom-8-32768"},
+		{template: "cpu-runner", size: VMSize{MemoryGB: 128}, want: "n2-custom-32-131072"},
+		{template: "cpu-runner", size: VMSize{GPU: "t4"}, want: "custom-8-32768"},
+		{template: "gpu-runner", size: VMSize{GPU: "a100"}, want: "a2-highgpu-1g"},
+		{template: "gpu-runner", size: VMSize{GPU: "a100", CPUs: 32}, wantErr: true},
+		{template: "gpu-runner", size: VMSize{GPU: "l4", CPUs: 16, MemoryGB: 96}, want: "g2-standard-24"},
+	} {
+		profile := withSize(vmProfile{instanceTemplate: tc.template}, tc.size)
+		got, err := m.sizedMachineType(context.Background(), profile)
+		if got != tc.want || (err != nil) != tc.wantErr {
+			t.Errorf("sizedMachineType(%s, %s) = %q, %v; want %q", tc.template, tc.size, got, err, tc.want)
+		}
+	}
+}
+
+func TestCreateVMForSizeLabels(t *testing.T) {
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			InstanceTemplate: "linux-gpu-runner",
+			GPUType:          "nvidia-tesla-t4",
+			SizeLabels:       []string{"gpu-a100", "cpu-32", "mem-128"},
+		},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		templatePropertiesFunc: func(context.Context, string) (*computepb.InstanceProperties, error) {
+			return &computepb.InstanceProperties{
+				MachineType: proto.String("n1-standard-8"),
+				Scheduling:  &computepb.Scheduling{Preemptible: proto.Bool(true)},
+			}, nil
+		},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-central1-a", region: "us-central1", available: 4}}, nil
+	}
+	var reqs []*computepb.InsertInstanceRequest
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		reqs = append(reqs, r)
+		return nil
+	}
+
+	// cpu-64 is not a configured size label, so it is ignored.
+	if _, err := m.CreateVMForLabels(context.Background(), "runner-1", "jit", []string{"self-hosted", "cpu-64", "MEM-128"}); err != nil {
+		t.Fatalf("CreateVMForLabels: %v", err)
+	}
+	vm := reqs[0].GetInstanceResource()
+	if got := vm.GetMachineType(); got != "zones/us-central1-a/machineTypes/custom-32-131072" {
+		t.Fatalf("machine type = %q, want custom-32-131072", got)
+	}
+	if len(vm.GetGuestAccelerators()) != 0 {
+		t.Fatalf("accelerators = %v, want the template's", vm.GetGuestAccelerators())
+	}
+
+	if _, err := m.CreateVMForLabels(context.Background(), "runner-2", "jit", []string{"self-hosted", "gpu-a100"}); err != nil {
+		t.Fatalf("CreateVMForLabels: %v", err)
+	}
+	vm = reqs[1].GetInstanceResource()
+	if got := vm.GetMachineType(); got != "zones/us-central1-a/machineTypes/a2-highgpu-1g" {
+		t.Fatalf("machine type = %q, want a2-highgpu-1g", got)
+	}
+	accs := vm.GetGuestAccelerators()
+	if len(accs) != 1 || accs[0].GetAcceleratorType() != "zones/us-central1-a/acceleratorTypes/nvidia-tesla-a100" {
+		t.Fatalf("accelerators = %v, want one A100", accs)
+	}
+	if s := vm.GetScheduling(); s.GetOnHostMaintenance() != "TERMINATE" || !s.GetPreemptible() {
+		t.Fatalf("scheduling = %v, want the template's with TERMINATE on host maintenance", s)
+	}
+	if got := m.vms["runner-2"].size; got != (VMSize{GPU: "a100"}) {
+		t.Fatalf("tracked size = %+v", got)
+	}
+	if got, _ := m.MachineType(context.Background(), "runner-2"); got != "a2-highgpu-1g" {
+		t.Fatalf("MachineType = %q, want a2-highgpu-1g", got)
+	}
+}
@@ -0,0 +1,82 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// RunnerSlot is one of the runners a VM runs.
+type RunnerSlot struct {
+	Name      string
+	JITConfig string
+}
+
+// RunnerSlots returns how many runners a VM created for a job with the given
+// runs-on labels runs at once: one per MIG slice when MIGProfile applies to
+// its GPU, otherwise the configured RunnerSlots.
+func (m *Manager) RunnerSlots(ctx context.Context, labels []string) (int, error) {
+	profile := m.profileForLabels(labels)
+	perGPU := MIGSlices(profile.gpuType, m.config.MIGProfile)
+	if perGPU == 0 {
+		return max(1, m.config.RunnerSlots), nil
+	}
+	gpus, err := m.templateGPUs(ctx, profile.instanceTemplate)
+	if err != nil {
+		return 0, err
+	}
+	return perGPU * gpus, nil
+}
+
+// CreateSlottedVM is CreateVMForLabels for a VM running all of runners, one
+// per slot, as returned by RunnerSlots. The VM is named after the first
+// runner and each runner is tracked on its own; DeleteByRunnerName deletes
+// the VM once its last runner is gone.
+func (m *Manager) CreateSlottedVM(ctx context.Context, runners []RunnerSlot, labels []string) (string, error) {
+	if len(runners) == 0 {
+		return "", fmt.Errorf("no runners to create a VM for")
+	}
+	return m.createVM(ctx, runners, m.profileForLabels(labels), "")
+}
+
+// slotMetadataItems returns the metadata telling the startup script to run
+// several runners: runner-slots, and jit-config-1, -2, ... next to the first
+// runner's jit-config. With a MIG profile, mig-profile names the slices to
+// create, one per runner.
+func (m *Manager) slotMetadataItems(runners []RunnerSlot, profile vmProfile) []*computepb.Items {
+	var items []*computepb.Items
+	if MIGSlices(profile.gpuType, m.config.MIGProfile) > 0 {
+		items = append(items, &computepb.Items{
+			Key:   proto.String("mig-profile"),
+			Value: proto.String(m.config.MIGProfile),
+		})
+	}
+	if len(runners) < 2 {
+		return items
+	}
+	items = append(items, &computepb.Items{
+		Key:   proto.String("runner-slots"),
+		Value: proto.String(strconv.Itoa(len(runners))),
+	})
+	for i, r := range runners[1:] {
+		items = append(items, &computepb.Items{
+			Key:   proto.String(fmt.Sprintf("jit-config-%d", i+1)),
+			Value: proto.String(r.JITConfig),
+		})
+	}
+	return items
+}
+
+// runsOtherSlotsLocked reports whether a runner is still tracked on vmName.
+// The caller must hold m.mu.
+func (m *Manager) runsOtherSlotsLocked(vmName string) bool {
+	for _, vm := range m.vms {
+		if vm.vmName == vmName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+func newSlotsManager(t *testing.T) (*Manager, *[]*computepb.InsertInstanceRequest, *[]string) {
+	t.Helper()
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			InstanceTemplate: "linux-a100",
+			GPUType:          "nvidia-tesla-a100",
+			Platform:         "linux",
+			MIGProfile:       "3g.20gb",
+		},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-central1-a", region: "us-central1", available: 1}}, nil
+	}
+	var reqs []*computepb.InsertInstanceRequest
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		reqs = append(reqs, r)
+		return nil
+	}
+	var deleted []string
+	m.deleteVMFunc = func(_ context.Context, vmName, _ string) error {
+		deleted = append(deleted, vmName)
+		return nil
+	}
+	return m, &reqs, &deleted
+}
+
+func metadataValue(req *computepb.InsertInstanceRequest, key string) string {
+	for _, item := range req.GetInstanceResource().GetMetadata().GetItems() {
+		if item.GetKey() == key {
+			return item.GetValue()
+		}
+	}
+	return ""
+}
+
+func TestCreateSlottedVMPassesEachRunnerItsConfig(t *testing.T) {
+	m, reqs, _ := newSlotsManager(t)
+
+	vmName, err := m.CreateSlottedVM(context.Background(), []RunnerSlot{
+		{Name: "a100-1", JITConfig: "jit-1"},
+		{Name: "a100-2", JITConfig: "jit-2"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("CreateSlottedVM: %v", err)
+	}
+	if vmName != "a100-1" {
+		t.Fatalf("vmName = %q, want the first runner's name", vmName)
+	}
+	req := (*reqs)[0]
+	for key, want := range map[string]string{
+		"jit-config":   "jit-1",
+		"jit-config-1": "jit-2",
+		"runner-slots": "2",
+		"mig-profile":  "3g.20gb",
+	} {
+		if got := metadataValue(req, key); got != want {
+			t.Errorf("metadata %s = %q, want %q", key, got, want)
+		}
+	}
+	if m.ActiveCount() != 2 {
+		t.Fatalf("ActiveCount = %d, want one per runner", m.ActiveCount())
+	}
+	if m.vms["a100-2"].vmName != "a100-1" || m.vms["a100-2"].slot != 1 {
+		t.Fatalf("a100-2 = %+v, want slot 1 of a100-1", m.vms["a100-2"])
+	}
+}
+
+func TestDeleteByRunnerNameKeepsVMForOtherSlots(t *testing.T) {
+	m, _, _ := newSlotsManager(t)
+	if _, err := m.CreateSlottedVM(context.Background(), []RunnerSlot{
+		{Name: "a100-1", JITConfig: "jit-1"},
+		{Name: "a100-2", JITConfig: "jit-2"},
+	}, nil); err != nil {
+		t.Fatalf("CreateSlottedVM: %v", err)
+	}
+
+	// The real delete has no client, so it would panic if called.
+	if err := m.DeleteByRunnerName(context.Background(), "a100-1"); err != nil {
+		t.Fatalf("DeleteByRunnerName: %v", err)
+	}
+	if m.ActiveCount() != 1 {
+		t.Fatalf("ActiveCount = %d, want the second runner still tracked", m.ActiveCount())
+	}
+}
+
+func TestPendingSlotsReserveQuotaOnce(t *testing.T) {
+	m, _, _ := newSlotsManager(t)
+	candidates := []zoneCandidate{{zone: "us-central1-a", region: "us-central1", available: 2}}
+
+	if _, err := m.reserveCreate([]string{"a100-1", "a100-2", "a100-3"}, "nvidia-tesla-a100", candidates); err != nil {
+		t.Fatalf("reserveCreate: %v", err)
+	}
+	if m.ActiveCount() != 3 {
+		t.Fatalf("ActiveCount = %d, want the three pending runners", m.ActiveCount())
+	}
+	// The first VM takes one of the two GPUs.
+	if _, err := m.reserveCreate([]string{"a100-4"}, "nvidia-tesla-a100", candidates); err != nil {
+		t.Fatalf("reserveCreate for the second VM: %v", err)
+	}
+}
+
+func TestEvictStaleOrphansWaitsForAllSlots(t *testing.T) {
+	m, _, deleted := newSlotsManager(t)
+	m.config.OrphanGracePeriod = time.Minute
+	now := time.Now()
+	m.nowFunc = func() time.Time { return now }
+	created := now.Add(-time.Hour)
+	m.vms["a100-1"] = &vmInfo{vmName: "a100-1", zone: "us-central1-a", createdAt: created}
+	m.vms["a100-2"] = &vmInfo{vmName: "a100-1", zone: "us-central1-a", createdAt: created, slot: 1, busy: true}
+
+	m.evictStaleOrphans(context.Background())
+	if len(*deleted) != 0 {
+		t.Fatalf("deleted = %v, want the VM kept while a slot is busy", *deleted)
+	}
+
+	m.vms["a100-2"].busy = false
+	m.evictStaleOrphans(context.Background())
+	if len(*deleted) != 1 || (*deleted)[0] != "a100-1" {
+		t.Fatalf("deleted = %v, want a100-1 deleted once", *deleted)
+	}
+	if len(m.vms) != 0 {
+		t.Fatalf("tracked = %v, want both runners dropped", m.vms)
+	}
+}
@@ -0,0 +1,98 @@
+package gcp
+
+import (
+	"sort"
+	"time"
+)
+
+// ZonePreemptions is how a zone's Spot VMs fared over PreemptionWindow:
+// the VMs the manager created there and those GCE preempted.
+type ZonePreemptions struct {
+	Created   int `json:"created"`
+	Preempted int `json:"preempted"`
+}
+
+// rate is the share of the zone's VMs that were preempted. Preemptions of
+// VMs created before the window count against a single create, so a zone
+// that only preempts is never rated better than one that creates.
+func (z ZonePreemptions) rate() float64 {
+	return float64(z.Preempted) / float64(max(z.Created, 1))
+}
+
+// recordZoneCreateLocked notes a VM created in zone, for its preemption
+// rate. The caller must hold m.mu.
+func (m *Manager) recordZoneCreateLocked(zone string, now time.Time) {
+	if m.config.PreemptionWindow <= 0 {
+		return
+	}
+	if m.zoneCreates == nil {
+		m.zoneCreates = make(map[string][]time.Time)
+	}
+	m.zoneCreates[zone] = append(m.zoneCreates[zone], now)
+}
+
+// recordZonePreemptionLocked notes a tracked VM GCE preempted in zone. The
+// caller must hold m.mu.
+func (m *Manager) recordZonePreemptionLocked(zone string, now time.Time) {
+	if m.config.PreemptionWindow <= 0 {
+		return
+	}
+	if m.zonePreemptions == nil {
+		m.zonePreemptions = make(map[string][]time.Time)
+	}
+	m.zonePreemptions[zone] = append(m.zonePreemptions[zone], now)
+}
+
+// zonePreemptionsLocked returns the creates and preemptions of each zone
+// that had a preemption within PreemptionWindow, dropping older records.
+// The caller must hold m.mu.
+func (m *Manager) zonePreemptionsLocked() map[string]ZonePreemptions {
+	if len(m.zonePreemptions) == 0 {
+		return nil
+	}
+	cutoff := m.now().Add(-m.config.PreemptionWindow)
+	recent := func(times []time.Time) []time.Time {
+		// Records are appended in order, so the recent ones are a suffix.
+		i := sort.Search(len(times), func(i int) bool { return times[i].After(cutoff) })
+		return times[i:]
+	}
+	for zone, times := range m.zoneCreates {
+		if m.zoneCreates[zone] = recent(times); len(m.zoneCreates[zone]) == 0 {
+			delete(m.zoneCreates, zone)
+		}
+	}
+	out := make(map[string]ZonePreemptions)
+	for zone, times := range m.zonePreemptions {
+		if m.zonePreemptions[zone] = recent(times); len(m.zonePreemptions[zone]) == 0 {
+			delete(m.zonePreemptions, zone)
+			continue
+		}
+		out[zone] = ZonePreemptions{Created: len(m.zoneCreates[zone]), Preempted: len(m.zonePreemptions[zone])}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// survivingZonesLocked orders candidates by the preemption rate of the
+// manager's Spot VMs in their zone over PreemptionWindow, lowest first,
+// keeping the order of zones with the same rate. best is how many of them
+// share the lowest rate; reserveCreate picks among those first. Without
+// preemptions, as in pools of on-demand VMs, candidates are returned as
+// they are. The caller must hold m.mu.
+func (m *Manager) survivingZonesLocked(candidates []zoneCandidate) (ordered []zoneCandidate, best int) {
+	preemptions := m.zonePreemptionsLocked()
+	if len(preemptions) == 0 {
+		return candidates, len(candidates)
+	}
+	ordered = append([]zoneCandidate(nil), candidates...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return preemptions[ordered[i].zone].rate() < preemptions[ordered[j].zone].rate()
+	})
+	lowest := preemptions[ordered[0].zone].rate()
+	for best < len(ordered) && preemptions[ordered[best].zone].rate() == lowest {
+		best++
+	}
+	return ordered, best
+}
@@ -0,0 +1,92 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+func TestPreemptionsSteerCreatesToSurvivingZones(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	zones := []zoneCandidate{
+		{zone: "us-east1-d", region: "us-east1", available: 8},
+		{zone: "us-east1-b", region: "us-east1", available: 8},
+	}
+	m := &Manager{
+		config:         ManagerConfig{Project: "test-project", InstanceTemplate: "t", GPUType: "nvidia-l4", PreemptionWindow: time.Hour},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		nowFunc:        func() time.Time { return now },
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return append([]zoneCandidate(nil), zones...), nil
+	}
+	inserts := make(map[string]int)
+	m.insertVMFunc = func(_ context.Context, req *computepb.InsertInstanceRequest) error {
+		inserts[req.GetZone()]++
+		return nil
+	}
+
+	if _, err := m.CreateVM(context.Background(), "runner-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if inserts["us-east1-d"] != 1 {
+		t.Fatalf("inserts = %v, want the first VM in us-east1-d", inserts)
+	}
+	m.preempted(preemption{vmName: "runner-1", at: now}, "us-east1-d")
+	want := map[string]ZonePreemptions{"us-east1-d": {Created: 1, Preempted: 1}}
+	if got := m.Capacity().ZonePreemptions; len(got) != 1 || got["us-east1-d"] != want["us-east1-d"] {
+		t.Fatalf("ZonePreemptions = %v, want %v", got, want)
+	}
+
+	// Even with fewer VMs, the zone that preempted one is passed over.
+	for _, name := range []string{"runner-2", "runner-3"} {
+		if _, err := m.CreateVM(context.Background(), name, "jit"); err != nil {
+			t.Fatalf("CreateVM(%s): %v", name, err)
+		}
+	}
+	if inserts["us-east1-d"] != 1 || inserts["us-east1-b"] != 2 {
+		t.Fatalf("inserts = %v, want both VMs in us-east1-b", inserts)
+	}
+
+	// Once the preemption leaves the window, VMs spread again.
+	now = now.Add(time.Hour)
+	if got := m.Capacity().ZonePreemptions; got != nil {
+		t.Fatalf("ZonePreemptions = %v after the window, want none", got)
+	}
+	if _, err := m.CreateVM(context.Background(), "runner-4", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if inserts["us-east1-d"] != 2 {
+		t.Fatalf("inserts = %v, want us-east1-d used again", inserts)
+	}
+}
+
+func TestSurvivingZonesRotateNonGPUCreates(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := &Manager{
+		config:  ManagerConfig{PreemptionWindow: time.Hour},
+		nowFunc: func() time.Time { return now },
+	}
+	candidates := []zoneCandidate{{zone: "us-east1-b"}, {zone: "us-east1-c"}, {zone: "us-east1-d"}}
+	if got, best := m.survivingZonesLocked(candidates); best != 3 || got[0].zone != "us-east1-b" {
+		t.Fatalf("without preemptions = %v, %d; want the candidates as they are", got, best)
+	}
+
+	for range 4 {
+		m.recordZoneCreateLocked("us-east1-b", now)
+		m.recordZoneCreateLocked("us-east1-c", now)
+	}
+	m.recordZonePreemptionLocked("us-east1-b", now)
+	m.recordZonePreemptionLocked("us-east1-b", now)
+	m.recordZonePreemptionLocked("us-east1-c", now)
+	got, best := m.survivingZonesLocked(candidates)
+	if best != 1 || got[0].zone != "us-east1-d" || got[1].zone != "us-east1-c" || got[2].zone != "us-east1-b" {
+		t.Fatalf("survivingZonesLocked = %v, %d; want us-east1-d alone first, then by preemption rate", got, best)
+	}
+	if candidates[0].zone != "us-east1-b" {
+		t.Fatal("survivingZonesLocked should not reorder its argument")
+	}
+}
@@ -4,9 +4,12 @@
 # 1. Removes any pre-existing runner service from the base image
 # 2. Updates the preinstalled GitHub Actions runner if its version differs
 #    from $RunnerVersion (no-op when the image is already up to date)
-# 3. Reads the JIT (just-in-time) runner config from GCP instance metadata
+# 3. Reads the JIT (just-in-time) runner config from GCP instance metadata,
+#    and installs the NVIDIA driver version it names if the image has another
 # 4. Configures the GitHub Actions runner with the JIT config
-# 5. Runs the runner (executes one job, then exits)
+# 5. Runs the runner (executes one job, then exits); when the scaler reuses
+#    VMs, waits for the next job's JIT config and runs it again. A VM given
+#    several runner slots runs one runner per slot side by side.
 # 6. Shuts down the VM (so the scaler can delete it)
 #
 # The JIT config is a base64-encoded blob generated by the Scale Set Client
@@ -33,9 +36,44 @@ function Write-Log {
     Add-Content -Path $logFile -Value $line
 }
 
+# Set-BootFailure reports a fatal boot error to the scaler.
+function Set-BootFailure {
+    param([string]$Message)
+    $firstLine = ($Message -split "`n")[0]
+    Set-GuestAttribute "error" $firstLine.Substring(0, [Math]::Min(200, $firstLine.Length))
+    Set-GuestAttribute "phase" "failed"
+}
+
+# Start-RunnerOnlineWatch reports runner-online once the runner in $Dir has
+# connected to GitHub and is listening for its job.
+function Start-RunnerOnlineWatch {
+    param([string]$Dir)
+    if (-not $guestAttributes) {
+        return
+    }
+    Start-Job -ArgumentList $Dir, (Get-Date) -ScriptBlock {
+        param($Dir, $Since)
+        for ($i = 0; $i -lt 120; $i++) {
+            $listening = Get-ChildItem "$Dir\_diag\Runner_*.log" -ErrorAction SilentlyContinue |
+                Where-Object { $_.LastWriteTime -gt $Since } |
+                Select-String -Pattern "Listening for Jobs" -SimpleMatch -List
+            if ($listening) {
+                try {
+                    Invoke-RestMethod -Method Put -Uri "http://metadata.google.internal/computeMetadata/v1/instance/guest-attributes/runner/phase" -Headers @{ "Metadata-Flavor" = "Google" } -Body "runner-online" -TimeoutSec 10 | Out-Null
+                }
+                catch {
+                }
+                return
+            }
+            Start-Sleep -Seconds 5
+        }
+    } | Out-Null
+}
+
 function Stop-WithFailure {
     param([string]$Message, [string]$ArchivePath)
     Write-Log "ERROR: $Message"
+    Set-BootFailure $Message
     if ($ArchivePath -and (Test-Path $ArchivePath)) {
         Remove-Item $ArchivePath -Force -ErrorAction SilentlyContinue
     }
@@ -43,6 +81,52 @@ function Stop-WithFailure {
     exit 1
 }
 
+# Set-GuestAttribute sets the runner/$Key guest attribute the scaler reads.
+# It does nothing unless the scaler set the enable-guest-attributes metadata.
+# The boot progresses through the phase values started, driver-ok,
+# runner-configured and runner-online, or ends in failed with the reason in
+# error.
+$guestAttributes = $false
+try {
+    $guestAttributes = (Invoke-RestMethod -Uri "http://metadata.google.internal/computeMetadata/v1/instance/attributes/enable-guest-attributes" -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 10) -eq "TRUE"
+}
+catch {
+}
+
+function Set-GuestAttribute {
+    param([string]$Key, [string]$Value)
+    if (-not $guestAttributes) {
+        return
+    }
+    try {
+        Invoke-RestMethod -Method Put -Uri "http://metadata.google.internal/computeMetadata/v1/instance/guest-attributes/runner/$Key" -Headers @{ "Metadata-Flavor" = "Google" } -Body $Value -TimeoutSec 10 | Out-Null
+    }
+    catch {
+        Write-Log "WARNING: Failed to report guest attribute ${Key}: $_"
+    }
+}
+
+# Get-UnsealedJitConfig returns the JIT config read from metadata as $Value.
+# When the scaler set jit-config-kms-key, $Value is encrypted with that Cloud
+# KMS key and is decrypted with the VM's service account, which needs
+# roles/cloudkms.cryptoKeyDecrypter on the key. It throws when that fails.
+function Get-UnsealedJitConfig {
+    param([string]$Value)
+    $key = $null
+    try {
+        $key = Invoke-RestMethod -Uri "http://metadata.google.internal/computeMetadata/v1/instance/attributes/jit-config-kms-key" -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 10
+    }
+    catch {
+    }
+    if (-not $key) {
+        return $Value
+    }
+    $token = (Invoke-RestMethod -Uri "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token" -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 10).access_token
+    $body = @{ ciphertext = $Value } | ConvertTo-Json
+    $response = Invoke-RestMethod -Method Post -Uri "https://cloudkms.googleapis.com/v1/${key}:decrypt" -Headers @{ Authorization = "Bearer $token" } -ContentType "application/json" -Body $body -TimeoutSec 30
+    return [System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String($response.plaintext))
+}
+
 function Get-InstalledRunnerVersion {
     $listener = Join-Path $runnerDir "bin\Runner.Listener.exe"
     if (-not (Test-Path $listener)) {
@@ -67,6 +151,9 @@ function Get-InstalledRunnerVersion {
 }
 
 Write-Log "=== Windows GPU Runner Startup ==="
+Set-GuestAttribute "error" ""
+Set-GuestAttribute "shutdown" ""
+Set-GuestAttribute "phase" "started"
 
 # Step 0: Remove any pre-existing runner service from the base image.
 # The base image was snapshotted from a static runner that has the runner
@@ -91,6 +178,12 @@ try {
         Remove-Item "$runnerDir\.credentials_rsaparams" -Force -ErrorAction SilentlyContinue
         Write-Log "  Removed old runner configuration files."
     }
+    # A VM started from the scaler's stopped pool still has the previous
+    # job's workspace.
+    if (Test-Path "$runnerDir\_work") {
+        Remove-Item "$runnerDir\_work" -Recurse -Force -ErrorAction SilentlyContinue
+        Write-Log "  Removed the previous job's workspace."
+    }
 }
 catch {
     Write-Log "  WARNING: Failed to remove existing service: $_"
@@ -167,7 +260,9 @@ $jitConfig = $null
 
 for ($i = 1; $i -le $maxRetries; $i++) {
     try {
-        $jitConfig = Invoke-RestMethod -Uri $metadataUrl -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 10
+        $response = Invoke-WebRequest -Uri $metadataUrl -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 10 -UseBasicParsing
+        $jitConfig = $response.Content
+        $etag = $response.Headers["ETag"]
         break
     }
     catch {
@@ -178,11 +273,85 @@ for ($i = 1; $i -le $maxRetries; $i++) {
 
 if (-not $jitConfig) {
     Write-Log "ERROR: Failed to read JIT config from metadata after $maxRetries attempts"
+    Set-BootFailure "Failed to read JIT config from metadata after $maxRetries attempts"
     Stop-Computer -Force
     exit 1
 }
 
 Write-Log "JIT config retrieved ($($jitConfig.Length) chars)"
+# $jitConfigRaw is the metadata value, which the reuse loop watches for a
+# change.
+$jitConfigRaw = $jitConfig
+try {
+    $jitConfig = Get-UnsealedJitConfig $jitConfigRaw
+}
+catch {
+    Stop-WithFailure "Failed to decrypt the JIT config with its Cloud KMS key: $_"
+}
+
+# Step 1.5: Install the NVIDIA driver the scaler asks for in the
+# gpu-driver-version metadata when the image has another one, so a driver
+# upgrade is a scaler config change rather than a new image or scaler build.
+# gpu-driver-url is the installer (https:// or gs://), gpu-driver-sha256 its
+# optional checksum. The installer runs silently without rebooting; the
+# smoke test below catches a driver that did not load.
+$driverVersion = ""
+$driverUrl = ""
+$driverSha256 = ""
+try {
+    $driverVersion = Invoke-RestMethod -Uri "http://metadata.google.internal/computeMetadata/v1/instance/attributes/gpu-driver-version" -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 10
+    $driverUrl = Invoke-RestMethod -Uri "http://metadata.google.internal/computeMetadata/v1/instance/attributes/gpu-driver-url" -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 10
+    $driverSha256 = Invoke-RestMethod -Uri "http://metadata.google.internal/computeMetadata/v1/instance/attributes/gpu-driver-sha256" -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 10
+}
+catch {
+}
+if ($driverVersion) {
+    $installedDriver = ""
+    try {
+        $installedDriver = (& nvidia-smi --query-gpu=driver_version --format=csv,noheader 2>$null | Select-Object -First 1)
+        if ($installedDriver) {
+            $installedDriver = $installedDriver.Trim()
+        }
+    }
+    catch {
+    }
+    Write-Log "NVIDIA driver: installed $(if ($installedDriver) { $installedDriver } else { 'none' }), wanted $driverVersion"
+    if ($installedDriver -ne $driverVersion) {
+        if (-not $driverUrl) {
+            Stop-WithFailure "gpu-driver-version $driverVersion is set without gpu-driver-url"
+        }
+        $driverInstaller = Join-Path $env:TEMP ("nvidia-driver-{0}.exe" -f ([guid]::NewGuid().ToString("N")))
+        Write-Log "Downloading NVIDIA driver $driverVersion from $driverUrl..."
+        try {
+            if ($driverUrl.StartsWith("gs://")) {
+                & gcloud storage cp $driverUrl $driverInstaller 2>&1 | ForEach-Object { Write-Log "  $_" }
+                if ($LASTEXITCODE -ne 0) {
+                    throw "gcloud storage cp exited with $LASTEXITCODE"
+                }
+            }
+            else {
+                Invoke-WebRequest -Uri $driverUrl -OutFile $driverInstaller -UseBasicParsing -TimeoutSec 600
+            }
+        }
+        catch {
+            Stop-WithFailure "Failed to download NVIDIA driver ${driverVersion}: $_" $driverInstaller
+        }
+        if ($driverSha256) {
+            $actualHash = (Get-FileHash -Path $driverInstaller -Algorithm SHA256).Hash.ToLowerInvariant()
+            if ($actualHash -ne $driverSha256.ToLowerInvariant()) {
+                Stop-WithFailure "NVIDIA driver $driverVersion checksum verification failed (expected ${driverSha256}, got ${actualHash})" $driverInstaller
+            }
+        }
+        Write-Log "Installing NVIDIA driver $driverVersion..."
+        $install = Start-Process -FilePath $driverInstaller -ArgumentList "-s", "-noreboot", "-clean" -Wait -PassThru
+        Remove-Item $driverInstaller -Force -ErrorAction SilentlyContinue
+        # 1 means a reboot is pending, which the driver does not need to load.
+        if ($install.ExitCode -ne 0 -and $install.ExitCode -ne 1) {
+            Stop-WithFailure "NVIDIA driver $driverVersion installer exited with $($install.ExitCode)"
+        }
+        Write-Log "  NVIDIA driver $driverVersion installed."
+    }
+}
 
 # Step 2: Log GPU and system info
 Write-Log "=== System Information ==="
@@ -193,6 +362,173 @@ catch {
     Write-Log "WARNING: nvidia-smi not available"
 }
 
+# Step 2.1: GPU smoke test. With gpu-check set, the scaler reads the result
+# from the runner/gpu-check guest attribute and replaces a VM whose check
+# failed, so no job lands on a broken driver install. A driver that failed
+# to load leaves the display adapter with an error code (43 for NVIDIA);
+# Vulkan is checked when vulkaninfo is installed. CPU-only VMs report
+# "skipped", so the scaler stops asking.
+$gpuCheck = ""
+$expectGPU = "true"
+try {
+    $gpuCheck = Invoke-RestMethod -Uri "http://metadata.google.internal/computeMetadata/v1/instance/attributes/gpu-check" -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 10
+    $expectGPU = Invoke-RestMethod -Uri "http://metadata.google.internal/computeMetadata/v1/instance/attributes/expect-gpu" -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 10
+}
+catch {
+}
+if ($gpuCheck -eq "true" -and $expectGPU -eq "false") {
+    Set-GuestAttribute "gpu-check" "skipped"
+}
+elseif ($gpuCheck -eq "true") {
+    Write-Log "Running GPU smoke test..."
+    Set-GuestAttribute "gpu-check" "running"
+    $gpuCheckError = $null
+    $smiExit = 1
+    try {
+        $smiOut = (& nvidia-smi --query-gpu=name,driver_version --format=csv,noheader 2>&1 | Out-String).Trim()
+        $smiExit = $LASTEXITCODE
+    }
+    catch {
+        $smiOut = "$_"
+    }
+    $adapter = Get-CimInstance Win32_VideoController | Where-Object { $_.Name -match "NVIDIA" } | Select-Object -First 1
+    if ($smiExit -ne 0) {
+        $gpuCheckError = "nvidia-smi: $smiOut"
+    }
+    elseif (-not $adapter) {
+        $gpuCheckError = "no NVIDIA display adapter"
+    }
+    elseif ($adapter.ConfigManagerErrorCode -ne 0) {
+        $gpuCheckError = "$($adapter.Name) has device error code $($adapter.ConfigManagerErrorCode)"
+    }
+    elseif (Get-Command vulkaninfo -ErrorAction SilentlyContinue) {
+        $vkOut = ""
+        try {
+            $vkOut = & vulkaninfo --summary 2>&1 | Out-String
+        }
+        catch {
+        }
+        if ($vkOut -notmatch "NVIDIA") {
+            $gpuCheckError = "vulkaninfo lists no NVIDIA device"
+        }
+    }
+    if ($gpuCheckError) {
+        $firstLine = ($gpuCheckError -split "`n")[0]
+        Write-Log "ERROR: GPU smoke test failed: $gpuCheckError"
+        Set-GuestAttribute "gpu-check" "failed: $($firstLine.Substring(0, [Math]::Min(200, $firstLine.Length)))"
+        Set-BootFailure "GPU smoke test failed: $gpuCheckError"
+        # Give the scaler time to read the result and replace the VM.
+        Start-Sleep -Seconds 600
+        Stop-Computer -Force
+        exit 1
+    }
+    Write-Log "  GPU smoke test passed: $smiOut"
+    Set-GuestAttribute "gpu-check" "ok"
+}
+Set-GuestAttribute "phase" "driver-ok"
+
+# Step 2.5: Mount the build cache disk, if the scaler attached one. The
+# pool's VMs take turns using it. Format it on first use and point the caches
+# at it, for sccache below and through the runner's .env file for jobs.
+$cacheDisk = Get-Disk | Where-Object { $_.SerialNumber -eq "build-cache" } | Select-Object -First 1
+if ($cacheDisk) {
+    Write-Log "Mounting build cache disk..."
+    try {
+        if ($cacheDisk.IsOffline) {
+            Set-Disk -Number $cacheDisk.Number -IsOffline $false
+        }
+        if ($cacheDisk.IsReadOnly) {
+            Set-Disk -Number $cacheDisk.Number -IsReadOnly $false
+        }
+        if ($cacheDisk.PartitionStyle -eq "RAW") {
+            Initialize-Disk -Number $cacheDisk.Number -PartitionStyle GPT
+            New-Partition -DiskNumber $cacheDisk.Number -UseMaximumSize |
+                Format-Volume -FileSystem NTFS -NewFileSystemLabel "build-cache" -Confirm:$false | Out-Null
+            Write-Log "  Formatted new build cache disk"
+        }
+        $partition = Get-Partition -DiskNumber $cacheDisk.Number | Where-Object { $_.Type -eq "Basic" } | Select-Object -First 1
+        if (-not $partition.DriveLetter) {
+            $partition | Add-PartitionAccessPath -AssignDriveLetter
+            $partition = Get-Partition -DiskNumber $cacheDisk.Number -PartitionNumber $partition.PartitionNumber
+        }
+        $cacheDir = "$($partition.DriveLetter):\"
+        $cacheEnv = [ordered]@{
+            SCCACHE_DIR                = Join-Path $cacheDir "sccache"
+            VCPKG_DEFAULT_BINARY_CACHE = Join-Path $cacheDir "vcpkg"
+            BUILD_CACHE_DIR            = $cacheDir
+        }
+        New-Item -ItemType Directory -Force -Path (Join-Path $cacheDir "sccache"), (Join-Path $cacheDir "vcpkg"), (Join-Path $cacheDir "shaders") | Out-Null
+        $envFile = Join-Path $runnerDir ".env"
+        $lines = @()
+        if (Test-Path $envFile) {
+            $lines = @(Get-Content $envFile | Where-Object { $_ -notmatch '^(SCCACHE_DIR|VCPKG_DEFAULT_BINARY_CACHE|BUILD_CACHE_DIR)=' })
+        }
+        foreach ($key in $cacheEnv.Keys) {
+            $lines += "$key=$($cacheEnv[$key])"
+            Set-Item -Path "env:$key" -Value $cacheEnv[$key]
+        }
+        Set-Content -Path $envFile -Value $lines
+        Write-Log "  Build cache mounted at $cacheDir"
+    }
+    catch {
+        Write-Log "WARNING: Failed to mount the build cache disk; running without it: $_"
+    }
+}
+
+# Step 2.6: Mount the local NVMe SSDs, if the scaler attached any, as scratch
+# space for jobs' temporary files. Their data is lost when the VM stops, so
+# format them when they are blank, striping several into one storage space.
+$scratchDisks = @(Get-PhysicalDisk | Where-Object { $_.FriendlyName -eq "nvme_card" })
+if ($scratchDisks.Count -gt 0) {
+    Write-Log "Mounting $($scratchDisks.Count) local SSD(s)..."
+    try {
+        $scratchDisk = $null
+        $virtualDisk = Get-VirtualDisk -FriendlyName "scratch" -ErrorAction SilentlyContinue
+        if ($virtualDisk) {
+            $scratchDisk = $virtualDisk | Get-Disk
+        }
+        elseif ($scratchDisks.Count -gt 1) {
+            $pool = New-StoragePool -FriendlyName "scratch" -StorageSubSystemFriendlyName "Windows Storage*" -PhysicalDisks $scratchDisks
+            $scratchDisk = New-VirtualDisk -StoragePoolFriendlyName $pool.FriendlyName -FriendlyName "scratch" `
+                -ResiliencySettingName Simple -NumberOfColumns $scratchDisks.Count -UseMaximumSize | Get-Disk
+        }
+        else {
+            $scratchDisk = Get-Disk -Number $scratchDisks[0].DeviceId
+        }
+        if ($scratchDisk.PartitionStyle -eq "RAW") {
+            Initialize-Disk -Number $scratchDisk.Number -PartitionStyle GPT
+            New-Partition -DiskNumber $scratchDisk.Number -UseMaximumSize |
+                Format-Volume -FileSystem NTFS -NewFileSystemLabel "scratch" -Confirm:$false | Out-Null
+        }
+        $partition = Get-Partition -DiskNumber $scratchDisk.Number | Where-Object { $_.Type -eq "Basic" } | Select-Object -First 1
+        if (-not $partition.DriveLetter) {
+            $partition | Add-PartitionAccessPath -AssignDriveLetter
+            $partition = Get-Partition -DiskNumber $scratchDisk.Number -PartitionNumber $partition.PartitionNumber
+        }
+        $scratchDir = "$($partition.DriveLetter):\"
+        $tempDir = Join-Path $scratchDir "tmp"
+        New-Item -ItemType Directory -Force -Path $tempDir | Out-Null
+        $scratchEnv = [ordered]@{
+            SCRATCH_DIR = $scratchDir
+            TEMP        = $tempDir
+            TMP         = $tempDir
+        }
+        $envFile = Join-Path $runnerDir ".env"
+        $lines = @()
+        if (Test-Path $envFile) {
+            $lines = @(Get-Content $envFile | Where-Object { $_ -notmatch '^(SCRATCH_DIR|TEMP|TMP)=' })
+        }
+        foreach ($key in $scratchEnv.Keys) {
+            $lines += "$key=$($scratchEnv[$key])"
+        }
+        Set-Content -Path $envFile -Value $lines
+        Write-Log "  Local SSDs mounted at $scratchDir"
+    }
+    catch {
+        Write-Log "WARNING: Failed to mount the local SSDs; running without them: $_"
+    }
+}
+
 # Step 3: Start sccache server (for build caching)
 Write-Log "Starting sccache server..."
 try {
@@ -207,20 +543,105 @@ catch {
 Write-Log "Configuring git safe directory..."
 git config --global --add safe.directory '*'
 
-# Step 4: Configure and run the GitHub Actions runner with JIT config
-Write-Log "Starting runner with JIT config..."
-Set-Location $runnerDir
+# The scaler sets runner-reuse when it may hand this VM another job: after the
+# runner exits it writes a new jit-config, which the loop below waits for.
+$runnerReuse = "false"
+try {
+    $runnerReuse = Invoke-RestMethod -Uri "http://metadata.google.internal/computeMetadata/v1/instance/attributes/runner-reuse" -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 10
+}
+catch {
+}
 
+# Step 3.6: Start the runners of extra slots, if the scaler gave this VM
+# several (runner-slots). Each runs from its own copy of the runner directory
+# with the JIT config in jit-config-N; slot 0 runs in Step 4 as usual, and the
+# VM shuts down once all of them have exited.
+$slotProcesses = @()
+$runnerSlots = 1
 try {
-    # The --jitconfig flag configures and runs the runner in one step.
-    # In ephemeral mode, it runs exactly one job and then exits.
-    & .\run.cmd --jitconfig $jitConfig
-    $exitCode = $LASTEXITCODE
-    Write-Log "Runner exited with code $exitCode"
+    $runnerSlots = [int](Invoke-RestMethod -Uri "http://metadata.google.internal/computeMetadata/v1/instance/attributes/runner-slots" -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 10)
 }
 catch {
-    Write-Log "ERROR: Runner failed: $_"
-    $exitCode = 1
+}
+for ($slot = 1; $slot -lt $runnerSlots; $slot++) {
+    try {
+        $slotConfig = Get-UnsealedJitConfig (Invoke-RestMethod -Uri "http://metadata.google.internal/computeMetadata/v1/instance/attributes/jit-config-$slot" -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 10)
+        $slotDir = "${runnerDir}-slot$slot"
+        Remove-Item $slotDir -Recurse -Force -ErrorAction SilentlyContinue
+        Copy-Item $runnerDir $slotDir -Recurse
+        foreach ($d in "_work", "_diag", "startup.log") {
+            Remove-Item "$slotDir\$d" -Recurse -Force -ErrorAction SilentlyContinue
+        }
+        Write-Log "Starting runner slot $slot..."
+        $slotProcesses += Start-Process -FilePath "cmd.exe" -ArgumentList "/c", "run.cmd --jitconfig $slotConfig >> `"$slotDir\runner.log`" 2>&1" -WorkingDirectory $slotDir -NoNewWindow -PassThru
+    }
+    catch {
+        Write-Log "WARNING: Failed to start runner slot ${slot}; skipping it: $_"
+    }
+}
+
+# Step 4: Configure and run the GitHub Actions runner with JIT config
+Set-Location $runnerDir
+while ($true) {
+    Write-Log "Starting runner with JIT config..."
+    Set-GuestAttribute "phase" "runner-configured"
+    Start-RunnerOnlineWatch $runnerDir
+    try {
+        # The --jitconfig flag configures and runs the runner in one step.
+        # In ephemeral mode, it runs exactly one job and then exits.
+        & .\run.cmd --jitconfig $jitConfig
+        $exitCode = $LASTEXITCODE
+        Write-Log "Runner exited with code $exitCode"
+    }
+    catch {
+        Write-Log "ERROR: Runner failed: $_"
+        $exitCode = 1
+    }
+
+    if ($runnerReuse -ne "true") {
+        break
+    }
+
+    # Wait for the scaler to write the next job's JIT config. The metadata
+    # server holds the request until the value changes or the timeout expires.
+    # A wait in flight when the scaler suspends the VM fails once it resumes,
+    # so failed waits are retried.
+    Write-Log "Waiting for a new JIT config to reuse this VM..."
+    $nextConfig = $null
+    for ($attempt = 1; $attempt -le 5; $attempt++) {
+        try {
+            $response = Invoke-WebRequest -Uri "${metadataUrl}?wait_for_change=true&timeout_sec=900&last_etag=$etag" -Headers @{ "Metadata-Flavor" = "Google" } -TimeoutSec 960 -UseBasicParsing
+            $nextConfig = $response.Content
+            $etag = $response.Headers["ETag"]
+            break
+        }
+        catch {
+            Write-Log "  Attempt ${attempt}/5: waiting for a new JIT config failed: $_"
+            Start-Sleep -Seconds 5
+        }
+    }
+    if (-not $nextConfig -or $nextConfig -eq $jitConfigRaw) {
+        Write-Log "No new JIT config arrived"
+        break
+    }
+    $jitConfigRaw = $nextConfig
+    try {
+        $jitConfig = Get-UnsealedJitConfig $jitConfigRaw
+    }
+    catch {
+        Write-Log "ERROR: Failed to decrypt the new JIT config with its Cloud KMS key: $_"
+        break
+    }
+    Write-Log "New JIT config retrieved ($($jitConfig.Length) chars); cleaning up the previous job"
+    Remove-Item "$runnerDir\_work" -Recurse -Force -ErrorAction SilentlyContinue
+    foreach ($f in ".runner", ".credentials", ".credentials_rsaparams") {
+        Remove-Item "$runnerDir\$f" -Force -ErrorAction SilentlyContinue
+    }
+}
+
+if ($slotProcesses) {
+    Write-Log "Waiting for $($slotProcesses.Count) other runner slot(s) to finish..."
+    $slotProcesses | Wait-Process
 }
 
 # Step 5: Stop sccache and show stats
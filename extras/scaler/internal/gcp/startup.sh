@@ -9,8 +9,10 @@
 # 1. Removes any pre-existing runner service from the base image
 # 2. Updates the preinstalled GitHub Actions runner if it is stale
 # 3. Reads the JIT config from GCP instance metadata
-# 4. Starts the GitHub Actions runner as the correct user
-# 5. Shuts down the VM when the job completes
+# 4. Starts the GitHub Actions runner as the correct user, or one runner per
+#    slot when the scaler gives the VM several (runner-slots)
+# 5. Shuts down the VM when the job completes, or, when the scaler reuses
+#    VMs, waits for the next job's JIT config and runs the runner again
 
 set -euo pipefail
 
@@ -89,12 +91,89 @@ log() {
   echo "$msg" >>"$LOG_FILE"
 }
 
+# read_metadata prints an instance metadata attribute, or nothing when it is
+# not set.
+read_metadata() {
+  curl -sf --max-time 10 --connect-timeout 5 -H "Metadata-Flavor: Google" \
+    "http://metadata.google.internal/computeMetadata/v1/instance/attributes/$1" 2>/dev/null || true
+}
+
+# unseal_jit_config prints the JIT config read from metadata as $1. When the
+# scaler set jit-config-kms-key, $1 is encrypted with that Cloud KMS key and
+# is decrypted with the VM's service account, which needs
+# roles/cloudkms.cryptoKeyDecrypter on the key.
+unseal_jit_config() {
+  local key token plaintext
+  key="$(read_metadata jit-config-kms-key)"
+  if [ -z "$key" ]; then
+    printf '%s' "$1"
+    return 0
+  fi
+  token="$(curl -sf --max-time 10 --connect-timeout 5 -H "Metadata-Flavor: Google" \
+    "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token" |
+    sed -n 's/.*"access_token" *: *"\([^"]*\)".*/\1/p')" || return 1
+  plaintext="$(curl -sf --max-time 30 --connect-timeout 5 -X POST \
+    -H "Authorization: Bearer $token" -H "Content-Type: application/json" \
+    --data "{\"ciphertext\":\"$1\"}" "https://cloudkms.googleapis.com/v1/${key}:decrypt" |
+    sed -n 's/.*"plaintext" *: *"\([^"]*\)".*/\1/p')" || return 1
+  [ -n "$plaintext" ] || return 1
+  base64 -d <<<"$plaintext"
+}
+
+# report_guest_attribute sets the runner/KEY guest attribute the scaler
+# reads. It does nothing unless the scaler set the enable-guest-attributes
+# metadata. The boot progresses through the phase values started,
+# driver-ok, runner-configured and runner-online, or ends in failed with
+# the reason in error.
+GUEST_ATTRIBUTES="$(read_metadata enable-guest-attributes)"
+report_guest_attribute() {
+  [ "${GUEST_ATTRIBUTES^^}" = "TRUE" ] || return 0
+  curl -sf --max-time 10 --connect-timeout 5 -X PUT --data "$2" -H "Metadata-Flavor: Google" \
+    "http://metadata.google.internal/computeMetadata/v1/instance/guest-attributes/runner/$1" >/dev/null 2>&1 ||
+    log "WARNING: Failed to report guest attribute $1"
+}
+
+# report_boot_failure reports a fatal boot error to the scaler.
+report_boot_failure() {
+  report_guest_attribute error "$(head -n 1 <<<"$1" | cut -c1-200)"
+  report_guest_attribute phase failed
+}
+
+# fail_boot logs and reports a fatal boot error and shuts the VM down.
+fail_boot() {
+  log "ERROR: $1"
+  report_boot_failure "$1"
+  shutdown -h now
+  exit 1
+}
+
+# watch_runner_online reports runner-online once the runner in dir has
+# connected to GitHub and is listening for its job.
+watch_runner_online() {
+  local dir="$1" marker
+  marker="$(mktemp)"
+  (
+    for _ in $(seq 1 120); do
+      if [ -n "$(find "$dir/_diag" -name 'Runner_*.log' -newer "$marker" -exec grep -ls 'Listening for Jobs' {} + 2>/dev/null)" ]; then
+        report_guest_attribute phase runner-online
+        break
+      fi
+      sleep 5
+    done
+    rm -f "$marker"
+  ) &
+}
+
 log "=== Linux Runner Startup ==="
 log "Runner directory: $RUNNER_DIR"
 log "Runner user: $RUNNER_USER"
+report_guest_attribute error ""
+report_guest_attribute shutdown ""
+report_guest_attribute phase started
 
 fail_update_and_shutdown() {
   log "ERROR: $1"
+  report_boot_failure "$1"
   if [ -n "${runner_archive:-}" ]; then
     rm -f "$runner_archive" || true
   fi
@@ -120,6 +199,80 @@ for f in .runner .credentials .credentials_rsaparams .runner_migrated; do
     log "  Removed $f"
   fi
 done
+# A VM started from the scaler's stopped pool still has the previous job's
+# workspace.
+if [ -d "$RUNNER_DIR/_work" ]; then
+  rm -rf "$RUNNER_DIR/_work"
+  log "  Removed the previous job's workspace"
+fi
+
+# The scaler may attach a persistent build cache disk, which the pool's VMs
+# take turns using. Format it on first use, mount it and point the caches at
+# it through the runner's .env file, which the runner passes on to jobs.
+CACHE_DEVICE=/dev/disk/by-id/google-build-cache
+CACHE_DIR=/mnt/build-cache
+if [ -e "$CACHE_DEVICE" ]; then
+  log "Mounting build cache disk at $CACHE_DIR..."
+  if ! blkid "$CACHE_DEVICE" >/dev/null 2>&1; then
+    mkfs.ext4 -q -F -L build-cache "$CACHE_DEVICE" && log "  Formatted new build cache disk" ||
+      log "WARNING: Failed to format the build cache disk"
+  fi
+  mkdir -p "$CACHE_DIR"
+  if mountpoint -q "$CACHE_DIR" || mount -o discard,defaults "$CACHE_DEVICE" "$CACHE_DIR"; then
+    mkdir -p "$CACHE_DIR/ccache" "$CACHE_DIR/vcpkg" "$CACHE_DIR/shaders"
+    chown "$RUNNER_USER":"$RUNNER_USER" "$CACHE_DIR" "$CACHE_DIR/ccache" "$CACHE_DIR/vcpkg" "$CACHE_DIR/shaders"
+    touch "$RUNNER_DIR/.env"
+    sed -i '/^\(CCACHE_DIR\|VCPKG_DEFAULT_BINARY_CACHE\|BUILD_CACHE_DIR\)=/d' "$RUNNER_DIR/.env"
+    {
+      echo "CCACHE_DIR=$CACHE_DIR/ccache"
+      echo "VCPKG_DEFAULT_BINARY_CACHE=$CACHE_DIR/vcpkg"
+      echo "BUILD_CACHE_DIR=$CACHE_DIR"
+    } >>"$RUNNER_DIR/.env"
+    chown "$RUNNER_USER":"$RUNNER_USER" "$RUNNER_DIR/.env"
+    log "  Build cache mounted ($(df -h --output=used,size "$CACHE_DIR" | tail -n 1 | xargs) used)"
+  else
+    log "WARNING: Failed to mount the build cache disk; running without it"
+  fi
+fi
+
+# The scaler may attach local NVMe SSDs as scratch space for jobs' temporary
+# files. Their data is lost when the VM stops, so format them on every boot
+# that finds them blank, striping several into one RAID 0 volume.
+SCRATCH_DIR=/mnt/scratch
+mapfile -t SCRATCH_DEVICES < <(ls /dev/disk/by-id/google-local-nvme-ssd-* 2>/dev/null || true)
+if [ "${#SCRATCH_DEVICES[@]}" -gt 0 ]; then
+  log "Mounting ${#SCRATCH_DEVICES[@]} local SSD(s) at $SCRATCH_DIR..."
+  SCRATCH_DEVICE="${SCRATCH_DEVICES[0]}"
+  if [ "${#SCRATCH_DEVICES[@]}" -gt 1 ]; then
+    if ! command -v mdadm >/dev/null 2>&1; then
+      log "WARNING: mdadm is not installed; using only the first local SSD"
+    elif [ -e /dev/md/scratch ] || mdadm --assemble /dev/md/scratch "${SCRATCH_DEVICES[@]}" >/dev/null 2>&1 ||
+      mdadm --create /dev/md/scratch --run --level=0 --raid-devices="${#SCRATCH_DEVICES[@]}" "${SCRATCH_DEVICES[@]}" >/dev/null 2>&1; then
+      SCRATCH_DEVICE=/dev/md/scratch
+    else
+      log "WARNING: Failed to stripe the local SSDs; using only the first"
+    fi
+  fi
+  if ! blkid "$SCRATCH_DEVICE" >/dev/null 2>&1; then
+    mkfs.ext4 -q -F -L scratch "$SCRATCH_DEVICE" || log "WARNING: Failed to format the local SSDs"
+  fi
+  mkdir -p "$SCRATCH_DIR"
+  if mountpoint -q "$SCRATCH_DIR" || mount -o discard,defaults "$SCRATCH_DEVICE" "$SCRATCH_DIR"; then
+    mkdir -p "$SCRATCH_DIR/tmp"
+    chmod 1777 "$SCRATCH_DIR/tmp"
+    chown "$RUNNER_USER":"$RUNNER_USER" "$SCRATCH_DIR"
+    touch "$RUNNER_DIR/.env"
+    sed -i '/^\(SCRATCH_DIR\|TMPDIR\)=/d' "$RUNNER_DIR/.env"
+    {
+      echo "SCRATCH_DIR=$SCRATCH_DIR"
+      echo "TMPDIR=$SCRATCH_DIR/tmp"
+    } >>"$RUNNER_DIR/.env"
+    chown "$RUNNER_USER":"$RUNNER_USER" "$RUNNER_DIR/.env"
+    log "  Local SSDs mounted ($(df -h --output=size "$SCRATCH_DIR" | tail -n 1 | xargs))"
+  else
+    log "WARNING: Failed to mount the local SSDs; running without them"
+  fi
+fi
 
 runner_version() {
   if [ -x "$RUNNER_DIR/bin/Runner.Listener" ]; then
@@ -199,9 +352,7 @@ elif grep -qi '0x10de' /sys/bus/pci/devices/*/vendor 2>/dev/null; then
 fi
 
 if [ "$EXPECT_GPU" != "false" ] && [ "$gpu_present" != "true" ]; then
-  log "ERROR: This pool expects an NVIDIA GPU but none is attached (accelerator attach failed?). Refusing to register a GPU runner with no device."
-  shutdown -h now
-  exit 1
+  fail_boot "This pool expects an NVIDIA GPU but none is attached (accelerator attach failed?). Refusing to register a GPU runner with no device."
 fi
 
 if [ "$gpu_present" = "true" ]; then
@@ -218,9 +369,7 @@ if [ "$gpu_present" = "true" ]; then
   done
 
   if [ "$gpu_ready" != "true" ]; then
-    log "ERROR: GPU initialization failed after 10 attempts"
-    shutdown -h now
-    exit 1
+    fail_boot "GPU initialization failed after 10 attempts"
   fi
 
   # Create nvidia-modeset device if it doesn't exist (needed for Vulkan)
@@ -258,6 +407,48 @@ if [ "$gpu_present" = "true" ]; then
 else
   log "No NVIDIA GPU on the PCI bus and none expected; skipping GPU initialization (CPU-only runner)."
 fi
+report_guest_attribute phase driver-ok
+
+
+# The scaler may run several runners on this VM, one per slot. On A100 pools
+# with a MIG profile, each slot gets its own MIG slice of the GPUs.
+RUNNER_SLOTS="$(read_metadata runner-slots)"
+RUNNER_SLOTS="${RUNNER_SLOTS:-1}"
+MIG_PROFILE="$(read_metadata mig-profile)"
+MIG_DEVICES=()
+if [ -n "$MIG_PROFILE" ]; then
+  log "Partitioning GPUs into $RUNNER_SLOTS MIG slice(s) of profile $MIG_PROFILE..."
+  nvidia-smi -mig 1 2>&1 | while read -r line; do log "  $line"; done || true
+  if nvidia-smi --query-gpu=mig.mode.current --format=csv,noheader | grep -qv Enabled; then
+    # Enabling MIG takes effect after a GPU reset.
+    nvidia-smi -r 2>&1 | while read -r line; do log "  $line"; done || true
+  fi
+  if nvidia-smi --query-gpu=mig.mode.current --format=csv,noheader | grep -qv Enabled; then
+    fail_boot "Failed to enable MIG mode"
+  fi
+  # Start from no instances, e.g. after a reboot, then give each GPU its
+  # share of the slices.
+  nvidia-smi mig -dci >/dev/null 2>&1 || true
+  nvidia-smi mig -dgi >/dev/null 2>&1 || true
+  gpu_count="$(nvidia-smi --query-gpu=index --format=csv,noheader | wc -l)"
+  per_gpu=$(((RUNNER_SLOTS + gpu_count - 1) / gpu_count))
+  remaining="$RUNNER_SLOTS"
+  for gpu in $(nvidia-smi --query-gpu=index --format=csv,noheader); do
+    n=$((remaining < per_gpu ? remaining : per_gpu))
+    [ "$n" -gt 0 ] || break
+    profiles="$(printf "${MIG_PROFILE},%.0s" $(seq 1 "$n"))"
+    profiles="${profiles%,}"
+    if ! mig_out="$(nvidia-smi mig -i "$gpu" -cgi "$profiles" -C 2>&1)"; then
+      fail_boot "Failed to create MIG instances on GPU $gpu: $mig_out"
+    fi
+    remaining=$((remaining - n))
+  done
+  mapfile -t MIG_DEVICES < <(nvidia-smi -L | grep -o 'MIG-[0-9a-f-]*')
+  log "  Created ${#MIG_DEVICES[@]} MIG device(s)"
+  if [ "${#MIG_DEVICES[@]}" -lt "$RUNNER_SLOTS" ]; then
+    fail_boot "Expected $RUNNER_SLOTS MIG devices, found ${#MIG_DEVICES[@]}"
+  fi
+fi
 
 # Step 1: Read JIT config from GCP instance metadata
 log "Reading JIT config from instance metadata..."
@@ -265,33 +456,170 @@ METADATA_URL="http://metadata.google.internal/computeMetadata/v1/instance/attrib
 MAX_RETRIES=10
 JIT_CONFIG=""
 
+HEADERS_FILE="$(mktemp)"
+
 for i in $(seq 1 "$MAX_RETRIES"); do
-  JIT_CONFIG=$(curl -sf --max-time 10 --connect-timeout 5 -H "Metadata-Flavor: Google" "$METADATA_URL") && break
+  JIT_CONFIG=$(curl -sf --max-time 10 --connect-timeout 5 -D "$HEADERS_FILE" -H "Metadata-Flavor: Google" "$METADATA_URL") && break
   log "  Attempt ${i}/${MAX_RETRIES}: Metadata not available yet, waiting..."
   sleep 5
 done
 
 if [ -z "$JIT_CONFIG" ]; then
-  log "ERROR: Failed to read JIT config from metadata after $MAX_RETRIES attempts"
-  shutdown -h now
-  exit 1
+  fail_boot "Failed to read JIT config from metadata after $MAX_RETRIES attempts"
 fi
 
 log "JIT config retrieved (${#JIT_CONFIG} chars)"
+# JIT_CONFIG_RAW is the metadata value, which the reuse loop watches for a
+# change.
+JIT_CONFIG_RAW="$JIT_CONFIG"
+JIT_CONFIG="$(unseal_jit_config "$JIT_CONFIG_RAW")" || fail_boot "Failed to decrypt the JIT config with its Cloud KMS key"
 
 # Step 2: Log GPU and system info
 log "=== System Information ==="
 nvidia-smi 2>&1 | while read -r line; do log "  $line"; done || log "WARNING: nvidia-smi not available"
 docker --version 2>&1 | while read -r line; do log "  $line"; done || log "WARNING: docker not available"
 
+# Step 2.5: GPU smoke test. With gpu-check set, the scaler reads the result
+# from the runner/gpu-check guest attribute and replaces a VM whose check
+# failed, so no job lands on a broken driver install. Vulkan is checked when
+# vulkaninfo is installed; MIG slices have no Vulkan support. CPU-only VMs
+# report "skipped", so the scaler stops asking.
+GPU_CHECK="$(read_metadata gpu-check)"
+if [ "$GPU_CHECK" = "true" ] && [ "$EXPECT_GPU" = "false" ]; then
+  report_guest_attribute gpu-check skipped
+elif [ "$GPU_CHECK" = "true" ]; then
+  log "Running GPU smoke test..."
+  report_guest_attribute gpu-check running
+  gpu_check_error=""
+  if ! smi_out="$(nvidia-smi --query-gpu=name,driver_version --format=csv,noheader 2>&1)"; then
+    gpu_check_error="nvidia-smi: ${smi_out}"
+  elif [ -z "$MIG_PROFILE" ] && command -v vulkaninfo >/dev/null 2>&1; then
+    vk_out="$(vulkaninfo --summary 2>&1 || true)"
+    if ! grep -qi nvidia <<<"$vk_out"; then
+      gpu_check_error="vulkaninfo lists no NVIDIA device"
+    fi
+  fi
+  if [ -n "$gpu_check_error" ]; then
+    log "ERROR: GPU smoke test failed: $gpu_check_error"
+    report_guest_attribute gpu-check "failed: $(head -n 1 <<<"$gpu_check_error" | cut -c1-200)"
+    report_boot_failure "GPU smoke test failed: $gpu_check_error"
+    # Give the scaler time to read the result and replace the VM.
+    sleep 600
+    shutdown -h now
+    exit 1
+  fi
+  log "  GPU smoke test passed: ${smi_out}"
+  report_guest_attribute gpu-check ok
+fi
+
+# The scaler sets runner-reuse when it may hand this VM another job: after the
+# runner exits it writes a new jit-config, which the loop below waits for.
+RUNNER_REUSE="$(curl -sf --max-time 10 --connect-timeout 5 \
+  -H "Metadata-Flavor: Google" \
+  "http://metadata.google.internal/computeMetadata/v1/instance/attributes/runner-reuse" 2>/dev/null || echo "false")"
+
+# metadata_etag prints the ETag of the last metadata response.
+metadata_etag() {
+  grep -i '^etag:' "$HEADERS_FILE" | awk '{print $2}' | tr -d '\r'
+}
+
+# set_slot_env points a slot's runner at its MIG device, if any, through
+# the runner directory's .env file.
+set_slot_env() {
+  local dir="$1" slot="$2"
+  [ "${#MIG_DEVICES[@]}" -gt 0 ] || return 0
+  touch "$dir/.env"
+  sed -i '/^\(CUDA_VISIBLE_DEVICES\|NVIDIA_VISIBLE_DEVICES\)=/d' "$dir/.env"
+  {
+    echo "CUDA_VISIBLE_DEVICES=${MIG_DEVICES[$slot]}"
+    echo "NVIDIA_VISIBLE_DEVICES=${MIG_DEVICES[$slot]}"
+  } >>"$dir/.env"
+  chown "$RUNNER_USER":"$RUNNER_USER" "$dir/.env"
+}
+
+# Step 3a: Run one runner per slot, each from its own copy of the runner
+# directory, and shut down once all of them have exited.
+if [ "$RUNNER_SLOTS" -gt 1 ]; then
+  report_guest_attribute phase runner-configured
+  watch_runner_online "$RUNNER_DIR"
+  pids=()
+  for slot in $(seq 0 $((RUNNER_SLOTS - 1))); do
+    dir="$RUNNER_DIR"
+    slot_config="$JIT_CONFIG"
+    if [ "$slot" -gt 0 ]; then
+      dir="${RUNNER_DIR}-slot${slot}"
+      rm -rf "$dir"
+      cp -a "$RUNNER_DIR" "$dir"
+      rm -rf "$dir/_work" "$dir/_diag"
+      slot_config="$(read_metadata "jit-config-${slot}")"
+      if [ -z "$slot_config" ]; then
+        log "WARNING: No JIT config for runner slot $slot; skipping it"
+        continue
+      fi
+      if ! slot_config="$(unseal_jit_config "$slot_config")"; then
+        log "WARNING: Failed to decrypt the JIT config of runner slot $slot; skipping it"
+        continue
+      fi
+    fi
+    set_slot_env "$dir" "$slot"
+    log "Starting runner slot $slot as user '$RUNNER_USER'..."
+    (cd "$dir" && sudo -u "$RUNNER_USER" ./run.sh --jitconfig "$slot_config") >>"$LOG_FILE" 2>&1 &
+    pids+=($!)
+  done
+  for pid in "${pids[@]}"; do
+    wait "$pid" || true
+  done
+  log "=== All runner slots complete, shutting down VM ==="
+  shutdown -h now
+  exit 0
+fi
+set_slot_env "$RUNNER_DIR" 0
+
 # Step 3: Run the GitHub Actions runner as the correct user
-log "Starting runner as user '$RUNNER_USER' with JIT config..."
 cd "$RUNNER_DIR"
+while true; do
+  log "Starting runner as user '$RUNNER_USER' with JIT config..."
+  report_guest_attribute phase runner-configured
+  watch_runner_online "$RUNNER_DIR"
+
+  # Run as the runner user, not root. The runner agent requires this.
+  EXIT_CODE=0
+  sudo -u "$RUNNER_USER" ./run.sh --jitconfig "$JIT_CONFIG" || EXIT_CODE=$?
+  log "Runner exited with code $EXIT_CODE"
+
+  if [ "$RUNNER_REUSE" != "true" ]; then
+    break
+  fi
 
-# Run as the runner user, not root. The runner agent requires this.
-EXIT_CODE=0
-sudo -u "$RUNNER_USER" ./run.sh --jitconfig "$JIT_CONFIG" || EXIT_CODE=$?
-log "Runner exited with code $EXIT_CODE"
+  # Wait for the scaler to write the next job's JIT config. The metadata
+  # server holds the request until the value changes or the timeout expires.
+  # A wait in flight when the scaler suspends the VM fails once it resumes,
+  # so failed waits are retried.
+  log "Waiting for a new JIT config to reuse this VM..."
+  NEXT_CONFIG=""
+  for attempt in 1 2 3 4 5; do
+    NEXT_CONFIG=$(curl -sf --max-time 960 --connect-timeout 5 -D "$HEADERS_FILE" \
+      -H "Metadata-Flavor: Google" \
+      "${METADATA_URL}?wait_for_change=true&timeout_sec=900&last_etag=$(metadata_etag)") && break
+    NEXT_CONFIG=""
+    log "  Attempt ${attempt}/5: waiting for a new JIT config failed"
+    sleep 5
+  done
+  if [ -z "$NEXT_CONFIG" ] || [ "$NEXT_CONFIG" = "$JIT_CONFIG_RAW" ]; then
+    log "No new JIT config arrived"
+    break
+  fi
+  JIT_CONFIG_RAW="$NEXT_CONFIG"
+  if ! JIT_CONFIG="$(unseal_jit_config "$JIT_CONFIG_RAW")"; then
+    log "ERROR: Failed to decrypt the new JIT config with its Cloud KMS key"
+    break
+  fi
+  log "New JIT config retrieved (${#JIT_CONFIG} chars); cleaning up the previous job"
+  rm -rf "$RUNNER_DIR/_work"
+  for f in .runner .credentials .credentials_rsaparams .runner_migrated; do
+    rm -f "$RUNNER_DIR/$f"
+  done
+done
 
 # Step 4: Shut down the VM
 log "=== Runner complete, shutting down VM ==="
@@ -0,0 +1,272 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stateSaveInterval is how often the tracked VMs are written to StateFile,
+// when they changed since the last write.
+const stateSaveInterval = 5 * time.Second
+
+// stateVersion is the StateFile format. A file of another version is
+// ignored.
+const stateVersion = 1
+
+// savedState is the StateFile: the tracked VMs and the creates in flight,
+// by runner name.
+type savedState struct {
+	Version int                    `json:"version"`
+	VMs     map[string]savedVM     `json:"vms"`
+	Creates map[string]savedCreate `json:"creates,omitempty"`
+}
+
+// savedVM is the part of a vmInfo that outlives the process.
+type savedVM struct {
+	VMName      string    `json:"vm_name"`
+	Zone        string    `json:"zone"`
+	Busy        bool      `json:"busy,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Template    string    `json:"template,omitempty"`
+	ReusedAt    time.Time `json:"reused_at,omitzero"`
+	Jobs        int       `json:"jobs,omitempty"`
+	Slot        int       `json:"slot,omitempty"`
+	Reservation string    `json:"reservation,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	Size        VMSize    `json:"size,omitzero"`
+	HourlyCost  float64   `json:"hourly_cost_usd,omitempty"`
+}
+
+// savedCreate records a create before its Insert, or the start of a stopped
+// VM. The runner's JIT config was issued by then, so it is registered with
+// GitHub whether or not its VM came to exist.
+type savedCreate struct {
+	VMName    string    `json:"vm_name"`
+	Zone      string    `json:"zone"`
+	Slot      int       `json:"slot,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// encodeStateLocked returns the tracked VMs as StateFile contents. The
+// caller must hold m.mu.
+func (m *Manager) encodeStateLocked() ([]byte, error) {
+	st := savedState{Version: stateVersion, VMs: make(map[string]savedVM, len(m.vms))}
+	for runnerName, vm := range m.vms {
+		st.VMs[runnerName] = savedVM{
+			VMName:      vm.vmName,
+			Zone:        vm.zone,
+			Busy:        vm.busy,
+			CreatedAt:   vm.createdAt,
+			Template:    vm.template,
+			ReusedAt:    vm.reusedAt,
+			Jobs:        vm.jobs,
+			Slot:        vm.slot,
+			Reservation: vm.reservation,
+			Image:       vm.image,
+			Size:        vm.size,
+			HourlyCost:  vm.hourlyCost,
+		}
+	}
+	for runnerName, c := range m.pendingCreates {
+		if c.vmName == "" {
+			continue
+		}
+		if st.Creates == nil {
+			st.Creates = make(map[string]savedCreate)
+		}
+		st.Creates[runnerName] = savedCreate{VMName: c.vmName, Zone: c.zone, Slot: c.slot, StartedAt: c.startedAt}
+	}
+	return json.Marshal(st)
+}
+
+// saveState writes the tracked VMs to StateFile if they changed since the
+// last write. It does nothing before restoreState ran, so a manager that
+// failed to start does not overwrite the previous run's state.
+func (m *Manager) saveState() error {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if !m.stateRestored {
+		return nil
+	}
+	m.mu.Lock()
+	data, err := m.encodeStateLocked()
+	m.mu.Unlock()
+	if err != nil || bytes.Equal(data, m.savedState) {
+		return err
+	}
+	if err := writeFileAtomic(m.config.StateFile, data); err != nil {
+		return err
+	}
+	m.savedState = data
+	return nil
+}
+
+// saveCreateIntent writes StateFile before runnerName's VM is inserted or
+// started, so a scaler that crashes before the create returns finds it on
+// restart. A failed write only loses that.
+func (m *Manager) saveCreateIntent(runnerName string) {
+	if m.config.StateFile == "" {
+		return
+	}
+	if err := m.saveState(); err != nil {
+		slog.Warn("failed to record create in state file", "runner", runnerName, "path", m.config.StateFile, "error", err)
+	}
+}
+
+// writeFileAtomic replaces path with data, so a crash mid-write leaves the
+// previous contents.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// restoreState tracks the VMs a previous run saved to StateFile, then drops
+// those no longer live in GCP, so a scaler restarted after a crash neither
+// loses its running VMs nor creates VMs for jobs they already serve. The
+// creates it recorded are finished the same way: one whose VM is live is
+// tracked, and one whose VM never came to exist is left for
+// AbandonedCreates. A missing, unreadable or outdated file starts with no
+// VMs.
+func (m *Manager) restoreState(ctx context.Context) error {
+	data, err := os.ReadFile(m.config.StateFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		data = nil
+	case err != nil:
+		return fmt.Errorf("reading state file: %w", err)
+	}
+	restored := 0
+	var creates []string
+	if data != nil {
+		var st savedState
+		if err := json.Unmarshal(data, &st); err != nil || st.Version != stateVersion {
+			slog.Warn("ignoring unusable state file", "path", m.config.StateFile, "version", st.Version, "error", err)
+		} else {
+			restored = m.trackSavedVMs(st)
+			creates = m.trackSavedCreates(st)
+		}
+	}
+	if restored+len(creates) > 0 {
+		m.reconcileTrackedVMs(ctx)
+		slog.Info("restored tracked VMs from the state file", "path", m.config.StateFile, "restored", restored, "creates", len(creates), "live", m.ActiveCount())
+	}
+	m.mu.Lock()
+	for _, runnerName := range creates {
+		if vm, ok := m.vms[runnerName]; ok {
+			slog.Info("finished a create cut short by a restart", "runner", runnerName, "vm", vm.vmName, "zone", vm.zone)
+		} else {
+			slog.Warn("VM of a create cut short by a restart does not exist", "runner", runnerName)
+			m.abandonedCreates = append(m.abandonedCreates, runnerName)
+		}
+	}
+	m.mu.Unlock()
+	m.stateMu.Lock()
+	m.stateRestored = true
+	m.stateMu.Unlock()
+	return m.saveState()
+}
+
+// trackSavedVMs adds the VMs of st named with VMPrefix, leaving out any of
+// another pool whose state file this was, and returns how many.
+func (m *Manager) trackSavedVMs(st savedState) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for runnerName, vm := range st.VMs {
+		if vm.VMName == "" || vm.Zone == "" || !strings.HasPrefix(vm.VMName, m.config.VMPrefix+"-") {
+			continue
+		}
+		m.vms[runnerName] = &vmInfo{
+			vmName:      vm.VMName,
+			zone:        vm.Zone,
+			busy:        vm.Busy,
+			createdAt:   vm.CreatedAt,
+			template:    vm.Template,
+			reusedAt:    vm.ReusedAt,
+			jobs:        vm.Jobs,
+			slot:        vm.Slot,
+			reservation: vm.Reservation,
+			image:       vm.Image,
+			size:        vm.Size,
+			hourlyCost:  vm.HourlyCost,
+		}
+		n++
+	}
+	return n
+}
+
+// trackSavedCreates tracks the VMs of the creates recorded in st, as
+// trackSavedVMs does, and returns their runners. Those whose VMs are not
+// live are dropped with the rest by reconcileTrackedVMs.
+func (m *Manager) trackSavedCreates(st savedState) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var runners []string
+	for runnerName, c := range st.Creates {
+		if c.VMName == "" || c.Zone == "" || !strings.HasPrefix(c.VMName, m.config.VMPrefix+"-") {
+			continue
+		}
+		if _, ok := m.vms[runnerName]; ok {
+			continue
+		}
+		m.vms[runnerName] = &vmInfo{vmName: c.VMName, zone: c.Zone, createdAt: c.StartedAt, slot: c.Slot}
+		runners = append(runners, runnerName)
+	}
+	sort.Strings(runners)
+	return runners
+}
+
+// AbandonedCreates returns the runners of the creates a previous run
+// recorded in StateFile but whose VMs never came to exist, e.g. because
+// the scaler crashed before its Insert. Their runners are still registered
+// with GitHub, which the caller is left to remove. Later calls return
+// nothing.
+func (m *Manager) AbandonedCreates() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runners := m.abandonedCreates
+	m.abandonedCreates = nil
+	return runners
+}
+
+// watchState saves the tracked VMs every stateSaveInterval until ctx is
+// done. Close saves them one last time.
+func (m *Manager) watchState(ctx context.Context) {
+	ticker := time.NewTicker(stateSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.saveState(); err != nil {
+				slog.Warn("failed to save state file", "path", m.config.StateFile, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+func TestStateFileRestoresLiveVMs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	created := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+	old := &Manager{
+		config: ManagerConfig{VMPrefix: "win-test", StateFile: path},
+		vms: map[string]*vmInfo{
+			"win-test-a": {vmName: "win-test-a", zone: "us-east1-c", busy: true, createdAt: created, template: "win-gpu", jobs: 1, size: VMSize{GPU: "t4", CPUs: 8}, hourlyCost: 0.9},
+			"win-test-b": {vmName: "win-test-b", zone: "us-east1-d", createdAt: created},
+		},
+		stateRestored: true,
+	}
+	if err := old.saveState(); err != nil {
+		t.Fatalf("saveState: %v", err)
+	}
+
+	m := &Manager{
+		config: ManagerConfig{VMPrefix: "win-test", StateFile: path},
+		vms:    make(map[string]*vmInfo),
+	}
+	// win-test-b finished its job while the scaler was down.
+	m.listLive = func(_ context.Context, zone string) ([]string, error) {
+		if zone == "us-east1-c" {
+			return []string{"win-test-a"}, nil
+		}
+		return nil, nil
+	}
+	if err := m.restoreState(context.Background()); err != nil {
+		t.Fatalf("restoreState: %v", err)
+	}
+	if len(m.vms) != 1 || !reflect.DeepEqual(m.vms["win-test-a"], old.vms["win-test-a"]) {
+		t.Fatalf("restored VMs = %+v, want only the live win-test-a as saved", m.vms)
+	}
+
+	// The reconciled state is saved right away.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(m.savedState) || reflect.DeepEqual(data, old.savedState) {
+		t.Fatalf("state file = %s, want the reconciled VMs", data)
+	}
+}
+
+func TestStateFileSkipsOtherPoolsAndBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	m := &Manager{
+		config: ManagerConfig{VMPrefix: "linux-test", StateFile: path},
+		vms:    make(map[string]*vmInfo),
+	}
+	// Before restoreState, nothing is written over the previous run's file.
+	if err := m.saveState(); err != nil {
+		t.Fatalf("saveState: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("state file written before restoreState: %v", err)
+	}
+
+	for _, data := range []string{
+		`{"version":1,"vms":{"win-test-a":{"vm_name":"win-test-a","zone":"us-east1-c"}}}`,
+		`{"version":99,"vms":{"linux-test-a":{"vm_name":"linux-test-a","zone":"us-east1-c"}}}`,
+		`not json`,
+	} {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		m.stateRestored = false
+		if err := m.restoreState(context.Background()); err != nil {
+			t.Fatalf("restoreState(%s): %v", data, err)
+		}
+		if len(m.vms) != 0 {
+			t.Fatalf("restoreState(%s) tracked %v, want nothing", data, m.vms)
+		}
+	}
+
+	// A state file in a missing directory fails the start.
+	m = &Manager{
+		config: ManagerConfig{VMPrefix: "linux-test", StateFile: filepath.Join(dir, "missing", "state.json")},
+		vms:    make(map[string]*vmInfo),
+	}
+	if err := m.restoreState(context.Background()); err == nil {
+		t.Fatal("restoreState should fail when the state file cannot be written")
+	}
+}
+
+func TestStateFileRecordsCreatesBeforeInsert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	old := &Manager{
+		config:         ManagerConfig{Project: "test-project", InstanceTemplate: "t", GPUType: "none", VMPrefix: "win-test", StateFile: path},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		stateRestored:  true,
+	}
+	old.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-c", region: "us-east1"}}, nil
+	}
+	var recorded []byte
+	old.insertVMFunc = func(context.Context, *computepb.InsertInstanceRequest) error {
+		// The scaler crashes mid-Insert.
+		var err error
+		recorded, err = os.ReadFile(path)
+		return err
+	}
+	for _, name := range []string{"win-test-a", "win-test-b"} {
+		if _, err := old.CreateVM(context.Background(), name, "jit"); err != nil {
+			t.Fatalf("CreateVM(%s): %v", name, err)
+		}
+	}
+	// Only win-test-a's VM came to exist.
+	if err := os.WriteFile(path, recorded, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := &Manager{
+		config: ManagerConfig{VMPrefix: "win-test", StateFile: path},
+		vms:    make(map[string]*vmInfo),
+	}
+	m.listLive = func(context.Context, string) ([]string, error) {
+		return []string{"win-test-a"}, nil
+	}
+	if err := m.restoreState(context.Background()); err != nil {
+		t.Fatalf("restoreState: %v", err)
+	}
+	if vm := m.vms["win-test-a"]; vm == nil || vm.vmName != "win-test-a" || vm.zone != "us-east1-c" || len(m.vms) != 1 {
+		t.Fatalf("restored VMs = %+v, want the live win-test-a", m.vms)
+	}
+	if got := m.AbandonedCreates(); !reflect.DeepEqual(got, []string{"win-test-b"}) {
+		t.Fatalf("AbandonedCreates() = %v, want [win-test-b]", got)
+	}
+	if got := m.AbandonedCreates(); got != nil {
+		t.Fatalf("AbandonedCreates() again = %v, want nothing", got)
+	}
+	// The finished creates are no longer recorded.
+	if data, _ := os.ReadFile(path); bytes.Contains(data, []byte("creates")) {
+		t.Fatalf("state file = %s, want no creates", data)
+	}
+}
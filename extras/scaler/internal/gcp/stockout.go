@@ -0,0 +1,69 @@
+package gcp
+
+import (
+	"sort"
+	"time"
+)
+
+// ZoneCooldown is a zone skipped for new VMs of a GPU type after a stockout
+// there, until Until (see StockoutCooldown).
+type ZoneCooldown struct {
+	Zone    string    `json:"zone"`
+	GPUType string    `json:"gpu_type"`
+	Until   time.Time `json:"until"`
+}
+
+// zoneGPU keys the stockout cooldowns: a zone out of one GPU type may
+// still have others.
+type zoneGPU struct{ zone, gpuType string }
+
+// stockedOutZonesLocked drops the candidates whose zone is cooling down
+// after a stockout of gpuType. Quota says nothing about physical stock, so a
+// zone that just ran out would otherwise be tried first again. When every
+// candidate is cooling down they are all kept, since one of them may have
+// stock again. The caller must hold m.mu.
+func (m *Manager) stockedOutZonesLocked(candidates []zoneCandidate, gpuType string) []zoneCandidate {
+	if len(m.stockoutUntil) == 0 {
+		return candidates
+	}
+	now := m.now()
+	var out []zoneCandidate
+	for _, c := range candidates {
+		if !now.Before(m.stockoutUntil[zoneGPU{c.zone, gpuType}]) {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		return candidates
+	}
+	return out
+}
+
+// clearStockout ends the cooldown of zone for gpuType once a VM was created
+// there.
+func (m *Manager) clearStockout(zone, gpuType string) {
+	m.mu.Lock()
+	delete(m.stockoutUntil, zoneGPU{zone, gpuType})
+	m.mu.Unlock()
+}
+
+// zoneCooldownsLocked returns the zones cooling down after a stockout,
+// sorted by zone and GPU type. The caller must hold m.mu.
+func (m *Manager) zoneCooldownsLocked() []ZoneCooldown {
+	now := m.now()
+	var out []ZoneCooldown
+	for key, until := range m.stockoutUntil {
+		if !now.Before(until) {
+			delete(m.stockoutUntil, key)
+			continue
+		}
+		out = append(out, ZoneCooldown{Zone: key.zone, GPUType: key.gpuType, Until: until})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Zone != out[j].Zone {
+			return out[i].Zone < out[j].Zone
+		}
+		return out[i].GPUType < out[j].GPUType
+	})
+	return out
+}
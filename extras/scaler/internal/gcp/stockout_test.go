@@ -0,0 +1,87 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+func TestStockoutCooldownSkipsZone(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	zones := []zoneCandidate{
+		{zone: "us-east1-d", region: "us-east1", available: 8},
+		{zone: "us-east1-b", region: "us-east1", available: 8},
+	}
+	m := &Manager{
+		config:         ManagerConfig{Project: "test-project", InstanceTemplate: "t", GPUType: "nvidia-l4", StockoutCooldown: 10 * time.Minute},
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]zoneCandidate{},
+		nowFunc:        func() time.Time { return now },
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return append([]zoneCandidate(nil), zones...), nil
+	}
+	stockedOut := true
+	inserts := make(map[string]int)
+	m.insertVMFunc = func(_ context.Context, req *computepb.InsertInstanceRequest) error {
+		inserts[req.GetZone()]++
+		if req.GetZone() == "us-east1-d" && stockedOut {
+			return errors.New("ZONE_RESOURCE_POOL_EXHAUSTED")
+		}
+		return nil
+	}
+
+	for _, name := range []string{"runner-1", "runner-2", "runner-3"} {
+		if _, err := m.CreateVM(context.Background(), name, "jit"); err != nil {
+			t.Fatalf("CreateVM(%s): %v", name, err)
+		}
+	}
+	// Only the first create tries the stocked-out zone.
+	if inserts["us-east1-d"] != 1 || inserts["us-east1-b"] != 3 {
+		t.Fatalf("inserts = %v, want us-east1-d skipped after its stockout", inserts)
+	}
+	cooldowns := m.Capacity().ZoneCooldowns
+	if len(cooldowns) != 1 || cooldowns[0].Zone != "us-east1-d" || cooldowns[0].GPUType != "nvidia-l4" || !cooldowns[0].Until.Equal(now.Add(10*time.Minute)) {
+		t.Fatalf("ZoneCooldowns = %+v, want us-east1-d for 10 minutes", cooldowns)
+	}
+
+	// Alone and cooling down, the zone is still tried.
+	zones = zones[:1]
+	if _, err := m.CreateVM(context.Background(), "runner-4", "jit"); err == nil || inserts["us-east1-d"] != 2 {
+		t.Fatalf("CreateVM = %v with %d inserts in us-east1-d, want the only zone tried", err, inserts["us-east1-d"])
+	}
+
+	// After the cooldown the zone is tried again.
+	now = now.Add(10 * time.Minute)
+	if got := m.Capacity().ZoneCooldowns; len(got) != 0 {
+		t.Fatalf("ZoneCooldowns = %+v after the cooldown, want none", got)
+	}
+	stockedOut = false
+	if _, err := m.CreateVM(context.Background(), "runner-5", "jit"); err != nil {
+		t.Fatalf("CreateVM after the cooldown: %v", err)
+	}
+	if inserts["us-east1-d"] != 3 {
+		t.Fatalf("inserts = %v, want us-east1-d tried after its cooldown", inserts)
+	}
+}
+
+func TestStockoutCooldownIsPerGPUType(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := &Manager{config: ManagerConfig{StockoutCooldown: time.Hour}, nowFunc: func() time.Time { return now }}
+	m.recordStockout("us-east1-d", "nvidia-l4")
+	candidates := []zoneCandidate{{zone: "us-east1-d"}, {zone: "us-east1-b"}}
+
+	m.mu.Lock()
+	l4 := m.stockedOutZonesLocked(candidates, "nvidia-l4")
+	t4 := m.stockedOutZonesLocked(candidates, "nvidia-tesla-t4")
+	m.mu.Unlock()
+	if len(l4) != 1 || l4[0].zone != "us-east1-b" {
+		t.Fatalf("L4 candidates = %+v, want us-east1-d skipped", l4)
+	}
+	if len(t4) != 2 {
+		t.Fatalf("T4 candidates = %+v, want both zones", t4)
+	}
+}
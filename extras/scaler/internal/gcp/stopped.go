@@ -0,0 +1,264 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+)
+
+// stoppedVM is a VM kept stopped, or suspended, for a later create (see
+// StopByRunnerName).
+type stoppedVM struct {
+	zone      string
+	template  string
+	stoppedAt time.Time
+	// reservation is the reservation the VM was created in. Its affinity
+	// stays with the VM, so it is started in the same one.
+	reservation string
+	// image is the ImageFamily image the VM boots from.
+	image string
+	// size is the size the VM was created with (see SizeLabels).
+	size VMSize
+	// hourlyCost is the VM's estimated price per hour once started again.
+	hourlyCost float64
+	// inUse is set while the VM is being stopped or started, so neither
+	// another create nor the cleanup loop touches it.
+	inUse bool
+}
+
+// StopByRunnerName stops the runner's VM and keeps it for a later create
+// instead of deleting it: a stopped VM is not billed for compute, and
+// starting it skips most of a cold boot. When StoppedPoolSize VMs are
+// already stopped, or stopping fails, the VM is deleted as by
+// DeleteByRunnerName. With SuspendPool the VM is suspended instead.
+func (m *Manager) StopByRunnerName(ctx context.Context, runnerName string) error {
+	m.mu.Lock()
+	vm, ok := m.vms[runnerName]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("no VM found for runner %q", runnerName)
+	}
+	delete(m.vms, runnerName)
+	// A VM of an earlier image is not worth keeping.
+	full := len(m.stopped) >= m.config.StoppedPoolSize || vm.image != m.image.Name
+	if !full {
+		if m.stopped == nil {
+			m.stopped = make(map[string]*stoppedVM)
+		}
+		m.stopped[vm.vmName] = &stoppedVM{zone: vm.zone, template: vm.template, reservation: vm.reservation, image: vm.image, size: vm.size, hourlyCost: vm.hourlyCost, inUse: true}
+	}
+	m.mu.Unlock()
+	if full {
+		return m.deleteVMForCleanup(ctx, vm.vmName, vm.zone)
+	}
+
+	stop, event := m.stopVM, "vm_stopped"
+	if m.config.SuspendPool {
+		stop, event = m.suspendVM, "vm_suspended"
+	}
+	if err := stop(ctx, vm.vmName, vm.zone); err != nil {
+		slog.Warn("failed to stop VM, deleting it instead", "vm", vm.vmName, "zone", vm.zone, "suspend", m.config.SuspendPool, "error", err)
+		m.mu.Lock()
+		delete(m.stopped, vm.vmName)
+		m.mu.Unlock()
+		return m.deleteVMForCleanup(ctx, vm.vmName, vm.zone)
+	}
+	m.mu.Lock()
+	// DeleteAll may have emptied the pool while the VM was stopping.
+	if stopped, ok := m.stopped[vm.vmName]; ok {
+		stopped.inUse = false
+		stopped.stoppedAt = m.now()
+	}
+	m.mu.Unlock()
+	slog.Info("VM kept for a later job", "event", event, "vm", vm.vmName, "zone", vm.zone, "runner", runnerName)
+	return nil
+}
+
+// isStopped reports whether vmName belongs to the stopped pool.
+func (m *Manager) isStopped(vmName string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.stopped[vmName]
+	return ok
+}
+
+// startStoppedVM starts a stopped VM created from profile's template, in
+// its size, for runnerName, handing it jitConfig. It returns "" when no such
+// VM is stopped or starting one failed; a VM that fails to start is deleted.
+func (m *Manager) startStoppedVM(ctx context.Context, runnerName, jitConfig string, profile vmProfile, avoidZone string) string {
+	zones := splitZones(m.zones())
+	m.mu.Lock()
+	var vmName string
+	var vm *stoppedVM
+	for name, candidate := range m.stopped {
+		if candidate.inUse || candidate.template != profile.instanceTemplate || candidate.size != profile.size || candidate.image != m.image.Name || candidate.zone == avoidZone || !slices.Contains(zones, candidate.zone) {
+			continue
+		}
+		// Prefer the most recently stopped VM.
+		if vm == nil || candidate.stoppedAt.After(vm.stoppedAt) {
+			vmName, vm = name, candidate
+		}
+	}
+	if vm == nil {
+		m.mu.Unlock()
+		return ""
+	}
+	vm.inUse = true
+	// The start counts as a pending create, against the region's quota too.
+	candidate := zoneCandidate{zone: vm.zone, region: zoneRegion(vm.zone), gpuType: profile.gpuType, reservation: vm.reservation, vmName: vmName, startedAt: m.now()}
+	m.pendingCreates[runnerName] = candidate
+	m.mu.Unlock()
+	m.saveCreateIntent(runnerName)
+
+	var err error
+	event := "vm_started"
+	if m.config.SuspendPool {
+		// The resumed startup script is waiting for the jit-config
+		// metadata to change.
+		event = "vm_resumed"
+		err = m.resumeVM(ctx, vmName, vm.zone)
+		if err == nil {
+			err = m.setJITConfig(ctx, vmName, vm.zone, jitConfig)
+		}
+	} else {
+		// The startup script reads the jit-config metadata on boot.
+		err = m.setJITConfig(ctx, vmName, vm.zone, jitConfig)
+		if err == nil {
+			err = m.startVM(ctx, vmName, vm.zone)
+		}
+	}
+	m.mu.Lock()
+	delete(m.stopped, vmName)
+	m.mu.Unlock()
+	if err != nil {
+		m.releaseCreate(runnerName)
+		slog.Warn("failed to start stopped VM, deleting it", "vm", vmName, "zone", vm.zone, "error", err)
+		if err := m.deleteVMForCleanup(ctx, vmName, vm.zone); err != nil {
+			slog.Warn("failed to delete stopped VM", "vm", vmName, "zone", vm.zone, "error", err)
+		}
+		return ""
+	}
+	m.completeCreate([]string{runnerName}, vmName, profile, vm.image, candidate, vm.hourlyCost)
+	slog.Info("VM started from the stopped pool", "event", event, "vm", vmName, "zone", vm.zone, "runner", runnerName)
+	return vmName
+}
+
+// deleteStopped deletes every VM in the stopped pool. Used during shutdown.
+func (m *Manager) deleteStopped(ctx context.Context) {
+	m.mu.Lock()
+	stopped := m.stopped
+	m.stopped = nil
+	m.mu.Unlock()
+	for vmName, vm := range stopped {
+		if err := m.deleteVMForCleanup(ctx, vmName, vm.zone); err != nil {
+			slog.Error("failed to delete stopped VM during cleanup", "vm", vmName, "error", err)
+		}
+	}
+}
+
+func (m *Manager) stopVM(ctx context.Context, vmName, zone string) (err error) {
+	if m.stopVMFunc != nil {
+		return m.stopVMFunc(ctx, vmName, zone)
+	}
+	ctx, span := tracer.Start(ctx, "gcp.StopVM", trace.WithAttributes(
+		attribute.String("gcp.instance", vmName),
+		attribute.String("gcp.zone", zone),
+	))
+	defer func() { endSpan(span, err) }()
+
+	op, err := m.instancesClient.Stop(ctx, &computepb.StopInstanceRequest{
+		Project:  m.config.Project,
+		Zone:     zone,
+		Instance: vmName,
+		// GCE refuses to stop a VM with local SSDs unless told what to do
+		// with their data. It is only scratch space, which the startup
+		// script formats again on boot.
+		DiscardLocalSsd: proto.Bool(m.config.LocalSSDs > 0),
+	})
+	if err != nil {
+		return fmt.Errorf("stopping instance %s in %s: %w", vmName, zone, err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("waiting for instance stop %s in %s: %w", vmName, zone, err)
+	}
+	return nil
+}
+
+func (m *Manager) startVM(ctx context.Context, vmName, zone string) (err error) {
+	if m.startVMFunc != nil {
+		return m.startVMFunc(ctx, vmName, zone)
+	}
+	ctx, span := tracer.Start(ctx, "gcp.StartVM", trace.WithAttributes(
+		attribute.String("gcp.instance", vmName),
+		attribute.String("gcp.zone", zone),
+	))
+	defer func() { endSpan(span, err) }()
+
+	op, err := m.instancesClient.Start(ctx, &computepb.StartInstanceRequest{
+		Project:  m.config.Project,
+		Zone:     zone,
+		Instance: vmName,
+	})
+	if err != nil {
+		return fmt.Errorf("starting instance %s in %s: %w", vmName, zone, err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("waiting for instance start %s in %s: %w", vmName, zone, err)
+	}
+	return nil
+}
+
+func (m *Manager) suspendVM(ctx context.Context, vmName, zone string) (err error) {
+	if m.suspendVMFunc != nil {
+		return m.suspendVMFunc(ctx, vmName, zone)
+	}
+	ctx, span := tracer.Start(ctx, "gcp.SuspendVM", trace.WithAttributes(
+		attribute.String("gcp.instance", vmName),
+		attribute.String("gcp.zone", zone),
+	))
+	defer func() { endSpan(span, err) }()
+
+	op, err := m.instancesClient.Suspend(ctx, &computepb.SuspendInstanceRequest{
+		Project:  m.config.Project,
+		Zone:     zone,
+		Instance: vmName,
+	})
+	if err != nil {
+		return fmt.Errorf("suspending instance %s in %s: %w", vmName, zone, err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("waiting for instance suspend %s in %s: %w", vmName, zone, err)
+	}
+	return nil
+}
+
+func (m *Manager) resumeVM(ctx context.Context, vmName, zone string) (err error) {
+	if m.resumeVMFunc != nil {
+		return m.resumeVMFunc(ctx, vmName, zone)
+	}
+	ctx, span := tracer.Start(ctx, "gcp.ResumeVM", trace.WithAttributes(
+		attribute.String("gcp.instance", vmName),
+		attribute.String("gcp.zone", zone),
+	))
+	defer func() { endSpan(span, err) }()
+
+	op, err := m.instancesClient.Resume(ctx, &computepb.ResumeInstanceRequest{
+		Project:  m.config.Project,
+		Zone:     zone,
+		Instance: vmName,
+	})
+	if err != nil {
+		return fmt.Errorf("resuming instance %s in %s: %w", vmName, zone, err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("waiting for instance resume %s in %s: %w", vmName, zone, err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+func newStoppedPoolManager(poolSize int) (*Manager, *[]string) {
+	m := &Manager{
+		config: ManagerConfig{
+			Project:          "test-project",
+			Zones:            "us-east1-c,us-east1-d",
+			InstanceTemplate: "win-gpu",
+			GPUType:          "nvidia-tesla-t4",
+			StoppedPoolSize:  poolSize,
+		},
+		vms: map[string]*vmInfo{
+			"win-1": {vmName: "win-1", zone: "us-east1-c", template: "win-gpu"},
+			"win-2": {vmName: "win-2", zone: "us-east1-d", template: "win-gpu"},
+		},
+		pendingCreates: map[string]zoneCandidate{},
+	}
+	var calls []string
+	m.stopVMFunc = func(_ context.Context, vmName, _ string) error {
+		calls = append(calls, "stop "+vmName)
+		return nil
+	}
+	m.startVMFunc = func(_ context.Context, vmName, _ string) error {
+		calls = append(calls, "start "+vmName)
+		return nil
+	}
+	m.deleteVMFunc = func(_ context.Context, vmName, _ string) error {
+		calls = append(calls, "delete "+vmName)
+		return nil
+	}
+	m.setJITConfigFunc = func(_ context.Context, vmName, _, jitConfig string) error {
+		calls = append(calls, "jit "+vmName+" "+jitConfig)
+		return nil
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-c", region: "us-east1", available: 4}}, nil
+	}
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		calls = append(calls, "insert "+r.GetInstanceResource().GetName())
+		return nil
+	}
+	return m, &calls
+}
+
+func TestStopByRunnerNameKeepsVMsUpToPoolSize(t *testing.T) {
+	m, calls := newStoppedPoolManager(1)
+
+	if err := m.StopByRunnerName(context.Background(), "win-1"); err != nil {
+		t.Fatalf("StopByRunnerName: %v", err)
+	}
+	if err := m.StopByRunnerName(context.Background(), "win-2"); err != nil {
+		t.Fatalf("StopByRunnerName: %v", err)
+	}
+	want := []string{"stop win-1", "delete win-2"}
+	if len(*calls) != 2 || (*calls)[0] != want[0] || (*calls)[1] != want[1] {
+		t.Fatalf("calls = %v, want %v", *calls, want)
+	}
+	if m.ActiveCount() != 0 {
+		t.Fatalf("ActiveCount = %d, stopped VMs must not count", m.ActiveCount())
+	}
+	if !m.isStopped("win-1") || m.isStopped("win-2") {
+		t.Fatal("only win-1 should be in the stopped pool")
+	}
+}
+
+func TestStopByRunnerNameDeletesWhenStopFails(t *testing.T) {
+	m, calls := newStoppedPoolManager(2)
+	m.stopVMFunc = func(context.Context, string, string) error { return errors.New("boom") }
+
+	if err := m.StopByRunnerName(context.Background(), "win-1"); err != nil {
+		t.Fatalf("StopByRunnerName: %v", err)
+	}
+	if len(*calls) != 1 || (*calls)[0] != "delete win-1" || m.isStopped("win-1") {
+		t.Fatalf("calls = %v, want the VM deleted after a failed stop", *calls)
+	}
+}
+
+func TestCreateVMStartsStoppedVM(t *testing.T) {
+	m, calls := newStoppedPoolManager(2)
+	if err := m.StopByRunnerName(context.Background(), "win-1"); err != nil {
+		t.Fatalf("StopByRunnerName: %v", err)
+	}
+	*calls = nil
+
+	vmName, err := m.CreateVM(context.Background(), "win-3", "jit-3")
+	if err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if vmName != "win-1" {
+		t.Fatalf("vmName = %q, want the stopped win-1", vmName)
+	}
+	want := []string{"jit win-1 jit-3", "start win-1"}
+	if len(*calls) != 2 || (*calls)[0] != want[0] || (*calls)[1] != want[1] {
+		t.Fatalf("calls = %v, want %v", *calls, want)
+	}
+	if vm := m.vms["win-3"]; vm == nil || vm.vmName != "win-1" || vm.createdAt.IsZero() {
+		t.Fatalf("tracked = %+v, want runner win-3 on VM win-1", vm)
+	}
+	if m.isStopped("win-1") || len(m.pendingCreates) != 0 {
+		t.Fatal("started VM is still in the stopped pool or pending")
+	}
+
+	// With the pool empty, the next create inserts a new VM.
+	*calls = nil
+	if _, err := m.CreateVM(context.Background(), "win-4", "jit-4"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if len(*calls) != 1 || (*calls)[0] != "insert win-4" {
+		t.Fatalf("calls = %v, want an insert", *calls)
+	}
+}
+
+func TestCreateVMFallsBackWhenStoppedVMFailsToStart(t *testing.T) {
+	m, calls := newStoppedPoolManager(2)
+	if err := m.StopByRunnerName(context.Background(), "win-1"); err != nil {
+		t.Fatalf("StopByRunnerName: %v", err)
+	}
+	m.startVMFunc = func(context.Context, string, string) error {
+		return errors.New("ZONE_RESOURCE_POOL_EXHAUSTED")
+	}
+	*calls = nil
+
+	vmName, err := m.CreateVM(context.Background(), "win-3", "jit-3")
+	if err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if vmName != "win-3" {
+		t.Fatalf("vmName = %q, want a new VM", vmName)
+	}
+	want := []string{"jit win-1 jit-3", "delete win-1", "insert win-3"}
+	if len(*calls) != 3 || (*calls)[1] != want[1] || (*calls)[2] != want[2] {
+		t.Fatalf("calls = %v, want %v", *calls, want)
+	}
+}
+
+func TestCleanupSkipsStoppedPool(t *testing.T) {
+	m, calls := newStoppedPoolManager(2)
+	if err := m.StopByRunnerName(context.Background(), "win-1"); err != nil {
+		t.Fatalf("StopByRunnerName: %v", err)
+	}
+	*calls = nil
+	m.config.OrphanGracePeriod = -1
+	m.listTerminated = func(context.Context, string) ([]string, error) {
+		return []string{"win-1", "win-old"}, nil
+	}
+
+	m.doCleanupTerminatedVMs(context.Background())
+
+	if len(*calls) != 2 || (*calls)[0] != "delete win-old" || (*calls)[1] != "delete win-old" {
+		t.Fatalf("calls = %v, want only win-old deleted (once per zone)", *calls)
+	}
+	if !m.isStopped("win-1") {
+		t.Fatal("stopped VM was dropped from the pool")
+	}
+
+	delete(m.vms, "win-2") // DeleteAll deletes tracked VMs without the hook
+	m.DeleteAll(context.Background())
+	if m.isStopped("win-1") || (*calls)[len(*calls)-1] != "delete win-1" {
+		t.Fatalf("calls = %v, DeleteAll should delete the stopped pool", *calls)
+	}
+}
+
+func TestSuspendPoolSuspendsAndResumes(t *testing.T) {
+	m, calls := newStoppedPoolManager(2)
+	m.config.SuspendPool = true
+	m.suspendVMFunc = func(_ context.Context, vmName, _ string) error {
+		*calls = append(*calls, "suspend "+vmName)
+		return nil
+	}
+	m.resumeVMFunc = func(_ context.Context, vmName, _ string) error {
+		*calls = append(*calls, "resume "+vmName)
+		return nil
+	}
+
+	if err := m.StopByRunnerName(context.Background(), "win-1"); err != nil {
+		t.Fatalf("StopByRunnerName: %v", err)
+	}
+	vmName, err := m.CreateVM(context.Background(), "win-3", "jit-3")
+	if err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if vmName != "win-1" {
+		t.Fatalf("vmName = %q, want the suspended win-1", vmName)
+	}
+	// The resumed VM is waiting for the metadata to change, so the JIT
+	// config is written after the resume.
+	want := []string{"suspend win-1", "resume win-1", "jit win-1 jit-3"}
+	if len(*calls) != 3 || (*calls)[0] != want[0] || (*calls)[1] != want[1] || (*calls)[2] != want[2] {
+		t.Fatalf("calls = %v, want %v", *calls, want)
+	}
+}
+
+func TestCreateVMMarksReuseForSuspendPool(t *testing.T) {
+	m, _ := newStoppedPoolManager(2)
+	m.config.SuspendPool = true
+	var req *computepb.InsertInstanceRequest
+	m.insertVMFunc = func(_ context.Context, r *computepb.InsertInstanceRequest) error {
+		req = r
+		return nil
+	}
+	if _, err := m.CreateVM(context.Background(), "win-3", "jit-3"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	for _, item := range req.GetInstanceResource().GetMetadata().GetItems() {
+		if item.GetKey() == "runner-reuse" && item.GetValue() == "true" {
+			return
+		}
+	}
+	t.Fatal("a suspended pool's VMs must wait for a new JIT config after their job")
+}
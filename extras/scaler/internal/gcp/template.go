@@ -0,0 +1,72 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// templateProperties returns the instance properties of an instance
+// template. Inserts that override a repeated field (disks, network
+// interfaces) replace the template's value wholesale, so overrides start
+// from these. Templates are immutable, so the result is cached per name;
+// callers must clone before modifying.
+func (m *Manager) templateProperties(ctx context.Context, template string) (*computepb.InstanceProperties, error) {
+	m.mu.Lock()
+	props, ok := m.templateCache[template]
+	m.mu.Unlock()
+	if ok {
+		return props, nil
+	}
+
+	if m.templatePropertiesFunc != nil {
+		var err error
+		props, err = m.templatePropertiesFunc(ctx, template)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		tmpl, err := m.templatesClient.Get(ctx, &computepb.GetInstanceTemplateRequest{
+			Project:          m.config.Project,
+			InstanceTemplate: template,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading instance template %s: %w", template, err)
+		}
+		props = tmpl.GetProperties()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.templateCache == nil {
+		m.templateCache = make(map[string]*computepb.InstanceProperties)
+	}
+	m.templateCache[template] = props
+	return props, nil
+}
+
+// MachineType returns the machine type of the runner's VM: the one its
+// size picked, or else the one of the instance template it was created
+// from. It returns "" for unknown runners and VMs adopted from a previous
+// run.
+func (m *Manager) MachineType(ctx context.Context, runnerName string) (string, error) {
+	m.mu.Lock()
+	vm, ok := m.vms[runnerName]
+	var profile vmProfile
+	if ok {
+		profile = vmProfile{instanceTemplate: vm.template, size: vm.size}
+	}
+	m.mu.Unlock()
+	if profile.instanceTemplate == "" {
+		return "", nil
+	}
+	if machineType, err := m.sizedMachineType(ctx, profile); err != nil || machineType != "" {
+		return machineType, err
+	}
+	props, err := m.templateProperties(ctx, profile.instanceTemplate)
+	if err != nil {
+		return "", err
+	}
+	return props.GetMachineType(), nil
+}
@@ -0,0 +1,40 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMachineTypeFromCreateTemplate(t *testing.T) {
+	m := &Manager{
+		config:         ManagerConfig{Project: "test-project", InstanceTemplate: "linux-gpu-runner", GPUType: "none"},
+		vms:            map[string]*vmInfo{"adopted": {vmName: "linux-adopted", zone: "us-east1-c"}},
+		pendingCreates: map[string]zoneCandidate{},
+		templatePropertiesFunc: func(_ context.Context, name string) (*computepb.InstanceProperties, error) {
+			if name != "linux-gpu-runner" {
+				t.Fatalf("template = %q, want linux-gpu-runner", name)
+			}
+			return &computepb.InstanceProperties{MachineType: proto.String("g2-standard-8")}, nil
+		},
+	}
+	m.selectZonesFunc = func(context.Context) ([]zoneCandidate, error) {
+		return []zoneCandidate{{zone: "us-east1-d", region: "us-east1"}}, nil
+	}
+	m.insertVMFunc = func(context.Context, *computepb.InsertInstanceRequest) error { return nil }
+	if _, err := m.CreateVM(context.Background(), "runner-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+
+	ctx := context.Background()
+	if got, err := m.MachineType(ctx, "runner-1"); err != nil || got != "g2-standard-8" {
+		t.Fatalf("MachineType(runner-1) = %q, %v; want g2-standard-8", got, err)
+	}
+	for _, name := range []string{"adopted", "unknown"} {
+		if got, err := m.MachineType(ctx, name); err != nil || got != "" {
+			t.Fatalf("MachineType(%s) = %q, %v; want empty", name, got, err)
+		}
+	}
+}
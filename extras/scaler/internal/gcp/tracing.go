@@ -0,0 +1,20 @@
+package gcp
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer reports VM lifecycle spans to the process's tracer provider, which
+// is a no-op unless the scaler was started with tracing enabled.
+var tracer = otel.Tracer("extras/scaler/internal/gcp")
+
+// endSpan records err on span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
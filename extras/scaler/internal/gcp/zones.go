@@ -0,0 +1,140 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+)
+
+// discoverZones sets Zones to every zone in Regions that offers the GPU
+// types of the pool and of its TemplateRoutes (every zone, for CPU-only
+// pools). Routed templates share the zone list, so a zone lacking any of
+// them is dropped. Regions keep their configured order so the first region
+// stays preferred; zones within a region are sorted.
+func (m *Manager) discoverZones(ctx context.Context) error {
+	gpuTypes := m.gpuTypes()
+	var offered map[string]bool
+	for i, gpuType := range gpuTypes {
+		zones, err := m.acceleratorZones(ctx, gpuType)
+		if err != nil {
+			return fmt.Errorf("listing zones offering %s: %w", gpuType, err)
+		}
+		next := make(map[string]bool, len(zones))
+		for _, z := range zones {
+			if i == 0 || offered[z] {
+				next[z] = true
+			}
+		}
+		offered = next
+	}
+
+	var zones []string
+	for _, region := range m.config.Regions {
+		regionZones, err := m.regionZones(ctx, region)
+		if err != nil {
+			return fmt.Errorf("listing zones in %s: %w", region, err)
+		}
+		sort.Strings(regionZones)
+		for _, z := range regionZones {
+			if offered == nil || offered[z] {
+				zones = append(zones, z)
+			}
+		}
+	}
+	if len(zones) == 0 {
+		return fmt.Errorf("no zone in %s offers %s", strings.Join(m.config.Regions, ", "), strings.Join(gpuTypes, " and "))
+	}
+
+	m.config.Zones = strings.Join(zones, ",")
+	slog.Info("discovered zones", "regions", m.config.Regions, "gpu_types", gpuTypes, "zones", m.config.Zones)
+	return nil
+}
+
+// gpuTypes returns the GPU types of the pool and its TemplateRoutes, each
+// once, without "none".
+func (m *Manager) gpuTypes() []string {
+	var types []string
+	add := func(gpuType string) {
+		if gpuType != "" && gpuType != "none" && !slices.Contains(types, gpuType) {
+			types = append(types, gpuType)
+		}
+	}
+	add(m.config.GPUType)
+	for _, route := range m.config.TemplateRoutes {
+		add(route.GPUType)
+	}
+	return types
+}
+
+func (m *Manager) regionZones(ctx context.Context, region string) ([]string, error) {
+	if m.regionZonesFunc != nil {
+		return m.regionZonesFunc(ctx, region)
+	}
+	var info *computepb.Region
+	err := m.retry(ctx, "Regions.Get", func(ctx context.Context) error {
+		var err error
+		info, err = m.regionsClient.Get(ctx, &computepb.GetRegionRequest{
+			Project: m.config.Project,
+			Region:  region,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	zones := make([]string, 0, len(info.GetZones()))
+	for _, u := range info.GetZones() {
+		zones = append(zones, path.Base(u))
+	}
+	return zones, nil
+}
+
+// acceleratorZones returns every zone in the project that offers gpuType.
+func (m *Manager) acceleratorZones(ctx context.Context, gpuType string) ([]string, error) {
+	if m.acceleratorZonesFunc != nil {
+		return m.acceleratorZonesFunc(ctx, gpuType)
+	}
+	opts, err := clientOptions(ctx, m.config.ImpersonateServiceAccount)
+	if err != nil {
+		return nil, err
+	}
+	client, err := compute.NewAcceleratorTypesRESTClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating accelerator types client: %w", err)
+	}
+	defer client.Close()
+
+	var zones []string
+	err = m.retry(ctx, "List", func(ctx context.Context) error {
+		zones = nil
+		it := client.AggregatedList(ctx, &computepb.AggregatedListAcceleratorTypesRequest{
+			Project: m.config.Project,
+			Filter:  proto.String(fmt.Sprintf("name = %s", gpuType)),
+		})
+		for {
+			pair, err := it.Next()
+			if err == iterator.Done {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if len(pair.Value.GetAcceleratorTypes()) > 0 {
+				zones = append(zones, strings.TrimPrefix(pair.Key, "zones/"))
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
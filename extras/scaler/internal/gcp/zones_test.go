@@ -0,0 +1,72 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+)
+
+func newZoneDiscoveryManager(gpuType string, regions ...string) *Manager {
+	return &Manager{
+		config: ManagerConfig{Project: "test-project", GPUType: gpuType, Regions: regions},
+		regionZonesFunc: func(_ context.Context, region string) ([]string, error) {
+			return map[string][]string{
+				"us-east1": {"us-east1-d", "us-east1-b", "us-east1-c"},
+				"us-west1": {"us-west1-c", "us-west1-a", "us-west1-b"},
+			}[region], nil
+		},
+		acceleratorZonesFunc: func(_ context.Context, gpuType string) ([]string, error) {
+			switch gpuType {
+			case "nvidia-tesla-t4":
+				return []string{"europe-west4-a", "us-east1-c", "us-east1-d", "us-west1-b"}, nil
+			case "nvidia-l4":
+				return []string{"us-east1-b", "us-east1-c", "us-west1-b"}, nil
+			}
+			return nil, nil
+		},
+	}
+}
+
+func TestDiscoverZonesFiltersByAccelerator(t *testing.T) {
+	m := newZoneDiscoveryManager("nvidia-tesla-t4", "us-west1", "us-east1")
+	if err := m.discoverZones(context.Background()); err != nil {
+		t.Fatalf("discoverZones: %v", err)
+	}
+	if want := "us-west1-b,us-east1-c,us-east1-d"; m.zones() != want {
+		t.Fatalf("zones = %q, want %q (region order kept, unsupported zones dropped)", m.zones(), want)
+	}
+}
+
+func TestDiscoverZonesKeepsZonesOfferingEveryRoutedGPU(t *testing.T) {
+	m := newZoneDiscoveryManager("nvidia-tesla-t4", "us-west1", "us-east1")
+	m.config.TemplateRoutes = []TemplateRoute{
+		{Label: "GCP-L4", InstanceTemplate: "l4-runner", GPUType: "nvidia-l4"},
+		{Label: "GCP-T4-Large", InstanceTemplate: "t4-large-runner"},
+	}
+	if err := m.discoverZones(context.Background()); err != nil {
+		t.Fatalf("discoverZones: %v", err)
+	}
+	if want := "us-west1-b,us-east1-c"; m.zones() != want {
+		t.Fatalf("zones = %q, want %q (only zones offering both T4 and L4)", m.zones(), want)
+	}
+}
+
+func TestDiscoverZonesCPUOnlyUsesEveryZone(t *testing.T) {
+	m := newZoneDiscoveryManager("none", "us-east1")
+	m.acceleratorZonesFunc = func(context.Context, string) ([]string, error) {
+		t.Fatal("CPU-only pools should not query accelerator types")
+		return nil, nil
+	}
+	if err := m.discoverZones(context.Background()); err != nil {
+		t.Fatalf("discoverZones: %v", err)
+	}
+	if want := "us-east1-b,us-east1-c,us-east1-d"; m.zones() != want {
+		t.Fatalf("zones = %q, want %q", m.zones(), want)
+	}
+}
+
+func TestDiscoverZonesFailsWhenNothingMatches(t *testing.T) {
+	m := newZoneDiscoveryManager("nvidia-tesla-a100", "us-east1")
+	if err := m.discoverZones(context.Background()); err == nil {
+		t.Fatal("discoverZones should fail when no zone offers the GPU type")
+	}
+}
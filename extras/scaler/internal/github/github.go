@@ -0,0 +1,319 @@
+// Package github is a minimal GitHub REST client for the calls the scale set
+// client does not cover. It authenticates as a GitHub App installation or
+// with a personal access token.
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// tokenRefreshMargin is how long before expiry an installation token is
+// replaced.
+const tokenRefreshMargin = 5 * time.Minute
+
+// AppAuth identifies a GitHub App installation.
+type AppAuth struct {
+	ClientID       string
+	InstallationID int64
+	PrivateKey     string // PEM
+}
+
+// Client calls the GitHub REST API as an App installation, or with a PAT.
+type Client struct {
+	apiURL string
+	// runnersPath is the runner list of the repository, organization or
+	// enterprise the config URL names.
+	runnersPath string
+	app         AppAuth
+	pat         string
+	http        *http.Client
+	now         func() time.Time
+
+	mu           sync.Mutex
+	token        string
+	tokenExpires time.Time
+}
+
+// New returns a Client for the GitHub instance hosting configURL (the
+// scaler's --url): api.github.com for github.com, /api/v3 on GitHub
+// Enterprise Server.
+func New(configURL string, app AppAuth) (*Client, error) {
+	if _, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(app.PrivateKey)); err != nil {
+		return nil, fmt.Errorf("parsing GitHub App private key: %w", err)
+	}
+	c, err := newClient(configURL)
+	if err != nil {
+		return nil, err
+	}
+	c.app = app
+	return c, nil
+}
+
+// NewWithToken is New for a personal access token.
+func NewWithToken(configURL, token string) (*Client, error) {
+	if token == "" {
+		return nil, fmt.Errorf("empty GitHub token")
+	}
+	c, err := newClient(configURL)
+	if err != nil {
+		return nil, err
+	}
+	c.pat = token
+	return c, nil
+}
+
+func newClient(configURL string) (*Client, error) {
+	u, err := url.Parse(configURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid GitHub URL %q", configURL)
+	}
+	apiURL := u.Scheme + "://" + u.Host + "/api/v3"
+	if u.Host == "github.com" || u.Host == "www.github.com" {
+		apiURL = "https://api.github.com"
+	}
+	var runnersPath string
+	switch parts := strings.Split(strings.Trim(u.Path, "/"), "/"); {
+	case len(parts) == 2 && parts[0] == "enterprises":
+		runnersPath = "/enterprises/" + parts[1] + "/actions/runners"
+	case len(parts) == 2:
+		runnersPath = "/repos/" + parts[0] + "/" + parts[1] + "/actions/runners"
+	case len(parts) == 1 && parts[0] != "":
+		runnersPath = "/orgs/" + parts[0] + "/actions/runners"
+	}
+	return &Client{apiURL: apiURL, runnersPath: runnersPath, http: &http.Client{Timeout: 30 * time.Second}, now: time.Now}, nil
+}
+
+// installationToken returns a cached installation token, minting a new one
+// shortly before the current one expires.
+func (c *Client) installationToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && c.now().Before(c.tokenExpires.Add(-tokenRefreshMargin)) {
+		return c.token, nil
+	}
+
+	// Backdating iat absorbs clock skew; GitHub caps exp at 10 minutes.
+	issuedAt := c.now().Add(-time.Minute)
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(c.app.PrivateKey))
+	if err != nil {
+		return "", fmt.Errorf("parsing GitHub App private key: %w", err)
+	}
+	appJWT, err := jwt.NewWithClaims(jwt.SigningMethodRS256, &jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(issuedAt),
+		ExpiresAt: jwt.NewNumericDate(issuedAt.Add(9 * time.Minute)),
+		Issuer:    c.app.ClientID,
+	}).SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("signing GitHub App JWT: %w", err)
+	}
+
+	var resp struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	path := fmt.Sprintf("/app/installations/%d/access_tokens", c.app.InstallationID)
+	if err := c.do(ctx, http.MethodPost, path, appJWT, nil, &resp); err != nil {
+		return "", fmt.Errorf("getting installation token: %w", err)
+	}
+	c.token, c.tokenExpires = resp.Token, resp.ExpiresAt
+	return c.token, nil
+}
+
+// call makes an authenticated API request, encoding in as the JSON body
+// (if non-nil) and decoding the response into out (if non-nil).
+func (c *Client) call(ctx context.Context, method, path string, in, out any) error {
+	if c.pat != "" {
+		return c.do(ctx, method, path, c.pat, in, out)
+	}
+	token, err := c.installationToken(ctx)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, method, path, token, in, out)
+}
+
+func (c *Client) do(ctx context.Context, method, path, token string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// WorkflowRunHeadSHA returns the commit a workflow run is running on.
+func (c *Client) WorkflowRunHeadSHA(ctx context.Context, owner, repo string, runID int64) (string, error) {
+	var run struct {
+		HeadSHA string `json:"head_sha"`
+	}
+	if err := c.call(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/actions/runs/%d", owner, repo, runID), nil, &run); err != nil {
+		return "", err
+	}
+	return run.HeadSHA, nil
+}
+
+// CheckRun is a completed check run with a Markdown summary.
+type CheckRun struct {
+	Name       string
+	HeadSHA    string
+	Conclusion string // e.g. "neutral"
+	Title      string
+	Summary    string
+}
+
+// CreateCheckRun creates run on the repository. The App needs the
+// checks:write permission.
+func (c *Client) CreateCheckRun(ctx context.Context, owner, repo string, run CheckRun) error {
+	body := map[string]any{
+		"name":       run.Name,
+		"head_sha":   run.HeadSHA,
+		"status":     "completed",
+		"conclusion": run.Conclusion,
+		"output":     map[string]string{"title": run.Title, "summary": run.Summary},
+	}
+	return c.call(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/check-runs", owner, repo), body, nil)
+}
+
+// CreateIssue opens an issue on the repository. The App needs the
+// issues:write permission; a PAT, the repo scope.
+func (c *Client) CreateIssue(ctx context.Context, owner, repo, title, body string) error {
+	return c.call(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/issues", owner, repo), map[string]string{"title": title, "body": body}, nil)
+}
+
+// RunnerStatus returns the status ("online" or "offline") of the
+// self-hosted runner with the given name, or "" if no such runner is
+// registered. The App needs read access to self-hosted runners; a PAT, the
+// repo or admin:org scope.
+func (c *Client) RunnerStatus(ctx context.Context, name string) (string, error) {
+	if c.runnersPath == "" {
+		return "", fmt.Errorf("cannot list runners: the GitHub URL names no repository, organization or enterprise")
+	}
+	var list struct {
+		Runners []struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+		} `json:"runners"`
+	}
+	if err := c.call(ctx, http.MethodGet, c.runnersPath+"?name="+url.QueryEscape(name), nil, &list); err != nil {
+		return "", err
+	}
+	for _, r := range list.Runners {
+		if r.Name == name {
+			return r.Status, nil
+		}
+	}
+	return "", nil
+}
+
+// Runner is a self-hosted runner in the list Runners returns.
+type Runner struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // "online" or "offline"
+	Busy   bool   `json:"busy"`   // running a job
+}
+
+// Runners returns every self-hosted runner registered to the repository,
+// organization or enterprise, with the same access as RunnerStatus.
+func (c *Client) Runners(ctx context.Context) ([]Runner, error) {
+	if c.runnersPath == "" {
+		return nil, fmt.Errorf("cannot list runners: the GitHub URL names no repository, organization or enterprise")
+	}
+	const perPage = 100
+	var runners []Runner
+	for page := 1; ; page++ {
+		var list struct {
+			TotalCount int      `json:"total_count"`
+			Runners    []Runner `json:"runners"`
+		}
+		if err := c.call(ctx, http.MethodGet, fmt.Sprintf("%s?per_page=%d&page=%d", c.runnersPath, perPage, page), nil, &list); err != nil {
+			return nil, err
+		}
+		runners = append(runners, list.Runners...)
+		if len(list.Runners) < perPage || len(runners) >= list.TotalCount {
+			return runners, nil
+		}
+	}
+}
+
+// egressMetaKeys are the sections of GitHub's meta API listing the
+// addresses runners connect to: the web UI and git, the REST API, GitHub
+// Packages, and Actions' services (job messages, logs, caches, artifacts).
+var egressMetaKeys = []string{"web", "api", "git", "packages", "actions"}
+
+// EgressRanges returns the IPv4 CIDRs runners need to reach, from the
+// meta API. GitHub Enterprise Server lists none there, so for it they are
+// the addresses its host resolves to.
+func (c *Client) EgressRanges(ctx context.Context) ([]string, error) {
+	var meta map[string]json.RawMessage
+	if err := c.call(ctx, http.MethodGet, "/meta", nil, &meta); err != nil {
+		return nil, fmt.Errorf("reading GitHub's meta API: %w", err)
+	}
+	seen := make(map[string]bool)
+	var ranges []string
+	for _, key := range egressMetaKeys {
+		var cidrs []string
+		if raw, ok := meta[key]; !ok || json.Unmarshal(raw, &cidrs) != nil {
+			continue
+		}
+		for _, cidr := range cidrs {
+			// A firewall rule can't mix IPv4 and IPv6 ranges.
+			if !strings.Contains(cidr, ":") && !seen[cidr] {
+				seen[cidr] = true
+				ranges = append(ranges, cidr)
+			}
+		}
+	}
+	if len(ranges) > 0 {
+		return ranges, nil
+	}
+
+	u, err := url.Parse(c.apiURL)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := net.DefaultResolver.LookupIP(ctx, "ip4", u.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		ranges = append(ranges, addr.String()+"/32")
+	}
+	return ranges, nil
+}
@@ -0,0 +1,232 @@
+package github
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func testKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+// fakeGitHub serves the installation token, meta, workflow run, check run,
+// issue and runner list endpoints, recording each request and its Authorization header.
+type fakeGitHub struct {
+	t   *testing.T
+	key *rsa.PrivateKey
+
+	mu        sync.Mutex
+	requests  []string
+	checkRuns []map[string]any
+	issues    []map[string]string
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	switch {
+	case r.URL.Path == "/api/v3/app/installations/42/access_tokens":
+		claims := &jwt.RegisteredClaims{}
+		if _, err := jwt.ParseWithClaims(auth, claims, func(*jwt.Token) (any, error) { return &f.key.PublicKey, nil }); err != nil || claims.Issuer != "Iv1.app" {
+			f.t.Errorf("bad App JWT (iss %q): %v", claims.Issuer, err)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"token": "ghs_install", "expires_at": time.Now().Add(time.Hour)})
+		return
+	case auth != "ghs_install" && auth != "ghp_pat":
+		http.Error(w, "bad credentials", http.StatusUnauthorized)
+	case r.URL.Path == "/api/v3/meta":
+		json.NewEncoder(w).Encode(map[string]any{
+			"verifiable_password_authentication": true,
+			"web":                                []string{"192.30.252.0/22", "2a0a:a440::/29"},
+			"api":                                []string{"192.30.252.0/22", "140.82.112.0/20"},
+			"actions":                            []string{"4.148.0.0/16"},
+			"hooks":                              []string{"192.30.252.0/22", "185.199.108.0/22"},
+		})
+	case r.URL.Path == "/api/v3/repos/shader-slang/slang/actions/runs/4242":
+		json.NewEncoder(w).Encode(map[string]string{"head_sha": "abc123"})
+	case r.URL.Path == "/api/v3/repos/shader-slang/slang/check-runs" && r.Method == http.MethodPost:
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		f.checkRuns = append(f.checkRuns, body)
+		w.WriteHeader(http.StatusCreated)
+	case r.URL.Path == "/api/v3/repos/shader-slang/infra/issues" && r.Method == http.MethodPost:
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		f.issues = append(f.issues, body)
+		w.WriteHeader(http.StatusCreated)
+	case r.URL.Path == "/api/v3/repos/shader-slang/slang/actions/runners":
+		runners := []map[string]any{}
+		for _, runner := range []struct {
+			name, status string
+			busy         bool
+		}{{"win-1", "online", true}, {"win-2", "offline", false}} {
+			if name := r.URL.Query().Get("name"); name == "" || name == runner.name {
+				runners = append(runners, map[string]any{"id": 1, "name": runner.name, "status": runner.status, "busy": runner.busy})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"total_count": len(runners), "runners": runners})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestClient(t *testing.T) (*Client, *fakeGitHub) {
+	t.Helper()
+	key, pemKey := testKey(t)
+	f := &fakeGitHub{t: t, key: key}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	c, err := New(srv.URL+"/shader-slang/slang", AppAuth{ClientID: "Iv1.app", InstallationID: 42, PrivateKey: pemKey})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c, f
+}
+
+func TestNewDerivesAPIURL(t *testing.T) {
+	_, pemKey := testKey(t)
+	for configURL, want := range map[string]string{
+		"https://github.com/shader-slang/slang": "https://api.github.com",
+		"https://ghe.example.com/org":           "https://ghe.example.com/api/v3",
+	} {
+		c, err := New(configURL, AppAuth{PrivateKey: pemKey})
+		if err != nil {
+			t.Fatalf("New(%s): %v", configURL, err)
+		}
+		if c.apiURL != want {
+			t.Errorf("New(%s).apiURL = %s, want %s", configURL, c.apiURL, want)
+		}
+	}
+	for configURL, want := range map[string]string{
+		"https://github.com/shader-slang/slang":  "/repos/shader-slang/slang/actions/runners",
+		"https://github.com/shader-slang":        "/orgs/shader-slang/actions/runners",
+		"https://github.com/enterprises/nvidia/": "/enterprises/nvidia/actions/runners",
+		"https://ghe.example.com/org/repo/extra": "",
+	} {
+		c, err := NewWithToken(configURL, "ghp_pat")
+		if err != nil {
+			t.Fatalf("NewWithToken(%s): %v", configURL, err)
+		}
+		if c.runnersPath != want {
+			t.Errorf("NewWithToken(%s).runnersPath = %q, want %q", configURL, c.runnersPath, want)
+		}
+	}
+	if _, err := New("https://github.com/org", AppAuth{PrivateKey: "not a key"}); err == nil {
+		t.Fatal("New should reject an unparsable private key")
+	}
+}
+
+func TestCreateCheckRunForWorkflowRun(t *testing.T) {
+	c, f := newTestClient(t)
+	ctx := context.Background()
+
+	sha, err := c.WorkflowRunHeadSHA(ctx, "shader-slang", "slang", 4242)
+	if err != nil || sha != "abc123" {
+		t.Fatalf("WorkflowRunHeadSHA = %q, %v", sha, err)
+	}
+	err = c.CreateCheckRun(ctx, "shader-slang", "slang", CheckRun{
+		Name: "runner: build", HeadSHA: sha, Conclusion: "neutral", Title: "win-1 in us-east1-c", Summary: "details",
+	})
+	if err != nil {
+		t.Fatalf("CreateCheckRun: %v", err)
+	}
+
+	if n := strings.Count(strings.Join(f.requests, "\n"), "access_tokens"); n != 1 {
+		t.Fatalf("installation token minted %d times, want 1 (cached)", n)
+	}
+	run := f.checkRuns[0]
+	output, _ := run["output"].(map[string]any)
+	if run["head_sha"] != "abc123" || run["status"] != "completed" || run["conclusion"] != "neutral" || output["title"] != "win-1 in us-east1-c" {
+		t.Fatalf("check run = %v", run)
+	}
+
+	if _, err := c.WorkflowRunHeadSHA(ctx, "shader-slang", "slang", 1); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("missing run: err = %v, want 404", err)
+	}
+}
+
+func TestCreateIssue(t *testing.T) {
+	c, f := newTestClient(t)
+	if err := c.CreateIssue(context.Background(), "shader-slang", "infra", "Runner cost", "| Pool |"); err != nil {
+		t.Fatalf("CreateIssue: %v", err)
+	}
+	if want := []map[string]string{{"title": "Runner cost", "body": "| Pool |"}}; !reflect.DeepEqual(f.issues, want) {
+		t.Fatalf("issues = %v, want %v", f.issues, want)
+	}
+}
+
+func TestRunnerStatus(t *testing.T) {
+	c, f := newTestClient(t)
+	ctx := context.Background()
+	for name, want := range map[string]string{"win-1": "online", "win-2": "offline", "win-3": ""} {
+		got, err := c.RunnerStatus(ctx, name)
+		if err != nil {
+			t.Fatalf("RunnerStatus(%s): %v", name, err)
+		}
+		if got != want {
+			t.Errorf("RunnerStatus(%s) = %q, want %q", name, got, want)
+		}
+	}
+
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	pat, err := NewWithToken(srv.URL+"/shader-slang/slang", "ghp_pat")
+	if err != nil {
+		t.Fatalf("NewWithToken: %v", err)
+	}
+	if got, err := pat.RunnerStatus(ctx, "win-1"); err != nil || got != "online" {
+		t.Fatalf("RunnerStatus with a PAT = %q, %v", got, err)
+	}
+	if n := strings.Count(strings.Join(f.requests, "\n"), "access_tokens"); n != 1 {
+		t.Fatalf("installation token minted %d times, want 1 (never for a PAT)", n)
+	}
+
+	org, _ := NewWithToken(srv.URL, "ghp_pat")
+	if _, err := org.RunnerStatus(ctx, "win-1"); err == nil {
+		t.Fatal("RunnerStatus should fail when the URL names no runner scope")
+	}
+}
+
+func TestRunners(t *testing.T) {
+	c, _ := newTestClient(t)
+	got, err := c.Runners(context.Background())
+	if err != nil {
+		t.Fatalf("Runners: %v", err)
+	}
+	want := []Runner{{Name: "win-1", Status: "online", Busy: true}, {Name: "win-2", Status: "offline"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Runners = %+v, want %+v", got, want)
+	}
+}
+
+func TestEgressRanges(t *testing.T) {
+	c, _ := newTestClient(t)
+	ranges, err := c.EgressRanges(context.Background())
+	if err != nil {
+		t.Fatalf("EgressRanges: %v", err)
+	}
+	want := []string{"192.30.252.0/22", "140.82.112.0/20", "4.148.0.0/16"}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("EgressRanges = %v, want %v", ranges, want)
+	}
+}
@@ -0,0 +1,81 @@
+// Package histogram aggregates observations into fixed buckets for export as
+// Cloud Monitoring distributions and for the status dashboard.
+package histogram
+
+import (
+	"math"
+	"slices"
+	"sync"
+)
+
+// Histogram counts observations per bucket. Bucket i holds values up to
+// Bounds[i]; the last bucket holds everything above the last bound. It is
+// safe for concurrent use.
+type Histogram struct {
+	mu   sync.Mutex
+	snap Snapshot
+}
+
+// Snapshot is a point-in-time copy of a Histogram.
+type Snapshot struct {
+	Bounds []float64
+	Counts []int64 // len(Bounds)+1
+	Count  int64
+	Mean   float64
+	// SumOfSquaredDeviation is Σ(x - Mean)², as Cloud Monitoring wants.
+	SumOfSquaredDeviation float64
+}
+
+// New returns a Histogram with the given ascending bucket bounds.
+func New(bounds ...float64) *Histogram {
+	return &Histogram{snap: Snapshot{
+		Bounds: slices.Clone(bounds),
+		Counts: make([]int64, len(bounds)+1),
+	}}
+}
+
+// Observe records v.
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := &h.snap
+	i, _ := slices.BinarySearch(s.Bounds, v)
+	s.Counts[i]++
+	s.Count++
+	// Welford's update keeps the mean and squared deviation exact without
+	// storing the observations.
+	delta := v - s.Mean
+	s.Mean += delta / float64(s.Count)
+	s.SumOfSquaredDeviation += delta * (v - s.Mean)
+}
+
+// Snapshot returns a copy of the current state.
+func (h *Histogram) Snapshot() Snapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.snap
+	s.Bounds = slices.Clone(s.Bounds)
+	s.Counts = slices.Clone(s.Counts)
+	return s
+}
+
+// Quantile estimates the q-th quantile (0 < q <= 1) as the upper bound of
+// the bucket containing it, or +Inf if that is the overflow bucket. It
+// returns 0 for an empty histogram.
+func (s Snapshot) Quantile(q float64) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(s.Count)))
+	var seen int64
+	for i, n := range s.Counts {
+		seen += n
+		if seen >= rank {
+			if i < len(s.Bounds) {
+				return s.Bounds[i]
+			}
+			break
+		}
+	}
+	return math.Inf(1)
+}
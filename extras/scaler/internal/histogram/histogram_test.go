@@ -0,0 +1,39 @@
+package histogram
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	h := New(10, 60, 300)
+	for _, v := range []float64{5, 10, 45, 90, 120, 600} {
+		h.Observe(v)
+	}
+	s := h.Snapshot()
+	if !slices.Equal(s.Counts, []int64{2, 1, 2, 1}) {
+		t.Fatalf("counts = %v, want [2 1 2 1] (bounds are inclusive)", s.Counts)
+	}
+	if s.Count != 6 || s.Mean != 145 {
+		t.Fatalf("count = %d, mean = %v; want 6, 145", s.Count, s.Mean)
+	}
+	// Σ(x-145)² over the observations.
+	if want := 140*140 + 135*135 + 100*100 + 55*55 + 25*25 + 455*455.0; math.Abs(s.SumOfSquaredDeviation-want) > 1e-6 {
+		t.Fatalf("sum of squared deviation = %v, want %v", s.SumOfSquaredDeviation, want)
+	}
+	if got := s.Quantile(0.5); got != 60 {
+		t.Fatalf("p50 = %v, want 60", got)
+	}
+	if got := s.Quantile(1); !math.IsInf(got, 1) {
+		t.Fatalf("p100 = %v, want +Inf (overflow bucket)", got)
+	}
+
+	h.Observe(1)
+	if s.Counts[0] != 2 {
+		t.Fatal("Snapshot shares its counts with the Histogram")
+	}
+	if New(1).Snapshot().Quantile(0.9) != 0 {
+		t.Fatal("empty histogram quantile should be 0")
+	}
+}
@@ -0,0 +1,146 @@
+// Package incident opens and resolves incidents in PagerDuty or Opsgenie.
+// Unlike notify's chat alerts, an incident pages the on-call engineer and
+// stays open until the scaler resolves it.
+package incident
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// postTimeout bounds one API call.
+const postTimeout = 10 * time.Second
+
+// Default API endpoints, overridable for tests and Opsgenie's EU instance.
+const (
+	PagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	OpsgenieURL  = "https://api.opsgenie.com/v2/alerts"
+)
+
+// Pager opens and resolves incidents. The scaler keeps at most one incident
+// open per key, so repeated triggers with the same key are deduplicated by
+// the service.
+type Pager interface {
+	Trigger(ctx context.Context, key, summary string) error
+	Resolve(ctx context.Context, key string) error
+}
+
+// New returns a Pager for provider ("pagerduty" or "opsgenie"). apiKey is a
+// PagerDuty Events v2 integration (routing) key or an Opsgenie API
+// integration key; source names the sender, e.g. the scale set. An empty
+// apiURL uses the provider's default endpoint.
+func New(provider, apiKey, source, apiURL string) (Pager, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("%s needs an API key", provider)
+	}
+	client := &http.Client{Timeout: postTimeout}
+	switch provider {
+	case "pagerduty":
+		if apiURL == "" {
+			apiURL = PagerDutyURL
+		}
+		return &pagerDuty{url: apiURL, routingKey: apiKey, source: source, client: client}, nil
+	case "opsgenie":
+		if apiURL == "" {
+			apiURL = OpsgenieURL
+		}
+		return &opsgenie{url: strings.TrimSuffix(apiURL, "/"), apiKey: apiKey, source: source, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown incident provider %q (want pagerduty or opsgenie)", provider)
+	}
+}
+
+// pagerDuty sends Events API v2 events; dedup_key ties a resolve to its
+// trigger.
+type pagerDuty struct {
+	url        string
+	routingKey string
+	source     string
+	client     *http.Client
+}
+
+func (p *pagerDuty) Trigger(ctx context.Context, key, summary string) error {
+	return post(ctx, p.client, p.url, nil, map[string]any{
+		"routing_key":  p.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    p.source + "/" + key,
+		"payload": map[string]string{
+			"summary":  fmt.Sprintf("[%s] %s", p.source, summary),
+			"source":   p.source,
+			"severity": "critical",
+		},
+	})
+}
+
+func (p *pagerDuty) Resolve(ctx context.Context, key string) error {
+	return post(ctx, p.client, p.url, nil, map[string]any{
+		"routing_key":  p.routingKey,
+		"event_action": "resolve",
+		"dedup_key":    p.source + "/" + key,
+	})
+}
+
+// opsgenie creates alerts with an alias, which both deduplicates open alerts
+// and identifies the alert to close.
+type opsgenie struct {
+	url    string
+	apiKey string
+	source string
+	client *http.Client
+}
+
+func (o *opsgenie) headers() http.Header {
+	return http.Header{"Authorization": {"GenieKey " + o.apiKey}}
+}
+
+func (o *opsgenie) Trigger(ctx context.Context, key, summary string) error {
+	message := fmt.Sprintf("[%s] %s", o.source, summary)
+	return post(ctx, o.client, o.url, o.headers(), map[string]any{
+		// Opsgenie truncates messages at 130 characters; the description
+		// keeps the whole text.
+		"message":     message,
+		"description": message,
+		"alias":       o.source + "/" + key,
+		"source":      o.source,
+		"priority":    "P1",
+	})
+}
+
+func (o *opsgenie) Resolve(ctx context.Context, key string) error {
+	closeURL := fmt.Sprintf("%s/%s/close?identifierType=alias", o.url, url.PathEscape(o.source+"/"+key))
+	return post(ctx, o.client, closeURL, o.headers(), map[string]string{"source": o.source})
+}
+
+func post(ctx context.Context, client *http.Client, url string, header http.Header, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), postTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package incident
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// recorder records the path, Authorization header and JSON body of each
+// request.
+type recorder struct {
+	mu     sync.Mutex
+	paths  []string
+	auth   []string
+	bodies []map[string]any
+	status int
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var body map[string]any
+	json.NewDecoder(req.Body).Decode(&body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths = append(r.paths, req.URL.RequestURI())
+	r.auth = append(r.auth, req.Header.Get("Authorization"))
+	r.bodies = append(r.bodies, body)
+	if r.status != 0 {
+		w.WriteHeader(r.status)
+	}
+}
+
+func newTestPager(t *testing.T, provider string) (Pager, *recorder) {
+	t.Helper()
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+	p, err := New(provider, "key-123", "windows-gpu", srv.URL+"/v2/alerts")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return p, rec
+}
+
+func TestNewValidates(t *testing.T) {
+	if _, err := New("pagerduty", "", "s", ""); err == nil {
+		t.Fatal("New should require an API key")
+	}
+	if _, err := New("victorops", "k", "s", ""); err == nil {
+		t.Fatal("New should reject an unknown provider")
+	}
+}
+
+func TestPagerDutyTriggerAndResolve(t *testing.T) {
+	p, rec := newTestPager(t, "pagerduty")
+	ctx := context.Background()
+	if err := p.Trigger(ctx, "no_vms", "no VM created for 30m"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	if err := p.Resolve(ctx, "no_vms"); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	trigger, resolve := rec.bodies[0], rec.bodies[1]
+	if trigger["routing_key"] != "key-123" || trigger["event_action"] != "trigger" || trigger["dedup_key"] != "windows-gpu/no_vms" {
+		t.Fatalf("trigger = %v", trigger)
+	}
+	payload, _ := trigger["payload"].(map[string]any)
+	if payload["summary"] != "[windows-gpu] no VM created for 30m" || payload["severity"] != "critical" {
+		t.Fatalf("trigger payload = %v", payload)
+	}
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != trigger["dedup_key"] {
+		t.Fatalf("resolve = %v, want the trigger's dedup_key", resolve)
+	}
+}
+
+func TestOpsgenieTriggerAndResolve(t *testing.T) {
+	p, rec := newTestPager(t, "opsgenie")
+	ctx := context.Background()
+	if err := p.Trigger(ctx, "no_vms", "no VM created for 30m"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	if err := p.Resolve(ctx, "no_vms"); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	if rec.auth[0] != "GenieKey key-123" || rec.auth[1] != "GenieKey key-123" {
+		t.Fatalf("Authorization = %q", rec.auth)
+	}
+	if rec.paths[0] != "/v2/alerts" || rec.bodies[0]["alias"] != "windows-gpu/no_vms" || rec.bodies[0]["priority"] != "P1" {
+		t.Fatalf("trigger %s = %v", rec.paths[0], rec.bodies[0])
+	}
+	if rec.paths[1] != "/v2/alerts/windows-gpu%2Fno_vms/close?identifierType=alias" {
+		t.Fatalf("close path = %s", rec.paths[1])
+	}
+}
+
+func TestTriggerReportsHTTPErrors(t *testing.T) {
+	p, rec := newTestPager(t, "pagerduty")
+	rec.status = http.StatusBadRequest
+	if err := p.Trigger(context.Background(), "no_vms", "x"); err == nil {
+		t.Fatal("Trigger should fail on a 400")
+	}
+}
@@ -0,0 +1,121 @@
+// Package lease elects one leader among scaler replicas with a lease kept in
+// a Cloud Storage object. The object's metadata names the holder and when
+// the lease expires; every write is conditional on the generation read
+// before it, so of two replicas racing for a free lease only one wins.
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// ParseURI splits a gs://BUCKET/OBJECT lease location.
+func ParseURI(uri string) (bucket, object string, err error) {
+	rest, ok := strings.CutPrefix(uri, "gs://")
+	bucket, object, _ = strings.Cut(rest, "/")
+	if !ok || bucket == "" || object == "" {
+		return "", "", fmt.Errorf("%q is not gs://BUCKET/OBJECT", uri)
+	}
+	return bucket, object, nil
+}
+
+// Lease is one replica's view of the lease. It is safe for concurrent use.
+type Lease struct {
+	svc    *storage.Service
+	bucket string
+	object string
+	holder string
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu sync.Mutex
+	// generation is the object's generation while l holds the lease; 0
+	// otherwise.
+	generation int64
+}
+
+// New returns holder's Lease at uri, held for ttl after each Acquire.
+// Credentials come from the environment and need read and write access to
+// objects in the bucket.
+func New(ctx context.Context, uri, holder string, ttl time.Duration, opts ...option.ClientOption) (*Lease, error) {
+	bucket, object, err := ParseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := storage.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating Cloud Storage client: %w", err)
+	}
+	return &Lease{svc: svc, bucket: bucket, object: object, holder: holder, ttl: ttl, now: time.Now}, nil
+}
+
+// Acquire takes the lease if it is free or expired, or renews it if l holds
+// it, for another ttl. It reports whether l holds the lease now, and who
+// does.
+func (l *Lease) Acquire(ctx context.Context) (held bool, holder string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	obj, err := l.svc.Objects.Get(l.bucket, l.object).Context(ctx).Do()
+	var generation int64 // 0: the object must not exist
+	switch {
+	case isStatus(err, http.StatusNotFound):
+	case err != nil:
+		return false, "", fmt.Errorf("reading lease: %w", err)
+	default:
+		holder = obj.Metadata["holder"]
+		expires, _ := time.Parse(time.RFC3339Nano, obj.Metadata["expires"])
+		if holder != l.holder && now.Before(expires) {
+			l.generation = 0
+			return false, holder, nil
+		}
+		generation = obj.Generation
+	}
+	obj, err = l.svc.Objects.Insert(l.bucket, &storage.Object{
+		Name: l.object,
+		Metadata: map[string]string{
+			"holder":  l.holder,
+			"expires": now.Add(l.ttl).UTC().Format(time.RFC3339Nano),
+		},
+	}).IfGenerationMatch(generation).Media(strings.NewReader("")).Context(ctx).Do()
+	switch {
+	case isStatus(err, http.StatusPreconditionFailed):
+		// Another replica wrote the lease since it was read.
+		l.generation = 0
+		return false, holder, nil
+	case err != nil:
+		return false, holder, fmt.Errorf("writing lease: %w", err)
+	}
+	l.generation = obj.Generation
+	return true, l.holder, nil
+}
+
+// Release gives up the lease, if l holds it, so another replica can take
+// it without waiting for it to expire.
+func (l *Lease) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.generation == 0 {
+		return nil
+	}
+	err := l.svc.Objects.Delete(l.bucket, l.object).IfGenerationMatch(l.generation).Context(ctx).Do()
+	l.generation = 0
+	if isStatus(err, http.StatusNotFound) || isStatus(err, http.StatusPreconditionFailed) {
+		return nil // already taken over
+	}
+	return err
+}
+
+func isStatus(err error, code int) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == code
+}
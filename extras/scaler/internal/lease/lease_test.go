@@ -0,0 +1,161 @@
+package lease
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// fakeGCS serves one object with generation preconditions, as Cloud
+// Storage does.
+type fakeGCS struct {
+	t *testing.T
+
+	mu  sync.Mutex
+	obj *storage.Object // nil while the object does not exist
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	const path = "/storage/v1/b/leases/o/windows-gpu"
+	var generation int64
+	if f.obj != nil {
+		generation = f.obj.Generation
+	}
+	if want := r.URL.Query().Get("ifGenerationMatch"); want != "" && want != strconv.FormatInt(generation, 10) {
+		http.Error(w, `{"error":{"code":412,"message":"precondition failed"}}`, http.StatusPreconditionFailed)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == path:
+		if f.obj == nil {
+			http.Error(w, `{"error":{"code":404,"message":"not found"}}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(f.obj)
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/leases/o":
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			f.t.Fatalf("upload content type: %v", err)
+		}
+		part, err := multipart.NewReader(r.Body, params["boundary"]).NextPart()
+		if err != nil {
+			f.t.Fatalf("upload body: %v", err)
+		}
+		var obj storage.Object
+		if err := json.NewDecoder(part).Decode(&obj); err != nil || obj.Name != "windows-gpu" {
+			f.t.Fatalf("uploaded object = %+v, %v", obj, err)
+		}
+		obj.Generation = generation + 1
+		f.obj = &obj
+		json.NewEncoder(w).Encode(f.obj)
+	case r.Method == http.MethodDelete && r.URL.Path == path:
+		f.obj = nil
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestLease(t *testing.T, srv *httptest.Server, holder string, now *time.Time) *Lease {
+	t.Helper()
+	l, err := New(context.Background(), "gs://leases/windows-gpu", holder, 30*time.Second,
+		option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestLeaseElectsOneHolder(t *testing.T) {
+	f := &fakeGCS{t: t}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+	a := newTestLease(t, srv, "scaler-a", &now)
+	b := newTestLease(t, srv, "scaler-b", &now)
+
+	if held, holder, err := a.Acquire(ctx); err != nil || !held || holder != "scaler-a" {
+		t.Fatalf("a.Acquire = %v, %q, %v; want the free lease", held, holder, err)
+	}
+	if held, holder, err := b.Acquire(ctx); err != nil || held || holder != "scaler-a" {
+		t.Fatalf("b.Acquire = %v, %q, %v; want a standby behind scaler-a", held, holder, err)
+	}
+	// a renews before it expires; b still waits.
+	now = now.Add(20 * time.Second)
+	if held, _, err := a.Acquire(ctx); err != nil || !held {
+		t.Fatalf("a renewing = %v, %v", held, err)
+	}
+	now = now.Add(20 * time.Second)
+	if held, _, _ := b.Acquire(ctx); held {
+		t.Fatal("b took a lease renewed 20s ago")
+	}
+
+	// a dies: b takes over once the lease expires, and a cannot renew.
+	now = now.Add(11 * time.Second)
+	if held, holder, err := b.Acquire(ctx); err != nil || !held || holder != "scaler-b" {
+		t.Fatalf("b.Acquire after expiry = %v, %q, %v", held, holder, err)
+	}
+	if held, holder, _ := a.Acquire(ctx); held || holder != "scaler-b" {
+		t.Fatalf("a.Acquire after takeover = %v, %q", held, holder)
+	}
+	if err := a.Release(ctx); err != nil || f.obj == nil {
+		t.Fatalf("a.Release = %v, should leave b's lease alone", err)
+	}
+
+	// b hands over: a gets the lease at once.
+	if err := b.Release(ctx); err != nil {
+		t.Fatalf("b.Release: %v", err)
+	}
+	if held, _, err := a.Acquire(ctx); err != nil || !held {
+		t.Fatalf("a.Acquire after release = %v, %v", held, err)
+	}
+}
+
+func TestLeaseLosesARace(t *testing.T) {
+	f := &fakeGCS{t: t}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	now := time.Date(2026, 3, 2, 14, 0, 0, 0, time.UTC)
+	a := newTestLease(t, srv, "scaler-a", &now)
+	// Another replica creates the object between a's read and write.
+	racing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			http.Error(w, `{"error":{"code":404}}`, http.StatusNotFound)
+			f.mu.Lock()
+			f.obj = &storage.Object{Name: "windows-gpu", Generation: 7, Metadata: map[string]string{"holder": "scaler-b"}}
+			f.mu.Unlock()
+			return
+		}
+		f.ServeHTTP(w, r)
+	}))
+	defer racing.Close()
+	a.svc.BasePath = racing.URL + "/storage/v1/"
+	if held, _, err := a.Acquire(context.Background()); err != nil || held {
+		t.Fatalf("Acquire = %v, %v; want the race lost", held, err)
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	if bucket, object, err := ParseURI("gs://slang-ci/leases/windows-gpu"); err != nil || bucket != "slang-ci" || object != "leases/windows-gpu" {
+		t.Fatalf("ParseURI = %q, %q, %v", bucket, object, err)
+	}
+	for _, uri := range []string{"slang-ci/lease", "gs://slang-ci", "gs:///lease", "gs://slang-ci/"} {
+		if _, _, err := ParseURI(uri); err == nil {
+			t.Errorf("ParseURI(%q) should fail", uri)
+		}
+	}
+}
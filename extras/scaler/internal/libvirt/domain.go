@@ -0,0 +1,202 @@
+package libvirt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The types below cover only the subset of the libvirt domain schema the
+// runner VMs need. See https://libvirt.org/formatdomain.html.
+
+type domain struct {
+	XMLName xml.Name      `xml:"domain"`
+	Type    string        `xml:"type,attr"`
+	Name    string        `xml:"name"`
+	Memory  domainMemory  `xml:"memory"`
+	VCPU    int           `xml:"vcpu"`
+	OS      domainOS      `xml:"os"`
+	CPU     domainCPU     `xml:"cpu"`
+	SysInfo domainSysInfo `xml:"sysinfo"`
+	Devices domainDevices `xml:"devices"`
+}
+
+type domainMemory struct {
+	Unit  string `xml:"unit,attr"`
+	Value int    `xml:",chardata"`
+}
+
+type domainOS struct {
+	Type domainOSType `xml:"type"`
+}
+
+type domainOSType struct {
+	Arch    string `xml:"arch,attr"`
+	Machine string `xml:"machine,attr"`
+	Value   string `xml:",chardata"`
+}
+
+type domainCPU struct {
+	Mode string `xml:"mode,attr"`
+}
+
+type domainSysInfo struct {
+	Type    string       `xml:"type,attr"`
+	Entries []fwCfgEntry `xml:"entry"`
+}
+
+type fwCfgEntry struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+type domainDevices struct {
+	Disks      []domainDisk      `xml:"disk"`
+	Interfaces []domainInterface `xml:"interface"`
+	HostDevs   []domainHostDev   `xml:"hostdev"`
+}
+
+type domainDisk struct {
+	Type   string           `xml:"type,attr"`
+	Device string           `xml:"device,attr"`
+	Driver domainDiskDriver `xml:"driver"`
+	Source domainDiskSource `xml:"source"`
+	Target domainDiskTarget `xml:"target"`
+}
+
+type domainDiskDriver struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type domainDiskSource struct {
+	File string `xml:"file,attr"`
+}
+
+type domainDiskTarget struct {
+	Dev string `xml:"dev,attr"`
+	Bus string `xml:"bus,attr"`
+}
+
+type domainInterface struct {
+	Type   string                `xml:"type,attr"`
+	Source domainInterfaceSource `xml:"source"`
+	Model  domainInterfaceModel  `xml:"model"`
+}
+
+type domainInterfaceSource struct {
+	Network string `xml:"network,attr"`
+}
+
+type domainInterfaceModel struct {
+	Type string `xml:"type,attr"`
+}
+
+type domainHostDev struct {
+	Mode    string              `xml:"mode,attr"`
+	Type    string              `xml:"type,attr"`
+	Managed string              `xml:"managed,attr"`
+	Source  domainHostDevSource `xml:"source"`
+}
+
+type domainHostDevSource struct {
+	Address pciAddress `xml:"address"`
+}
+
+type pciAddress struct {
+	Domain   string `xml:"domain,attr"`
+	Bus      string `xml:"bus,attr"`
+	Slot     string `xml:"slot,attr"`
+	Function string `xml:"function,attr"`
+}
+
+// parsePCIAddress parses a "DDDD:BB:SS.F" host PCI address as printed by
+// lspci -D into libvirt's hex attribute form.
+func parsePCIAddress(addr string) (pciAddress, error) {
+	invalid := fmt.Errorf("invalid PCI address %q (want DDDD:BB:SS.F)", addr)
+
+	parts := strings.Split(addr, ":")
+	if len(parts) != 3 {
+		return pciAddress{}, invalid
+	}
+	slotFn := strings.Split(parts[2], ".")
+	if len(slotFn) != 2 {
+		return pciAddress{}, invalid
+	}
+	fields := []string{parts[0], parts[1], slotFn[0], slotFn[1]}
+	for _, f := range fields {
+		if _, err := strconv.ParseUint(f, 16, 16); err != nil {
+			return pciAddress{}, invalid
+		}
+	}
+	return pciAddress{
+		Domain:   "0x" + fields[0],
+		Bus:      "0x" + fields[1],
+		Slot:     "0x" + fields[2],
+		Function: "0x" + fields[3],
+	}, nil
+}
+
+// domainXML renders the domain definition for one runner VM.
+func (m *Manager) domainXML(vmName, disk, gpu, jitConfig string) ([]byte, error) {
+	script := linuxStartupScript
+	if m.config.StartupScript != nil {
+		var err error
+		script, err = m.config.StartupScript.Render(vmName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	d := domain{
+		Type:   "kvm",
+		Name:   vmName,
+		Memory: domainMemory{Unit: "MiB", Value: m.config.MemoryMiB},
+		VCPU:   m.config.CPUs,
+		OS: domainOS{
+			Type: domainOSType{Arch: "x86_64", Machine: "q35", Value: "hvm"},
+		},
+		CPU: domainCPU{Mode: "host-passthrough"},
+		SysInfo: domainSysInfo{
+			Type: "fwcfg",
+			Entries: []fwCfgEntry{
+				{Name: fwCfgPrefix + "jit-config", Value: jitConfig},
+				{Name: fwCfgPrefix + "startup-script", Value: script},
+				{Name: fwCfgPrefix + "expect-gpu", Value: strconv.FormatBool(gpu != "")},
+			},
+		},
+		Devices: domainDevices{
+			Disks: []domainDisk{{
+				Type:   "file",
+				Device: "disk",
+				Driver: domainDiskDriver{Name: "qemu", Type: "qcow2"},
+				Source: domainDiskSource{File: disk},
+				Target: domainDiskTarget{Dev: "vda", Bus: "virtio"},
+			}},
+			Interfaces: []domainInterface{{
+				Type:   "network",
+				Source: domainInterfaceSource{Network: m.config.Network},
+				Model:  domainInterfaceModel{Type: "virtio"},
+			}},
+		},
+	}
+
+	if gpu != "" {
+		addr, err := parsePCIAddress(gpu)
+		if err != nil {
+			return nil, err
+		}
+		d.Devices.HostDevs = []domainHostDev{{
+			Mode:    "subsystem",
+			Type:    "pci",
+			Managed: "yes",
+			Source:  domainHostDevSource{Address: addr},
+		}}
+	}
+
+	out, err := xml.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("rendering domain XML for %s: %w", vmName, err)
+	}
+	return out, nil
+}
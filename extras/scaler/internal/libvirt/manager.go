@@ -0,0 +1,433 @@
+// Package libvirt provides VM lifecycle management for ephemeral GitHub
+// Actions runners on a local libvirt/QEMU hypervisor.
+//
+// It targets teams running the scaler on their own GPU servers without any
+// cloud account: each runner gets a copy-on-write qcow2 overlay of a base
+// image and, when GPUs are configured, one passed-through PCI device. The
+// JIT config and startup script reach the guest through QEMU fw_cfg entries
+// (readable under /sys/firmware/qemu_fw_cfg/by_name/), so no network
+// metadata service is needed.
+//
+// The Manager shells out to virsh and qemu-img rather than linking libvirt
+// through cgo, which keeps the scaler a static binary.
+package libvirt
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"extras/scaler/internal/startup"
+	"extras/scaler/internal/vmstate"
+)
+
+const (
+	defaultURI             = "qemu:///system"
+	defaultImageDir        = "/var/lib/libvirt/images"
+	defaultNetwork         = "default"
+	defaultMemoryMiB       = 16384
+	defaultCPUs            = 8
+	defaultCleanupInterval = 2 * time.Minute
+	cleanupCommandTimeout  = 45 * time.Second
+
+	// fwCfgPrefix namespaces our fw_cfg entries; QEMU requires user entries
+	// to live under "opt/".
+	fwCfgPrefix = "opt/org.github.runner/"
+)
+
+//go:embed startup.sh
+var linuxStartupScript string
+
+// ManagerConfig holds the libvirt configuration for VM management.
+type ManagerConfig struct {
+	URI       string   // libvirt connection URI (e.g., "qemu:///system")
+	BaseImage string   // Path to the base qcow2 image every runner is cloned from
+	ImageDir  string   // Directory for per-runner overlay disks
+	Network   string   // libvirt network the runner NIC attaches to
+	MemoryMiB int      // Guest memory
+	CPUs      int      // Guest vCPUs
+	GPUs      []string // PCI addresses (e.g., "0000:65:00.0") handed out one per VM; empty means CPU-only
+	VMPrefix  string   // VM name prefix for cleanup (e.g., "lab-test")
+	// StartupScript, when set, replaces the embedded startup script.
+	StartupScript *startup.Script
+	// CleanupInterval controls how often shut-off runner domains are
+	// reaped. Guests power off after their job, like the GCP pools.
+	CleanupInterval time.Duration
+}
+
+type vmInfo struct {
+	vmName    string
+	gpu       string
+	busy      bool
+	createdAt time.Time
+}
+
+// Manager handles creating and deleting libvirt domains for GitHub Actions
+// runners.
+type Manager struct {
+	config        ManagerConfig
+	cancelCleanup context.CancelFunc
+	// runCommand executes an external command and returns its stdout. It is
+	// overridable in tests so no hypervisor is needed.
+	runCommand func(ctx context.Context, name string, args ...string) (string, error)
+
+	mu sync.Mutex
+	// runnerName -> vmInfo
+	vms            map[string]*vmInfo
+	pendingCreates map[string]string // runnerName -> reserved GPU
+}
+
+// NewManager creates a new libvirt VM manager.
+func NewManager(ctx context.Context, cfg ManagerConfig) (*Manager, error) {
+	if cfg.BaseImage == "" {
+		return nil, fmt.Errorf("libvirt base image is required")
+	}
+	if cfg.URI == "" {
+		cfg.URI = defaultURI
+	}
+	if cfg.ImageDir == "" {
+		cfg.ImageDir = defaultImageDir
+	}
+	if cfg.Network == "" {
+		cfg.Network = defaultNetwork
+	}
+	if cfg.MemoryMiB <= 0 {
+		cfg.MemoryMiB = defaultMemoryMiB
+	}
+	if cfg.CPUs <= 0 {
+		cfg.CPUs = defaultCPUs
+	}
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = defaultCleanupInterval
+	}
+	for _, gpu := range cfg.GPUs {
+		if _, err := parsePCIAddress(gpu); err != nil {
+			return nil, err
+		}
+	}
+
+	cleanupCtx, cancelCleanup := context.WithCancel(ctx)
+	mgr := &Manager{
+		config:         cfg,
+		cancelCleanup:  cancelCleanup,
+		runCommand:     execCommand,
+		vms:            make(map[string]*vmInfo),
+		pendingCreates: make(map[string]string),
+	}
+
+	if cfg.VMPrefix != "" {
+		go mgr.cleanupShutOffVMs(cleanupCtx)
+	}
+	return mgr, nil
+}
+
+func execCommand(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// Close shuts down the manager.
+func (m *Manager) Close() {
+	m.cancelCleanup()
+}
+
+// ActiveCount returns the number of VMs currently tracked or being created.
+func (m *Manager) ActiveCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.vms) + len(m.pendingCreates)
+}
+
+// ActiveRunnerNames returns the names of all tracked runners.
+func (m *Manager) ActiveRunnerNames() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.vms)+len(m.pendingCreates))
+	for name := range m.vms {
+		names = append(names, name)
+	}
+	for name := range m.pendingCreates {
+		if _, ok := m.vms[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// VMs returns the tracked VMs and pending creates, located by the GPU they
+// hold.
+func (m *Manager) VMs() []vmstate.VM {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	vms := make([]vmstate.VM, 0, len(m.vms)+len(m.pendingCreates))
+	for name, vm := range m.vms {
+		vms = append(vms, vmstate.VM{RunnerName: name, Name: vm.vmName, Location: vm.gpu, Busy: vm.busy, CreatedAt: vm.createdAt})
+	}
+	for name, gpu := range m.pendingCreates {
+		if _, ok := m.vms[name]; !ok {
+			vms = append(vms, vmstate.VM{RunnerName: name, Location: gpu, Pending: true})
+		}
+	}
+	vmstate.Sort(vms)
+	return vms
+}
+
+// MarkBusy marks a runner as busy (job started).
+func (m *Manager) MarkBusy(runnerName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if vm, ok := m.vms[runnerName]; ok {
+		vm.busy = true
+	}
+}
+
+// CreateVM clones the base image, defines a domain with a passed-through
+// GPU (when configured) and starts it.
+func (m *Manager) CreateVM(ctx context.Context, runnerName, jitConfig string) (string, error) {
+	gpu, err := m.reserveCreate(runnerName)
+	if err != nil {
+		return "", err
+	}
+
+	vmName := runnerName
+	disk := m.overlayPath(vmName)
+
+	if _, err := m.runCommand(ctx, "qemu-img", "create", "-f", "qcow2", "-F", "qcow2", "-b", m.config.BaseImage, disk); err != nil {
+		m.releaseCreate(runnerName)
+		return "", fmt.Errorf("cloning base image for %s: %w", vmName, err)
+	}
+
+	domainXML, err := m.domainXML(vmName, disk, gpu, jitConfig)
+	if err != nil {
+		m.discardDisk(disk)
+		m.releaseCreate(runnerName)
+		return "", err
+	}
+
+	if err := m.defineAndStart(ctx, vmName, domainXML); err != nil {
+		m.undefine(context.WithoutCancel(ctx), vmName)
+		m.discardDisk(disk)
+		m.releaseCreate(runnerName)
+		return "", err
+	}
+
+	m.mu.Lock()
+	delete(m.pendingCreates, runnerName)
+	m.vms[runnerName] = &vmInfo{vmName: vmName, gpu: gpu, createdAt: time.Now()}
+	m.mu.Unlock()
+
+	slog.Info("VM created", "vm", vmName, "provider", "libvirt", "gpu", gpu)
+	return vmName, nil
+}
+
+func (m *Manager) defineAndStart(ctx context.Context, vmName string, domainXML []byte) error {
+	f, err := os.CreateTemp("", vmName+"-*.xml")
+	if err != nil {
+		return fmt.Errorf("writing domain XML for %s: %w", vmName, err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(domainXML); err != nil {
+		f.Close()
+		return fmt.Errorf("writing domain XML for %s: %w", vmName, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing domain XML for %s: %w", vmName, err)
+	}
+
+	if _, err := m.virsh(ctx, "define", f.Name()); err != nil {
+		return fmt.Errorf("defining domain %s: %w", vmName, err)
+	}
+	if _, err := m.virsh(ctx, "start", vmName); err != nil {
+		return fmt.Errorf("starting domain %s: %w", vmName, err)
+	}
+	return nil
+}
+
+// reserveCreate records a pending create and hands out a free GPU. GPU
+// passthrough is exclusive, so the GPU list is also the pool's hard
+// capacity limit.
+func (m *Manager) reserveCreate(runnerName string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.vms[runnerName]; ok {
+		return "", fmt.Errorf("runner %q is already tracked", runnerName)
+	}
+	if _, ok := m.pendingCreates[runnerName]; ok {
+		return "", fmt.Errorf("runner %q already has a pending create", runnerName)
+	}
+
+	var gpu string
+	if len(m.config.GPUs) > 0 {
+		inUse := make(map[string]bool, len(m.vms)+len(m.pendingCreates))
+		for _, vm := range m.vms {
+			inUse[vm.gpu] = true
+		}
+		for _, reserved := range m.pendingCreates {
+			inUse[reserved] = true
+		}
+		for _, candidate := range m.config.GPUs {
+			if !inUse[candidate] {
+				gpu = candidate
+				break
+			}
+		}
+		if gpu == "" {
+			return "", fmt.Errorf("all %d passthrough GPUs are in use", len(m.config.GPUs))
+		}
+	}
+
+	m.pendingCreates[runnerName] = gpu
+	return gpu, nil
+}
+
+func (m *Manager) releaseCreate(runnerName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pendingCreates, runnerName)
+}
+
+// DeleteByRunnerName deletes the VM associated with a runner name.
+func (m *Manager) DeleteByRunnerName(ctx context.Context, runnerName string) error {
+	m.mu.Lock()
+	vm, ok := m.vms[runnerName]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("no VM found for runner %q", runnerName)
+	}
+	vmName := vm.vmName
+	delete(m.vms, runnerName)
+	m.mu.Unlock()
+
+	return m.deleteVM(ctx, vmName)
+}
+
+// DeleteAll deletes all tracked VMs. Used during shutdown.
+func (m *Manager) DeleteAll(ctx context.Context) {
+	m.mu.Lock()
+	vms := make(map[string]*vmInfo)
+	for rn, vm := range m.vms {
+		vms[rn] = vm
+	}
+	m.mu.Unlock()
+
+	for rn, vm := range vms {
+		if err := m.deleteVM(ctx, vm.vmName); err != nil {
+			slog.Error("failed to delete VM during cleanup", "vm", vm.vmName, "error", err)
+		}
+		m.mu.Lock()
+		delete(m.vms, rn)
+		m.mu.Unlock()
+	}
+}
+
+// deleteVM powers off and undefines the domain, then removes its overlay.
+// destroy fails for domains that already shut themselves down, which is the
+// normal end of a job, so only undefine errors are reported.
+func (m *Manager) deleteVM(ctx context.Context, vmName string) error {
+	_, _ = m.virsh(ctx, "destroy", vmName)
+	if _, err := m.virsh(ctx, "undefine", vmName, "--nvram"); err != nil {
+		return fmt.Errorf("undefining domain %s: %w", vmName, err)
+	}
+	m.discardDisk(m.overlayPath(vmName))
+	slog.Info("VM deleted", "event", "vm_deleted", "vm", vmName, "provider", "libvirt")
+	return nil
+}
+
+func (m *Manager) undefine(ctx context.Context, vmName string) {
+	_, _ = m.virsh(ctx, "destroy", vmName)
+	_, _ = m.virsh(ctx, "undefine", vmName, "--nvram")
+}
+
+func (m *Manager) discardDisk(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		slog.Warn("failed to remove overlay disk", "path", path, "error", err)
+	}
+}
+
+func (m *Manager) overlayPath(vmName string) string {
+	return filepath.Join(m.config.ImageDir, vmName+".qcow2")
+}
+
+func (m *Manager) virsh(ctx context.Context, args ...string) (string, error) {
+	return m.runCommand(ctx, "virsh", append([]string{"-c", m.config.URI}, args...)...)
+}
+
+// cleanupShutOffVMs periodically deletes runner domains that have powered
+// themselves off but were not deleted by the scaler (e.g., after a restart).
+func (m *Manager) cleanupShutOffVMs(ctx context.Context) {
+	ticker := time.NewTicker(m.config.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		m.doCleanupShutOffVMs(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) doCleanupShutOffVMs(ctx context.Context) {
+	listCtx, cancel := context.WithTimeout(ctx, cleanupCommandTimeout)
+	out, err := m.virsh(listCtx, "list", "--name", "--state-shutoff")
+	cancel()
+	if err != nil {
+		slog.Warn("failed to list shut-off domains for cleanup", "error", err)
+		return
+	}
+
+	deleted := 0
+	for _, name := range strings.Fields(out) {
+		if !strings.HasPrefix(name, m.config.VMPrefix+"-") || m.creating(name) {
+			continue
+		}
+		slog.Info("cleaning up shut-off VM", "vm", name)
+		deleteCtx, cancelDelete := context.WithTimeout(ctx, cleanupCommandTimeout)
+		err := m.deleteVM(deleteCtx, name)
+		cancelDelete()
+		if err != nil {
+			slog.Warn("failed to delete shut-off VM", "vm", name, "error", err)
+			continue
+		}
+		deleted++
+		m.removeTrackedVMByVMName(name)
+	}
+	slog.Info("shut-off VM cleanup pass completed", "shutoff_vms_deleted", deleted)
+}
+
+// creating reports whether vmName is being created. A domain is shut off
+// between "virsh define" and "virsh start", so cleanup must leave it alone.
+func (m *Manager) creating(vmName string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Domains are named after their runner.
+	_, ok := m.pendingCreates[vmName]
+	return ok
+}
+
+func (m *Manager) removeTrackedVMByVMName(vmName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for runnerName, vm := range m.vms {
+		if vm.vmName == vmName {
+			delete(m.vms, runnerName)
+			return
+		}
+	}
+}
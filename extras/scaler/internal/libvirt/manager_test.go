@@ -0,0 +1,203 @@
+package libvirt
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakeHypervisor struct {
+	mu       sync.Mutex
+	commands []string
+	domains  []string // captured domain XML, in define order
+	failOn   string   // first command word sequence that should fail
+	shutOff  string   // output for "virsh list --state-shutoff"
+}
+
+func (f *fakeHypervisor) run(_ context.Context, name string, args ...string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cmd := strings.Join(append([]string{name}, args...), " ")
+	f.commands = append(f.commands, cmd)
+	if f.failOn != "" && strings.Contains(cmd, f.failOn) {
+		return "", errors.New("command failed")
+	}
+	if name == "virsh" && len(args) >= 4 && args[2] == "define" {
+		data, err := os.ReadFile(args[3])
+		if err != nil {
+			return "", err
+		}
+		f.domains = append(f.domains, string(data))
+	}
+	if strings.Contains(cmd, "--state-shutoff") {
+		return f.shutOff, nil
+	}
+	return "", nil
+}
+
+func newTestManager(f *fakeHypervisor, gpus ...string) *Manager {
+	return &Manager{
+		config: ManagerConfig{
+			URI:       defaultURI,
+			BaseImage: "/images/base.qcow2",
+			ImageDir:  "/images",
+			Network:   defaultNetwork,
+			MemoryMiB: 8192,
+			CPUs:      4,
+			GPUs:      gpus,
+			VMPrefix:  "lab-test",
+		},
+		cancelCleanup:  func() {},
+		runCommand:     f.run,
+		vms:            map[string]*vmInfo{},
+		pendingCreates: map[string]string{},
+	}
+}
+
+func TestParsePCIAddress(t *testing.T) {
+	got, err := parsePCIAddress("0000:65:00.0")
+	if err != nil {
+		t.Fatalf("parsePCIAddress returned error: %v", err)
+	}
+	want := pciAddress{Domain: "0x0000", Bus: "0x65", Slot: "0x00", Function: "0x0"}
+	if got != want {
+		t.Fatalf("parsePCIAddress = %+v, want %+v", got, want)
+	}
+
+	for _, bad := range []string{"", "65:00.0", "0000:65:00", "0000:zz:00.0"} {
+		if _, err := parsePCIAddress(bad); err == nil {
+			t.Fatalf("parsePCIAddress(%q) should fail", bad)
+		}
+	}
+}
+
+func TestCreateVMClonesDefinesAndStarts(t *testing.T) {
+	f := &fakeHypervisor{}
+	m := newTestManager(f, "0000:65:00.0")
+
+	vmName, err := m.CreateVM(context.Background(), "lab-test-1", "jit-blob")
+	if err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if vmName != "lab-test-1" {
+		t.Fatalf("vmName = %q, want lab-test-1", vmName)
+	}
+
+	if !strings.HasPrefix(f.commands[0], "qemu-img create -f qcow2 -F qcow2 -b /images/base.qcow2 /images/lab-test-1.qcow2") {
+		t.Fatalf("first command = %q, want qcow2 overlay clone", f.commands[0])
+	}
+	if f.commands[len(f.commands)-1] != "virsh -c qemu:///system start lab-test-1" {
+		t.Fatalf("last command = %q, want virsh start", f.commands[len(f.commands)-1])
+	}
+
+	if len(f.domains) != 1 {
+		t.Fatalf("defined domains = %d, want 1", len(f.domains))
+	}
+	for _, want := range []string{
+		`<name>lab-test-1</name>`,
+		`<entry name="opt/org.github.runner/jit-config">jit-blob</entry>`,
+		`<entry name="opt/org.github.runner/expect-gpu">true</entry>`,
+		`<address domain="0x0000" bus="0x65" slot="0x00" function="0x0"></address>`,
+		`<source file="/images/lab-test-1.qcow2"></source>`,
+	} {
+		if !strings.Contains(f.domains[0], want) {
+			t.Fatalf("domain XML missing %q:\n%s", want, f.domains[0])
+		}
+	}
+	if got := m.ActiveCount(); got != 1 {
+		t.Fatalf("ActiveCount = %d, want 1", got)
+	}
+}
+
+func TestCreateVMWithoutGPUsIsCPUOnly(t *testing.T) {
+	f := &fakeHypervisor{}
+	m := newTestManager(f)
+
+	if _, err := m.CreateVM(context.Background(), "lab-test-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if strings.Contains(f.domains[0], "<hostdev") {
+		t.Fatal("CPU-only pool should not pass through a GPU")
+	}
+	if !strings.Contains(f.domains[0], `<entry name="opt/org.github.runner/expect-gpu">false</entry>`) {
+		t.Fatal("CPU-only pool should tell the guest not to expect a GPU")
+	}
+}
+
+func TestCreateVMHandsOutEachGPUOnce(t *testing.T) {
+	f := &fakeHypervisor{}
+	m := newTestManager(f, "0000:65:00.0", "0000:b3:00.0")
+
+	for _, name := range []string{"lab-test-1", "lab-test-2"} {
+		if _, err := m.CreateVM(context.Background(), name, "jit"); err != nil {
+			t.Fatalf("CreateVM(%s): %v", name, err)
+		}
+	}
+	if m.vms["lab-test-1"].gpu == m.vms["lab-test-2"].gpu {
+		t.Fatalf("both VMs got GPU %s", m.vms["lab-test-1"].gpu)
+	}
+
+	if _, err := m.CreateVM(context.Background(), "lab-test-3", "jit"); err == nil {
+		t.Fatal("CreateVM should fail once every GPU is in use")
+	}
+
+	if err := m.DeleteByRunnerName(context.Background(), "lab-test-1"); err != nil {
+		t.Fatalf("DeleteByRunnerName: %v", err)
+	}
+	if _, err := m.CreateVM(context.Background(), "lab-test-3", "jit"); err != nil {
+		t.Fatalf("CreateVM after freeing a GPU: %v", err)
+	}
+}
+
+func TestCreateVMStartFailureCleansUp(t *testing.T) {
+	f := &fakeHypervisor{failOn: "start"}
+	m := newTestManager(f, "0000:65:00.0")
+
+	if _, err := m.CreateVM(context.Background(), "lab-test-1", "jit"); err == nil {
+		t.Fatal("CreateVM should fail when virsh start fails")
+	}
+	if !slices.Contains(f.commands, "virsh -c qemu:///system undefine lab-test-1 --nvram") {
+		t.Fatalf("failed create should undefine the domain, commands = %v", f.commands)
+	}
+	if got := m.ActiveCount(); got != 0 {
+		t.Fatalf("ActiveCount after failed create = %d, want 0", got)
+	}
+}
+
+func TestDoCleanupShutOffVMsOnlyDeletesOurPrefix(t *testing.T) {
+	f := &fakeHypervisor{shutOff: "lab-test-1\nother-vm\n"}
+	m := newTestManager(f)
+	m.vms["lab-test-1"] = &vmInfo{vmName: "lab-test-1"}
+
+	m.doCleanupShutOffVMs(context.Background())
+
+	if !slices.Contains(f.commands, "virsh -c qemu:///system undefine lab-test-1 --nvram") {
+		t.Fatalf("lab-test-1 should be undefined, commands = %v", f.commands)
+	}
+	for _, cmd := range f.commands {
+		if strings.Contains(cmd, "other-vm") {
+			t.Fatalf("cleanup touched a foreign domain: %q", cmd)
+		}
+	}
+	if _, ok := m.vms["lab-test-1"]; ok {
+		t.Fatal("cleaned-up VM should no longer be tracked")
+	}
+}
+
+func TestDoCleanupShutOffVMsSkipsPendingCreates(t *testing.T) {
+	f := &fakeHypervisor{shutOff: "lab-test-2\n"}
+	m := newTestManager(f)
+	m.pendingCreates["lab-test-2"] = ""
+
+	m.doCleanupShutOffVMs(context.Background())
+
+	for _, cmd := range f.commands {
+		if strings.Contains(cmd, "lab-test-2") {
+			t.Fatalf("cleanup touched a domain being created: %q", cmd)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+#!/bin/bash
+# Linux Lab Runner Startup Script (libvirt/QEMU)
+#
+# A systemd unit baked into the base qcow2 image reads this script from the
+# fw_cfg entry opt/org.github.runner/startup-script and runs it as root at
+# boot. Unlike GCE there is no metadata server: the scaler passes the JIT
+# config and GPU expectation as fw_cfg entries, which the qemu_fw_cfg kernel
+# module exposes under /sys/firmware/qemu_fw_cfg/by_name/.
+#
+# Steps:
+# 1. Reads the JIT config from fw_cfg
+# 2. Verifies the passed-through GPU when the pool expects one
+# 3. Starts the GitHub Actions runner as the runner user
+# 4. Shuts down the VM when the job completes
+
+set -euo pipefail
+
+RUNNER_DIR="/home/runner/actions-runner"
+RUNNER_USER="runner"
+LOG_FILE="${RUNNER_DIR}/startup.log"
+FW_CFG_DIR="/sys/firmware/qemu_fw_cfg/by_name/opt/org.github.runner"
+
+log() {
+  local msg
+  msg="$(date '+%Y-%m-%d %H:%M:%S') - $1"
+  echo "$msg"
+  echo "$msg" >>"$LOG_FILE"
+}
+
+read_fw_cfg() {
+  cat "${FW_CFG_DIR}/$1/raw" 2>/dev/null
+}
+
+log "=== Linux Lab Runner Startup ==="
+
+modprobe qemu_fw_cfg 2>/dev/null || true
+
+# Step 1: Read JIT config from fw_cfg
+JIT_CONFIG="$(read_fw_cfg jit-config || true)"
+if [ -z "$JIT_CONFIG" ]; then
+  log "ERROR: No JIT config in fw_cfg (${FW_CFG_DIR}/jit-config)"
+  shutdown -h now
+  exit 1
+fi
+log "JIT config retrieved (${#JIT_CONFIG} chars)"
+
+# Step 2: Verify the passed-through GPU. A missing device on a GPU pool means
+# the hostdev attach failed; registering anyway would accept GPU jobs on a
+# VM that cannot run them.
+EXPECT_GPU="$(read_fw_cfg expect-gpu || echo "true")"
+log "GPU expectation for this pool: expect-gpu=${EXPECT_GPU}"
+if [ "$EXPECT_GPU" != "false" ]; then
+  if ! nvidia-smi >/dev/null 2>&1; then
+    log "ERROR: This pool expects an NVIDIA GPU but nvidia-smi failed"
+    shutdown -h now
+    exit 1
+  fi
+  nvidia-smi 2>&1 | while read -r line; do log "  $line"; done || true
+fi
+
+# Step 3: Run the GitHub Actions runner as the runner user
+log "Starting runner as user '$RUNNER_USER' with JIT config..."
+cd "$RUNNER_DIR"
+
+EXIT_CODE=0
+sudo -u "$RUNNER_USER" ./run.sh --jitconfig "$JIT_CONFIG" || EXIT_CODE=$?
+log "Runner exited with code $EXIT_CODE"
+
+# Step 4: Shut down the VM; the scaler undefines it and drops the overlay.
+log "=== Runner complete, shutting down VM ==="
+shutdown -h now
@@ -0,0 +1,120 @@
+// Package notify posts scaler alerts to a Slack incoming webhook or any
+// other endpoint that accepts a JSON POST.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// postTimeout bounds one webhook delivery; alerts are best effort.
+const postTimeout = 10 * time.Second
+
+// Kind identifies a class of alert. Rate limiting is per kind.
+type Kind string
+
+const (
+	CreateFailures Kind = "create_failures"
+	QuotaExhausted Kind = "quota_exhausted"
+	DrainComplete  Kind = "drain_complete"
+	StuckBooting   Kind = "stuck_booting"
+	BudgetExceeded Kind = "budget_exceeded"
+	StuckJob       Kind = "stuck_job"
+	IdleCost       Kind = "idle_cost"
+)
+
+// Notifier sends alerts to a webhook, at most one per kind per interval.
+// Alerts inside the interval are dropped and counted in the next one sent.
+// A nil *Notifier discards everything.
+type Notifier struct {
+	url      string
+	source   string
+	interval time.Duration
+	client   *http.Client
+	logger   *slog.Logger
+	now      func() time.Time
+
+	mu         sync.Mutex
+	lastSent   map[Kind]time.Time
+	suppressed map[Kind]int
+}
+
+// New returns a Notifier posting to url. source names the sender in every
+// message, e.g. the scale set name.
+func New(url, source string, interval time.Duration, logger *slog.Logger) *Notifier {
+	return &Notifier{
+		url:        url,
+		source:     source,
+		interval:   interval,
+		client:     &http.Client{Timeout: postTimeout},
+		logger:     logger,
+		now:        time.Now,
+		lastSent:   make(map[Kind]time.Time),
+		suppressed: make(map[Kind]int),
+	}
+}
+
+// payload is a Slack incoming-webhook message; Slack reads only text, the
+// other fields are for generic receivers.
+type payload struct {
+	Text       string `json:"text"`
+	Kind       Kind   `json:"kind"`
+	Source     string `json:"source"`
+	Suppressed int    `json:"suppressed,omitempty"`
+}
+
+// Notify sends message as an alert of the given kind unless one was sent
+// within the interval. Delivery failures are logged, not returned.
+func (n *Notifier) Notify(ctx context.Context, kind Kind, message string) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	now := n.now()
+	if last, ok := n.lastSent[kind]; ok && now.Sub(last) < n.interval {
+		n.suppressed[kind]++
+		n.mu.Unlock()
+		return
+	}
+	n.lastSent[kind] = now
+	suppressed := n.suppressed[kind]
+	delete(n.suppressed, kind)
+	n.mu.Unlock()
+
+	p := payload{Text: fmt.Sprintf("[%s] %s", n.source, message), Kind: kind, Source: n.source, Suppressed: suppressed}
+	if suppressed > 0 {
+		p.Text += fmt.Sprintf(" (%d similar alerts suppressed)", suppressed)
+	}
+	if err := n.post(ctx, p); err != nil {
+		n.logger.Warn("failed to send notification", "kind", kind, "error", err)
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, p payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), postTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeWebhook struct {
+	mu       sync.Mutex
+	payloads []payload
+	status   int
+}
+
+func (f *fakeWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var p payload
+	json.NewDecoder(r.Body).Decode(&p)
+	f.mu.Lock()
+	f.payloads = append(f.payloads, p)
+	status := f.status
+	f.mu.Unlock()
+	if status != 0 {
+		w.WriteHeader(status)
+	}
+}
+
+func newTestNotifier(t *testing.T, f *fakeWebhook) (*Notifier, *time.Time) {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	n := New(srv.URL, "windows-gpu", 15*time.Minute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	now := time.Now()
+	n.now = func() time.Time { return now }
+	return n, &now
+}
+
+func TestNotifyRateLimitsPerKind(t *testing.T) {
+	f := &fakeWebhook{}
+	n, now := newTestNotifier(t, f)
+	ctx := context.Background()
+
+	n.Notify(ctx, CreateFailures, "3 consecutive VM creates failed")
+	n.Notify(ctx, CreateFailures, "4 consecutive VM creates failed")
+	n.Notify(ctx, CreateFailures, "5 consecutive VM creates failed")
+	n.Notify(ctx, DrainComplete, "drain complete")
+	if len(f.payloads) != 2 {
+		t.Fatalf("sent %d alerts, want 2 (one per kind)", len(f.payloads))
+	}
+	if got := f.payloads[0]; got.Text != "[windows-gpu] 3 consecutive VM creates failed" || got.Kind != CreateFailures || got.Source != "windows-gpu" {
+		t.Fatalf("first alert = %+v", got)
+	}
+
+	*now = now.Add(16 * time.Minute)
+	n.Notify(ctx, CreateFailures, "6 consecutive VM creates failed")
+	if len(f.payloads) != 3 {
+		t.Fatalf("sent %d alerts, want 3 after the interval", len(f.payloads))
+	}
+	got := f.payloads[2]
+	if got.Suppressed != 2 || got.Text != "[windows-gpu] 6 consecutive VM creates failed (2 similar alerts suppressed)" {
+		t.Fatalf("alert after interval = %+v, want 2 suppressed", got)
+	}
+}
+
+func TestNotifyToleratesWebhookErrors(t *testing.T) {
+	f := &fakeWebhook{status: http.StatusInternalServerError}
+	n, _ := newTestNotifier(t, f)
+	if err := n.post(context.Background(), payload{Text: "x"}); err == nil {
+		t.Fatal("post should report a non-2xx status")
+	}
+	n.Notify(context.Background(), QuotaExhausted, "no quota")
+
+	var nilNotifier *Notifier
+	nilNotifier.Notify(context.Background(), QuotaExhausted, "no quota")
+}
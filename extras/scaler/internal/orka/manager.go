@@ -0,0 +1,293 @@
+// Package orka provides macOS VM lifecycle management for ephemeral GitHub
+// Actions runners on a MacStadium Orka cluster.
+//
+// GCP cannot host macOS guests, so darwin pools (the Metal test jobs) are
+// served from Orka instead. The Manager mirrors the surface of the GCP
+// manager — CreateVM, DeleteByRunnerName, DeleteAll and the tracking
+// accessors — so the scaler can drive either backend unchanged.
+package orka
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"extras/scaler/internal/startup"
+	"extras/scaler/internal/vmstate"
+)
+
+const (
+	defaultNamespace = "orka-default"
+	defaultCPU       = 6
+	requestTimeout   = 5 * time.Minute
+)
+
+//go:embed startup.sh
+var darwinStartupScript string
+
+// ManagerConfig holds the Orka configuration for VM management.
+type ManagerConfig struct {
+	Endpoint  string // Orka API base URL (e.g., "https://10.221.188.20")
+	Token     string // Orka service-account bearer token
+	Namespace string // Orka namespace the VMs are deployed into
+	Image     string // macOS image name (e.g., "sonoma-metal-runner")
+	CPU       int    // vCPUs per VM
+	// StartupScript, when set, replaces the embedded startup script.
+	StartupScript *startup.Script
+}
+
+type vmInfo struct {
+	vmName    string
+	busy      bool
+	createdAt time.Time
+}
+
+// Manager handles creating and deleting Orka macOS VMs for GitHub Actions
+// runners.
+type Manager struct {
+	config     ManagerConfig
+	httpClient *http.Client
+
+	mu sync.Mutex
+	// runnerName -> vmInfo
+	vms            map[string]*vmInfo
+	pendingCreates map[string]struct{}
+}
+
+// NewManager creates a new Orka VM manager.
+func NewManager(cfg ManagerConfig) (*Manager, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("orka endpoint is required")
+	}
+	if cfg.Image == "" {
+		return nil, fmt.Errorf("orka image is required")
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = defaultNamespace
+	}
+	if cfg.CPU <= 0 {
+		cfg.CPU = defaultCPU
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+
+	return &Manager{
+		config:         cfg,
+		httpClient:     &http.Client{Timeout: requestTimeout},
+		vms:            make(map[string]*vmInfo),
+		pendingCreates: make(map[string]struct{}),
+	}, nil
+}
+
+// Close shuts down the manager. Orka has no long-lived client state.
+func (m *Manager) Close() {}
+
+// ActiveCount returns the number of VMs currently tracked or being created.
+func (m *Manager) ActiveCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.vms) + len(m.pendingCreates)
+}
+
+// ActiveRunnerNames returns the names of all tracked runners.
+func (m *Manager) ActiveRunnerNames() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.vms)+len(m.pendingCreates))
+	for name := range m.vms {
+		names = append(names, name)
+	}
+	for name := range m.pendingCreates {
+		if _, ok := m.vms[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// VMs returns the tracked VMs and pending creates.
+func (m *Manager) VMs() []vmstate.VM {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	vms := make([]vmstate.VM, 0, len(m.vms)+len(m.pendingCreates))
+	for name, vm := range m.vms {
+		vms = append(vms, vmstate.VM{RunnerName: name, Name: vm.vmName, Busy: vm.busy, CreatedAt: vm.createdAt})
+	}
+	for name := range m.pendingCreates {
+		if _, ok := m.vms[name]; !ok {
+			vms = append(vms, vmstate.VM{RunnerName: name, Pending: true})
+		}
+	}
+	vmstate.Sort(vms)
+	return vms
+}
+
+// MarkBusy marks a runner as busy (job started).
+func (m *Manager) MarkBusy(runnerName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if vm, ok := m.vms[runnerName]; ok {
+		vm.busy = true
+	}
+}
+
+// deployRequest is the body of an Orka VM deployment. Custom metadata is
+// exposed to the guest through the Orka VM tools, which is how the startup
+// script picks up the JIT config.
+type deployRequest struct {
+	Name     string            `json:"name"`
+	Image    string            `json:"image"`
+	CPU      int               `json:"cpu"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// CreateVM deploys a new macOS VM from the configured image and passes the
+// JIT config and startup script through VM metadata.
+func (m *Manager) CreateVM(ctx context.Context, runnerName, jitConfig string) (string, error) {
+	script := darwinStartupScript
+	if m.config.StartupScript != nil {
+		var err error
+		script, err = m.config.StartupScript.Render(runnerName)
+		if err != nil {
+			return "", err
+		}
+	}
+	if err := m.reserveCreate(runnerName); err != nil {
+		return "", err
+	}
+
+	vmName := runnerName
+	body := deployRequest{
+		Name:  vmName,
+		Image: m.config.Image,
+		CPU:   m.config.CPU,
+		Metadata: map[string]string{
+			"jit-config":     jitConfig,
+			"startup-script": script,
+		},
+	}
+
+	if err := m.do(ctx, http.MethodPost, m.vmsPath(""), body); err != nil {
+		m.releaseCreate(runnerName)
+		return "", fmt.Errorf("deploying VM %s: %w", vmName, err)
+	}
+
+	m.mu.Lock()
+	delete(m.pendingCreates, runnerName)
+	m.vms[runnerName] = &vmInfo{vmName: vmName, createdAt: time.Now()}
+	m.mu.Unlock()
+
+	slog.Info("VM created", "vm", vmName, "provider", "orka", "image", m.config.Image)
+	return vmName, nil
+}
+
+func (m *Manager) reserveCreate(runnerName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.vms[runnerName]; ok {
+		return fmt.Errorf("runner %q is already tracked", runnerName)
+	}
+	if _, ok := m.pendingCreates[runnerName]; ok {
+		return fmt.Errorf("runner %q already has a pending create", runnerName)
+	}
+	m.pendingCreates[runnerName] = struct{}{}
+	return nil
+}
+
+func (m *Manager) releaseCreate(runnerName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pendingCreates, runnerName)
+}
+
+// DeleteByRunnerName deletes the VM associated with a runner name.
+func (m *Manager) DeleteByRunnerName(ctx context.Context, runnerName string) error {
+	m.mu.Lock()
+	vm, ok := m.vms[runnerName]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("no VM found for runner %q", runnerName)
+	}
+	vmName := vm.vmName
+	delete(m.vms, runnerName)
+	m.mu.Unlock()
+
+	return m.deleteVM(ctx, vmName)
+}
+
+// DeleteAll deletes all tracked VMs. Used during shutdown.
+func (m *Manager) DeleteAll(ctx context.Context) {
+	m.mu.Lock()
+	vms := make(map[string]*vmInfo)
+	for rn, vm := range m.vms {
+		vms[rn] = vm
+	}
+	m.mu.Unlock()
+
+	for rn, vm := range vms {
+		if err := m.deleteVM(ctx, vm.vmName); err != nil {
+			slog.Error("failed to delete VM during cleanup", "vm", vm.vmName, "error", err)
+		}
+		m.mu.Lock()
+		delete(m.vms, rn)
+		m.mu.Unlock()
+	}
+}
+
+func (m *Manager) deleteVM(ctx context.Context, vmName string) error {
+	if err := m.do(ctx, http.MethodDelete, m.vmsPath(vmName), nil); err != nil {
+		return fmt.Errorf("deleting VM %s: %w", vmName, err)
+	}
+	slog.Info("VM deleted", "event", "vm_deleted", "vm", vmName, "provider", "orka")
+	return nil
+}
+
+func (m *Manager) vmsPath(vmName string) string {
+	p := "/api/v1/namespaces/" + url.PathEscape(m.config.Namespace) + "/vms"
+	if vmName != "" {
+		p += "/" + url.PathEscape(vmName)
+	}
+	return p
+}
+
+func (m *Manager) do(ctx context.Context, method, path string, body any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, m.config.Endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if m.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+m.config.Token)
+	}
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package orka
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"extras/scaler/internal/startup"
+)
+
+type fakeOrka struct {
+	mu       sync.Mutex
+	deployed map[string]deployRequest
+	deleted  []string
+	failWith int
+	auth     []string
+}
+
+func (f *fakeOrka) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	if f.failWith != 0 {
+		http.Error(w, "no capacity", f.failWith)
+		return
+	}
+	const prefix = "/api/v1/namespaces/ci/vms"
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == prefix:
+		var req deployRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.deployed[req.Name] = req
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, prefix+"/"):
+		f.deleted = append(f.deleted, strings.TrimPrefix(r.URL.Path, prefix+"/"))
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestManager(t *testing.T, f *fakeOrka) *Manager {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	m, err := NewManager(ManagerConfig{
+		Endpoint:  srv.URL + "/",
+		Token:     "secret",
+		Namespace: "ci",
+		Image:     "sonoma-metal-runner",
+	})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return m
+}
+
+func TestNewManagerRequiresEndpointAndImage(t *testing.T) {
+	if _, err := NewManager(ManagerConfig{Image: "img"}); err == nil {
+		t.Fatal("NewManager should fail without an endpoint")
+	}
+	if _, err := NewManager(ManagerConfig{Endpoint: "https://orka"}); err == nil {
+		t.Fatal("NewManager should fail without an image")
+	}
+}
+
+func TestCreateVMDeploysWithMetadata(t *testing.T) {
+	f := &fakeOrka{deployed: map[string]deployRequest{}}
+	m := newTestManager(t, f)
+
+	vmName, err := m.CreateVM(context.Background(), "mac-test-1", "jit-blob")
+	if err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if vmName != "mac-test-1" {
+		t.Fatalf("vmName = %q, want mac-test-1", vmName)
+	}
+
+	req, ok := f.deployed["mac-test-1"]
+	if !ok {
+		t.Fatal("VM was not deployed")
+	}
+	if req.Image != "sonoma-metal-runner" || req.CPU != defaultCPU {
+		t.Fatalf("deploy request = %+v, want image sonoma-metal-runner and default CPU", req)
+	}
+	if req.Metadata["jit-config"] != "jit-blob" {
+		t.Fatalf("jit-config metadata = %q, want jit-blob", req.Metadata["jit-config"])
+	}
+	if req.Metadata["startup-script"] != darwinStartupScript {
+		t.Fatal("startup-script metadata should carry the embedded darwin script")
+	}
+	if f.auth[0] != "Bearer secret" {
+		t.Fatalf("Authorization = %q, want bearer token", f.auth[0])
+	}
+	if got := m.ActiveCount(); got != 1 {
+		t.Fatalf("ActiveCount = %d, want 1", got)
+	}
+}
+
+func TestCreateVMFailureReleasesReservation(t *testing.T) {
+	f := &fakeOrka{deployed: map[string]deployRequest{}, failWith: http.StatusServiceUnavailable}
+	m := newTestManager(t, f)
+
+	_, err := m.CreateVM(context.Background(), "mac-test-1", "jit-blob")
+	if err == nil {
+		t.Fatal("CreateVM should fail when Orka rejects the deployment")
+	}
+	if !strings.Contains(err.Error(), "no capacity") {
+		t.Fatalf("error = %q, want the Orka response body", err)
+	}
+	if got := m.ActiveCount(); got != 0 {
+		t.Fatalf("ActiveCount after failed create = %d, want 0", got)
+	}
+}
+
+func TestDeleteByRunnerNameRemovesTracking(t *testing.T) {
+	f := &fakeOrka{deployed: map[string]deployRequest{}}
+	m := newTestManager(t, f)
+
+	if _, err := m.CreateVM(context.Background(), "mac-test-1", "jit"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	m.MarkBusy("mac-test-1")
+	if err := m.DeleteByRunnerName(context.Background(), "mac-test-1"); err != nil {
+		t.Fatalf("DeleteByRunnerName: %v", err)
+	}
+	if len(f.deleted) != 1 || f.deleted[0] != "mac-test-1" {
+		t.Fatalf("deleted = %v, want [mac-test-1]", f.deleted)
+	}
+	if got := m.ActiveCount(); got != 0 {
+		t.Fatalf("ActiveCount = %d, want 0", got)
+	}
+	if err := m.DeleteByRunnerName(context.Background(), "mac-test-1"); err == nil {
+		t.Fatal("deleting an untracked runner should fail")
+	}
+}
+
+func TestCreateVMRendersStartupScriptOverride(t *testing.T) {
+	f := &fakeOrka{deployed: map[string]deployRequest{}}
+	m := newTestManager(t, f)
+	script, err := startup.Parse("startup.sh", "echo {{.RunnerName}} {{.Pool}}")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	script.Vars.Pool = "mac-test"
+	m.config.StartupScript = script
+
+	if _, err := m.CreateVM(context.Background(), "mac-test-1", "jit-blob"); err != nil {
+		t.Fatalf("CreateVM: %v", err)
+	}
+	if got := f.deployed["mac-test-1"].Metadata["startup-script"]; got != "echo mac-test-1 mac-test" {
+		t.Fatalf("startup-script metadata = %q, want rendered override", got)
+	}
+}
@@ -0,0 +1,70 @@
+#!/bin/bash
+# macOS Runner Startup Script
+#
+# A LaunchDaemon baked into the Orka image fetches this script from the
+# "startup-script" VM metadata key and runs it once at boot, mirroring GCE
+# metadata startup scripts. Updating this file and redeploying the scaler is
+# enough to change the bootstrap without rebaking the image.
+#
+# Steps:
+# 1. Reads the JIT config from Orka VM metadata
+# 2. Logs Metal/GPU information for debugging
+# 3. Starts the GitHub Actions runner as the runner user
+# 4. Shuts down the VM when the job completes
+
+set -euo pipefail
+
+RUNNER_DIR="/Users/runner/actions-runner"
+RUNNER_USER="runner"
+LOG_FILE="${RUNNER_DIR}/startup.log"
+
+log() {
+  local msg
+  msg="$(date '+%Y-%m-%d %H:%M:%S') - $1"
+  echo "$msg"
+  echo "$msg" >>"$LOG_FILE"
+}
+
+# orka-vm-tools ships with Orka images and exposes the metadata passed at
+# deploy time.
+read_metadata() {
+  /usr/local/bin/orka-vm-tools metadata get "$1" 2>/dev/null
+}
+
+log "=== macOS Runner Startup ==="
+
+# Step 1: Read JIT config from VM metadata
+log "Reading JIT config from VM metadata..."
+MAX_RETRIES=10
+JIT_CONFIG=""
+
+for i in $(seq 1 "$MAX_RETRIES"); do
+  JIT_CONFIG=$(read_metadata jit-config) && [ -n "$JIT_CONFIG" ] && break
+  log "  Attempt ${i}/${MAX_RETRIES}: Metadata not available yet, waiting..."
+  sleep 5
+done
+
+if [ -z "$JIT_CONFIG" ]; then
+  log "ERROR: Failed to read JIT config from metadata after $MAX_RETRIES attempts"
+  shutdown -h now
+  exit 1
+fi
+
+log "JIT config retrieved (${#JIT_CONFIG} chars)"
+
+# Step 2: Log GPU and system info
+log "=== System Information ==="
+sw_vers 2>&1 | while read -r line; do log "  $line"; done || true
+system_profiler SPDisplaysDataType 2>&1 | grep -E 'Chipset|Metal' | while read -r line; do log "  $line"; done || log "WARNING: no Metal device information"
+
+# Step 3: Run the GitHub Actions runner as the runner user
+log "Starting runner as user '$RUNNER_USER' with JIT config..."
+cd "$RUNNER_DIR"
+
+EXIT_CODE=0
+sudo -u "$RUNNER_USER" ./run.sh --jitconfig "$JIT_CONFIG" || EXIT_CODE=$?
+log "Runner exited with code $EXIT_CODE"
+
+# Step 4: Shut down the VM
+log "=== Runner complete, shutting down VM ==="
+shutdown -h now
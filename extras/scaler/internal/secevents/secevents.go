@@ -0,0 +1,226 @@
+// Package secevents ships the scaler's security-relevant events (runners
+// registered and removed, VMs created and the identity they run as, admin
+// actions) to a Pub/Sub topic or a dedicated Cloud Logging log, for SecOps
+// to ingest into their SIEM.
+package secevents
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	logging "google.golang.org/api/logging/v2"
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+// Event is one security-relevant event.
+type Event struct {
+	Time     time.Time         `json:"time"`
+	Event    string            `json:"event"`
+	ScaleSet string            `json:"scale_set"`
+	Message  string            `json:"message"`
+	Attrs    map[string]string `json:"attrs,omitempty"`
+}
+
+// Sink writes batches of events.
+type Sink interface {
+	Write(ctx context.Context, events []Event) error
+	// String names the destination for logs.
+	String() string
+}
+
+// New returns the Sink for dest, either "pubsub:projects/P/topics/T" or
+// "logging:projects/P/logs/NAME". Credentials come from the environment and
+// need roles/pubsub.publisher on the topic or roles/logging.logWriter on
+// the project.
+func New(ctx context.Context, dest string, opts ...option.ClientOption) (Sink, error) {
+	kind, name, err := ParseDestination(dest)
+	if err != nil {
+		return nil, err
+	}
+	if kind == "pubsub" {
+		svc, err := pubsub.NewService(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating Pub/Sub client: %w", err)
+		}
+		return &pubsubSink{topic: name, svc: svc}, nil
+	}
+	svc, err := logging.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating Cloud Logging client: %w", err)
+	}
+	return &loggingSink{logName: name, project: strings.Split(name, "/")[1], svc: svc}, nil
+}
+
+// ParseDestination splits dest (see New) into its kind, "pubsub" or
+// "logging", and the topic or log name.
+func ParseDestination(dest string) (kind, name string, err error) {
+	kind, name, _ = strings.Cut(dest, ":")
+	parts := strings.Split(name, "/")
+	collection := map[string]string{"pubsub": "topics", "logging": "logs"}[kind]
+	if collection == "" || len(parts) != 4 || parts[0] != "projects" || parts[2] != collection || parts[1] == "" || parts[3] == "" {
+		return "", "", fmt.Errorf("invalid destination %q (want pubsub:projects/P/topics/T or logging:projects/P/logs/NAME)", dest)
+	}
+	return kind, name, nil
+}
+
+// pubsubSink publishes each event as a JSON message with its name and
+// scale set as attributes, so subscriptions can filter on them.
+type pubsubSink struct {
+	topic string
+	svc   *pubsub.Service
+}
+
+func (s *pubsubSink) Write(ctx context.Context, events []Event) error {
+	req := &pubsub.PublishRequest{}
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		req.Messages = append(req.Messages, &pubsub.PubsubMessage{
+			Data:       base64.StdEncoding.EncodeToString(data),
+			Attributes: map[string]string{"event": e.Event, "scale_set": e.ScaleSet},
+		})
+	}
+	if _, err := s.svc.Projects.Topics.Publish(s.topic, req).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("publishing to %s: %w", s.topic, err)
+	}
+	return nil
+}
+
+func (s *pubsubSink) String() string { return "pubsub:" + s.topic }
+
+// loggingSink writes each event as a structured entry of its own log, so a
+// log sink or SIEM integration can route it apart from the scaler's
+// operational logs.
+type loggingSink struct {
+	logName string
+	project string
+	svc     *logging.Service
+}
+
+func (s *loggingSink) Write(ctx context.Context, events []Event) error {
+	req := &logging.WriteLogEntriesRequest{
+		LogName:  s.logName,
+		Resource: &logging.MonitoredResource{Type: "global", Labels: map[string]string{"project_id": s.project}},
+	}
+	for _, e := range events {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		req.Entries = append(req.Entries, &logging.LogEntry{
+			Timestamp:   e.Time.UTC().Format(time.RFC3339Nano),
+			Severity:    "NOTICE",
+			JsonPayload: payload,
+			Labels:      map[string]string{"event": e.Event, "scale_set": e.ScaleSet},
+		})
+	}
+	if _, err := s.svc.Entries.Write(req).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("writing to %s: %w", s.logName, err)
+	}
+	return nil
+}
+
+func (s *loggingSink) String() string { return "logging:" + s.logName }
+
+// The exporter's batching and buffering limits.
+const (
+	maxBatch      = 100
+	maxPending    = 1000
+	flushInterval = time.Second
+)
+
+// Exporter buffers events and writes them to a Sink in batches, off the
+// logging path. Events a failed write leaves pending are retried with the
+// next batch; past maxPending the oldest are dropped.
+type Exporter struct {
+	sink Sink
+
+	mu      sync.Mutex
+	pending []Event
+	head    int64 // sequence number of pending[0]
+	dropped int64
+	wake    chan struct{}
+}
+
+// NewExporter returns an Exporter writing to sink once Run is called.
+func NewExporter(sink Sink) *Exporter {
+	return &Exporter{sink: sink, wake: make(chan struct{}, 1)}
+}
+
+// Emit queues e without blocking.
+func (x *Exporter) Emit(e Event) {
+	x.mu.Lock()
+	if len(x.pending) >= maxPending {
+		x.pending = x.pending[1:]
+		x.head++
+		x.dropped++
+	}
+	x.pending = append(x.pending, e)
+	full := len(x.pending) >= maxBatch
+	x.mu.Unlock()
+	if full {
+		select {
+		case x.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run writes the queued events every flushInterval, or as soon as a batch
+// fills, until ctx is done, and then writes what is left.
+func (x *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+			defer cancel()
+			for x.flush(flushCtx) {
+			}
+			return
+		case <-ticker.C:
+		case <-x.wake:
+		}
+		for x.flush(ctx) {
+		}
+	}
+}
+
+// flush writes up to maxBatch queued events and reports whether it wrote a
+// full batch, so more may be waiting.
+func (x *Exporter) flush(ctx context.Context) bool {
+	x.mu.Lock()
+	batch := x.pending[:min(len(x.pending), maxBatch):min(len(x.pending), maxBatch)]
+	end := x.head + int64(len(batch))
+	dropped := x.dropped
+	x.dropped = 0
+	x.mu.Unlock()
+	if dropped > 0 {
+		slog.Warn("dropped security events the sink did not take in time", "sink", x.sink.String(), "dropped", dropped)
+	}
+	if len(batch) == 0 {
+		return false
+	}
+	if err := x.sink.Write(ctx, batch); err != nil {
+		slog.Warn("failed to write security events, retrying with the next batch", "sink", x.sink.String(), "events", len(batch), "error", err)
+		return false
+	}
+	x.mu.Lock()
+	// Emit may have dropped some of the batch from the front meanwhile.
+	if written := end - x.head; written > 0 {
+		x.pending = x.pending[written:]
+		x.head = end
+	}
+	x.mu.Unlock()
+	return len(batch) == maxBatch
+}
@@ -0,0 +1,144 @@
+package secevents
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	logging "google.golang.org/api/logging/v2"
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+var testEvent = Event{
+	Time:     time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC),
+	Event:    "vm_created",
+	ScaleSet: "linux-gpu",
+	Message:  "created runner VM",
+	Attrs:    map[string]string{"vm": "linux-test-1", "service_account": "ci-runner@p.iam.gserviceaccount.com"},
+}
+
+func TestPubSubSinkPublishes(t *testing.T) {
+	var path string
+	var req pubsub.PublishRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte(`{"messageIds": ["1"]}`))
+	}))
+	defer srv.Close()
+
+	sink, err := New(context.Background(), "pubsub:projects/secops/topics/ci-scaler", option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := sink.Write(context.Background(), []Event{testEvent}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if path != "/v1/projects/secops/topics/ci-scaler:publish" {
+		t.Fatalf("path = %q", path)
+	}
+	if len(req.Messages) != 1 || req.Messages[0].Attributes["event"] != "vm_created" {
+		t.Fatalf("messages = %+v", req.Messages)
+	}
+	data, _ := base64.StdEncoding.DecodeString(req.Messages[0].Data)
+	var got Event
+	if err := json.Unmarshal(data, &got); err != nil || got.Attrs["service_account"] != "ci-runner@p.iam.gserviceaccount.com" {
+		t.Fatalf("message = %s (%v)", data, err)
+	}
+}
+
+func TestLoggingSinkWritesEntries(t *testing.T) {
+	var req logging.WriteLogEntriesRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	sink, err := New(context.Background(), "logging:projects/slang-runners/logs/scaler-security", option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := sink.Write(context.Background(), []Event{testEvent}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if req.LogName != "projects/slang-runners/logs/scaler-security" || len(req.Entries) != 1 {
+		t.Fatalf("request = %+v", req)
+	}
+	if e := req.Entries[0]; e.Labels["event"] != "vm_created" || e.Timestamp != "2026-06-01T12:00:00Z" {
+		t.Fatalf("entry = %+v", e)
+	}
+}
+
+func TestNewRejectsBadDestinations(t *testing.T) {
+	for _, dest := range []string{"", "projects/p/topics/t", "pubsub:projects/p/logs/l", "logging:projects/p/logs/", "kafka:projects/p/topics/t"} {
+		if _, err := New(context.Background(), dest, option.WithoutAuthentication()); err == nil {
+			t.Errorf("New(%q) should fail", dest)
+		}
+	}
+}
+
+type fakeSink struct {
+	mu      sync.Mutex
+	err     error
+	written []Event
+}
+
+func (f *fakeSink) Write(_ context.Context, events []Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.written = append(f.written, events...)
+	return nil
+}
+
+func (f *fakeSink) String() string { return "fake" }
+
+func TestExporterRetriesFailedBatches(t *testing.T) {
+	sink := &fakeSink{err: errors.New("unavailable")}
+	x := NewExporter(sink)
+	for range 150 {
+		x.Emit(Event{Event: "runner_registered"})
+	}
+	if x.flush(context.Background()) {
+		t.Fatal("a failed flush should not report more to write")
+	}
+	sink.err = nil
+	for x.flush(context.Background()) {
+	}
+	if len(sink.written) != 150 || len(x.pending) != 0 {
+		t.Fatalf("written %d, pending %d; want all 150 written once", len(sink.written), len(x.pending))
+	}
+}
+
+func TestExporterDropsOldestPastLimit(t *testing.T) {
+	x := NewExporter(&fakeSink{})
+	for i := range maxPending + 5 {
+		x.Emit(Event{Event: "runner_registered", Attrs: map[string]string{"i": strconv.Itoa(i)}})
+	}
+	if len(x.pending) != maxPending || x.dropped != 5 || x.pending[0].Attrs["i"] != "5" {
+		t.Fatalf("pending %d, dropped %d; want the 5 oldest dropped", len(x.pending), x.dropped)
+	}
+}
+
+func TestExporterFlushesOnShutdown(t *testing.T) {
+	sink := &fakeSink{}
+	x := NewExporter(sink)
+	x.Emit(testEvent)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	x.Run(ctx)
+	if len(sink.written) != 1 {
+		t.Fatalf("written %d, want the queued event written on shutdown", len(sink.written))
+	}
+}
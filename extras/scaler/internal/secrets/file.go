@@ -0,0 +1,101 @@
+package secrets
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// File reads a secret from a local file, e.g. one a config management
+// agent or a mounted Kubernetes secret keeps current. Replace it with an
+// atomic rename so a refresh never reads it half written.
+type File struct {
+	path string
+}
+
+// NewFile returns a Source for the file at path.
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Fetch returns the file's contents with surrounding whitespace trimmed.
+func (f *File) Fetch(context.Context) (string, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("secret file %s is empty", f.path)
+	}
+	return value, nil
+}
+
+func (f *File) String() string { return f.path }
+
+// EnvFile reads one variable of a systemd EnvironmentFile, such as
+// scaler.env. systemd only reads the file when the unit starts, so
+// re-reading it is what lets an edited file take effect without a
+// restart.
+type EnvFile struct {
+	path string
+	key  string
+}
+
+// NewEnvFile returns a Source for the variable key of the EnvironmentFile
+// at path.
+func NewEnvFile(path, key string) *EnvFile {
+	return &EnvFile{path: path, key: key}
+}
+
+// Fetch returns the variable's last assignment in the file. Values may be
+// quoted, and quoted values may span lines, as a PEM key does.
+func (e *EnvFile) Fetch(context.Context) (string, error) {
+	f, err := os.Open(e.path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var value string
+	found := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		key, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		v = strings.TrimSpace(v)
+		if len(v) > 0 && (v[0] == '"' || v[0] == '\'') {
+			quote := v[:1]
+			v = v[1:]
+			for !strings.HasSuffix(v, quote) && scanner.Scan() {
+				v += "\n" + scanner.Text()
+			}
+			v = strings.TrimSuffix(v, quote)
+		}
+		if key == e.key {
+			value, found = v, true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("reading %s: %w", e.path, err)
+	}
+	if !found {
+		return "", fmt.Errorf("%s does not set %s", e.path, e.key)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("%s in %s is empty", e.key, e.path)
+	}
+	return value, nil
+}
+
+func (e *EnvFile) String() string { return e.path + "#" + e.key }
@@ -0,0 +1,81 @@
+// Package secrets fetches scaler credentials from external secret stores so
+// they never have to sit in flags or a plaintext EnvironmentFile.
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/option"
+	secretmanager "google.golang.org/api/secretmanager/v1"
+)
+
+// Source fetches the current value of a single secret. Callers poll it to
+// pick up rotations.
+type Source interface {
+	Fetch(ctx context.Context) (string, error)
+	// String names the secret for logs; it never includes the value.
+	String() string
+}
+
+// SecretManager reads a GCP Secret Manager secret version.
+type SecretManager struct {
+	name string
+	svc  *secretmanager.Service
+}
+
+// NewSecretManager returns a Source for name, which is either a secret
+// ("projects/p/secrets/s", read at its latest version) or a specific
+// version ("projects/p/secrets/s/versions/3"). Credentials come from the
+// environment (the scaler VM's service account), which needs
+// roles/secretmanager.secretAccessor on the secret.
+func NewSecretManager(ctx context.Context, name string, opts ...option.ClientOption) (*SecretManager, error) {
+	name, err := secretVersionName(name)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := secretmanager.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating Secret Manager client: %w", err)
+	}
+	return &SecretManager{name: name, svc: svc}, nil
+}
+
+func secretVersionName(name string) (string, error) {
+	parts := strings.Split(name, "/")
+	switch {
+	case len(parts) == 4 && parts[0] == "projects" && parts[2] == "secrets" && parts[1] != "" && parts[3] != "":
+		return name + "/versions/latest", nil
+	case len(parts) == 6 && parts[0] == "projects" && parts[2] == "secrets" && parts[4] == "versions" &&
+		parts[1] != "" && parts[3] != "" && parts[5] != "":
+		return name, nil
+	default:
+		return "", fmt.Errorf("invalid secret %q (want projects/PROJECT/secrets/SECRET[/versions/VERSION])", name)
+	}
+}
+
+// Fetch returns the secret payload with surrounding whitespace trimmed, since
+// secrets created with `gcloud secrets create --data-file` often carry a
+// trailing newline.
+func (s *SecretManager) Fetch(ctx context.Context) (string, error) {
+	resp, err := s.svc.Projects.Secrets.Versions.Access(s.name).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("accessing %s: %w", s.name, err)
+	}
+	if resp.Payload == nil {
+		return "", fmt.Errorf("accessing %s: empty payload", s.name)
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("decoding %s: %w", s.name, err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("secret %s is empty", s.name)
+	}
+	return value, nil
+}
+
+func (s *SecretManager) String() string { return s.name }
@@ -0,0 +1,82 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/option"
+)
+
+func TestSecretVersionName(t *testing.T) {
+	tests := map[string]string{
+		"projects/p/secrets/gh-token":            "projects/p/secrets/gh-token/versions/latest",
+		"projects/p/secrets/gh-token/versions/3": "projects/p/secrets/gh-token/versions/3",
+	}
+	for in, want := range tests {
+		got, err := secretVersionName(in)
+		if err != nil {
+			t.Fatalf("secretVersionName(%q): %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("secretVersionName(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	for _, bad := range []string{"", "gh-token", "projects/p/secrets/", "projects/p/topics/t", "projects/p/secrets/s/versions/"} {
+		if _, err := secretVersionName(bad); err == nil {
+			t.Fatalf("secretVersionName(%q) should fail", bad)
+		}
+	}
+}
+
+func newTestSecretManager(t *testing.T, handler http.HandlerFunc) *SecretManager {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	s, err := NewSecretManager(context.Background(), "projects/p/secrets/gh-token",
+		option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("NewSecretManager: %v", err)
+	}
+	return s
+}
+
+func TestSecretManagerFetch(t *testing.T) {
+	var gotPath string
+	s := newTestSecretManager(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		data := base64.StdEncoding.EncodeToString([]byte("ghp_secret\n"))
+		w.Write([]byte(`{"name":"projects/p/secrets/gh-token/versions/7","payload":{"data":"` + data + `"}}`))
+	})
+
+	got, err := s.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if got != "ghp_secret" {
+		t.Fatalf("Fetch = %q, want trimmed payload", got)
+	}
+	if !strings.HasSuffix(gotPath, "projects/p/secrets/gh-token/versions/latest:access") {
+		t.Fatalf("request path = %q, want latest version access", gotPath)
+	}
+}
+
+func TestSecretManagerFetchErrors(t *testing.T) {
+	denied := newTestSecretManager(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"code":403,"message":"denied"}}`, http.StatusForbidden)
+	})
+	if _, err := denied.Fetch(context.Background()); err == nil {
+		t.Fatal("Fetch should fail on a 403")
+	}
+
+	empty := newTestSecretManager(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"payload":{"data":"` + base64.StdEncoding.EncodeToString([]byte(" \n")) + `"}}`))
+	})
+	if _, err := empty.Fetch(context.Background()); err == nil {
+		t.Fatal("Fetch should reject an empty secret")
+	}
+}
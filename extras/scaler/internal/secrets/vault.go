@@ -0,0 +1,248 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+)
+
+// vaultRenewMargin is how long before expiry a Vault token is renewed (or,
+// with GCE auth, replaced by a fresh login).
+const vaultRenewMargin = time.Minute
+
+// VaultConfig configures a Vault client.
+type VaultConfig struct {
+	Addr string // e.g. https://vault.example.com:8200
+	// Path is read with GET /v1/<Path>. Either a KV v2 data path
+	// ("secret/data/ci/scaler") or a GitHub secrets engine token path
+	// ("github/token").
+	Path string
+	// Role logs in with Vault's GCP auth method using the VM's identity
+	// token, so no Vault token is stored anywhere. Empty uses Token.
+	Role  string
+	Token string // static token, e.g. from VAULT_TOKEN
+}
+
+// Vault reads credentials from a HashiCorp Vault path and keeps its own Vault
+// token alive: renewable tokens are renewed before they expire, and GCE-auth
+// logins are repeated once the token's TTL runs out.
+type Vault struct {
+	config     VaultConfig
+	httpClient *http.Client
+	// identityToken fetches the GCE instance identity JWT for audience.
+	identityToken func(ctx context.Context, audience string) (string, error)
+	now           func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time // zero means not yet known
+	renewable bool
+}
+
+// NewVault validates cfg and returns a client. No request is made until the
+// first Fetch.
+func NewVault(cfg VaultConfig) (*Vault, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("vault address is required")
+	}
+	if _, err := url.Parse(cfg.Addr); err != nil {
+		return nil, fmt.Errorf("invalid vault address: %w", err)
+	}
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("vault path is required")
+	}
+	if cfg.Role == "" && cfg.Token == "" {
+		return nil, fmt.Errorf("vault needs a GCE auth role or a token (VAULT_TOKEN)")
+	}
+	return &Vault{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		identityToken: func(ctx context.Context, audience string) (string, error) {
+			return metadata.GetWithContext(ctx, "instance/service-accounts/default/identity?format=full&audience="+url.QueryEscape(audience))
+		},
+		now:   time.Now,
+		token: cfg.Token,
+	}, nil
+}
+
+// Field returns a Source reading one key of the secret at the configured
+// path. KV v2 secrets hold the credentials under keys of their own choosing;
+// the GitHub secrets engine returns the installation token as "token".
+func (v *Vault) Field(key string) Source {
+	return &vaultField{vault: v, key: key}
+}
+
+type vaultField struct {
+	vault *Vault
+	key   string
+}
+
+func (f *vaultField) Fetch(ctx context.Context) (string, error) {
+	data, err := f.vault.read(ctx)
+	if err != nil {
+		return "", err
+	}
+	raw, ok := data[f.key]
+	if !ok {
+		return "", fmt.Errorf("vault %s has no %q key", f.vault.config.Path, f.key)
+	}
+	value, ok := raw.(string)
+	if !ok || strings.TrimSpace(value) == "" {
+		return "", fmt.Errorf("vault %s key %q is empty or not a string", f.vault.config.Path, f.key)
+	}
+	return strings.TrimSpace(value), nil
+}
+
+func (f *vaultField) String() string { return "vault:" + f.vault.config.Path + "#" + f.key }
+
+type vaultAuth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int    `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+type vaultResponse struct {
+	Data   map[string]any `json:"data"`
+	Auth   *vaultAuth     `json:"auth"`
+	Errors []string       `json:"errors"`
+}
+
+// read returns the secret's key/value data, unwrapping the KV v2 envelope.
+func (v *Vault) read(ctx context.Context) (map[string]any, error) {
+	token, err := v.ensureToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.do(ctx, http.MethodGet, v.config.Path, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	data := resp.Data
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, kv2 := data["metadata"]; kv2 {
+			data = inner
+		}
+	}
+	return data, nil
+}
+
+// ensureToken returns a usable Vault token, logging in or renewing first
+// when the current one is missing or close to expiry.
+func (v *Vault) ensureToken(ctx context.Context) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.config.Role == "" && v.expiresAt.IsZero() {
+		// Learn the static token's TTL and whether it can be renewed on
+		// first use.
+		if err := v.lookupLocked(ctx); err != nil {
+			return "", err
+		}
+	}
+	expiring := !v.expiresAt.IsZero() && v.now().After(v.expiresAt.Add(-vaultRenewMargin))
+	switch {
+	case v.config.Role != "" && (v.token == "" || expiring):
+		if err := v.loginLocked(ctx); err != nil {
+			return "", err
+		}
+	case v.config.Role == "" && expiring && v.renewable:
+		// Renew the static token before it expires. Non-renewable tokens,
+		// such as root tokens with no TTL, are used as-is.
+		if err := v.renewLocked(ctx); err != nil {
+			return "", err
+		}
+	}
+	return v.token, nil
+}
+
+func (v *Vault) loginLocked(ctx context.Context) error {
+	jwt, err := v.identityToken(ctx, "http://vault/"+v.config.Role)
+	if err != nil {
+		return fmt.Errorf("fetching GCE identity token for vault login: %w", err)
+	}
+	body, _ := json.Marshal(map[string]string{"role": v.config.Role, "jwt": jwt})
+	resp, err := v.do(ctx, http.MethodPost, "auth/gcp/login", "", body)
+	if err != nil {
+		return fmt.Errorf("vault login: %w", err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return fmt.Errorf("vault login: response has no client token")
+	}
+	v.token = resp.Auth.ClientToken
+	v.setLeaseLocked(resp.Auth)
+	return nil
+}
+
+func (v *Vault) lookupLocked(ctx context.Context) error {
+	resp, err := v.do(ctx, http.MethodGet, "auth/token/lookup-self", v.token, nil)
+	if err != nil {
+		return fmt.Errorf("looking up vault token: %w", err)
+	}
+	ttl, _ := resp.Data["ttl"].(float64)
+	renewable, _ := resp.Data["renewable"].(bool)
+	v.setLeaseLocked(&vaultAuth{LeaseDuration: int(ttl), Renewable: renewable})
+	return nil
+}
+
+func (v *Vault) renewLocked(ctx context.Context) error {
+	resp, err := v.do(ctx, http.MethodPost, "auth/token/renew-self", v.token, []byte("{}"))
+	if err != nil {
+		return fmt.Errorf("renewing vault token: %w", err)
+	}
+	if resp.Auth == nil {
+		return fmt.Errorf("renewing vault token: response has no auth")
+	}
+	v.setLeaseLocked(resp.Auth)
+	return nil
+}
+
+func (v *Vault) setLeaseLocked(auth *vaultAuth) {
+	v.renewable = auth.Renewable
+	if auth.LeaseDuration <= 0 {
+		// TTL-less token: never expires, never needs renewal.
+		v.expiresAt = v.now().Add(100 * 365 * 24 * time.Hour)
+		return
+	}
+	v.expiresAt = v.now().Add(time.Duration(auth.LeaseDuration) * time.Second)
+}
+
+func (v *Vault) do(ctx context.Context, method, path, token string, body []byte) (*vaultResponse, error) {
+	endpoint := strings.TrimRight(v.config.Addr, "/") + "/v1/" + strings.TrimLeft(path, "/")
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out vaultResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s %s: decoding response: %w", method, path, err)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.Join(out.Errors, "; "))
+	}
+	return &out, nil
+}
@@ -0,0 +1,204 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeVault serves a KV v2 secret, the GitHub secrets engine, GCE login and
+// token lookup and renewal, recording each request.
+type fakeVault struct {
+	mu       sync.Mutex
+	requests []string
+	tokens   []string // X-Vault-Token per request
+	leaseTTL int
+	// nonRenewable makes the token non-renewable, so renew-self fails.
+	nonRenewable bool
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	f.tokens = append(f.tokens, r.Header.Get("X-Vault-Token"))
+	ttl, renewable := f.leaseTTL, !f.nonRenewable
+	f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/v1/auth/gcp/login":
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["role"] != "scaler" || body["jwt"] != "identity-jwt" {
+			http.Error(w, `{"errors":["bad login"]}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{
+			"client_token": "login-token", "lease_duration": ttl, "renewable": true,
+		}})
+	case "/v1/auth/token/lookup-self":
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+			"id": r.Header.Get("X-Vault-Token"), "ttl": ttl, "renewable": renewable,
+		}})
+	case "/v1/auth/token/renew-self":
+		if !renewable {
+			http.Error(w, `{"errors":["lease is not renewable"]}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{
+			"client_token": r.Header.Get("X-Vault-Token"), "lease_duration": ttl, "renewable": true,
+		}})
+	case "/v1/secret/data/ci/scaler":
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+			"data":     map[string]any{"token": "ghp_kv\n", "app_private_key": "PEM"},
+			"metadata": map[string]any{"version": 3},
+		}})
+	case "/v1/github/token":
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"token": "ghs_installation"}})
+	default:
+		http.Error(w, `{"errors":["no handler"]}`, http.StatusNotFound)
+	}
+}
+
+func (f *fakeVault) count(req string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, r := range f.requests {
+		if r == req {
+			n++
+		}
+	}
+	return n
+}
+
+func newTestVault(t *testing.T, f *fakeVault, cfg VaultConfig) *Vault {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	cfg.Addr = srv.URL
+	v, err := NewVault(cfg)
+	if err != nil {
+		t.Fatalf("NewVault: %v", err)
+	}
+	v.identityToken = func(_ context.Context, audience string) (string, error) {
+		if audience != "http://vault/scaler" {
+			t.Errorf("identity audience = %q, want http://vault/scaler", audience)
+		}
+		return "identity-jwt", nil
+	}
+	return v
+}
+
+func TestNewVaultValidates(t *testing.T) {
+	for _, cfg := range []VaultConfig{
+		{Path: "secret/data/x", Token: "t"},
+		{Addr: "https://vault", Token: "t"},
+		{Addr: "https://vault", Path: "secret/data/x"},
+	} {
+		if _, err := NewVault(cfg); err == nil {
+			t.Fatalf("NewVault(%+v) should fail", cfg)
+		}
+	}
+}
+
+func TestVaultReadsKVv2WithStaticToken(t *testing.T) {
+	f := &fakeVault{leaseTTL: 3600}
+	v := newTestVault(t, f, VaultConfig{Path: "secret/data/ci/scaler", Token: "static"})
+
+	got, err := v.Field("token").Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if got != "ghp_kv" {
+		t.Fatalf("token = %q, want trimmed KV value", got)
+	}
+	if _, err := v.Field("app_private_key").Fetch(context.Background()); err != nil {
+		t.Fatalf("Fetch app_private_key: %v", err)
+	}
+	if _, err := v.Field("missing").Fetch(context.Background()); err == nil {
+		t.Fatal("Fetch of a missing key should fail")
+	}
+	if n := f.count("GET /v1/auth/token/lookup-self"); n != 1 {
+		t.Fatalf("lookup-self calls = %d, want 1 (first use only)", n)
+	}
+	if n := f.count("POST /v1/auth/token/renew-self"); n != 0 {
+		t.Fatalf("renew-self calls = %d, want none while the TTL is long", n)
+	}
+	for _, tok := range f.tokens {
+		if tok != "static" {
+			t.Fatalf("request used token %q, want static", tok)
+		}
+	}
+}
+
+func TestVaultRenewsStaticTokenBeforeExpiry(t *testing.T) {
+	f := &fakeVault{leaseTTL: 120}
+	v := newTestVault(t, f, VaultConfig{Path: "secret/data/ci/scaler", Token: "static"})
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	field := v.Field("token")
+	if _, err := field.Fetch(context.Background()); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	now = now.Add(90 * time.Second) // within vaultRenewMargin of the 120s TTL
+	if _, err := field.Fetch(context.Background()); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if n := f.count("POST /v1/auth/token/renew-self"); n != 1 {
+		t.Fatalf("renew-self calls = %d, want 1", n)
+	}
+}
+
+func TestVaultUsesNonRenewableStaticTokenAsIs(t *testing.T) {
+	f := &fakeVault{nonRenewable: true} // a root token: no TTL
+	v := newTestVault(t, f, VaultConfig{Path: "secret/data/ci/scaler", Token: "root"})
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	field := v.Field("token")
+	if _, err := field.Fetch(context.Background()); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	now = now.Add(48 * time.Hour)
+	if _, err := field.Fetch(context.Background()); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if n := f.count("POST /v1/auth/token/renew-self"); n != 0 {
+		t.Fatalf("renew-self calls = %d, want none for a non-renewable token", n)
+	}
+}
+
+func TestVaultGCELoginAndGitHubEngine(t *testing.T) {
+	f := &fakeVault{leaseTTL: 300}
+	v := newTestVault(t, f, VaultConfig{Path: "github/token", Role: "scaler"})
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	field := v.Field("token")
+	got, err := field.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if got != "ghs_installation" {
+		t.Fatalf("token = %q, want installation token", got)
+	}
+	if _, err := field.Fetch(context.Background()); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if n := f.count("POST /v1/auth/gcp/login"); n != 1 {
+		t.Fatalf("logins = %d, want 1 while the token is fresh", n)
+	}
+
+	now = now.Add(5 * time.Minute)
+	if _, err := field.Fetch(context.Background()); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if n := f.count("POST /v1/auth/gcp/login"); n != 2 {
+		t.Fatalf("logins = %d, want a fresh login after the TTL ran out", n)
+	}
+}
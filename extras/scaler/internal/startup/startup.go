@@ -0,0 +1,106 @@
+// Package startup loads user-supplied VM startup scripts that replace the
+// scripts embedded in each provider, so bootstrap can be customized without
+// rebuilding the scaler.
+package startup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	storage "google.golang.org/api/storage/v1"
+)
+
+// Vars are the values a startup script template can reference, e.g.
+// {{.RunnerName}} or {{join .Labels ","}}.
+type Vars struct {
+	RunnerName string   // runner (and VM) name
+	Labels     []string // runner labels the scale set advertises
+	ScaleSet   string   // scale set name
+	Pool       string   // VM name prefix identifying the pool
+}
+
+// Script is a parsed startup script template. Vars holds the pool-wide
+// values; Render fills in the runner name per VM.
+type Script struct {
+	Vars   Vars
+	source string
+	tmpl   *template.Template
+}
+
+// Load reads the script at src, a local path or a gs://bucket/object URL,
+// and parses it as a Go text/template.
+func Load(ctx context.Context, src string) (*Script, error) {
+	data, err := Read(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(src, string(data))
+}
+
+// Parse parses text as a startup script template; name identifies it in
+// errors.
+func Parse(name, text string) (*Script, error) {
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"join": strings.Join}).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing startup script %s: %w", name, err)
+	}
+	// Catch references to fields Vars lacks now rather than on the first
+	// VM create.
+	if err := tmpl.Execute(io.Discard, Vars{}); err != nil {
+		return nil, fmt.Errorf("startup script %s: %w", name, err)
+	}
+	return &Script{source: name, tmpl: tmpl}, nil
+}
+
+// Render returns the script for runnerName.
+func (s *Script) Render(runnerName string) (string, error) {
+	v := s.Vars
+	v.RunnerName = runnerName
+	var buf bytes.Buffer
+	if err := s.tmpl.Execute(&buf, v); err != nil {
+		return "", fmt.Errorf("rendering startup script %s: %w", s.source, err)
+	}
+	return buf.String(), nil
+}
+
+func (s *Script) String() string { return s.source }
+
+// Read returns the contents of the script at src, a local path or a
+// gs://bucket/object URL, without parsing them.
+func Read(ctx context.Context, src string) ([]byte, error) {
+	rest, ok := strings.CutPrefix(src, "gs://")
+	if !ok {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return nil, fmt.Errorf("reading startup script: %w", err)
+		}
+		return data, nil
+	}
+
+	bucket, object, ok := strings.Cut(rest, "/")
+	if !ok || bucket == "" || object == "" {
+		return nil, fmt.Errorf("invalid startup script URL %q (want gs://BUCKET/OBJECT)", src)
+	}
+	svc, err := storage.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating Cloud Storage client: %w", err)
+	}
+	resp, err := svc.Objects.Get(bucket, object).Context(ctx).Download()
+	if err != nil {
+		return nil, fmt.Errorf("downloading startup script %s: %w", src, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("downloading startup script %s: %w", src, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,53 @@
+package startup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadAndRender(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "startup.sh")
+	text := "#!/bin/bash\necho {{.RunnerName}} {{.ScaleSet}} {{.Pool}} {{join .Labels \",\"}}\n"
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Load(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	s.Vars = Vars{Labels: []string{"self-hosted", "gpu"}, ScaleSet: "linux-gpu", Pool: "linux-test"}
+	got, err := s.Render("linux-test-abc")
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if want := "#!/bin/bash\necho linux-test-abc linux-gpu linux-test self-hosted,gpu\n"; got != want {
+		t.Fatalf("Render = %q, want %q", got, want)
+	}
+}
+
+func TestParseRejectsBadTemplates(t *testing.T) {
+	for _, text := range []string{
+		"echo {{.RunnerName",
+		"echo {{.JobID}}",
+	} {
+		if _, err := Parse("startup.sh", text); err == nil {
+			t.Fatalf("Parse(%q) should fail", text)
+		}
+	}
+}
+
+func TestLoadValidatesSource(t *testing.T) {
+	for _, src := range []string{"gs://bucket-only", "gs:///object", filepath.Join(t.TempDir(), "missing.sh")} {
+		_, err := Load(context.Background(), src)
+		if err == nil {
+			t.Fatalf("Load(%q) should fail", src)
+		}
+		if strings.HasPrefix(src, "gs://") && !strings.Contains(err.Error(), "gs://BUCKET/OBJECT") {
+			t.Fatalf("Load(%q) error = %v", src, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Package supervise keeps the scaler's background work going when it hits a
+// bug: a panic is recovered and logged with its stack trace, and a loop that
+// panicked is restarted after a backoff, so one unexpected nil dereference
+// in a subsystem does not take every pool down with the process.
+package supervise
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	// MinBackoff and MaxBackoff bound the wait before a loop that panicked
+	// is restarted. It doubles with each panic in a row, and starts over
+	// once the loop ran for MaxBackoff.
+	MinBackoff = time.Second
+	MaxBackoff = time.Minute
+)
+
+// PanicError is a panic recovered by Catch.
+type PanicError struct {
+	Subsystem string
+	Value     any
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s panicked: %v", e.Subsystem, e.Value)
+}
+
+// Go runs loop in a goroutine until it returns. When loop panics, the panic
+// is logged and loop is started again after a backoff, unless ctx is done.
+func Go(ctx context.Context, name string, loop func(context.Context)) {
+	go supervise(ctx, name, loop, MinBackoff, MaxBackoff)
+}
+
+func supervise(ctx context.Context, name string, loop func(context.Context), minBackoff, maxBackoff time.Duration) {
+	backoff := minBackoff
+	for {
+		start := time.Now()
+		if !panicked(name, func() { loop(ctx) }) || ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) >= maxBackoff {
+			backoff = minBackoff
+		}
+		slog.Warn("restarting subsystem after a panic", "subsystem", name, "retry_in", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// panicked runs fn and reports whether it panicked.
+func panicked(name string, fn func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			logPanic(name, r)
+			panicked = true
+		}
+	}()
+	fn()
+	return false
+}
+
+// Recover, deferred at the top of a one-off goroutine, recovers a panic of
+// the goroutine and logs it.
+func Recover(name string) {
+	if r := recover(); r != nil {
+		logPanic(name, r)
+	}
+}
+
+// Catch, deferred, recovers a panic of the calling function, logs it, and
+// returns it through err as a *PanicError.
+func Catch(name string, err *error) {
+	if r := recover(); r != nil {
+		logPanic(name, r)
+		*err = &PanicError{Subsystem: name, Value: r}
+	}
+}
+
+// logPanic logs a recovered panic with the stack of the goroutine that
+// panicked, which is still on the stack while a deferred function runs.
+func logPanic(name string, r any) {
+	slog.Error("recovered from a panic", "event", "panic", "subsystem", name, "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+}
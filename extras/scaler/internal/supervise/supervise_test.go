@@ -0,0 +1,74 @@
+package supervise
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSuperviseRestartsAfterPanic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runs := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		supervise(ctx, "test", func(context.Context) {
+			runs++
+			if runs < 3 {
+				var m map[string]int
+				m["boom"]++
+			}
+		}, time.Millisecond, 2*time.Millisecond)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervise did not return once the loop returned")
+	}
+	if runs != 3 {
+		t.Fatalf("runs = %d, want 2 restarts after panics", runs)
+	}
+}
+
+func TestSuperviseStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		supervise(ctx, "test", func(context.Context) {
+			runs++
+			cancel()
+			panic("boom")
+		}, time.Hour, time.Hour)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervise restarted a loop whose context is done")
+	}
+	if runs != 1 {
+		t.Fatalf("runs = %d, want 1", runs)
+	}
+}
+
+func TestCatchAndRecover(t *testing.T) {
+	f := func() (err error) {
+		defer Catch("handler", &err)
+		panic("boom")
+	}
+	var pe *PanicError
+	if err := f(); !errors.As(err, &pe) || pe.Subsystem != "handler" || pe.Value != "boom" {
+		t.Fatalf("err = %v, want the panic", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer Recover("one-off")
+		panic("boom")
+	}()
+	<-done
+}
@@ -0,0 +1,48 @@
+// Package vmstate describes the runner VMs a provider tracks in a
+// provider-neutral form, for the admin API and status dashboard.
+package vmstate
+
+import (
+	"sort"
+	"time"
+)
+
+// VM is one tracked runner VM, or a create still in flight.
+type VM struct {
+	RunnerName string `json:"runner_name"`
+	Name       string `json:"vm_name,omitempty"` // empty while pending
+	// Location is where the VM runs: the GCP zone, or the passed-through
+	// GPU for libvirt. Empty when the provider has nothing to report.
+	Location  string    `json:"location,omitempty"`
+	Busy      bool      `json:"busy"`
+	Pending   bool      `json:"pending"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	// ReusedAt is when a VM kept for reuse got the JIT config for its
+	// current runner; zero unless it ran a job before. Jobs counts the jobs
+	// it has started. Both are only reported by providers that reuse VMs.
+	ReusedAt time.Time `json:"reused_at,omitempty"`
+	Jobs     int       `json:"jobs,omitempty"`
+	// Reservation is the GCP reservation the VM runs in; empty for
+	// on-demand VMs and other providers.
+	Reservation string `json:"reservation,omitempty"`
+	// Phase is the last boot phase the VM reported, e.g. "driver-ok",
+	// and BootError why its boot failed. Only reported by providers that
+	// track boot phases.
+	Phase     string `json:"phase,omitempty"`
+	BootError string `json:"boot_error,omitempty"`
+	// Image is the image the VM boots from, when the provider pins an
+	// image family.
+	Image string `json:"image,omitempty"`
+	// Size is the size labels the VM was created for, e.g.
+	// "gpu-a100,cpu-32", when a job asked for a size.
+	Size string `json:"size,omitempty"`
+	// HourlyCost is the VM's estimated price in USD per hour, when the
+	// provider estimates costs. A VM running several runners reports it
+	// for one of them only, so the costs of a listing add up.
+	HourlyCost float64 `json:"hourly_cost_usd,omitempty"`
+}
+
+// Sort orders vms by runner name so listings are stable.
+func Sort(vms []VM) {
+	sort.Slice(vms, func(i, j int) bool { return vms[i].RunnerName < vms[j].RunnerName })
+}